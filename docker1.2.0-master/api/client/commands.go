@@ -15,6 +15,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -81,7 +82,7 @@ func (cli *DockerCli) CmdHelp(args ...string) error {
 		{"rm", "Remove one or more containers"},
 		{"rmi", "Remove one or more images"},
 		{"run", "Run a command in a new container"},
-		{"save", "Save an image to a tar archive"},
+		{"save", "Save one or more images to a tar archive"},
 		{"search", "Search for an image on the Docker Hub"},
 		{"start", "Start a stopped container"},
 		{"stop", "Stop a running container"},
@@ -102,8 +103,19 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	tag := cmd.String([]string{"t", "-tag"}, "", "Repository name (and optionally a tag) to be applied to the resulting image in case of success")
 	suppressOutput := cmd.Bool([]string{"q", "-quiet"}, false, "Suppress the verbose output generated by the containers")
 	noCache := cmd.Bool([]string{"#no-cache", "-no-cache"}, false, "Do not use cache when building the image")
+	cacheIgnoreMtime := cmd.Bool([]string{"-cache-ignore-mtime"}, false, "Leave the modification times of the files out of the cache of ADD and COPY, only their contents and metadata matter")
 	rm := cmd.Bool([]string{"#rm", "-rm"}, true, "Remove intermediate containers after a successful build")
 	forceRm := cmd.Bool([]string{"-force-rm"}, false, "Always remove intermediate containers, even after unsuccessful builds")
+	squash := cmd.Bool([]string{"-squash"}, false, "Squash the layers produced by the build into a single one")
+	timestamp := cmd.String([]string{"-timestamp"}, "", "Pin the creation date of the images and the times of their files, in seconds since the epoch")
+	dryRun := cmd.Bool([]string{"-dry-run"}, false, "Only parse and validate the Dockerfile, reporting all its errors without running anything")
+	gitRef := cmd.String([]string{"-git-ref"}, "", "Branch or tag to check out when PATH is a git repository")
+	gitDepth := cmd.Int([]string{"-git-depth"}, 0, "Number of commits to fetch when PATH is a git repository, 0 for the whole history")
+	flSecrets := opts.NewListOpts(nil)
+	cmd.Var(&flSecrets, []string{"-secret"}, "Secret given to the RUN instructions in /run/secrets/ID, as id=ID,src=PATH or id=ID,env=VARIABLE")
+	flBuildArgs := opts.NewListOpts(opts.ValidateEnv)
+	cmd.Var(&flBuildArgs, []string{"-build-arg"}, "Set a build-time variable declared with ARG (KEY=VALUE, or KEY to use the value of the client environment)")
+	flChunkSize := cmd.String([]string{"-chunk-size"}, "", "Send the context in chunks of this size (format: <number><optional unit>, where unit = b, k, m or g), resuming the chunks interrupted by a failure")
 	if err := cmd.Parse(args); err != nil {
 		return nil
 	}
@@ -111,11 +123,14 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		cmd.Usage()
 		return nil
 	}
+	chunkSize, err := parseChunkSize(*flChunkSize)
+	if err != nil {
+		return err
+	}
 
 	var (
 		context  archive.Archive
 		isRemote bool
-		err      error
 	)
 
 	_, err = exec.LookPath("git")
@@ -149,53 +164,80 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		//将内容压缩打包，得到最终结果 context
 		root := cmd.Arg(0)
 		if utils.IsGIT(root) {
-			remoteURL := cmd.Arg(0)
-			if !strings.HasPrefix(remoteURL, "git://") && !strings.HasPrefix(remoteURL, "git@") && !utils.IsURL(remoteURL) {
-				remoteURL = "https://" + remoteURL
-			}
-
 			root, err = ioutil.TempDir("", "docker-build-git")
 			if err != nil {
 				return err
 			}
 			defer os.RemoveAll(root)
 
-			if output, err := exec.Command("git", "clone", "--recursive", remoteURL, root).CombinedOutput(); err != nil {
-				return fmt.Errorf("Error trying to use git: %s (%s)", err, output)
+			if err := utils.GitClone(cmd.Arg(0), *gitRef, *gitDepth, root); err != nil {
+				return err
 			}
 		}
-		if _, err := os.Stat(root); err != nil {
+		fi, err := os.Stat(root)
+		if err != nil {
 			return err
 		}
-		filename := path.Join(root, "Dockerfile")
-		if _, err = os.Stat(filename); os.IsNotExist(err) {
-			return fmt.Errorf("no Dockerfile found in %s", cmd.Arg(0))
-		}
-		var excludes []string
-		ignore, err := ioutil.ReadFile(path.Join(root, ".dockerignore"))
-		if err != nil && !os.IsNotExist(err) {
-			return fmt.Errorf("Error reading .dockerignore: '%s'", err)
-		}
-		for _, pattern := range strings.Split(string(ignore), "\n") {
-			ok, err := filepath.Match(pattern, "Dockerfile")
+		if !fi.IsDir() {
+			// A context archive, compressed or not, is sent as is
+			f, err := os.Open(root)
 			if err != nil {
-				return fmt.Errorf("Bad .dockerignore pattern: '%s', error: %s", pattern, err)
+				return err
 			}
-			if ok {
-				return fmt.Errorf("Dockerfile was excluded by .dockerignore pattern '%s'", pattern)
+			defer f.Close()
+			buf := bufio.NewReader(f)
+			magic, err := buf.Peek(tarHeaderSize)
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to peek context header from %s: %v", root, err)
+			}
+			if !archive.IsArchive(magic) {
+				return fmt.Errorf("%s is neither a directory nor a tar archive", root)
+			}
+			context = ioutil.NopCloser(buf)
+		} else {
+			filename := path.Join(root, "Dockerfile")
+			if _, err = os.Stat(filename); os.IsNotExist(err) {
+				return fmt.Errorf("no Dockerfile found in %s", cmd.Arg(0))
+			}
+			var excludes []string
+			ignore, err := ioutil.ReadFile(path.Join(root, ".dockerignore"))
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("Error reading .dockerignore: '%s'", err)
+			}
+			for _, pattern := range strings.Split(string(ignore), "\n") {
+				ok, err := filepath.Match(pattern, "Dockerfile")
+				if err != nil {
+					return fmt.Errorf("Bad .dockerignore pattern: '%s', error: %s", pattern, err)
+				}
+				if ok {
+					return fmt.Errorf("Dockerfile was excluded by .dockerignore pattern '%s'", pattern)
+				}
+				excludes = append(excludes, pattern)
+			}
+			if err = utils.ValidateContextDirectory(root, excludes); err != nil {
+				return fmt.Errorf("Error checking context is accessible: '%s'. Please check permissions and try again.", err)
+			}
+			// The Dockerfile is sent first, for the daemon to start the build
+			// while it receives the rest of the context
+			includes := []string{"Dockerfile"}
+			entries, err := ioutil.ReadDir(root)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				if entry.Name() != "Dockerfile" {
+					includes = append(includes, entry.Name())
+				}
+			}
+			options := &archive.TarOptions{
+				Compression: archive.Uncompressed,
+				Includes:    includes,
+				Excludes:    excludes,
+			}
+			context, err = archive.TarWithOptions(root, options)
+			if err != nil {
+				return err
 			}
-			excludes = append(excludes, pattern)
-		}
-		if err = utils.ValidateContextDirectory(root, excludes); err != nil {
-			return fmt.Errorf("Error checking context is accessible: '%s'. Please check permissions and try again.", err)
-		}
-		options := &archive.TarOptions{
-			Compression: archive.Uncompressed,
-			Excludes:    excludes,
-		}
-		context, err = archive.TarWithOptions(root, options)
-		if err != nil {
-			return err
 		}
 	}
 	var body io.Reader
@@ -223,10 +265,19 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	}
 	if isRemote {
 		v.Set("remote", cmd.Arg(0))
+		if *gitRef != "" {
+			v.Set("gitref", *gitRef)
+		}
+		if *gitDepth > 0 {
+			v.Set("gitdepth", strconv.Itoa(*gitDepth))
+		}
 	}
 	if *noCache {
 		v.Set("nocache", "1")
 	}
+	if *cacheIgnoreMtime {
+		v.Set("ignoremtime", "1")
+	}
 	if *rm {
 		v.Set("rm", "1")
 	} else {
@@ -236,6 +287,27 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	if *forceRm {
 		v.Set("forcerm", "1")
 	}
+	if *squash {
+		v.Set("squash", "1")
+	}
+	if *timestamp != "" {
+		v.Set("timestamp", *timestamp)
+	}
+	if *dryRun {
+		v.Set("dryrun", "1")
+	}
+	if buildArgs := flBuildArgs.GetAll(); len(buildArgs) > 0 {
+		values := make(map[string]string)
+		for _, arg := range buildArgs {
+			parts := strings.SplitN(arg, "=", 2)
+			values[parts[0]] = parts[1]
+		}
+		buf, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		v.Set("buildargs", string(buf))
+	}
 
 	cli.LoadConfigFile()
 
@@ -246,7 +318,30 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	}
 	headers.Add("X-Registry-Config", base64.URLEncoding.EncodeToString(buf))
 
-	if context != nil {
+	if specs := flSecrets.GetAll(); len(specs) > 0 {
+		secrets := make(map[string][]byte)
+		for _, spec := range specs {
+			id, value, err := readSecret(spec)
+			if err != nil {
+				return err
+			}
+			secrets[id] = value
+		}
+		buf, err := json.Marshal(secrets)
+		if err != nil {
+			return err
+		}
+		headers.Add("X-Build-Secrets", base64.URLEncoding.EncodeToString(buf))
+	}
+
+	if context != nil && chunkSize > 0 {
+		id, err := cli.upload(body, chunkSize)
+		if err != nil {
+			return err
+		}
+		v.Set("upload", id)
+		body = nil
+	} else if context != nil {
 		headers.Set("Content-Type", "application/tar")
 	}
 	err = cli.stream("POST", fmt.Sprintf("/build?%s", v.Encode()), body, cli.out, headers)
@@ -453,6 +548,9 @@ func (cli *DockerCli) CmdVersion(args ...string) error {
 	if apiVersion := remoteVersion.Get("ApiVersion"); apiVersion != "" {
 		fmt.Fprintf(cli.out, "Server API version: %s\n", apiVersion)
 	}
+	if minAPIVersion := remoteVersion.Get("MinAPIVersion"); minAPIVersion != "" {
+		fmt.Fprintf(cli.out, "Server minimum API version: %s\n", minAPIVersion)
+	}
 	fmt.Fprintf(cli.out, "Go version (server): %s\n", remoteVersion.Get("GoVersion"))
 	fmt.Fprintf(cli.out, "Git commit (server): %s\n", remoteVersion.Get("GitCommit"))
 	return nil
@@ -499,6 +597,19 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 	fmt.Fprintf(cli.out, "Execution Driver: %s\n", remoteInfo.Get("ExecutionDriver"))
 	fmt.Fprintf(cli.out, "Kernel Version: %s\n", remoteInfo.Get("KernelVersion"))
 	fmt.Fprintf(cli.out, "Operating System: %s\n", remoteInfo.Get("OperatingSystem"))
+	if remoteInfo.Exists("LogsSize") {
+		logsSize := units.HumanSize(remoteInfo.GetInt64("LogsSize"))
+		if max := remoteInfo.GetInt64("LogsSizeMax"); max > 0 {
+			logsSize += " (max " + units.HumanSize(max) + ")"
+		}
+		fmt.Fprintf(cli.out, "Logs Size: %s\n", logsSize)
+	}
+	if remoteInfo.Exists("CgroupControllers") {
+		fmt.Fprintf(cli.out, "Cgroup Controllers: %s\n", strings.Join(remoteInfo.GetList("CgroupControllers"), ", "))
+		if remoteInfo.GetBool("CgroupUnified") {
+			fmt.Fprintf(cli.out, "WARNING: Some cgroup controllers are only in the unified hierarchy, which is not supported\n")
+		}
+	}
 
 	if remoteInfo.GetBool("Debug") || os.Getenv("DEBUG") != "" {
 		fmt.Fprintf(cli.out, "Debug mode (server): %v\n", remoteInfo.GetBool("Debug"))
@@ -506,6 +617,24 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 		fmt.Fprintf(cli.out, "Fds: %d\n", remoteInfo.GetInt("NFd"))
 		fmt.Fprintf(cli.out, "Goroutines: %d\n", remoteInfo.GetInt("NGoroutines"))
 		fmt.Fprintf(cli.out, "EventsListeners: %d\n", remoteInfo.GetInt("NEventsListener"))
+		fmt.Fprintf(cli.out, "Handlers: %d\n", len(remoteInfo.GetList("Handlers")))
+		for _, pair := range [][2]string{
+			{"Storage Driver Capabilities", "DriverCapabilities"},
+			{"Execution Driver Capabilities", "ExecutionDriverCapabilities"},
+		} {
+			var capabilities map[string]bool
+			if err := remoteInfo.GetJson(pair[1], &capabilities); err != nil || capabilities == nil {
+				continue
+			}
+			var names []string
+			for name, enabled := range capabilities {
+				if enabled {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			fmt.Fprintf(cli.out, "%s: %s\n", pair[0], strings.Join(names, ", "))
+		}
 
 		if initSha1 := remoteInfo.Get("InitSha1"); initSha1 != "" {
 			fmt.Fprintf(cli.out, "Init SHA1: %s\n", initSha1)
@@ -1082,7 +1211,8 @@ func (cli *DockerCli) CmdKill(args ...string) error {
 }
 
 func (cli *DockerCli) CmdImport(args ...string) error {
-	cmd := cli.Subcmd("import", "URL|- [REPOSITORY[:TAG]]", "Create an empty filesystem image and import the contents of the tarball (.tar, .tar.gz, .tgz, .bzip, .tar.xz, .txz) into it, then optionally tag it.")
+	cmd := cli.Subcmd("import", "[OPTIONS] URL|- [REPOSITORY[:TAG]]", "Create an empty filesystem image and import the contents of the tarball (.tar, .tar.gz, .tgz, .bzip, .tar.xz, .txz) into it, then optionally tag it.")
+	flChunkSize := cmd.String([]string{"-chunk-size"}, "", "Send the tarball read from STDIN in chunks of this size (format: <number><optional unit>, where unit = b, k, m or g), resuming the chunks interrupted by a failure")
 
 	if err := cmd.Parse(args); err != nil {
 		return nil
@@ -1091,6 +1221,10 @@ func (cli *DockerCli) CmdImport(args ...string) error {
 		cmd.Usage()
 		return nil
 	}
+	chunkSize, err := parseChunkSize(*flChunkSize)
+	if err != nil {
+		return err
+	}
 
 	var (
 		v          = url.Values{}
@@ -1115,7 +1249,13 @@ func (cli *DockerCli) CmdImport(args ...string) error {
 
 	var in io.Reader
 
-	if src == "-" {
+	if src == "-" && chunkSize > 0 {
+		id, err := cli.upload(cli.in, chunkSize)
+		if err != nil {
+			return err
+		}
+		v.Set("upload", id)
+	} else if src == "-" {
 		in = cli.in
 	}
 
@@ -1123,7 +1263,9 @@ func (cli *DockerCli) CmdImport(args ...string) error {
 }
 
 func (cli *DockerCli) CmdPush(args ...string) error {
-	cmd := cli.Subcmd("push", "NAME[:TAG]", "Push an image or a repository to the registry")
+	cmd := cli.Subcmd("push", "NAME[:TAG|@DIGEST]", "Push an image or a repository to the registry")
+	flTags := opts.NewListOpts(nil)
+	cmd.Var(&flTags, []string{"-tag"}, "Push only the given tags of the repository, sharing their layer uploads")
 	if err := cmd.Parse(args); err != nil {
 		return nil
 	}
@@ -1158,7 +1300,12 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 	}
 
 	v := url.Values{}
-	v.Set("tag", tag)
+	if tag != "" {
+		v.Add("tag", tag)
+	}
+	for _, tag := range flTags.GetAll() {
+		v.Add("tag", tag)
+	}
 	push := func(authConfig registry.AuthConfig) error {
 		buf, err := json.Marshal(authConfig)
 		if err != nil {
@@ -1189,7 +1336,7 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 
 //pull镜像
 func (cli *DockerCli) CmdPull(args ...string) error {
-	cmd := cli.Subcmd("pull", "NAME[:TAG]", "Pull an image or a repository from the registry")
+	cmd := cli.Subcmd("pull", "NAME[:TAG|@DIGEST]", "Pull an image or a repository from the registry")
 	tag := cmd.String([]string{"#t", "#-tag"}, "", "Download tagged image in a repository") //解析参数
 	if err := cmd.Parse(args); err != nil {
 		return nil
@@ -1497,7 +1644,7 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 	last := cmd.Int([]string{"n"}, -1, "Show n last created containers, include non-running ones.")
 
 	flFilter := opts.NewListOpts(nil)
-	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values. Valid filters:\nexited=<int> - containers with exit code of <int>")
+	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values. Valid filters:\nexited=<int> - containers with exit code of <int>\nstatus=(running|paused|restarting|exited)\nname=<regexp> - containers with a name matching <regexp>\nancestor=<image> - containers of <image> or of an image built on top of it\nlabel=<key> or label=<key>=<value>")
 
 	if err := cmd.Parse(args); err != nil {
 		return nil
@@ -1608,6 +1755,8 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 func (cli *DockerCli) CmdCommit(args ...string) error {
 	cmd := cli.Subcmd("commit", "[OPTIONS] CONTAINER [REPOSITORY[:TAG]]", "Create a new image from a container's changes")
 	flPause := cmd.Bool([]string{"p", "-pause"}, true, "Pause container during commit")
+	flSquash := cmd.Bool([]string{"-squash"}, false, "Squash the image into a single layer")
+	flTimestamp := cmd.String([]string{"-timestamp"}, "", "Pin the creation date of the image and the times of its files, in seconds since the epoch")
 	flComment := cmd.String([]string{"m", "-message"}, "", "Commit message")
 	flAuthor := cmd.String([]string{"a", "#author", "-author"}, "", "Author (e.g., \"John Hannibal Smith <hannibal@a-team.com>\")")
 	// FIXME: --run is deprecated, it will be replaced with inline Dockerfile commands.
@@ -1643,6 +1792,12 @@ func (cli *DockerCli) CmdCommit(args ...string) error {
 	if *flPause != true {
 		v.Set("pause", "0")
 	}
+	if *flSquash {
+		v.Set("squash", "1")
+	}
+	if *flTimestamp != "" {
+		v.Set("timestamp", *flTimestamp)
+	}
 
 	var (
 		config *runconfig.Config
@@ -1678,26 +1833,12 @@ func (cli *DockerCli) CmdEvents(args ...string) error {
 		cmd.Usage()
 		return nil
 	}
-	var (
-		v   = url.Values{}
-		loc = time.FixedZone(time.Now().Zone())
-	)
-	var setTime = func(key, value string) {
-		format := time.RFC3339Nano
-		if len(value) < len(format) {
-			format = format[:len(value)]
-		}
-		if t, err := time.ParseInLocation(format, value, loc); err == nil {
-			v.Set(key, strconv.FormatInt(t.Unix(), 10))
-		} else {
-			v.Set(key, value)
-		}
-	}
+	v := url.Values{}
 	if *since != "" {
-		setTime("since", *since)
+		v.Set("since", timestampToUnix(*since))
 	}
 	if *until != "" {
-		setTime("until", *until)
+		v.Set("until", timestampToUnix(*until))
 	}
 	if err := cli.stream("GET", "/events?"+v.Encode(), nil, cli.out, nil); err != nil {
 		return err
@@ -1763,6 +1904,7 @@ func (cli *DockerCli) CmdLogs(args ...string) error {
 		follow = cmd.Bool([]string{"f", "-follow"}, false, "Follow log output")
 		times  = cmd.Bool([]string{"t", "-timestamps"}, false, "Show timestamps")
 		tail   = cmd.String([]string{"-tail"}, "all", "Output the specified number of lines at the end of logs (defaults to all logs)")
+		since  = cmd.String([]string{"-since"}, "", "Show only logs created since timestamp")
 	)
 
 	if err := cmd.Parse(args); err != nil {
@@ -1796,6 +1938,9 @@ func (cli *DockerCli) CmdLogs(args ...string) error {
 	if *follow {
 		v.Set("follow", "1")
 	}
+	if *since != "" {
+		v.Set("since", timestampToUnix(*since))
+	}
 	v.Set("tail", *tail)
 
 	return cli.streamHelper("GET", "/containers/"+name+"/logs?"+v.Encode(), env.GetSubEnv("Config").GetBool("Tty"), nil, cli.out, cli.err, nil)
@@ -2265,14 +2410,15 @@ func (cli *DockerCli) CmdCp(args ...string) error {
 }
 
 func (cli *DockerCli) CmdSave(args ...string) error {
-	cmd := cli.Subcmd("save", "IMAGE", "Save an image to a tar archive (streamed to STDOUT by default)")
+	cmd := cli.Subcmd("save", "IMAGE [IMAGE...]", "Save one or more images to a tar archive (streamed to STDOUT by default)")
 	outfile := cmd.String([]string{"o", "-output"}, "", "Write to an file, instead of STDOUT")
+	buildCache := cmd.Bool([]string{"-build-cache"}, false, "Save the build cache of the images, all the images built on top of them included, without tags")
 
 	if err := cmd.Parse(args); err != nil {
 		return err
 	}
 
-	if cmd.NArg() != 1 {
+	if cmd.NArg() < 1 {
 		cmd.Usage()
 		return nil
 	}
@@ -2287,8 +2433,25 @@ func (cli *DockerCli) CmdSave(args ...string) error {
 			return err
 		}
 	}
-	image := cmd.Arg(0)
-	if err := cli.stream("GET", "/images/"+image+"/get", nil, output, nil); err != nil {
+	if *buildCache {
+		v := url.Values{}
+		for _, image := range cmd.Args() {
+			v.Add("names", image)
+		}
+		return cli.stream("GET", "/build/cache?"+v.Encode(), nil, output, nil)
+	}
+	if cmd.NArg() == 1 {
+		image := cmd.Arg(0)
+		if err := cli.stream("GET", "/images/"+image+"/get", nil, output, nil); err != nil {
+			return err
+		}
+		return nil
+	}
+	v := url.Values{}
+	for _, image := range cmd.Args() {
+		v.Add("names", image)
+	}
+	if err := cli.stream("GET", "/images/get?"+v.Encode(), nil, output, nil); err != nil {
 		return err
 	}
 	return nil
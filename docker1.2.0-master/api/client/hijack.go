@@ -38,6 +38,7 @@ func (cli *DockerCli) hijack(method, path string, setRawTerminal bool, in io.Rea
 	}
 	req.Header.Set("User-Agent", "Docker-Client/"+dockerversion.VERSION)
 	req.Header.Set("Content-Type", "plain/text")
+	req.Header.Set("Accept", api.MultiplexedStreamContentType+", "+api.RawStreamContentType)
 	req.Host = cli.addr
 
 	dial, err := cli.dial()
@@ -51,7 +52,10 @@ func (cli *DockerCli) hijack(method, path string, setRawTerminal bool, in io.Rea
 	defer clientconn.Close()
 
 	// Server hijacks the connection, error 'connection closed' expected
-	clientconn.Do(req)
+	var contentType string
+	if resp, _ := clientconn.Do(req); resp != nil {
+		contentType = resp.Header.Get("Content-Type")
+	}
 
 	rwc, br := clientconn.Hijack()
 	defer rwc.Close()
@@ -89,7 +93,7 @@ func (cli *DockerCli) hijack(method, path string, setRawTerminal bool, in io.Rea
 			}()
 
 			// When TTY is ON, use regular copy
-			if setRawTerminal && stdout != nil {
+			if !isMultiplexedStream(contentType, setRawTerminal) && stdout != nil {
 				_, err = io.Copy(stdout, br)
 			} else {
 				_, err = utils.StdCopy(stdout, stderr, br)
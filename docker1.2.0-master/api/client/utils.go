@@ -16,12 +16,14 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/term"
+	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/utils"
 )
@@ -130,6 +132,7 @@ func (cli *DockerCli) streamHelper(method, path string, setRawTerminal bool, in
 	if method == "POST" {
 		req.Header.Set("Content-Type", "plain/text")
 	}
+	req.Header.Set("Accept", api.MultiplexedStreamContentType+", "+api.RawStreamContentType+", application/json")
 
 	if headers != nil {
 		for k, v := range headers {
@@ -161,7 +164,7 @@ func (cli *DockerCli) streamHelper(method, path string, setRawTerminal bool, in
 	}
 	if stdout != nil || stderr != nil {
 		// When TTY is ON, use regular copy
-		if setRawTerminal {
+		if !isMultiplexedStream(resp.Header.Get("Content-Type"), setRawTerminal) {
 			_, err = io.Copy(stdout, resp.Body)
 		} else {
 			_, err = utils.StdCopy(stdout, stderr, resp.Body)
@@ -172,6 +175,17 @@ func (cli *DockerCli) streamHelper(method, path string, setRawTerminal bool, in
 	return nil
 }
 
+// isMultiplexedStream reports whether an attach or logs stream with the given
+// content type has to be demultiplexed. Daemons predating
+// api.MultiplexedStreamContentType always answer with the raw stream type, in
+// which case the stream is framed unless the container has a TTY.
+func isMultiplexedStream(contentType string, setRawTerminal bool) bool {
+	if api.MatchesContentType(contentType, api.MultiplexedStreamContentType) {
+		return true
+	}
+	return !setRawTerminal
+}
+
 func (cli *DockerCli) resizeTty(id string) {
 	height, width := cli.getTtySize()
 	if height == 0 && width == 0 {
@@ -259,3 +273,174 @@ func readBody(stream io.ReadCloser, statusCode int, err error) ([]byte, int, err
 	}
 	return body, statusCode, nil
 }
+
+// readSecret reads the value of a build secret given as id=ID,src=PATH, the
+// content of a file, or id=ID,env=VARIABLE, a variable of the environment.
+func readSecret(spec string) (string, []byte, error) {
+	var id, src, env string
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, fmt.Errorf("Invalid secret %q, expected id=ID,src=PATH or id=ID,env=VARIABLE", spec)
+		}
+		switch kv[0] {
+		case "id":
+			id = kv[1]
+		case "src":
+			src = kv[1]
+		case "env":
+			env = kv[1]
+		default:
+			return "", nil, fmt.Errorf("Invalid secret option %q", kv[0])
+		}
+	}
+	if id == "" || (src == "") == (env == "") {
+		return "", nil, fmt.Errorf("Invalid secret %q, expected id=ID,src=PATH or id=ID,env=VARIABLE", spec)
+	}
+	if env != "" {
+		value, exists := syscall.Getenv(env)
+		if !exists {
+			return "", nil, fmt.Errorf("Secret %s: %s is not set", id, env)
+		}
+		return id, []byte(value), nil
+	}
+	value, err := ioutil.ReadFile(src)
+	if err != nil {
+		return "", nil, fmt.Errorf("Secret %s: %s", id, err)
+	}
+	return id, value, nil
+}
+
+// timestampToUnix converts a timestamp given in the local timezone, in the
+// RFC3339 format or any prefix of it, to a unix timestamp. Values which can't
+// be parsed, like unix timestamps, are returned as is.
+func timestampToUnix(value string) string {
+	var (
+		format = time.RFC3339Nano
+		loc    = time.FixedZone(time.Now().Zone())
+	)
+	if len(value) < len(format) {
+		format = format[:len(value)]
+	}
+	if t, err := time.ParseInLocation(format, value, loc); err == nil {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return value
+}
+
+// parseChunkSize parses the --chunk-size of an upload, 0 to send it in one
+// request.
+func parseChunkSize(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	size, err := units.RAMInBytes(value)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("Invalid chunk size: %s", value)
+	}
+	return size, nil
+}
+
+// uploadRetries is how many times a chunk of an upload is sent before
+// giving up.
+const uploadRetries = 5
+
+// upload sends in to the daemon in chunks of chunkSize bytes and returns the
+// id of the upload, to build or import in place of a body.
+func (cli *DockerCli) upload(in io.Reader, chunkSize int64) (string, error) {
+	stream, _, err := cli.call("POST", "/uploads", nil, false)
+	if err != nil {
+		return "", err
+	}
+	env := engine.Env{}
+	err = env.Decode(stream)
+	stream.Close()
+	if err != nil {
+		return "", err
+	}
+	var (
+		id     = env.Get("Id")
+		chunk  = make([]byte, chunkSize)
+		offset int64
+	)
+	for {
+		n, err := io.ReadFull(in, chunk)
+		if n > 0 {
+			if offset, err = cli.sendChunk(id, offset, chunk[:n]); err != nil {
+				return "", err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return id, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// sendChunk sends the chunk at offset, and after a failure the part of it
+// the daemon hasn't stored. It returns the offset of the next chunk.
+func (cli *DockerCli) sendChunk(id string, offset int64, chunk []byte) (int64, error) {
+	var (
+		stored int64 // bytes of the chunk stored by the daemon
+		err    error
+	)
+	for retry := 0; retry < uploadRetries; retry++ {
+		if retry > 0 {
+			time.Sleep(time.Duration(retry) * time.Second)
+			size, sizeErr := cli.uploadSize(id)
+			if sizeErr != nil {
+				log.Debugf("Error getting the size of upload %s: %s", id, sizeErr)
+				continue
+			}
+			if stored = size - offset; stored < 0 || stored > int64(len(chunk)) {
+				return 0, fmt.Errorf("Upload %s has %d bytes, the chunk at %d can't be resumed", id, size, offset)
+			}
+		}
+		var size int64
+		if size, err = cli.postChunk(id, offset+stored, chunk[stored:]); err == nil {
+			return size, nil
+		}
+		log.Debugf("Error sending the chunk at %d of upload %s: %s", offset+stored, id, err)
+	}
+	return 0, err
+}
+
+// postChunk appends data to the upload at offset and returns its size.
+func (cli *DockerCli) postChunk(id string, offset int64, data []byte) (int64, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v%s/uploads/%s?offset=%d", api.APIVERSION, id, offset), bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Docker-Client/"+dockerversion.VERSION)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.URL.Host = cli.addr
+	req.URL.Scheme = cli.scheme
+	resp, err := cli.HTTPClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return 0, fmt.Errorf("Error response from daemon: %s", bytes.TrimSpace(body))
+	}
+	env := engine.Env{}
+	if err := env.Decode(resp.Body); err != nil {
+		return 0, err
+	}
+	return env.GetInt64("Size"), nil
+}
+
+func (cli *DockerCli) uploadSize(id string) (int64, error) {
+	stream, _, err := cli.call("GET", "/uploads/"+id, nil, false)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+	env := engine.Env{}
+	if err := env.Decode(stream); err != nil {
+		return 0, err
+	}
+	return env.GetInt64("Size"), nil
+}
@@ -12,9 +12,18 @@ import (
 )
 
 const (
-	APIVERSION        version.Version = "1.14"
+	APIVERSION        version.Version = "1.15"
+	MINAPIVERSION     version.Version = "1.0" // oldest version of the API the daemon serves
 	DEFAULTHTTPHOST                   = "127.0.0.1"
 	DEFAULTUNIXSOCKET                 = "/var/run/docker.sock"
+
+	// RawStreamContentType is the media type of attach and logs streams
+	// carrying the container output as is.
+	RawStreamContentType = "application/vnd.docker.raw-stream"
+	// MultiplexedStreamContentType is the media type of attach and logs
+	// streams where every chunk of output is framed with a header holding
+	// the stream id and the chunk length, see utils.StdWriter.
+	MultiplexedStreamContentType = "application/vnd.docker.multiplexed-stream"
 )
 
 func ValidateHost(val string) (string, error) {
@@ -40,6 +49,17 @@ func DisplayablePorts(ports *engine.Table) string {
 	return strings.Join(result, ", ")
 }
 
+// AcceptsContentType reports whether expectedType is listed in the given
+// Accept header.
+func AcceptsContentType(accept, expectedType string) bool {
+	for _, contentType := range strings.Split(accept, ",") {
+		if mimetype, _, err := mime.ParseMediaType(contentType); err == nil && mimetype == expectedType {
+			return true
+		}
+	}
+	return false
+}
+
 func MatchesContentType(contentType, expectedType string) bool {
 	mimetype, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessLog writes a line in the combined log format for each request,
+// followed by the id of the request.
+type accessLog struct {
+	sync.Mutex
+	w    io.Writer
+	path string // of the file the log is written to, if any
+}
+
+// openAccessLog opens the access log at path, appending to the file.
+func openAccessLog(path string) (*accessLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &accessLog{w: f, path: path}, nil
+}
+
+// reopen opens the file of the log again, for the lines to go to a new file
+// once the log was rotated.
+func (l *accessLog) reopen() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	l.Lock()
+	old := l.w
+	l.w = f
+	l.Unlock()
+	if c, ok := old.(io.Closer); ok {
+		c.Close()
+	}
+	return nil
+}
+
+func (l *accessLog) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	if c, ok := l.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// handler logs the requests served by h.
+func (l *accessLog) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &accessLogWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		h.ServeHTTP(rw, r)
+		l.write(r, rw, start)
+	})
+}
+
+func (l *accessLog) write(r *http.Request, rw *accessLogWriter, start time.Time) {
+	host := "-"
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	user := "-"
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		user = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	size := "-"
+	if rw.size > 0 && !rw.hijacked {
+		size = fmt.Sprintf("%d", rw.size)
+	}
+	requestID := rw.Header().Get("X-Request-Id")
+	if requestID == "" {
+		requestID = "-"
+	}
+	l.Lock()
+	defer l.Unlock()
+	fmt.Fprintf(l.w, "%s - %s [%s] \"%s %s %s\" %d %s %q %q %s\n",
+		host, user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, strings.Replace(r.RequestURI, "\"", "%22", -1), r.Proto,
+		rw.status, size, r.Referer(), r.UserAgent(), requestID)
+}
+
+// accessLogWriter records the status and the size of a response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// CloseNotify forwards the notifications of the connection, if it has them.
+func (w *accessLogWriter) CloseNotify() <-chan bool {
+	if n, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return n.CloseNotify()
+	}
+	return nil
+}
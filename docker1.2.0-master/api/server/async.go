@@ -0,0 +1,362 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/version"
+)
+
+// asyncRoutes are the long running routes which run in the background with
+// async=1, answering at once with the id to poll them with under /jobs.
+var asyncRoutes = map[string]map[string]struct{}{
+	"POST": {
+		"/build":                 {},
+		"/images/create":         {},
+		"/images/{name:.*}/push": {},
+	},
+}
+
+const (
+	// asyncJobRetention is how long the finished jobs are kept.
+	asyncJobRetention = time.Hour
+	// maxAsyncOutput is the output kept by a job, the oldest being dropped.
+	maxAsyncOutput = 4 << 20
+)
+
+// The statuses of an async job
+const (
+	asyncRunning   = "running"
+	asyncSucceeded = "succeeded"
+	asyncFailed    = "failed"
+	asyncCanceled  = "canceled"
+)
+
+// asyncJob is a request running in the background. It is the response
+// writer of its handler, keeping the output for the clients to poll.
+type asyncJob struct {
+	id       string
+	method   string
+	route    string
+	eng      *engine.Engine
+	created  time.Time
+	finished time.Time
+	status   string
+	err      string
+	code     int
+	header   http.Header // for the handler only
+	ctype    string      // the content type of the output
+	output   []byte
+	dropped  int // the bytes dropped from the start of the output
+	sync.Mutex
+	changed *sync.Cond
+}
+
+func (j *asyncJob) Header() http.Header {
+	return j.header
+}
+
+func (j *asyncJob) WriteHeader(code int) {
+	j.Lock()
+	j.writeHeader(code)
+	j.Unlock()
+}
+
+// writeHeader records the status and the content type of the response,
+// which are set once like those of an http response.
+func (j *asyncJob) writeHeader(code int) {
+	if j.code == 0 {
+		j.code = code
+		j.ctype = j.header.Get("Content-Type")
+	}
+}
+
+func (j *asyncJob) Write(b []byte) (int, error) {
+	j.Lock()
+	defer j.Unlock()
+	j.writeHeader(http.StatusOK)
+	j.output = append(j.output, b...)
+	if over := len(j.output) - maxAsyncOutput; over > 0 {
+		j.output = append([]byte(nil), j.output[over:]...)
+		j.dropped += over
+	}
+	j.changed.Broadcast()
+	return len(b), nil
+}
+
+// Flush lets the handlers flush their output as they would to a client.
+func (j *asyncJob) Flush() {}
+
+// finish records how the handler ended, with the error it returned.
+func (j *asyncJob) finish(err error) {
+	j.Lock()
+	defer j.Unlock()
+	j.finished = time.Now()
+	canceled := false
+	select {
+	case <-j.eng.Canceled():
+		canceled = true
+	default:
+	}
+	switch {
+	case canceled:
+		j.status = asyncCanceled
+	case err != nil:
+		j.status, j.err = asyncFailed, err.Error()
+	case j.code >= 400:
+		j.status, j.err = asyncFailed, http.StatusText(j.code)
+	default:
+		// The streams report their errors in their last message
+		j.status = asyncSucceeded
+		if msg := lastStreamError(j.output); msg != "" {
+			j.status, j.err = asyncFailed, msg
+		}
+	}
+	j.changed.Broadcast()
+}
+
+// lastStreamError returns the error of the last json message of output.
+func lastStreamError(output []byte) string {
+	lines := bytes.Split(bytes.TrimSpace(output), []byte("\n"))
+	var msg struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(lines[len(lines)-1], &msg) != nil {
+		return ""
+	}
+	return msg.Error
+}
+
+func (j *asyncJob) env() *engine.Env {
+	j.Lock()
+	defer j.Unlock()
+	out := &engine.Env{}
+	out.Set("Id", j.id)
+	out.Set("Method", j.method)
+	out.Set("Route", j.route)
+	out.Set("Status", j.status)
+	out.Set("Error", j.err)
+	out.SetInt64("Created", j.created.Unix())
+	if !j.finished.IsZero() {
+		out.SetInt64("Finished", j.finished.Unix())
+	}
+	out.SetInt64("OutputSize", int64(j.dropped+len(j.output)))
+	return out
+}
+
+// copyOutput writes the output of the job to w, following it until the job
+// finishes if follow is set.
+func (j *asyncJob) copyOutput(w io.Writer, follow bool) error {
+	j.Lock()
+	defer j.Unlock()
+	offset := j.dropped
+	for {
+		if offset < j.dropped {
+			offset = j.dropped
+		}
+		if chunk := j.output[offset-j.dropped:]; len(chunk) > 0 {
+			offset += len(chunk)
+			// The job can go on while the client reads
+			buf := append([]byte(nil), chunk...)
+			j.Unlock()
+			_, err := w.Write(buf)
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			j.Lock()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !follow || j.status != asyncRunning {
+			return nil
+		}
+		j.changed.Wait()
+	}
+}
+
+// asyncJobStore keeps the async jobs of all the listeners.
+type asyncJobStore struct {
+	sync.Mutex
+	jobs map[string]*asyncJob
+}
+
+var asyncJobs = &asyncJobStore{jobs: make(map[string]*asyncJob)}
+
+// add registers the job, pruning the jobs finished for long.
+func (s *asyncJobStore) add(j *asyncJob) error {
+	s.Lock()
+	defer s.Unlock()
+	for id, old := range s.jobs {
+		old.Lock()
+		expired := !old.finished.IsZero() && time.Since(old.finished) > asyncJobRetention
+		old.Unlock()
+		if expired {
+			delete(s.jobs, id)
+		}
+	}
+	if _, exists := s.jobs[j.id]; exists {
+		return fmt.Errorf("Conflict: a job with the id %s already exists", j.id)
+	}
+	s.jobs[j.id] = j
+	return nil
+}
+
+func (s *asyncJobStore) get(id string) (*asyncJob, error) {
+	s.Lock()
+	defer s.Unlock()
+	j, exists := s.jobs[id]
+	if !exists {
+		return nil, fmt.Errorf("No such job: %s", id)
+	}
+	return j, nil
+}
+
+// isAsync returns true if the request asks to run in the background. The
+// form is left for the handler to parse, its body being the build context
+// for some.
+func isAsync(r *http.Request) (bool, error) {
+	return getBoolParam(r.URL.Query().Get("async"))
+}
+
+// startAsync runs the handler of the request in the background, on its own
+// request engine, and answers with the id of the job. The body of the request
+// is read first, for the client to be done with the request.
+func startAsync(eng *engine.Engine, id, method, route string, handlerFunc HttpApiFunc, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	body, err := ioutil.TempFile("", "docker-async-")
+	if err != nil {
+		return err
+	}
+	os.Remove(body.Name())
+	if _, err := io.Copy(body, r.Body); err != nil {
+		body.Close()
+		return err
+	}
+	if _, err := body.Seek(0, 0); err != nil {
+		body.Close()
+		return err
+	}
+	// The request outlives its handler, which closes its body
+	req := *r
+	req.Body = body
+
+	j := &asyncJob{
+		id:      id,
+		method:  method,
+		route:   route,
+		eng:     eng.WithRequestID(id),
+		created: time.Now(),
+		status:  asyncRunning,
+		header:  make(http.Header),
+	}
+	j.changed = sync.NewCond(j)
+	if err := asyncJobs.add(j); err != nil {
+		j.eng.Release()
+		body.Close()
+		return err
+	}
+	go func() {
+		defer body.Close()
+		defer j.eng.Release()
+		err := handlerFunc(j.eng, version, j, &req, vars)
+		if err != nil {
+			apiLog.Errorf("Handler for %s %s (job %s) returned error: %s", method, route, id, err)
+		}
+		j.finish(err)
+	}()
+
+	out := &engine.Env{}
+	out.Set("Id", id)
+	return writeJSON(w, http.StatusAccepted, *out)
+}
+
+func getJobsJSON(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	asyncJobs.Lock()
+	jobs := make([]*asyncJob, 0, len(asyncJobs.jobs))
+	for _, j := range asyncJobs.jobs {
+		jobs = append(jobs, j)
+	}
+	asyncJobs.Unlock()
+	sort.Sort(byCreation(jobs))
+
+	outs := engine.NewTable("", 0)
+	for _, j := range jobs {
+		outs.Add(j.env())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err := outs.WriteListTo(w)
+	return err
+}
+
+type byCreation []*asyncJob
+
+func (b byCreation) Len() int           { return len(b) }
+func (b byCreation) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byCreation) Less(i, j int) bool { return b[i].created.Before(b[j].created) }
+
+func getJobJSON(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	j, err := asyncJobs.get(vars["id"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, *j.env())
+}
+
+func getJobLogs(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	follow, err := getBoolParam(r.Form.Get("follow"))
+	if err != nil {
+		return err
+	}
+	j, err := asyncJobs.get(vars["id"])
+	if err != nil {
+		return err
+	}
+	j.Lock()
+	contentType := j.ctype
+	j.Unlock()
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(http.StatusOK)
+	return j.copyOutput(w, follow)
+}
+
+func deleteJob(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	asyncJobs.Lock()
+	defer asyncJobs.Unlock()
+	j, exists := asyncJobs.jobs[vars["id"]]
+	if !exists {
+		return fmt.Errorf("No such job: %s", vars["id"])
+	}
+	j.Lock()
+	running := j.status == asyncRunning
+	j.Unlock()
+	if running {
+		return fmt.Errorf("Conflict: job %s is running, cancel it first", j.id)
+	}
+	delete(asyncJobs.jobs, j.id)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
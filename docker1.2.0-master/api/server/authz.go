@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAuthzBodySize is the size of the request and response bodies
+	// digested for the authorization plugins. The larger bodies, like the
+	// build contexts, are streamed without digest.
+	maxAuthzBodySize = 1024 * 1024
+	authzTimeout     = 10 * time.Second
+)
+
+// authzMessage is what the authorization plugins get, posted as json to
+// /AuthZPlugin.AuthZReq before the request is handled and to
+// /AuthZPlugin.AuthZRes before the response is sent.
+type authzMessage struct {
+	User                    string   `json:",omitempty"`
+	UserOrganizationalUnits []string `json:",omitempty"`
+	UserAuthNMethod         string   `json:",omitempty"`
+	RequestMethod           string
+	RequestURI              string
+	RequestBodyDigest       string      `json:",omitempty"`
+	ResponseStatusCode      int         `json:",omitempty"`
+	ResponseHeaders         http.Header `json:",omitempty"`
+	ResponseBodyDigest      string      `json:",omitempty"`
+}
+
+// authzReply is the answer of an authorization plugin.
+type authzReply struct {
+	Allow bool
+	Msg   string
+	Err   string
+}
+
+// authzPlugin is an authorization plugin listening on a unix or tcp socket.
+type authzPlugin struct {
+	addr   string
+	client *http.Client
+}
+
+func newAuthzPlugin(addr string) (*authzPlugin, error) {
+	parts := strings.SplitN(addr, "://", 2)
+	if len(parts) != 2 || (parts[0] != "unix" && parts[0] != "tcp") || parts[1] == "" {
+		return nil, fmt.Errorf("Invalid authorization plugin '%s', expected unix:///path/to/socket or tcp://host:port", addr)
+	}
+	proto, sockAddr := parts[0], parts[1]
+	return &authzPlugin{
+		addr: addr,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Dial: func(network, _ string) (net.Conn, error) {
+					return net.DialTimeout(proto, sockAddr, authzTimeout)
+				},
+			},
+			Timeout: authzTimeout,
+		},
+	}, nil
+}
+
+// call posts the message to the method of the plugin, returning an error
+// when the plugin denies it or can't be reached.
+func (p *authzPlugin) call(method string, msg *authzMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post("http://plugin/AuthZPlugin."+method, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Error calling the authorization plugin %s: %s", p.addr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error calling the authorization plugin %s: status %d", p.addr, resp.StatusCode)
+	}
+	var reply authzReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("Error decoding the answer of the authorization plugin %s: %s", p.addr, err)
+	}
+	if reply.Err != "" {
+		return fmt.Errorf("Error from the authorization plugin %s: %s", p.addr, reply.Err)
+	}
+	if !reply.Allow {
+		return &authzDeniedError{plugin: p.addr, msg: reply.Msg}
+	}
+	return nil
+}
+
+// authzDeniedError is returned when a plugin denies the message, as opposed
+// to the errors of the plugins failing to answer.
+type authzDeniedError struct {
+	plugin string
+	msg    string
+}
+
+func (e *authzDeniedError) Error() string {
+	return fmt.Sprintf("Forbidden by the authorization plugin %s: %s", e.plugin, e.msg)
+}
+
+// authzPlugins are called in turn on each request, the first denying it
+// stops it. No plugins allow every request.
+type authzPlugins []*authzPlugin
+
+func parseAuthzPlugins(addrs []string) (authzPlugins, error) {
+	var plugins authzPlugins
+	for _, addr := range addrs {
+		p, err := newAuthzPlugin(addr)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+// check returns the status and the error of the first plugin not allowing
+// the message: 403 when it is denied, 500 when the plugin failed.
+func (plugins authzPlugins) check(method string, msg *authzMessage) (int, error) {
+	for _, p := range plugins {
+		if err := p.call(method, msg); err != nil {
+			if _, denied := err.(*authzDeniedError); denied {
+				return http.StatusForbidden, err
+			}
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// authorizeRequest asks the plugins for the request. Once allowed, the
+// response goes through the returned writer, which asks them again before
+// sending it.
+func (plugins authzPlugins) authorizeRequest(w http.ResponseWriter, r *http.Request) (*authzResponseWriter, int, error) {
+	msg := &authzMessage{
+		RequestMethod: r.Method,
+		RequestURI:    r.RequestURI,
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		subject := r.TLS.PeerCertificates[0].Subject
+		msg.User = subject.CommonName
+		msg.UserOrganizationalUnits = subject.OrganizationalUnit
+		msg.UserAuthNMethod = "TLS"
+	}
+	if r.Body != nil {
+		bodyDigest, body, err := digestBody(r.Body)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		msg.RequestBodyDigest = bodyDigest
+		r.Body = body
+	}
+	if status, err := plugins.check("AuthZReq", msg); err != nil {
+		return nil, status, err
+	}
+	return &authzResponseWriter{ResponseWriter: w, plugins: plugins, msg: msg, status: http.StatusOK}, http.StatusOK, nil
+}
+
+// digestBody returns the digest of the body when it is small enough, and a
+// body to read in its place.
+func digestBody(body io.ReadCloser) (string, io.ReadCloser, error) {
+	buf, err := ioutil.ReadAll(io.LimitReader(body, maxAuthzBodySize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(buf) > maxAuthzBodySize {
+		return "", struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), body), body}, nil
+	}
+	body.Close()
+	return digest(buf), ioutil.NopCloser(bytes.NewReader(buf)), nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// authzResponseWriter holds the response until it is complete, or until it
+// is flushed, hijacked or too large for the plugins to see it whole, and
+// asks the plugins before sending it. A denied response is replaced by the
+// error.
+type authzResponseWriter struct {
+	http.ResponseWriter
+	plugins   authzPlugins
+	msg       *authzMessage
+	status    int
+	buf       bytes.Buffer
+	committed bool
+	err       error
+}
+
+func (w *authzResponseWriter) WriteHeader(status int) {
+	if !w.committed {
+		w.status = status
+	} else if w.err == nil {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *authzResponseWriter) Write(data []byte) (int, error) {
+	if !w.committed {
+		w.buf.Write(data)
+		if w.buf.Len() <= maxAuthzBodySize {
+			return len(data), nil
+		}
+		if err := w.commit(false); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *authzResponseWriter) Flush() {
+	if w.commit(false) != nil {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *authzResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if err := w.commit(false); err != nil {
+		return nil, nil, err
+	}
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// commit asks the plugins for the response held so far, then sends it or
+// the error. The body is digested when complete.
+func (w *authzResponseWriter) commit(complete bool) error {
+	if w.committed {
+		return w.err
+	}
+	w.committed = true
+	w.msg.ResponseStatusCode = w.status
+	w.msg.ResponseHeaders = w.Header()
+	if complete {
+		w.msg.ResponseBodyDigest = digest(w.buf.Bytes())
+	}
+	if status, err := w.plugins.check("AuthZRes", w.msg); err != nil {
+		w.err = err
+		http.Error(w.ResponseWriter, err.Error(), status)
+		return err
+	}
+	if w.status != http.StatusOK {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() > 0 {
+		if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	w.buf.Reset()
+	return nil
+}
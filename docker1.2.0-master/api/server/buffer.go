@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/listenbuffer"
+)
+
+// parseBufferOptions returns the bounds of the connections held until the
+// daemon accepts them, nil when they are not held. The connections rejected
+// are answered with a 503 unless the listener uses TLS, which a plain http
+// response would only confuse.
+func parseBufferOptions(job *engine.Job, useTls bool) (*listenbuffer.Options, error) {
+	if !job.GetenvBool("BufferRequests") {
+		return nil, nil
+	}
+	opts := &listenbuffer.Options{MaxConns: job.GetenvInt("BufferMaxConns")}
+	if opts.MaxConns < 0 {
+		return nil, fmt.Errorf("Invalid BufferMaxConns: %d", opts.MaxConns)
+	}
+	if value := job.Getenv("BufferTimeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("Invalid BufferTimeout: %s", value)
+		}
+		opts.Timeout = timeout
+	}
+	if value := job.Getenv("BufferHandshakeTimeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("Invalid BufferHandshakeTimeout: %s", value)
+		}
+		opts.HandshakeTimeout = timeout
+	}
+	if !useTls {
+		opts.Reject = rejectConn
+	}
+	return opts, nil
+}
+
+// rejectConn answers the request of a connection rejected before the
+// daemon started.
+func rejectConn(conn net.Conn, err error) {
+	fmt.Fprintf(conn, "HTTP/1.1 503 Service Unavailable\r\nContent-Type: text/plain\r\nConnection: close\r\n\r\n%s\n", err)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/version"
+)
+
+// cancelableRoutes are the long running routes whose jobs are canceled when
+// the client disconnects. None of them hijacks the connection, which the
+// close notifications can't share.
+var cancelableRoutes = map[string]map[string]struct{}{
+	"POST": {
+		"/build":                     {},
+		"/build/batch":               {},
+		"/images/create":             {},
+		"/images/{name:.*}/push":     {},
+		"/containers/{name:.*}/wait": {},
+	},
+}
+
+// cancelOnClose calls cancel if the client of w disconnects before the
+// returned function is called.
+func cancelOnClose(cancel func(), w http.ResponseWriter) func() {
+	notifier, ok := w.(http.CloseNotifier)
+	if !ok {
+		return func() {}
+	}
+	closed := notifier.CloseNotify()
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-closed:
+			cancel()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// cancelOnHangup cancels the jobs of the request engine once the client of
+// a hijacked connection, which is not expected to send anything more, hangs
+// up. It is for the attachments without stdin, which would otherwise only
+// notice when writing to the client.
+func cancelOnHangup(eng *engine.Engine, conn io.Reader) {
+	go func() {
+		io.Copy(ioutil.Discard, conn)
+		eng.Cancel()
+	}()
+}
+
+func postRequestsCancel(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := eng.CancelRequest(vars["id"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
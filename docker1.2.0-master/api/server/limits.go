@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/listenbuffer"
+)
+
+const (
+	// maxIdleClients is the number of clients whose usage is kept before
+	// the idle ones are forgotten.
+	maxIdleClients        = 1024
+	statusTooManyRequests = 429
+)
+
+// clientUsage is what a client of the api is using of its limits.
+type clientUsage struct {
+	inFlight int
+	conns    int
+	tokens   float64
+	last     time.Time
+}
+
+// apiLimits caps the requests in flight, the rate of the requests and the
+// open connections of each client, identified by its TLS certificate, the
+// user of its process on the unix sockets, or its address. A nil apiLimits
+// doesn't limit anything.
+type apiLimits struct {
+	sync.Mutex
+	maxInFlight int
+	maxConns    int
+	rate        float64
+	clients     map[string]*clientUsage
+}
+
+// newAPILimits returns the limits of maxInFlight concurrent requests, of
+// maxConns open connections and of rate requests per second, in bursts of
+// up to rate requests, by client. It returns nil without limits.
+func newAPILimits(maxInFlight, maxConns int, rate float64) *apiLimits {
+	if maxInFlight <= 0 && maxConns <= 0 && rate <= 0 {
+		return nil
+	}
+	return &apiLimits{
+		maxInFlight: maxInFlight,
+		maxConns:    maxConns,
+		rate:        rate,
+		clients:     make(map[string]*clientUsage),
+	}
+}
+
+func (l *apiLimits) burst() float64 {
+	if l.rate < 1 {
+		return 1
+	}
+	return l.rate
+}
+
+// acquire counts a request of the client, returning the function to call
+// once it is handled, or an error when the client is over its limits.
+func (l *apiLimits) acquire(client string, now time.Time) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.Lock()
+	defer l.Unlock()
+	u := l.usage(client, now)
+	if l.maxInFlight > 0 && u.inFlight >= l.maxInFlight {
+		return nil, fmt.Errorf("Too many requests: %s already has %d requests in flight", client, u.inFlight)
+	}
+	if l.rate > 0 {
+		u.tokens += now.Sub(u.last).Seconds() * l.rate
+		if u.tokens > l.burst() {
+			u.tokens = l.burst()
+		}
+		u.last = now
+		if u.tokens < 1 {
+			return nil, fmt.Errorf("Too many requests: %s is limited to %g requests per second", client, l.rate)
+		}
+		u.tokens--
+	}
+	u.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.Lock()
+			u.inFlight--
+			l.Unlock()
+		})
+	}, nil
+}
+
+// acquireConn counts a connection of the client, returning the function to
+// call once it is closed, or an error when the client has too many open.
+func (l *apiLimits) acquireConn(client string, now time.Time) (func(), error) {
+	if l == nil || l.maxConns <= 0 {
+		return func() {}, nil
+	}
+	l.Lock()
+	defer l.Unlock()
+	u := l.usage(client, now)
+	if u.conns >= l.maxConns {
+		return nil, fmt.Errorf("Too many connections: %s already has %d connections open", client, u.conns)
+	}
+	u.conns++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.Lock()
+			u.conns--
+			l.Unlock()
+		})
+	}, nil
+}
+
+// usage returns the usage of the client, which it creates if needed.
+func (l *apiLimits) usage(client string, now time.Time) *clientUsage {
+	u, exists := l.clients[client]
+	if !exists {
+		if len(l.clients) >= maxIdleClients {
+			l.forgetIdle(now)
+		}
+		u = &clientUsage{tokens: l.burst(), last: now}
+		l.clients[client] = u
+	}
+	return u
+}
+
+// forgetIdle forgets the clients without requests in flight whose rate is
+// back to a full burst.
+func (l *apiLimits) forgetIdle(now time.Time) {
+	for client, u := range l.clients {
+		if u.inFlight == 0 && u.conns == 0 && (l.rate <= 0 || u.tokens+now.Sub(u.last).Seconds()*l.rate >= l.burst()) {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// clientIdentity returns the common name of the client certificate of the
+// request, or the address of the client.
+func clientIdentity(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return "CN=" + r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return addrIdentity(r.RemoteAddr)
+}
+
+// addrIdentity returns the client of the address: the host of a tcp
+// address, the user of the process of a unix client identified by
+// identifyPeers, or else unix.
+func addrIdentity(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	if strings.HasPrefix(addr, "uid=") {
+		return strings.SplitN(addr, ",", 2)[0]
+	}
+	if addr == "" || addr == "@" {
+		return "unix"
+	}
+	return addr
+}
+
+// peerAddr is the address of a unix client, given by its credentials.
+type peerAddr string
+
+func (a peerAddr) Network() string { return "unix" }
+func (a peerAddr) String() string  { return string(a) }
+
+// peerConn is a connection of a unix socket whose remote address is the
+// process, the user and the group of its client.
+type peerConn struct {
+	net.Conn
+	addr peerAddr
+}
+
+func (c *peerConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// limitConns closes the connections of the clients over their connection
+// cap, once accepted.
+func limitConns(l net.Listener, limits *apiLimits) net.Listener {
+	if limits == nil || limits.maxConns <= 0 {
+		return l
+	}
+	return &connLimiter{Listener: l, limits: limits}
+}
+
+type connLimiter struct {
+	net.Listener
+	limits *apiLimits
+}
+
+func (l *connLimiter) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return conn, err
+		}
+		release, err := l.limits.acquireConn(addrIdentity(conn.RemoteAddr().String()), time.Now())
+		if err != nil {
+			apiLog.Errorf("Closing the connection to %s: %s", l.Addr(), err)
+			conn.Close()
+			continue
+		}
+		return &limitedConn{Conn: conn, release: release}, nil
+	}
+}
+
+// limitedConn is a connection counted by the connection cap of its client
+// until it is closed.
+type limitedConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.release()
+	return err
+}
+
+// rawConn returns the connection of the socket under the wrappers of the
+// listeners.
+func rawConn(conn net.Conn) net.Conn {
+	for {
+		wrapped := unwrapConn(conn)
+		if wrapped == conn {
+			return conn
+		}
+		conn = wrapped
+	}
+}
+
+// releaseConn stops counting conn as a connection of its client.
+func releaseConn(conn net.Conn) {
+	for {
+		if c, ok := conn.(*limitedConn); ok {
+			c.release()
+			return
+		}
+		wrapped := unwrapConn(conn)
+		if wrapped == conn {
+			return
+		}
+		conn = wrapped
+	}
+}
+
+// unwrapConn returns the connection wrapped by conn, or conn.
+func unwrapConn(conn net.Conn) net.Conn {
+	switch c := conn.(type) {
+	case *limitedConn:
+		return c.Conn
+	case *peerConn:
+		return c.Conn
+	case *writeTimeoutConn:
+		return c.Conn
+	}
+	return listenbuffer.Unwrap(conn)
+}
+
+// streamReleaser releases the in-flight slot of a request once it is
+// hijacked: the stream then only counts as a connection of its client.
+type streamReleaser struct {
+	http.ResponseWriter
+	release func()
+}
+
+func (w *streamReleaser) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *streamReleaser) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		w.release()
+	}
+	return conn, rw, err
+}
+
+// CloseNotify forwards the notifications of the connection, if it has them.
+func (w *streamReleaser) CloseNotify() <-chan bool {
+	if n, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return n.CloseNotify()
+	}
+	return nil
+}
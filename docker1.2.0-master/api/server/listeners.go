@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/proxyproto"
+)
+
+// ListenerConfig is the configuration of one of the sockets the api is
+// served on. The empty TlsCa, TlsCert, TlsKey and Group take the values of
+// the serveapi job.
+type ListenerConfig struct {
+	Addr          string // PROTO://ADDR
+	Owner         string // of the unix socket, a user name or uid
+	Group         string
+	Mode          string   // of the unix socket, in octal
+	LogPeers      bool     // log the process, user and group of the unix clients
+	ProxyProtocol bool     // read the address of the tcp clients from the PROXY protocol header
+	ProxyTrusted  []string // the addresses or CIDRs of the proxies allowed to send it
+	Tls           bool
+	TlsVerify     bool
+	TlsCa         string
+	TlsCert       string
+	TlsKey        string
+	TlsRoles      []string
+	ReadOnly      bool
+}
+
+// listenerFromJob returns the configuration of a -H address, from the
+// env of the serveapi job.
+func listenerFromJob(job *engine.Job, addr string) *ListenerConfig {
+	return &ListenerConfig{
+		Addr:          addr,
+		Owner:         job.Getenv("SocketOwner"),
+		Group:         job.Getenv("SocketGroup"),
+		Mode:          job.Getenv("SocketMode"),
+		LogPeers:      job.GetenvBool("LogPeers"),
+		ProxyProtocol: job.GetenvBool("ProxyProtocol"),
+		ProxyTrusted:  job.GetenvList("ProxyTrusted"),
+		Tls:           job.GetenvBool("Tls"),
+		TlsVerify:     job.GetenvBool("TlsVerify"),
+		TlsCa:         job.Getenv("TlsCa"),
+		TlsCert:       job.Getenv("TlsCert"),
+		TlsKey:        job.Getenv("TlsKey"),
+		TlsRoles:      job.GetenvList("TlsRoles"),
+	}
+}
+
+// listenerConfigs returns the configurations of the addresses of the job
+// and of the "Listeners" json list. A listener of the list replaces the
+// address of the job it has.
+func listenerConfigs(job *engine.Job) ([]*ListenerConfig, error) {
+	var (
+		listeners []*ListenerConfig
+		configs   []*ListenerConfig
+		index     = make(map[string]int)
+	)
+	if err := job.GetenvJson("Listeners", &configs); err != nil {
+		return nil, fmt.Errorf("Invalid listeners: %s", err)
+	}
+	for _, addr := range job.Args {
+		if _, exists := index[addr]; !exists {
+			index[addr] = len(listeners)
+			listeners = append(listeners, listenerFromJob(job, addr))
+		}
+	}
+	fromList := make(map[string]struct{})
+	for _, cfg := range configs {
+		if _, exists := fromList[cfg.Addr]; exists {
+			return nil, fmt.Errorf("Invalid listeners: %s is listed twice", cfg.Addr)
+		}
+		fromList[cfg.Addr] = struct{}{}
+		if cfg.Owner == "" {
+			cfg.Owner = job.Getenv("SocketOwner")
+		}
+		if cfg.Group == "" {
+			cfg.Group = job.Getenv("SocketGroup")
+		}
+		if cfg.Mode == "" {
+			cfg.Mode = job.Getenv("SocketMode")
+		}
+		if job.GetenvBool("LogPeers") {
+			cfg.LogPeers = true
+		}
+		if job.GetenvBool("ProxyProtocol") {
+			cfg.ProxyProtocol = true
+		}
+		if len(cfg.ProxyTrusted) == 0 {
+			cfg.ProxyTrusted = job.GetenvList("ProxyTrusted")
+		}
+		if cfg.TlsCa == "" {
+			cfg.TlsCa = job.Getenv("TlsCa")
+		}
+		if cfg.TlsCert == "" {
+			cfg.TlsCert = job.Getenv("TlsCert")
+		}
+		if cfg.TlsKey == "" {
+			cfg.TlsKey = job.Getenv("TlsKey")
+		}
+		if i, exists := index[cfg.Addr]; exists {
+			listeners[i] = cfg
+			continue
+		}
+		index[cfg.Addr] = len(listeners)
+		listeners = append(listeners, cfg)
+	}
+	for _, cfg := range listeners {
+		if parts := strings.SplitN(cfg.Addr, "://", 2); len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid listener address '%s', expected PROTO://ADDR", cfg.Addr)
+		}
+		if cfg.ProxyProtocol && strings.HasPrefix(cfg.Addr, "tcp://") {
+			if len(cfg.ProxyTrusted) == 0 {
+				return nil, fmt.Errorf("Invalid listener %s: the PROXY protocol needs the trusted proxies", cfg.Addr)
+			}
+			if _, err := proxyproto.ParseTrusted(cfg.ProxyTrusted); err != nil {
+				return nil, fmt.Errorf("Invalid listener %s: %s", cfg.Addr, err)
+			}
+		}
+	}
+	return listeners, nil
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// peerIdentifier gives the clients of a unix socket the process, the user
+// and the group of their client as remote address, read with SO_PEERCRED
+// as it accepts them, and logs them if asked to.
+type peerIdentifier struct {
+	net.Listener
+	addr string
+	log  bool
+}
+
+func identifyPeers(l net.Listener, addr string, log bool) net.Listener {
+	return &peerIdentifier{Listener: l, addr: addr, log: log}
+}
+
+func (l *peerIdentifier) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return conn, err
+	}
+	cred, err := peerCred(conn)
+	if err != nil {
+		apiLog.Errorf("Could not read the credentials of the client of %s: %s", l.addr, err)
+		return conn, nil
+	}
+	if l.log {
+		apiLog.Infof("Connection to %s from pid %d, uid %d, gid %d", l.addr, cred.Pid, cred.Uid, cred.Gid)
+	}
+	return &peerConn{Conn: conn, addr: peerAddr(fmt.Sprintf("uid=%d,gid=%d,pid=%d", cred.Uid, cred.Gid, cred.Pid))}, nil
+}
+
+func peerCred(conn net.Conn) (*syscall.Ucred, error) {
+	uc, ok := rawConn(conn).(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a unix socket", conn.RemoteAddr())
+	}
+	f, err := uc.File()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	// The duplicate shares the blocking mode of the connection, which File
+	// changed
+	defer syscall.SetNonblock(fd, true)
+	return syscall.GetsockoptUcred(fd, syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPeerCred(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-peercred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = identifyPeers(l, addr, true)
+	defer l.Close()
+
+	client, err := net.Dial("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cred, err := peerCred(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(cred.Pid) != os.Getpid() || int(cred.Uid) != os.Getuid() {
+		t.Fatalf("Expected the credentials of the test, got %#v", cred)
+	}
+	if identity := addrIdentity(conn.RemoteAddr().String()); identity != fmt.Sprintf("uid=%d", os.Getuid()) {
+		t.Fatalf("Expected the client to be identified by its user, got %s", identity)
+	}
+
+	// The connection still works once its credentials read
+	go client.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("Expected to read ping, got %q (%v)", buf, err)
+	}
+}
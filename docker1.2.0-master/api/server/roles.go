@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/engine"
+)
+
+// role is what a client of the remote api is allowed to do, each role
+// allowing what the previous ones allow.
+type role int
+
+const (
+	roleNone role = iota
+	roleReadOnly
+	roleOperator
+	roleAdmin
+)
+
+var roleNames = map[string]role{
+	"readonly": roleReadOnly,
+	"operator": roleOperator,
+	"admin":    roleAdmin,
+}
+
+func (r role) String() string {
+	for name, value := range roleNames {
+		if value == r {
+			return name
+		}
+	}
+	return "none"
+}
+
+// operatorRoutes are the routes operating the existing containers, the
+// other POST and DELETE routes need the admin role.
+var operatorRoutes = map[string]map[string]struct{}{
+	"GET": {
+		"/containers/{name:.*}/attach/ws": {},
+	},
+	"POST": {
+		"/containers/create":            {},
+		"/containers/{name:.*}/kill":    {},
+		"/containers/{name:.*}/pause":   {},
+		"/containers/{name:.*}/unpause": {},
+		"/containers/{name:.*}/restart": {},
+		"/containers/{name:.*}/start":   {},
+		"/containers/{name:.*}/stop":    {},
+		"/containers/{name:.*}/wait":    {},
+		"/containers/{name:.*}/resize":  {},
+		"/containers/{name:.*}/attach":  {},
+		"/containers/{name:.*}/copy":    {},
+	},
+}
+
+// adminRoutes are the GET routes needing the admin role, running commands
+// in the containers like the other exec routes.
+var adminRoutes = map[string]map[string]struct{}{
+	"GET": {
+		"/containers/{name:.*}/exec/ws": {},
+	},
+}
+
+// operatorHostConfig are the keys of the host configs operators may set,
+// the other ones, like Privileged, Binds, VolumesFrom, CapAdd or Devices,
+// giving the container access to the host or to the volumes of the other
+// containers.
+var operatorHostConfig = map[string]struct{}{
+	"ContainerIDFile": {},
+	"PortBindings":    {},
+	"Links":           {},
+	"PublishAllPorts": {},
+	"Dns":             {},
+	"DnsSearch":       {},
+	"NetworkMode":     {},
+	"CapDrop":         {},
+	"RestartPolicy":   {},
+	"LogConfig":       {},
+}
+
+// checkHostConfig refuses the host configs of a container start setting
+// other keys than the operatorHostConfig ones, or the network of the host or
+// of another container, which only admins may set. The body is read and put
+// back for the handler.
+func checkHostConfig(r *http.Request) error {
+	if r.Body == nil || r.ContentLength == 0 {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	hostConfig := &engine.Env{}
+	if err := hostConfig.Decode(bytes.NewReader(body)); err != nil {
+		// Left to the handler to report
+		return nil
+	}
+	for key, value := range hostConfig.Map() {
+		if _, allowed := operatorHostConfig[key]; allowed {
+			continue
+		}
+		switch value {
+		case "", "null", "false", "[]", "{}":
+		default:
+			return fmt.Errorf("Forbidden: the operator role doesn't allow setting %s, admin is required", key)
+		}
+	}
+	switch mode := hostConfig.Get("NetworkMode"); {
+	case mode == "host":
+		return fmt.Errorf("Forbidden: the operator role doesn't allow the host network, admin is required")
+	case strings.HasPrefix(mode, "container:"):
+		return fmt.Errorf("Forbidden: the operator role doesn't allow the network of another container, admin is required")
+	}
+	return nil
+}
+
+// requiredRole returns the role needed to call the route.
+func requiredRole(method, route string) role {
+	if _, exists := adminRoutes[method][route]; exists {
+		return roleAdmin
+	}
+	if _, exists := operatorRoutes[method][route]; exists {
+		return roleOperator
+	}
+	if method == "GET" || method == "OPTIONS" {
+		return roleReadOnly
+	}
+	return roleAdmin
+}
+
+// roleMapping gives a role to the certificates whose subject has all the
+// attributes.
+type roleMapping struct {
+	role       role
+	attributes [][2]string
+}
+
+func (m *roleMapping) match(subject pkix.Name) bool {
+	for _, attr := range m.attributes {
+		var values []string
+		switch attr[0] {
+		case "CN":
+			values = []string{subject.CommonName}
+		case "O":
+			values = subject.Organization
+		case "OU":
+			values = subject.OrganizationalUnit
+		case "C":
+			values = subject.Country
+		case "L":
+			values = subject.Locality
+		case "ST":
+			values = subject.Province
+		}
+		found := false
+		for _, value := range values {
+			if value == attr[1] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// tlsRoles maps the subjects of the client certificates to roles. A nil
+// tlsRoles lets every client call every route.
+type tlsRoles struct {
+	mappings []*roleMapping
+}
+
+// parseTlsRoles parses ROLE:ATTR=VALUE[,ATTR=VALUE...] specs, ATTR being CN,
+// O, OU, C, L or ST. It returns nil without specs.
+func parseTlsRoles(specs []string) (*tlsRoles, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	roles := &tlsRoles{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		r, exists := roleNames[parts[0]]
+		if !exists || len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid TLS role '%s', expected readonly, operator or admin:ATTR=VALUE[,ATTR=VALUE...]", spec)
+		}
+		m := &roleMapping{role: r}
+		for _, attr := range strings.Split(parts[1], ",") {
+			kv := strings.SplitN(attr, "=", 2)
+			if len(kv) != 2 || kv[1] == "" {
+				return nil, fmt.Errorf("Invalid subject attribute '%s' in TLS role '%s'", attr, spec)
+			}
+			switch kv[0] {
+			case "CN", "O", "OU", "C", "L", "ST":
+			default:
+				return nil, fmt.Errorf("Invalid subject attribute '%s' in TLS role '%s', expected CN, O, OU, C, L or ST", kv[0], spec)
+			}
+			m.attributes = append(m.attributes, [2]string{kv[0], kv[1]})
+		}
+		roles.mappings = append(roles.mappings, m)
+	}
+	return roles, nil
+}
+
+// roleOf returns the highest role given to the subject.
+func (roles *tlsRoles) roleOf(subject pkix.Name) role {
+	result := roleNone
+	for _, m := range roles.mappings {
+		if m.role > result && m.match(subject) {
+			result = m.role
+		}
+	}
+	return result
+}
+
+// authorize checks the role of the client certificate of the request allows
+// the route. The requests without a client certificate, on the unix socket,
+// are not checked.
+func (roles *tlsRoles) authorize(r *http.Request, method, route string) error {
+	if roles == nil || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	subject := r.TLS.PeerCertificates[0].Subject
+	required := requiredRole(method, route)
+	granted := roles.roleOf(subject)
+	if granted < required {
+		return fmt.Errorf("Forbidden: the %s role of %s doesn't allow %s %s, %s is required", granted, subject.CommonName, method, route, required)
+	}
+	if granted < roleAdmin && method == "POST" && route == "/containers/{name:.*}/start" {
+		return checkHostConfig(r)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/version"
+)
+
+// The types of the parameters of the schema
+const (
+	paramString = "string"
+	paramBool   = "bool"
+	paramInt    = "int"
+	paramJSON   = "json"
+	paramList   = "list" // the parameter is repeated
+)
+
+// The kinds of the bodies of the requests and responses of the schema
+const (
+	bodyNone      = "none"
+	bodyJSON      = "json"
+	bodyStream    = "jsonstream" // json messages, flushed as they come
+	bodyTar       = "tar"
+	bodyBinary    = "binary"
+	bodyText      = "text"
+	bodyRaw       = "raw" // the connection is hijacked
+	bodyWebsocket = "websocket"
+)
+
+// schemaParam is a query parameter or a header of an endpoint.
+type schemaParam struct {
+	Name     string
+	Type     string
+	Required bool `json:",omitempty"`
+}
+
+// routeSchema describes what a route of the api accepts and returns, the
+// requests having no body unless given.
+type routeSchema struct {
+	Params   []schemaParam
+	Headers  []schemaParam
+	Body     string
+	Response string
+}
+
+// schemaEndpoint is a route in the schema, its path variables in braces.
+type schemaEndpoint struct {
+	Method   string
+	Path     string
+	Params   []schemaParam `json:",omitempty"`
+	Headers  []schemaParam `json:",omitempty"`
+	Body     string        `json:",omitempty"`
+	Response string
+}
+
+// apiSchema is the schema of the api of the daemon, from MinVersion to
+// Version.
+type apiSchema struct {
+	Version    version.Version
+	MinVersion version.Version
+	Endpoints  []schemaEndpoint
+}
+
+var (
+	registryAuth   = schemaParam{Name: "X-Registry-Auth", Type: paramString}
+	registryConfig = schemaParam{Name: "X-Registry-Config", Type: paramString}
+	attachParams   = []schemaParam{
+		{Name: "logs", Type: paramBool},
+		{Name: "stream", Type: paramBool},
+		{Name: "stdin", Type: paramBool},
+		{Name: "stdout", Type: paramBool},
+		{Name: "stderr", Type: paramBool},
+	}
+	listContainersParams = []schemaParam{
+		{Name: "all", Type: paramBool},
+		{Name: "size", Type: paramBool},
+		{Name: "driver", Type: paramString},
+		{Name: "opt", Type: paramList},
+		{Name: "since", Type: paramString},
+		{Name: "before", Type: paramString},
+		{Name: "limit", Type: paramInt},
+		{Name: "offset", Type: paramInt},
+		{Name: "filters", Type: paramJSON},
+	}
+)
+
+// routeSchemas has the schema of each route of createRouter, by method and
+// route.
+var routeSchemas = map[string]map[string]routeSchema{
+	"GET": {
+		"/_ping":   {Params: []schemaParam{{Name: "verbose", Type: paramBool}}, Response: bodyText},
+		"/events":  {Params: []schemaParam{{Name: "since", Type: paramInt}, {Name: "until", Type: paramInt}}, Response: bodyStream},
+		"/info":    {Response: bodyJSON},
+		"/version": {Response: bodyJSON},
+		"/schema":  {Response: bodyJSON},
+		"/images/json": {
+			Params: []schemaParam{
+				{Name: "all", Type: paramBool},
+				{Name: "filter", Type: paramString},
+				{Name: "filters", Type: paramJSON},
+				{Name: "usage", Type: paramBool},
+				{Name: "limit", Type: paramInt},
+				{Name: "offset", Type: paramInt},
+			},
+			Response: bodyJSON,
+		},
+		"/images/viz": {Response: bodyText},
+		"/images/search": {
+			Params:   []schemaParam{{Name: "term", Type: paramString, Required: true}},
+			Headers:  []schemaParam{registryAuth},
+			Response: bodyJSON,
+		},
+		"/images/get":                   {Params: []schemaParam{{Name: "names", Type: paramList}}, Response: bodyTar},
+		"/build/cache":                  {Params: []schemaParam{{Name: "names", Type: paramList}}, Response: bodyTar},
+		"/images/usage":                 {Params: []schemaParam{{Name: "all", Type: paramBool}}, Response: bodyJSON},
+		"/images/{name:.*}/get":         {Response: bodyTar},
+		"/images/{name:.*}/history":     {Response: bodyJSON},
+		"/images/{name:.*}/json":        {Response: bodyJSON},
+		"/containers/ps":                {Params: listContainersParams, Response: bodyJSON},
+		"/containers/json":              {Params: listContainersParams, Response: bodyJSON},
+		"/containers/logs/usage":        {Response: bodyJSON},
+		"/containers/{name:.*}/export":  {Response: bodyTar},
+		"/containers/{name:.*}/changes": {Response: bodyJSON},
+		"/containers/{name:.*}/json":    {Response: bodyJSON},
+		"/containers/{name:.*}/top":     {Params: []schemaParam{{Name: "ps_args", Type: paramString}}, Response: bodyJSON},
+		"/containers/{name:.*}/stats":   {Response: bodyJSON},
+		"/containers/{name:.*}/logs": {
+			Params: []schemaParam{
+				{Name: "follow", Type: paramBool},
+				{Name: "stdout", Type: paramBool},
+				{Name: "stderr", Type: paramBool},
+				{Name: "timestamps", Type: paramBool},
+				{Name: "tail", Type: paramString},
+				{Name: "since", Type: paramInt},
+			},
+			Response: bodyRaw,
+		},
+		"/containers/{name:.*}/attach/ws": {
+			Params:   append([]schemaParam{{Name: "binary", Type: paramBool}}, attachParams...),
+			Response: bodyWebsocket,
+		},
+		"/containers/{name:.*}/exec/ws": {
+			Params: []schemaParam{
+				{Name: "cmd", Type: paramList, Required: true},
+				{Name: "stdin", Type: paramBool},
+				{Name: "binary", Type: paramBool},
+			},
+			Response: bodyWebsocket,
+		},
+		"/volumes/json":           {Response: bodyJSON},
+		"/volumes/{name:.*}/json": {Response: bodyJSON},
+		"/uploads/{name:.*}":      {Response: bodyJSON},
+		"/jobs/json":              {Response: bodyJSON},
+		"/jobs/{id:.*}/json":      {Response: bodyJSON},
+		"/jobs/{id:.*}/logs":      {Params: []schemaParam{{Name: "follow", Type: paramBool}}, Response: bodyStream},
+		"/loglevels":              {Response: bodyJSON},
+	},
+	"POST": {
+		"/auth": {Body: bodyJSON, Response: bodyJSON},
+		"/commit": {
+			Params: []schemaParam{
+				{Name: "container", Type: paramString, Required: true},
+				{Name: "repo", Type: paramString},
+				{Name: "tag", Type: paramString},
+				{Name: "author", Type: paramString},
+				{Name: "comment", Type: paramString},
+				{Name: "pause", Type: paramBool},
+				{Name: "squash", Type: paramBool},
+				{Name: "timestamp", Type: paramInt},
+			},
+			Body:     bodyJSON,
+			Response: bodyJSON,
+		},
+		"/build": {
+			Params: []schemaParam{
+				{Name: "t", Type: paramString},
+				{Name: "remote", Type: paramString},
+				{Name: "gitref", Type: paramString},
+				{Name: "gitdepth", Type: paramInt},
+				{Name: "upload", Type: paramString},
+				{Name: "q", Type: paramBool},
+				{Name: "nocache", Type: paramBool},
+				{Name: "rm", Type: paramBool},
+				{Name: "forcerm", Type: paramBool},
+				{Name: "squash", Type: paramBool},
+				{Name: "timestamp", Type: paramInt},
+				{Name: "buildargs", Type: paramJSON},
+				{Name: "steps", Type: paramBool},
+				{Name: "dryrun", Type: paramBool},
+				{Name: "ignoremtime", Type: paramBool},
+				{Name: "async", Type: paramBool},
+			},
+			Headers:  []schemaParam{registryAuth, registryConfig, {Name: "X-Build-Secrets", Type: paramString}},
+			Body:     bodyTar,
+			Response: bodyStream,
+		},
+		"/build/batch": {
+			Params: []schemaParam{
+				{Name: "workers", Type: paramInt},
+				{Name: "rm", Type: paramBool},
+				{Name: "forcerm", Type: paramBool},
+			},
+			Headers:  []schemaParam{registryConfig},
+			Body:     bodyJSON,
+			Response: bodyStream,
+		},
+		"/images/create": {
+			Params: []schemaParam{
+				{Name: "fromImage", Type: paramString},
+				{Name: "fromSrc", Type: paramString},
+				{Name: "repo", Type: paramString},
+				{Name: "tag", Type: paramString},
+				{Name: "upload", Type: paramString},
+				{Name: "async", Type: paramBool},
+			},
+			Headers:  []schemaParam{registryAuth},
+			Body:     bodyTar,
+			Response: bodyStream,
+		},
+		"/images/load": {Body: bodyTar, Response: bodyStream},
+		"/images/{name:.*}/push": {
+			Params:   []schemaParam{{Name: "tag", Type: paramList}, {Name: "async", Type: paramBool}},
+			Headers:  []schemaParam{registryAuth},
+			Response: bodyStream,
+		},
+		"/images/{name:.*}/tag": {
+			Params: []schemaParam{
+				{Name: "repo", Type: paramString, Required: true},
+				{Name: "tag", Type: paramString},
+				{Name: "force", Type: paramBool},
+			},
+			Response: bodyNone,
+		},
+		"/containers/create":            {Params: []schemaParam{{Name: "name", Type: paramString}}, Body: bodyJSON, Response: bodyJSON},
+		"/containers/{name:.*}/kill":    {Params: []schemaParam{{Name: "signal", Type: paramString}}, Response: bodyNone},
+		"/containers/{name:.*}/pause":   {Response: bodyNone},
+		"/containers/{name:.*}/unpause": {Response: bodyNone},
+		"/containers/{name:.*}/restart": {Params: []schemaParam{{Name: "t", Type: paramInt}}, Response: bodyNone},
+		"/containers/{name:.*}/start":   {Body: bodyJSON, Response: bodyNone},
+		"/containers/{name:.*}/stop":    {Params: []schemaParam{{Name: "t", Type: paramInt}}, Response: bodyNone},
+		"/containers/{name:.*}/wait":    {Params: []schemaParam{{Name: "timeout", Type: paramInt}}, Response: bodyJSON},
+		"/containers/{name:.*}/resize": {
+			Params: []schemaParam{
+				{Name: "h", Type: paramInt, Required: true},
+				{Name: "w", Type: paramInt, Required: true},
+			},
+			Response: bodyNone,
+		},
+		"/containers/{name:.*}/attach": {Params: attachParams, Response: bodyRaw},
+		"/containers/{name:.*}/copy":   {Body: bodyJSON, Response: bodyTar},
+		"/volumes/create": {
+			Params:   []schemaParam{{Name: "name", Type: paramString}, {Name: "size", Type: paramString}},
+			Response: bodyJSON,
+		},
+		"/volumes/prune": {Params: []schemaParam{{Name: "all", Type: paramBool}}, Response: bodyJSON},
+		"/images/gc": {
+			Params: []schemaParam{
+				{Name: "dryrun", Type: paramBool},
+				{Name: "maxage", Type: paramString},
+				{Name: "keeptags", Type: paramInt},
+			},
+			Response: bodyJSON,
+		},
+		"/graph/migrate": {
+			Params:   []schemaParam{{Name: "from", Type: paramString, Required: true}, {Name: "opt", Type: paramList}},
+			Response: bodyStream,
+		},
+		"/graph/fsck":              {Params: []schemaParam{{Name: "repair", Type: paramBool}, {Name: "removedirs", Type: paramBool}}, Response: bodyJSON},
+		"/uploads":                 {Response: bodyJSON},
+		"/uploads/{name:.*}":       {Params: []schemaParam{{Name: "offset", Type: paramInt, Required: true}}, Body: bodyBinary, Response: bodyJSON},
+		"/requests/{id:.*}/cancel": {Response: bodyNone},
+		"/loglevels": {
+			// The level of each logger, named after it
+			Params: []schemaParam{
+				{Name: "default", Type: paramString},
+				{Name: "daemon", Type: paramString},
+				{Name: "bridge", Type: paramString},
+				{Name: "execdriver", Type: paramString},
+				{Name: "api", Type: paramString},
+			},
+			Response: bodyJSON,
+		},
+	},
+	"DELETE": {
+		"/containers/{name:.*}": {
+			Params: []schemaParam{
+				{Name: "force", Type: paramBool},
+				{Name: "v", Type: paramBool},
+				{Name: "link", Type: paramBool},
+			},
+			Response: bodyNone,
+		},
+		"/images/{name:.*}": {
+			Params:   []schemaParam{{Name: "force", Type: paramBool}, {Name: "noprune", Type: paramBool}},
+			Response: bodyJSON,
+		},
+		"/volumes/{name:.*}": {Response: bodyNone},
+		"/uploads/{name:.*}": {Response: bodyNone},
+		"/jobs/{id:.*}":      {Response: bodyNone},
+	},
+}
+
+// routeVariable matches the patterns of the variables of the routes.
+var routeVariable = regexp.MustCompile(`\{([a-z]+):[^}]*\}`)
+
+// getSchema returns the endpoints of the latest version of the api, the
+// parameters they accept and what they return, for the clients generated
+// from it. It was added in the API v1.15, so it only describes the latest
+// version.
+func getSchema(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var endpoints []schemaEndpoint
+	for method, routes := range apiRoutes() {
+		for route := range routes {
+			if route == "" {
+				continue
+			}
+			schema := routeSchemas[method][route]
+			endpoints = append(endpoints, schemaEndpoint{
+				Method:   method,
+				Path:     routeVariable.ReplaceAllString(route, "{$1}"),
+				Params:   schema.Params,
+				Headers:  schema.Headers,
+				Body:     schema.Body,
+				Response: schema.Response,
+			})
+		}
+	}
+	sort.Sort(byPath(endpoints))
+	// Not an engine.Env, which would turn the versions into numbers
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(&apiSchema{
+		Version:    api.APIVERSION,
+		MinVersion: api.MINAPIVERSION,
+		Endpoints:  endpoints,
+	})
+}
+
+type byPath []schemaEndpoint
+
+func (e byPath) Len() int      { return len(e) }
+func (e byPath) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byPath) Less(i, j int) bool {
+	if e[i].Path != e[j].Path {
+		return e[i].Path < e[j].Path
+	}
+	return e[i].Method < e[j].Method
+}
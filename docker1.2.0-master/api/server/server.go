@@ -3,8 +3,6 @@ package server
 import (
 	"bufio"
 	"bytes"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"expvar"
@@ -15,9 +13,13 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"code.google.com/p/go.net/websocket"
 	"github.com/docker/libcontainer/user"
@@ -28,6 +30,8 @@ import (
 	"github.com/docker/docker/pkg/listenbuffer"
 	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/proxyproto"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/systemd"
 	"github.com/docker/docker/pkg/version"
 	"github.com/docker/docker/registry"
@@ -35,11 +39,21 @@ import (
 )
 
 var (
+	apiLog = log.New("api")
+
 	activationLock chan struct{}
 )
 
 type HttpApiFunc func(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error
 
+// apiAccess restricts the requests served on a listener.
+type apiAccess struct {
+	readOnly bool
+	roles    *tlsRoles
+	authz    authzPlugins
+	limits   *apiLimits
+}
+
 func hijackServer(w http.ResponseWriter) (io.ReadCloser, io.Writer, error) {
 	conn, _, err := w.(http.Hijacker).Hijack()
 	if err != nil {
@@ -50,6 +64,19 @@ func hijackServer(w http.ResponseWriter) (io.ReadCloser, io.Writer, error) {
 	return conn, conn, nil
 }
 
+// closeWrite closes the writing side of a tcp stream, and the other streams
+// entirely. The stream then stops counting as a connection of its client.
+func closeWrite(stream io.Closer) error {
+	if conn, ok := stream.(net.Conn); ok {
+		if tcpc, ok := rawConn(conn).(*net.TCPConn); ok {
+			err := tcpc.CloseWrite()
+			releaseConn(conn)
+			return err
+		}
+	}
+	return stream.Close()
+}
+
 //If we don't do this, POST method without Content-type (even with empty body) will fail
 func parseForm(r *http.Request) error {
 	if r == nil {
@@ -88,7 +115,7 @@ func httpError(w http.ResponseWriter, err error) {
 	}
 
 	if err != nil {
-		log.Errorf("HTTP Error: statusCode=%d %s", statusCode, err.Error())
+		apiLog.Errorf("HTTP Error: statusCode=%d %s", statusCode, err.Error())
 		http.Error(w, err.Error(), statusCode)
 	}
 }
@@ -144,6 +171,9 @@ func postAuth(eng *engine.Engine, version version.Version, w http.ResponseWriter
 
 func getVersion(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	w.Header().Set("Content-Type", "application/json")
+	if version.LessThan(additionsVersion) {
+		return writeEnvWithout(eng.Job("version"), w, newVersionFields)
+	}
 	eng.ServeHTTP(w, r)
 	return nil
 }
@@ -223,6 +253,9 @@ func getImagesJSON(eng *engine.Engine, version version.Version, w http.ResponseW
 	// FIXME this parameter could just be a match filter
 	job.Setenv("filter", r.Form.Get("filter"))
 	job.Setenv("all", r.Form.Get("all"))
+	job.Setenv("usage", r.Form.Get("usage"))
+	job.Setenv("limit", r.Form.Get("limit"))
+	job.Setenv("offset", r.Form.Get("offset"))
 
 	if version.GreaterThanOrEqualTo("1.7") {
 		streamJSON(job, w, false)
@@ -268,6 +301,9 @@ func getImagesViz(eng *engine.Engine, version version.Version, w http.ResponseWr
 
 func getInfo(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	w.Header().Set("Content-Type", "application/json")
+	if version.LessThan(additionsVersion) {
+		return writeEnvWithout(eng.Job("info"), w, newInfoFields)
+	}
 	eng.ServeHTTP(w, r)
 	return nil
 }
@@ -324,6 +360,15 @@ func getContainersTop(eng *engine.Engine, version version.Version, w http.Respon
 	return job.Run()
 }
 
+func getContainersStats(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	job := eng.Job("stats", vars["name"])
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
 func getContainersJSON(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -339,6 +384,7 @@ func getContainersJSON(eng *engine.Engine, version version.Version, w http.Respo
 	job.Setenv("since", r.Form.Get("since"))
 	job.Setenv("before", r.Form.Get("before"))
 	job.Setenv("limit", r.Form.Get("limit"))
+	job.Setenv("offset", r.Form.Get("offset"))
 	job.Setenv("filters", r.Form.Get("filters"))
 
 	if version.GreaterThanOrEqualTo("1.5") {
@@ -363,6 +409,33 @@ func getContainersJSON(eng *engine.Engine, version version.Version, w http.Respo
 	return nil
 }
 
+// useMultiplexedStream reports whether stdout and stderr of the container
+// described by c should be framed on a single stream. Containers with a TTY
+// only have one stream. Since API 1.15, clients which don't support framing
+// can ask for the raw stream by only accepting api.RawStreamContentType.
+func useMultiplexedStream(r *http.Request, version version.Version, c *engine.Env) bool {
+	if c.GetSubEnv("Config") == nil || c.GetSubEnv("Config").GetBool("Tty") || version.LessThan("1.6") {
+		return false
+	}
+	if version.LessThan("1.15") {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	if api.AcceptsContentType(accept, api.RawStreamContentType) && !api.AcceptsContentType(accept, api.MultiplexedStreamContentType) {
+		return false
+	}
+	return true
+}
+
+// streamContentType returns the content type of an attach or logs stream.
+// Before API 1.15, the streams are all of the raw stream type.
+func streamContentType(version version.Version, multiplexed bool) string {
+	if multiplexed && version.GreaterThanOrEqualTo("1.15") {
+		return api.MultiplexedStreamContentType
+	}
+	return api.RawStreamContentType
+}
+
 func getContainersLogs(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -384,6 +457,7 @@ func getContainersLogs(eng *engine.Engine, version version.Version, w http.Respo
 	logsJob.Setenv("stdout", r.Form.Get("stdout"))
 	logsJob.Setenv("stderr", r.Form.Get("stderr"))
 	logsJob.Setenv("timestamps", r.Form.Get("timestamps"))
+	logsJob.Setenv("since", r.Form.Get("since"))
 	// Validate args here, because we can't return not StatusOK after job.Run() call
 	stdout, stderr := logsJob.GetenvBool("stdout"), logsJob.GetenvBool("stderr")
 	if !(stdout || stderr) {
@@ -394,9 +468,14 @@ func getContainersLogs(eng *engine.Engine, version version.Version, w http.Respo
 	}
 
 	var outStream, errStream io.Writer
+	multiplexed := useMultiplexedStream(r, version, c)
+	// The logs had no content type before API 1.15
+	if version.GreaterThanOrEqualTo("1.15") {
+		w.Header().Set("Content-Type", streamContentType(version, multiplexed))
+	}
 	outStream = utils.NewWriteFlusher(w)
 
-	if c.GetSubEnv("Config") != nil && !c.GetSubEnv("Config").GetBool("Tty") && version.GreaterThanOrEqualTo("1.6") {
+	if multiplexed {
 		errStream = utils.NewStdWriter(outStream, utils.Stderr)
 		outStream = utils.NewStdWriter(outStream, utils.Stdout)
 	} else {
@@ -439,7 +518,7 @@ func postCommit(eng *engine.Engine, version version.Version, w http.ResponseWrit
 		stdoutBuffer = bytes.NewBuffer(nil)
 	)
 	if err := config.Decode(r.Body); err != nil {
-		log.Errorf("%s", err)
+		apiLog.Errorf("%s", err)
 	}
 
 	if r.FormValue("pause") == "" && version.GreaterThanOrEqualTo("1.13") {
@@ -452,6 +531,8 @@ func postCommit(eng *engine.Engine, version version.Version, w http.ResponseWrit
 	job.Setenv("tag", r.Form.Get("tag"))
 	job.Setenv("author", r.Form.Get("author"))
 	job.Setenv("comment", r.Form.Get("comment"))
+	job.Setenv("squash", r.Form.Get("squash"))
+	job.Setenv("timestamp", r.Form.Get("timestamp"))
 	job.SetenvSubEnv("config", &config)
 
 	job.Stdout.Add(stdoutBuffer)
@@ -485,6 +566,9 @@ func postImagesCreate(eng *engine.Engine, version version.Version, w http.Respon
 		}
 	}
 	if image != "" { //pull
+		if strings.Contains(image, "@") && version.LessThan(additionsVersion) {
+			return fmt.Errorf("Bad parameter: pulling by digest needs the API v%s", additionsVersion)
+		}
 		if tag == "" {
 			image, tag = parsers.ParseRepositoryTag(image)
 		}
@@ -503,7 +587,18 @@ func postImagesCreate(eng *engine.Engine, version version.Version, w http.Respon
 			repo, tag = parsers.ParseRepositoryTag(repo)
 		}
 		job = eng.Job("import", r.Form.Get("fromSrc"), repo, tag)
-		job.Stdin.Add(r.Body)
+		if id := r.Form.Get("upload"); id != "" {
+			if r.Form.Get("fromSrc") != "-" {
+				return fmt.Errorf("Bad parameter: an upload is imported with fromSrc=-")
+			}
+			upload, err := openUpload(eng, id)
+			if err != nil {
+				return err
+			}
+			job.Stdin.Add(upload)
+		} else {
+			job.Stdin.Add(r.Body)
+		}
 	}
 
 	if version.GreaterThan("1.0") {
@@ -589,7 +684,23 @@ func postImagesPush(eng *engine.Engine, version version.Version, w http.Response
 	job := eng.Job("push", vars["name"])
 	job.SetenvJson("metaHeaders", metaHeaders)
 	job.SetenvJson("authConfig", authConfig)
-	job.Setenv("tag", r.Form.Get("tag"))
+	// Several tags can be pushed together, all of them when none is given
+	var tags []string
+	for _, tag := range r.Form["tag"] {
+		if tag == "" {
+			continue
+		}
+		if version.LessThan(additionsVersion) {
+			// The older versions push a single tag, not a digest
+			if strings.HasPrefix(tag, "sha256:") {
+				return fmt.Errorf("Bad parameter: pushing by digest needs the API v%s", additionsVersion)
+			}
+			tags = []string{tag}
+			break
+		}
+		tags = append(tags, tag)
+	}
+	job.SetenvList("tags", tags)
 	if version.GreaterThan("1.0") {
 		job.SetenvBool("json", true)
 		streamJSON(job, w, true)
@@ -607,14 +718,55 @@ func postImagesPush(eng *engine.Engine, version version.Version, w http.Response
 	return nil
 }
 
+func getImagesUsage(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("images_usage")
+	job.Setenv("all", r.Form.Get("all"))
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func getContainersLogUsage(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", "application/json")
+	job := eng.Job("container_log_usage")
+	job.Stdout.Add(w)
+	return job.Run()
+}
+
 func getImagesGet(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
 	}
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	names := r.Form["names"]
+	if name, exists := vars["name"]; exists {
+		names = []string{name}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("Missing parameter")
+	}
 	if version.GreaterThan("1.0") {
 		w.Header().Set("Content-Type", "application/x-tar")
 	}
-	job := eng.Job("image_export", vars["name"])
+	job := eng.Job("image_export", names...)
+	job.Stdout.Add(w)
+	return job.Run()
+}
+
+func getBuildCache(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	names := r.Form["names"]
+	if len(names) == 0 {
+		return fmt.Errorf("Missing parameter")
+	}
+	w.Header().Set("Content-Type", "application/x-tar")
+	job := eng.Job("build_cache_export", names...)
 	job.Stdout.Add(w)
 	return job.Run()
 }
@@ -622,7 +774,24 @@ func getImagesGet(eng *engine.Engine, version version.Version, w http.ResponseWr
 func postImagesLoad(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	job := eng.Job("load")
 	job.Stdin.Add(r.Body)
-	return job.Run()
+	if version.LessThan(additionsVersion) {
+		// The progress of the load was added in v1.15
+		return job.Run()
+	}
+	if version.GreaterThan("1.0") {
+		job.SetenvBool("json", true)
+		streamJSON(job, w, true)
+	} else {
+		job.Stdout.Add(utils.NewWriteFlusher(w))
+	}
+	if err := job.Run(); err != nil {
+		if !job.Stdout.Used() {
+			return err
+		}
+		sf := utils.NewStreamFormatter(version.GreaterThan("1.0"))
+		w.Write(sf.FormatError(err))
+	}
+	return nil
 }
 
 func postContainersCreate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
@@ -762,6 +931,23 @@ func postContainersWait(eng *engine.Engine, version version.Version, w http.Resp
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
 	}
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	// The wait gives up after timeout seconds, if given
+	var deadline time.Time
+	if value := r.Form.Get("timeout"); value != "" {
+		timeout, err := strconv.Atoi(value)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("Bad parameter: invalid timeout %s", value)
+		}
+		if timeout > 0 {
+			var cancel func()
+			deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+			eng, cancel = eng.WithDeadline(deadline)
+			defer cancel()
+		}
+	}
 	var (
 		env          engine.Env
 		stdoutBuffer = bytes.NewBuffer(nil)
@@ -769,6 +955,9 @@ func postContainersWait(eng *engine.Engine, version version.Version, w http.Resp
 	)
 	job.Stdout.Add(stdoutBuffer)
 	if err := job.Run(); err != nil {
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return fmt.Errorf("Timeout waiting for the container %s to stop", vars["name"])
+		}
 		return err
 	}
 
@@ -812,26 +1001,21 @@ func postContainersAttach(eng *engine.Engine, version version.Version, w http.Re
 	if err != nil {
 		return err
 	}
+	defer closeWrite(inStream)
 	defer func() {
-		if tcpc, ok := inStream.(*net.TCPConn); ok {
-			tcpc.CloseWrite()
-		} else {
-			inStream.Close()
-		}
-	}()
-	defer func() {
-		if tcpc, ok := outStream.(*net.TCPConn); ok {
-			tcpc.CloseWrite()
-		} else if closer, ok := outStream.(io.Closer); ok {
-			closer.Close()
+		if closer, ok := outStream.(io.Closer); ok {
+			closeWrite(closer)
 		}
 	}()
 
-	var errStream io.Writer
+	var (
+		errStream   io.Writer
+		multiplexed = useMultiplexedStream(r, version, c)
+	)
 
-	fmt.Fprintf(outStream, "HTTP/1.1 200 OK\r\nContent-Type: application/vnd.docker.raw-stream\r\n\r\n")
+	fmt.Fprintf(outStream, "HTTP/1.1 200 OK\r\nContent-Type: %s\r\n\r\n", streamContentType(version, multiplexed))
 
-	if c.GetSubEnv("Config") != nil && !c.GetSubEnv("Config").GetBool("Tty") && version.GreaterThanOrEqualTo("1.6") {
+	if multiplexed {
 		errStream = utils.NewStdWriter(outStream, utils.Stderr)
 		outStream = utils.NewStdWriter(outStream, utils.Stdout)
 	} else {
@@ -844,7 +1028,11 @@ func postContainersAttach(eng *engine.Engine, version version.Version, w http.Re
 	job.Setenv("stdin", r.Form.Get("stdin"))
 	job.Setenv("stdout", r.Form.Get("stdout"))
 	job.Setenv("stderr", r.Form.Get("stderr"))
-	job.Stdin.Add(inStream)
+	if job.GetenvBool("stdin") {
+		job.Stdin.Add(inStream)
+	} else {
+		cancelOnHangup(eng, inStream)
+	}
 	job.Stdout.Add(outStream)
 	job.Stderr.Set(errStream)
 	if err := job.Run(); err != nil {
@@ -862,6 +1050,10 @@ func wsContainersAttach(eng *engine.Engine, version version.Version, w http.Resp
 		return fmt.Errorf("Missing parameter")
 	}
 
+	binary, err := getBoolParam(r.Form.Get("binary"))
+	if err != nil {
+		return err
+	}
 	if err := eng.Job("container_inspect", vars["name"]).Run(); err != nil {
 		return err
 	}
@@ -874,11 +1066,66 @@ func wsContainersAttach(eng *engine.Engine, version version.Version, w http.Resp
 		job.Setenv("stdin", r.Form.Get("stdin"))
 		job.Setenv("stdout", r.Form.Get("stdout"))
 		job.Setenv("stderr", r.Form.Get("stderr"))
-		job.Stdin.Add(ws)
+		var stdin io.Reader = ws
+		if binary {
+			ws.PayloadType = websocket.BinaryFrame
+			stdin = &wsStream{ws: ws, resize: func(height, width int) error {
+				return eng.Job("resize", vars["name"], strconv.Itoa(height), strconv.Itoa(width)).Run()
+			}}
+		}
+		job.Stdin.Add(stdin)
+		job.Stdout.Add(ws)
+		job.Stderr.Set(ws)
+		if err := job.Run(); err != nil {
+			apiLog.Errorf("Error attaching websocket: %s", err)
+		}
+	})
+	h.ServeHTTP(w, r)
+
+	return nil
+}
+
+func wsContainersExec(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	cmd := r.Form["cmd"]
+	if len(cmd) == 0 {
+		return fmt.Errorf("Missing parameter")
+	}
+	binary, err := getBoolParam(r.Form.Get("binary"))
+	if err != nil {
+		return err
+	}
+	if err := eng.Job("container_inspect", vars["name"]).Run(); err != nil {
+		return err
+	}
+
+	h := websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		job := eng.Job("exec", append([]string{vars["name"]}, cmd...)...)
+		job.Setenv("stdin", r.Form.Get("stdin"))
+		var stdin io.Reader = ws
+		if binary {
+			ws.PayloadType = websocket.BinaryFrame
+			// The commands run without a tty, there is nothing to resize
+			stdin = &wsStream{ws: ws}
+		}
+		job.Stdin.Add(stdin)
 		job.Stdout.Add(ws)
 		job.Stderr.Set(ws)
 		if err := job.Run(); err != nil {
-			log.Errorf("Error attaching websocket: %s", err)
+			apiLog.Errorf("Error running exec over websocket: %s", err)
+			return
+		}
+		if binary {
+			exitCode := job.GetenvInt("ExitCode")
+			if err := websocket.JSON.Send(ws, &wsControl{ExitCode: &exitCode}); err != nil {
+				apiLog.Debugf("Error sending the exit code over websocket: %s", err)
+			}
 		}
 	})
 	h.ServeHTTP(w, r)
@@ -886,6 +1133,67 @@ func wsContainersAttach(eng *engine.Engine, version version.Version, w http.Resp
 	return nil
 }
 
+// wsControl is a control message of a binary websocket, sent as JSON in a
+// text frame: the client resizes the tty, the daemon reports the exit code
+// of an exec.
+type wsControl struct {
+	Resize   *wsResize `json:",omitempty"`
+	ExitCode *int      `json:",omitempty"`
+}
+
+type wsResize struct {
+	Height, Width int
+}
+
+// wsFrame is a frame received with wsFrameCodec, its type kept.
+type wsFrame struct {
+	payloadType byte
+	data        []byte
+}
+
+var wsFrameCodec = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		frame := v.(*wsFrame)
+		frame.payloadType = payloadType
+		frame.data = data
+		return nil
+	},
+}
+
+// wsStream reads the input of a binary websocket: the binary frames are the
+// stdin, the text frames are control messages.
+type wsStream struct {
+	ws     *websocket.Conn
+	resize func(height, width int) error
+	buf    []byte
+}
+
+func (s *wsStream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		var frame wsFrame
+		if err := wsFrameCodec.Receive(s.ws, &frame); err != nil {
+			return 0, err
+		}
+		if frame.payloadType == websocket.BinaryFrame {
+			s.buf = frame.data
+			continue
+		}
+		var msg wsControl
+		if err := json.Unmarshal(frame.data, &msg); err != nil {
+			apiLog.Debugf("Invalid websocket control message: %s", err)
+			continue
+		}
+		if msg.Resize != nil && s.resize != nil {
+			if err := s.resize(msg.Resize.Height, msg.Resize.Width); err != nil {
+				apiLog.Debugf("Error resizing over websocket: %s", err)
+			}
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
 func getContainersByName(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
@@ -894,6 +1202,9 @@ func getContainersByName(eng *engine.Engine, version version.Version, w http.Res
 	if version.LessThan("1.12") {
 		job.SetenvBool("raw", true)
 	}
+	if version.LessThan(additionsVersion) {
+		return writeEnvWithout(job, w, newContainerFields)
+	}
 	streamJSON(job, w, false)
 	return job.Run()
 }
@@ -906,10 +1217,233 @@ func getImagesByName(eng *engine.Engine, version version.Version, w http.Respons
 	if version.LessThan("1.12") {
 		job.SetenvBool("raw", true)
 	}
+	if version.LessThan(additionsVersion) {
+		return writeEnvWithout(job, w, newContainerFields)
+	}
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func getVolumesJSON(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	job := eng.Job("volume_ls")
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func getLogLevels(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	job := eng.Job("log_levels")
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+// postLogLevels sets the levels of the loggers given as parameters, like
+// bridge=debug.
+func postLogLevels(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	var levels []string
+	for name, values := range r.Form {
+		for _, level := range values {
+			levels = append(levels, name+"="+level)
+		}
+	}
+	sort.Strings(levels)
+	job := eng.Job("log_levels", levels...)
 	streamJSON(job, w, false)
 	return job.Run()
 }
 
+func getVolumesByName(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	job := eng.Job("volume_inspect", vars["name"])
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func postVolumesCreate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	var (
+		out          engine.Env
+		job          = eng.Job("volume_create", r.Form.Get("name"))
+		stdoutBuffer = bytes.NewBuffer(nil)
+	)
+	job.Setenv("size", r.Form.Get("size"))
+	job.Setenv("driver", r.Form.Get("driver"))
+	job.SetenvList("opt", r.Form["opt"])
+	job.Stdout.Add(stdoutBuffer)
+	if err := job.Run(); err != nil {
+		return err
+	}
+	out.Set("Name", engine.Tail(stdoutBuffer, 1))
+	return writeJSON(w, http.StatusCreated, out)
+}
+
+func postVolumesPrune(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("volumes_prune")
+	job.Setenv("all", r.Form.Get("all"))
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func postImagesGC(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("images_gc")
+	job.Setenv("dryrun", r.Form.Get("dryrun"))
+	for _, key := range []string{"maxage", "keeptags"} {
+		if value := r.Form.Get(key); value != "" {
+			job.Setenv(key, value)
+		}
+	}
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func postGraphFsck(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("graph_fsck")
+	job.Setenv("repair", r.Form.Get("repair"))
+	job.Setenv("removedirs", r.Form.Get("removedirs"))
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
+func postGraphMigrate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("graph_migrate", r.Form.Get("from"))
+	job.SetenvList("options", r.Form["opt"])
+	job.SetenvBool("json", true)
+	streamJSON(job, w, true)
+	if err := job.Run(); err != nil {
+		if !job.Stdout.Used() {
+			return err
+		}
+		sf := utils.NewStreamFormatter(true)
+		w.Write(sf.FormatError(err))
+	}
+	return nil
+}
+
+func deleteVolumes(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := eng.Job("volume_rm", vars["name"]).Run(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func postUploads(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var (
+		out          engine.Env
+		job          = eng.Job("upload_create")
+		stdoutBuffer = bytes.NewBuffer(nil)
+	)
+	job.Stdout.Add(stdoutBuffer)
+	if err := job.Run(); err != nil {
+		return err
+	}
+	out.Set("Id", engine.Tail(stdoutBuffer, 1))
+	return writeJSON(w, http.StatusCreated, out)
+}
+
+func getUpload(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	return writeUpload(eng.Job("upload_inspect", vars["name"]), w)
+}
+
+// postUpload appends the body to the upload, at the offset its size must
+// be. The size is the offset of the next chunk, also after an error.
+func postUpload(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	job := eng.Job("upload_append", vars["name"])
+	job.Setenv("offset", r.Form.Get("offset"))
+	job.Stdin.Add(r.Body)
+	return writeUpload(job, w)
+}
+
+func deleteUpload(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := eng.Job("upload_rm", vars["name"]).Run(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// writeUpload runs the job and writes the id and the size of its upload,
+// the path being only for the daemon.
+func writeUpload(job *engine.Job, w http.ResponseWriter) error {
+	env, err := job.Stdout.AddEnv()
+	if err != nil {
+		return err
+	}
+	if err := job.Run(); err != nil {
+		return err
+	}
+	var out engine.Env
+	out.Set("Id", env.Get("Id"))
+	out.SetInt64("Size", env.GetInt64("Size"))
+	return writeJSON(w, http.StatusOK, out)
+}
+
+// openUpload opens the upload to read in place of the body of a build or
+// of an import. The upload is removed once closed, with the stdin of the
+// job.
+func openUpload(eng *engine.Engine, id string) (io.ReadCloser, error) {
+	job := eng.Job("upload_inspect", id)
+	env, err := job.Stdout.AddEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := job.Run(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(env.Get("Path"))
+	if err != nil {
+		return nil, err
+	}
+	return &uploadReader{File: f, eng: eng, id: id}, nil
+}
+
+type uploadReader struct {
+	*os.File
+	eng *engine.Engine
+	id  string
+}
+
+func (r *uploadReader) Close() error {
+	err := r.File.Close()
+	if rmErr := r.eng.Job("upload_rm", r.id).Run(); rmErr != nil {
+		apiLog.Errorf("Failed to remove the upload %s: %s", r.id, rmErr)
+	}
+	return err
+}
+
 func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if version.LessThan("1.3") {
 		return fmt.Errorf("Multipart upload for build is no longer supported. Please upgrade your docker client.")
@@ -958,12 +1492,35 @@ func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWrite
 	} else {
 		job.Setenv("rm", r.FormValue("rm"))
 	}
-	job.Stdin.Add(r.Body)
+	if id := r.FormValue("upload"); id != "" {
+		upload, err := openUpload(eng, id)
+		if err != nil {
+			return err
+		}
+		job.Stdin.Add(upload)
+	} else {
+		job.Stdin.Add(r.Body)
+	}
 	job.Setenv("remote", r.FormValue("remote"))
+	job.Setenv("gitref", r.FormValue("gitref"))
+	job.Setenv("gitdepth", r.FormValue("gitdepth"))
 	job.Setenv("t", r.FormValue("t"))
 	job.Setenv("q", r.FormValue("q"))
 	job.Setenv("nocache", r.FormValue("nocache"))
 	job.Setenv("forcerm", r.FormValue("forcerm"))
+	job.Setenv("squash", r.FormValue("squash"))
+	job.Setenv("timestamp", r.FormValue("timestamp"))
+	job.Setenv("buildargs", r.FormValue("buildargs"))
+	job.Setenv("steps", r.FormValue("steps"))
+	job.Setenv("dryrun", r.FormValue("dryrun"))
+	job.Setenv("ignoremtime", r.FormValue("ignoremtime"))
+	if secretsEncoded := r.Header.Get("X-Build-Secrets"); secretsEncoded != "" {
+		secrets, err := base64.URLEncoding.DecodeString(secretsEncoded)
+		if err != nil {
+			return fmt.Errorf("Invalid X-Build-Secrets header: %s", err)
+		}
+		job.Setenv("secrets", string(secrets))
+	}
 	job.SetenvJson("authConfig", authConfig)
 	job.SetenvJson("configFile", configFile)
 
@@ -977,6 +1534,42 @@ func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWrite
 	return nil
 }
 
+func postBuildBatch(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	var (
+		configFileEncoded = r.Header.Get("X-Registry-Config")
+		configFile        = &registry.ConfigFile{}
+		job               = eng.Job("build_batch")
+	)
+	if configFileEncoded != "" {
+		configFileJson := base64.NewDecoder(base64.URLEncoding, strings.NewReader(configFileEncoded))
+		if err := json.NewDecoder(configFileJson).Decode(configFile); err != nil {
+			configFile = &registry.ConfigFile{}
+		}
+	}
+	job.Stdin.Add(r.Body)
+	job.Setenv("workers", r.Form.Get("workers"))
+	if r.Form.Get("rm") == "" {
+		job.Setenv("rm", "1")
+	} else {
+		job.Setenv("rm", r.Form.Get("rm"))
+	}
+	job.Setenv("forcerm", r.Form.Get("forcerm"))
+	job.SetenvJson("configFile", configFile)
+	job.SetenvBool("json", true)
+	streamJSON(job, w, true)
+	if err := job.Run(); err != nil {
+		if !job.Stdout.Used() {
+			return err
+		}
+		sf := utils.NewStreamFormatter(true)
+		w.Write(sf.FormatError(err))
+	}
+	return nil
+}
+
 func postContainersCopy(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
@@ -1005,7 +1598,7 @@ func postContainersCopy(eng *engine.Engine, version version.Version, w http.Resp
 	job := eng.Job("container_copy", vars["name"], copyData.Get("Resource"))
 	job.Stdout.Add(w)
 	if err := job.Run(); err != nil {
-		log.Errorf("%s", err.Error())
+		apiLog.Errorf("%s", err.Error())
 		if strings.Contains(err.Error(), "No such container") {
 			w.WriteHeader(http.StatusNotFound)
 		} else if strings.Contains(err.Error(), "no such file or directory") {
@@ -1025,25 +1618,78 @@ func writeCorsHeaders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Access-Control-Allow-Methods", "GET, POST, DELETE, PUT, OPTIONS")
 }
 
+// ping answers OK, or with verbose the health of the daemon, with the
+// status 503 until it is healthy.
 func ping(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
-	_, err := w.Write([]byte{'O', 'K'})
-	return err
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	verbose, err := getBoolParam(r.Form.Get("verbose"))
+	if err != nil {
+		return err
+	}
+	if !verbose {
+		_, err := w.Write([]byte{'O', 'K'})
+		return err
+	}
+	job := eng.Job("daemon_health")
+	health, err := job.Stdout.AddEnv()
+	if err != nil {
+		return err
+	}
+	if err := job.Run(); err != nil {
+		return err
+	}
+	code := http.StatusOK
+	if health.Get("State") != "healthy" {
+		code = http.StatusServiceUnavailable
+	}
+	return writeJSON(w, code, *health)
+}
+
+var validAPIVersion = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+
+// validRequestID are the request ids the clients can give, the others are
+// replaced.
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// checkAPIVersion tells whether the daemon serves the version of the API a
+// client asked for, in the range from MINAPIVERSION to APIVERSION.
+func checkAPIVersion(v version.Version) error {
+	if !validAPIVersion.MatchString(string(v)) {
+		return fmt.Errorf("Invalid API version %s, expected MAJOR.MINOR", v)
+	}
+	if v.GreaterThan(api.APIVERSION) {
+		return fmt.Errorf("client is newer than server (client API version: %s, server API version: %s), please upgrade the server", v, api.APIVERSION)
+	}
+	if v.LessThan(api.MINAPIVERSION) {
+		return fmt.Errorf("client is too old (client API version: %s, minimum supported API version: %s), please upgrade the client", v, api.MINAPIVERSION)
+	}
+	return nil
 }
 
 //构造处理函数
-func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, localRoute string, handlerFunc HttpApiFunc, enableCors bool, dockerVersion version.Version) http.HandlerFunc {
+func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, localRoute string, handlerFunc HttpApiFunc, enableCors bool, dockerVersion version.Version, access *apiAccess) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Each request has an id, given by the client or generated, which
+		// its jobs log and the response returns
+		requestID := r.Header.Get("X-Request-Id")
+		if !validRequestID.MatchString(requestID) {
+			requestID = utils.TruncateID(utils.GenerateRandomID())
+		}
+		w.Header().Set("X-Request-Id", requestID)
+
 		// log the request
-		log.Debugf("Calling %s %s", localMethod, localRoute)
+		apiLog.Debugf("Calling %s %s (request %s)", localMethod, localRoute, requestID)
 
 		if logging {
-			log.Infof("%s %s", r.Method, r.RequestURI)
+			apiLog.Infof("%s %s (request %s)", r.Method, r.RequestURI, requestID)
 		}
 
 		if strings.Contains(r.Header.Get("User-Agent"), "Docker-Client/") {
 			userAgent := strings.Split(r.Header.Get("User-Agent"), "/")
 			if len(userAgent) == 2 && !dockerVersion.Equal(version.Version(userAgent[1])) {
-				log.Debugf("Warning: client and server don't have the same version (client: %s, server: %s)", userAgent[1], dockerVersion)
+				apiLog.Debugf("Warning: client and server don't have the same version (client: %s, server: %s)", userAgent[1], dockerVersion)
 			}
 		}
 		version := version.Version(mux.Vars(r)["version"])
@@ -1053,14 +1699,85 @@ func makeHttpHandler(eng *engine.Engine, logging bool, localMethod string, local
 		if enableCors { //写响应头
 			writeCorsHeaders(w, r)
 		}
+		// The clients learn the range of the versions the daemon serves
+		w.Header().Set("Api-Version", string(api.APIVERSION))
+		w.Header().Set("Api-Min-Version", string(api.MINAPIVERSION))
 
-		if version.GreaterThan(api.APIVERSION) {
-			http.Error(w, fmt.Errorf("client and server don't have same version (client : %s, server: %s)", version, api.APIVERSION).Error(), http.StatusNotFound)
+		if err := checkAPIVersion(version); err != nil {
+			apiLog.Errorf("Handler for %s %s (request %s): %s", localMethod, localRoute, requestID, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkRouteVersion(version, localMethod, localRoute, r); err != nil {
+			apiLog.Errorf("Handler for %s %s (request %s): %s", localMethod, localRoute, requestID, err)
+			httpError(w, err)
+			return
+		}
+
+		// The health probes are never limited
+		if localRoute != "/_ping" {
+			release, err := access.limits.acquire(clientIdentity(r), time.Now())
+			if err != nil {
+				apiLog.Errorf("Handler for %s %s (request %s): %s", localMethod, localRoute, requestID, err)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, err.Error(), statusTooManyRequests)
+				return
+			}
+			defer release()
+			// The streams stop counting as requests in flight once hijacked
+			if _, ok := w.(http.Hijacker); ok {
+				w = &streamReleaser{ResponseWriter: w, release: release}
+			}
+		}
+		if access.readOnly && requiredRole(localMethod, localRoute) > roleReadOnly {
+			err := fmt.Errorf("Forbidden: %s %s on a read-only listener", localMethod, localRoute)
+			apiLog.Errorf("Handler for %s %s (request %s): %s", localMethod, localRoute, requestID, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
+		if err := access.roles.authorize(r, localMethod, localRoute); err != nil {
+			apiLog.Errorf("Handler for %s %s (request %s): %s", localMethod, localRoute, requestID, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		reqEng := eng.WithRequestID(requestID)
+		defer reqEng.Release()
+		if _, exists := cancelableRoutes[localMethod][localRoute]; exists {
+			// The jobs run on an engine canceled with the request, or when
+			// the client disconnects
+			var cancel func()
+			reqEng, cancel = reqEng.WithCancel()
+			defer cancel()
+			defer cancelOnClose(cancel, w)()
+		}
+		if len(access.authz) > 0 {
+			aw, status, err := access.authz.authorizeRequest(w, r)
+			if err != nil {
+				apiLog.Errorf("Handler for %s %s (request %s): %s", localMethod, localRoute, requestID, err)
+				http.Error(w, err.Error(), status)
+				return
+			}
+			defer aw.commit(true)
+			w = aw
+		}
 
-		if err := handlerFunc(eng, version, w, r, mux.Vars(r)); err != nil {
-			log.Errorf("Handler for %s %s returned error: %s", localMethod, localRoute, err)
+		// The async requests run in the background, on their own engine
+		if _, exists := asyncRoutes[localMethod][localRoute]; exists {
+			async, err := isAsync(r)
+			if err == nil && async {
+				err = startAsync(eng, requestID, localMethod, localRoute, handlerFunc, version, w, r, mux.Vars(r))
+			}
+			if err != nil {
+				apiLog.Errorf("Handler for %s %s (request %s) returned error: %s", localMethod, localRoute, requestID, err)
+				httpError(w, err)
+				return
+			}
+			if async {
+				return
+			}
+		}
+		if err := handlerFunc(reqEng, version, w, r, mux.Vars(r)); err != nil {
+			apiLog.Errorf("Handler for %s %s (request %s) returned error: %s", localMethod, localRoute, requestID, err)
 			httpError(w, err)
 		}
 	}
@@ -1093,39 +1810,48 @@ func AttachProfiler(router *mux.Router) {
 	router.HandleFunc("/debug/pprof/threadcreate", pprof.Handler("threadcreate").ServeHTTP)
 }
 
-//创建路由及处理函数
-func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion string) (*mux.Router, error) {
-	//.创建空路由实例
-	r := mux.NewRouter()
-	if os.Getenv("DEBUG") != "" {
-		AttachProfiler(r) //添加与 DEBUG 相关的路由记录
-	}
-	//添加handler
-	m := map[string]map[string]HttpApiFunc{
+// apiRoutes returns the handlers of the api, by method and route.
+func apiRoutes() map[string]map[string]HttpApiFunc {
+	return map[string]map[string]HttpApiFunc{
 		"GET": {
 			"/_ping":                          ping,
 			"/events":                         getEvents,
 			"/info":                           getInfo,
 			"/version":                        getVersion,
+			"/schema":                         getSchema,
 			"/images/json":                    getImagesJSON,
 			"/images/viz":                     getImagesViz,
 			"/images/search":                  getImagesSearch,
+			"/images/get":                     getImagesGet,
+			"/build/cache":                    getBuildCache,
+			"/images/usage":                   getImagesUsage,
 			"/images/{name:.*}/get":           getImagesGet,
 			"/images/{name:.*}/history":       getImagesHistory,
 			"/images/{name:.*}/json":          getImagesByName,
 			"/containers/ps":                  getContainersJSON,
 			"/containers/json":                getContainersJSON,
+			"/containers/logs/usage":          getContainersLogUsage,
 			"/containers/{name:.*}/export":    getContainersExport,
 			"/containers/{name:.*}/changes":   getContainersChanges,
 			"/containers/{name:.*}/json":      getContainersByName,
 			"/containers/{name:.*}/top":       getContainersTop,
+			"/containers/{name:.*}/stats":     getContainersStats,
 			"/containers/{name:.*}/logs":      getContainersLogs,
 			"/containers/{name:.*}/attach/ws": wsContainersAttach,
+			"/containers/{name:.*}/exec/ws":   wsContainersExec,
+			"/volumes/json":                   getVolumesJSON,
+			"/volumes/{name:.*}/json":         getVolumesByName,
+			"/uploads/{name:.*}":              getUpload,
+			"/jobs/json":                      getJobsJSON,
+			"/jobs/{id:.*}/json":              getJobJSON,
+			"/jobs/{id:.*}/logs":              getJobLogs,
+			"/loglevels":                      getLogLevels,
 		},
 		"POST": {
 			"/auth":                         postAuth,
 			"/commit":                       postCommit,
 			"/build":                        postBuild,
+			"/build/batch":                  postBuildBatch,
 			"/images/create":                postImagesCreate,
 			"/images/load":                  postImagesLoad,
 			"/images/{name:.*}/push":        postImagesPush,
@@ -1141,19 +1867,42 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion st
 			"/containers/{name:.*}/resize":  postContainersResize,
 			"/containers/{name:.*}/attach":  postContainersAttach,
 			"/containers/{name:.*}/copy":    postContainersCopy,
+			"/volumes/create":               postVolumesCreate,
+			"/volumes/prune":                postVolumesPrune,
+			"/images/gc":                    postImagesGC,
+			"/graph/migrate":                postGraphMigrate,
+			"/graph/fsck":                   postGraphFsck,
+			"/uploads":                      postUploads,
+			"/uploads/{name:.*}":            postUpload,
+			"/requests/{id:.*}/cancel":      postRequestsCancel,
+			"/loglevels":                    postLogLevels,
 		},
 		"DELETE": {
 			"/containers/{name:.*}": deleteContainers,
 			"/images/{name:.*}":     deleteImages,
+			"/volumes/{name:.*}":    deleteVolumes,
+			"/uploads/{name:.*}":    deleteUpload,
+			"/jobs/{id:.*}":         deleteJob,
 		},
 		"OPTIONS": {
 			"": optionsHandler,
 		},
 	}
+}
+
+//创建路由及处理函数
+func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion string, access *apiAccess) (*mux.Router, error) {
+	//.创建空路由实例
+	r := mux.NewRouter()
+	if os.Getenv("DEBUG") != "" {
+		AttachProfiler(r) //添加与 DEBUG 相关的路由记录
+	}
+	//添加handler
+	m := apiRoutes()
 	//添加路由实例
 	for method, routes := range m {
 		for route, fct := range routes {
-			log.Debugf("Registering %s, %s", method, route)
+			apiLog.Debugf("Registering %s, %s", method, route)
 			// NOTE: scope issue, make sure the variables are local and won't be changed
 			localRoute := route
 			localFct := fct
@@ -1161,7 +1910,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion st
 
 			// build the handler function
 			//构造处理函数
-			f := makeHttpHandler(eng, logging, localMethod, localRoute, localFct, enableCors, version.Version(dockerVersion))
+			f := makeHttpHandler(eng, logging, localMethod, localRoute, localFct, enableCors, version.Version(dockerVersion), access)
 
 			// add the new route
 			//向路由实例添加新的路由记录
@@ -1181,7 +1930,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion st
 // FIXME: refactor this to be part of Server and not require re-creating a new
 // router each time. This requires first moving ListenAndServe into Server.
 func ServeRequest(eng *engine.Engine, apiversion version.Version, w http.ResponseWriter, req *http.Request) error {
-	router, err := createRouter(eng, false, true, "")
+	router, err := createRouter(eng, false, true, "", &apiAccess{})
 	if err != nil {
 		return err
 	}
@@ -1193,7 +1942,7 @@ func ServeRequest(eng *engine.Engine, apiversion version.Version, w http.Respons
 
 // ServeFD creates an http.Server and sets it up to serve given a socket activated
 // argument.
-func ServeFd(addr string, handle http.Handler) error {
+func ServeFd(addr string, handle http.Handler, timeouts *serverTimeouts) error {
 	ls, e := systemd.ListenFD(addr)
 	if e != nil {
 		return e
@@ -1211,8 +1960,7 @@ func ServeFd(addr string, handle http.Handler) error {
 	for i := range ls {
 		listener := ls[i]
 		go func() {
-			httpSrv := http.Server{Handler: handle}
-			chErrors <- httpSrv.Serve(listener)
+			chErrors <- timeouts.server("", handle).Serve(timeouts.listener(listener))
 		}()
 	}
 
@@ -1239,14 +1987,58 @@ func lookupGidByName(nameOrGid string) (int, error) {
 	return -1, fmt.Errorf("Group %s not found", nameOrGid)
 }
 
-func changeGroup(addr string, nameOrGid string) error {
-	gid, err := lookupGidByName(nameOrGid)
-	if err != nil {
-		return err
+// proxyHeaderTimeout is how long the clients of the listeners reading the
+// PROXY protocol header have to send it.
+const proxyHeaderTimeout = 10 * time.Second
+
+// signalHandlerTimeout is how long the handlers of the signals reopening the
+// access log or reloading the certificates hold the next handlers.
+const signalHandlerTimeout = 10 * time.Second
+
+// listen binds the socket of the address. A unix socket is bound to a
+// temporary path and renamed once given its ownership, replacing the socket
+// left by a previous daemon, so that it is never reachable with another.
+func listen(proto, addr string, ownership *socketOwnership, buffer *listenbuffer.Options) (net.Listener, error) {
+	bindAddr := addr
+	if proto == "unix" {
+		bindAddr = filepath.Join(filepath.Dir(addr), "."+filepath.Base(addr)+".tmp")
+		if err := syscall.Unlink(bindAddr); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	var oldmask int
+	if proto == "unix" {
+		oldmask = syscall.Umask(0777)
 	}
 
-	log.Debugf("%s group found. gid: %d", nameOrGid, gid)
-	return os.Chown(addr, 0, gid)
+	var (
+		l   net.Listener
+		err error
+	)
+	if buffer != nil {
+		l, err = listenbuffer.NewListenBuffer(proto, bindAddr, activationLock, *buffer)
+	} else {
+		l, err = net.Listen(proto, bindAddr)
+	}
+
+	if proto == "unix" {
+		syscall.Umask(oldmask)
+	}
+	if err != nil || proto != "unix" {
+		return l, err
+	}
+	if err := ownership.apply(bindAddr); err != nil {
+		l.Close()
+		os.Remove(bindAddr)
+		return nil, err
+	}
+	if err := os.Rename(bindAddr, addr); err != nil {
+		l.Close()
+		os.Remove(bindAddr)
+		return nil, err
+	}
+	return l, nil
 }
 
 // ListenAndServe sets up the required http.Server and gets it listening for
@@ -1256,95 +2048,118 @@ func changeGroup(addr string, nameOrGid string) error {
 //2) 创建 1istener 监听实例。
 //3 )创建 http.Se er
 //4) 启动 API 服务。
-func ListenAndServe(proto, addr string, job *engine.Job) error {
+func ListenAndServe(cfg *ListenerConfig, limits *apiLimits, logger *accessLog, job *engine.Job) error {
 	var l net.Listener
+	protoAddrParts := strings.SplitN(cfg.Addr, "://", 2)
+	proto, addr := protoAddrParts[0], protoAddrParts[1]
+	// The roles of the client certificates, checked on the tcp sockets
+	roles, err := parseTlsRoles(cfg.TlsRoles)
+	if err != nil {
+		return err
+	}
+	if roles != nil && !cfg.TlsVerify {
+		return fmt.Errorf("TLS roles need the client certificates verified with --tlsverify")
+	}
+	authz, err := parseAuthzPlugins(job.GetenvList("AuthzPlugins"))
+	if err != nil {
+		return err
+	}
+	timeouts, err := parseServerTimeouts(job)
+	if err != nil {
+		return err
+	}
 	//创建路由
-	r, err := createRouter(job.Eng, job.GetenvBool("Logging"), job.GetenvBool("EnableCors"), job.Getenv("Version"))
+	r, err := createRouter(job.Eng, job.GetenvBool("Logging"), job.GetenvBool("EnableCors"), job.Getenv("Version"), &apiAccess{
+		readOnly: cfg.ReadOnly,
+		roles:    roles,
+		authz:    authz,
+		limits:   limits,
+	})
 	if err != nil {
 		return err
 	}
+	var handler http.Handler = r
+	if logger != nil {
+		handler = logger.handler(r)
+	}
 
 	if proto == "fd" {
-		return ServeFd(addr, r)
+		return ServeFd(addr, handler, timeouts)
 	}
 
-	if proto == "unix" {
-		if err := syscall.Unlink(addr); err != nil && !os.IsNotExist(err) {
-			return err
-		}
+	// The socket systemd activated for the address is used as is, owned as
+	// its unit sets it
+	activated, err := systemd.ActivatedListener(proto, addr)
+	if err != nil {
+		return err
 	}
-
-	var oldmask int
-	if proto == "unix" {
-		oldmask = syscall.Umask(0777)
+	// The connections held until the daemon started are bounded
+	buffer, err := parseBufferOptions(job, proto != "unix" && (cfg.Tls || cfg.TlsVerify))
+	if err != nil {
+		return err
 	}
-
-	if job.GetenvBool("BufferRequests") {
-		l, err = listenbuffer.NewListenBuffer(proto, addr, activationLock)
+	if activated != nil {
+		apiLog.Infof("Using the socket activated by systemd for %s", cfg.Addr)
+		l = activated
+		if buffer != nil {
+			l = listenbuffer.Buffer(activated, activationLock, *buffer)
+		}
 	} else {
-		l, err = net.Listen(proto, addr)
+		var ownership *socketOwnership
+		if proto == "unix" {
+			if ownership, err = parseSocketOwnership(cfg); err != nil {
+				return err
+			}
+		}
+		if l, err = listen(proto, addr, ownership, buffer); err != nil {
+			return err
+		}
 	}
-
 	if proto == "unix" {
-		syscall.Umask(oldmask)
-	}
-	if err != nil {
-		return err
+		l = identifyPeers(l, addr, cfg.LogPeers)
 	}
-
-	if proto != "unix" && (job.GetenvBool("Tls") || job.GetenvBool("TlsVerify")) {
-		tlsCert := job.Getenv("TlsCert")
-		tlsKey := job.Getenv("TlsKey")
-		cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+	// The header comes ahead of the TLS handshake
+	if proto == "tcp" && cfg.ProxyProtocol {
+		trusted, err := proxyproto.ParseTrusted(cfg.ProxyTrusted)
 		if err != nil {
-			return fmt.Errorf("Couldn't load X509 key pair (%s, %s): %s. Key encrypted?",
-				tlsCert, tlsKey, err)
-		}
-		tlsConfig := &tls.Config{
-			NextProtos:   []string{"http/1.1"},
-			Certificates: []tls.Certificate{cert},
+			return err
 		}
-		if job.GetenvBool("TlsVerify") {
-			certPool := x509.NewCertPool()
-			file, err := ioutil.ReadFile(job.Getenv("TlsCa"))
-			if err != nil {
-				return fmt.Errorf("Couldn't read CA certificate: %s", err)
-			}
-			certPool.AppendCertsFromPEM(file)
+		l = proxyproto.NewListener(l, proxyHeaderTimeout, trusted)
+	}
+	// The connections are counted once their client is known
+	l = limitConns(l, limits)
 
-			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-			tlsConfig.ClientCAs = certPool
+	if proto != "unix" && (cfg.Tls || cfg.TlsVerify) {
+		tl, err := newTlsListener(l, cfg)
+		if err != nil {
+			return err
 		}
-		l = tls.NewListener(l, tlsConfig)
+		// SIGHUP reloads the certificates, once renewed
+		signal.Handle(syscall.SIGHUP, &signal.Handler{
+			Name:    "tls reload " + cfg.Addr,
+			Timeout: signalHandlerTimeout,
+			Func: func(os.Signal) {
+				if err := tl.reload(); err != nil {
+					apiLog.Errorf("Error reloading the certificates of %s, keeping the previous ones: %s", cfg.Addr, err)
+				}
+			},
+		})
+		l = tl
 	}
 
 	// Basic error and sanity checking
 	switch proto {
 	case "tcp":
-		if !strings.HasPrefix(addr, "127.0.0.1") && !job.GetenvBool("TlsVerify") {
-			log.Infof("/!\\ DON'T BIND ON ANOTHER IP ADDRESS THAN 127.0.0.1 IF YOU DON'T KNOW WHAT YOU'RE DOING /!\\")
+		if !strings.HasPrefix(addr, "127.0.0.1") && !cfg.TlsVerify {
+			apiLog.Infof("/!\\ DON'T BIND ON ANOTHER IP ADDRESS THAN 127.0.0.1 IF YOU DON'T KNOW WHAT YOU'RE DOING /!\\")
 		}
 	case "unix":
-		socketGroup := job.Getenv("SocketGroup")
-		if socketGroup != "" {
-			if err := changeGroup(addr, socketGroup); err != nil {
-				if socketGroup == "docker" {
-					// if the user hasn't explicitly specified the group ownership, don't fail on errors.
-					log.Debugf("Warning: could not chgrp %s to docker: %s", addr, err.Error())
-				} else {
-					return err
-				}
-			}
-		}
-		if err := os.Chmod(addr, 0660); err != nil {
-			return err
-		}
+		// The socket was given its ownership when bound
 	default:
 		return fmt.Errorf("Invalid protocol format.")
 	}
 
-	httpSrv := http.Server{Addr: addr, Handler: r}
-	return httpSrv.Serve(l)
+	return timeouts.server(addr, handler).Serve(timeouts.listener(l))
 }
 
 // ServeApi loops through all of the protocols sent in to docker and spawns
@@ -1352,13 +2167,35 @@ func ListenAndServe(proto, addr string, job *engine.Job) error {
 /*通过循环多种指定协议，创建出 goroutine 协调来配置
 指定的 http.Server ，最终为不同协议的请求服务*/
 func ServeApi(job *engine.Job) engine.Status {
-	if len(job.Args) == 0 { //)检验 Job 的参数，确保传人参数无误。
+	listeners, err := listenerConfigs(job)
+	if err != nil {
+		return job.Error(err)
+	}
+	if len(listeners) == 0 { //)检验 Job 的参数，确保传人参数无误。
 		return job.Errorf("usage: %s PROTO://ADDR [PROTO://ADDR ...]", job.Name)
 	}
-	var ( //定义 Docker Server 的监昕协议与地址，以及错误信息管道 channel
-		protoAddrs = job.Args
-		chErrors   = make(chan error, len(protoAddrs))
-	)
+	//定义错误信息管道 channel
+	chErrors := make(chan error, len(listeners))
+	// The limits of each client are shared by the listeners
+	limits := newAPILimits(job.GetenvInt("ApiMaxRequests"), job.GetenvInt("ApiMaxConnections"), float64(job.GetenvInt("ApiRateLimit")))
+	// So is the access log
+	var logger *accessLog
+	if path := job.Getenv("AccessLog"); path != "" {
+		if logger, err = openAccessLog(path); err != nil {
+			return job.Errorf("Error opening the access log: %s", err)
+		}
+		defer logger.Close()
+		// SIGUSR2 reopens it once rotated
+		signal.Handle(syscall.SIGUSR2, &signal.Handler{
+			Name:    "access log reopen",
+			Timeout: signalHandlerTimeout,
+			Func: func(os.Signal) {
+				if err := logger.reopen(); err != nil {
+					apiLog.Errorf("Error reopening the access log %s: %s", path, err)
+				}
+			},
+		})
+	}
 
 	//se eapi 运行时， ServeFd ListenAndServe 函数
 	//均由于 activationLock 中没有内容而阻塞，而当运行 acceptionconnections 这个 Job 时，该 Job
@@ -1368,18 +2205,15 @@ func ServeApi(job *engine.Job) engine.Status {
 	//务于 API 请求的功能。
 	activationLock = make(chan struct{})
 
-	for _, protoAddr := range protoAddrs { //遍历协议地址，针对协议创建相应的服务端。
-		protoAddrParts := strings.SplitN(protoAddr, "://", 2)
-		if len(protoAddrParts) != 2 {
-			return job.Errorf("usage: %s PROTO://ADDR [PROTO://ADDR ...]", job.Name)
-		}
+	for _, cfg := range listeners { //遍历监听配置，针对协议创建相应的服务端。
+		cfg := cfg
 		go func() { //通过 chErrors 建立 goroutine 与主进程之间的协调关系。
-			log.Infof("Listening for HTTP on %s (%s)", protoAddrParts[0], protoAddrParts[1])
-			chErrors <- ListenAndServe(protoAddrParts[0], protoAddrParts[1], job)
+			apiLog.Infof("Listening for HTTP on %s", cfg.Addr)
+			chErrors <- ListenAndServe(cfg, limits, logger, job)
 		}()
 	}
 
-	for i := 0; i < len(protoAddrs); i += 1 {
+	for i := 0; i < len(listeners); i += 1 {
 		err := <-chErrors
 		if err != nil {
 			return job.Error(err)
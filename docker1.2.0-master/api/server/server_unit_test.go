@@ -2,18 +2,31 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"code.google.com/p/go.net/websocket"
 	"github.com/docker/docker/api"
+	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/version"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libcontainer/cgroups"
 )
 
 func TestGetBoolParam(t *testing.T) {
@@ -89,6 +102,35 @@ func TestGetVersion(t *testing.T) {
 	}
 }
 
+func TestAPIVersionRange(t *testing.T) {
+	eng := engine.New()
+	var called bool
+	eng.Register("version", func(job *engine.Job) engine.Status {
+		called = true
+		return engine.StatusOK
+	})
+	for _, v := range []version.Version{"0.9", "1.99", "1..14"} {
+		r := serveRequestUsingVersion("GET", "/version", v, nil, eng, t)
+		if r.Code != http.StatusBadRequest {
+			t.Fatalf("Expected a bad request for the API version %s, got %d", v, r.Code)
+		}
+	}
+	if called {
+		t.Fatal("The handler was called for an unsupported API version")
+	}
+
+	r := serveRequestUsingVersion("GET", "/version", api.MINAPIVERSION, nil, eng, t)
+	if !called {
+		t.Fatalf("The handler was not called for the API version %s", api.MINAPIVERSION)
+	}
+	if v := r.HeaderMap.Get("Api-Version"); v != string(api.APIVERSION) {
+		t.Fatalf("Expected the Api-Version header to be %s, got %s", api.APIVERSION, v)
+	}
+	if v := r.HeaderMap.Get("Api-Min-Version"); v != string(api.MINAPIVERSION) {
+		t.Fatalf("Expected the Api-Min-Version header to be %s, got %s", api.MINAPIVERSION, v)
+	}
+}
+
 func TestGetInfo(t *testing.T) {
 	eng := engine.New()
 	var called bool
@@ -116,6 +158,128 @@ func TestGetInfo(t *testing.T) {
 	assertContentType(r, "application/json", t)
 }
 
+func TestGetContainersLogUsage(t *testing.T) {
+	eng := engine.New()
+	eng.Register("container_log_usage", func(job *engine.Job) engine.Status {
+		v := &engine.Env{}
+		v.SetInt64("LogsSize", 4096)
+		v.SetInt64("LogsSizeMax", 1048576)
+		v.SetJson("Containers", []map[string]interface{}{{"Id": "4fa6e0f0c678", "Name": "/web", "LogsSize": 4096}})
+		if _, err := v.WriteTo(job.Stdout); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	})
+	r := serveRequest("GET", "/containers/logs/usage", nil, eng, t)
+	assertHttpNotError(r, t)
+	assertContentType(r, "application/json", t)
+	v := readEnv(r.Body, t)
+	if v.GetInt64("LogsSize") != 4096 || v.GetInt64("LogsSizeMax") != 1048576 {
+		t.Fatalf("Unexpected log usage %#v", v)
+	}
+	var containers []struct {
+		Id       string
+		LogsSize int64
+	}
+	if err := v.GetJson("Containers", &containers); err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 || containers[0].Id != "4fa6e0f0c678" || containers[0].LogsSize != 4096 {
+		t.Fatalf("Unexpected containers %#v", containers)
+	}
+
+	if r := serveRequestUsingVersion("GET", "/containers/logs/usage", "1.14", nil, eng, t); r.Code != http.StatusNotFound {
+		t.Fatalf("Expected the log usage not to be found in the API v1.14, got %d", r.Code)
+	}
+}
+
+func TestPing(t *testing.T) {
+	eng := engine.New()
+	r := serveRequest("GET", "/_ping", nil, eng, t)
+	if r.Code != http.StatusOK || r.Body.String() != "OK" {
+		t.Fatalf("Expected OK, got %d %q", r.Code, r.Body.String())
+	}
+
+	state := "healthy"
+	eng.Register("daemon_health", func(job *engine.Job) engine.Status {
+		v := &engine.Env{}
+		v.Set("State", state)
+		v.SetJson("Checks", map[string]string{"storage": "ok"})
+		if _, err := v.WriteTo(job.Stdout); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	})
+	r = serveRequest("GET", "/_ping?verbose=1", nil, eng, t)
+	if r.Code != http.StatusOK {
+		t.Fatalf("Expected 200 when healthy, got %d", r.Code)
+	}
+	assertContentType(r, "application/json", t)
+	if v := readEnv(r.Body, t); v.Get("State") != "healthy" {
+		t.Fatalf("Unexpected health %#v", v)
+	}
+
+	state = "degraded"
+	r = serveRequest("GET", "/_ping?verbose=1", nil, eng, t)
+	if r.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 when degraded, got %d", r.Code)
+	}
+}
+
+func TestPostBuildUpload(t *testing.T) {
+	f, err := ioutil.TempFile("", "docker-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("context")
+	f.Close()
+
+	eng := engine.New()
+	var removed bool
+	eng.Register("upload_inspect", func(job *engine.Job) engine.Status {
+		v := &engine.Env{}
+		v.Set("Id", job.Args[0])
+		v.SetInt64("Size", 7)
+		v.Set("Path", f.Name())
+		if _, err := v.WriteTo(job.Stdout); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	})
+	eng.Register("upload_rm", func(job *engine.Job) engine.Status {
+		removed = true
+		return engine.StatusOK
+	})
+	eng.Register("build", func(job *engine.Job) engine.Status {
+		data, err := ioutil.ReadAll(job.Stdin)
+		if err != nil {
+			return job.Error(err)
+		}
+		if string(data) != "context" {
+			t.Errorf("Expected the upload as context, got %q", data)
+		}
+		return engine.StatusOK
+	})
+
+	r := serveRequest("GET", "/uploads/abc", nil, eng, t)
+	assertHttpNotError(r, t)
+	if v := readEnv(r.Body, t); v.GetInt64("Size") != 7 || v.Exists("Path") {
+		t.Fatalf("Unexpected upload %#v", v)
+	}
+
+	r = serveRequest("POST", "/build?upload=abc", nil, eng, t)
+	assertHttpNotError(r, t)
+	if !removed {
+		t.Fatal("Expected the upload to be removed once built")
+	}
+
+	r = serveRequest("POST", "/images/create?fromSrc=http://example.com/image.tar&upload=abc", strings.NewReader(""), eng, t)
+	if r.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 importing an upload from an url, got %d", r.Code)
+	}
+}
+
 func TestGetImagesJSON(t *testing.T) {
 	eng := engine.New()
 	var called bool
@@ -250,6 +414,36 @@ func TestGetContainersByName(t *testing.T) {
 	}
 }
 
+func TestGetContainersStats(t *testing.T) {
+	eng := engine.New()
+	name := "container_name"
+	eng.Register("stats", func(job *engine.Job) engine.Status {
+		if job.Args[0] != name {
+			t.Errorf("name != '%s': %#v", name, job.Args[0])
+		}
+		stats := &execdriver.ResourceStats{Stats: cgroups.NewStats(), Processes: 2}
+		stats.MemoryStats.Usage = 1024
+		if err := job.WritePayload(stats); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	})
+	r := serveRequest("GET", "/containers/"+name+"/stats", nil, eng, t)
+	assertContentType(r, "application/json", t)
+	var stats struct {
+		MemoryStats struct {
+			Usage uint64 `json:"usage"`
+		} `json:"memory_stats"`
+		Processes int `json:"processes"`
+	}
+	if err := json.Unmarshal(r.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.MemoryStats.Usage != 1024 || stats.Processes != 2 {
+		t.Fatalf("Unexpected stats %s", r.Body.Bytes())
+	}
+}
+
 func TestGetEvents(t *testing.T) {
 	eng := engine.New()
 	var called bool
@@ -474,6 +668,95 @@ func TestDeleteContainers(t *testing.T) {
 	}
 }
 
+func TestPostVolumesCreate(t *testing.T) {
+	eng := engine.New()
+	var called bool
+	eng.Register("volume_create", func(job *engine.Job) engine.Status {
+		called = true
+		if len(job.Args) != 1 || job.Args[0] != "data" {
+			t.Fatalf("Expected the volume name data, got %v", job.Args)
+		}
+		if driver := job.Getenv("driver"); driver != "nfs" {
+			t.Fatalf("Expected the nfs driver, got %q", driver)
+		}
+		if opts := job.GetenvList("opt"); len(opts) != 2 || opts[0] != "server=10.0.0.1" || opts[1] != "export=/data" {
+			t.Fatalf("Expected the driver options, got %v", opts)
+		}
+		job.Printf("%s\n", job.Args[0])
+		return engine.StatusOK
+	})
+	r := serveRequest("POST", "/volumes/create?name=data&driver=nfs&opt=server=10.0.0.1&opt=export=/data", bytes.NewReader(nil), eng, t)
+	if !called {
+		t.Fatalf("handler was not called")
+	}
+	if r.Code != http.StatusCreated {
+		t.Fatalf("Got status %d, expected %d", r.Code, http.StatusCreated)
+	}
+}
+
+func TestLogsMultiplexed(t *testing.T) {
+	eng := engine.New()
+	eng.Register("container_inspect", func(job *engine.Job) engine.Status {
+		job.Stdout.Write([]byte(`{"Config":{"Tty":false}}`))
+		return engine.StatusOK
+	})
+	eng.Register("logs", func(job *engine.Job) engine.Status {
+		job.Stdout.Write([]byte("out"))
+		job.Stderr.Write([]byte("err"))
+		return engine.StatusOK
+	})
+
+	r := serveRequest("GET", "/containers/test/logs?stdout=1&stderr=1", nil, eng, t)
+	if r.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d", r.Code, http.StatusOK)
+	}
+	assertContentType(r, api.MultiplexedStreamContentType, t)
+	var stdout, stderr bytes.Buffer
+	if _, err := utils.StdCopy(&stdout, &stderr, r.Body); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Fatalf("Got stdout %q and stderr %q, expected \"out\" and \"err\"", stdout.String(), stderr.String())
+	}
+
+	// Clients only accepting the raw stream get the output as is
+	r = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/containers/test/logs?stdout=1&stderr=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", api.RawStreamContentType)
+	if err := ServeRequest(eng, api.APIVERSION, r, req); err != nil {
+		t.Fatal(err)
+	}
+	assertContentType(r, api.RawStreamContentType, t)
+	if res := r.Body.String(); res != "outerr" {
+		t.Fatalf("Output %q, expected %q", res, "outerr")
+	}
+
+	// Older versions keep the framed stream without content type
+	r = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/containers/test/logs?stdout=1&stderr=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", api.RawStreamContentType)
+	if err := ServeRequest(eng, "1.14", r, req); err != nil {
+		t.Fatal(err)
+	}
+	if contentType := r.HeaderMap.Get("Content-Type"); contentType == api.MultiplexedStreamContentType || contentType == api.RawStreamContentType {
+		t.Fatalf("Expected no stream content type before 1.15, got %s", contentType)
+	}
+	stdout.Reset()
+	stderr.Reset()
+	if _, err := utils.StdCopy(&stdout, &stderr, r.Body); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Fatalf("Got stdout %q and stderr %q, expected \"out\" and \"err\"", stdout.String(), stderr.String())
+	}
+}
+
 func serveRequest(method, target string, body io.Reader, eng *engine.Engine, t *testing.T) *httptest.ResponseRecorder {
 	return serveRequestUsingVersion(method, target, api.APIVERSION, body, eng, t)
 }
@@ -553,3 +836,950 @@ var sampleImage getImagesJSONStruct = getImagesJSONStruct{
 	Size:        777,
 	VirtualSize: 666,
 }
+
+func TestWsStreamRead(t *testing.T) {
+	var (
+		resized [2]int
+		done    = make(chan string)
+	)
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		s := &wsStream{ws: ws, resize: func(height, width int) error {
+			resized = [2]int{height, width}
+			return nil
+		}}
+		data, _ := ioutil.ReadAll(s)
+		done <- string(data)
+	}))
+	defer srv.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	if _, err := ws.Write([]byte("hello ")); err != nil {
+		t.Fatal(err)
+	}
+	if err := websocket.JSON.Send(ws, &wsControl{Resize: &wsResize{Height: 24, Width: 80}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+
+	if stdin := <-done; stdin != "hello world" {
+		t.Fatalf("Expected the stdin to be %q, got %q", "hello world", stdin)
+	}
+	if resized != [2]int{24, 80} {
+		t.Fatalf("Expected a resize to 24x80, got %v", resized)
+	}
+}
+
+func TestTlsRoles(t *testing.T) {
+	roles, err := parseTlsRoles([]string{"readonly:OU=monitoring", "operator:OU=ops", "admin:CN=alice,OU=ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(subject pkix.Name) *http.Request {
+		return &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: subject}}}}
+	}
+	monitoring := pkix.Name{CommonName: "nagios", OrganizationalUnit: []string{"monitoring"}}
+	ops := pkix.Name{CommonName: "bob", OrganizationalUnit: []string{"ops"}}
+	alice := pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"ops"}}
+	unknown := pkix.Name{CommonName: "eve"}
+
+	for _, c := range []struct {
+		subject pkix.Name
+		method  string
+		route   string
+		allowed bool
+	}{
+		{monitoring, "GET", "/containers/json", true},
+		{monitoring, "GET", "/containers/{name:.*}/exec/ws", false},
+		{ops, "GET", "/containers/{name:.*}/attach/ws", true},
+		{ops, "GET", "/containers/{name:.*}/exec/ws", false},
+		{alice, "GET", "/containers/{name:.*}/exec/ws", true},
+		{monitoring, "DELETE", "/containers/{name:.*}", false},
+		{ops, "POST", "/containers/{name:.*}/stop", true},
+		{ops, "POST", "/images/create", false},
+		{ops, "DELETE", "/containers/{name:.*}", false},
+		{alice, "DELETE", "/containers/{name:.*}", true},
+		{unknown, "GET", "/containers/json", false},
+	} {
+		err := roles.authorize(request(c.subject), c.method, c.route)
+		if allowed := err == nil; allowed != c.allowed {
+			t.Errorf("Expected %s %s by %s to be allowed: %t, got %v", c.method, c.route, c.subject.CommonName, c.allowed, err)
+		}
+	}
+
+	// Without certificate, on the unix socket, or without roles
+	if err := roles.authorize(&http.Request{}, "DELETE", "/containers/{name:.*}"); err != nil {
+		t.Fatal(err)
+	}
+	var none *tlsRoles
+	if err := none.authorize(request(unknown), "DELETE", "/containers/{name:.*}"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []string{"root:CN=alice", "admin", "admin:CN", "admin:SN=alice"} {
+		if _, err := parseTlsRoles([]string{spec}); err == nil {
+			t.Errorf("Expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestTlsRolesHostConfig(t *testing.T) {
+	roles, err := parseTlsRoles([]string{"operator:OU=ops", "admin:CN=alice,OU=ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := pkix.Name{CommonName: "bob", OrganizationalUnit: []string{"ops"}}
+	alice := pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"ops"}}
+	start := func(subject pkix.Name, hostConfig string) error {
+		r, err := http.NewRequest("POST", "/containers/web/start", strings.NewReader(hostConfig))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: subject}}}
+		if err := roles.authorize(r, "POST", "/containers/{name:.*}/start"); err != nil {
+			return err
+		}
+		// The handler still gets the body
+		if body, err := ioutil.ReadAll(r.Body); err != nil || string(body) != hostConfig {
+			t.Fatalf("Expected the body %q to be left for the handler, got %q (%v)", hostConfig, body, err)
+		}
+		return nil
+	}
+
+	for _, hostConfig := range []string{
+		``,
+		`{}`,
+		`{"Privileged":false,"Binds":null,"CapAdd":[],"Devices":null,"PortBindings":{"80/tcp":[{"HostPort":"8080"}]},"NetworkMode":"bridge"}`,
+		`{"RestartPolicy":{"Name":"always"},"Links":["db:db"]}`,
+		`{"VolumesFrom":[],"Dns":["8.8.8.8"],"CapDrop":["NET_RAW"],"PublishAllPorts":true}`,
+	} {
+		if err := start(ops, hostConfig); err != nil {
+			t.Errorf("Expected the operator to start with %s, got %v", hostConfig, err)
+		}
+	}
+	for _, hostConfig := range []string{
+		`{"Privileged":true}`,
+		`{"Binds":["/:/host"]}`,
+		`{"CapAdd":["SYS_ADMIN"]}`,
+		`{"Devices":[{"PathOnHost":"/dev/sda","PathInContainer":"/dev/sda","CgroupPermissions":"rwm"}]}`,
+		`{"DeviceCgroupRules":["b 8:* rwm"]}`,
+		`{"LxcConf":[{"Key":"lxc.aa_profile","Value":"unconfined"}]}`,
+		`{"SeccompProfile":"/etc/docker/unconfined.json"}`,
+		`{"NetworkMode":"host"}`,
+		`{"NetworkMode":"container:admin"}`,
+		`{"VolumesFrom":["admin"]}`,
+		`{"SeccompAudit":true}`,
+		`{"MountOptions":{"/data":["suid"]}}`,
+	} {
+		if err := start(ops, hostConfig); err == nil {
+			t.Errorf("Expected the operator not to start with %s", hostConfig)
+		}
+		if err := start(alice, hostConfig); err != nil {
+			t.Errorf("Expected the admin to start with %s, got %v", hostConfig, err)
+		}
+	}
+}
+
+func TestAuthzPlugins(t *testing.T) {
+	var messages []authzMessage
+	plugin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg authzMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		messages = append(messages, msg)
+		reply := authzReply{Allow: true}
+		switch {
+		case msg.RequestMethod == "DELETE":
+			reply = authzReply{Msg: "no deletion"}
+		case msg.RequestMethod == "POST":
+			reply = authzReply{Err: "Forbidden policy store unreachable"}
+		case r.URL.Path == "/AuthZPlugin.AuthZRes" && msg.ResponseStatusCode == http.StatusNotFound:
+			reply = authzReply{Msg: "no missing containers"}
+		}
+		json.NewEncoder(w).Encode(&reply)
+	}))
+	defer plugin.Close()
+	authz, err := parseAuthzPlugins([]string{"tcp://" + plugin.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, body string, status int) *httptest.ResponseRecorder {
+		messages = nil
+		r, _ := http.NewRequest(method, "/containers/foo/json", strings.NewReader(body))
+		r.RequestURI = "/containers/foo/json"
+		w := httptest.NewRecorder()
+		aw, code, err := authz.authorizeRequest(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return w
+		}
+		if data, _ := ioutil.ReadAll(r.Body); string(data) != body {
+			t.Fatalf("Expected the body %q to be left for the handler, got %q", body, data)
+		}
+		aw.WriteHeader(status)
+		aw.Write([]byte("response"))
+		aw.commit(true)
+		return w
+	}
+
+	if w := serve("GET", "{}", http.StatusOK); w.Code != http.StatusOK || w.Body.String() != "response" {
+		t.Fatalf("Expected the response to be allowed, got %d %q", w.Code, w.Body.String())
+	}
+	if len(messages) != 2 || messages[0].RequestBodyDigest != digest([]byte("{}")) || messages[1].ResponseBodyDigest != digest([]byte("response")) {
+		t.Fatalf("Unexpected messages to the plugin: %v", messages)
+	}
+	if w := serve("DELETE", "", http.StatusNoContent); w.Code != http.StatusForbidden || len(messages) != 1 {
+		t.Fatalf("Expected the request to be denied, got %d after %d messages", w.Code, len(messages))
+	}
+	if w := serve("GET", "", http.StatusNotFound); w.Code != http.StatusForbidden || strings.Contains(w.Body.String(), "response") {
+		t.Fatalf("Expected the response to be denied, got %d %q", w.Code, w.Body.String())
+	}
+	// A plugin failing is not a denial, whatever its error says
+	if w := serve("POST", "", http.StatusOK); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected the plugin error to be a server error, got %d %q", w.Code, w.Body.String())
+	}
+
+	if _, err := parseAuthzPlugins([]string{"http://localhost:8080"}); err == nil {
+		t.Fatal("Expected an error for an http address")
+	}
+}
+
+func TestListenerConfigs(t *testing.T) {
+	eng := engine.New()
+	job := eng.Job("serveapi", "unix:///var/run/docker.sock", "tcp://127.0.0.1:2375")
+	job.Setenv("SocketGroup", "docker")
+	job.Setenv("TlsCert", "/etc/docker/cert.pem")
+	job.Setenv("Listeners", `[
+		{"Addr": "tcp://127.0.0.1:2375", "ReadOnly": true},
+		{"Addr": "tcp://0.0.0.0:2376", "TlsVerify": true, "TlsKey": "/etc/docker/tcp-key.pem"}
+	]`)
+	listeners, err := listenerConfigs(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 3 {
+		t.Fatalf("Expected 3 listeners, got %d", len(listeners))
+	}
+	if l := listeners[0]; l.Addr != "unix:///var/run/docker.sock" || l.Group != "docker" || l.ReadOnly {
+		t.Fatalf("Unexpected listener from -H: %#v", l)
+	}
+	if l := listeners[1]; l.Addr != "tcp://127.0.0.1:2375" || !l.ReadOnly {
+		t.Fatalf("Expected the listener of the list to replace the one from -H, got %#v", l)
+	}
+	if l := listeners[2]; !l.TlsVerify || l.TlsCert != "/etc/docker/cert.pem" || l.TlsKey != "/etc/docker/tcp-key.pem" {
+		t.Fatalf("Unexpected TLS listener: %#v", l)
+	}
+
+	// The trusted proxies of the daemon apply to the listeners without
+	job.Setenv("Listeners", `[{"Addr": "tcp://0.0.0.0:2377", "ProxyProtocol": true}]`)
+	job.SetenvList("ProxyTrusted", []string{"10.0.0.0/24"})
+	if listeners, err = listenerConfigs(job); err != nil {
+		t.Fatal(err)
+	}
+	if l := listeners[2]; len(l.ProxyTrusted) != 1 || l.ProxyTrusted[0] != "10.0.0.0/24" {
+		t.Fatalf("Expected the trusted proxies of the daemon, got %#v", l)
+	}
+
+	for _, invalid := range []string{
+		`[{"Addr": "tcp://0.0.0.0:2376"}, {"Addr": "tcp://0.0.0.0:2376"}]`,
+		`[{"Addr": "0.0.0.0:2376"}]`,
+		`{"Addr": "tcp://0.0.0.0:2376"}`,
+		`[{"Addr": "tcp://0.0.0.0:2376", "ProxyProtocol": true}]`,
+		`[{"Addr": "tcp://0.0.0.0:2376", "ProxyProtocol": true, "ProxyTrusted": ["lb.local"]}]`,
+	} {
+		job := eng.Job("serveapi")
+		job.Setenv("Listeners", invalid)
+		if _, err := listenerConfigs(job); err == nil {
+			t.Errorf("Expected an error for the listeners %s", invalid)
+		}
+	}
+}
+
+func TestAPILimits(t *testing.T) {
+	if newAPILimits(0, 0, 0) != nil {
+		t.Fatal("Expected no limits")
+	}
+	now := time.Now()
+
+	limits := newAPILimits(2, 0, 0)
+	release, err := limits.acquire("10.0.0.1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limits.acquire("10.0.0.1", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limits.acquire("10.0.0.1", now); err == nil {
+		t.Fatal("Expected a third request in flight to be refused")
+	}
+	if _, err := limits.acquire("10.0.0.2", now); err != nil {
+		t.Fatalf("Expected another client not to be limited: %s", err)
+	}
+	release()
+	if _, err := limits.acquire("10.0.0.1", now); err != nil {
+		t.Fatalf("Expected a request to be accepted once another is done: %s", err)
+	}
+
+	limits = newAPILimits(0, 0, 2)
+	for i := 0; i < 2; i++ {
+		release, err := limits.acquire("10.0.0.1", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if _, err := limits.acquire("10.0.0.1", now); err == nil {
+		t.Fatal("Expected a third request in the same second to be refused")
+	}
+	if _, err := limits.acquire("10.0.0.1", now.Add(500*time.Millisecond)); err != nil {
+		t.Fatalf("Expected a request to be accepted half a second later: %s", err)
+	}
+
+	limits = newAPILimits(0, 1, 0)
+	release, err = limits.acquireConn("uid=1000", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limits.acquireConn("uid=1000", now); err == nil {
+		t.Fatal("Expected a second connection to be refused")
+	}
+	if _, err := limits.acquireConn("uid=0", now); err != nil {
+		t.Fatalf("Expected another user not to be limited: %s", err)
+	}
+	release()
+	release()
+	if _, err := limits.acquireConn("uid=1000", now); err != nil {
+		t.Fatalf("Expected a connection to be accepted once another is closed: %s", err)
+	}
+	if _, err := limits.acquireConn("uid=1000", now); err == nil {
+		t.Fatal("Expected a release to only count once")
+	}
+}
+
+func TestAPILimitsConnections(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = limitConns(l, newAPILimits(0, 1, 0))
+	defer l.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	first, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	conn := <-accepted
+
+	// The second connection of the client is closed
+	second, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("Expected the connection over the cap to be closed, got %v", err)
+	}
+
+	// Until the first is
+	conn.Close()
+	third, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a connection to be accepted once the first is closed")
+	}
+}
+
+func TestAPILimitsHijack(t *testing.T) {
+	limits := newAPILimits(1, 0, 0)
+	access := &apiAccess{limits: limits}
+	hijacked := make(chan struct{})
+	done := make(chan struct{})
+	h := makeHttpHandler(engine.New(), false, "POST", "/containers/{name:.*}/attach", func(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		close(hijacked)
+		<-done
+		return nil
+	}, false, version.Version("1.15"), access)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	go func() {
+		r, _ := http.NewRequest("POST", srv.URL+"/containers/foo/attach", strings.NewReader(""))
+		if resp, err := http.DefaultClient.Do(r); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-hijacked
+	// The stream left its slot to the next requests
+	if _, err := limits.acquire("127.0.0.1", time.Now()); err != nil {
+		t.Fatalf("Expected the stream not to count as a request in flight: %s", err)
+	}
+	close(done)
+}
+
+func TestClientIdentity(t *testing.T) {
+	for _, c := range []struct {
+		r        *http.Request
+		identity string
+	}{
+		{&http.Request{RemoteAddr: "10.0.0.1:51234"}, "10.0.0.1"},
+		{&http.Request{RemoteAddr: "@"}, "unix"},
+		{&http.Request{RemoteAddr: "uid=1000,gid=1000,pid=4242"}, "uid=1000"},
+		{&http.Request{RemoteAddr: "10.0.0.1:51234", TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "kubelet"}}}}}, "CN=kubelet"},
+	} {
+		if identity := clientIdentity(c.r); identity != c.identity {
+			t.Errorf("Expected %q, got %q", c.identity, identity)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &accessLog{w: &buf}
+	h := logger.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc123")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No such container: foo"))
+	}))
+	r, _ := http.NewRequest("GET", "/containers/foo/json", nil)
+	r.RequestURI = "/containers/foo/json"
+	r.RemoteAddr = "10.0.0.1:51234"
+	r.Header.Set("User-Agent", "Docker-Client/1.2.0")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	line := buf.String()
+	for _, part := range []string{
+		"10.0.0.1 - - [",
+		`] "GET /containers/foo/json HTTP/1.1" 404 22 "" "Docker-Client/1.2.0" abc123` + "\n",
+	} {
+		if !strings.Contains(line, part) {
+			t.Fatalf("Expected %q in the access log, got %q", part, line)
+		}
+	}
+}
+
+func TestAccessLogReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-access-log-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	logger, err := openAccessLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	h := logger.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(uri string) {
+		r, _ := http.NewRequest("GET", uri, nil)
+		r.RequestURI = uri
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve("/_ping")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	serve("/version")
+	if err := logger.reopen(); err != nil {
+		t.Fatal(err)
+	}
+	serve("/info")
+
+	rotated, err := ioutil.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rotated), "/version") || strings.Contains(string(rotated), "/info") {
+		t.Fatalf("Unexpected rotated log %q", rotated)
+	}
+	if !strings.Contains(string(current), "/info") || strings.Count(string(current), "\n") != 1 {
+		t.Fatalf("Unexpected log %q", current)
+	}
+}
+
+// closeNotifyRecorder is a recorder whose client disconnects when closed is.
+type closeNotifyRecorder struct {
+	*httptest.ResponseRecorder
+	closed chan bool
+}
+
+func (r *closeNotifyRecorder) CloseNotify() <-chan bool {
+	return r.closed
+}
+
+func registerBlockingWait(eng *engine.Engine) chan struct{} {
+	started := make(chan struct{})
+	eng.Register("wait", func(job *engine.Job) engine.Status {
+		close(started)
+		<-job.Canceled()
+		return job.Errorf("wait canceled")
+	})
+	return started
+}
+
+func TestCancelRequest(t *testing.T) {
+	eng := engine.New()
+	started := registerBlockingWait(eng)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		r := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/containers/foo/wait", strings.NewReader(""))
+		req.Header.Set("X-Request-Id", "waitfoo")
+		if err := ServeRequest(eng, api.APIVERSION, r, req); err != nil {
+			t.Error(err)
+		}
+		done <- r
+	}()
+	<-started
+
+	if r := serveRequest("POST", "/requests/other/cancel", nil, eng, t); r.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 canceling an unknown request, got %d", r.Code)
+	}
+	if r := serveRequest("POST", "/requests/waitfoo/cancel", nil, eng, t); r.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", r.Code, r.Body.String())
+	}
+	select {
+	case r := <-done:
+		if r.Code != http.StatusInternalServerError || !strings.Contains(r.Body.String(), "canceled") {
+			t.Fatalf("Expected the wait to fail canceled, got %d %q", r.Code, r.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The wait wasn't canceled")
+	}
+
+	// The request is forgotten once served
+	if r := serveRequest("POST", "/requests/waitfoo/cancel", nil, eng, t); r.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 canceling a served request, got %d", r.Code)
+	}
+}
+
+func TestCancelOnClose(t *testing.T) {
+	eng := engine.New()
+	started := registerBlockingWait(eng)
+
+	r := &closeNotifyRecorder{ResponseRecorder: httptest.NewRecorder(), closed: make(chan bool, 1)}
+	done := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("POST", "/containers/foo/wait", strings.NewReader(""))
+		if err := ServeRequest(eng, api.APIVERSION, r, req); err != nil {
+			t.Error(err)
+		}
+		close(done)
+	}()
+	<-started
+	r.closed <- true
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The wait wasn't canceled when the client disconnected")
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	eng := engine.New()
+	registerBlockingWait(eng)
+
+	start := time.Now()
+	r := serveRequest("POST", "/containers/foo/wait?timeout=1", strings.NewReader(""), eng, t)
+	if r.Code != http.StatusInternalServerError || !strings.Contains(r.Body.String(), "Timeout") {
+		t.Fatalf("Expected the wait to time out, got %d %q", r.Code, r.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 5*time.Second {
+		t.Fatalf("Expected the wait to give up after a second, took %s", elapsed)
+	}
+
+	r = serveRequest("POST", "/containers/foo/wait?timeout=-1", strings.NewReader(""), eng, t)
+	if r.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for a negative timeout, got %d", r.Code)
+	}
+}
+
+func TestCancelOnHangup(t *testing.T) {
+	reqEng := engine.New().WithRequestID("abc123")
+	defer reqEng.Release()
+
+	r, w := io.Pipe()
+	cancelOnHangup(reqEng, r)
+	w.Write([]byte("ignored"))
+	w.Close()
+	select {
+	case <-reqEng.Canceled():
+	case <-time.After(5 * time.Second):
+		t.Fatal("The request wasn't canceled when the client hung up")
+	}
+}
+
+func TestParseServerTimeouts(t *testing.T) {
+	job := engine.New().Job("serveapi")
+	job.Setenv("ApiReadTimeout", "30s")
+	job.Setenv("ApiIdleTimeout", "2m0s")
+	timeouts, err := parseServerTimeouts(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := serverTimeouts{read: 30 * time.Second, idle: 2 * time.Minute}
+	if *timeouts != expected {
+		t.Fatalf("Expected %#v, got %#v", expected, *timeouts)
+	}
+	srv := timeouts.server(":0", nil)
+	if srv.ReadTimeout != 30*time.Second || srv.WriteTimeout != 0 || srv.ConnState == nil {
+		t.Fatalf("Unexpected server %#v", srv)
+	}
+
+	job.Setenv("ApiWriteTimeout", "-1s")
+	if _, err := parseServerTimeouts(job); err == nil {
+		t.Fatal("Expected an error for a negative timeout")
+	}
+}
+
+func TestWriteTimeoutStreams(t *testing.T) {
+	timeouts := &serverTimeouts{write: 100 * time.Millisecond}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// A stream writing slower than the write timeout
+	srv := timeouts.server("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "event %d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	go srv.Serve(timeouts.listener(l))
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "event 0\nevent 1\nevent 2\n"; string(body) != expected {
+		t.Fatalf("Expected the whole stream %q, got %q", expected, body)
+	}
+}
+
+func TestSchemaCoversRoutes(t *testing.T) {
+	routes := apiRoutes()
+	for method, handlers := range routes {
+		for route := range handlers {
+			if _, exists := routeSchemas[method][route]; !exists && route != "" {
+				t.Errorf("No schema for %s %s", method, route)
+			}
+		}
+	}
+	for method, schemas := range routeSchemas {
+		for route, schema := range schemas {
+			if _, exists := routes[method][route]; !exists {
+				t.Errorf("Schema of the unknown route %s %s", method, route)
+			}
+			if schema.Response == "" {
+				t.Errorf("No response in the schema of %s %s", method, route)
+			}
+		}
+	}
+}
+
+func TestGetSchema(t *testing.T) {
+	r := serveRequest("GET", "/schema", nil, engine.New(), t)
+	assertHttpNotError(r, t)
+	assertContentType(r, "application/json", t)
+	var schema apiSchema
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Version != api.APIVERSION || schema.MinVersion != api.MINAPIVERSION {
+		t.Fatalf("Unexpected versions %s and %s", schema.Version, schema.MinVersion)
+	}
+	for _, e := range schema.Endpoints {
+		if e.Method == "POST" && e.Path == "/containers/{name}/kill" {
+			if len(e.Params) != 1 || e.Params[0].Name != "signal" || e.Response != "none" {
+				t.Fatalf("Unexpected endpoint %#v", e)
+			}
+			return
+		}
+	}
+	t.Fatalf("POST /containers/{name}/kill missing from %#v", schema.Endpoints)
+}
+
+func TestGetSchemaVersion(t *testing.T) {
+	// The schema was added in 1.15, like the endpoints it describes
+	r := serveRequestUsingVersion("GET", "/schema", "1.14", nil, engine.New(), t)
+	if r.Code != http.StatusNotFound {
+		t.Fatalf("Expected no schema in the API v1.14, got %d", r.Code)
+	}
+	r = serveRequestUsingVersion("GET", "/schema", additionsVersion, nil, engine.New(), t)
+	assertHttpNotError(r, t)
+	var schema apiSchema
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Version != api.APIVERSION {
+		t.Fatalf("Expected the schema of %s, got %s", api.APIVERSION, schema.Version)
+	}
+}
+
+func TestRouteVersions(t *testing.T) {
+	eng := engine.New()
+	var called bool
+	eng.Register("volume_ls", func(job *engine.Job) engine.Status {
+		called = true
+		return engine.StatusOK
+	})
+	r := serveRequestUsingVersion("GET", "/volumes/json", "1.14", nil, eng, t)
+	if r.Code != http.StatusNotFound || called {
+		t.Fatalf("Expected the volumes not to be found in the API v1.14, got %d", r.Code)
+	}
+	r = serveRequestUsingVersion("GET", "/volumes/json", additionsVersion, nil, eng, t)
+	if !called {
+		t.Fatalf("Expected the volumes to be listed in the API v%s, got %d", additionsVersion, r.Code)
+	}
+
+	// The parameters and the fields of the body added since are dropped
+	req, err := http.NewRequest("POST", "/containers/web/wait?timeout=1&other=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRouteVersion("1.14", "POST", "/containers/{name:.*}/wait", req); err != nil {
+		t.Fatal(err)
+	}
+	if query := req.URL.Query(); query.Get("timeout") != "" || query.Get("other") != "1" {
+		t.Fatalf("Expected only the timeout to be dropped, got %s", req.URL.RawQuery)
+	}
+
+	var hostConfig *engine.Env
+	eng.Register("start", func(job *engine.Job) engine.Status {
+		hostConfig = job.Env()
+		return engine.StatusOK
+	})
+	start := func(v version.Version) {
+		req, err := http.NewRequest("POST", "/containers/web/start", strings.NewReader(`{"Gpus":"all","Dns":["8.8.8.8"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		r := httptest.NewRecorder()
+		if err := ServeRequest(eng, v, r, req); err != nil {
+			t.Fatal(err)
+		}
+		assertHttpNotError(r, t)
+	}
+	start("1.14")
+	if hostConfig.Get("Gpus") != "" || hostConfig.Get("Dns") == "" {
+		t.Fatalf("Expected only the GPUs to be dropped in the API v1.14, got %v", hostConfig)
+	}
+	start(additionsVersion)
+	if hostConfig.Get("Gpus") != "all" {
+		t.Fatalf("Expected the GPUs in the API v%s, got %v", additionsVersion, hostConfig)
+	}
+}
+
+func TestResponseFieldVersions(t *testing.T) {
+	eng := engine.New()
+	eng.Register("container_inspect", func(job *engine.Job) engine.Status {
+		out := &engine.Env{}
+		out.Set("Id", "web")
+		out.Set("ImageDigest", "sha256:abc")
+		out.SetJson("State", map[string]interface{}{"Running": true, "Health": map[string]string{"Status": "healthy"}})
+		if _, err := out.WriteTo(job.Stdout); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	})
+
+	r := serveRequestUsingVersion("GET", "/containers/web/json", "1.14", nil, eng, t)
+	assertHttpNotError(r, t)
+	container := readEnv(r.Body, t)
+	state := container.GetSubEnv("State")
+	if container.Get("Id") != "web" || container.Exists("ImageDigest") || state == nil || !state.GetBool("Running") || state.Exists("Health") {
+		t.Fatalf("Expected the container without the fields added since v1.14, got %v", container)
+	}
+
+	r = serveRequestUsingVersion("GET", "/containers/web/json", additionsVersion, nil, eng, t)
+	assertHttpNotError(r, t)
+	container = readEnv(r.Body, t)
+	if container.Get("ImageDigest") == "" || !container.GetSubEnv("State").Exists("Health") {
+		t.Fatalf("Expected the container with all its fields in the API v%s, got %v", additionsVersion, container)
+	}
+}
+
+func TestListenUnixOwnership(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "docker.sock")
+	// The socket of a previous daemon is replaced
+	if err := ioutil.WriteFile(addr, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ownership, err := parseSocketOwnership(&ListenerConfig{
+		Addr:  "unix://" + addr,
+		Owner: strconv.Itoa(os.Getuid()),
+		Group: strconv.Itoa(os.Getgid()),
+		Mode:  "0640",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := listen("unix", addr, ownership, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	fi, err := os.Stat(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0640 {
+		t.Fatalf("Expected a socket with mode 0640, got %s", fi.Mode())
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("Expected only the socket in %s, got %d files", dir, len(files))
+	}
+
+	for _, cfg := range []*ListenerConfig{
+		{Addr: "unix://" + addr, Mode: "0999"},
+		{Addr: "unix://" + addr, Mode: "01777"},
+		{Addr: "unix://" + addr, Owner: "no-such-user-of-docker"},
+		{Addr: "unix://" + addr, Group: "no-such-group-of-docker"},
+	} {
+		if _, err := parseSocketOwnership(cfg); err == nil {
+			t.Errorf("Expected an error for %#v", cfg)
+		}
+	}
+	// The default group may not exist
+	if o, err := parseSocketOwnership(&ListenerConfig{Addr: "unix://" + addr, Group: "docker"}); err != nil || o.mode != defaultSocketMode {
+		t.Fatalf("Unexpected ownership %#v (%v)", o, err)
+	}
+}
+
+func TestAsyncJob(t *testing.T) {
+	asyncJobs = &asyncJobStore{jobs: make(map[string]*asyncJob)}
+	eng := engine.New()
+	proceed := make(chan struct{})
+	eng.Register("pull", func(job *engine.Job) engine.Status {
+		job.Printf("{\"status\":\"Pulling %s\"}\n", job.Args[0])
+		switch job.Args[0] {
+		case "bad":
+			return job.Errorf("Error: image bad not found")
+		case "slow":
+			<-job.Canceled()
+			return job.Errorf("Pull canceled")
+		}
+		<-proceed
+		job.Printf("{\"status\":\"Downloaded\"}\n")
+		return engine.StatusOK
+	})
+	serve := func(method, path, id string) *httptest.ResponseRecorder {
+		r := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(""))
+		if id != "" {
+			req.Header.Set("X-Request-Id", id)
+		}
+		if err := ServeRequest(eng, api.APIVERSION, r, req); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	status := func(id string) *engine.Env {
+		r := serve("GET", "/jobs/"+id+"/json", "")
+		if r.Code != http.StatusOK {
+			t.Fatalf("%d from the status of job %s: %s", r.Code, id, r.Body)
+		}
+		out := &engine.Env{}
+		if err := out.Decode(r.Body); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	r := serve("POST", "/images/create?fromImage=good&async=1", "pullgood")
+	if r.Code != http.StatusAccepted || !strings.Contains(r.Body.String(), "pullgood") {
+		t.Fatalf("Expected the id of the job with a 202, got %d: %s", r.Code, r.Body)
+	}
+	if s := status("pullgood").Get("Status"); s != asyncRunning {
+		t.Fatalf("Expected the job to be running, got %s", s)
+	}
+	if r := serve("POST", "/images/create?fromImage=good&async=1", "pullgood"); r.Code != http.StatusConflict {
+		t.Fatalf("Expected a 409 starting a job with the id of another, got %d", r.Code)
+	}
+	if r := serve("DELETE", "/jobs/pullgood", ""); r.Code != http.StatusConflict {
+		t.Fatalf("Expected a 409 removing a running job, got %d", r.Code)
+	}
+
+	close(proceed)
+	r = serve("GET", "/jobs/pullgood/logs?follow=1", "")
+	if r.Body.String() != "{\"status\":\"Pulling good\"}\n{\"status\":\"Downloaded\"}\n" {
+		t.Fatalf("Unexpected output %q", r.Body)
+	}
+	if s := status("pullgood").Get("Status"); s != asyncSucceeded {
+		t.Fatalf("Expected the job to have succeeded, got %s", s)
+	}
+
+	serve("POST", "/images/create?fromImage=bad&async=1", "pullbad")
+	serve("GET", "/jobs/pullbad/logs?follow=1", "")
+	if s := status("pullbad"); s.Get("Status") != asyncFailed || s.Get("Error") != "Error: image bad not found" {
+		t.Fatalf("Expected the job to have failed with its error, got %v", s)
+	}
+
+	serve("POST", "/images/create?fromImage=slow&async=1", "pullslow")
+	if r := serve("POST", "/requests/pullslow/cancel", ""); r.Code != http.StatusNoContent {
+		t.Fatalf("Expected a 204 canceling the job, got %d: %s", r.Code, r.Body)
+	}
+	serve("GET", "/jobs/pullslow/logs?follow=1", "")
+	if s := status("pullslow").Get("Status"); s != asyncCanceled {
+		t.Fatalf("Expected the job to be canceled, got %s", s)
+	}
+
+	if r := serve("DELETE", "/jobs/pullgood", ""); r.Code != http.StatusNoContent {
+		t.Fatalf("Expected a 204 removing a finished job, got %d", r.Code)
+	}
+	if r := serve("GET", "/jobs/pullgood/json", ""); r.Code != http.StatusNotFound {
+		t.Fatalf("Expected a 404 for a removed job, got %d", r.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/engine"
+)
+
+// serverTimeouts bound how long a connection may take to send its request,
+// to receive each write of the response, and to send the next request once
+// idle. None applies when zero.
+type serverTimeouts struct {
+	read  time.Duration
+	write time.Duration
+	idle  time.Duration
+}
+
+func parseServerTimeouts(job *engine.Job) (*serverTimeouts, error) {
+	t := &serverTimeouts{}
+	for key, d := range map[string]*time.Duration{
+		"ApiReadTimeout":  &t.read,
+		"ApiWriteTimeout": &t.write,
+		"ApiIdleTimeout":  &t.idle,
+	} {
+		value := job.Getenv(key)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("Invalid %s: %s", key, value)
+		}
+		*d = parsed
+	}
+	return t, nil
+}
+
+// server returns the http server of handler applying the read and idle
+// timeouts, the write timeout being applied by listener.
+func (t *serverTimeouts) server(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:        addr,
+		Handler:     handler,
+		ReadTimeout: t.read,
+		ConnState:   t.connState,
+	}
+}
+
+// listener applies the write timeout to the connections of l. Unlike the
+// write timeout of the http server, running from the end of the request,
+// it bounds each write: the streams like events or logs --follow, writing
+// as their content comes, last as long as their client keeps reading.
+func (t *serverTimeouts) listener(l net.Listener) net.Listener {
+	if t.write == 0 {
+		return l
+	}
+	return &writeTimeoutListener{Listener: l, timeout: t.write}
+}
+
+type writeTimeoutListener struct {
+	net.Listener
+	timeout time.Duration
+}
+
+func (l *writeTimeoutListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return conn, err
+	}
+	return &writeTimeoutConn{Conn: conn, timeout: l.timeout}, nil
+}
+
+// writeTimeoutConn sets the write deadline of each write, until hijacked.
+type writeTimeoutConn struct {
+	net.Conn
+	timeout  time.Duration
+	hijacked bool
+}
+
+func (c *writeTimeoutConn) Write(p []byte) (int, error) {
+	if !c.hijacked {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	return c.Conn.Write(p)
+}
+
+// connState applies the idle timeout between the requests of a connection.
+// The hijacked connections, attached to containers, have no deadline.
+func (t *serverTimeouts) connState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateIdle:
+		if t.idle > 0 {
+			conn.SetReadDeadline(time.Now().Add(t.idle))
+		}
+	case http.StateActive:
+		// The server sets the read deadline of each request when it has a
+		// read timeout
+		if t.idle > 0 && t.read == 0 {
+			conn.SetReadDeadline(time.Time{})
+		}
+	case http.StateHijacked:
+		if c, ok := conn.(*writeTimeoutConn); ok {
+			c.hijacked = true
+		}
+		conn.SetDeadline(time.Time{})
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+)
+
+// tlsListener serves TLS with the certificates loaded last, for them to be
+// renewed without restarting the daemon.
+type tlsListener struct {
+	net.Listener
+	cfg    *ListenerConfig
+	mu     sync.RWMutex
+	config *tls.Config
+}
+
+func newTlsListener(l net.Listener, cfg *ListenerConfig) (*tlsListener, error) {
+	config, err := loadTlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &tlsListener{Listener: l, cfg: cfg, config: config}, nil
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.RLock()
+	config := l.config
+	l.mu.RUnlock()
+	return tls.Server(c, config), nil
+}
+
+// reload loads the certificates again, the connections accepted from then on
+// being served with them.
+func (l *tlsListener) reload() error {
+	config, err := loadTlsConfig(l.cfg)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.config = config
+	l.mu.Unlock()
+	return nil
+}
+
+// loadTlsConfig reads the certificate and the key of the listener, and the
+// CA the clients are verified with.
+func loadTlsConfig(cfg *ListenerConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TlsCert, cfg.TlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load X509 key pair (%s, %s): %s. Key encrypted?",
+			cfg.TlsCert, cfg.TlsKey, err)
+	}
+	config := &tls.Config{
+		NextProtos:   []string{"http/1.1"},
+		Certificates: []tls.Certificate{cert},
+	}
+	if cfg.TlsVerify {
+		certPool := x509.NewCertPool()
+		file, err := ioutil.ReadFile(cfg.TlsCa)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read CA certificate: %s", err)
+		}
+		certPool.AppendCertsFromPEM(file)
+
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = certPool
+	}
+	return config, nil
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/docker/libcontainer/user"
+)
+
+// defaultSocketMode is the mode of the unix sockets without one configured.
+const defaultSocketMode os.FileMode = 0660
+
+// socketOwnership is the owner, the group and the mode given to a unix
+// socket when bound. A uid or gid of -1 is left unchanged.
+type socketOwnership struct {
+	uid  int
+	gid  int
+	mode os.FileMode
+}
+
+// parseSocketOwnership returns the ownership of the unix socket of the
+// listener.
+func parseSocketOwnership(cfg *ListenerConfig) (*socketOwnership, error) {
+	o := &socketOwnership{uid: -1, gid: -1, mode: defaultSocketMode}
+	if cfg.Owner != "" {
+		uid, err := lookupUidByName(cfg.Owner)
+		if err != nil {
+			return nil, err
+		}
+		o.uid = uid
+	}
+	if cfg.Group != "" {
+		gid, err := lookupGidByName(cfg.Group)
+		if err != nil {
+			if cfg.Group != "docker" {
+				return nil, err
+			}
+			// if the user hasn't explicitly specified the group ownership, don't fail on errors.
+			apiLog.Debugf("Warning: could not chgrp %s to docker: %s", cfg.Addr, err)
+		} else {
+			o.gid = gid
+		}
+	}
+	if cfg.Mode != "" {
+		mode, err := strconv.ParseUint(cfg.Mode, 8, 32)
+		if err != nil || mode > 0777 {
+			return nil, fmt.Errorf("Invalid mode %s for %s, expected octal permissions like 0660", cfg.Mode, cfg.Addr)
+		}
+		o.mode = os.FileMode(mode)
+	}
+	return o, nil
+}
+
+// apply gives its ownership to the socket at path.
+func (o *socketOwnership) apply(path string) error {
+	if o.uid != -1 || o.gid != -1 {
+		if err := os.Chown(path, o.uid, o.gid); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(path, o.mode)
+}
+
+func lookupUidByName(nameOrUid string) (int, error) {
+	if uid, err := strconv.Atoi(nameOrUid); err == nil && uid >= 0 {
+		return uid, nil
+	}
+	users, err := user.ParsePasswdFilter(func(u *user.User) bool {
+		return u.Name == nameOrUid
+	})
+	if err != nil {
+		return -1, err
+	}
+	if len(users) > 0 {
+		return users[0].Uid, nil
+	}
+	return -1, fmt.Errorf("User %s not found", nameOrUid)
+}
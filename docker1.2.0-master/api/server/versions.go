@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/version"
+)
+
+// The routes, parameters and fields added in the version 1.15 of the API are
+// left out of the older versions, which are served as they were released.
+const additionsVersion version.Version = "1.15"
+
+// newRoutes are the routes added in additionsVersion, not found in the older
+// versions.
+var newRoutes = map[string]map[string]struct{}{
+	"GET": {
+		"/schema":                       {},
+		"/images/get":                   {},
+		"/build/cache":                  {},
+		"/images/usage":                 {},
+		"/containers/logs/usage":        {},
+		"/containers/{name:.*}/stats":   {},
+		"/containers/{name:.*}/exec/ws": {},
+		"/volumes/json":                 {},
+		"/volumes/{name:.*}/json":       {},
+		"/uploads/{name:.*}":            {},
+		"/jobs/json":                    {},
+		"/jobs/{id:.*}/json":            {},
+		"/jobs/{id:.*}/logs":            {},
+		"/loglevels":                    {},
+	},
+	"POST": {
+		"/build/batch":             {},
+		"/volumes/create":          {},
+		"/volumes/prune":           {},
+		"/images/gc":               {},
+		"/graph/migrate":           {},
+		"/graph/fsck":              {},
+		"/uploads":                 {},
+		"/uploads/{name:.*}":       {},
+		"/requests/{id:.*}/cancel": {},
+		"/loglevels":               {},
+	},
+	"DELETE": {
+		"/volumes/{name:.*}": {},
+		"/uploads/{name:.*}": {},
+		"/jobs/{id:.*}":      {},
+	},
+}
+
+// newParams are the query parameters added in additionsVersion to the
+// existing routes, ignored in the older versions.
+var newParams = map[string]map[string][]string{
+	"GET": {
+		"/_ping":                          {"verbose"},
+		"/images/json":                    {"limit", "offset", "usage"},
+		"/containers/json":                {"offset"},
+		"/containers/{name:.*}/logs":      {"since"},
+		"/containers/{name:.*}/attach/ws": {"binary"},
+	},
+	"POST": {
+		"/commit":                    {"squash", "timestamp"},
+		"/build":                     {"async", "buildargs", "dryrun", "gitdepth", "gitref", "ignoremtime", "squash", "steps", "timestamp", "upload"},
+		"/images/create":             {"async", "upload"},
+		"/images/{name:.*}/push":     {"async"},
+		"/containers/{name:.*}/wait": {"timeout"},
+	},
+}
+
+// newHeaders are the request headers added in additionsVersion, ignored in
+// the older versions.
+var newHeaders = map[string]map[string][]string{
+	"POST": {
+		"/build": {"X-Build-Secrets"},
+	},
+}
+
+// newBodyFields are the fields of the json bodies added in additionsVersion,
+// ignored in the older versions: the config of a container created and the
+// host config of a container started.
+var newBodyFields = map[string]map[string][]string{
+	"POST": {
+		"/containers/create":          {"Labels", "Healthcheck"},
+		"/containers/{name:.*}/start": {"DeviceCgroupRules", "Gpus", "LogConfig", "LxcFragment", "LxcTemplate", "MountOptions", "SeccompAudit", "SeccompProfile", "Ulimits"},
+	},
+}
+
+// The fields of the responses added in additionsVersion, left out of the
+// older versions, the nested ones named like State.Health.
+var (
+	newInfoFields      = []string{"CgroupControllers", "CgroupUnified", "Handlers", "DriverCapabilities", "ExecutionDriverCapabilities", "LogsSize", "LogsSizeMax"}
+	newVersionFields   = []string{"MinAPIVersion"}
+	newContainerFields = []string{
+		"ImageDigest",
+		"Config.Labels", "Config.Healthcheck",
+		"State.OOMKilled", "State.Health", "State.Usage",
+		"HostConfig.DeviceCgroupRules", "HostConfig.Gpus", "HostConfig.LogConfig", "HostConfig.LxcFragment", "HostConfig.LxcTemplate",
+		"HostConfig.MountOptions", "HostConfig.SeccompAudit", "HostConfig.SeccompProfile", "HostConfig.Ulimits",
+	}
+)
+
+// checkRouteVersion refuses the routes added after the version of the API
+// the client asked for, and removes the parameters, headers and body fields
+// added after it from the request.
+func checkRouteVersion(v version.Version, method, route string, r *http.Request) error {
+	if !v.LessThan(additionsVersion) {
+		return nil
+	}
+	if _, exists := newRoutes[method][route]; exists {
+		return fmt.Errorf("No such route %s %s in the API v%s, it was added in v%s", method, route, v, additionsVersion)
+	}
+	if params, exists := newParams[method][route]; exists {
+		query := r.URL.Query()
+		for _, param := range params {
+			query.Del(param)
+			if r.Form != nil {
+				r.Form.Del(param)
+			}
+		}
+		r.URL.RawQuery = query.Encode()
+	}
+	for _, header := range newHeaders[method][route] {
+		r.Header.Del(header)
+	}
+	if fields, exists := newBodyFields[method][route]; exists {
+		return dropBodyFields(r, fields)
+	}
+	return nil
+}
+
+// dropBodyFields removes the fields from the json object of the body of r.
+// The bodies which are not json objects are left to the handler to report.
+func dropBodyFields(r *http.Request, fields []string) error {
+	if r.Body == nil || r.ContentLength == 0 {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err == nil && object != nil {
+		for _, field := range fields {
+			delete(object, field)
+		}
+		if body, err = json.Marshal(object); err != nil {
+			return err
+		}
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return nil
+}
+
+// writeEnvWithout runs job and writes its output, a json object, without
+// the fields.
+func writeEnvWithout(job *engine.Job, w http.ResponseWriter, fields []string) error {
+	var buf bytes.Buffer
+	job.Stdout.Add(&buf)
+	if err := job.Run(); err != nil {
+		return err
+	}
+	env := &engine.Env{}
+	if err := env.Decode(&buf); err != nil {
+		return err
+	}
+	env = withoutFields(env, fields)
+	w.Header().Set("Content-Type", "application/json")
+	return env.Encode(w)
+}
+
+// withoutFields returns env without the fields, the nested ones named like
+// State.Health.
+func withoutFields(env *engine.Env, fields []string) *engine.Env {
+	var (
+		dropped = make(map[string]bool)
+		nested  = make(map[string][]string)
+	)
+	for _, field := range fields {
+		if parts := strings.SplitN(field, ".", 2); len(parts) == 2 {
+			nested[parts[0]] = append(nested[parts[0]], parts[1])
+		} else {
+			dropped[field] = true
+		}
+	}
+	result := &engine.Env{}
+	for _, kv := range *env {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if !dropped[key] {
+			*result = append(*result, kv)
+		}
+	}
+	for key, subFields := range nested {
+		if sub := result.GetSubEnv(key); sub != nil {
+			result.SetSubEnv(key, withoutFields(sub, subFields))
+		}
+	}
+	return result
+}
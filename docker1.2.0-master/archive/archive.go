@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
 
@@ -32,6 +33,15 @@ type (
 		Excludes    []string
 		Compression Compression
 		NoLchown    bool
+		// ChownOpts, if set, gives the owner of all the extracted files
+		ChownOpts *TarChownOptions
+		// Extracted, if set, is called with the name of each file once
+		// Untar has written it, so that it can be used before the end of
+		// the archive
+		Extracted func(name string)
+	}
+	TarChownOptions struct {
+		UID, GID int
 	}
 )
 
@@ -119,6 +129,90 @@ func CompressStream(dest io.WriteCloser, compression Compression) (io.WriteClose
 	}
 }
 
+// CompressStreamLevel is CompressStream with the level of the compression,
+// 0 being the default level of the compression.
+func CompressStreamLevel(dest io.WriteCloser, compression Compression, level int) (io.WriteCloser, error) {
+	if compression == Gzip && level != 0 {
+		return gzip.NewWriterLevel(dest, level)
+	}
+	return CompressStream(dest, compression)
+}
+
+// Compress returns the data of source compressed with compression at
+// level, 0 being the default level of the compression.
+func Compress(source Archive, compression Compression, level int) (Archive, error) {
+	if compression == Uncompressed {
+		return source, nil
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	compressWriter, err := CompressStreamLevel(pipeWriter, compression, level)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_, err := io.Copy(compressWriter, source)
+		if cerr := compressWriter.Close(); err == nil {
+			err = cerr
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	return utils.NewReadCloserWrapper(pipeReader, func() error {
+		pipeReader.Close()
+		return source.Close()
+	}), nil
+}
+
+// PinTimestamps returns the uncompressed archive source with the
+// modification time of all its entries set to timestamp, so that archiving
+// the same files always gives the same data.
+func PinTimestamps(source ArchiveReader, timestamp time.Time) (Archive, error) {
+	decompressed, err := DecompressStream(source)
+	if err != nil {
+		return nil, err
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		tr := tar.NewReader(decompressed)
+		tw := tar.NewWriter(pipeWriter)
+		var err error
+		for {
+			var hdr *tar.Header
+			if hdr, err = tr.Next(); err != nil {
+				if err == io.EOF {
+					err = tw.Close()
+				}
+				break
+			}
+			hdr.ModTime = timestamp
+			hdr.AccessTime = time.Time{}
+			hdr.ChangeTime = time.Time{}
+			if err = tw.WriteHeader(hdr); err != nil {
+				break
+			}
+			if _, err = io.Copy(tw, tr); err != nil {
+				break
+			}
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	return utils.NewReadCloserWrapper(pipeReader, func() error {
+		pipeReader.Close()
+		return decompressed.Close()
+	}), nil
+}
+
+// ParseCompression returns the compression named name, which docker can
+// compress layers with: "gzip" or "none".
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "gzip":
+		return Gzip, nil
+	case "none":
+		return Uncompressed, nil
+	}
+	return Uncompressed, fmt.Errorf("Unsupported layer compression %s, use gzip or none", name)
+}
+
 func (compression *Compression) Extension() string {
 	switch *compression {
 	case Uncompressed:
@@ -465,9 +559,16 @@ loop:
 			}
 		}
 		trBuf.Reset(tr)
+		if options.ChownOpts != nil {
+			hdr.Uid = options.ChownOpts.UID
+			hdr.Gid = options.ChownOpts.GID
+		}
 		if err := createTarFile(path, dest, hdr, trBuf, !options.NoLchown); err != nil {
 			return err
 		}
+		if options.Extracted != nil {
+			options.Extracted(hdr.Name)
+		}
 
 		// Directory mtimes must be handled at the end to avoid further
 		// file creation in them to modify the directory mtime
@@ -503,12 +604,17 @@ func TarUntar(src string, dst string) error {
 // UntarPath is a convenience function which looks for an archive
 // at filesystem path `src`, and unpacks it at `dst`.
 func UntarPath(src, dst string) error {
+	return UntarPathWithOptions(src, dst, nil)
+}
+
+// UntarPathWithOptions is like UntarPath, unpacking with `options`.
+func UntarPathWithOptions(src, dst string, options *TarOptions) error {
 	archive, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer archive.Close()
-	if err := Untar(archive, dst, nil); err != nil {
+	if err := Untar(archive, dst, options); err != nil {
 		return err
 	}
 	return nil
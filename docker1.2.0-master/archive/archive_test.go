@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"syscall"
 	"testing"
 	"time"
 
@@ -132,6 +133,182 @@ func TestTarUntar(t *testing.T) {
 	}
 }
 
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte("layer data "), 1024)
+	for _, c := range []struct {
+		compression Compression
+		level       int
+	}{
+		{Uncompressed, 0},
+		{Gzip, 0},
+		{Gzip, 1},
+		{Gzip, 9},
+	} {
+		compressed, err := Compress(ioutil.NopCloser(bytes.NewReader(data)), c.compression, c.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ioutil.ReadAll(compressed)
+		compressed.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detected := DetectCompression(buf); detected != c.compression {
+			t.Fatalf("Expected compression %s, detected %s", c.compression.Extension(), detected.Extension())
+		}
+		decompressed, err := DecompressStream(bytes.NewReader(buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, err := ioutil.ReadAll(decompressed); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(result, data) {
+			t.Fatalf("Unexpected data after compression %s at level %d", c.compression.Extension(), c.level)
+		}
+	}
+
+	if _, err := Compress(ioutil.NopCloser(bytes.NewReader(data)), Gzip, 42); err == nil {
+		t.Fatal("Expected an error compressing at an invalid level")
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	for name, expected := range map[string]Compression{"gzip": Gzip, "none": Uncompressed} {
+		if c, err := ParseCompression(name); err != nil {
+			t.Fatal(err)
+		} else if c != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected.Extension(), c.Extension())
+		}
+	}
+	for _, name := range []string{"", "xz", "bzip2", "zip"} {
+		if _, err := ParseCompression(name); err == nil {
+			t.Fatalf("Expected an error parsing compression %q", name)
+		}
+	}
+}
+
+func TestPinTimestamps(t *testing.T) {
+	origin, err := ioutil.TempDir("", "docker-test-pin-timestamps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(origin)
+	file := path.Join(origin, "1")
+	if err := ioutil.WriteFile(file, []byte("hello world"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Unix(0, 0)
+
+	var pinned [][]byte
+	for _, mtime := range []time.Time{time.Unix(1000, 0), time.Unix(2000, 0)} {
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		layer, err := Tar(origin, Gzip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := PinTimestamps(layer, timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ioutil.ReadAll(a)
+		a.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pinned = append(pinned, buf)
+	}
+	if !bytes.Equal(pinned[0], pinned[1]) {
+		t.Fatal("Expected the same archive with pinned timestamps")
+	}
+
+	tr := tar.NewReader(bytes.NewReader(pinned[0]))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !hdr.ModTime.Equal(timestamp) {
+			t.Fatalf("Expected %s to be modified at %s, got %s", hdr.Name, timestamp, hdr.ModTime)
+		}
+	}
+}
+
+func TestUntarChown(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Changing the owner of the files requires root")
+	}
+	origin, err := ioutil.TempDir("", "docker-test-untar-chown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(origin)
+	if err := os.MkdirAll(path.Join(origin, "src", "dir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(origin, "src", "dir", "1"), []byte("hello world"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := Tar(path.Join(origin, "src"), Uncompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer layer.Close()
+	dest := path.Join(origin, "dest")
+	if err := Untar(layer, dest, &TarOptions{ChownOpts: &TarChownOptions{UID: 1000, GID: 1001}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir", "dir/1"} {
+		fi, err := os.Lstat(path.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := fi.Sys().(*syscall.Stat_t)
+		if st.Uid != 1000 || st.Gid != 1001 {
+			t.Fatalf("Expected %s to be owned by 1000:1001, got %d:%d", name, st.Uid, st.Gid)
+		}
+	}
+}
+
+func TestUntarExtracted(t *testing.T) {
+	origin, err := ioutil.TempDir("", "docker-test-untar-extracted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(origin)
+	if err := os.MkdirAll(path.Join(origin, "src", "dir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(origin, "src", "dir", "1"), []byte("hello world"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := Tar(path.Join(origin, "src"), Uncompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer layer.Close()
+	var (
+		dest      = path.Join(origin, "dest")
+		extracted []string
+	)
+	options := &TarOptions{Extracted: func(name string) {
+		// The file is there as soon as it is reported
+		if _, err := os.Lstat(path.Join(dest, name)); err != nil {
+			t.Fatal(err)
+		}
+		extracted = append(extracted, name)
+	}}
+	if err := Untar(layer, dest, options); err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted) != 2 || extracted[0] != "dir" || extracted[1] != "dir/1" {
+		t.Fatalf("Expected dir and dir/1 to be extracted, got %v", extracted)
+	}
+}
+
 func TestTarWithOptions(t *testing.T) {
 	origin, err := ioutil.TempDir("", "docker-test-untar-origin")
 	if err != nil {
@@ -10,7 +10,6 @@ import (
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/events"
 	"github.com/docker/docker/pkg/parsers/kernel"
-	"github.com/docker/docker/registry"
 )
 
 //向 eng 对象注册特定的处理 方法。
@@ -31,12 +30,8 @@ func Register(eng *engine.Engine) error {
 		return err
 	}
 	//注册版本处理方法
-	if err := eng.Register("version", dockerVersion); err != nil {
-		return err
-	}
-
-	//在 eng 对象对外暴露的 API 信息、中添加 docker registry 的信息
-	return registry.NewService().Install(eng)
+	//docker registry 的处理方法依赖 daemon 的配置（--insecure-registry），由调用者注册
+	return eng.Register("version", dockerVersion)
 }
 
 // remote: a RESTful api for cross-docker communication
@@ -76,6 +71,7 @@ func dockerVersion(job *engine.Job) engine.Status {
 	v := &engine.Env{}
 	v.SetJson("Version", dockerversion.VERSION)
 	v.SetJson("ApiVersion", api.APIVERSION)
+	v.SetJson("MinAPIVersion", api.MINAPIVERSION)
 	v.Set("GitCommit", dockerversion.GITCOMMIT)
 	v.Set("GoVersion", runtime.Version())
 	v.Set("Os", runtime.GOOS)
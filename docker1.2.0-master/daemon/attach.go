@@ -5,11 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
 
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/jsonlog"
-	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/utils"
 )
 
@@ -33,29 +31,34 @@ func (daemon *Daemon) ContainerAttach(job *engine.Job) engine.Status {
 	}
 
 	//logs
-	if logs {
-		cLog, err := container.ReadLog("json")
+	if logs && !container.readLogsSupported() {
+		daemonLog.Debugf("Skipping logs replay of %s: not supported by its logging driver", container.ID)
+	} else if logs {
+		cLog, err := container.ReadJSONLogs()
+		if err == nil {
+			defer cLog.Close()
+		}
 		if err != nil && os.IsNotExist(err) {
 			// Legacy logs
-			log.Debugf("Old logs format")
+			daemonLog.Debugf("Old logs format")
 			if stdout {
 				cLog, err := container.ReadLog("stdout")
 				if err != nil {
-					log.Errorf("Error reading logs (stdout): %s", err)
+					daemonLog.Errorf("Error reading logs (stdout): %s", err)
 				} else if _, err := io.Copy(job.Stdout, cLog); err != nil {
-					log.Errorf("Error streaming logs (stdout): %s", err)
+					daemonLog.Errorf("Error streaming logs (stdout): %s", err)
 				}
 			}
 			if stderr {
 				cLog, err := container.ReadLog("stderr")
 				if err != nil {
-					log.Errorf("Error reading logs (stderr): %s", err)
+					daemonLog.Errorf("Error reading logs (stderr): %s", err)
 				} else if _, err := io.Copy(job.Stderr, cLog); err != nil {
-					log.Errorf("Error streaming logs (stderr): %s", err)
+					daemonLog.Errorf("Error streaming logs (stderr): %s", err)
 				}
 			}
 		} else if err != nil {
-			log.Errorf("Error reading logs (json): %s", err)
+			daemonLog.Errorf("Error reading logs (json): %s", err)
 		} else {
 			dec := json.NewDecoder(cLog)
 			for {
@@ -64,7 +67,7 @@ func (daemon *Daemon) ContainerAttach(job *engine.Job) engine.Status {
 				if err := dec.Decode(l); err == io.EOF {
 					break
 				} else if err != nil {
-					log.Errorf("Error streaming logs: %s", err)
+					daemonLog.Errorf("Error streaming logs: %s", err)
 					break
 				}
 				if l.Stream == "stdout" && stdout {
@@ -89,7 +92,7 @@ func (daemon *Daemon) ContainerAttach(job *engine.Job) engine.Status {
 			r, w := io.Pipe()
 			go func() {
 				defer w.Close()
-				defer log.Debugf("Closing buffered stdin pipe")
+				defer daemonLog.Debugf("Closing buffered stdin pipe")
 				io.Copy(w, job.Stdin)
 			}()
 			cStdin = r
@@ -102,12 +105,18 @@ func (daemon *Daemon) ContainerAttach(job *engine.Job) engine.Status {
 			cStderr = job.Stderr
 		}
 
-		<-daemon.Attach(container, cStdin, cStdinCloser, cStdout, cStderr)
+		// The streams are left to end on their own when canceled, once the
+		// connection of the client is closed
+		select {
+		case <-daemon.Attach(container, cStdin, cStdinCloser, cStdout, cStderr):
+		case <-job.Canceled():
+			return job.Errorf("Attaching to %s canceled", name)
+		}
 
 		// If we are in stdinonce mode, wait for the process to end
 		// otherwise, simply return
 		if container.Config.StdinOnce && !container.Config.Tty {
-			container.State.WaitStop(-1 * time.Second)
+			container.State.WaitStopOrCancel(job.Canceled())
 		}
 	}
 	return engine.StatusOK
@@ -132,8 +141,8 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 			errors <- err
 		} else {
 			go func() {
-				log.Debugf("attach: stdin: begin")
-				defer log.Debugf("attach: stdin: end")
+				daemonLog.Debugf("attach: stdin: begin")
+				defer daemonLog.Debugf("attach: stdin: end")
 				// No matter what, when stdin is closed (io.Copy unblock), close stdout and stderr
 				if container.Config.StdinOnce && !container.Config.Tty {
 					defer cStdin.Close()
@@ -156,7 +165,7 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 					err = nil
 				}
 				if err != nil {
-					log.Errorf("attach: stdin: %s", err)
+					daemonLog.Errorf("attach: stdin: %s", err)
 				}
 				errors <- err
 			}()
@@ -169,8 +178,8 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 		} else {
 			cStdout = p
 			go func() {
-				log.Debugf("attach: stdout: begin")
-				defer log.Debugf("attach: stdout: end")
+				daemonLog.Debugf("attach: stdout: begin")
+				defer daemonLog.Debugf("attach: stdout: end")
 				// If we are in StdinOnce mode, then close stdin
 				if container.Config.StdinOnce && stdin != nil {
 					defer stdin.Close()
@@ -183,7 +192,7 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 					err = nil
 				}
 				if err != nil {
-					log.Errorf("attach: stdout: %s", err)
+					daemonLog.Errorf("attach: stdout: %s", err)
 				}
 				errors <- err
 			}()
@@ -194,7 +203,7 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 				defer stdinCloser.Close()
 			}
 			if cStdout, err := container.StdoutPipe(); err != nil {
-				log.Errorf("attach: stdout pipe: %s", err)
+				daemonLog.Errorf("attach: stdout pipe: %s", err)
 			} else {
 				io.Copy(&utils.NopWriter{}, cStdout)
 			}
@@ -207,8 +216,8 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 		} else {
 			cStderr = p
 			go func() {
-				log.Debugf("attach: stderr: begin")
-				defer log.Debugf("attach: stderr: end")
+				daemonLog.Debugf("attach: stderr: begin")
+				defer daemonLog.Debugf("attach: stderr: end")
 				// If we are in StdinOnce mode, then close stdin
 				if container.Config.StdinOnce && stdin != nil {
 					defer stdin.Close()
@@ -221,7 +230,7 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 					err = nil
 				}
 				if err != nil {
-					log.Errorf("attach: stderr: %s", err)
+					daemonLog.Errorf("attach: stderr: %s", err)
 				}
 				errors <- err
 			}()
@@ -233,7 +242,7 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 			}
 
 			if cStderr, err := container.StderrPipe(); err != nil {
-				log.Errorf("attach: stdout pipe: %s", err)
+				daemonLog.Errorf("attach: stdout pipe: %s", err)
 			} else {
 				io.Copy(&utils.NopWriter{}, cStderr)
 			}
@@ -253,14 +262,14 @@ func (daemon *Daemon) Attach(container *Container, stdin io.ReadCloser, stdinClo
 		// FIXME: how to clean up the stdin goroutine without the unwanted side effect
 		// of closing the passed stdin? Add an intermediary io.Pipe?
 		for i := 0; i < nJobs; i += 1 {
-			log.Debugf("attach: waiting for job %d/%d", i+1, nJobs)
+			daemonLog.Debugf("attach: waiting for job %d/%d", i+1, nJobs)
 			if err := <-errors; err != nil {
-				log.Errorf("attach: job %d returned error %s, aborting all jobs", i+1, err)
+				daemonLog.Errorf("attach: job %d returned error %s, aborting all jobs", i+1, err)
 				return err
 			}
-			log.Debugf("attach: job %d completed successfully", i+1)
+			daemonLog.Debugf("attach: job %d completed successfully", i+1)
 		}
-		log.Debugf("attach: all jobs completed successfully")
+		daemonLog.Debugf("attach: all jobs completed successfully")
 		return nil
 	})
 }
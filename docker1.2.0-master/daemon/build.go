@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"bufio"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -10,12 +11,12 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -23,7 +24,6 @@ import (
 	"github.com/docker/docker/archive"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/nat"
-	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/pkg/system"
@@ -44,29 +44,42 @@ func (daemon *Daemon) CmdBuild(job *engine.Job) engine.Status {
 		noCache        = job.GetenvBool("nocache")
 		rm             = job.GetenvBool("rm")
 		forceRm        = job.GetenvBool("forcerm")
+		squash         = job.GetenvBool("squash")
 		authConfig     = &registry.AuthConfig{}
 		configFile     = &registry.ConfigFile{}
+		buildArgs      = make(map[string]string)
+		secrets        = make(map[string][]byte)
 		tag            string
 		context        io.ReadCloser
 	)
 	job.GetenvJson("authConfig", authConfig)
 	job.GetenvJson("configFile", configFile)
+	if err := job.GetenvJson("buildargs", &buildArgs); err != nil {
+		return job.Errorf("Invalid build args: %s", err)
+	}
+	if err := job.GetenvJson("secrets", &secrets); err != nil {
+		return job.Errorf("Invalid build secrets: %s", err)
+	}
+	if err := validateSecrets(secrets); err != nil {
+		return job.Error(err)
+	}
 	repoName, tag = parsers.ParseRepositoryTag(repoName)
+	timestamp, err := parseTimestamp(job.Getenv("timestamp"))
+	if err != nil {
+		return job.Error(err)
+	}
 
 	if remoteURL == "" {
 		context = ioutil.NopCloser(job.Stdin)
 	} else if utils.IsGIT(remoteURL) {
-		if !strings.HasPrefix(remoteURL, "git://") {
-			remoteURL = "https://" + remoteURL
-		}
 		root, err := ioutil.TempDir("", "docker-build-git")
 		if err != nil {
 			return job.Error(err)
 		}
 		defer os.RemoveAll(root)
 
-		if output, err := exec.Command("git", "clone", "--recursive", remoteURL, root).CombinedOutput(); err != nil {
-			return job.Errorf("Error trying to use git: %s (%s)", err, output)
+		if err := utils.GitClone(remoteURL, job.Getenv("gitref"), job.GetenvInt("gitdepth"), root); err != nil {
+			return job.Error(err)
 		}
 
 		c, err := archive.Tar(root, archive.Uncompressed)
@@ -80,20 +93,32 @@ func (daemon *Daemon) CmdBuild(job *engine.Job) engine.Status {
 			return job.Error(err)
 		}
 		defer f.Body.Close()
-		dockerFile, err := ioutil.ReadAll(f.Body)
-		if err != nil {
+		// The URL is either a whole context, as an archive, or a Dockerfile
+		body := bufio.NewReader(f.Body)
+		magic, err := body.Peek(tarHeaderSize)
+		if err != nil && err != io.EOF {
 			return job.Error(err)
 		}
-		c, err := archive.Generate("Dockerfile", string(dockerFile))
-		if err != nil {
-			return job.Error(err)
+		if archive.IsArchive(magic) {
+			context = ioutil.NopCloser(body)
+		} else {
+			dockerFile, err := ioutil.ReadAll(body)
+			if err != nil {
+				return job.Error(err)
+			}
+			c, err := archive.Generate("Dockerfile", string(dockerFile))
+			if err != nil {
+				return job.Error(err)
+			}
+			context = c
 		}
-		context = c
 	}
 	defer context.Close()
 
 	sf := utils.NewStreamFormatter(job.GetenvBool("json"))
-	b := NewBuildFile(daemon, daemon.eng,
+	// The jobs of the build, such as the pull of its base image, are
+	// canceled with its request
+	b := NewBuildFile(daemon, job.Eng,
 		&utils.StdoutFormater{
 			Writer:          job.Stdout,
 			StreamFormatter: sf,
@@ -102,7 +127,13 @@ func (daemon *Daemon) CmdBuild(job *engine.Job) engine.Status {
 			Writer:          job.Stdout,
 			StreamFormatter: sf,
 		},
-		!suppressOutput, !noCache, rm, forceRm, job.Stdout, sf, authConfig, configFile)
+		!suppressOutput, !noCache, rm, forceRm, squash, timestamp, buildArgs, secrets, job.GetenvBool("steps"), job.GetenvBool("ignoremtime"), job.Stdout, sf, authConfig, configFile)
+	if job.GetenvBool("dryrun") {
+		if err := b.Check(context); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	}
 	id, err := b.Build(context)
 	if err != nil {
 		return job.Error(err)
@@ -117,8 +148,11 @@ var (
 	ErrDockerfileEmpty = errors.New("Dockerfile cannot be empty")
 )
 
+const tarHeaderSize = 512
+
 type BuildFile interface {
 	Build(io.Reader) (string, error)
+	Check(io.Reader) error
 	CmdFrom(string) error
 	CmdRun(string) error
 }
@@ -128,16 +162,41 @@ type buildFile struct {
 	eng    *engine.Engine
 
 	image      string
+	from       string // image of the last FROM
 	maintainer string
 	config     *runconfig.Config
 
 	contextPath string
 	context     *tarsum.TarSum
+	// contextDone is closed once the context is extracted, with contextErr
+	contextDone chan struct{}
+	contextErr  error
 
 	verbose      bool
 	utilizeCache bool
 	rm           bool
 	forceRm      bool
+	squash       bool
+	timestamp    time.Time // pinned creation date of the images, if not zero
+
+	// buildArgs are the values given to the build, args the KEY=value of
+	// the variables declared with ARG, in order
+	buildArgs map[string]string
+	args      []string
+
+	// secrets are mounted from secretsDir in the containers of RUN
+	secrets    map[string][]byte
+	secretsDir string
+
+	steps  bool // send the progress of the steps in the JSON stream
+	cached bool // the current step used the cache
+
+	// ignoreMtime leaves the modification times of the files of the
+	// context out of the cache of ADD and COPY
+	ignoreMtime bool
+
+	// dryRun only parses and validates the instructions, see Check
+	dryRun bool
 
 	authConfig *registry.AuthConfig
 	configFile *registry.ConfigFile
@@ -169,6 +228,9 @@ func (b *buildFile) clearTmp(containers map[string]struct{}) {
 }
 
 func (b *buildFile) CmdFrom(name string) error {
+	if b.dryRun {
+		return b.checkFrom(name)
+	}
 	image, err := b.daemon.Repositories().LookupImage(name)
 	if err != nil {
 		if b.daemon.Graph().IsNotExist(err) {
@@ -200,6 +262,7 @@ func (b *buildFile) CmdFrom(name string) error {
 		}
 	}
 	b.image = image.ID
+	b.from = image.ID
 	b.config = &runconfig.Config{}
 	if image.Config != nil {
 		b.config = image.Config
@@ -256,18 +319,20 @@ func (b *buildFile) CmdMaintainer(name string) error {
 // and if so attempts to look up the current `b.image` and `b.config` pair
 // in the current server `b.daemon`. If an image is found, probeCache returns
 // `(true, nil)`. If no image is found, it returns `(false, nil)`. If there
-// is any error, it returns `(false, err)`.
+// is any error, it returns `(false, err)`. With a pinned timestamp, only the
+// images created at that time are reused.
 func (b *buildFile) probeCache() (bool, error) {
 	if b.utilizeCache {
 		if cache, err := b.daemon.ImageGetCached(b.image, b.config); err != nil {
 			return false, err
-		} else if cache != nil {
+		} else if cache != nil && (b.timestamp.IsZero() || cache.Created.Equal(b.timestamp)) {
 			fmt.Fprintf(b.outStream, " ---> Using cache\n")
-			log.Debugf("[BUILDER] Use cached version")
+			daemonLog.Debugf("[BUILDER] Use cached version")
 			b.image = cache.ID
+			b.cached = true
 			return true, nil
 		} else {
-			log.Debugf("[BUILDER] Cache miss")
+			daemonLog.Debugf("[BUILDER] Cache miss")
 		}
 	}
 	return false, nil
@@ -281,6 +346,9 @@ func (b *buildFile) CmdRun(args string) error {
 	if err != nil {
 		return err
 	}
+	if b.dryRun {
+		return nil
+	}
 
 	cmd := b.config.Cmd
 	// set Cmd manually, this is special case only for Dockerfiles
@@ -289,7 +357,13 @@ func (b *buildFile) CmdRun(args string) error {
 
 	defer func(cmd []string) { b.config.Cmd = cmd }(cmd)
 
-	log.Debugf("Command to be executed: %v", b.config.Cmd)
+	// The build args are in the environment of the command, and in the
+	// config of its container for the cache, but not in the image config
+	env := b.config.Env
+	b.config.Env = append(append([]string{}, env...), b.argsEnv()...)
+	defer func() { b.config.Env = env }()
+
+	daemonLog.Debugf("Command to be executed: %v", b.config.Cmd)
 
 	hit, err := b.probeCache()
 	if err != nil {
@@ -303,6 +377,7 @@ func (b *buildFile) CmdRun(args string) error {
 	if err != nil {
 		return err
 	}
+	b.bindSecrets(c)
 	// Ensure that we keep the container mounted until the commit
 	// to avoid unmounting and then mounting directly again
 	c.Mount()
@@ -312,6 +387,7 @@ func (b *buildFile) CmdRun(args string) error {
 	if err != nil {
 		return err
 	}
+	b.config.Env = env
 	if err := b.commit(c.ID, cmd, "run"); err != nil {
 		return err
 	}
@@ -339,7 +415,7 @@ func (b *buildFile) ReplaceEnvMatches(value string) (string, error) {
 		match = match[strings.Index(match, "$"):]
 		matchKey := strings.Trim(match, "${}")
 
-		for _, envVar := range b.config.Env {
+		for _, envVar := range append(b.config.Env, b.argsEnv()...) {
 			envParts := strings.SplitN(envVar, "=", 2)
 			envKey := envParts[0]
 			envValue := envParts[1]
@@ -376,10 +452,63 @@ func (b *buildFile) CmdEnv(args string) error {
 	return b.commit("", b.config.Cmd, fmt.Sprintf("ENV %s", replacedVar))
 }
 
+// CmdArg declares a variable NAME[=default] set with --build-arg. It is
+// available to the following instructions, like ENV, but not stored in
+// the image.
+func (b *buildFile) CmdArg(args string) error {
+	tmp := strings.SplitN(args, "=", 2)
+	key := strings.Trim(tmp[0], " \t")
+	if key == "" || strings.ContainsAny(key, " \t") {
+		return fmt.Errorf("Invalid ARG format")
+	}
+	value, exists := b.buildArgs[key]
+	if !exists {
+		if len(tmp) != 2 {
+			// Neither given nor defaulted, the variable stays unset
+			return nil
+		}
+		replacedValue, err := b.ReplaceEnvMatches(strings.Trim(tmp[1], " \t"))
+		if err != nil {
+			return err
+		}
+		value = replacedValue
+	}
+	replacedVar := fmt.Sprintf("%s=%s", key, value)
+	for i, arg := range b.args {
+		if strings.SplitN(arg, "=", 2)[0] == key {
+			b.args[i] = replacedVar
+			return nil
+		}
+	}
+	b.args = append(b.args, replacedVar)
+	return nil
+}
+
+// argsEnv returns the variables declared with ARG which ENV doesn't
+// override.
+func (b *buildFile) argsEnv() []string {
+	var env []string
+	for _, arg := range b.args {
+		if b.FindEnvKey(strings.SplitN(arg, "=", 2)[0]) < 0 {
+			env = append(env, arg)
+		}
+	}
+	return env
+}
+
+func (b *buildFile) argDeclared(key string) bool {
+	for _, arg := range b.args {
+		if strings.SplitN(arg, "=", 2)[0] == key {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *buildFile) buildCmdFromJson(args string) []string {
 	var cmd []string
 	if err := json.Unmarshal([]byte(args), &cmd); err != nil {
-		log.Debugf("Error unmarshalling: %s, setting to /bin/sh -c", err)
+		daemonLog.Debugf("Error unmarshalling: %s, setting to /bin/sh -c", err)
 		cmd = []string{"/bin/sh", "-c", args}
 	}
 	return cmd
@@ -408,6 +537,55 @@ func (b *buildFile) CmdEntrypoint(args string) error {
 	return nil
 }
 
+// CmdHealthcheck sets the command checking the health of the containers of
+// the image, HEALTHCHECK [--interval=d] [--timeout=d] [--retries=n] CMD command,
+// or disables the check of the base image with HEALTHCHECK NONE.
+func (b *buildFile) CmdHealthcheck(args string) error {
+	if strings.ToUpper(args) == "NONE" {
+		b.config.Healthcheck = &runconfig.HealthConfig{Test: []string{"NONE"}}
+		return b.commit("", b.config.Cmd, "HEALTHCHECK NONE")
+	}
+	check := &runconfig.HealthConfig{}
+	for strings.HasPrefix(args, "--") {
+		tmp := strings.SplitN(args, " ", 2)
+		if len(tmp) != 2 {
+			return fmt.Errorf("Invalid HEALTHCHECK format")
+		}
+		opt := strings.SplitN(strings.TrimPrefix(tmp[0], "--"), "=", 2)
+		if len(opt) != 2 {
+			return fmt.Errorf("Invalid HEALTHCHECK option: %s", tmp[0])
+		}
+		switch opt[0] {
+		case "interval", "timeout":
+			d, err := time.ParseDuration(opt[1])
+			if err != nil || d <= 0 {
+				return fmt.Errorf("Invalid HEALTHCHECK %s: %s", opt[0], opt[1])
+			}
+			if opt[0] == "interval" {
+				check.Interval = d
+			} else {
+				check.Timeout = d
+			}
+		case "retries":
+			n, err := strconv.Atoi(opt[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("Invalid HEALTHCHECK retries: %s", opt[1])
+			}
+			check.Retries = n
+		default:
+			return fmt.Errorf("Unknown HEALTHCHECK option: %s", tmp[0])
+		}
+		args = strings.TrimLeft(tmp[1], " \t")
+	}
+	tmp := strings.SplitN(args, " ", 2)
+	if len(tmp) != 2 || strings.ToUpper(tmp[0]) != "CMD" {
+		return fmt.Errorf("HEALTHCHECK requires NONE or a CMD")
+	}
+	check.Test = b.buildCmdFromJson(strings.Trim(tmp[1], " \t"))
+	b.config.Healthcheck = check
+	return b.commit("", b.config.Cmd, fmt.Sprintf("HEALTHCHECK %v", check.Test))
+}
+
 func (b *buildFile) CmdExpose(args string) error {
 	portsTab := strings.Split(args, " ")
 
@@ -497,13 +675,19 @@ func (b *buildFile) checkPathForAddition(orig string) error {
 	return nil
 }
 
-func (b *buildFile) addContext(container *Container, orig, dest string, decompress bool) error {
+// addContext copies orig to dest in the container, the files being owned by
+// chown, or by root when chown is nil.
+func (b *buildFile) addContext(container *Container, orig, dest string, decompress bool, chown *archive.TarChownOptions) error {
 	var (
 		err        error
 		destExists = true
 		origPath   = path.Join(b.contextPath, orig)
 		destPath   = path.Join(container.RootfsPath(), dest)
+		uid, gid   int
 	)
+	if chown != nil {
+		uid, gid = chown.UID, chown.GID
+	}
 
 	if destPath != container.RootfsPath() {
 		destPath, err = symlink.FollowSymlinkInScope(destPath, container.RootfsPath())
@@ -534,7 +718,7 @@ func (b *buildFile) addContext(container *Container, orig, dest string, decompre
 	}
 
 	if fi.IsDir() {
-		return copyAsDirectory(origPath, destPath, destExists)
+		return copyAsDirectory(origPath, destPath, destExists, uid, gid)
 	}
 
 	// If we are adding a remote file (or we've been told not to decompress), do not try to untar it
@@ -549,10 +733,10 @@ func (b *buildFile) addContext(container *Container, orig, dest string, decompre
 		}
 
 		// try to successfully untar the orig
-		if err := archive.UntarPath(origPath, tarDest); err == nil {
+		if err := archive.UntarPathWithOptions(origPath, tarDest, &archive.TarOptions{ChownOpts: chown}); err == nil {
 			return nil
 		} else if err != io.EOF {
-			log.Debugf("Couldn't untar %s to %s: %s", origPath, tarDest, err)
+			daemonLog.Debugf("Couldn't untar %s to %s: %s", origPath, tarDest, err)
 		}
 	}
 
@@ -568,13 +752,35 @@ func (b *buildFile) addContext(container *Container, orig, dest string, decompre
 		resPath = path.Join(destPath, path.Base(origPath))
 	}
 
-	return fixPermissions(resPath, 0, 0)
+	return fixPermissions(resPath, uid, gid)
 }
 
 func (b *buildFile) runContextCommand(args string, allowRemote bool, allowDecompression bool, cmdName string) error {
 	if b.context == nil {
 		return fmt.Errorf("No context given. Impossible to use %s", cmdName)
 	}
+	if err := b.waitContext(); err != nil {
+		return err
+	}
+	var (
+		chown     *archive.TarChownOptions
+		chownFlag string
+	)
+	if strings.HasPrefix(args, "--chown=") {
+		tmp := strings.SplitN(args, " ", 2)
+		if len(tmp) != 2 {
+			return fmt.Errorf("Invalid %s format", cmdName)
+		}
+		spec, err := b.ReplaceEnvMatches(strings.TrimPrefix(tmp[0], "--chown="))
+		if err != nil {
+			return err
+		}
+		if chown, err = parseChown(spec); err != nil {
+			return err
+		}
+		chownFlag = fmt.Sprintf("--chown=%d:%d ", chown.UID, chown.GID)
+		args = strings.TrimLeft(tmp[1], " \t")
+	}
 	tmp := strings.SplitN(args, " ", 2)
 	if len(tmp) != 2 {
 		return fmt.Errorf("Invalid %s format", cmdName)
@@ -591,7 +797,7 @@ func (b *buildFile) runContextCommand(args string, allowRemote bool, allowDecomp
 	}
 
 	cmd := b.config.Cmd
-	b.config.Cmd = []string{"/bin/sh", "-c", fmt.Sprintf("#(nop) %s %s in %s", cmdName, orig, dest)}
+	b.config.Cmd = []string{"/bin/sh", "-c", fmt.Sprintf("#(nop) %s %s%s in %s", cmdName, chownFlag, orig, dest)}
 	defer func(cmd []string) { b.config.Cmd = cmd }(cmd)
 	b.config.Image = b.image
 
@@ -606,6 +812,9 @@ func (b *buildFile) runContextCommand(args string, allowRemote bool, allowDecomp
 	isRemote = utils.IsURL(orig)
 	if isRemote && !allowRemote {
 		return fmt.Errorf("Source can't be an URL for %s", cmdName)
+	} else if isRemote && b.dryRun {
+		// The remote sources aren't downloaded by a dry run
+		return nil
 	} else if utils.IsURL(orig) {
 		// Initiate the download
 		resp, err := utils.Download(orig)
@@ -675,6 +884,9 @@ func (b *buildFile) runContextCommand(args string, allowRemote bool, allowDecomp
 	if err := b.checkPathForAddition(origPath); err != nil {
 		return err
 	}
+	if b.dryRun {
+		return nil
+	}
 
 	// Hash path and check the cache
 	if b.utilizeCache {
@@ -709,7 +921,7 @@ func (b *buildFile) runContextCommand(args string, allowRemote bool, allowDecomp
 				hash = "file:" + h
 			}
 		}
-		b.config.Cmd = []string{"/bin/sh", "-c", fmt.Sprintf("#(nop) %s %s in %s", cmdName, hash, dest)}
+		b.config.Cmd = []string{"/bin/sh", "-c", fmt.Sprintf("#(nop) %s %s%s in %s", cmdName, chownFlag, hash, dest)}
 		hit, err := b.probeCache()
 		if err != nil {
 			return err
@@ -721,7 +933,7 @@ func (b *buildFile) runContextCommand(args string, allowRemote bool, allowDecomp
 	}
 
 	// Create the container
-	container, _, err := b.daemon.Create(b.config, "")
+	container, _, err := b.daemon.Create(b.daemon.mergeDefaults(b.config), "")
 	if err != nil {
 		return err
 	}
@@ -735,11 +947,11 @@ func (b *buildFile) runContextCommand(args string, allowRemote bool, allowDecomp
 	if !allowDecompression || isRemote {
 		decompress = false
 	}
-	if err := b.addContext(container, origPath, destPath, decompress); err != nil {
+	if err := b.addContext(container, origPath, destPath, decompress, chown); err != nil {
 		return err
 	}
 
-	if err := b.commit(container.ID, cmd, fmt.Sprintf("%s %s in %s", cmdName, orig, dest)); err != nil {
+	if err := b.commit(container.ID, cmd, fmt.Sprintf("%s %s%s in %s", cmdName, chownFlag, orig, dest)); err != nil {
 		return err
 	}
 	return nil
@@ -756,7 +968,7 @@ func (b *buildFile) create() (*Container, error) {
 	b.config.Image = b.image
 
 	// Create the container
-	c, _, err := b.daemon.Create(b.config, "")
+	c, _, err := b.daemon.Create(b.daemon.mergeDefaults(b.config), "")
 	if err != nil {
 		return nil, err
 	}
@@ -794,8 +1006,15 @@ func (b *buildFile) run(c *Container) error {
 		}
 	}
 
-	// Wait for it to finish
-	if ret, _ := c.State.WaitStop(-1 * time.Second); ret != 0 {
+	// Wait for it to finish, killing it if the build is canceled
+	ret, err := c.State.WaitStopOrCancel(b.eng.Canceled())
+	if err != nil {
+		if err := c.Kill(); err != nil {
+			daemonLog.Errorf("Failed to kill the canceled build container %s: %s", c.ID, err)
+		}
+		return err
+	}
+	if ret != 0 {
 		err := &utils.JSONError{
 			Message: fmt.Sprintf("The command %v returned a non-zero code: %d", b.config.Cmd, ret),
 			Code:    ret,
@@ -811,6 +1030,9 @@ func (b *buildFile) commit(id string, autoCmd []string, comment string) error {
 	if b.image == "" {
 		return fmt.Errorf("Please provide a source image with `from` prior to commit")
 	}
+	if b.dryRun {
+		return nil
+	}
 	b.config.Image = b.image
 	if id == "" {
 		cmd := b.config.Cmd
@@ -825,7 +1047,7 @@ func (b *buildFile) commit(id string, autoCmd []string, comment string) error {
 			return nil
 		}
 
-		container, warnings, err := b.daemon.Create(b.config, "")
+		container, warnings, err := b.daemon.Create(b.daemon.mergeDefaults(b.config), "")
 		if err != nil {
 			return err
 		}
@@ -850,7 +1072,7 @@ func (b *buildFile) commit(id string, autoCmd []string, comment string) error {
 	autoConfig := *b.config
 	autoConfig.Cmd = autoCmd
 	// Commit the container
-	image, err := b.daemon.Commit(container, "", "", "", b.maintainer, true, &autoConfig)
+	image, err := b.daemon.Commit(container, "", "", "", b.maintainer, true, false, b.timestamp, &autoConfig)
 	if err != nil {
 		return err
 	}
@@ -862,44 +1084,115 @@ func (b *buildFile) commit(id string, autoCmd []string, comment string) error {
 // Long lines can be split with a backslash
 var lineContinuation = regexp.MustCompile(`\\\s*\n`)
 
-func (b *buildFile) Build(context io.Reader) (string, error) {
+// extractContext untars the context in a temporary directory, which the
+// caller removes after waitContext. The context is streamed: extractContext
+// returns as soon as the Dockerfile is extracted, the rest of the context
+// being extracted while the first instructions run.
+func (b *buildFile) extractContext(context io.Reader) error {
 	tmpdirPath, err := ioutil.TempDir("", "docker-build")
 	if err != nil {
-		return "", err
+		return err
 	}
+	b.contextPath = tmpdirPath
 
 	decompressedStream, err := archive.DecompressStream(context)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	b.context = &tarsum.TarSum{Reader: decompressedStream, DisableCompression: true}
-	if err := archive.Untar(b.context, tmpdirPath, nil); err != nil {
-		return "", err
+	b.context = &tarsum.TarSum{Reader: decompressedStream, DisableCompression: true, IgnoreMtime: b.ignoreMtime}
+	var (
+		dockerfile = make(chan struct{})
+		extracted  bool
+		options    = &archive.TarOptions{
+			Extracted: func(name string) {
+				if name == "Dockerfile" && !extracted {
+					extracted = true
+					close(dockerfile)
+				}
+			},
+		}
+	)
+	b.contextDone = make(chan struct{})
+	go func() {
+		b.contextErr = archive.Untar(b.context, tmpdirPath, options)
+		close(b.contextDone)
+	}()
+	select {
+	case <-dockerfile:
+		return nil
+	case <-b.contextDone:
+		return b.contextErr
 	}
-	defer os.RemoveAll(tmpdirPath)
+}
 
-	b.contextPath = tmpdirPath
-	filename := path.Join(tmpdirPath, "Dockerfile")
+// waitContext waits for the end of the extraction of the context, which the
+// instructions using its files and the sums of the cache need.
+func (b *buildFile) waitContext() error {
+	if b.contextDone == nil {
+		return nil
+	}
+	<-b.contextDone
+	return b.contextErr
+}
+
+// readDockerfile returns the instructions of the Dockerfile of the context,
+// without the comments and the line continuations.
+func (b *buildFile) readDockerfile() ([]string, error) {
+	filename := path.Join(b.contextPath, "Dockerfile")
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return "", fmt.Errorf("Can't build a directory with no Dockerfile")
+		return nil, fmt.Errorf("Can't build a directory with no Dockerfile")
 	}
 	fileBytes, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if len(fileBytes) == 0 {
-		return "", ErrDockerfileEmpty
+		return nil, ErrDockerfileEmpty
 	}
 	var (
 		dockerfile = lineContinuation.ReplaceAllString(stripComments(fileBytes), "")
-		stepN      = 0
+		lines      []string
 	)
 	for _, line := range strings.Split(dockerfile, "\n") {
 		line = strings.Trim(strings.Replace(line, "\t", " ", -1), " \t\r\n")
 		if len(line) == 0 {
 			continue
 		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func (b *buildFile) Build(context io.Reader) (string, error) {
+	err := b.extractContext(context)
+	if b.contextPath != "" {
+		defer os.RemoveAll(b.contextPath)
+	}
+	defer b.waitContext()
+	if err != nil {
+		return "", err
+	}
+
+	if len(b.secrets) > 0 {
+		if err := b.mountSecrets(); err != nil {
+			return "", err
+		}
+		defer b.unmountSecrets()
+	}
+	lines, err := b.readDockerfile()
+	if err != nil {
+		return "", err
+	}
+	for stepN, line := range lines {
+		select {
+		case <-b.eng.Canceled():
+			if b.forceRm {
+				b.clearTmp(b.tmpContainers)
+			}
+			return "", fmt.Errorf("Build canceled before step %d", stepN)
+		default:
+		}
 		if err := b.BuildStep(fmt.Sprintf("%d", stepN), line); err != nil {
 			if b.forceRm {
 				b.clearTmp(b.tmpContainers)
@@ -908,9 +1201,25 @@ func (b *buildFile) Build(context io.Reader) (string, error) {
 		} else if b.rm {
 			b.clearTmp(b.tmpContainers)
 		}
-		stepN += 1
+	}
+	// A truncated or invalid context fails the build, even unused
+	if err := b.waitContext(); err != nil {
+		return "", err
+	}
+	for key := range b.buildArgs {
+		if !b.argDeclared(key) {
+			fmt.Fprintf(b.errStream, "# Build arg %s was not consumed by an ARG instruction\n", key)
+		}
 	}
 	if b.image != "" {
+		if b.squash && b.image != b.from {
+			fmt.Fprintf(b.outStream, "Squashing the layers above %s\n", utils.TruncateID(b.from))
+			img, err := b.daemon.Graph().Squash(b.image, b.from, b.timestamp)
+			if err != nil {
+				return "", err
+			}
+			b.image = img.ID
+		}
 		fmt.Fprintf(b.outStream, "Successfully built %s\n", utils.TruncateID(b.image))
 		return b.image, nil
 	}
@@ -928,21 +1237,43 @@ func (b *buildFile) BuildStep(name, expression string) error {
 	instruction := strings.ToLower(strings.Trim(tmp[0], " "))
 	arguments := strings.Trim(tmp[1], " ")
 
+	step := &utils.JSONBuildStep{Step: name, Instruction: strings.ToUpper(instruction), Status: "started"}
+	b.writeStep(step)
+	start := time.Now()
+
 	method, exists := reflect.TypeOf(b).MethodByName("Cmd" + strings.ToUpper(instruction[:1]) + strings.ToLower(instruction[1:]))
 	if !exists {
 		fmt.Fprintf(b.errStream, "# Skipping unknown instruction %s\n", strings.ToUpper(instruction))
+		step.Status = "skipped"
+		b.writeStep(step)
 		return nil
 	}
 
+	b.cached = false
 	ret := method.Func.Call([]reflect.Value{reflect.ValueOf(b), reflect.ValueOf(arguments)})[0].Interface()
+	step.Duration = time.Since(start).Seconds()
 	if ret != nil {
+		step.Status = "failed"
+		step.Error = ret.(error).Error()
+		b.writeStep(step)
 		return ret.(error)
 	}
 
 	fmt.Fprintf(b.outStream, " ---> %s\n", utils.TruncateID(b.image))
+	step.Status = "done"
+	step.Cached = b.cached
+	step.ID = b.image
+	b.writeStep(step)
 	return nil
 }
 
+// writeStep sends the progress of a step to the client, if it asked for it.
+func (b *buildFile) writeStep(step *utils.JSONBuildStep) {
+	if b.steps {
+		b.outOld.Write(b.sf.FormatBuildStep(step))
+	}
+}
+
 func stripComments(raw []byte) string {
 	var (
 		out   []string
@@ -957,7 +1288,7 @@ func stripComments(raw []byte) string {
 	return strings.Join(out, "\n")
 }
 
-func copyAsDirectory(source, destination string, destinationExists bool) error {
+func copyAsDirectory(source, destination string, destinationExists bool, uid, gid int) error {
 	if err := archive.CopyWithTar(source, destination); err != nil {
 		return err
 	}
@@ -969,14 +1300,31 @@ func copyAsDirectory(source, destination string, destinationExists bool) error {
 		}
 
 		for _, file := range files {
-			if err := fixPermissions(filepath.Join(destination, file.Name()), 0, 0); err != nil {
+			if err := fixPermissions(filepath.Join(destination, file.Name()), uid, gid); err != nil {
 				return err
 			}
 		}
 		return nil
 	}
 
-	return fixPermissions(destination, 0, 0)
+	return fixPermissions(destination, uid, gid)
+}
+
+// parseChown parses the uid[:gid] of the --chown flag of ADD and COPY, the
+// group defaulting to the uid.
+func parseChown(spec string) (*archive.TarChownOptions, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil || uid < 0 {
+		return nil, fmt.Errorf("Invalid --chown uid: %s", spec)
+	}
+	gid := uid
+	if len(parts) == 2 {
+		if gid, err = strconv.Atoi(parts[1]); err != nil || gid < 0 {
+			return nil, fmt.Errorf("Invalid --chown gid: %s", spec)
+		}
+	}
+	return &archive.TarChownOptions{UID: uid, GID: gid}, nil
 }
 
 func fixPermissions(destination string, uid, gid int) error {
@@ -988,7 +1336,7 @@ func fixPermissions(destination string, uid, gid int) error {
 	})
 }
 
-func NewBuildFile(d *Daemon, eng *engine.Engine, outStream, errStream io.Writer, verbose, utilizeCache, rm bool, forceRm bool, outOld io.Writer, sf *utils.StreamFormatter, auth *registry.AuthConfig, authConfigFile *registry.ConfigFile) BuildFile {
+func NewBuildFile(d *Daemon, eng *engine.Engine, outStream, errStream io.Writer, verbose, utilizeCache, rm bool, forceRm, squash bool, timestamp time.Time, buildArgs map[string]string, secrets map[string][]byte, steps, ignoreMtime bool, outOld io.Writer, sf *utils.StreamFormatter, auth *registry.AuthConfig, authConfigFile *registry.ConfigFile) BuildFile {
 	return &buildFile{
 		daemon:        d,
 		eng:           eng,
@@ -1001,6 +1349,12 @@ func NewBuildFile(d *Daemon, eng *engine.Engine, outStream, errStream io.Writer,
 		utilizeCache:  utilizeCache,
 		rm:            rm,
 		forceRm:       forceRm,
+		squash:        squash,
+		timestamp:     timestamp,
+		buildArgs:     buildArgs,
+		secrets:       secrets,
+		steps:         steps,
+		ignoreMtime:   ignoreMtime,
 		sf:            sf,
 		authConfig:    auth,
 		configFile:    authConfigFile,
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestCmdArgDefault(t *testing.T) {
+	b := &buildFile{config: &runconfig.Config{}, buildArgs: map[string]string{}}
+	if err := b.CmdArg("VERSION=1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.CmdArg("UNSET"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"VERSION=1.0"}; !reflect.DeepEqual(b.argsEnv(), expected) {
+		t.Fatalf("Expected the arguments %v, got %v", expected, b.argsEnv())
+	}
+	if !b.argDeclared("VERSION") || b.argDeclared("UNSET") {
+		t.Fatal("Expected only the argument with a value to be declared")
+	}
+	if value, _ := b.ReplaceEnvMatches("app-${VERSION}.tar.gz"); value != "app-1.0.tar.gz" {
+		t.Fatalf("Expected the default value to be used, got %s", value)
+	}
+
+	// A default referencing ENV
+	b.config.Env = []string{"BASE=/srv"}
+	if err := b.CmdArg("APP_DIR=$BASE/app"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := b.ReplaceEnvMatches("$APP_DIR"); value != "/srv/app" {
+		t.Fatalf("Expected /srv/app, got %s", value)
+	}
+
+	for _, invalid := range []string{"", "=1.0", "TWO WORDS=1"} {
+		if err := b.CmdArg(invalid); err == nil {
+			t.Fatalf("Expected an error for ARG %q", invalid)
+		}
+	}
+}
+
+func TestCmdArgBuildArg(t *testing.T) {
+	b := &buildFile{config: &runconfig.Config{}, buildArgs: map[string]string{"VERSION": "2.0", "UNSET": "set"}}
+	if err := b.CmdArg("VERSION=1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.CmdArg("UNSET"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"VERSION=2.0", "UNSET=set"}; !reflect.DeepEqual(b.argsEnv(), expected) {
+		t.Fatalf("Expected the --build-arg values %v, got %v", expected, b.argsEnv())
+	}
+
+	// A second ARG doesn't override --build-arg
+	if err := b.CmdArg("VERSION=3.0"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := b.ReplaceEnvMatches("$VERSION"); value != "2.0" {
+		t.Fatalf("Expected the --build-arg value 2.0, got %s", value)
+	}
+
+	// ENV takes precedence over ARG, as after ENV VERSION 4.0
+	b.config.Env = []string{"VERSION=4.0"}
+	if value, _ := b.ReplaceEnvMatches("$VERSION"); value != "4.0" {
+		t.Fatalf("Expected the ENV value 4.0, got %s", value)
+	}
+	if expected := []string{"UNSET=set"}; !reflect.DeepEqual(b.argsEnv(), expected) {
+		t.Fatalf("Expected ENV to override the argument, got %v", b.argsEnv())
+	}
+}
+
+func TestCmdArgUseBeforeDeclaration(t *testing.T) {
+	b := &buildFile{config: &runconfig.Config{}, buildArgs: map[string]string{"VERSION": "2.0"}}
+
+	// --build-arg alone doesn't define the variable
+	if value, _ := b.ReplaceEnvMatches("app-$VERSION"); value != "app-$VERSION" {
+		t.Fatalf("Expected $VERSION to be left as is before ARG, got %s", value)
+	}
+	if b.argDeclared("VERSION") {
+		t.Fatal("Expected VERSION not to be declared before ARG")
+	}
+	if err := b.CmdArg("VERSION"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := b.ReplaceEnvMatches("app-$VERSION"); value != "app-2.0" {
+		t.Fatalf("Expected $VERSION to be replaced after ARG, got %s", value)
+	}
+}
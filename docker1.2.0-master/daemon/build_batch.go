@@ -0,0 +1,209 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/utils"
+)
+
+// batchBuild is a build of a batch, from a remote context.
+type batchBuild struct {
+	Remote    string
+	Tag       string
+	GitRef    string
+	GitDepth  int
+	NoCache   bool
+	BuildArgs map[string]string
+	// After are the tags of the builds of the batch this one depends on
+	After []string
+}
+
+// CmdBuildBatch builds the remote contexts read from stdin, concurrently up
+// to "workers" builds at a time. A build starts once the builds whose tags
+// it lists in After succeeded.
+func (daemon *Daemon) CmdBuildBatch(job *engine.Job) engine.Status {
+	if len(job.Args) != 0 {
+		return job.Errorf("Usage: %s", job.Name)
+	}
+	var builds []*batchBuild
+	if err := json.NewDecoder(job.Stdin).Decode(&builds); err != nil {
+		return job.Errorf("Invalid builds: %s", err)
+	}
+	if err := checkBatch(builds); err != nil {
+		return job.Error(err)
+	}
+	workers := job.GetenvInt("workers")
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		sf     = utils.NewStreamFormatter(job.GetenvBool("json"))
+		out    = &lockedWriter{w: job.Stdout}
+		slots  = make(chan struct{}, workers)
+		done   = make(map[string]chan struct{})
+		failed = make(map[string]error)
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for _, b := range builds {
+		done[b.Tag] = make(chan struct{})
+	}
+	for _, b := range builds {
+		wg.Add(1)
+		go func(b *batchBuild) {
+			defer wg.Done()
+			defer close(done[b.Tag])
+			err := func() error {
+				for _, dep := range b.After {
+					<-done[dep]
+					mu.Lock()
+					depErr := failed[dep]
+					mu.Unlock()
+					if depErr != nil {
+						return fmt.Errorf("Dependency %s failed", dep)
+					}
+				}
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				return daemon.runBatchBuild(job, b, &statusWriter{w: out, sf: sf, id: b.Tag})
+			}()
+			if err != nil {
+				mu.Lock()
+				failed[b.Tag] = err
+				mu.Unlock()
+				out.Write(sf.FormatStatus(b.Tag, "Build failed: %s", err))
+			}
+		}(b)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		tags := make([]string, 0, len(failed))
+		for tag := range failed {
+			tags = append(tags, tag)
+		}
+		return job.Errorf("%d of %d builds failed: %s", len(failed), len(builds), strings.Join(tags, ", "))
+	}
+	return engine.StatusOK
+}
+
+// checkBatch checks that the builds have distinct tags and depend on builds
+// of the batch, without cycles.
+func checkBatch(builds []*batchBuild) error {
+	byTag := make(map[string]*batchBuild)
+	for _, b := range builds {
+		if b.Remote == "" || b.Tag == "" {
+			return fmt.Errorf("A build of a batch needs a Remote and a Tag")
+		}
+		if _, exists := byTag[b.Tag]; exists {
+			return fmt.Errorf("Tag %s is built twice", b.Tag)
+		}
+		byTag[b.Tag] = b
+	}
+	// 0: not visited, 1: being visited, 2: visited
+	visits := make(map[string]int)
+	var visit func(b *batchBuild) error
+	visit = func(b *batchBuild) error {
+		switch visits[b.Tag] {
+		case 1:
+			return fmt.Errorf("Circular dependency on %s", b.Tag)
+		case 2:
+			return nil
+		}
+		visits[b.Tag] = 1
+		for _, dep := range b.After {
+			d, exists := byTag[dep]
+			if !exists {
+				return fmt.Errorf("%s depends on %s, which isn't built by the batch", b.Tag, dep)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		visits[b.Tag] = 2
+		return nil
+	}
+	for _, b := range builds {
+		if err := visit(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBatchBuild runs the build job of b, its output being written to out.
+func (daemon *Daemon) runBatchBuild(parent *engine.Job, b *batchBuild, out *statusWriter) error {
+	// The build is canceled with the request of the batch
+	job := parent.Eng.Job("build")
+	job.Setenv("remote", b.Remote)
+	job.Setenv("t", b.Tag)
+	job.Setenv("gitref", b.GitRef)
+	job.SetenvInt("gitdepth", b.GitDepth)
+	job.SetenvBool("nocache", b.NoCache)
+	job.Setenv("rm", parent.Getenv("rm"))
+	job.Setenv("forcerm", parent.Getenv("forcerm"))
+	job.Setenv("authConfig", parent.Getenv("authConfig"))
+	job.Setenv("configFile", parent.Getenv("configFile"))
+	if b.BuildArgs != nil {
+		job.SetenvJson("buildargs", b.BuildArgs)
+	}
+	job.Stdout.Add(out)
+	err := job.Run()
+	out.Flush()
+	return err
+}
+
+// lockedWriter serializes the writes of the concurrent builds.
+type lockedWriter struct {
+	sync.Mutex
+	w io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+	return l.w.Write(p)
+}
+
+// statusWriter writes the lines of the text output of a build as statuses
+// of its tag.
+type statusWriter struct {
+	w   io.Writer
+	sf  *utils.StreamFormatter
+	id  string
+	buf bytes.Buffer
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	s.buf.Write(p)
+	for {
+		i := bytes.IndexByte(s.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := s.buf.Next(i + 1)
+		s.writeLine(line)
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line, without a newline.
+func (s *statusWriter) Flush() {
+	if s.buf.Len() > 0 {
+		s.writeLine(s.buf.Next(s.buf.Len()))
+	}
+}
+
+func (s *statusWriter) writeLine(line []byte) {
+	if text := strings.Trim(string(line), "\r\n"); text != "" {
+		s.w.Write(s.sf.FormatStatus(s.id, "%s", text))
+	}
+}
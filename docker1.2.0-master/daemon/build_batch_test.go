@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"testing"
+)
+
+func TestCheckBatch(t *testing.T) {
+	valid := []*batchBuild{
+		{Remote: "github.com/user/base", Tag: "user/base"},
+		{Remote: "github.com/user/app", Tag: "user/app", After: []string{"user/base"}},
+		{Remote: "github.com/user/tools", Tag: "user/tools"},
+	}
+	if err := checkBatch(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, builds := range [][]*batchBuild{
+		{{Remote: "github.com/user/base"}},
+		{{Remote: "github.com/user/base", Tag: "user/base"}, {Remote: "github.com/user/app", Tag: "user/base"}},
+		{{Remote: "github.com/user/app", Tag: "user/app", After: []string{"user/base"}}},
+		{
+			{Remote: "github.com/user/base", Tag: "user/base", After: []string{"user/app"}},
+			{Remote: "github.com/user/app", Tag: "user/app", After: []string{"user/base"}},
+		},
+	} {
+		if err := checkBatch(builds); err == nil {
+			t.Fatalf("Expected an error checking %v", builds)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/runconfig"
+)
+
+// Check parses and validates the Dockerfile of the context without running
+// anything: no image is pulled, no container is created and nothing is
+// committed. All the invalid instructions are reported, not only the first.
+func (b *buildFile) Check(context io.Reader) error {
+	b.dryRun = true
+	err := b.extractContext(context)
+	if b.contextPath != "" {
+		defer os.RemoveAll(b.contextPath)
+	}
+	defer b.waitContext()
+	if err != nil {
+		return err
+	}
+	lines, err := b.readDockerfile()
+	if err != nil {
+		return err
+	}
+	nErrors := 0
+	for stepN, line := range lines {
+		fmt.Fprintf(b.outStream, "Step %d : %s\n", stepN, line)
+		if err := b.checkStep(line); err != nil {
+			fmt.Fprintf(b.outStream, " ---> Error: %s\n", err)
+			nErrors++
+		}
+	}
+	if nErrors > 0 {
+		return fmt.Errorf("The Dockerfile has %d invalid instructions", nErrors)
+	}
+	fmt.Fprintf(b.outStream, "The Dockerfile is valid\n")
+	return nil
+}
+
+// checkStep validates a single instruction, the unknown ones being errors
+// whereas a build skips them.
+func (b *buildFile) checkStep(expression string) error {
+	tmp := strings.SplitN(expression, " ", 2)
+	if len(tmp) != 2 {
+		return fmt.Errorf("Invalid Dockerfile format")
+	}
+	instruction := strings.ToLower(strings.Trim(tmp[0], " "))
+	arguments := strings.Trim(tmp[1], " ")
+
+	method, exists := reflect.TypeOf(b).MethodByName("Cmd" + strings.ToUpper(instruction[:1]) + strings.ToLower(instruction[1:]))
+	if !exists {
+		return fmt.Errorf("Unknown instruction %s", strings.ToUpper(instruction))
+	}
+	ret := method.Func.Call([]reflect.Value{reflect.ValueOf(b), reflect.ValueOf(arguments)})[0].Interface()
+	if ret != nil {
+		return ret.(error)
+	}
+	return nil
+}
+
+// checkFrom validates the name of the base image. The config of the image
+// is used when it is already there, for the variables of its environment,
+// but it isn't pulled and its ONBUILD triggers aren't checked.
+func (b *buildFile) checkFrom(name string) error {
+	// The following instructions are checked even if the name is invalid
+	b.image = name
+	b.from = name
+	b.config = &runconfig.Config{}
+	if image, err := b.daemon.Repositories().LookupImage(name); err == nil {
+		if image.Config != nil {
+			b.config = image.Config
+		}
+		b.image = image.ID
+		b.from = image.ID
+	} else if !b.daemon.Graph().IsNotExist(err) {
+		return err
+	} else {
+		remote, _ := parsers.ParseRepositoryTag(name)
+		if _, _, err := registry.ResolveRepositoryName(remote); err != nil {
+			return err
+		}
+	}
+	if b.config.Env == nil || len(b.config.Env) == 0 {
+		b.config.Env = append(b.config.Env, "PATH="+DefaultPathEnv)
+	}
+	b.config.OnBuild = []string{}
+	return nil
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/docker/docker/pkg/tarsum"
+	"github.com/docker/docker/runconfig"
+)
+
+func TestCheckStep(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-build-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := ioutil.WriteFile(path.Join(tmp, "config.yml"), []byte("debug: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// As after a FROM, the base image is only checked by name
+	b := &buildFile{
+		dryRun:      true,
+		image:       "busybox",
+		config:      &runconfig.Config{},
+		contextPath: tmp,
+		context:     &tarsum.TarSum{},
+	}
+	for _, valid := range []string{
+		"RUN make",
+		"ENV APP /srv/app",
+		"COPY --chown=1000 config.yml $APP/",
+		"ADD http://example.com/app.tar.gz /srv/",
+		"EXPOSE 8080",
+		"HEALTHCHECK --interval=5s CMD ./healthy",
+	} {
+		if err := b.checkStep(valid); err != nil {
+			t.Fatalf("Unexpected error checking %q: %s", valid, err)
+		}
+	}
+	if b.image != "busybox" {
+		t.Fatalf("Expected the dry run to leave the image as is, got %s", b.image)
+	}
+
+	for _, invalid := range []string{
+		"RUNN make",
+		"RUN",
+		"COPY --chown=app config.yml /etc/",
+		"ADD missing.tar /",
+		"COPY http://example.com/app.tar.gz /srv/",
+		"EXPOSE 80:80:80:80",
+		"HEALTHCHECK --retries=0 CMD ./healthy",
+	} {
+		if err := b.checkStep(invalid); err == nil {
+			t.Fatalf("Expected an error checking %q", invalid)
+		}
+	}
+}
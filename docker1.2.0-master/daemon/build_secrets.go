@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/pkg/mount"
+)
+
+// secretsPath is where the secrets of a build are in the containers of its
+// RUN instructions
+const secretsPath = "/run/secrets"
+
+// validateSecrets checks that the names of the secrets are file names.
+func validateSecrets(secrets map[string][]byte) error {
+	for name := range secrets {
+		if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+			return fmt.Errorf("Invalid secret name: %q", name)
+		}
+	}
+	return nil
+}
+
+// mountSecrets writes the secrets of the build to a tmpfs, so that they are
+// never stored on disk. It is bind mounted read-only in the containers of
+// the RUN instructions, outside of the layers they commit.
+func (b *buildFile) mountSecrets() error {
+	dir, err := ioutil.TempDir("", "docker-build-secrets")
+	if err != nil {
+		return err
+	}
+	if err := mount.Mount("tmpfs", dir, "tmpfs", "mode=0700"); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("Error mounting the tmpfs of the secrets: %s", err)
+	}
+	b.secretsDir = dir
+	for name, value := range b.secrets {
+		if err := ioutil.WriteFile(path.Join(dir, name), value, 0400); err != nil {
+			b.unmountSecrets()
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *buildFile) unmountSecrets() {
+	if b.secretsDir == "" {
+		return
+	}
+	if err := mount.Unmount(b.secretsDir); err != nil {
+		fmt.Fprintf(b.errStream, "# Error unmounting the secrets: %s\n", err)
+		return
+	}
+	os.RemoveAll(b.secretsDir)
+	b.secretsDir = ""
+}
+
+// bindSecrets mounts the secrets in the container, if the build has any.
+func (b *buildFile) bindSecrets(c *Container) {
+	if b.secretsDir != "" {
+		c.hostConfig.Binds = append(c.hostConfig.Binds, fmt.Sprintf("%s:%s:ro", b.secretsDir, secretsPath))
+	}
+}
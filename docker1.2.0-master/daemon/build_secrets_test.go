@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/docker/docker/pkg/mount"
+	"github.com/docker/docker/runconfig"
+)
+
+func TestValidateSecrets(t *testing.T) {
+	if err := validateSecrets(map[string][]byte{"token": nil, "id_rsa": nil}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", ".", "..", "../etc/passwd", "a/b"} {
+		if err := validateSecrets(map[string][]byte{name: nil}); err == nil {
+			t.Fatalf("Expected an error for the secret %q", name)
+		}
+	}
+}
+
+func TestMountSecrets(t *testing.T) {
+	b := &buildFile{
+		secrets:   map[string][]byte{"token": []byte("s3cr3t")},
+		errStream: ioutil.Discard,
+	}
+	if err := b.mountSecrets(); err != nil {
+		// Mounting a tmpfs needs root
+		t.Skip(err)
+	}
+	defer b.unmountSecrets()
+	dir := b.secretsDir
+
+	if mounted, err := mount.Mounted(dir); err != nil || !mounted {
+		t.Fatalf("Expected a tmpfs to be mounted on %s (%v)", dir, err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Fatalf("Expected the secrets directory to be 0700, got %o", perm)
+	}
+	file := path.Join(dir, "token")
+	if info, err = os.Stat(file); err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0400 {
+		t.Fatalf("Expected the secret to be 0400, got %o", perm)
+	}
+	if content, err := ioutil.ReadFile(file); err != nil || !bytes.Equal(content, []byte("s3cr3t")) {
+		t.Fatalf("Expected the content of the secret, got %q (%v)", content, err)
+	}
+
+	c := &Container{hostConfig: &runconfig.HostConfig{}}
+	b.bindSecrets(c)
+	if expected := dir + ":" + secretsPath + ":ro"; len(c.hostConfig.Binds) != 1 || c.hostConfig.Binds[0] != expected {
+		t.Fatalf("Expected the read-only bind %s, got %v", expected, c.hostConfig.Binds)
+	}
+
+	b.unmountSecrets()
+	if b.secretsDir != "" {
+		t.Fatal("Expected the secrets directory to be forgotten")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected the secrets directory to be removed, got %v", err)
+	}
+	// Nothing is left to bind once cleaned up
+	c = &Container{hostConfig: &runconfig.HostConfig{}}
+	b.bindSecrets(c)
+	if len(c.hostConfig.Binds) != 0 {
+		t.Fatalf("Expected no bind after the cleanup, got %v", c.hostConfig.Binds)
+	}
+}
+
+func TestMountSecretsInvalidName(t *testing.T) {
+	b := &buildFile{
+		secrets:   map[string][]byte{"missing/token": []byte("s3cr3t")},
+		errStream: ioutil.Discard,
+	}
+	if err := b.mountSecrets(); err == nil {
+		b.unmountSecrets()
+		t.Fatal("Expected an error writing a secret in a missing directory")
+	}
+	if b.secretsDir != "" {
+		t.Fatalf("Expected the secrets to be cleaned up on error, %s is left", b.secretsDir)
+	}
+}
@@ -1,6 +1,10 @@
 package daemon
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/runconfig"
@@ -30,7 +34,12 @@ func (daemon *Daemon) ContainerCommit(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
-	img, err := daemon.Commit(container, job.Getenv("repo"), job.Getenv("tag"), job.Getenv("comment"), job.Getenv("author"), job.GetenvBool("pause"), &newConfig)
+	timestamp, err := parseTimestamp(job.Getenv("timestamp"))
+	if err != nil {
+		return job.Error(err)
+	}
+
+	img, err := daemon.Commit(container, job.Getenv("repo"), job.Getenv("tag"), job.Getenv("comment"), job.Getenv("author"), job.GetenvBool("pause"), job.GetenvBool("squash"), timestamp, &newConfig)
 	if err != nil {
 		return job.Error(err)
 	}
@@ -39,8 +48,10 @@ func (daemon *Daemon) ContainerCommit(job *engine.Job) engine.Status {
 }
 
 // Commit creates a new filesystem image from the current state of a container.
-// The image can optionally be tagged into a repository
-func (daemon *Daemon) Commit(container *Container, repository, tag, comment, author string, pause bool, config *runconfig.Config) (*image.Image, error) {
+// The image can optionally be tagged into a repository, and squashed into a
+// single layer without parent. A non zero timestamp is the creation date of
+// the image and the modification time of the files of its layer.
+func (daemon *Daemon) Commit(container *Container, repository, tag, comment, author string, pause, squash bool, timestamp time.Time, config *runconfig.Config) (*image.Image, error) {
 	if pause {
 		container.Pause()
 		defer container.Unpause()
@@ -69,10 +80,22 @@ func (daemon *Daemon) Commit(container *Container, repository, tag, comment, aut
 		containerConfig = container.Config
 	}
 
-	img, err := daemon.graph.Create(rwTar, containerID, containerImage, comment, author, containerConfig, config)
+	img, err := daemon.graph.CreateAt(rwTar, containerID, containerImage, comment, author, containerConfig, config, timestamp)
 	if err != nil {
 		return nil, err
 	}
+	if squash {
+		// The image committed is only an intermediate one
+		squashed, err := daemon.graph.Squash(img.ID, "", timestamp)
+		if err != nil {
+			daemon.graph.Delete(img.ID)
+			return nil, err
+		}
+		if err := daemon.graph.Delete(img.ID); err != nil {
+			return nil, err
+		}
+		img = squashed
+	}
 
 	// Register the image if needed
 	if repository != "" {
@@ -82,3 +105,16 @@ func (daemon *Daemon) Commit(container *Container, repository, tag, comment, aut
 	}
 	return img, nil
 }
+
+// parseTimestamp parses the seconds since the epoch images are pinned to, an
+// empty value being a zero time which doesn't pin them.
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("Invalid timestamp %s: expected seconds since the epoch", value)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
@@ -2,8 +2,10 @@ package daemon
 
 import (
 	"net"
+	"time"
 
 	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/graph"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 )
@@ -18,23 +20,46 @@ const (
 // to the docker daemon when you launch it with say: `docker -d -e lxc`
 // FIXME: separate runtime configuration from http api configuration
 type Config struct {
-	Pidfile                     string   //Docker Daemon 所属进程的 PID 文件
-	Root                        string   //Docker 运行时所使用的 root 路径
-	AutoRestart                 bool     //是否一直支持创建容器的重启
-	Dns                         []string //DockerDaemon 为容器准备的 DNS Server 地址
-	DnsSearch                   []string //Docker 使用的指定的 DNS 查找地址
-	EnableIptables              bool     //是否启用 Docker iptables 功能
-	EnableIpForward             bool     //是否启用 net.ipv4.ip_forward 功能
-	DefaultIp                   net.IP   //绑定容器端口时使用的默认 IP
-	BridgeIface                 string   //添加容器网络至已有的网桥接口名
-	BridgeIP                    string   //创建网桥的 IP 地址
-	InterContainerCommunication bool     //是否允许宿主机上 Docker 容器间的通信
-	GraphDriver                 string   //Docker Daemon 运行时使用的特定存储驱动
-	GraphOptions                []string // 可设置的存储驱动选项
-	ExecDriver                  string   //Docker 运行时使用的特定 exec 驱动
-	Mtu                         int      //设置容器网络接口的 MTU
-	DisableNetwork              bool     //是否支持 Docker 容器的网络模式
-	EnableSelinuxSupport        bool     //是否启用对 SELinux 功能的支持
+	Pidfile                     string        //Docker Daemon 所属进程的 PID 文件
+	Root                        string        //Docker 运行时所使用的 root 路径
+	AutoRestart                 bool          //是否一直支持创建容器的重启
+	Dns                         []string      //DockerDaemon 为容器准备的 DNS Server 地址
+	DnsSearch                   []string      //Docker 使用的指定的 DNS 查找地址
+	EnableIptables              bool          //是否启用 Docker iptables 功能
+	EnableIpForward             bool          //是否启用 net.ipv4.ip_forward 功能
+	DefaultIp                   net.IP        //绑定容器端口时使用的默认 IP
+	BridgeIface                 string        //添加容器网络至已有的网桥接口名
+	BridgeIP                    string        //创建网桥的 IP 地址
+	InterContainerCommunication bool          //是否允许宿主机上 Docker 容器间的通信
+	GraphDriver                 string        //Docker Daemon 运行时使用的特定存储驱动
+	GraphOptions                []string      // 可设置的存储驱动选项
+	RwLayersRoot                string        //容器读写层的存储路径，为空时与镜像层一起存储在 root 下
+	Fsck                        bool          //启动时检查镜像、layer 与 tag 的一致性
+	FsckRepair                  bool          //启动时检查并修复不一致的镜像、layer 与 tag
+	ExecDriver                  string        //Docker 运行时使用的特定 exec 驱动
+	Mtu                         int           //设置容器网络接口的 MTU
+	DisableNetwork              bool          //是否支持 Docker 容器的网络模式
+	EnableSelinuxSupport        bool          //是否启用对 SELinux 功能的支持
+	DefaultLabels               []string      //注入每个新建容器的默认标签 (key=value)
+	DefaultEnv                  []string      //注入每个新建容器的默认环境变量
+	LogDriver                   string        //未指定时容器使用的默认日志驱动
+	LogOpts                     []string      //默认日志驱动的选项 (key=value)
+	LogDiskMax                  string        //所有容器日志文件占用磁盘空间的上限
+	VolumesGCInterval           time.Duration //定期清理无容器引用的匿名数据卷的间隔，0 表示不清理
+	ImagesGCInterval            time.Duration //定期按策略清理未使用镜像的间隔，0 表示不清理
+	ImagesGCMaxAge              time.Duration //保留创建时间在此时长之内的镜像，0 表示不限
+	ImagesGCKeepTags            int           //每个仓库保留最近的 tag 数，0 表示保留所有带 tag 的镜像
+	InsecureRegistries          []string      //允许通过 http 或自签名证书访问的 registry (host[:port] 或 CIDR)
+	MaxConcurrentDownloads      int           //daemon 同时下载的 layer 数上限
+	MaxConcurrentUploads        int           //daemon 同时上传的 layer 数上限
+	LayerCompression            string        //push 与 save 时 layer 的压缩算法 (gzip 或 none)
+	LayerCompressionLevel       int           //layer 的压缩级别 (1-9)，0 为默认级别
+	GpuProfile                  string        //以 --gpus 创建的容器访问 GPU 的配置文件，为空时使用 nvidia 的默认配置
+	NameWordlist                string        //生成容器名所用的词表文件，为空时使用默认的形容词与人名
+	NamePrefix                  string        //生成的容器名的前缀
+	NameSuffix                  string        //生成的容器名的后缀
+	NameSequential              bool          //以前缀、递增序号与后缀生成容器名
+	IDGenerator                 string        //生成容器与镜像 ID 的方式：random 或 sortable
 	Context                     map[string][]string
 }
 
@@ -53,13 +78,36 @@ func (config *Config) InstallFlags() {
 	flag.BoolVar(&config.InterContainerCommunication, []string{"#icc", "-icc"}, true, "Enable inter-container communication")
 	flag.StringVar(&config.GraphDriver, []string{"s", "-storage-driver"}, "", "Force the Docker runtime to use a specific storage driver")
 	flag.StringVar(&config.ExecDriver, []string{"e", "-exec-driver"}, "native", "Force the Docker runtime to use a specific exec driver")
+	flag.StringVar(&config.LogDriver, []string{"-log-driver"}, "json-file", "Default logging driver for containers")
+	opts.ListVar(&config.LogOpts, []string{"-log-opt"}, "Set log driver options (key=value)")
+	flag.DurationVar(&config.VolumesGCInterval, []string{"-volumes-gc-interval"}, 0, "Interval at which volumes no container references are removed (e.g. 1h), 0 to disable")
+	flag.DurationVar(&config.ImagesGCInterval, []string{"-images-gc-interval"}, 0, "Interval at which the images no container uses are removed (e.g. 24h), 0 to disable")
+	flag.DurationVar(&config.ImagesGCMaxAge, []string{"-images-gc-max-age"}, 0, "Keep the images created more recently than this (e.g. 720h), 0 for no limit")
+	flag.IntVar(&config.ImagesGCKeepTags, []string{"-images-gc-keep-tags"}, 0, "Keep the most recent tags of each repository, 0 to keep all the tagged images")
+	flag.StringVar(&config.LogDiskMax, []string{"-log-disk-max"}, "", "Maximum disk space used by the logs of all containers, the oldest logs are pruned beyond it (e.g. 10g)")
 	flag.BoolVar(&config.EnableSelinuxSupport, []string{"-selinux-enabled"}, false, "Enable selinux support. SELinux does not presently support the BTRFS storage driver")
 	flag.IntVar(&config.Mtu, []string{"#mtu", "-mtu"}, 0, "Set the containers network MTU\nif no value is provided: default to the default route MTU or 1500 if no default route is available")
 	opts.IPVar(&config.DefaultIp, []string{"#ip", "-ip"}, "0.0.0.0", "Default IP address to use when binding container ports")
 	opts.ListVar(&config.GraphOptions, []string{"-storage-opt"}, "Set storage driver options")
+	flag.BoolVar(&config.Fsck, []string{"-fsck"}, false, "Check the consistency of the images, layers and tags at startup")
+	flag.BoolVar(&config.FsckRepair, []string{"-fsck-repair"}, false, "Check the images, layers and tags at startup and remove the inconsistent ones")
+	flag.StringVar(&config.RwLayersRoot, []string{"-rw-layers-root"}, "", "Path to store the read-write layers of the containers apart from the image layers (aufs and vfs storage drivers)")
 	// FIXME: why the inconsistency between "hosts" and "sockets"?
 	opts.IPListVar(&config.Dns, []string{"#dns", "-dns"}, "Force Docker to use specific DNS servers")
 	opts.DnsSearchListVar(&config.DnsSearch, []string{"-dns-search"}, "Force Docker to use specific DNS search domains")
+	opts.LabelListVar(&config.DefaultLabels, []string{"-default-label"}, "Set a default label (key=value) on every created container")
+	opts.EnvListVar(&config.DefaultEnv, []string{"-default-env"}, "Set a default environment variable on every created container")
+	opts.InsecureRegistryListVar(&config.InsecureRegistries, []string{"-insecure-registry"}, "Allow http or self-signed certificates for this registry (host[:port] or CIDR)")
+	flag.IntVar(&config.MaxConcurrentDownloads, []string{"-max-concurrent-downloads"}, graph.DefaultMaxConcurrentDownloads, "Maximum number of layers downloaded at the same time")
+	flag.IntVar(&config.MaxConcurrentUploads, []string{"-max-concurrent-uploads"}, graph.DefaultMaxConcurrentUploads, "Maximum number of layers uploaded at the same time")
+	flag.StringVar(&config.LayerCompression, []string{"-layer-compression"}, graph.DefaultLayerCompression, "Compression of the layers pushed and saved: gzip or none")
+	flag.IntVar(&config.LayerCompressionLevel, []string{"-layer-compression-level"}, 0, "Level of the layer compression, from 1 (fastest) to 9 (smallest), 0 for the default level")
+	flag.StringVar(&config.GpuProfile, []string{"-gpu-profile"}, "", "Path to the JSON profile of the GPU devices and driver libraries given to the containers run with --gpus, the nvidia devices by default")
+	flag.StringVar(&config.NameWordlist, []string{"-name-wordlist"}, "", "Path to a list of words, one per line, to generate the names of the containers from")
+	flag.StringVar(&config.NamePrefix, []string{"-name-prefix"}, "", "Prefix of the generated names of the containers (e.g. web1-)")
+	flag.StringVar(&config.NameSuffix, []string{"-name-suffix"}, "", "Suffix of the generated names of the containers")
+	flag.BoolVar(&config.NameSequential, []string{"-name-sequential"}, false, "Generate the names of the containers from a counter between the prefix and the suffix (e.g. web1-42)")
+	flag.StringVar(&config.IDGenerator, []string{"-id-generator"}, "random", "Generator of the IDs of the containers and the images: random or sortable (by creation time)")
 }
 
 func GetDefaultNetworkMtu() int {
@@ -20,21 +20,27 @@ import (
 	"github.com/docker/docker/archive"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/jsonfilelog"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/links"
 	"github.com/docker/docker/nat"
 	"github.com/docker/docker/pkg/broadcastwriter"
-	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/networkfs/etchosts"
 	"github.com/docker/docker/pkg/networkfs/resolvconf"
 	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 )
 
 const DefaultPathEnv = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
 
+// attachBufferSize is the amount of output buffered for every consumer of
+// the container's stdout and stderr.
+const attachBufferSize = 1024 * 1024
+
 var (
 	ErrNotATTY               = errors.New("The PTY is not a file")
 	ErrNoTTY                 = errors.New("No PTY found")
@@ -57,6 +63,9 @@ type Container struct {
 	Config *runconfig.Config
 	State  *State
 	Image  string
+	// ImageDigest is the content digest of the image the container was
+	// created from, empty if the image isn't content addressable
+	ImageDigest string
 
 	NetworkSettings *NetworkSettings
 
@@ -72,6 +81,7 @@ type Container struct {
 	stderr    *broadcastwriter.BroadcastWriter
 	stdin     io.ReadCloser
 	stdinPipe io.WriteCloser
+	logDriver logger.Logger
 
 	daemon                   *Daemon
 	MountLabel, ProcessLabel string
@@ -85,6 +95,12 @@ type Container struct {
 
 	activeLinks map[string]*links.Link
 	monitor     *containerMonitor
+	healthStop  chan struct{} // closed when the container exits to stop the health checks
+	// The last size of the tty, given again to the tty of the next runs
+	ttyLock             sync.Mutex
+	ttyHeight, ttyWidth int
+	// Named volumes mounted by a volume driver for the container
+	driverVolumes []string
 }
 
 func (container *Container) FromDisk() error {
@@ -172,9 +188,19 @@ func (container *Container) WriteHostConfig() error {
 }
 
 func (container *Container) LogEvent(action string) {
+	container.LogEventWithAttributes(action, nil)
+}
+
+// LogEventWithAttributes logs an event of the container with more details,
+// e.g. its exit code.
+func (container *Container) LogEventWithAttributes(action string, attributes map[string]string) {
 	d := container.daemon
-	if err := d.eng.Job("log", action, container.ID, d.Repositories().ImageName(container.Image)).Run(); err != nil {
-		log.Errorf("Error logging event %s for %s: %s", action, container.ID, err)
+	job := d.eng.Job("log", action, container.ID, d.Repositories().ImageName(container.Image))
+	if attributes != nil {
+		job.SetenvJson("Attributes", attributes)
+	}
+	if err := job.Run(); err != nil {
+		daemonLog.Errorf("Error logging event %s for %s: %s", action, container.ID, err)
 	}
 }
 
@@ -236,18 +262,49 @@ func populateCommand(c *Container, env []string) error {
 		}
 		userSpecifiedDevices[i] = device
 	}
+	gpuDevices, err := c.daemon.gpuDevices(c)
+	if err != nil {
+		return err
+	}
+	userSpecifiedDevices = append(userSpecifiedDevices, gpuDevices...)
 	allowedDevices := append(devices.DefaultAllowedDevices, userSpecifiedDevices...)
+	// The rules allow devices which may not exist yet, there is nothing to create
+	for _, rule := range c.hostConfig.DeviceCgroupRules {
+		device, err := parseDeviceCgroupRule(rule)
+		if err != nil {
+			return err
+		}
+		allowedDevices = append(allowedDevices, device)
+	}
 
 	autoCreatedDevices := append(devices.DefaultAutoCreatedDevices, userSpecifiedDevices...)
 
 	// TODO: this can be removed after lxc-conf is fully deprecated
 	mergeLxcConfIntoOptions(c.hostConfig, context)
 
+	seccompConfig, err := loadSeccompProfile(c.hostConfig)
+	if err != nil {
+		return err
+	}
+	if c.hostConfig.SeccompAudit {
+		c.daemon.seccompAuditor.watch(c)
+	}
+
+	var rlimits []*ulimit.Rlimit
+	for _, u := range c.hostConfig.Ulimits {
+		rl, err := u.GetRlimit()
+		if err != nil {
+			return err
+		}
+		rlimits = append(rlimits, rl)
+	}
+
 	resources := &execdriver.Resources{
 		Memory:     c.Config.Memory,
 		MemorySwap: c.Config.MemorySwap,
 		CpuShares:  c.Config.CpuShares,
 		Cpuset:     c.Config.Cpuset,
+		Rlimits:    rlimits,
 	}
 	c.command = &execdriver.Command{
 		ID:                 c.ID,
@@ -266,6 +323,7 @@ func populateCommand(c *Container, env []string) error {
 		AutoCreatedDevices: autoCreatedDevices,
 		CapAdd:             c.hostConfig.CapAdd,
 		CapDrop:            c.hostConfig.CapDrop,
+		Seccomp:            seccompConfig,
 	}
 	c.command.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
 	c.command.Env = env
@@ -354,7 +412,9 @@ func (container *Container) Output() (output []byte, err error) {
 // which can be used to retrieve the standard output (and error) generated
 // by the container's active process. The output (and error) are actually
 // copied and delivered to all StdoutPipe and StderrPipe consumers, using
-// a kind of "broadcaster".
+// a kind of "broadcaster". Every consumer gets up to attachBufferSize bytes
+// of buffering, output beyond that is dropped for slow consumers rather than
+// stalling the container.
 
 func (container *Container) StdinPipe() (io.WriteCloser, error) {
 	return container.stdinPipe, nil
@@ -362,26 +422,26 @@ func (container *Container) StdinPipe() (io.WriteCloser, error) {
 
 func (container *Container) StdoutPipe() (io.ReadCloser, error) {
 	reader, writer := io.Pipe()
-	container.stdout.AddWriter(writer, "")
-	return utils.NewBufReader(reader), nil
+	container.stdout.AddBufferedWriter(writer, "", attachBufferSize)
+	return reader, nil
 }
 
 func (container *Container) StderrPipe() (io.ReadCloser, error) {
 	reader, writer := io.Pipe()
-	container.stderr.AddWriter(writer, "")
-	return utils.NewBufReader(reader), nil
+	container.stderr.AddBufferedWriter(writer, "", attachBufferSize)
+	return reader, nil
 }
 
 func (container *Container) StdoutLogPipe() io.ReadCloser {
 	reader, writer := io.Pipe()
-	container.stdout.AddWriter(writer, "stdout")
-	return utils.NewBufReader(reader)
+	container.stdout.AddBufferedWriter(writer, "stdout", attachBufferSize)
+	return reader
 }
 
 func (container *Container) StderrLogPipe() io.ReadCloser {
 	reader, writer := io.Pipe()
-	container.stderr.AddWriter(writer, "stderr")
-	return utils.NewBufReader(reader)
+	container.stderr.AddBufferedWriter(writer, "stderr", attachBufferSize)
+	return reader
 }
 
 func (container *Container) buildHostnameFile() error {
@@ -515,12 +575,14 @@ func (container *Container) cleanup() {
 	}
 
 	if err := container.Unmount(); err != nil {
-		log.Errorf("%v: Failed to umount filesystem: %v", container.ID, err)
+		daemonLog.Errorf("%v: Failed to umount filesystem: %v", container.ID, err)
 	}
+
+	container.daemon.unmountDriverVolumes(container)
 }
 
 func (container *Container) KillSig(sig int) error {
-	log.Debugf("Sending %d to %s", sig, container.ID)
+	daemonLog.Debugf("Sending %d to %s", sig, container.ID)
 	container.Lock()
 	defer container.Unlock()
 
@@ -581,7 +643,7 @@ func (container *Container) Kill() error {
 	if _, err := container.State.WaitStop(10 * time.Second); err != nil {
 		// Ensure that we don't kill ourselves
 		if pid := container.State.GetPid(); pid != 0 {
-			log.Infof("Container %s failed to exit within 10 seconds of kill - trying direct SIGKILL", utils.TruncateID(container.ID))
+			daemonLog.Infof("Container %s failed to exit within 10 seconds of kill - trying direct SIGKILL", utils.TruncateID(container.ID))
 			if err := syscall.Kill(pid, 9); err != nil {
 				return err
 			}
@@ -599,7 +661,7 @@ func (container *Container) Stop(seconds int) error {
 
 	// 1. Send a SIGTERM
 	if err := container.KillSig(15); err != nil {
-		log.Infof("Failed to send SIGTERM to the process, force killing")
+		daemonLog.Infof("Failed to send SIGTERM to the process, force killing")
 		if err := container.KillSig(9); err != nil {
 			return err
 		}
@@ -607,7 +669,7 @@ func (container *Container) Stop(seconds int) error {
 
 	// 2. Wait for the process to exit on its own
 	if _, err := container.State.WaitStop(time.Duration(seconds) * time.Second); err != nil {
-		log.Infof("Container %v failed to exit within %d seconds of SIGTERM - using the force", container.ID, seconds)
+		daemonLog.Infof("Container %v failed to exit within %d seconds of SIGTERM - using the force", container.ID, seconds)
 		// 3. If it doesn't, then send SIGKILL
 		if err := container.Kill(); err != nil {
 			container.State.WaitStop(-1 * time.Second)
@@ -631,10 +693,32 @@ func (container *Container) Restart(seconds int) error {
 	return container.Start()
 }
 
+// Resize sets the window size of the tty of the container, the programs in
+// it getting SIGWINCH. The size is kept for the tty of its next runs.
 func (container *Container) Resize(h, w int) error {
+	if !container.State.IsRunning() {
+		return fmt.Errorf("Container %s is not running", container.ID)
+	}
+	container.ttyLock.Lock()
+	container.ttyHeight, container.ttyWidth = h, w
+	container.ttyLock.Unlock()
 	return container.command.Terminal.Resize(h, w)
 }
 
+// restoreTtySize gives the new tty of the container the size of the previous
+// one, for the programs to render at once after a restart.
+func (container *Container) restoreTtySize() {
+	container.ttyLock.Lock()
+	h, w := container.ttyHeight, container.ttyWidth
+	container.ttyLock.Unlock()
+	if h == 0 && w == 0 {
+		return
+	}
+	if err := container.command.Terminal.Resize(h, w); err != nil {
+		daemonLog.Debugf("Error restoring the tty size of %s: %s", container.ID, err)
+	}
+}
+
 func (container *Container) ExportRw() (archive.Archive, error) {
 	if err := container.Mount(); err != nil {
 		return nil, err
@@ -706,6 +790,54 @@ func (container *Container) ReadLog(name string) (io.Reader, error) {
 	return os.Open(pth)
 }
 
+// ReadJSONLogs returns the content of the json log of the container,
+// including the files rotated by the json-file log driver, oldest first.
+func (container *Container) ReadJSONLogs() (io.ReadCloser, error) {
+	files, err := container.jsonLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		readers []io.Reader
+		closers multiCloser
+	)
+	for i, f := range files {
+		log, err := os.Open(f)
+		if err != nil {
+			if os.IsNotExist(err) && i < len(files)-1 {
+				// rotated out while we were opening the files
+				continue
+			}
+			closers.Close()
+			return nil, err
+		}
+		readers = append(readers, log)
+		closers = append(closers, log)
+	}
+	return utils.NewReadCloserWrapper(io.MultiReader(readers...), closers.Close), nil
+}
+
+// jsonLogFiles returns the json log files of the container, oldest first.
+func (container *Container) jsonLogFiles() ([]string, error) {
+	pth, err := container.logPath("json")
+	if err != nil {
+		return nil, err
+	}
+	return jsonfilelog.LogFiles(pth), nil
+}
+
+type multiCloser []io.Closer
+
+func (c multiCloser) Close() error {
+	var err error
+	for _, closer := range c {
+		if e := closer.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
 func (container *Container) hostConfigPath() (string, error) {
 	return container.getRootResourcePath("hostconfig.json")
 }
@@ -736,7 +868,7 @@ func (container *Container) GetSize() (int64, int64) {
 	)
 
 	if err := container.Mount(); err != nil {
-		log.Errorf("Warning: failed to compute size of container rootfs %s: %s", container.ID, err)
+		daemonLog.Errorf("Warning: failed to compute size of container rootfs %s: %s", container.ID, err)
 		return sizeRw, sizeRootfs
 	}
 	defer container.Unmount()
@@ -744,7 +876,7 @@ func (container *Container) GetSize() (int64, int64) {
 	if differ, ok := container.daemon.driver.(graphdriver.Differ); ok {
 		sizeRw, err = differ.DiffSize(container.ID)
 		if err != nil {
-			log.Errorf("Warning: driver %s couldn't return diff size of container %s: %s", driver, container.ID, err)
+			daemonLog.Errorf("Warning: driver %s couldn't return diff size of container %s: %s", driver, container.ID, err)
 			// FIXME: GetSize should return an error. Not changing it now in case
 			// there is a side-effect.
 			sizeRw = -1
@@ -841,7 +973,7 @@ func (container *Container) DisableLink(name string) {
 		if link, exists := container.activeLinks[name]; exists {
 			link.Disable()
 		} else {
-			log.Debugf("Could not find active link for %s", name)
+			daemonLog.Debugf("Could not find active link for %s", name)
 		}
 	}
 }
@@ -944,15 +1076,23 @@ func (container *Container) initializeNetworking() error {
 // Make sure the config is compatible with the current kernel
 func (container *Container) verifyDaemonSettings() {
 	if container.Config.Memory > 0 && !container.daemon.sysInfo.MemoryLimit {
-		log.Infof("WARNING: Your kernel does not support memory limit capabilities. Limitation discarded.")
+		daemonLog.Infof("WARNING: Your kernel does not support memory limit capabilities. Limitation discarded.")
 		container.Config.Memory = 0
 	}
 	if container.Config.Memory > 0 && !container.daemon.sysInfo.SwapLimit {
-		log.Infof("WARNING: Your kernel does not support swap limit capabilities. Limitation discarded.")
+		daemonLog.Infof("WARNING: Your kernel does not support swap limit capabilities. Limitation discarded.")
 		container.Config.MemorySwap = -1
 	}
+	if container.Config.CpuShares > 0 && !container.daemon.sysInfo.CpuShares {
+		daemonLog.Infof("WARNING: Your kernel does not support cgroup cpu shares. Shares discarded.")
+		container.Config.CpuShares = 0
+	}
+	if container.Config.Cpuset != "" && !container.daemon.sysInfo.Cpuset {
+		daemonLog.Infof("WARNING: Your kernel does not support cgroup cpuset. Cpuset discarded.")
+		container.Config.Cpuset = ""
+	}
 	if container.daemon.sysInfo.IPv4ForwardingDisabled {
-		log.Infof("WARNING: IPv4 forwarding is disabled. Networking will not work")
+		daemonLog.Infof("WARNING: IPv4 forwarding is disabled. Networking will not work")
 	}
 }
 
@@ -1058,24 +1198,61 @@ func (container *Container) setupWorkingDirectory() error {
 	return nil
 }
 
-func (container *Container) startLoggingToDisk() error {
-	// Setup logging of stdout and stderr to disk
-	pth, err := container.logPath("json")
+// getLogConfig returns the log configuration of the container, falling back
+// to the daemon's default log driver.
+func (container *Container) getLogConfig() runconfig.LogConfig {
+	return container.daemon.mergeLogConfig(container.hostConfig.LogConfig)
+}
+
+func (container *Container) startLogging() error {
+	cfg := container.getLogConfig()
+	create, err := logger.GetLogDriver(cfg.Type)
 	if err != nil {
 		return err
 	}
-
-	if err := container.daemon.LogToDisk(container.stdout, pth, "stdout"); err != nil {
+	pth, err := container.logPath("json")
+	if err != nil {
 		return err
 	}
-
-	if err := container.daemon.LogToDisk(container.stderr, pth, "stderr"); err != nil {
+	spool, err := container.logPath(cfg.Type + "-spool")
+	if err != nil {
 		return err
 	}
+	l, err := create(logger.Context{
+		ContainerID:   container.ID,
+		ContainerName: strings.TrimPrefix(container.Name, "/"),
+		LogPath:       pth,
+		SpoolPath:     spool,
+		Config:        cfg.Options,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to initialize logging driver %s: %s", cfg.Type, err)
+	}
+
+	container.stdout.AddWriter(logger.NewWriter(l, container.ID, "stdout"), "")
+	container.stderr.AddWriter(logger.NewWriter(l, container.ID, "stderr"), "")
+	container.logDriver = l
 
 	return nil
 }
 
+// stopLogging closes the log driver once stdout and stderr have been cleaned
+// up, so the last non-eol-terminated lines have been flushed to it.
+func (container *Container) stopLogging() error {
+	if container.logDriver == nil {
+		return nil
+	}
+	err := container.logDriver.Close()
+	container.logDriver = nil
+	return err
+}
+
+// readLogsSupported reports whether the log driver of the container stores
+// logs that can be read back by the daemon.
+func (container *Container) readLogsSupported() bool {
+	return container.getLogConfig().Type == jsonfilelog.Name
+}
+
 func (container *Container) waitForStart() error {
 	container.monitor = newContainerMonitor(container, container.hostConfig.RestartPolicy)
 
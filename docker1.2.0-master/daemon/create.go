@@ -1,6 +1,8 @@
 package daemon
 
 import (
+	"strings"
+
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/pkg/parsers"
@@ -27,6 +29,15 @@ func (daemon *Daemon) ContainerCreate(job *engine.Job) engine.Status {
 		job.Errorf("Your kernel does not support swap limit capabilities. Limitation discarded.\n")
 		config.MemorySwap = -1
 	}
+	if config.CpuShares > 0 && !daemon.SystemConfig().CpuShares {
+		job.Errorf("Your kernel does not support cgroup cpu shares. Shares discarded.\n")
+		config.CpuShares = 0
+	}
+	if config.Cpuset != "" && !daemon.SystemConfig().Cpuset {
+		job.Errorf("Your kernel does not support cgroup cpuset. Cpuset discarded.\n")
+		config.Cpuset = ""
+	}
+	config = daemon.mergeDefaults(config)
 	container, buildWarnings, err := daemon.Create(config, name)
 	if err != nil {
 		if daemon.Graph().IsNotExist(err) {
@@ -54,6 +65,43 @@ func (daemon *Daemon) ContainerCreate(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
+// mergeDefaults returns a copy of config with the daemon-wide default labels
+// and environment variables. Values set explicitly on the container take
+// precedence over the daemon defaults. config itself is left untouched, the
+// builder committing its config in the images.
+func (daemon *Daemon) mergeDefaults(config *runconfig.Config) *runconfig.Config {
+	merged := *config
+	if len(daemon.config.DefaultLabels) > 0 {
+		merged.Labels = make(map[string]string, len(config.Labels))
+		for key, value := range config.Labels {
+			merged.Labels[key] = value
+		}
+	}
+	for _, label := range daemon.config.DefaultLabels {
+		parts := strings.SplitN(label, "=", 2)
+		if _, exists := merged.Labels[parts[0]]; !exists {
+			merged.Labels[parts[0]] = parts[1]
+		}
+	}
+	if len(daemon.config.DefaultEnv) > 0 {
+		merged.Env = append([]string{}, config.Env...)
+	}
+	for _, env := range daemon.config.DefaultEnv {
+		key := strings.SplitN(env, "=", 2)[0]
+		found := false
+		for _, userEnv := range config.Env {
+			if strings.SplitN(userEnv, "=", 2)[0] == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Env = append(merged.Env, env)
+		}
+	}
+	return &merged
+}
+
 // Create creates a new container from the given configuration with a given name.
 func (daemon *Daemon) Create(config *runconfig.Config, name string) (*Container, []string, error) {
 	var (
@@ -68,6 +116,10 @@ func (daemon *Daemon) Create(config *runconfig.Config, name string) (*Container,
 	if err := img.CheckDepth(); err != nil {
 		return nil, nil, err
 	}
+	// Refuse images of another platform rather than failing to exec them
+	if err := img.CheckPlatform(); err != nil {
+		return nil, nil, err
+	}
 	if warnings, err = daemon.mergeAndVerifyConfig(config, img); err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestMergeDefaults(t *testing.T) {
+	daemon := &Daemon{config: &Config{
+		DefaultLabels: []string{"team=infra", "env=prod"},
+		DefaultEnv:    []string{"TZ=UTC", "LANG=C"},
+	}}
+	config := &runconfig.Config{
+		Labels: map[string]string{"env": "dev"},
+		Env:    []string{"LANG=en_US.UTF-8"},
+	}
+
+	merged := daemon.mergeDefaults(config)
+	if expected := map[string]string{"env": "dev", "team": "infra"}; !reflect.DeepEqual(merged.Labels, expected) {
+		t.Fatalf("Expected the labels %v, got %v", expected, merged.Labels)
+	}
+	if expected := []string{"LANG=en_US.UTF-8", "TZ=UTC"}; !reflect.DeepEqual(merged.Env, expected) {
+		t.Fatalf("Expected the environment %v, got %v", expected, merged.Env)
+	}
+
+	// The builder commits its config, the defaults must stay out of it
+	if len(config.Labels) != 1 || len(config.Env) != 1 {
+		t.Fatalf("Expected the config to be left untouched, got %v and %v", config.Labels, config.Env)
+	}
+}
@@ -20,6 +20,10 @@ import (
 	"github.com/docker/docker/daemon/execdriver/lxc"
 	"github.com/docker/docker/daemon/graphdriver"
 	_ "github.com/docker/docker/daemon/graphdriver/vfs"
+	"github.com/docker/docker/daemon/logger"
+	_ "github.com/docker/docker/daemon/logger/fluentd"
+	_ "github.com/docker/docker/daemon/logger/gelf"
+	"github.com/docker/docker/daemon/logger/jsonfilelog"
 	_ "github.com/docker/docker/daemon/networkdriver/bridge"
 	"github.com/docker/docker/daemon/networkdriver/portallocator"
 	"github.com/docker/docker/dockerversion"
@@ -29,17 +33,22 @@ import (
 	"github.com/docker/docker/pkg/broadcastwriter"
 	"github.com/docker/docker/pkg/graphdb"
 	"github.com/docker/docker/pkg/log"
-	"github.com/docker/docker/pkg/namesgenerator"
 	"github.com/docker/docker/pkg/networkfs/resolvconf"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/kernel"
 	"github.com/docker/docker/pkg/sysinfo"
 	"github.com/docker/docker/pkg/truncindex"
+	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 )
 
+// ServiceName is the name the daemon is registered under in the engine.
+const ServiceName = "daemon"
+
 var (
+	daemonLog = log.New("daemon")
+
 	DefaultDns                = []string{"8.8.8.8", "8.8.4.4"}
 	validContainerNameChars   = `[a-zA-Z0-9_.-]`
 	validContainerNamePattern = regexp.MustCompile(`^/?` + validContainerNameChars + `+$`)
@@ -89,11 +98,19 @@ type Daemon struct {
 	idIndex        *truncindex.TruncIndex
 	sysInfo        *sysinfo.SysInfo
 	volumes        *graph.Graph
+	volumeStore    *VolumeStore
+	uploads        *UploadStore
 	eng            *engine.Engine
 	config         *Config
 	containerGraph *graphdb.Database
 	driver         graphdriver.Driver
 	execDriver     execdriver.Driver
+	logOpts        map[string]string
+	logDiskMax     int64
+	gpuProfile     *GpuProfile
+	seccompAuditor *seccompAuditor
+	names          *namesGenerator
+	started        time.Time
 }
 
 // Install installs daemon capabilities to eng.
@@ -103,28 +120,47 @@ func (daemon *Daemon) Install(eng *engine.Engine) error {
 	// FIXME: rename ContainerDestroy to ContainerRm for consistency with the CLI command
 	// FIXME: remove ImageDelete's dependency on Daemon, then move to graph/
 	for name, method := range map[string]engine.Handler{
-		"attach":            daemon.ContainerAttach,
-		"build":             daemon.CmdBuild,
-		"commit":            daemon.ContainerCommit,
-		"container_changes": daemon.ContainerChanges,
-		"container_copy":    daemon.ContainerCopy,
-		"container_inspect": daemon.ContainerInspect,
-		"containers":        daemon.Containers,
-		"create":            daemon.ContainerCreate,
-		"delete":            daemon.ContainerDestroy,
-		"export":            daemon.ContainerExport,
-		"info":              daemon.CmdInfo,
-		"kill":              daemon.ContainerKill,
-		"logs":              daemon.ContainerLogs,
-		"pause":             daemon.ContainerPause,
-		"resize":            daemon.ContainerResize,
-		"restart":           daemon.ContainerRestart,
-		"start":             daemon.ContainerStart,
-		"stop":              daemon.ContainerStop,
-		"top":               daemon.ContainerTop,
-		"unpause":           daemon.ContainerUnpause,
-		"wait":              daemon.ContainerWait,
-		"image_delete":      daemon.ImageDelete, // FIXME: see above
+		"attach":              daemon.ContainerAttach,
+		"build":               daemon.CmdBuild,
+		"build_batch":         daemon.CmdBuildBatch,
+		"commit":              daemon.ContainerCommit,
+		"container_changes":   daemon.ContainerChanges,
+		"container_copy":      daemon.ContainerCopy,
+		"container_inspect":   daemon.ContainerInspect,
+		"container_log_usage": daemon.ContainerLogUsage,
+		"containers":          daemon.Containers,
+		"create":              daemon.ContainerCreate,
+		"delete":              daemon.ContainerDestroy,
+		"exec":                daemon.ContainerExec,
+		"export":              daemon.ContainerExport,
+		"info":                daemon.CmdInfo,
+		"kill":                daemon.ContainerKill,
+		"logs":                daemon.ContainerLogs,
+		"log_levels":          daemon.LogLevels,
+		"pause":               daemon.ContainerPause,
+		"resize":              daemon.ContainerResize,
+		"restart":             daemon.ContainerRestart,
+		"start":               daemon.ContainerStart,
+		"stats":               daemon.ContainerStats,
+		"stop":                daemon.ContainerStop,
+		"top":                 daemon.ContainerTop,
+		"unpause":             daemon.ContainerUnpause,
+		"volume_create":       daemon.VolumeCreate,
+		"volume_inspect":      daemon.VolumeInspect,
+		"volume_ls":           daemon.VolumeList,
+		"volume_rm":           daemon.VolumeRm,
+		"volumes_prune":       daemon.VolumesPrune,
+		"wait":                daemon.ContainerWait,
+		"image_delete":        daemon.ImageDelete, // FIXME: see above
+		"images_gc":           daemon.ImagesGC,
+		"images_usage":        daemon.ImagesUsage,
+		"graph_migrate":       daemon.GraphMigrate,
+		"graph_fsck":          daemon.GraphFsck,
+		"daemon_health":       daemon.CmdDaemonHealth,
+		"upload_create":       daemon.UploadCreate,
+		"upload_append":       daemon.UploadAppend,
+		"upload_inspect":      daemon.UploadInspect,
+		"upload_rm":           daemon.UploadRm,
 	} {
 		if err := eng.Register(name, method); err != nil {
 			return err
@@ -133,11 +169,9 @@ func (daemon *Daemon) Install(eng *engine.Engine) error {
 	if err := daemon.Repositories().Install(eng); err != nil {
 		return err
 	}
-	// FIXME: this hack is necessary for legacy integration tests to access
-	// the daemon object.
-	//实现向 eng 对象中类型为 map hack 对象中添加一条记录，键为 httpapi.daemon ，值为 daemon
-	eng.Hack_SetGlobalVar("httpapi.daemon", daemon)
-	return nil
+	// The legacy integration tests access the daemon object through it.
+	//实现向 eng 对象中注册名为 daemon 的服务，值为 daemon
+	return eng.RegisterService(ServiceName, daemon)
 }
 
 // Get looks for a container by the specified ID or name, and returns it.
@@ -187,12 +221,14 @@ func (daemon *Daemon) Register(container *Container) error {
 
 // register makes a container object usable by the daemon as <container.ID>
 func (daemon *Daemon) register(container *Container, updateSuffixarray bool) error {
-	if container.daemon != nil || daemon.Exists(container.ID) {
+	// the containers restored are not indexed yet
+	if container.daemon != nil || daemon.containers.Get(container.ID) != nil || daemon.Exists(container.ID) {
 		return fmt.Errorf("Container is already loaded")
 	}
 	if err := validateID(container.ID); err != nil {
 		return err
 	}
+	generatedName := container.Name == ""
 	if err := daemon.ensureName(container); err != nil {
 		return err
 	}
@@ -213,13 +249,22 @@ func (daemon *Daemon) register(container *Container, updateSuffixarray bool) err
 
 	// don't update the Suffixarray if we're starting up
 	// we'll waste time if we update it for every container
-	daemon.idIndex.Add(container.ID)
+	if updateSuffixarray {
+		if err := daemon.idIndex.Add(container.ID); err != nil {
+			daemon.containers.Delete(container.ID)
+			container.daemon = nil
+			if generatedName {
+				daemon.releaseName(container)
+			}
+			return err
+		}
+	}
 
 	// FIXME: if the container is supposed to be running but is not, auto restart it?
 	//        if so, then we need to restart monitor and init a new lock
 	// If the container is supposed to be running, make sure of it
 	if container.State.IsRunning() {
-		log.Debugf("killing old running container %s", container.ID)
+		daemonLog.Debugf("killing old running container %s", container.ID)
 
 		existingPid := container.State.Pid
 		container.State.SetStopped(0)
@@ -236,26 +281,31 @@ func (daemon *Daemon) register(container *Container, updateSuffixarray bool) err
 			var err error
 			cmd.Process, err = os.FindProcess(existingPid)
 			if err != nil {
-				log.Debugf("cannot find existing process for %d", existingPid)
+				daemonLog.Debugf("cannot find existing process for %d", existingPid)
 			}
 			daemon.execDriver.Terminate(cmd)
 		}
 
 		if err := container.Unmount(); err != nil {
-			log.Debugf("unmount error %s", err)
+			daemonLog.Debugf("unmount error %s", err)
 		}
 		if err := container.ToDisk(); err != nil {
-			log.Debugf("saving stopped state to disk %s", err)
+			daemonLog.Debugf("saving stopped state to disk %s", err)
 		}
 
 		info := daemon.execDriver.Info(container.ID)
 		if !info.IsRunning() {
-			log.Debugf("Container %s was supposed to be running but is not.", container.ID)
+			daemonLog.Debugf("Container %s was supposed to be running but is not.", container.ID)
 
-			log.Debugf("Marking as stopped")
+			daemonLog.Debugf("Marking as stopped")
 
 			container.State.SetStopped(-127)
 			if err := container.ToDisk(); err != nil {
+				daemon.unregister(container)
+				container.daemon = nil
+				if generatedName {
+					daemon.releaseName(container)
+				}
 				return err
 			}
 		}
@@ -263,6 +313,15 @@ func (daemon *Daemon) register(container *Container, updateSuffixarray bool) err
 	return nil
 }
 
+// unregister reverts a successful register, removing the container from
+// the container store and the ID index so its prefix no longer resolves.
+func (daemon *Daemon) unregister(container *Container) {
+	if err := daemon.idIndex.Delete(container.ID); err != nil {
+		daemonLog.Debugf("Unable to remove container %s from the ID index: %s", container.ID, err)
+	}
+	daemon.containers.Delete(container.ID)
+}
+
 func (daemon *Daemon) ensureName(container *Container) error {
 	if container.Name == "" {
 		name, err := daemon.generateNewName(container.ID)
@@ -272,19 +331,19 @@ func (daemon *Daemon) ensureName(container *Container) error {
 		container.Name = name
 
 		if err := container.ToDisk(); err != nil {
-			log.Debugf("Error saving container name %s", err)
+			daemonLog.Debugf("Error saving container name %s", err)
 		}
 	}
 	return nil
 }
 
-func (daemon *Daemon) LogToDisk(src *broadcastwriter.BroadcastWriter, dst, stream string) error {
-	log, err := os.OpenFile(dst, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
-	if err != nil {
-		return err
+// releaseName frees the name ensureName generated for a container whose
+// registration failed afterwards, so the name can be given again.
+func (daemon *Daemon) releaseName(container *Container) {
+	if err := daemon.containerGraph.Delete(container.Name); err != nil {
+		daemonLog.Debugf("Unable to release the name %s of container %s: %s", container.Name, container.ID, err)
 	}
-	src.AddWriter(log, stream)
-	return nil
+	container.Name = ""
 }
 
 //重载容器
@@ -296,7 +355,7 @@ func (daemon *Daemon) restore() error {
 	)
 
 	if !debug {
-		log.Infof("Loading containers: ")
+		daemonLog.Infof("Loading containers: ")
 	}
 	dir, err := ioutil.ReadDir(daemon.repository)
 	if err != nil {
@@ -310,21 +369,24 @@ func (daemon *Daemon) restore() error {
 			fmt.Print(".")
 		}
 		if err != nil {
-			log.Errorf("Failed to load container %v: %v", id, err)
+			daemonLog.Errorf("Failed to load container %v: %v", id, err)
 			continue
 		}
 
 		// Ignore the container if it does not support the current driver being used by the graph
 		if (container.Driver == "" && currentDriver == "aufs") || container.Driver == currentDriver {
-			log.Debugf("Loaded container %v", container.ID)
+			daemonLog.Debugf("Loaded container %v", container.ID)
 
 			containers[container.ID] = container
 		} else {
-			log.Debugf("Cannot load container %s because it was created with another graph driver.", container.ID)
+			daemonLog.Debugf("Cannot load container %s because it was created with another graph driver.", container.ID)
 		}
 	}
 
-	registeredContainers := []*Container{}
+	var (
+		registeredContainers = []*Container{}
+		ids                  []string
+	)
 
 	if entities := daemon.containerGraph.List("/", -1); entities != nil {
 		for _, p := range entities.Paths() {
@@ -336,7 +398,9 @@ func (daemon *Daemon) restore() error {
 
 			if container, ok := containers[e.ID()]; ok {
 				if err := daemon.register(container, false); err != nil {
-					log.Debugf("Failed to register container %s: %s", container.ID, err)
+					daemonLog.Debugf("Failed to register container %s: %s", container.ID, err)
+				} else {
+					ids = append(ids, container.ID)
 				}
 
 				registeredContainers = append(registeredContainers, container)
@@ -352,37 +416,43 @@ func (daemon *Daemon) restore() error {
 		// Try to set the default name for a container if it exists prior to links
 		container.Name, err = daemon.generateNewName(container.ID)
 		if err != nil {
-			log.Debugf("Setting default id - %s", err)
+			daemonLog.Debugf("Setting default id - %s", err)
 		}
 
 		if err := daemon.register(container, false); err != nil {
-			log.Debugf("Failed to register container %s: %s", container.ID, err)
+			daemonLog.Debugf("Failed to register container %s: %s", container.ID, err)
+		} else {
+			ids = append(ids, container.ID)
 		}
 
 		registeredContainers = append(registeredContainers, container)
 	}
 
+	// the ids of the containers registered are indexed at once
+	if err := daemon.idIndex.AddBatch(ids); err != nil {
+		return err
+	}
+
 	// check the restart policy on the containers and restart any container with
 	// the restart policy of "always"
 	if daemon.config.AutoRestart {
-		log.Debugf("Restarting containers...")
+		daemonLog.Debugf("Restarting containers...")
 
 		for _, container := range registeredContainers {
 			if container.hostConfig.RestartPolicy.Name == "always" ||
 				(container.hostConfig.RestartPolicy.Name == "on-failure" && container.State.ExitCode != 0) {
-				log.Debugf("Starting container %s", container.ID)
+				daemonLog.Debugf("Starting container %s", container.ID)
 
 				if err := container.Start(); err != nil {
-					log.Debugf("Failed to start container %s: %s", container.ID, err)
+					daemonLog.Debugf("Failed to start container %s: %s", container.ID, err)
 				}
 			}
 		}
 	}
 
 	if !debug {
-		log.Infof(": done.")
+		daemonLog.Infof(": done.")
 	}
-
 	return nil
 }
 
@@ -472,7 +542,7 @@ func (daemon *Daemon) reserveName(id, name string) (string, error) {
 func (daemon *Daemon) generateNewName(id string) (string, error) {
 	var name string
 	for i := 0; i < 6; i++ {
-		name = namesgenerator.GetRandomName(i)
+		name = daemon.names.Name(i)
 		if name[0] != '/' {
 			name = "/" + name
 		}
@@ -483,6 +553,9 @@ func (daemon *Daemon) generateNewName(id string) (string, error) {
 			}
 			continue
 		}
+		if err := daemon.names.saveCounter(); err != nil {
+			daemonLog.Errorf("Error saving the names counter: %s", err)
+		}
 		return name, nil
 	}
 
@@ -538,6 +611,7 @@ func (daemon *Daemon) newContainer(name string, config *runconfig.Config, img *i
 		Config:          config,
 		hostConfig:      &runconfig.HostConfig{},
 		Image:           img.ID, // Always use the resolved image id
+		ImageDigest:     img.Digest(),
 		NetworkSettings: &NetworkSettings{},
 		Name:            name,
 		Driver:          daemon.driver.String(),
@@ -559,7 +633,7 @@ func (daemon *Daemon) createRootfs(container *Container, img *image.Image) error
 		return err
 	}
 	initID := fmt.Sprintf("%s-init", container.ID)
-	if err := daemon.driver.Create(initID, img.ID); err != nil {
+	if err := daemon.createRwLayer(initID, img.ID); err != nil {
 		return err
 	}
 	initPath, err := daemon.driver.Get(initID, "")
@@ -572,12 +646,21 @@ func (daemon *Daemon) createRootfs(container *Container, img *image.Image) error
 		return err
 	}
 
-	if err := daemon.driver.Create(container.ID, initID); err != nil {
+	if err := daemon.createRwLayer(container.ID, initID); err != nil {
 		return err
 	}
 	return nil
 }
 
+// createRwLayer creates a layer of a container, under the read-write layers
+// root of the driver when it has one.
+func (daemon *Daemon) createRwLayer(id, parent string) error {
+	if rwDriver, ok := daemon.driver.(graphdriver.RwLayersDriver); ok {
+		return rwDriver.CreateRw(id, parent)
+	}
+	return daemon.driver.Create(id, parent)
+}
+
 func GetFullContainerName(name string) (string, error) {
 	if name == "" {
 		return "", fmt.Errorf("Container name cannot be empty")
@@ -689,6 +772,47 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	if !config.EnableIptables && !config.InterContainerCommunication {
 		return nil, fmt.Errorf("You specified --iptables=false with --icc=false. ICC uses iptables to function. Please set --icc or --iptables to true.")
 	}
+	if config.LogDriver == "" {
+		config.LogDriver = jsonfilelog.Name
+	}
+	if _, err := logger.GetLogDriver(config.LogDriver); err != nil {
+		return nil, err
+	}
+	logOpts := make(map[string]string)
+	for _, opt := range config.LogOpts {
+		k, v, err := parsers.ParseKeyValueOpt(opt)
+		if err != nil {
+			return nil, err
+		}
+		logOpts[k] = v
+	}
+	if err := logger.ValidateLogOpts(config.LogDriver, logOpts); err != nil {
+		return nil, err
+	}
+	if config.MaxConcurrentDownloads <= 0 || config.MaxConcurrentUploads <= 0 {
+		return nil, fmt.Errorf("--max-concurrent-downloads and --max-concurrent-uploads must be positive")
+	}
+	var logDiskMax int64
+	if config.LogDiskMax != "" {
+		size, err := units.RAMInBytes(config.LogDiskMax)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --log-disk-max %s: %s", config.LogDiskMax, err)
+		}
+		logDiskMax = size
+	}
+	gpuProfile, err := loadGpuProfile(config.GpuProfile)
+	if err != nil {
+		return nil, err
+	}
+	names, err := newNamesGenerator(config)
+	if err != nil {
+		return nil, err
+	}
+	idGenerator, err := utils.NewIDGenerator(config.IDGenerator)
+	if err != nil {
+		return nil, err
+	}
+	utils.SetIDGenerator(idGenerator)
 	//处理网络功能配置
 	// FIXME: DisableNetworkBidge doesn't need to be public anymore
 	config.DisableNetwork = config.BridgeIface == DisableNetworkBridge
@@ -710,24 +834,24 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	//检测系统支持及用户极限
 	// FIXME: return errors instead of calling Fatal
 	if runtime.GOOS != "linux" { //通过 runtime.GOOS 检测操作系统的类型。
-		log.Fatalf("The Docker daemon is only supported on linux")
+		daemonLog.Fatalf("The Docker daemon is only supported on linux")
 	}
 	if os.Geteuid() != 0 { //检测程序用户是否拥有足够权限。（0代表root）
-		log.Fatalf("The Docker daemon needs to be run as root")
+		daemonLog.Fatalf("The Docker daemon needs to be run as root")
 	}
 	if err := checkKernelAndArch(); err != nil { //检测内核的版本以及主机处理器类型。
-		log.Fatalf(err.Error())
+		daemonLog.Fatalf(err.Error())
 	}
 
 	// set up the TempDir to use a canonical path
 	//配置工作路径
 	tmp, err := utils.TempDir(config.Root)
 	if err != nil {
-		log.Fatalf("Unable to get the TempDir under %s: %s", config.Root, err)
+		daemonLog.Fatalf("Unable to get the TempDir under %s: %s", config.Root, err)
 	}
 	realTmp, err := utils.ReadSymlinkedDirectory(tmp)
 	if err != nil {
-		log.Fatalf("Unable to get the full path to the TempDir (%s): %s", tmp, err)
+		daemonLog.Fatalf("Unable to get the full path to the TempDir (%s): %s", tmp, err)
 	}
 	os.Setenv("TMPDIR", realTmp)
 	if !config.EnableSelinuxSupport {
@@ -741,7 +865,7 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	} else {
 		realRoot, err = utils.ReadSymlinkedDirectory(config.Root)
 		if err != nil {
-			log.Fatalf("Unable to get the full path to root (%s): %s", config.Root, err)
+			daemonLog.Fatalf("Unable to get the full path to root (%s): %s", config.Root, err)
 		}
 	}
 	config.Root = realRoot
@@ -759,7 +883,19 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("Using graph driver %s", driver)
+	daemonLog.Debugf("Using graph driver %s", driver)
+
+	// The read-write layers of the containers can be stored on another
+	// filesystem than the images
+	if config.RwLayersRoot != "" {
+		rwDriver, ok := driver.(graphdriver.RwLayersDriver)
+		if !ok {
+			return nil, fmt.Errorf("The %s graph driver can't store the read-write layers apart from the images", driver)
+		}
+		if err := rwDriver.SetRwLayersRoot(path.Join(config.RwLayersRoot, driver.String())); err != nil {
+			return nil, err
+		}
+	}
 
 	// As Docker on btrfs and SELinux are incompatible at present, error on both being enabled
 	//由于目前在 btrfs 文件系统上运行的 Docker 不兼容 SELinux ，因此当 config 中配置信息
@@ -781,7 +917,7 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		return nil, err
 	}
 
-	log.Debugf("Creating images graph")
+	daemonLog.Debugf("Creating images graph")
 	//通过 Docker root 目录以及 graphdriver 实例，实例化
 	//一个全新的 graph 对象，用以管理在文件系统中 Docker root 路径下 graph 目录的内容。
 	g, err := graph.NewGraph(path.Join(config.Root, "graph"), driver)
@@ -796,17 +932,31 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("Creating volumes graph")
+	daemonLog.Debugf("Creating volumes graph")
 	//主要完成工作为:使用 vfs 这种类型的 driver 创建 volumesDriver ;在 Docker root 径下创建 volumes 目录，并返回 volumes 这个 graph 对象实例。
 	volumes, err := graph.NewGraph(path.Join(config.Root, "volumes"), volumesDriver)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("Creating repository list")
+	volumeStore, err := NewVolumeStore(path.Join(config.Root, "named-volumes"), volumes)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create volume store: %s", err)
+	}
+	uploads, err := NewUploadStore(path.Join(config.Root, "uploads"))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create upload store: %s", err)
+	}
+	daemonLog.Debugf("Creating repository list")
 
 	//TagStore 主要是用于管理存储镜像的仓库列表 (repository list)
 
-	repositories, err := graph.NewTagStore(path.Join(config.Root, "repositories-"+driver.String()), g)
+	repositories, err := graph.NewTagStore(path.Join(config.Root, "repositories-"+driver.String()), g, &graph.TagStoreConfig{
+		InsecureRegistries:     config.InsecureRegistries,
+		MaxConcurrentDownloads: config.MaxConcurrentDownloads,
+		MaxConcurrentUploads:   config.MaxConcurrentUploads,
+		LayerCompression:       config.LayerCompression,
+		LayerCompressionLevel:  config.LayerCompressionLevel,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't create Tag store: %s", err)
 	}
@@ -876,13 +1026,21 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		idIndex:        truncindex.NewTruncIndex([]string{}),       //用于通过简短有效的字符串前缀定位唯一的镜像
 		sysInfo:        sysInfo,                                    //系统功能信息
 		volumes:        volumes,                                    //管理宿主机上 volumes 内容的 graphdriver ，默认为 vfs 类型
+		volumeStore:    volumeStore,                                //记录命名数据卷的对象
+		uploads:        uploads,                                    //断点续传的上传，以 id 索引
 		config:         config,                                     //Config.go 文件中的配置信息，以及执行后产生的配置 DisableNetwork
 		containerGraph: graph,                                      //存放 Docker 镜像关系的 graphdb
 		driver:         driver,                                     //管理 Docker 镜像的驱动 graphdriver ，默认为 au也类型
 		sysInitPath:    sysInitPath,                                //系统 dockerinit 二进制文件所在的路径
 		execDriver:     ed,                                         //Docker Daemon exec 驱动，默认为 nalive 类型
 		eng:            eng,                                        //Docker 的执行引擎 Engine 类型
+		logOpts:        logOpts,                                    //默认日志驱动的选项
+		logDiskMax:     logDiskMax,                                 //容器日志占用磁盘空间的上限，0 表示不限制
+		gpuProfile:     gpuProfile,                                 //以 --gpus 创建的容器访问 GPU 的配置
+		names:          names,                                      //生成未命名容器的名称
+		started:        time.Now(),                                 //Docker Daemon 的启动时间
 	}
+	daemon.seccompAuditor = newSeccompAuditor(daemon) //将审计模式下容器被记录的系统调用转为事件
 	//检测Docker 运行环境中 DNS 的配置，
 	if err := daemon.checkLocaldns(); err != nil {
 		return nil, err
@@ -891,6 +1049,27 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	if err := daemon.restore(); err != nil {
 		return nil, err
 	}
+	if config.Fsck || config.FsckRepair {
+		if err := daemon.fsckGraph(config.FsckRepair); err != nil {
+			return nil, err
+		}
+	}
+	if daemon.logDiskMax > 0 {
+		stop := make(chan struct{})
+		go daemon.enforceLogDiskMax(stop)
+		eng.OnShutdown(func() {
+			close(stop)
+		})
+	}
+	if config.VolumesGCInterval > 0 {
+		go daemon.reapVolumes(config.VolumesGCInterval)
+	}
+	if config.ImagesGCInterval > 0 {
+		go daemon.reapImages(config.ImagesGCInterval, &ImagesGCPolicy{
+			MaxAge:   config.ImagesGCMaxAge,
+			KeepTags: config.ImagesGCKeepTags,
+		})
+	}
 	// Setup shutdown handlers
 	// FIXME: can these shutdown handlers be registered closer to their source?
 	eng.OnShutdown(func() {
@@ -898,16 +1077,19 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 		// them as separate handlers to speed up total shutdown time
 		// FIXME: use engine logging instead of log.Errorf
 		if err := daemon.shutdown(); err != nil { //做 daemon 方面的善后工作。
-			log.Errorf("daemon.shutdown(): %s", err)
+			daemonLog.Errorf("daemon.shutdown(): %s", err)
+		}
+		if err := daemon.volumeStore.UnmountQuotas(); err != nil {
+			daemonLog.Errorf("daemon.volumeStore.UnmountQuotas(): %s", err)
 		}
 		if err := portallocator.ReleaseAll(); err != nil { //释放所有之前占用的端口资源。
-			log.Errorf("portallocator.ReleaseAll(): %s", err)
+			daemonLog.Errorf("portallocator.ReleaseAll(): %s", err)
 		}
 		if err := daemon.driver.Cleanup(); err != nil { //通过 graphdriver 实现 unmount 所有有关镜像 layer 的挂载点。
-			log.Errorf("daemon.driver.Cleanup(): %s", err.Error())
+			daemonLog.Errorf("daemon.driver.Cleanup(): %s", err.Error())
 		}
 		if err := daemon.containerGraph.Close(); err != nil { //通过 daemon.containerGraph.CloseO 关闭 graphdb 的连接。
-			log.Errorf("daemon.containerGraph.Close(): %s", err.Error())
+			daemonLog.Errorf("daemon.containerGraph.Close(): %s", err.Error())
 		}
 	})
 
@@ -916,20 +1098,20 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 
 func (daemon *Daemon) shutdown() error {
 	group := sync.WaitGroup{}
-	log.Debugf("starting clean shutdown of all containers...")
+	daemonLog.Debugf("starting clean shutdown of all containers...")
 	for _, container := range daemon.List() {
 		c := container
 		if c.State.IsRunning() {
-			log.Debugf("stopping %s", c.ID)
+			daemonLog.Debugf("stopping %s", c.ID)
 			group.Add(1)
 
 			go func() {
 				defer group.Done()
 				if err := c.KillSig(15); err != nil {
-					log.Debugf("kill 15 error for %s - %s", c.ID, err)
+					daemonLog.Debugf("kill 15 error for %s - %s", c.ID, err)
 				}
 				c.State.WaitStop(-1 * time.Second)
-				log.Debugf("container stopped %s", c.ID)
+				daemonLog.Debugf("container stopped %s", c.ID)
 			}()
 		}
 	}
@@ -1099,35 +1281,20 @@ func (daemon *Daemon) checkLocaldns() error {
 		return err
 	}
 	if len(daemon.config.Dns) == 0 && utils.CheckLocalDns(resolvConf) {
-		log.Infof("Local (127.0.0.1) DNS resolver found in resolv.conf and containers can't use it. Using default external servers : %v", DefaultDns)
+		daemonLog.Infof("Local (127.0.0.1) DNS resolver found in resolv.conf and containers can't use it. Using default external servers : %v", DefaultDns)
 		daemon.config.Dns = DefaultDns
 	}
 	return nil
 }
 
 func (daemon *Daemon) ImageGetCached(imgID string, config *runconfig.Config) (*image.Image, error) {
-	// Retrieve all images
-	images, err := daemon.Graph().Map()
+	// Loop on the children of the given image and check the config
+	children, err := daemon.Graph().Children(imgID)
 	if err != nil {
 		return nil, err
 	}
-
-	// Store the tree in a map of map (map[parentId][childId])
-	imageMap := make(map[string]map[string]struct{})
-	for _, img := range images {
-		if _, exists := imageMap[img.Parent]; !exists {
-			imageMap[img.Parent] = make(map[string]struct{})
-		}
-		imageMap[img.Parent][img.ID] = struct{}{}
-	}
-
-	// Loop on the children of the given image and check the config
 	var match *image.Image
-	for elem := range imageMap[imgID] {
-		img, err := daemon.Graph().Get(elem)
-		if err != nil {
-			return nil, err
-		}
+	for _, img := range children {
 		if runconfig.Compare(&img.ContainerConfig, config) {
 			if match == nil || match.Created.Before(img.Created) {
 				match = img
@@ -1151,11 +1318,11 @@ func checkKernelAndArch() error {
 	// the circumstances of pre-3.8 crashes are clearer.
 	// For details see http://github.com/docker/docker/issues/407
 	if k, err := kernel.GetKernelVersion(); err != nil {
-		log.Infof("WARNING: %s", err)
+		daemonLog.Infof("WARNING: %s", err)
 	} else {
 		if kernel.CompareKernelVersion(k, &kernel.KernelVersionInfo{Kernel: 3, Major: 8, Minor: 0}) < 0 {
 			if os.Getenv("DOCKER_NOWARN_KERNEL_VERSION") == "" {
-				log.Infof("WARNING: You are running linux kernel version %s, which might be unstable running docker. Please upgrade your kernel to 3.8.0.", k.String())
+				daemonLog.Infof("WARNING: You are running linux kernel version %s, which might be unstable running docker. Please upgrade your kernel to 3.8.0.", k.String())
 			}
 		}
 	}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/docker/engine"
+)
+
+// The states of the daemon reported by the daemon_health job.
+const (
+	daemonHealthy  = "healthy"
+	daemonDegraded = "degraded"
+)
+
+// CmdDaemonHealth reports the jobs running and the checks of the storage, of
+// the graphdb and of the bridge, each being "ok", "disabled" or the error.
+// The daemon is "degraded" while a check fails. The api only serves it once
+// the containers are restored, the connections being held until then.
+func (daemon *Daemon) CmdDaemonHealth(job *engine.Job) engine.Status {
+	checks := map[string]string{
+		"storage": checkResult(daemon.checkStorage()),
+		"graphdb": checkResult(daemon.containerGraph.Ping()),
+		"bridge":  "disabled",
+	}
+	if !daemon.config.DisableNetwork {
+		bridgeJob := job.Eng.Job("bridge_status")
+		bridge, err := bridgeJob.Stdout.AddEnv()
+		if err != nil {
+			return job.Error(err)
+		}
+		if err := bridgeJob.Run(); err != nil {
+			checks["bridge"] = err.Error()
+		} else {
+			checks["bridge"] = "ok"
+			if msg := bridge.Get("Error"); msg != "" {
+				checks["bridge"] = msg
+			}
+		}
+	}
+
+	state := daemonHealthy
+	for _, result := range checks {
+		if result != "ok" && result != "disabled" {
+			state = daemonDegraded
+		}
+	}
+
+	v := &engine.Env{}
+	v.Set("State", state)
+	// The daemon_health job is running too
+	v.SetInt("PendingJobs", job.Eng.PendingJobs()-1)
+	v.Set("Driver", daemon.GraphDriver().String())
+	v.SetJson("DriverStatus", daemon.GraphDriver().Status())
+	v.SetJson("Checks", checks)
+	if _, err := v.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// checkStorage checks the images are still there and the root of the daemon
+// can still be written to.
+func (daemon *Daemon) checkStorage() error {
+	if _, err := os.Stat(daemon.graph.Root); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(daemon.config.Root, ".health")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func checkResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/docker/docker/engine"
-	"github.com/docker/docker/pkg/log"
 )
 
 // FIXME: rename to ContainerRemove for consistency with the CLI command.
@@ -66,50 +65,16 @@ func (daemon *Daemon) ContainerDestroy(job *engine.Job) engine.Status {
 		container.LogEvent("destroy")
 
 		if removeVolume {
-			var (
-				volumes     = make(map[string]struct{})
-				binds       = make(map[string]struct{})
-				usedVolumes = make(map[string]*Container)
-			)
-
-			// the volume id is always the base of the path
-			getVolumeId := func(p string) string {
-				return filepath.Base(strings.TrimSuffix(p, "/layer"))
-			}
-
-			// populate bind map so that they can be skipped and not removed
-			for _, bind := range container.HostConfig().Binds {
-				source := strings.Split(bind, ":")[0]
-				// TODO: refactor all volume stuff, all of it
-				// it is very important that we eval the link or comparing the keys to container.Volumes will not work
-				//
-				// eval symlink can fail, ref #5244 if we receive an is not exist error we can ignore it
-				p, err := filepath.EvalSymlinks(source)
-				if err != nil && !os.IsNotExist(err) {
-					return job.Error(err)
-				}
-				if p != "" {
-					source = p
-				}
-				binds[source] = struct{}{}
-			}
-
-			// Store all the deleted containers volumes
-			for _, volumeId := range container.Volumes {
-				// Skip the volumes mounted from external
-				// bind mounts here will will be evaluated for a symlink
-				if _, exists := binds[volumeId]; exists {
-					continue
-				}
-
-				volumeId = getVolumeId(volumeId)
-				volumes[volumeId] = struct{}{}
+			volumes, err := daemon.removableVolumes(container)
+			if err != nil {
+				return job.Error(err)
 			}
+			usedVolumes := make(map[string]*Container)
 
 			// Retrieve all volumes from all remaining containers
 			for _, container := range daemon.List() {
 				for _, containerVolumeId := range container.Volumes {
-					containerVolumeId = getVolumeId(containerVolumeId)
+					containerVolumeId = volumeIDFromPath(containerVolumeId)
 					usedVolumes[containerVolumeId] = container
 				}
 			}
@@ -117,7 +82,7 @@ func (daemon *Daemon) ContainerDestroy(job *engine.Job) engine.Status {
 			for volumeId := range volumes {
 				// If the requested volu
 				if c, exists := usedVolumes[volumeId]; exists {
-					log.Infof("The volume %s is used by the container %s. Impossible to remove it. Skipping.", volumeId, c.ID)
+					daemonLog.Infof("The volume %s is used by the container %s. Impossible to remove it. Skipping.", volumeId, c.ID)
 					continue
 				}
 				if err := daemon.Volumes().Delete(volumeId); err != nil {
@@ -131,6 +96,58 @@ func (daemon *Daemon) ContainerDestroy(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
+// removableVolumes returns the IDs of the volumes removed with container,
+// leaving out the bind mounts and the named volumes. The named volumes
+// outlive their containers, however they got them, e.g. with --volumes-from
+// from a container already removed: they are removed with volume_rm.
+func (daemon *Daemon) removableVolumes(container *Container) (map[string]struct{}, error) {
+	var (
+		volumes = make(map[string]struct{})
+		binds   = make(map[string]struct{})
+		named   = make(map[string]struct{})
+	)
+	for _, v := range daemon.volumeStore.List() {
+		named[v.ID] = struct{}{}
+	}
+
+	// populate bind map so that they can be skipped and not removed
+	for _, bind := range container.HostConfig().Binds {
+		source := strings.Split(bind, ":")[0]
+		if !filepath.IsAbs(source) {
+			// a named volume
+			continue
+		}
+		// TODO: refactor all volume stuff, all of it
+		// it is very important that we eval the link or comparing the keys to container.Volumes will not work
+		//
+		// eval symlink can fail, ref #5244 if we receive an is not exist error we can ignore it
+		p, err := filepath.EvalSymlinks(source)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if p != "" {
+			source = p
+		}
+		binds[source] = struct{}{}
+	}
+
+	// Store all the deleted containers volumes
+	for _, volumeId := range container.Volumes {
+		// Skip the volumes mounted from external
+		// bind mounts here will will be evaluated for a symlink
+		if _, exists := binds[volumeId]; exists {
+			continue
+		}
+
+		volumeId = volumeIDFromPath(volumeId)
+		if _, exists := named[volumeId]; exists {
+			continue
+		}
+		volumes[volumeId] = struct{}{}
+	}
+	return volumes, nil
+}
+
 // Destroy unregisters a container from the daemon and cleanly removes its contents from the filesystem.
 // FIXME: rename to Rm for consistency with the CLI command
 func (daemon *Daemon) Destroy(container *Container) error {
@@ -148,11 +165,10 @@ func (daemon *Daemon) Destroy(container *Container) error {
 	}
 
 	// Deregister the container before removing its directory, to avoid race conditions
-	daemon.idIndex.Delete(container.ID)
-	daemon.containers.Delete(container.ID)
+	daemon.unregister(container)
 
 	if _, err := daemon.containerGraph.Purge(container.ID); err != nil {
-		log.Debugf("Unable to remove container from link graph: %s", err)
+		daemonLog.Debugf("Unable to remove container from link graph: %s", err)
 	}
 
 	if err := daemon.driver.Remove(container.ID); err != nil {
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/graph"
+	"github.com/docker/docker/runconfig"
+)
+
+func TestRemovableVolumes(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-volumes-rm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	driver, err := graphdriver.GetDriver("vfs", root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := graph.NewGraph(filepath.Join(root, "volumes"), driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewVolumeStore(filepath.Join(root, "named-volumes"), volumes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := &Daemon{
+		containers:  &contStore{s: make(map[string]*Container)},
+		volumes:     volumes,
+		volumeStore: store,
+	}
+
+	anonymous, err := volumes.Create(nil, "", "", "", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymousPath, err := driver.Get(anonymous.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	named, err := store.Create("data", 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namedPath, err := store.Path(named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindPath := filepath.Join(root, "bind")
+	if err := os.Mkdir(bindPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, binds := range [][]string{
+		{"data:/data", bindPath + ":/bind"},
+		// The named volume of a container given with --volumes-from, and
+		// not in its own binds, is kept all the same
+		{bindPath + ":/bind"},
+	} {
+		container := &Container{
+			Volumes:    map[string]string{"/anonymous": anonymousPath, "/data": namedPath, "/bind": bindPath},
+			hostConfig: &runconfig.HostConfig{Binds: binds},
+		}
+		removable, err := daemon.removableVolumes(container)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, exists := removable[anonymous.ID]; !exists || len(removable) != 1 {
+			t.Fatalf("Expected only the anonymous volume %s to be removed with the binds %v, got %v", anonymous.ID, binds, removable)
+		}
+	}
+}
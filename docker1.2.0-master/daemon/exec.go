@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"io"
+	"os"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/engine"
+)
+
+// ContainerExec runs a command in a running container, with the streams of
+// the job as its stdio, until it exits. The exit code of the command is set
+// as ExitCode in the env of the job.
+func (daemon *Daemon) ContainerExec(job *engine.Job) engine.Status {
+	if len(job.Args) < 2 {
+		return job.Errorf("Usage: %s CONTAINER COMMAND [ARG...]\n", job.Name)
+	}
+	name := job.Args[0]
+	container := daemon.Get(name)
+	if container == nil {
+		return job.Errorf("No such container: %s", name)
+	}
+	if !container.State.IsRunning() {
+		return job.Errorf("Container %s is not running", name)
+	}
+	execer, ok := daemon.execDriver.(execdriver.Execer)
+	if !ok {
+		return job.Errorf("The %s execution driver can't run commands in a container", daemon.execDriver.Name())
+	}
+
+	pipes := execdriver.NewPipes(nil, job.Stdout, job.Stderr, false)
+	if job.GetenvBool("stdin") {
+		// Given a file, the command doesn't wait for the end of the input
+		// once it exited
+		r, w, err := os.Pipe()
+		if err != nil {
+			return job.Error(err)
+		}
+		defer r.Close()
+		defer w.Close()
+		go func() {
+			if _, err := io.Copy(w, job.Stdin); err != nil {
+				daemonLog.Debugf("Error copying the input of the exec in %s: %s", container.ID, err)
+			}
+			w.Close()
+		}()
+		pipes.Stdin = r
+	}
+
+	daemonLog.Debugf("Exec in %s: %v", container.ID, job.Args[1:])
+	exitCode, err := execer.Exec(container.command, job.Args[1:], pipes, nil)
+	if err != nil {
+		return job.Error(err)
+	}
+	job.SetenvInt("ExitCode", exitCode)
+	return engine.StatusOK
+}
@@ -5,8 +5,13 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"syscall"
+	"time"
 
+	"github.com/docker/docker/pkg/ulimit"
+	"github.com/docker/libcontainer/cgroups"
 	"github.com/docker/libcontainer/devices"
+	"github.com/docker/libcontainer/security/seccomp"
 )
 
 // Context is a generic key value pair that allows
@@ -18,6 +23,7 @@ var (
 	ErrWaitTimeoutReached      = errors.New("Wait timeout reached")
 	ErrDriverAlreadyRegistered = errors.New("A driver already registered this docker init function")
 	ErrDriverNotFound          = errors.New("The requested docker init has not been found")
+	ErrNotSupported            = errors.New("Operation not supported by the execution driver")
 )
 
 type StartCallback func(*Command)
@@ -49,6 +55,53 @@ type Driver interface {
 	Info(id string) Info                          // "temporary" hack (until we move state from core to plugins)
 	GetPidsForContainer(id string) ([]int, error) // Returns a list of pids for the given container.
 	Terminate(c *Command) error                   // kill it with fire
+	Stats(id string) (*ResourceStats, error)      // Returns the metrics of the cgroups of the given container.
+	Capabilities() Capabilities                   // Returns the optional features of the driver.
+
+	// Checkpoint saves the state of the processes of the running container
+	// under opts.ImagesDirectory, stopping them unless opts.LeaveRunning.
+	Checkpoint(c *Command, opts *CheckpointOptions) error
+	// Restore runs the processes of a checkpoint of the container like Run,
+	// blocking until they exit and returning the exit code.
+	Restore(c *Command, pipes *Pipes, opts *CheckpointOptions, startCallback StartCallback) (int, error)
+}
+
+// CheckpointOptions are the options of the checkpoint and the restore of a
+// container
+type CheckpointOptions struct {
+	ImagesDirectory         string `json:"images_directory"` // the state of the processes
+	WorkDirectory           string `json:"work_directory"`   // the logs, the images directory by default
+	LeaveRunning            bool   `json:"leave_running"`    // keep the container running after the checkpoint
+	TcpEstablished          bool   `json:"tcp_established"`  // checkpoint the established TCP connections
+	ExternalUnixConnections bool   `json:"ext_unix_sk"`      // checkpoint the unix connections to the outside
+}
+
+// Capabilities are the optional features of a driver, for the clients to
+// detect rather than fail using them.
+type Capabilities struct {
+	Exec            bool // runs processes in running containers, implementing Execer
+	Pause           bool // freezes the processes of containers
+	Stats           bool // reports the metrics of the cgroups of containers
+	OOMNotification bool // calls the OOMCallback of the commands
+	Seccomp         bool // applies the seccomp filters of the commands
+	Rlimits         bool // applies the rlimits of the resources of the commands
+	Checkpoint      bool // checkpoints and restores containers
+}
+
+// ResourceStats are the cpu, memory and blkio metrics of the cgroups of a
+// running container, with the number of its processes
+type ResourceStats struct {
+	*cgroups.Stats
+	Read      time.Time `json:"read"`
+	Processes int       `json:"processes"`
+}
+
+// Execer is implemented by the drivers able to run another process in a
+// running container
+type Execer interface {
+	// Exec runs args in the container of c and blocks until the process
+	// exits, returning its exit code
+	Exec(c *Command, args []string, pipes *Pipes, startCallback func(*exec.Cmd)) (int, error)
 }
 
 // Network settings of the container
@@ -67,10 +120,11 @@ type NetworkInterface struct {
 }
 
 type Resources struct {
-	Memory     int64  `json:"memory"`
-	MemorySwap int64  `json:"memory_swap"`
-	CpuShares  int64  `json:"cpu_shares"`
-	Cpuset     string `json:"cpuset"`
+	Memory     int64            `json:"memory"`
+	MemorySwap int64            `json:"memory_swap"`
+	CpuShares  int64            `json:"cpu_shares"`
+	Cpuset     string           `json:"cpuset"`
+	Rlimits    []*ulimit.Rlimit `json:"rlimits"`
 }
 
 type Mount struct {
@@ -78,6 +132,10 @@ type Mount struct {
 	Destination string `json:"destination"`
 	Writable    bool   `json:"writable"`
 	Private     bool   `json:"private"`
+	// Options are applied to the mount by the driver: nosuid, nodev and
+	// noexec. The copy, nocopy, uid=UID and gid=GID options are applied by
+	// the daemon when the volume is created and are ignored.
+	Options []string `json:"options,omitempty"`
 }
 
 // Process wrapps an os/exec.Cmd to add more metadata
@@ -102,8 +160,10 @@ type Command struct {
 	AutoCreatedDevices []*devices.Device   `json:"autocreated_devices"`
 	CapAdd             []string            `json:"cap_add"`
 	CapDrop            []string            `json:"cap_drop"`
+	Seccomp            *seccomp.Config     `json:"seccomp"` // syscalls allowed in the container, nil allowing all
 
 	Terminal     Terminal `json:"-"`             // standard or tty terminal
+	OOMCallback  func()   `json:"-"`             // called when the container runs out of memory, if the driver can tell
 	Console      string   `json:"-"`             // dev/console path
 	ContainerPid int      `json:"container_pid"` // the pid for the process inside a container
 }
@@ -113,3 +173,29 @@ type Command struct {
 func (c *Command) Pid() int {
 	return c.ContainerPid
 }
+
+// ResourceUsage is the resource usage of the process of a container, and of
+// the processes it waited for, when it exited.
+type ResourceUsage struct {
+	UserTime   time.Duration // cpu time in user mode
+	SystemTime time.Duration // cpu time in kernel mode
+	MaxRss     int64         // maximum resident set size, in bytes
+}
+
+// Usage returns the resource usage of the process of the command, nil until
+// the driver waited for it.
+func (c *Command) Usage() *ResourceUsage {
+	if c.ProcessState == nil {
+		return nil
+	}
+	rusage, ok := c.ProcessState.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return nil
+	}
+	return &ResourceUsage{
+		UserTime:   c.ProcessState.UserTime(),
+		SystemTime: c.ProcessState.SystemTime(),
+		// in kilobytes on linux
+		MaxRss: rusage.Maxrss * 1024,
+	}
+}
@@ -21,12 +21,15 @@ import (
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/docker/utils"
 	"github.com/docker/libcontainer/cgroups"
+	"github.com/docker/libcontainer/cgroups/fs"
 	"github.com/docker/libcontainer/label"
 	"github.com/docker/libcontainer/mount/nodes"
 )
 
 const DriverName = "lxc"
 
+var driverLog = log.New("execdriver")
+
 type driver struct {
 	root       string // root path for the driver to use
 	initPath   string
@@ -53,12 +56,31 @@ func (d *driver) Name() string {
 	return fmt.Sprintf("%s-%s", DriverName, version)
 }
 
+func (d *driver) Capabilities() execdriver.Capabilities {
+	return execdriver.Capabilities{
+		Pause: true,
+		Stats: true,
+	}
+}
+
+func (d *driver) Checkpoint(c *execdriver.Command, opts *execdriver.CheckpointOptions) error {
+	return execdriver.ErrNotSupported
+}
+
+func (d *driver) Restore(c *execdriver.Command, pipes *execdriver.Pipes, opts *execdriver.CheckpointOptions, startCallback execdriver.StartCallback) (int, error) {
+	return -1, execdriver.ErrNotSupported
+}
+
 func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallback execdriver.StartCallback) (int, error) {
 	var (
 		term execdriver.Terminal
 		err  error
 	)
 
+	if c.Seccomp != nil {
+		return -1, fmt.Errorf("seccomp profiles are not supported by the lxc driver")
+	}
+
 	if c.Tty {
 		term, err = NewTtyConsole(c, pipes)
 	} else {
@@ -209,27 +231,24 @@ func (d *driver) Kill(c *execdriver.Command, sig int) error {
 }
 
 func (d *driver) Pause(c *execdriver.Command) error {
-	_, err := exec.LookPath("lxc-freeze")
-	if err == nil {
-		output, errExec := exec.Command("lxc-freeze", "-n", c.ID).CombinedOutput()
-		if errExec != nil {
-			return fmt.Errorf("Err: %s Output: %s", errExec, output)
-		}
-	}
-
-	return err
+	return d.freeze(c.ID, cgroups.Frozen)
 }
 
 func (d *driver) Unpause(c *execdriver.Command) error {
-	_, err := exec.LookPath("lxc-unfreeze")
-	if err == nil {
-		output, errExec := exec.Command("lxc-unfreeze", "-n", c.ID).CombinedOutput()
-		if errExec != nil {
-			return fmt.Errorf("Err: %s Output: %s", errExec, output)
-		}
-	}
+	return d.freeze(c.ID, cgroups.Thawed)
+}
 
-	return err
+// freeze sets the state of the freezer cgroup of the container directly,
+// lxc-freeze and lxc-unfreeze not being installed with every lxc version.
+func (d *driver) freeze(id string, state cgroups.FreezerState) error {
+	c, err := d.cgroup(id)
+	if err != nil {
+		return err
+	}
+	if err := fs.Freeze(c, state); err != nil {
+		return fmt.Errorf("Error setting the freezer state of %s to %s: %s", id, state, err)
+	}
+	return nil
 }
 
 func (d *driver) Terminate(c *execdriver.Command) error {
@@ -320,7 +339,7 @@ func (i *info) IsRunning() bool {
 
 	output, err := i.driver.getInfo(i.ID)
 	if err != nil {
-		log.Errorf("Error getting info for lxc container %s: %s (%s)", i.ID, err, output)
+		driverLog.Errorf("Error getting info for lxc container %s: %s (%s)", i.ID, err, output)
 		return false
 	}
 	if strings.Contains(string(output), "RUNNING") {
@@ -374,6 +393,47 @@ func (d *driver) GetPidsForContainer(id string) ([]int, error) {
 	return pids, nil
 }
 
+// cgroup returns the cgroup of the container, relative to the cgroups root.
+func (d *driver) cgroup(id string) (*cgroups.Cgroup, error) {
+	// cpu is chosen because it is the only non optional subsystem in cgroups
+	subsystem := "cpu"
+	cgroupRoot, err := cgroups.FindCgroupMountpoint(subsystem)
+	if err != nil {
+		return nil, err
+	}
+	cgroupDir, err := cgroups.GetThisCgroupDir(subsystem)
+	if err != nil {
+		return nil, err
+	}
+	parent := filepath.Join("/", cgroupDir)
+	if _, err := os.Stat(filepath.Join(cgroupRoot, parent, id)); os.IsNotExist(err) {
+		// With more recent lxc versions use, cgroup will be in lxc/
+		parent = filepath.Join(parent, "lxc")
+	}
+	return &cgroups.Cgroup{Parent: parent, Name: id}, nil
+}
+
+func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
+	c, err := d.cgroup(id)
+	if err != nil {
+		return nil, err
+	}
+	read := time.Now()
+	stats, err := fs.GetStats(c)
+	if err != nil {
+		return nil, err
+	}
+	pids, err := d.GetPidsForContainer(id)
+	if err != nil {
+		return nil, err
+	}
+	return &execdriver.ResourceStats{
+		Stats:     stats,
+		Read:      read,
+		Processes: len(pids),
+	}, nil
+}
+
 func linkLxcStart(root string) error {
 	sourcePath, err := exec.LookPath("lxc-start")
 	if err != nil {
@@ -425,7 +485,15 @@ func (d *driver) generateLXCConfig(c *execdriver.Command) (string, error) {
 		}
 	}
 
-	if err := LxcTemplateCompiled.Execute(fo, struct {
+	lxcVersion := d.version()
+	// The --lxc-conf options are appended to the config by the template
+	for _, opt := range c.Config["lxc"] {
+		if err := validateLxcKey("lxc."+strings.TrimSpace(strings.SplitN(opt, "=", 2)[0]), lxcVersion); err != nil {
+			return "", err
+		}
+	}
+
+	data := struct {
 		*execdriver.Command
 		AppArmor     bool
 		ProcessLabel string
@@ -435,9 +503,27 @@ func (d *driver) generateLXCConfig(c *execdriver.Command) (string, error) {
 		AppArmor:     d.apparmor,
 		ProcessLabel: process,
 		MountLabel:   mount,
-	}); err != nil {
+	}
+	if custom := c.Config["lxc_template"]; len(custom) > 0 {
+		tmpl, err := loadTemplate(custom[0])
+		if err != nil {
+			return "", err
+		}
+		if err := executeCustom(fo, tmpl, data, lxcVersion); err != nil {
+			return "", err
+		}
+	} else if err := LxcTemplateCompiled.Execute(fo, data); err != nil {
 		return "", err
 	}
+	if fragment := c.Config["lxc_fragment"]; len(fragment) > 0 {
+		tmpl, err := parseCustom("lxc template fragment", fragment[0])
+		if err != nil {
+			return "", err
+		}
+		if err := executeCustom(fo, tmpl, data, lxcVersion); err != nil {
+			return "", err
+		}
+	}
 	return root, nil
 }
 
@@ -1,10 +1,16 @@
 package lxc
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 	"text/template"
 
 	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/version"
 	"github.com/docker/libcontainer/label"
 )
 
@@ -113,7 +119,29 @@ lxc.{{$value}}
 {{end}}
 `
 
-var LxcTemplateCompiled *template.Template
+var (
+	LxcTemplateCompiled *template.Template
+
+	funcMap = template.FuncMap{
+		"getMemorySwap":     getMemorySwap,
+		"escapeFstabSpaces": escapeFstabSpaces,
+		"formatMountLabel":  label.FormatMountLabel,
+	}
+)
+
+// lxcKeyVersions are the lxc versions which introduced the config keys, or
+// the key prefixes ending with a dot, which older versions refuse.
+var lxcKeyVersions = map[string]version.Version{
+	"lxc.aa_profile": "0.8",
+	"lxc.hook.":      "0.8",
+	"lxc.seccomp":    "0.8",
+	"lxc.cap.keep":   "1.0",
+	"lxc.group":      "1.0",
+	"lxc.id_map":     "1.0",
+	"lxc.mount.auto": "1.0",
+	"lxc.se_context": "1.0",
+	"lxc.start.":     "1.0",
+}
 
 // Escape spaces in strings according to the fstab documentation, which is the
 // format for "lxc.mount.entry" lines in lxc.conf. See also "man 5 fstab".
@@ -141,13 +169,77 @@ func getLabel(c map[string][]string, name string) string {
 	return ""
 }
 
+// parseCustom parses the lxc template text of a user, with the functions
+// of the builtin one.
+func parseCustom(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcMap).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s", name, err)
+	}
+	return t, nil
+}
+
+// loadTemplate parses the lxc template at path, which replaces the builtin
+// one.
+func loadTemplate(path string) (*template.Template, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read lxc template: %s", err)
+	}
+	return parseCustom("lxc template "+path, string(data))
+}
+
+// executeCustom executes the template of a user, validating the lxc config
+// it results in for lxcVersion, and writes it to w.
+func executeCustom(w io.Writer, t *template.Template, data interface{}, lxcVersion string) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return fmt.Errorf("Invalid %s: %s", t.Name(), err)
+	}
+	if err := validateLxcConfig(buf.Bytes(), lxcVersion); err != nil {
+		return fmt.Errorf("Invalid %s: %s", t.Name(), err)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// validateLxcConfig checks every line of conf is a comment or a lxc.key = value
+// setting supported by lxcVersion, which is not checked if unknown.
+func validateLxcConfig(conf []byte, lxcVersion string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(conf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !strings.HasPrefix(key, "lxc.") {
+			return fmt.Errorf("%q is not a lxc.key = value setting", line)
+		}
+		if err := validateLxcKey(key, lxcVersion); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func validateLxcKey(key, lxcVersion string) error {
+	if lxcVersion == "" {
+		return nil
+	}
+	for prefix, since := range lxcKeyVersions {
+		if key == prefix || (strings.HasSuffix(prefix, ".") && strings.HasPrefix(key, prefix)) {
+			if version.Version(lxcVersion).LessThan(since) {
+				return fmt.Errorf("%s requires lxc %s, found %s", key, since, lxcVersion)
+			}
+		}
+	}
+	return nil
+}
+
 func init() {
 	var err error
-	funcMap := template.FuncMap{
-		"getMemorySwap":     getMemorySwap,
-		"escapeFstabSpaces": escapeFstabSpaces,
-		"formatMountLabel":  label.FormatMountLabel,
-	}
 	LxcTemplateCompiled, err = template.New("lxc").Funcs(funcMap).Parse(LxcTemplate)
 	if err != nil {
 		panic(err)
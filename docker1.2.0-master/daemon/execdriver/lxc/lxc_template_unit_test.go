@@ -101,6 +101,69 @@ func TestCustomLxcConfig(t *testing.T) {
 	grepFile(t, p, "lxc.cgroup.cpuset.cpus = 0,1")
 }
 
+func TestCustomLxcTemplate(t *testing.T) {
+	root, err := ioutil.TempDir("", "TestCustomLxcTemplate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	os.MkdirAll(path.Join(root, "containers", "1"), 0777)
+	tmpl := path.Join(root, "lxc.tmpl")
+	if err := ioutil.WriteFile(tmpl, []byte("lxc.rootfs = {{escapeFstabSpaces .Rootfs}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, err := NewDriver(root, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := &execdriver.Command{
+		ID:     "1",
+		Rootfs: "/var/lib/docker/my rootfs",
+		Config: map[string][]string{
+			"lxc_template": {tmpl},
+			"lxc_fragment": {"# custom\nlxc.utsname = {{.ID}}"},
+		},
+		Network: &execdriver.Network{
+			Mtu:       1500,
+			Interface: nil,
+		},
+	}
+
+	p, err := driver.generateLXCConfig(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grepFile(t, p, "lxc.rootfs = /var/lib/docker/my\\040rootfs")
+	grepFile(t, p, "lxc.utsname = 1")
+
+	command.Config["lxc_fragment"] = []string{"utsname = docker"}
+	if _, err := driver.generateLXCConfig(command); err == nil {
+		t.Fatal("Expected a fragment without the lxc. prefix to be refused")
+	}
+	command.Config["lxc_fragment"] = []string{"lxc.utsname = {{.Unknown}}"}
+	if _, err := driver.generateLXCConfig(command); err == nil {
+		t.Fatal("Expected a fragment with an unknown field to be refused")
+	}
+}
+
+func TestValidateLxcConfig(t *testing.T) {
+	conf := []byte("# comment\n\nlxc.mount.auto = proc sys\nlxc.start.auto = 1\n")
+	if err := validateLxcConfig(conf, "1.0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateLxcConfig(conf, ""); err != nil {
+		t.Fatalf("Expected an unknown version not to be checked, got %s", err)
+	}
+	if err := validateLxcConfig(conf, "0.9.0"); err == nil || !strings.Contains(err.Error(), "lxc.mount.auto requires lxc 1.0") {
+		t.Fatalf("Expected lxc.mount.auto to be refused by lxc 0.9, got %v", err)
+	}
+	if err := validateLxcConfig([]byte("lxc.utsname\n"), "1.0.5"); err == nil {
+		t.Fatal("Expected a line without a value to be refused")
+	}
+}
+
 func grepFile(t *testing.T, path string, pattern string) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -0,0 +1,274 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/system"
+)
+
+// descriptorsFile records, in the images of a checkpoint, what the standard
+// streams of the container were connected to, for the restore to connect
+// them to the new pipes.
+const descriptorsFile = "descriptors.json"
+
+// criuAvailable returns true if criu, which checkpoints and restores the
+// containers, is installed.
+func criuAvailable() bool {
+	_, err := exec.LookPath("criu")
+	return err == nil
+}
+
+func (d *driver) Checkpoint(c *execdriver.Command, opts *execdriver.CheckpointOptions) error {
+	if c.Tty {
+		return fmt.Errorf("Cannot checkpoint the container %s: its tty would be lost", c.ID)
+	}
+	if c.ContainerPid == 0 {
+		return fmt.Errorf("Cannot checkpoint the container %s: it is not running", c.ID)
+	}
+	if err := os.MkdirAll(opts.ImagesDirectory, 0700); err != nil {
+		return err
+	}
+	descriptors, err := stdioDescriptors(c.ContainerPid)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(descriptors)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(opts.ImagesDirectory, descriptorsFile), data, 0600); err != nil {
+		return err
+	}
+
+	args := append([]string{"dump", "--tree", strconv.Itoa(c.ContainerPid)}, criuArgs(c.Rootfs, "dump.log", opts)...)
+	if opts.LeaveRunning {
+		args = append(args, "--leave-running")
+	}
+	// the volumes are bind mounted again by the restore
+	for _, m := range c.Mounts {
+		args = append(args, "--ext-mount-map", m.Destination+":"+m.Destination)
+	}
+	return runCriu(args, nil)
+}
+
+func (d *driver) Restore(c *execdriver.Command, pipes *execdriver.Pipes, opts *execdriver.CheckpointOptions, startCallback execdriver.StartCallback) (int, error) {
+	if c.Tty {
+		return -1, fmt.Errorf("Cannot restore the container %s: its tty would be lost", c.ID)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(opts.ImagesDirectory, descriptorsFile))
+	if err != nil {
+		return -1, fmt.Errorf("Error reading the checkpoint of %s: %s", c.ID, err)
+	}
+	var descriptors []string
+	if err := json.Unmarshal(data, &descriptors); err != nil {
+		return -1, fmt.Errorf("Invalid checkpoint of %s: %s", c.ID, err)
+	}
+
+	container, err := d.createContainer(c)
+	if err != nil {
+		return -1, err
+	}
+
+	d.Lock()
+	d.activeContainers[c.ID] = &activeContainer{
+		container: container,
+		cmd:       &c.Cmd,
+	}
+	d.Unlock()
+
+	dataPath := filepath.Join(d.root, c.ID)
+	if err := d.createContainerRoot(c.ID); err != nil {
+		return -1, err
+	}
+	defer d.removeContainerRoot(c.ID)
+
+	defer d.cleanupMountOptions(c.ID)
+	if err := d.setupMountOptions(c); err != nil {
+		return -1, err
+	}
+
+	if err := d.writeContainerFile(container, c.ID); err != nil {
+		return -1, err
+	}
+
+	pidFile := filepath.Join(dataPath, "restore.pid")
+	args := append([]string{"restore", "--restore-detached", "--restore-sibling", "--pidfile", pidFile}, criuArgs(c.Rootfs, "restore.log", opts)...)
+	for _, m := range container.MountConfig.Mounts {
+		args = append(args, "--ext-mount-map", m.Destination+":"+m.Source)
+	}
+	files, stdio, err := restorePipes(descriptors, pipes)
+	if err != nil {
+		return -1, err
+	}
+	for i, descriptor := range descriptors {
+		if files[i] != nil {
+			args = append(args, "--inherit-fd", fmt.Sprintf("fd[%d]:%s", 3+i, descriptor))
+		}
+	}
+	err = runCriu(args, files)
+	// the ends of the pipes of the container are its own now
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		for _, f := range stdio {
+			if f != nil {
+				f.Close()
+			}
+		}
+		return -1, err
+	}
+
+	data, err = ioutil.ReadFile(pidFile)
+	if err != nil {
+		return -1, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return -1, err
+	}
+	startTime, err := system.GetProcessStartTime(pid)
+	if err != nil {
+		return -1, err
+	}
+	if err := libcontainer.SaveState(dataPath, &libcontainer.State{InitPid: pid, InitStartTime: startTime}); err != nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+		return -1, err
+	}
+
+	// criu restores the processes as children of the daemon
+	c.Process, err = os.FindProcess(pid)
+	if err != nil {
+		return -1, err
+	}
+	c.ContainerPid = pid
+	c.Terminal = &execdriver.StdConsole{}
+	copyPipes(stdio, pipes)
+
+	d.notifyOnOOM(c, container)
+	if startCallback != nil {
+		startCallback(c)
+	}
+
+	ps, err := c.Process.Wait()
+	if err != nil {
+		return -1, err
+	}
+	c.ProcessState = ps
+	return ps.Sys().(syscall.WaitStatus).ExitStatus(), nil
+}
+
+// criuArgs returns the arguments common to the dump and the restore of the
+// container with the root rootfs.
+func criuArgs(rootfs, logFile string, opts *execdriver.CheckpointOptions) []string {
+	workDir := opts.WorkDirectory
+	if workDir == "" {
+		workDir = opts.ImagesDirectory
+	}
+	args := []string{
+		"--images-dir", opts.ImagesDirectory,
+		"--work-dir", workDir,
+		"--root", rootfs,
+		"--manage-cgroups",
+		"--evasive-devices",
+		"--file-locks",
+		"-v4", "--log-file", logFile,
+	}
+	if opts.TcpEstablished {
+		args = append(args, "--tcp-established")
+	}
+	if opts.ExternalUnixConnections {
+		args = append(args, "--ext-unix-sk")
+	}
+	return args
+}
+
+// runCriu runs criu with args, the files being its descriptors from 3.
+func runCriu(args []string, files []*os.File) error {
+	driverLog.Debugf("criu %s", strings.Join(args, " "))
+	cmd := exec.Command("criu", args...)
+	cmd.ExtraFiles = files
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("criu %s failed: %s (%s)", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// stdioDescriptors returns what the standard streams of the process are
+// connected to, e.g. pipe:[1234].
+func stdioDescriptors(pid int) ([]string, error) {
+	descriptors := make([]string, 3)
+	for i := range descriptors {
+		target, err := os.Readlink(fmt.Sprintf("/proc/%d/fd/%d", pid, i))
+		if err != nil {
+			return nil, err
+		}
+		descriptors[i] = target
+	}
+	return descriptors, nil
+}
+
+// restorePipes creates the pipes of the standard streams of the container
+// which were pipes, returning the ends for the container and the ends for
+// the daemon, nil for the other streams.
+func restorePipes(descriptors []string, pipes *execdriver.Pipes) ([]*os.File, []*os.File, error) {
+	var (
+		files = make([]*os.File, len(descriptors))
+		stdio = make([]*os.File, len(descriptors))
+	)
+	for i, descriptor := range descriptors {
+		if !strings.HasPrefix(descriptor, "pipe:") || (i == 0 && pipes.Stdin == nil) {
+			continue
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			for j := 0; j < i; j++ {
+				if files[j] != nil {
+					files[j].Close()
+					stdio[j].Close()
+				}
+			}
+			return nil, nil, err
+		}
+		if i == 0 {
+			files[i], stdio[i] = r, w
+		} else {
+			files[i], stdio[i] = w, r
+		}
+	}
+	return files, stdio, nil
+}
+
+// copyPipes copies the standard streams of the restored container from and
+// to the pipes.
+func copyPipes(stdio []*os.File, pipes *execdriver.Pipes) {
+	if stdio[0] != nil {
+		go func() {
+			io.Copy(stdio[0], pipes.Stdin)
+			stdio[0].Close()
+		}()
+	}
+	for i, w := range []io.Writer{pipes.Stdout, pipes.Stderr} {
+		if r := stdio[i+1]; r != nil {
+			go func(r *os.File, w io.Writer) {
+				io.Copy(w, r)
+				r.Close()
+			}(r, w)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+func TestCriuArgs(t *testing.T) {
+	common := []string{"--manage-cgroups", "--evasive-devices", "--file-locks", "-v4", "--log-file", "dump.log"}
+	for _, c := range []struct {
+		opts     execdriver.CheckpointOptions
+		expected []string
+	}{
+		{
+			execdriver.CheckpointOptions{ImagesDirectory: "/images"},
+			append([]string{"--images-dir", "/images", "--work-dir", "/images", "--root", "/rootfs"}, common...),
+		},
+		{
+			execdriver.CheckpointOptions{ImagesDirectory: "/images", WorkDirectory: "/work"},
+			append([]string{"--images-dir", "/images", "--work-dir", "/work", "--root", "/rootfs"}, common...),
+		},
+		{
+			execdriver.CheckpointOptions{ImagesDirectory: "/images", TcpEstablished: true},
+			append(append([]string{"--images-dir", "/images", "--work-dir", "/images", "--root", "/rootfs"}, common...), "--tcp-established"),
+		},
+		{
+			execdriver.CheckpointOptions{ImagesDirectory: "/images", TcpEstablished: true, ExternalUnixConnections: true},
+			append(append([]string{"--images-dir", "/images", "--work-dir", "/images", "--root", "/rootfs"}, common...), "--tcp-established", "--ext-unix-sk"),
+		},
+		{
+			// Leaving the container running only concerns the dump
+			execdriver.CheckpointOptions{ImagesDirectory: "/images", LeaveRunning: true},
+			append([]string{"--images-dir", "/images", "--work-dir", "/images", "--root", "/rootfs"}, common...),
+		},
+	} {
+		opts := c.opts
+		if args := criuArgs("/rootfs", "dump.log", &opts); !reflect.DeepEqual(args, c.expected) {
+			t.Errorf("Expected %v for %#v, got %v", c.expected, c.opts, args)
+		}
+	}
+}
+
+func TestRestorePipes(t *testing.T) {
+	stdin := ioutil.NopCloser(strings.NewReader(""))
+	for _, c := range []struct {
+		descriptors []string
+		stdin       bool
+		piped       []bool
+	}{
+		{[]string{"pipe:[1]", "pipe:[2]", "pipe:[3]"}, true, []bool{true, true, true}},
+		// Without stdin to restore, stdin isn't piped
+		{[]string{"pipe:[1]", "pipe:[2]", "pipe:[3]"}, false, []bool{false, true, true}},
+		{[]string{"/dev/null", "pipe:[2]", "/var/log/app.log"}, true, []bool{false, true, false}},
+		{[]string{"/dev/null", "/dev/null", "/dev/null"}, true, []bool{false, false, false}},
+	} {
+		pipes := &execdriver.Pipes{Stdout: ioutil.Discard, Stderr: ioutil.Discard}
+		if c.stdin {
+			pipes.Stdin = stdin
+		}
+		files, stdio, err := restorePipes(c.descriptors, pipes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, piped := range c.piped {
+			if (files[i] != nil) != piped || (stdio[i] != nil) != piped {
+				t.Errorf("Expected stream %d of %v to be piped: %t", i, c.descriptors, piped)
+				continue
+			}
+			if !piped {
+				continue
+			}
+			// The daemon writes to stdin and reads the other streams
+			w, r := stdio[i], files[i]
+			if i > 0 {
+				w, r = files[i], stdio[i]
+			}
+			if _, err := w.Write([]byte("x")); err != nil {
+				t.Errorf("Expected stream %d to be written to: %s", i, err)
+			}
+			buf := make([]byte, 1)
+			if _, err := r.Read(buf); err != nil || buf[0] != 'x' {
+				t.Errorf("Expected to read from stream %d, got %q (%v)", i, buf, err)
+			}
+			files[i].Close()
+			stdio[i].Close()
+		}
+	}
+}
+
+func TestStdioDescriptors(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if _, err := cmd.StdinPipe(); err != nil {
+		t.Fatal(err)
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	cmd.Stdout = devNull
+	if _, err := cmd.StderrPipe(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	for _, c := range []struct {
+		pid    int
+		prefix []string
+		fails  bool
+	}{
+		{cmd.Process.Pid, []string{"pipe:", os.DevNull, "pipe:"}, false},
+		// A process which is gone
+		{-1, nil, true},
+	} {
+		descriptors, err := stdioDescriptors(c.pid)
+		if c.fails {
+			if err == nil {
+				t.Errorf("Expected an error for the pid %d", c.pid)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(descriptors) != len(c.prefix) {
+			t.Fatalf("Expected %d descriptors, got %v", len(c.prefix), descriptors)
+		}
+		for i, prefix := range c.prefix {
+			if !strings.HasPrefix(descriptors[i], prefix) {
+				t.Errorf("Expected the descriptor %d to start with %s, got %s", i, prefix, descriptors[i])
+			}
+		}
+	}
+}
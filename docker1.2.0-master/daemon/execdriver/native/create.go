@@ -31,6 +31,7 @@ func (d *driver) createContainer(c *execdriver.Command) (*libcontainer.Config, e
 	container.Cgroups.Name = c.ID
 	container.Cgroups.AllowedDevices = c.AllowedDevices
 	container.MountConfig.DeviceNodes = c.AutoCreatedDevices
+	container.Seccomp = c.Seccomp
 
 	// check to see if we are running in ramdisk to disable pivot root
 	container.MountConfig.NoPivotRoot = os.Getenv("DOCKER_RAMDISK") != ""
@@ -59,6 +60,8 @@ func (d *driver) createContainer(c *execdriver.Command) (*libcontainer.Config, e
 		return nil, err
 	}
 
+	d.setupRlimits(container, c)
+
 	if err := d.setupLabels(container, c); err != nil {
 		return nil, err
 	}
@@ -169,13 +172,38 @@ func (d *driver) setupCgroups(container *libcontainer.Config, c *execdriver.Comm
 	return nil
 }
 
+// setupRlimits sets the resource limits applied by the init of the container
+// before it execs the process.
+func (d *driver) setupRlimits(container *libcontainer.Config, c *execdriver.Command) {
+	if c.Resources == nil {
+		return
+	}
+	for _, rlimit := range c.Resources.Rlimits {
+		container.Rlimits = append(container.Rlimits, libcontainer.Rlimit{
+			Type: rlimit.Type,
+			Hard: rlimit.Hard,
+			Soft: rlimit.Soft,
+		})
+	}
+}
+
 func (d *driver) setupMounts(container *libcontainer.Config, c *execdriver.Command) error {
-	for _, m := range c.Mounts {
+	for i, m := range c.Mounts {
+		flags, err := parseMountOptions(m.Options)
+		if err != nil {
+			return err
+		}
+		source, writable := m.Source, m.Writable
+		if flags != 0 {
+			// The staged source is already read-only when it should be,
+			// remounting it would drop its flags
+			source, writable = d.stagedMountSource(c.ID, i), true
+		}
 		container.MountConfig.Mounts = append(container.MountConfig.Mounts, mount.Mount{
 			Type:        "bind",
-			Source:      m.Source,
+			Source:      source,
 			Destination: m.Destination,
-			Writable:    m.Writable,
+			Writable:    writable,
 			Private:     m.Private,
 		})
 	}
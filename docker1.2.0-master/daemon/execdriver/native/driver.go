@@ -13,15 +13,19 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/apparmor"
+	"github.com/docker/libcontainer/cgroups"
 	"github.com/docker/libcontainer/cgroups/fs"
 	"github.com/docker/libcontainer/cgroups/systemd"
 	consolepkg "github.com/docker/libcontainer/console"
 	"github.com/docker/libcontainer/namespaces"
+	"github.com/docker/libcontainer/security/seccomp"
 	"github.com/docker/libcontainer/system"
 )
 
@@ -30,6 +34,8 @@ const (
 	Version    = "0.2"
 )
 
+var driverLog = log.New("execdriver")
+
 type activeContainer struct {
 	container *libcontainer.Config
 	cmd       *exec.Cmd
@@ -95,6 +101,11 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 	}
 	defer d.removeContainerRoot(c.ID)
 
+	defer d.cleanupMountOptions(c.ID)
+	if err := d.setupMountOptions(c); err != nil {
+		return -1, err
+	}
+
 	if err := d.writeContainerFile(container, c.ID); err != nil {
 		return -1, err
 	}
@@ -120,6 +131,7 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 
 		return &c.Cmd
 	}, func() {
+		d.notifyOnOOM(c, container)
 		if startCallback != nil {
 			c.ContainerPid = c.Process.Pid
 			startCallback(c)
@@ -128,6 +140,29 @@ func (d *driver) Run(c *execdriver.Command, pipes *execdriver.Pipes, startCallba
 	//execdriver 模块的执行部分已经结束， Docker Daemon 的运行陷入 libcontainer
 }
 
+// notifyOnOOM calls the OOM callback of c each time the memory cgroup of the
+// container hits its limit, until the cgroup is removed.
+func (d *driver) notifyOnOOM(c *execdriver.Command, container *libcontainer.Config) {
+	if c.OOMCallback == nil {
+		return
+	}
+	// The cgroups of systemd are named after its units
+	if systemd.UseSystemd() {
+		driverLog.Debugf("%s: OOM notifications are not supported with systemd cgroups", c.ID)
+		return
+	}
+	oom, err := fs.NotifyOnOOM(container.Cgroups)
+	if err != nil {
+		driverLog.Errorf("%s: Error registering for OOM notifications: %s", c.ID, err)
+		return
+	}
+	go func() {
+		for _ = range oom {
+			c.OOMCallback()
+		}
+	}()
+}
+
 func (d *driver) Kill(p *execdriver.Command, sig int) error {
 	return syscall.Kill(p.Process.Pid, syscall.Signal(sig))
 }
@@ -203,6 +238,19 @@ func (d *driver) Name() string {
 	return fmt.Sprintf("%s-%s", DriverName, Version)
 }
 
+func (d *driver) Capabilities() execdriver.Capabilities {
+	return execdriver.Capabilities{
+		Exec:            true,
+		Pause:           true,
+		Stats:           true,
+		OOMNotification: !systemd.UseSystemd(),
+		Rlimits:         true,
+		Checkpoint:      criuAvailable(),
+		// the filters need the syscall table of the arch
+		Seccomp: (&seccomp.Config{}).Validate() == nil,
+	}
+}
+
 func (d *driver) GetPidsForContainer(id string) ([]int, error) {
 	d.Lock()
 	active := d.activeContainers[id]
@@ -219,6 +267,39 @@ func (d *driver) GetPidsForContainer(id string) ([]int, error) {
 	return fs.GetPids(c)
 }
 
+func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
+	d.Lock()
+	active := d.activeContainers[id]
+	d.Unlock()
+
+	if active == nil {
+		return nil, fmt.Errorf("active container for %s does not exist", id)
+	}
+	var (
+		c     = active.container.Cgroups
+		stats *cgroups.Stats
+		pids  []int
+		err   error
+	)
+	read := time.Now()
+	if systemd.UseSystemd() {
+		stats, err = systemd.GetStats(c)
+	} else {
+		stats, err = fs.GetStats(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pids, err = d.GetPidsForContainer(id); err != nil {
+		return nil, err
+	}
+	return &execdriver.ResourceStats{
+		Stats:     stats,
+		Read:      read,
+		Processes: len(pids),
+	}, nil
+}
+
 func (d *driver) writeContainerFile(container *libcontainer.Config, id string) error {
 	data, err := json.Marshal(container)
 	if err != nil {
@@ -0,0 +1,80 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/reexec"
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/namespaces"
+	_ "github.com/docker/libcontainer/namespaces/nsenter"
+	"github.com/docker/libcontainer/syncpipe"
+	"github.com/docker/libcontainer/system"
+)
+
+const execCommandName = "nsenter-exec"
+
+func init() {
+	reexec.Register(execCommandName, nsenterExec)
+}
+
+// nsenterExec runs the process in the namespaces of the container, joined
+// before the runtime started. The config of the container is read from the
+// sync pipe and the command follows "--".
+func nsenterExec() {
+	syncPipe, err := syncpipe.NewSyncPipeFromFd(0, 3)
+	if err != nil {
+		log.Fatalf("failed to open the sync pipe: %s", err)
+	}
+	var container *libcontainer.Config
+	if err := syncPipe.ReadFromParent(&container); err != nil {
+		log.Fatalf("failed to read the container config: %s", err)
+	}
+	var args []string
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = os.Args[i+1:]
+			break
+		}
+	}
+	if len(args) == 0 {
+		log.Fatalf("no command to run")
+	}
+	// The process is still in the directory of the daemon, out of the
+	// rootfs of the container
+	if container.WorkingDir == "" {
+		container.WorkingDir = "/"
+	}
+	if err := namespaces.FinalizeSetns(container, args); err != nil {
+		log.Fatalf("failed to nsenter: %s", err)
+	}
+}
+
+// Exec runs args in the running container of c, with the environment of
+// the container. The process joins the namespaces and the cgroups of the
+// init of the container, with pipes as its stdio.
+func (d *driver) Exec(c *execdriver.Command, args []string, pipes *execdriver.Pipes, startCallback func(*exec.Cmd)) (int, error) {
+	d.Lock()
+	active := d.activeContainers[c.ID]
+	d.Unlock()
+	if active == nil {
+		return -1, fmt.Errorf("No active container exists with ID %s", c.ID)
+	}
+	state, err := libcontainer.GetState(filepath.Join(d.root, c.ID))
+	if err != nil {
+		return -1, err
+	}
+	// The pid of an init which exited can be reused, the process would
+	// join the namespaces of another one
+	startTime, err := system.GetProcessStartTime(state.InitPid)
+	if err != nil || startTime != state.InitStartTime {
+		return -1, fmt.Errorf("Container %s is not running", c.ID)
+	}
+	return namespaces.ExecIn(active.container, state, args, d.initPath, "exec", pipes.Stdin, pipes.Stdout, pipes.Stderr, "", startCallback)
+}
@@ -0,0 +1,122 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+// parseMountOptions returns the mount flags set by the options of a mount.
+func parseMountOptions(options []string) (int, error) {
+	var flags int
+	for _, opt := range options {
+		switch opt {
+		case "nosuid":
+			flags |= syscall.MS_NOSUID
+		case "nodev":
+			flags |= syscall.MS_NODEV
+		case "noexec":
+			flags |= syscall.MS_NOEXEC
+		case "copy", "nocopy":
+			// Applied by the daemon when the volume is created
+		default:
+			// uid and gid are applied by the daemon to the new volumes
+			if !strings.HasPrefix(opt, "uid=") && !strings.HasPrefix(opt, "gid=") {
+				return 0, fmt.Errorf("unsupported mount option %s", opt)
+			}
+		}
+	}
+	return flags, nil
+}
+
+func (d *driver) stagingRoot(id string) string {
+	return filepath.Join(d.root, "mounts", id)
+}
+
+// stagedMountSource returns the path the source of the i-th mount of
+// container id is staged on, when the mount has flags.
+func (d *driver) stagedMountSource(id string, i int) string {
+	return filepath.Join(d.stagingRoot(id), strconv.Itoa(i))
+}
+
+// setupMountOptions applies the flags of the mounts of c. libcontainer
+// only bind mounts read-only or read-write, so the source of a mount with
+// flags is first bind mounted on a staging path with these flags: the
+// recursive bind mount of the container inherits them.
+func (d *driver) setupMountOptions(c *execdriver.Command) error {
+	for i, m := range c.Mounts {
+		if len(m.Options) == 0 {
+			continue
+		}
+		flags, err := parseMountOptions(m.Options)
+		if err != nil {
+			return err
+		}
+		if flags == 0 {
+			continue
+		}
+		if !m.Writable {
+			flags |= syscall.MS_RDONLY
+		}
+		if err := d.stageMount(m.Source, d.stagedMountSource(c.ID, i), flags); err != nil {
+			return fmt.Errorf("staging mount %s: %s", m.Destination, err)
+		}
+	}
+	return nil
+}
+
+func (d *driver) stageMount(source, staging string, flags int) error {
+	stat, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(staging), 0700); err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		err = os.Mkdir(staging, 0700)
+	} else {
+		var f *os.File
+		if f, err = os.OpenFile(staging, os.O_CREATE, 0600); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err := syscall.Mount(source, staging, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+	if err := syscall.Mount(source, staging, "bind", uintptr(syscall.MS_BIND|syscall.MS_REMOUNT|flags), ""); err != nil {
+		syscall.Unmount(staging, syscall.MNT_DETACH)
+		return err
+	}
+	return nil
+}
+
+// cleanupMountOptions unmounts the staged mounts of container id. The
+// staging paths are only removed once unmounted, never recursively, as
+// they may still hold the volume data.
+func (d *driver) cleanupMountOptions(id string) {
+	root := d.stagingRoot(id)
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		p := filepath.Join(root, e.Name())
+		if err := syscall.Unmount(p, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL {
+			continue
+		}
+		os.Remove(p)
+	}
+	os.Remove(root)
+}
@@ -0,0 +1,305 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/image"
+)
+
+// fsckResult lists the inconsistencies found in the graph of the daemon.
+type fsckResult struct {
+	// OrphanedLayers are the layers of the driver no image, container or
+	// volume uses
+	OrphanedLayers []string
+	// OrphanedImageDirs are the directories of the graph root which are
+	// not registered images, their layer being missing
+	OrphanedImageDirs []string
+	// BrokenImages are the images with a parent missing from the graph
+	BrokenImages []string
+	// DanglingTags are the repo:tag pointing to a missing or broken image
+	DanglingTags []string
+}
+
+func (r *fsckResult) empty() bool {
+	return len(r.OrphanedLayers) == 0 && len(r.OrphanedImageDirs) == 0 && len(r.BrokenImages) == 0 && len(r.DanglingTags) == 0
+}
+
+// checkGraph looks for the inconsistencies of the graph. With repair, the
+// broken images no container uses, the dangling tags and the orphaned
+// layers are removed, and with removeDirs the orphaned image directories.
+func (daemon *Daemon) checkGraph(repair, removeDirs bool) (*fsckResult, error) {
+	result := &fsckResult{
+		OrphanedLayers:    []string{},
+		OrphanedImageDirs: []string{},
+		BrokenImages:      []string{},
+		DanglingTags:      []string{},
+	}
+	images, err := daemon.graph.Map()
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]struct{})
+	for _, container := range daemon.List() {
+		used[container.Image] = struct{}{}
+	}
+
+	// Images whose chain of parents doesn't reach a base image, removed
+	// children first
+	var broken []*image.Image
+	depths := make(map[string]int)
+	for id, img := range images {
+		p := img
+		for ; p != nil && p.Parent != ""; p = images[p.Parent] {
+			depths[id]++
+		}
+		if p == nil {
+			broken = append(broken, img)
+		}
+	}
+	sort.Sort(byDepth{broken, depths})
+	for _, img := range broken {
+		result.BrokenImages = append(result.BrokenImages, img.ID)
+		if _, exists := used[img.ID]; !repair || exists || daemon.graph.HasChildren(img.ID) {
+			continue
+		}
+		if err := daemon.graph.Delete(img.ID); err != nil {
+			return result, err
+		}
+		delete(images, img.ID)
+		daemon.logImageEvent("delete", img.ID)
+	}
+
+	if err := daemon.checkTags(images, broken, repair, result); err != nil {
+		return result, err
+	}
+	if err := daemon.checkImageDirs(removeDirs, result); err != nil {
+		return result, err
+	}
+	if err := daemon.checkLayers(images, repair, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// checkTags looks for the tags pointing to images missing from images or
+// broken.
+func (daemon *Daemon) checkTags(images map[string]*image.Image, broken []*image.Image, repair bool, result *fsckResult) error {
+	brokenIDs := make(map[string]struct{})
+	for _, img := range broken {
+		brokenIDs[img.ID] = struct{}{}
+	}
+	store := daemon.Repositories()
+	var dangling [][2]string
+	store.Lock()
+	for repoName, repository := range store.Repositories {
+		for tag, id := range repository {
+			_, exists := images[id]
+			if _, isBroken := brokenIDs[id]; !exists || isBroken {
+				dangling = append(dangling, [2]string{repoName, tag})
+			}
+		}
+	}
+	store.Unlock()
+
+	for _, t := range dangling {
+		result.DanglingTags = append(result.DanglingTags, t[0]+":"+t[1])
+		if !repair {
+			continue
+		}
+		if _, err := store.Delete(t[0], t[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkImageDirs looks for the directories of the graph root which the graph
+// doesn't load, the layer of the image being missing from every driver. The
+// graph root is shared by the drivers: the directories of the layers of
+// another driver are kept for graph_migrate, as are the ones created since
+// the daemon started or by an image being registered. The orphaned
+// directories are only removed with removeDirs.
+func (daemon *Daemon) checkImageDirs(removeDirs bool, result *fsckResult) error {
+	dirs, err := ioutil.ReadDir(daemon.graph.Root)
+	if err != nil {
+		return err
+	}
+	var (
+		others []graphdriver.Driver
+		loaded bool
+	)
+	defer func() {
+		for _, driver := range others {
+			driver.Cleanup()
+		}
+	}()
+	for _, dir := range dirs {
+		id := dir.Name()
+		// The temporary directories and the index of the graph
+		if strings.HasPrefix(id, "_") || daemon.graph.Exists(id) {
+			continue
+		}
+		// A pull, a commit or a load in progress
+		if !dir.ModTime().Before(daemon.started) || daemon.graph.Registering(id) {
+			continue
+		}
+		if !loaded {
+			if others, err = daemon.otherDrivers(); err != nil {
+				daemonLog.Errorf("%s, skipping the orphaned image directories", err)
+				return nil
+			}
+			loaded = true
+		}
+		if driverHas(others, id) {
+			continue
+		}
+		result.OrphanedImageDirs = append(result.OrphanedImageDirs, id)
+		if removeDirs && !daemon.graph.Exists(id) && !daemon.graph.Registering(id) {
+			if err := os.RemoveAll(daemon.graph.ImageRoot(id)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// otherDrivers loads the graph drivers other than the one of the daemon
+// which have a directory in its root.
+func (daemon *Daemon) otherDrivers() ([]graphdriver.Driver, error) {
+	entries, err := ioutil.ReadDir(daemon.config.Root)
+	if err != nil {
+		return nil, err
+	}
+	var drivers []graphdriver.Driver
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || name == daemon.driver.String() {
+			continue
+		}
+		driver, err := graphdriver.GetDriver(name, daemon.config.Root, daemon.config.GraphOptions)
+		if err == graphdriver.ErrNotSupported {
+			// Not the directory of a driver
+			continue
+		}
+		if err != nil {
+			for _, d := range drivers {
+				d.Cleanup()
+			}
+			return nil, fmt.Errorf("Error loading the %s driver: %s", name, err)
+		}
+		drivers = append(drivers, driver)
+	}
+	return drivers, nil
+}
+
+func driverHas(drivers []graphdriver.Driver, id string) bool {
+	for _, driver := range drivers {
+		if driver.Exists(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLayers looks for the layers of the driver which are neither images,
+// the layers of a container or volumes, when the driver can list them.
+func (daemon *Daemon) checkLayers(images map[string]*image.Image, repair bool, result *fsckResult) error {
+	lister, ok := daemon.driver.(graphdriver.Lister)
+	if !ok {
+		daemonLog.Debugf("The %s driver can't list its layers, skipping the orphaned layers", daemon.driver)
+		return nil
+	}
+	ids, err := lister.List()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]struct{})
+	for id := range images {
+		known[id] = struct{}{}
+	}
+	for _, container := range daemon.List() {
+		known[container.ID] = struct{}{}
+		known[fmt.Sprintf("%s-init", container.ID)] = struct{}{}
+	}
+	// The volumes share the layers of the vfs driver
+	volumes, err := daemon.volumes.Map()
+	if err != nil {
+		return err
+	}
+	for id := range volumes {
+		known[id] = struct{}{}
+	}
+
+	for _, id := range ids {
+		if _, exists := known[id]; exists {
+			continue
+		}
+		result.OrphanedLayers = append(result.OrphanedLayers, id)
+		if repair {
+			if err := daemon.driver.Remove(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fsckGraph checks the graph at startup, logging what is found. The
+// orphaned image directories are only reported.
+func (daemon *Daemon) fsckGraph(repair bool) error {
+	result, err := daemon.checkGraph(repair, false)
+	if err != nil {
+		return fmt.Errorf("Error checking the graph: %s", err)
+	}
+	if result.empty() {
+		daemonLog.Infof("The graph is consistent")
+		return nil
+	}
+	for _, p := range []struct {
+		what     string
+		ids      []string
+		repaired bool
+	}{
+		{"orphaned layer", result.OrphanedLayers, repair},
+		{"orphaned image directory", result.OrphanedImageDirs, false},
+		{"broken image", result.BrokenImages, repair},
+		{"dangling tag", result.DanglingTags, repair},
+	} {
+		action := "found"
+		if p.repaired {
+			action = "repaired"
+		}
+		for _, id := range p.ids {
+			daemonLog.Infof("Graph check: %s %s %s", p.what, id, action)
+		}
+	}
+	return nil
+}
+
+// GraphFsck checks the consistency of the images, their layers and the
+// tags. With "repair" set, the inconsistencies are removed, the orphaned
+// image directories only with "removedirs" set.
+func (daemon *Daemon) GraphFsck(job *engine.Job) engine.Status {
+	if len(job.Args) != 0 {
+		return job.Errorf("Usage: %s", job.Name)
+	}
+	result, err := daemon.checkGraph(job.GetenvBool("repair"), job.GetenvBool("removedirs"))
+	if err != nil {
+		return job.Errorf("Error checking the graph: %s", err)
+	}
+	out := &engine.Env{}
+	out.SetList("OrphanedLayers", result.OrphanedLayers)
+	out.SetList("OrphanedImageDirs", result.OrphanedImageDirs)
+	out.SetList("BrokenImages", result.BrokenImages)
+	out.SetList("DanglingTags", result.DanglingTags)
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
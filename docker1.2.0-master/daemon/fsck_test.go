@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/daemon/graphdriver/vfs"
+	"github.com/docker/docker/graph"
+	"github.com/docker/docker/utils"
+)
+
+func init() {
+	// Another driver sharing the graph root, as after --storage-driver changed
+	graphdriver.Register("fsck-other", vfs.Init)
+}
+
+func TestCheckGraph(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-fsck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	daemon, register := mkTestImagesDaemon(t, root)
+	if daemon.volumes, err = graph.NewGraph(filepath.Join(root, "volumes"), daemon.driver); err != nil {
+		t.Fatal(err)
+	}
+	g, store := daemon.graph, daemon.repositories
+
+	var (
+		base = register("", 0, 0)
+		mid  = register(base, 0, 0)
+		top  = register(mid, 0, 0)
+	)
+	if err := store.Set("app", "mid", mid, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("app", "base", base, true); err != nil {
+		t.Fatal(err)
+	}
+	// Break the parent chain of top
+	if err := g.Delete(mid); err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.driver.Create("orphan", ""); err != nil {
+		t.Fatal(err)
+	}
+	daemon.config = &Config{Root: root}
+	other, err := graphdriver.GetDriver("fsck-other", root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		orphanDir = utils.GenerateRandomID()
+		otherDir  = utils.GenerateRandomID()
+		newDir    = utils.GenerateRandomID()
+	)
+	if err := other.Create(otherDir, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{orphanDir, otherDir} {
+		if err := os.Mkdir(g.ImageRoot(id), 0700); err != nil {
+			t.Fatal(err)
+		}
+		past := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(g.ImageRoot(id), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	daemon.started = time.Now().Add(-time.Minute)
+	// Created since the daemon started, as by a pull in progress
+	if err := os.Mkdir(g.ImageRoot(newDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := daemon.checkGraph(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for what, c := range map[string]struct {
+		found    []string
+		expected string
+	}{
+		"broken image":             {result.BrokenImages, top},
+		"dangling tag":             {result.DanglingTags, "app:mid"},
+		"orphaned layer":           {result.OrphanedLayers, "orphan"},
+		"orphaned image directory": {result.OrphanedImageDirs, orphanDir},
+	} {
+		if len(c.found) != 1 || c.found[0] != c.expected {
+			t.Fatalf("Expected the %s %s, got %v", what, c.expected, c.found)
+		}
+	}
+	if !g.Exists(top) {
+		t.Fatal("Expected nothing to be removed without repair")
+	}
+
+	if _, err := daemon.checkGraph(true, false); err != nil {
+		t.Fatal(err)
+	}
+	if g.Exists(top) || daemon.driver.Exists("orphan") {
+		t.Fatal("Expected the broken image and the orphaned layer to be removed")
+	}
+	if _, err := os.Stat(g.ImageRoot(orphanDir)); err != nil {
+		t.Fatal("Expected the orphaned image directory to be kept without removedirs")
+	}
+	if _, err := daemon.checkGraph(true, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(g.ImageRoot(orphanDir)); err == nil {
+		t.Fatal("Expected the orphaned image directory to be removed")
+	}
+	for _, id := range []string{otherDir, newDir} {
+		if _, err := os.Stat(g.ImageRoot(id)); err != nil {
+			t.Fatalf("Expected the image directory %s to be kept", id)
+		}
+	}
+	if img, err := store.GetImage("app", "base"); err != nil || img == nil || img.ID != base {
+		t.Fatalf("Expected app:base to be kept, got %v (%v)", img, err)
+	}
+	if result, err := daemon.checkGraph(false, false); err != nil {
+		t.Fatal(err)
+	} else if !result.empty() {
+		t.Fatalf("Expected a consistent graph after the repair, got %+v", result)
+	}
+}
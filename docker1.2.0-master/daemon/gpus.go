@@ -0,0 +1,140 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/libcontainer/devices"
+)
+
+// GpuProfile is how the containers created with --gpus get access to the
+// GPUs of the host, read from the file given with --gpu-profile.
+type GpuProfile struct {
+	// Devices is the glob of the device nodes of the GPUs. The nodes ending
+	// with a number are the GPU of that number, the others are the control
+	// nodes every GPU container needs.
+	Devices string
+	// Mounts are the host:container paths of the driver libraries, mounted
+	// read-only.
+	Mounts []string
+}
+
+// defaultGpuProfile gives access to the nvidia GPUs, the containers coming
+// with the libraries of the driver.
+var defaultGpuProfile = &GpuProfile{Devices: "/dev/nvidia*"}
+
+var gpuNumber = regexp.MustCompile(`[0-9]+$`)
+
+// loadGpuProfile reads the GPU profile at path, the default one if empty.
+func loadGpuProfile(path string) (*GpuProfile, error) {
+	if path == "" {
+		return defaultGpuProfile, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --gpu-profile: %s", err)
+	}
+	defer f.Close()
+	profile := &GpuProfile{}
+	if err := json.NewDecoder(f).Decode(profile); err != nil {
+		return nil, fmt.Errorf("Invalid --gpu-profile %s: %s", path, err)
+	}
+	if _, err := filepath.Match(profile.Devices, ""); err != nil || profile.Devices == "" {
+		return nil, fmt.Errorf("Invalid --gpu-profile %s: bad Devices glob %q", path, profile.Devices)
+	}
+	for _, m := range profile.Mounts {
+		parts := strings.Split(m, ":")
+		if len(parts) != 2 || !filepath.IsAbs(parts[0]) || !filepath.IsAbs(parts[1]) {
+			return nil, fmt.Errorf("Invalid --gpu-profile %s: bad mount %q, expected /host/path:/container/path", path, m)
+		}
+	}
+	return profile, nil
+}
+
+// devicePaths returns the device nodes giving access to the GPUs of spec,
+// all or a list of GPU numbers: the nodes of the GPUs and the control nodes.
+func (p *GpuProfile) devicePaths(spec string) ([]string, error) {
+	numbers, err := runconfig.ParseGpus(spec)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(p.Devices)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		paths []string
+		gpus  = make(map[int]string)
+	)
+	for _, path := range matches {
+		n := gpuNumber.FindString(filepath.Base(path))
+		if n == "" {
+			paths = append(paths, path)
+			continue
+		}
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return nil, err
+		}
+		gpus[i] = path
+	}
+	if len(gpus) == 0 {
+		return nil, fmt.Errorf("No GPU found matching %s", p.Devices)
+	}
+	if numbers == nil {
+		for _, path := range gpus {
+			paths = append(paths, path)
+		}
+	}
+	for _, i := range numbers {
+		path, exists := gpus[i]
+		if !exists {
+			return nil, fmt.Errorf("No such GPU: %d", i)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// gpuDevices returns the devices of the GPUs the container was created with.
+func (daemon *Daemon) gpuDevices(container *Container) ([]*devices.Device, error) {
+	if container.hostConfig.Gpus == "" {
+		return nil, nil
+	}
+	paths, err := daemon.gpuProfile.devicePaths(container.hostConfig.Gpus)
+	if err != nil {
+		return nil, err
+	}
+	gpuDevices := make([]*devices.Device, len(paths))
+	for i, path := range paths {
+		device, err := devices.GetDevice(path, "rwm")
+		if err != nil {
+			return nil, fmt.Errorf("error gathering information of the GPU device %s: %s", path, err)
+		}
+		gpuDevices[i] = device
+	}
+	return gpuDevices, nil
+}
+
+// gpuMounts returns the read-only mounts of the driver libraries of the
+// profile for the container, if it was created with GPUs.
+func (daemon *Daemon) gpuMounts(container *Container) []execdriver.Mount {
+	if container.hostConfig.Gpus == "" {
+		return nil
+	}
+	var mounts []execdriver.Mount
+	for _, m := range daemon.gpuProfile.Mounts {
+		parts := strings.Split(m, ":")
+		mounts = append(mounts, execdriver.Mount{Source: parts[0], Destination: parts[1], Private: true})
+	}
+	return mounts
+}
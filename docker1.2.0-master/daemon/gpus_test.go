@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGpuDevicePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-gpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"nvidia0", "nvidia1", "nvidiactl", "nvidia-uvm", "other"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	profile := &GpuProfile{Devices: filepath.Join(dir, "nvidia*")}
+	for spec, expected := range map[string][]string{
+		"all": {"nvidia-uvm", "nvidia0", "nvidia1", "nvidiactl"},
+		"1":   {"nvidia-uvm", "nvidia1", "nvidiactl"},
+	} {
+		paths, err := profile.devicePaths(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range expected {
+			expected[i] = filepath.Join(dir, expected[i])
+		}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Fatalf("%s: expected %v, got %v", spec, expected, paths)
+		}
+	}
+	if _, err := profile.devicePaths("2"); err == nil {
+		t.Fatal("Expected a missing GPU to fail")
+	}
+	profile.Devices = filepath.Join(dir, "amd*")
+	if _, err := profile.devicePaths("all"); err == nil {
+		t.Fatal("Expected a profile matching no GPU to fail")
+	}
+}
+
+func TestLoadGpuProfile(t *testing.T) {
+	if profile, err := loadGpuProfile(""); err != nil || profile != defaultGpuProfile {
+		t.Fatalf("Expected the default profile, got %v, %v", profile, err)
+	}
+	f, err := ioutil.TempFile("", "docker-gpu-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"Devices": "/dev/nvidia*", "Mounts": ["/usr/lib/nvidia-340:/usr/local/nvidia/lib"]}`)
+	f.Close()
+	profile, err := loadGpuProfile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profile.Mounts) != 1 || profile.Mounts[0] != "/usr/lib/nvidia-340:/usr/local/nvidia/lib" {
+		t.Fatalf("Unexpected profile %#v", profile)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte(`{"Devices": "/dev/nvidia*", "Mounts": ["lib:/lib"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadGpuProfile(f.Name()); err == nil {
+		t.Fatal("Expected a relative mount to be refused")
+	}
+}
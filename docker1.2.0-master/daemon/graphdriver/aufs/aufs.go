@@ -16,6 +16,9 @@ aufs driver directory structure
     ├── 2
     └── 3
 
+The diff of the layers created with CreateRw is a symlink to their content
+in the diff directory of the read-write layers root.
+
 */
 
 package aufs
@@ -52,7 +55,8 @@ func init() {
 
 type Driver struct {
 	root       string
-	sync.Mutex // Protects concurrent modification to active
+	rwRoot     string // root of the read-write layers, if set
+	sync.Mutex        // Protects concurrent modification to active
 	active     map[string]int
 }
 
@@ -143,10 +147,37 @@ func (Driver) String() string {
 
 func (a Driver) Status() [][2]string {
 	ids, _ := loadIds(path.Join(a.rootPath(), "layers"))
-	return [][2]string{
+	status := [][2]string{
 		{"Root Dir", a.rootPath()},
 		{"Dirs", fmt.Sprintf("%d", len(ids))},
 	}
+	if a.rwRoot != "" {
+		status = append(status, [2]string{"Read-Write Layers Dir", a.rwRoot})
+	}
+	return status
+}
+
+func (a *Driver) Capabilities() graphdriver.Capabilities {
+	return graphdriver.Capabilities{Diff: true, List: true, RwLayers: true}
+}
+
+// SetRwLayersRoot sets the root where the content of the layers created with
+// CreateRw is stored.
+func (a *Driver) SetRwLayersRoot(root string) error {
+	if err := os.MkdirAll(path.Join(root, "diff"), 0755); err != nil {
+		return err
+	}
+	a.rwRoot = root
+	return nil
+}
+
+// diffPath returns the directory of the content of the layer id.
+func (a *Driver) diffPath(id string) string {
+	dir := path.Join(a.rootPath(), "diff", id)
+	if target, err := os.Readlink(dir); err == nil {
+		return target
+	}
+	return dir
 }
 
 // Exists returns true if the given id is registered with
@@ -158,6 +189,11 @@ func (a Driver) Exists(id string) bool {
 	return true
 }
 
+// List returns the IDs of the layers of the driver.
+func (a *Driver) List() ([]string, error) {
+	return loadIds(path.Join(a.rootPath(), "layers"))
+}
+
 // Three folders are created for each id
 // mnt, layers, and diff
 //layers diff 以及 mnt 为目录 /var/lib/docker/aufs 下的三个子目录，
@@ -195,6 +231,23 @@ func (a *Driver) Create(id, parent string) error {
 	return nil
 }
 
+// CreateRw creates a layer like Create, with its content stored under the
+// read-write layers root when one is set.
+func (a *Driver) CreateRw(id, parent string) error {
+	if a.rwRoot == "" {
+		return a.Create(id, parent)
+	}
+	diff := path.Join(a.rwRoot, "diff", id)
+	if err := os.MkdirAll(diff, 0755); err != nil {
+		return err
+	}
+	if err := os.Symlink(diff, path.Join(a.rootPath(), "diff", id)); err != nil {
+		os.RemoveAll(diff)
+		return err
+	}
+	return a.Create(id, parent)
+}
+
 func (a *Driver) createDirsFor(id string) error {
 	paths := []string{
 		"mnt",
@@ -223,6 +276,14 @@ func (a *Driver) Remove(id string) error {
 	if err := a.unmount(id); err != nil {
 		return err
 	}
+	// The content of a read-write layer is removed with its symlink
+	if diff := a.diffPath(id); diff != path.Join(a.rootPath(), "diff", id) {
+		tmpPath := fmt.Sprintf("%s-removing", diff)
+		if err := os.Rename(diff, tmpPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		defer os.RemoveAll(tmpPath)
+	}
 	tmpDirs := []string{
 		"mnt",
 		"diff",
@@ -267,7 +328,7 @@ func (a *Driver) Get(id, mountLabel string) (string, error) {
 
 	// If a dir does not have a parent ( no layers )do not try to mount
 	// just return the diff path to the data
-	out := path.Join(a.rootPath(), "diff", id)
+	out := a.diffPath(id)
 	if len(ids) > 0 {
 		out = path.Join(a.rootPath(), "mnt", id)
 
@@ -302,18 +363,18 @@ func (a *Driver) Put(id string) {
 
 // Returns an archive of the contents for the id
 func (a *Driver) Diff(id string) (archive.Archive, error) {
-	return archive.TarWithOptions(path.Join(a.rootPath(), "diff", id), &archive.TarOptions{
+	return archive.TarWithOptions(a.diffPath(id), &archive.TarOptions{
 		Compression: archive.Uncompressed,
 	})
 }
 
 func (a *Driver) ApplyDiff(id string, diff archive.ArchiveReader) error {
-	return archive.Untar(diff, path.Join(a.rootPath(), "diff", id), nil)
+	return archive.Untar(diff, a.diffPath(id), nil)
 }
 
 // Returns the size of the contents for the id
 func (a *Driver) DiffSize(id string) (int64, error) {
-	return utils.TreeSize(path.Join(a.rootPath(), "diff", id))
+	return utils.TreeSize(a.diffPath(id))
 }
 
 func (a *Driver) Changes(id string) ([]archive.Change, error) {
@@ -321,7 +382,7 @@ func (a *Driver) Changes(id string) ([]archive.Change, error) {
 	if err != nil {
 		return nil, err
 	}
-	return archive.Changes(layers, path.Join(a.rootPath(), "diff", id))
+	return archive.Changes(layers, a.diffPath(id))
 }
 
 func (a *Driver) getParentLayerPaths(id string) ([]string, error) {
@@ -336,7 +397,7 @@ func (a *Driver) getParentLayerPaths(id string) ([]string, error) {
 
 	// Get the diff paths for all the parent ids
 	for i, p := range parentIds {
-		layers[i] = path.Join(a.rootPath(), "diff", p)
+		layers[i] = a.diffPath(p)
 	}
 	return layers, nil
 }
@@ -349,7 +410,7 @@ func (a *Driver) mount(id, mountLabel string) error {
 
 	var (
 		target = path.Join(a.rootPath(), "mnt", id)
-		rw     = path.Join(a.rootPath(), "diff", id)
+		rw     = a.diffPath(id)
 	)
 
 	layers, err := a.getParentLayerPaths(id)
@@ -58,6 +58,19 @@ func TestAufsString(t *testing.T) {
 	}
 }
 
+func TestAufsCapabilities(t *testing.T) {
+	d := newDriver(t)
+	defer os.RemoveAll(tmp)
+
+	var driver graphdriver.Driver = d
+	_, differ := driver.(graphdriver.Differ)
+	_, lister := driver.(graphdriver.Lister)
+	_, rwLayers := driver.(graphdriver.RwLayersDriver)
+	if c := d.Capabilities(); c.Diff != differ || c.List != lister || c.RwLayers != rwLayers || c.Quota {
+		t.Fatalf("Unexpected capabilities %+v", c)
+	}
+}
+
 func TestCreateDirStructure(t *testing.T) {
 	newDriver(t)
 	defer os.RemoveAll(tmp)
@@ -159,6 +172,38 @@ func TestGetWithoutParent(t *testing.T) {
 	}
 }
 
+func TestCreateRw(t *testing.T) {
+	d := newDriver(t)
+	defer os.RemoveAll(tmp)
+	rwRoot := path.Join(os.TempDir(), "aufs-tests", "rw")
+	defer os.RemoveAll(rwRoot)
+
+	if err := d.SetRwLayersRoot(rwRoot); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create("1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateRw("2", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(rwRoot, "diff", "1")); err == nil {
+		t.Fatal("Expected the image layer to be stored under the root")
+	}
+	if diff := d.diffPath("2"); diff != path.Join(rwRoot, "diff", "2") {
+		t.Fatalf("Expected the read-write layer to be stored under %s, got %s", rwRoot, diff)
+	}
+
+	if err := d.Remove("2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{path.Join(tmp, "diff", "2"), path.Join(rwRoot, "diff", "2")} {
+		if _, err := os.Lstat(p); err == nil {
+			t.Fatalf("Expected %s to be removed", p)
+		}
+	}
+}
+
 func TestCleanupWithNoDirs(t *testing.T) {
 	d := newDriver(t)
 	defer os.RemoveAll(tmp)
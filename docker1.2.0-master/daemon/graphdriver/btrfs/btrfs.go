@@ -61,6 +61,10 @@ func (d *Driver) Status() [][2]string {
 	return nil
 }
 
+func (d *Driver) Capabilities() graphdriver.Capabilities {
+	return graphdriver.Capabilities{}
+}
+
 func (d *Driver) Cleanup() error {
 	return mount.Unmount(d.home)
 }
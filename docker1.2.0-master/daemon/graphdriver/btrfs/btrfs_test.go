@@ -23,6 +23,10 @@ func TestBtrfsCreateSnap(t *testing.T) {
 	graphtest.DriverTestCreateSnap(t, "btrfs")
 }
 
+func TestBtrfsCapabilities(t *testing.T) {
+	graphtest.DriverTestCapabilities(t, "btrfs")
+}
+
 func TestBtrfsTeardown(t *testing.T) {
 	graphtest.PutDriver(t)
 }
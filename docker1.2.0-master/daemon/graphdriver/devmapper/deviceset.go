@@ -80,6 +80,7 @@ type DeviceSet struct {
 	dataDevice           string
 	metadataDevice       string
 	doBlkDiscard         bool
+	doMountDiscard       bool
 	thinpBlockSize       uint32
 }
 
@@ -977,8 +978,10 @@ func (devices *DeviceSet) MountDevice(hash, path, mountLabel string) error {
 	options = joinMountOptions(options, devices.mountOptions)
 	options = joinMountOptions(options, label.FormatMountLabel("", mountLabel))
 
-	err = syscall.Mount(info.DevName(), path, fstype, flags, joinMountOptions("discard", options))
-	if err != nil && err == syscall.EINVAL {
+	if devices.doMountDiscard {
+		err = syscall.Mount(info.DevName(), path, fstype, flags, joinMountOptions("discard", options))
+	}
+	if !devices.doMountDiscard || (err != nil && err == syscall.EINVAL) {
 		err = syscall.Mount(info.DevName(), path, fstype, flags, options)
 	}
 	if err != nil {
@@ -1151,6 +1154,51 @@ func (devices *DeviceSet) Status() *Status {
 	return status
 }
 
+// validateOptions checks the options make a usable thin pool before
+// anything gets created.
+func (devices *DeviceSet) validateOptions() error {
+	// dm-thin requires a block size multiple of 64K, between 64K and 1G
+	const minBlockSize, maxBlockSize = 128, 2097152 // 512b sectors
+	if devices.thinpBlockSize < minBlockSize || devices.thinpBlockSize > maxBlockSize || devices.thinpBlockSize%minBlockSize != 0 {
+		return fmt.Errorf("Invalid dm.blocksize %d, must be a multiple of 64K between 64K and 1G", int64(devices.thinpBlockSize)<<9)
+	}
+	if devices.baseFsSize == 0 {
+		return fmt.Errorf("Invalid dm.basesize, must be greater than 0")
+	}
+	if devices.dataDevice == "" && devices.dataLoopbackSize <= 0 {
+		return fmt.Errorf("Invalid dm.loopdatasize, must be greater than 0")
+	}
+	if devices.metadataDevice == "" && devices.metaDataLoopbackSize <= 0 {
+		return fmt.Errorf("Invalid dm.loopmetadatasize, must be greater than 0")
+	}
+	for opt, dev := range map[string]string{"dm.datadev": devices.dataDevice, "dm.metadatadev": devices.metadataDevice} {
+		if dev == "" {
+			continue
+		}
+		if err := checkBlockDevice(dev); err != nil {
+			return fmt.Errorf("Invalid %s: %s", opt, err)
+		}
+	}
+	if devices.dataDevice != "" && devices.dataDevice == devices.metadataDevice {
+		return fmt.Errorf("dm.datadev and dm.metadatadev must be different devices")
+	}
+	if (devices.dataDevice == "") != (devices.metadataDevice == "") {
+		log.Infof("Only one of dm.datadev and dm.metadatadev is set, a loopback file is used for the other one")
+	}
+	return nil
+}
+
+func checkBlockDevice(dev string) error {
+	fi, err := os.Stat(dev)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeDevice == 0 || fi.Mode()&os.ModeCharDevice != 0 {
+		return fmt.Errorf("%s is not a block device", dev)
+	}
+	return nil
+}
+
 func NewDeviceSet(root string, doInit bool, options []string) (*DeviceSet, error) {
 	SetDevDir("/dev")
 
@@ -1162,6 +1210,7 @@ func NewDeviceSet(root string, doInit bool, options []string) (*DeviceSet, error
 		baseFsSize:           DefaultBaseFsSize,
 		filesystem:           "ext4",
 		doBlkDiscard:         true,
+		doMountDiscard:       true,
 		thinpBlockSize:       DefaultThinpBlockSize,
 	}
 
@@ -1210,6 +1259,11 @@ func NewDeviceSet(root string, doInit bool, options []string) (*DeviceSet, error
 			if err != nil {
 				return nil, err
 			}
+		case "dm.mountdiscard":
+			devices.doMountDiscard, err = strconv.ParseBool(val)
+			if err != nil {
+				return nil, err
+			}
 		case "dm.blocksize":
 			size, err := units.RAMInBytes(val)
 			if err != nil {
@@ -1227,6 +1281,10 @@ func NewDeviceSet(root string, doInit bool, options []string) (*DeviceSet, error
 		devices.doBlkDiscard = false
 	}
 
+	if err := devices.validateOptions(); err != nil {
+		return nil, err
+	}
+
 	if err := devices.initDevmapper(doInit); err != nil {
 		return nil, err
 	}
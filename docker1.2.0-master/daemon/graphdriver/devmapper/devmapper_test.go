@@ -32,6 +32,39 @@ func TestDevmapperCreateSnap(t *testing.T) {
 	graphtest.DriverTestCreateSnap(t, "devicemapper")
 }
 
+func TestDevmapperCapabilities(t *testing.T) {
+	graphtest.DriverTestCapabilities(t, "devicemapper")
+}
+
 func TestDevmapperTeardown(t *testing.T) {
 	graphtest.PutDriver(t)
 }
+
+func TestDevmapperValidateOptions(t *testing.T) {
+	valid := func() *DeviceSet {
+		return &DeviceSet{
+			dataLoopbackSize:     DefaultDataLoopbackSize,
+			metaDataLoopbackSize: DefaultMetaDataLoopbackSize,
+			baseFsSize:           DefaultBaseFsSize,
+			thinpBlockSize:       DefaultThinpBlockSize,
+		}
+	}
+	if err := valid().validateOptions(); err != nil {
+		t.Fatal(err)
+	}
+	for _, invalid := range []func(*DeviceSet){
+		func(d *DeviceSet) { d.thinpBlockSize = 64 },
+		func(d *DeviceSet) { d.thinpBlockSize = 192 },
+		func(d *DeviceSet) { d.thinpBlockSize = 4194304 },
+		func(d *DeviceSet) { d.baseFsSize = 0 },
+		func(d *DeviceSet) { d.dataLoopbackSize = 0 },
+		func(d *DeviceSet) { d.dataDevice = "/dev/null" },
+		func(d *DeviceSet) { d.metadataDevice = "/nonexistent" },
+	} {
+		devices := valid()
+		invalid(devices)
+		if err := devices.validateOptions(); err == nil {
+			t.Fatalf("Expected an error for %+v", devices)
+		}
+	}
+}
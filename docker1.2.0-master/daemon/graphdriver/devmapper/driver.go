@@ -61,10 +61,27 @@ func (d *Driver) Status() [][2]string {
 		{"Data Space Total", fmt.Sprintf("%.1f Mb", float64(s.Data.Total)/(1024*1024))},
 		{"Metadata Space Used", fmt.Sprintf("%.1f Mb", float64(s.Metadata.Used)/(1024*1024))},
 		{"Metadata Space Total", fmt.Sprintf("%.1f Mb", float64(s.Metadata.Total)/(1024*1024))},
+		{"Data Space Available", fmt.Sprintf("%.1f Mb", float64(s.Data.Total-s.Data.Used)/(1024*1024))},
+		{"Metadata Space Available", fmt.Sprintf("%.1f Mb", float64(s.Metadata.Total-s.Metadata.Used)/(1024*1024))},
+		{"Data Space Usage", poolUsage(s.Data)},
+		{"Metadata Space Usage", poolUsage(s.Metadata)},
 	}
 	return status
 }
 
+// Capabilities tells the layers are thin devices of the base size.
+func (d *Driver) Capabilities() graphdriver.Capabilities {
+	return graphdriver.Capabilities{Quota: true}
+}
+
+// poolUsage returns the percentage of the space of the pool used.
+func poolUsage(u DiskUsage) string {
+	if u.Total == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%.1f%%", float64(u.Used)*100/float64(u.Total))
+}
+
 func (d *Driver) Cleanup() error {
 	err := d.DeviceSet.Shutdown()
 
@@ -30,10 +30,39 @@ type Driver interface {
 	Exists(id string) bool
 
 	Status() [][2]string
+	// Capabilities returns the optional features of the driver.
+	Capabilities() Capabilities
 
 	Cleanup() error
 }
 
+// Capabilities are the optional features of a driver, for the clients to
+// detect rather than fail using them.
+type Capabilities struct {
+	Diff     bool // computes the diffs of its layers natively, implementing Differ
+	List     bool // lists its layers, implementing Lister
+	RwLayers bool // stores the read-write layers apart, implementing RwLayersDriver
+	Quota    bool // limits the size of every layer
+}
+
+// RwLayersDriver is implemented by the drivers able to store the read-write
+// layers of containers under a root of their own, such as a local disk while
+// the image layers are on shared storage.
+type RwLayersDriver interface {
+	Driver
+
+	// SetRwLayersRoot sets the root of the layers created with CreateRw.
+	SetRwLayersRoot(root string) error
+	// CreateRw creates a layer like Create, its content being stored under
+	// the read-write layers root.
+	CreateRw(id, parent string) error
+}
+
+// Lister is implemented by the drivers able to list the IDs of their layers.
+type Lister interface {
+	List() ([]string, error)
+}
+
 type Differ interface {
 	Diff(id string) (archive.Archive, error)
 	Changes(id string) ([]archive.Change, error)
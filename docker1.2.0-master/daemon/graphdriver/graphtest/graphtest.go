@@ -227,3 +227,20 @@ func DriverTestCreateSnap(t *testing.T, drivername string) {
 		t.Fatal(err)
 	}
 }
+
+// DriverTestCapabilities checks that the driver has the capabilities of the
+// optional interfaces it implements, and only those.
+func DriverTestCapabilities(t *testing.T, drivername string) {
+	// the interfaces of the driver itself, not of its test wrapper
+	driver := GetDriver(t, drivername).(*Driver).Driver
+	defer PutDriver(t)
+
+	capabilities := driver.Capabilities()
+	_, differ := driver.(graphdriver.Differ)
+	_, lister := driver.(graphdriver.Lister)
+	_, rwLayers := driver.(graphdriver.RwLayersDriver)
+	if capabilities.Diff != differ || capabilities.List != lister || capabilities.RwLayers != rwLayers {
+		t.Fatalf("Capabilities %+v don't match the interfaces of the driver (Differ: %v, Lister: %v, RwLayersDriver: %v)",
+			capabilities, differ, lister, rwLayers)
+	}
+}
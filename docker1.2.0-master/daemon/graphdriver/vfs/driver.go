@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/docker/docker/daemon/graphdriver"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
@@ -21,7 +22,8 @@ func Init(home string, options []string) (graphdriver.Driver, error) {
 }
 
 type Driver struct {
-	home string
+	home   string
+	rwRoot string // root of the read-write layers, if set
 }
 
 func (d *Driver) String() string {
@@ -29,6 +31,23 @@ func (d *Driver) String() string {
 }
 
 func (d *Driver) Status() [][2]string {
+	if d.rwRoot != "" {
+		return [][2]string{{"Read-Write Layers Dir", d.rwRoot}}
+	}
+	return nil
+}
+
+func (d *Driver) Capabilities() graphdriver.Capabilities {
+	return graphdriver.Capabilities{List: true, RwLayers: true}
+}
+
+// SetRwLayersRoot sets the root where the layers created with CreateRw are
+// stored.
+func (d *Driver) SetRwLayersRoot(root string) error {
+	if err := os.MkdirAll(path.Join(root, "dir"), 0700); err != nil {
+		return err
+	}
+	d.rwRoot = root
 	return nil
 }
 
@@ -67,6 +86,31 @@ func (d *Driver) Create(id, parent string) error {
 	if err := os.Mkdir(dir, 0755); err != nil {
 		return err
 	}
+	return d.copyParent(dir, parent)
+}
+
+// CreateRw creates a layer like Create, stored under the read-write layers
+// root when one is set. Its directory in the home of the driver is a
+// symlink to it.
+func (d *Driver) CreateRw(id, parent string) error {
+	if d.rwRoot == "" {
+		return d.Create(id, parent)
+	}
+	dir := path.Join(d.rwRoot, "dir", path.Base(id))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(d.dir(id)), 0700); err != nil {
+		return err
+	}
+	if err := os.Symlink(dir, d.dir(id)); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return d.copyParent(dir, parent)
+}
+
+func (d *Driver) copyParent(dir, parent string) error {
 	if parent == "" {
 		return nil
 	}
@@ -88,11 +132,19 @@ func (d *Driver) Remove(id string) error {
 	if _, err := os.Stat(d.dir(id)); err != nil {
 		return err
 	}
+	if target, err := os.Readlink(d.dir(id)); err == nil {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
 	return os.RemoveAll(d.dir(id))
 }
 
 func (d *Driver) Get(id, mountLabel string) (string, error) {
 	dir := d.dir(id)
+	if target, err := os.Readlink(dir); err == nil {
+		dir = target
+	}
 	if st, err := os.Stat(dir); err != nil {
 		return "", err
 	} else if !st.IsDir() {
@@ -101,6 +153,22 @@ func (d *Driver) Get(id, mountLabel string) (string, error) {
 	return dir, nil
 }
 
+// List returns the IDs of the layers of the driver.
+func (d *Driver) List() ([]string, error) {
+	dirs, err := ioutil.ReadDir(path.Join(d.home, "dir"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ids := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		ids = append(ids, dir.Name())
+	}
+	return ids, nil
+}
+
 func (d *Driver) Put(id string) {
 	// The vfs driver has no runtime resources (e.g. mounts)
 	// to clean up, so we don't need anything here
@@ -23,6 +23,10 @@ func TestVfsCreateSnap(t *testing.T) {
 	graphtest.DriverTestCreateSnap(t, "vfs")
 }
 
+func TestVfsCapabilities(t *testing.T) {
+	graphtest.DriverTestCapabilities(t, "vfs")
+}
+
 func TestVfsTeardown(t *testing.T) {
 	graphtest.PutDriver(t)
 }
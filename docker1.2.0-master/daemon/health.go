@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/runconfig"
+)
+
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 30 * time.Second
+	defaultHealthRetries  = 3
+	// maxHealthOutput is the size of the end of the output of a check kept
+	maxHealthOutput = 4096
+
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// Health is the result of the checks of a running container.
+type Health struct {
+	Status        string
+	FailingStreak int
+	LastCheck     time.Time
+	LastExitCode  int
+	LastOutput    string
+}
+
+// update records the result of a check, the container being unhealthy after
+// retries failures in a row. It returns whether the status changed.
+func (h *Health) update(exitCode int, output string, retries int) bool {
+	status := h.Status
+	h.LastCheck = time.Now().UTC()
+	h.LastExitCode = exitCode
+	h.LastOutput = output
+	if exitCode == 0 {
+		h.FailingStreak = 0
+		h.Status = HealthHealthy
+	} else {
+		h.FailingStreak++
+		if h.FailingStreak >= retries {
+			h.Status = HealthUnhealthy
+		}
+	}
+	return status != h.Status
+}
+
+// startHealthcheck checks the health of the container while it runs, if its
+// config has a check and the execution driver can run it.
+func (container *Container) startHealthcheck() {
+	check := container.Config.Healthcheck
+	if check.Disabled() {
+		container.State.setHealth(nil)
+		return
+	}
+	execer, ok := container.daemon.execDriver.(execdriver.Execer)
+	if !ok {
+		daemonLog.Infof("%s: The %s execution driver can't run health checks", container.ID, container.daemon.execDriver.Name())
+		container.State.setHealth(nil)
+		return
+	}
+	container.State.setHealth(&Health{Status: HealthStarting})
+	stop := make(chan struct{})
+	container.healthStop = stop
+	go container.monitorHealth(execer, check, stop)
+}
+
+// stopHealthcheck stops the checks of the container, which exited.
+func (container *Container) stopHealthcheck() {
+	if container.healthStop != nil {
+		close(container.healthStop)
+		container.healthStop = nil
+	}
+}
+
+func (container *Container) monitorHealth(execer execdriver.Execer, check *runconfig.HealthConfig, stop chan struct{}) {
+	var (
+		interval = defaultHealthInterval
+		timeout  = defaultHealthTimeout
+		retries  = defaultHealthRetries
+	)
+	if check.Interval > 0 {
+		interval = check.Interval
+	}
+	if check.Timeout > 0 {
+		timeout = check.Timeout
+	}
+	if check.Retries > 0 {
+		retries = check.Retries
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+		exitCode, output := container.probeHealth(execer, check.Test, timeout)
+		select {
+		case <-stop:
+			// The failure of the check may be the exit of the container
+			return
+		default:
+		}
+		if status, changed := container.State.updateHealth(exitCode, output, retries); changed {
+			container.LogEvent("health_status: " + status)
+		}
+	}
+}
+
+// probeHealth runs the check in the container, killing it after timeout. It
+// returns the exit code of the check, -1 if it couldn't run, and the end of
+// its output.
+func (container *Container) probeHealth(execer execdriver.Execer, args []string, timeout time.Duration) (int, string) {
+	var (
+		output   bytes.Buffer
+		exitCode int
+		err      error
+		started  = make(chan *exec.Cmd, 1)
+		done     = make(chan struct{})
+	)
+	go func() {
+		pipes := &execdriver.Pipes{Stdout: &output, Stderr: &output}
+		exitCode, err = execer.Exec(container.command, args, pipes, func(cmd *exec.Cmd) { started <- cmd })
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		select {
+		case cmd := <-started:
+			cmd.Process.Kill()
+			<-done
+		case <-done:
+		}
+		return -1, fmt.Sprintf("Health check exceeded the timeout of %s", timeout)
+	}
+	if err != nil {
+		return -1, err.Error()
+	}
+	out := output.String()
+	if len(out) > maxHealthOutput {
+		out = out[len(out)-maxHealthOutput:]
+	}
+	return exitCode, out
+}
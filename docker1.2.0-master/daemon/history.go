@@ -14,6 +14,9 @@ func (history *History) Len() int {
 
 func (history *History) Less(i, j int) bool {
 	containers := *history
+	if containers[i].Created.Equal(containers[j].Created) {
+		return containers[i].ID > containers[j].ID
+	}
 	return containers[j].Created.Before(containers[i].Created)
 }
 
@@ -0,0 +1,218 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/image"
+)
+
+// imagesGCGracePeriod protects the images registered recently, such as the
+// layers of a pull in progress, which are not tagged yet.
+var imagesGCGracePeriod = time.Hour
+
+// ImagesGCPolicy selects the images removed by the image garbage collection.
+// The images used by a container are always kept, with their parents.
+type ImagesGCPolicy struct {
+	// MaxAge keeps the images created less than MaxAge ago, 0 for no limit
+	MaxAge time.Duration
+	// KeepTags keeps the KeepTags most recent tags of each repository,
+	// 0 to keep all the tagged images
+	KeepTags int
+}
+
+// imagesGCResult lists the tags and images removed by a garbage collection.
+type imagesGCResult struct {
+	Untagged []string
+	Deleted  []string
+}
+
+// repositoryTag is a tag and the image it references.
+type repositoryTag struct {
+	repo, tag string
+	img       *image.Image
+}
+
+// keptTags returns the IDs of the images referenced by the tags the policy
+// keeps, and the tags which are not kept.
+func (daemon *Daemon) keptTags(images map[string]*image.Image, policy *ImagesGCPolicy) (map[string]struct{}, []repositoryTag) {
+	store := daemon.Repositories()
+	store.Lock()
+	repos := make(map[string][]repositoryTag)
+	for repoName, repository := range store.Repositories {
+		for tag, id := range repository {
+			if img, exists := images[id]; exists {
+				repos[repoName] = append(repos[repoName], repositoryTag{repoName, tag, img})
+			}
+		}
+	}
+	store.Unlock()
+
+	kept := make(map[string]struct{})
+	var expired []repositoryTag
+	for _, tags := range repos {
+		sort.Sort(byCreated(tags))
+		for i, t := range tags {
+			if policy.KeepTags > 0 && i >= policy.KeepTags {
+				expired = append(expired, t)
+			} else {
+				kept[t.img.ID] = struct{}{}
+			}
+		}
+	}
+	return kept, expired
+}
+
+// byCreated sorts the tags from the most recent image, then by name.
+type byCreated []repositoryTag
+
+func (s byCreated) Len() int      { return len(s) }
+func (s byCreated) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byCreated) Less(i, j int) bool {
+	if !s[i].img.Created.Equal(s[j].img.Created) {
+		return s[i].img.Created.After(s[j].img.Created)
+	}
+	return s[i].tag < s[j].tag
+}
+
+// collectImages removes the images the policy doesn't keep, children first,
+// along with their tags. With dryRun, it only returns what would be removed.
+func (daemon *Daemon) collectImages(policy *ImagesGCPolicy, dryRun bool) (*imagesGCResult, error) {
+	images, err := daemon.Graph().Map()
+	if err != nil {
+		return nil, err
+	}
+
+	kept, expired := daemon.keptTags(images, policy)
+	for _, container := range daemon.List() {
+		kept[container.Image] = struct{}{}
+	}
+	for id, img := range images {
+		if policy.MaxAge > 0 && time.Since(img.Created) < policy.MaxAge {
+			kept[id] = struct{}{}
+		} else if fi, err := os.Stat(daemon.Graph().ImageRoot(id)); err == nil && time.Since(fi.ModTime()) < imagesGCGracePeriod {
+			kept[id] = struct{}{}
+		}
+	}
+	// The parents of the images kept are kept as well
+	for id := range kept {
+		for img := images[id]; img != nil && img.Parent != ""; {
+			kept[img.Parent] = struct{}{}
+			img = images[img.Parent]
+		}
+	}
+
+	result := &imagesGCResult{Untagged: []string{}, Deleted: []string{}}
+	for _, t := range expired {
+		if _, exists := kept[t.img.ID]; exists {
+			continue
+		}
+		if !dryRun {
+			if _, err := daemon.Repositories().Delete(t.repo, t.tag); err != nil {
+				return result, err
+			}
+			daemon.logImageEvent("untag", t.img.ID)
+		}
+		result.Untagged = append(result.Untagged, t.repo+":"+t.tag)
+	}
+
+	var deleted []*image.Image
+	for id, img := range images {
+		if _, exists := kept[id]; !exists {
+			deleted = append(deleted, img)
+		}
+	}
+	depths := make(map[string]int)
+	for _, img := range deleted {
+		for p := img; p != nil && p.Parent != ""; p = images[p.Parent] {
+			depths[img.ID]++
+		}
+	}
+	sort.Sort(byDepth{deleted, depths})
+	for _, img := range deleted {
+		if !dryRun {
+			if err := daemon.Graph().Delete(img.ID); err != nil {
+				return result, err
+			}
+			daemon.logImageEvent("delete", img.ID)
+		}
+		result.Deleted = append(result.Deleted, img.ID)
+	}
+	return result, nil
+}
+
+// byDepth sorts the images from the deepest one, so that the children are
+// deleted before their parents.
+type byDepth struct {
+	images []*image.Image
+	depths map[string]int
+}
+
+func (s byDepth) Len() int      { return len(s.images) }
+func (s byDepth) Swap(i, j int) { s.images[i], s.images[j] = s.images[j], s.images[i] }
+func (s byDepth) Less(i, j int) bool {
+	return s.depths[s.images[i].ID] > s.depths[s.images[j].ID]
+}
+
+func (daemon *Daemon) logImageEvent(action, id string) {
+	if daemon.eng != nil {
+		daemon.eng.Job("log", action, id, "").Run()
+	}
+}
+
+// reapImages periodically removes the images the policy doesn't keep.
+func (daemon *Daemon) reapImages(interval time.Duration, policy *ImagesGCPolicy) {
+	for _ = range time.Tick(interval) {
+		result, err := daemon.collectImages(policy, false)
+		if err != nil {
+			daemonLog.Errorf("Error collecting images: %s", err)
+		}
+		if result != nil && len(result.Deleted) > 0 {
+			daemonLog.Infof("Collected %d unused image(s)", len(result.Deleted))
+		}
+	}
+}
+
+// ImagesGC removes the images no container uses and the policy of the
+// daemon doesn't keep. The "maxage" and "keeptags" env override the policy,
+// and with "dryrun" set nothing is removed.
+func (daemon *Daemon) ImagesGC(job *engine.Job) engine.Status {
+	if len(job.Args) != 0 {
+		return job.Errorf("Usage: %s", job.Name)
+	}
+	policy := &ImagesGCPolicy{
+		MaxAge:   daemon.config.ImagesGCMaxAge,
+		KeepTags: daemon.config.ImagesGCKeepTags,
+	}
+	if job.EnvExists("maxage") {
+		maxAge, err := time.ParseDuration(job.Getenv("maxage"))
+		if err != nil {
+			return job.Errorf("Invalid maxage: %s", err)
+		}
+		policy.MaxAge = maxAge
+	}
+	if job.EnvExists("keeptags") {
+		policy.KeepTags = job.GetenvInt("keeptags")
+	}
+	if policy.MaxAge < 0 || policy.KeepTags < 0 {
+		return job.Errorf("Invalid policy, maxage and keeptags can't be negative")
+	}
+
+	result, err := daemon.collectImages(policy, job.GetenvBool("dryrun"))
+	if err != nil {
+		if result != nil {
+			err = fmt.Errorf("%s (%d image(s) removed)", err, len(result.Deleted))
+		}
+		return job.Errorf("Error collecting images: %s", err)
+	}
+	out := &engine.Env{}
+	out.SetList("Untagged", result.Untagged)
+	out.SetList("Deleted", result.Deleted)
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/archive"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/graph"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/utils"
+)
+
+// mkTestImagesDaemon returns a daemon with an empty vfs graph in root, and a
+// function registering an image created age ago with a layer of size bytes.
+func mkTestImagesDaemon(t *testing.T, root string) (*Daemon, func(parent string, age time.Duration, size int) string) {
+	driver, err := graphdriver.GetDriver("vfs", root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := graph.NewGraph(filepath.Join(root, "graph"), driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := graph.NewTagStore(filepath.Join(root, "repositories"), g, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := &Daemon{
+		containers:   &contStore{s: make(map[string]*Container)},
+		graph:        g,
+		driver:       driver,
+		repositories: store,
+	}
+
+	register := func(parent string, age time.Duration, size int) string {
+		id := utils.GenerateRandomID()
+		dir := filepath.Join(root, "layers", id)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, id), make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		layer, err := archive.Tar(dir, archive.Uncompressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer layer.Close()
+		img := &image.Image{ID: id, Parent: parent, Created: time.Now().Add(-age)}
+		if err := g.Register(nil, layer, img); err != nil {
+			t.Fatal(err)
+		}
+		return img.ID
+	}
+	return daemon, register
+}
+
+func TestCollectImages(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-images-gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	daemon, register := mkTestImagesDaemon(t, root)
+	g, store := daemon.graph, daemon.repositories
+
+	day := 24 * time.Hour
+	var (
+		base   = register("", 10*day, 0)
+		used   = register(base, 5*day, 0)
+		v1     = register(base, 3*day, 0)
+		v2     = register(base, 2*day, 0)
+		v3     = register(base, day, 0)
+		orphan = register("", 5*day, 0)
+		child  = register(orphan, 4*day, 0)
+		recent = register(base, time.Hour, 0)
+	)
+	for tag, id := range map[string]string{"v1": v1, "v2": v2, "v3": v3} {
+		if err := store.Set("app", tag, id, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	daemon.containers.Add("aaa", &Container{ID: "aaa", Image: used})
+
+	policy := &ImagesGCPolicy{MaxAge: 12 * time.Hour, KeepTags: 2}
+
+	// The images are too recently registered to be collected
+	if result, err := daemon.collectImages(policy, false); err != nil || len(result.Deleted) != 0 {
+		t.Fatalf("Expected no image to be collected, got %v: %v", result, err)
+	}
+
+	defer func(d time.Duration) { imagesGCGracePeriod = d }(imagesGCGracePeriod)
+	imagesGCGracePeriod = 0
+
+	expected := []string{v1, orphan, child}
+	sort.Strings(expected)
+	check := func(result *imagesGCResult) {
+		if len(result.Untagged) != 1 || result.Untagged[0] != "app:v1" {
+			t.Fatalf("Expected app:v1 to be untagged, got %v", result.Untagged)
+		}
+		if len(result.Deleted) != 3 || result.Deleted[2] != orphan {
+			t.Fatalf("Expected the children to be deleted first, got %v", result.Deleted)
+		}
+		deleted := append([]string{}, result.Deleted...)
+		sort.Strings(deleted)
+		for i := range expected {
+			if deleted[i] != expected[i] {
+				t.Fatalf("Expected %v to be deleted, got %v", expected, result.Deleted)
+			}
+		}
+	}
+
+	result, err := daemon.collectImages(policy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(result)
+	if !g.Exists(v1) || !g.Exists(orphan) {
+		t.Fatal("Expected a dry run to keep the images")
+	}
+
+	if result, err = daemon.collectImages(policy, false); err != nil {
+		t.Fatal(err)
+	}
+	check(result)
+	for _, id := range expected {
+		if g.Exists(id) {
+			t.Fatalf("Expected %s to be deleted", id)
+		}
+	}
+	for _, id := range []string{base, used, v2, v3, recent} {
+		if !g.Exists(id) {
+			t.Fatalf("Expected %s to be kept", id)
+		}
+	}
+	if img, err := store.GetImage("app", "v1"); err != nil || img != nil {
+		t.Fatal("Expected app:v1 to be untagged")
+	}
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/image"
+)
+
+// layerSize returns the disk space used by the layer of img. Drivers able to
+// diff their layers measure it, the size computed by the driver when the
+// image was registered is used otherwise.
+func (daemon *Daemon) layerSize(img *image.Image) (int64, error) {
+	if differ, ok := daemon.driver.(graphdriver.Differ); ok {
+		return differ.DiffSize(img.ID)
+	}
+	if img.Size < 0 {
+		return 0, nil
+	}
+	return img.Size, nil
+}
+
+// imagesUsage computes the disk usage of the images in the graph.
+type imagesUsage struct {
+	images   map[string]*image.Image
+	sizes    map[string]int64
+	children map[string][]string
+	// refs counts the referenced images, tagged, used by a container or
+	// without children, in the subtree of each image
+	refs       map[string]int
+	containers map[string][]string
+}
+
+func (daemon *Daemon) computeImagesUsage() (*imagesUsage, error) {
+	images, err := daemon.Graph().Map()
+	if err != nil {
+		return nil, err
+	}
+	u := &imagesUsage{
+		images:     images,
+		sizes:      make(map[string]int64),
+		children:   make(map[string][]string),
+		refs:       make(map[string]int),
+		containers: make(map[string][]string),
+	}
+	for id, img := range images {
+		if u.sizes[id], err = daemon.layerSize(img); err != nil {
+			return nil, err
+		}
+		if img.Parent != "" {
+			u.children[img.Parent] = append(u.children[img.Parent], id)
+		}
+	}
+
+	referenced := make(map[string]struct{})
+	for id := range daemon.Repositories().ByID() {
+		referenced[id] = struct{}{}
+	}
+	for _, container := range daemon.List() {
+		referenced[container.Image] = struct{}{}
+		for _, id := range u.chain(container.Image) {
+			u.containers[id] = append(u.containers[id], container.ID)
+		}
+	}
+	for id := range images {
+		if len(u.children[id]) == 0 {
+			referenced[id] = struct{}{}
+		}
+	}
+	for id := range referenced {
+		for _, p := range u.chain(id) {
+			u.refs[p]++
+		}
+	}
+	return u, nil
+}
+
+// chain returns the ID of the image and of all its parents.
+func (u *imagesUsage) chain(id string) []string {
+	var ids []string
+	for img := u.images[id]; img != nil; img = u.images[img.Parent] {
+		ids = append(ids, img.ID)
+	}
+	return ids
+}
+
+// virtualSize returns the size of the image with all its parents.
+func (u *imagesUsage) virtualSize(id string) int64 {
+	var size int64
+	for _, p := range u.chain(id) {
+		size += u.sizes[p]
+	}
+	return size
+}
+
+// uniqueSize returns the size of the layers of the image and its parents
+// which no other image than the image and its children uses, that is the
+// space freed by removing the image with its children.
+func (u *imagesUsage) uniqueSize(id string) int64 {
+	var size int64
+	for _, p := range u.chain(id) {
+		if u.refs[p] != u.refs[id] {
+			break
+		}
+		size += u.sizes[p]
+	}
+	return size
+}
+
+// ImagesUsage reports the disk usage of the tagged images and of the images
+// without children, or of all the images with the "all" env. For each of
+// them, it returns the size of its layer, its total size with its parents,
+// the size it doesn't share with other images and the containers using it.
+func (daemon *Daemon) ImagesUsage(job *engine.Job) engine.Status {
+	if len(job.Args) != 0 {
+		return job.Errorf("Usage: %s", job.Name)
+	}
+	u, err := daemon.computeImagesUsage()
+	if err != nil {
+		return job.Error(err)
+	}
+	repoTags := daemon.Repositories().ByID()
+	all := job.GetenvBool("all")
+
+	outs := engine.NewTable("UniqueSize", len(u.images))
+	for id, img := range u.images {
+		tags, tagged := repoTags[id]
+		if !all && !tagged && len(u.children[id]) != 0 {
+			continue
+		}
+		if !tagged {
+			tags = []string{"<none>:<none>"}
+		}
+		containers := u.containers[id]
+		if containers == nil {
+			containers = []string{}
+		}
+		out := &engine.Env{}
+		out.Set("Id", id)
+		out.Set("ParentId", img.Parent)
+		out.SetList("RepoTags", tags)
+		out.SetInt64("Size", u.sizes[id])
+		out.SetInt64("VirtualSize", u.virtualSize(id))
+		out.SetInt64("UniqueSize", u.uniqueSize(id))
+		out.SetList("Containers", containers)
+		outs.Add(out)
+	}
+	outs.ReverseSort()
+	if _, err := outs.WriteListTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
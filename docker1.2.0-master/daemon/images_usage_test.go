@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestImagesUsage(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-images-usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	daemon, register := mkTestImagesDaemon(t, root)
+
+	var (
+		base   = register("", 0, 1000)
+		tagged = register(base, 0, 100)
+		child  = register(tagged, 0, 20)
+		used   = register(base, 0, 3)
+	)
+	if err := daemon.repositories.Set("app", "latest", tagged, false); err != nil {
+		t.Fatal(err)
+	}
+	daemon.containers.Add("aaa", &Container{ID: "aaa", Image: used})
+
+	u, err := daemon.computeImagesUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, expected := range map[string][3]int64{
+		// layer, virtual and unique sizes
+		base:   {1000, 1000, 1000},
+		tagged: {100, 1100, 100},
+		child:  {20, 1120, 20},
+		used:   {3, 1003, 3},
+	} {
+		if size, virtual, unique := u.sizes[id], u.virtualSize(id), u.uniqueSize(id); size != expected[0] || virtual != expected[1] || unique != expected[2] {
+			t.Errorf("Expected the sizes of %s to be %v, got [%d %d %d]", id, expected, size, virtual, unique)
+		}
+	}
+	for _, id := range []string{base, used} {
+		if containers := u.containers[id]; len(containers) != 1 || containers[0] != "aaa" {
+			t.Errorf("Expected %s to be used by aaa, got %v", id, containers)
+		}
+	}
+	if containers := u.containers[tagged]; len(containers) != 0 {
+		t.Errorf("Expected %s to be unused, got %v", tagged, containers)
+	}
+
+	// Without the other images, the whole base is freed with the child
+	if err := daemon.graph.Delete(used); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.repositories.Delete("app", "latest"); err != nil {
+		t.Fatal(err)
+	}
+	daemon.containers.Delete("aaa")
+	if u, err = daemon.computeImagesUsage(); err != nil {
+		t.Fatal(err)
+	}
+	if unique := u.uniqueSize(child); unique != 1120 {
+		t.Fatalf("Expected the unique size of %s to be 1120, got %d", child, unique)
+	}
+}
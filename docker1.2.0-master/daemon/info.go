@@ -1,12 +1,13 @@
 package daemon
 
 import (
+	"bytes"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/engine"
-	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/parsers/kernel"
 	"github.com/docker/docker/pkg/parsers/operatingsystem"
 	"github.com/docker/docker/registry"
@@ -31,7 +32,7 @@ func (daemon *Daemon) CmdInfo(job *engine.Job) engine.Status {
 		operatingSystem = s
 	}
 	if inContainer, err := operatingsystem.IsContainerized(); err != nil {
-		log.Errorf("Could not determine if daemon is containerized: %v", err)
+		daemonLog.Errorf("Could not determine if daemon is containerized: %v", err)
 		operatingSystem += " (error determining if containerized)"
 	} else if inContainer {
 		operatingSystem += " (containerized)"
@@ -49,6 +50,12 @@ func (daemon *Daemon) CmdInfo(job *engine.Job) engine.Status {
 	if err := cjob.Run(); err != nil {
 		return job.Error(err)
 	}
+	var handlers bytes.Buffer
+	hjob := job.Eng.Job("commands")
+	hjob.Stdout.Add(&handlers)
+	if err := hjob.Run(); err != nil {
+		return job.Error(err)
+	}
 	v := &engine.Env{}
 	v.SetInt("Containers", len(daemon.List()))
 	v.SetInt("Images", imgcount)
@@ -56,17 +63,25 @@ func (daemon *Daemon) CmdInfo(job *engine.Job) engine.Status {
 	v.SetJson("DriverStatus", daemon.GraphDriver().Status())
 	v.SetBool("MemoryLimit", daemon.SystemConfig().MemoryLimit)
 	v.SetBool("SwapLimit", daemon.SystemConfig().SwapLimit)
+	v.SetList("CgroupControllers", daemon.SystemConfig().Controllers())
+	v.SetBool("CgroupUnified", daemon.SystemConfig().CgroupUnified)
 	v.SetBool("IPv4Forwarding", !daemon.SystemConfig().IPv4ForwardingDisabled)
 	v.SetBool("Debug", os.Getenv("DEBUG") != "")
 	v.SetInt("NFd", utils.GetTotalUsedFds())
 	v.SetInt("NGoroutines", runtime.NumGoroutine())
 	v.Set("ExecutionDriver", daemon.ExecutionDriver().Name())
 	v.SetInt("NEventsListener", env.GetInt("count"))
+	v.SetList("Handlers", strings.Fields(handlers.String()))
+	v.SetJson("DriverCapabilities", daemon.GraphDriver().Capabilities())
+	v.SetJson("ExecutionDriverCapabilities", daemon.ExecutionDriver().Capabilities())
 	v.Set("KernelVersion", kernelVersion)
 	v.Set("OperatingSystem", operatingSystem)
 	v.Set("IndexServerAddress", registry.IndexServerAddress())
 	v.Set("InitSha1", dockerversion.INITSHA1)
 	v.Set("InitPath", initPath)
+	_, logsSize := daemon.logDiskUsage()
+	v.SetInt64("LogsSize", logsSize)
+	v.SetInt64("LogsSizeMax", daemon.logDiskMax)
 	if _, err := v.WriteTo(job.Stdout); err != nil {
 		return job.Error(err)
 	}
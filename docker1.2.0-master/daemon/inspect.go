@@ -36,6 +36,7 @@ func (daemon *Daemon) ContainerInspect(job *engine.Job) engine.Status {
 		out.SetJson("Config", container.Config)
 		out.SetJson("State", container.State)
 		out.Set("Image", container.Image)
+		out.Set("ImageDigest", container.ImageDigest)
 		out.SetJson("NetworkSettings", container.NetworkSettings)
 		out.Set("ResolvConfPath", container.ResolvConfPath)
 		out.Set("HostnamePath", container.HostnamePath)
@@ -3,6 +3,7 @@ package daemon
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -12,6 +13,23 @@ import (
 	"github.com/docker/docker/pkg/parsers/filters"
 )
 
+// acceptedPsFilters are the filters of the containers job.
+var acceptedPsFilters = map[string]struct{}{
+	"exited":   {},
+	"status":   {},
+	"name":     {},
+	"ancestor": {},
+	"label":    {},
+}
+
+// containerStatuses are the values of the status filter.
+var containerStatuses = map[string]struct{}{
+	"running":    {},
+	"paused":     {},
+	"restarting": {},
+	"exited":     {},
+}
+
 // List returns an array of all containers registered in the daemon.
 func (daemon *Daemon) List() []*Container {
 	return daemon.containers.List()
@@ -21,20 +39,54 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 	var (
 		foundBefore bool
 		displayed   int
+		skipped     int
 		all         = job.GetenvBool("all")
 		since       = job.Getenv("since")
 		before      = job.Getenv("before")
 		n           = job.GetenvInt("limit")
+		offset      = job.GetenvInt("offset")
 		size        = job.GetenvBool("size")
 		psFilters   filters.Args
 		filt_exited []int
+		filt_names  []*regexp.Regexp
+		ancestors   = make(map[string]struct{})
 	)
 	outs := engine.NewTable("Created", 0)
 
+	if n < 0 || offset < 0 {
+		return job.Errorf("Bad parameter: limit and offset can't be negative")
+	}
 	psFilters, err := filters.FromParam(job.Getenv("filters"))
 	if err != nil {
 		return job.Error(err)
 	}
+	for name := range psFilters {
+		if _, exists := acceptedPsFilters[name]; !exists {
+			return job.Errorf("Invalid filter '%s'", name)
+		}
+	}
+	for _, value := range psFilters["status"] {
+		if _, exists := containerStatuses[value]; !exists {
+			return job.Errorf("Invalid status filter '%s', expected running, paused, restarting or exited", value)
+		}
+		// The status filter looks at all the containers
+		all = true
+	}
+	for _, value := range psFilters["name"] {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return job.Errorf("Invalid name filter '%s': %s", value, err)
+		}
+		filt_names = append(filt_names, re)
+	}
+	for _, value := range psFilters["ancestor"] {
+		img, err := daemon.Repositories().LookupImage(value)
+		if err != nil {
+			return job.Error(err)
+		}
+		ancestors[img.ID] = struct{}{}
+	}
+	descendants := make(map[string]bool)
 	if i, ok := psFilters["exited"]; ok {
 		for _, value := range i {
 			code, err := strconv.Atoi(value)
@@ -99,6 +151,22 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 				return nil
 			}
 		}
+		if len(psFilters["status"]) > 0 && !matchStatus(container.State, psFilters["status"]) {
+			return nil
+		}
+		if len(filt_names) > 0 && !matchNames(names[container.ID], filt_names) {
+			return nil
+		}
+		if len(ancestors) > 0 && !daemon.descendsFrom(container.Image, ancestors, descendants) {
+			return nil
+		}
+		if !psFilters.MatchKVList("label", container.Config.Labels) {
+			return nil
+		}
+		if skipped < offset {
+			skipped++
+			return nil
+		}
 		displayed++
 		out := &engine.Env{}
 		out.Set("Id", container.ID)
@@ -143,9 +211,59 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 			break
 		}
 	}
-	outs.ReverseSort()
+	// The containers are already listed from the most recent one, in an
+	// order stable across calls for the pages to follow each other
 	if _, err := outs.WriteListTo(job.Stdout); err != nil {
 		return job.Error(err)
 	}
 	return engine.StatusOK
 }
+
+// matchStatus tells whether the container is in one of the statuses.
+func matchStatus(state *State, statuses []string) bool {
+	status := "exited"
+	switch {
+	case state.IsPaused():
+		status = "paused"
+	case state.IsRestarting():
+		status = "restarting"
+	case state.IsRunning():
+		status = "running"
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// matchNames tells whether one of the names of a container, without the
+// leading slash, matches one of the patterns.
+func matchNames(names []string, patterns []*regexp.Regexp) bool {
+	for _, name := range names {
+		for _, re := range patterns {
+			if re.MatchString(strings.TrimPrefix(name, "/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// descendsFrom tells whether the image is one of the ancestors or is built
+// on top of one of them. The answers are kept in descendants, as many
+// containers share their images.
+func (daemon *Daemon) descendsFrom(id string, ancestors map[string]struct{}, descendants map[string]bool) bool {
+	if result, exists := descendants[id]; exists {
+		return result
+	}
+	result := false
+	if _, exists := ancestors[id]; exists {
+		result = true
+	} else if img, err := daemon.graph.Get(id); err == nil && img.Parent != "" {
+		result = daemon.descendsFrom(img.Parent, ancestors, descendants)
+	}
+	descendants[id] = result
+	return result
+}
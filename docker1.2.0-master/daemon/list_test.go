@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatchStatus(t *testing.T) {
+	s := NewState()
+	if !matchStatus(s, []string{"exited"}) || matchStatus(s, []string{"running"}) {
+		t.Fatal("Expected a new container to be exited")
+	}
+	s.SetRunning(100)
+	if !matchStatus(s, []string{"paused", "running"}) {
+		t.Fatal("Expected a running container to match running")
+	}
+	s.SetPaused()
+	if !matchStatus(s, []string{"paused"}) || matchStatus(s, []string{"running"}) {
+		t.Fatal("Expected a paused container to only match paused")
+	}
+}
+
+func TestMatchNames(t *testing.T) {
+	names := []string{"/webapp", "/webapp/db"}
+	for _, pattern := range []string{"^webapp$", "db$", "app"} {
+		if !matchNames(names, []*regexp.Regexp{regexp.MustCompile(pattern)}) {
+			t.Fatalf("Expected %v to match %s", names, pattern)
+		}
+	}
+	if matchNames(names, []*regexp.Regexp{regexp.MustCompile("^/webapp"), regexp.MustCompile("redis")}) {
+		t.Fatalf("Expected %v to match neither ^/webapp nor redis", names)
+	}
+}
@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"strings"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/log"
+)
+
+// LogLevels sets the levels of the loggers given as NAME=LEVEL arguments,
+// like bridge=debug, and outputs the level of each logger. The loggers
+// without a level of their own follow the level of the default one.
+func (daemon *Daemon) LogLevels(job *engine.Job) engine.Status {
+	for _, arg := range job.Args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return job.Errorf("Bad parameter: %s, expected NAME=LEVEL", arg)
+		}
+		if !log.Registered(parts[0]) {
+			names := []string{}
+			for _, level := range log.Levels() {
+				names = append(names, level[0])
+			}
+			return job.Errorf("Bad parameter: unknown logger %s, expected %s", parts[0], strings.Join(names, ", "))
+		}
+		if !log.SetLevel(parts[0], parts[1]) {
+			return job.Errorf("Bad parameter: invalid log level %s for %s, expected fatal, error, info, debug or default", parts[1], parts[0])
+		}
+		daemonLog.Infof("Log level of %s set to %s", parts[0], parts[1])
+	}
+
+	outs := engine.NewTable("", 0)
+	for _, level := range log.Levels() {
+		out := &engine.Env{}
+		out.Set("Name", level[0])
+		out.Set("Level", level[1])
+		outs.Add(out)
+	}
+	if _, err := outs.WriteListTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
@@ -0,0 +1,146 @@
+package fluentd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/utils"
+)
+
+const (
+	Name = "fluentd"
+
+	defaultAddress = "localhost:24224"
+	dialTimeout    = 2 * time.Second
+	writeTimeout   = 5 * time.Second
+)
+
+func init() {
+	if err := logger.RegisterLogDriver(Name, New); err != nil {
+		panic(err)
+	}
+	if err := logger.RegisterLogOptValidator(Name, ValidateLogOpt); err != nil {
+		panic(err)
+	}
+}
+
+// Fluentd forwards every message as a [tag, time, record] event to the
+// in_forward input of a fluentd collector.
+type Fluentd struct {
+	tag         string
+	containerID string
+	name        string
+	forwarder   *logger.Forwarder
+}
+
+type sender struct {
+	address string
+	conn    net.Conn
+}
+
+// New creates a fluentd logger. The collector is set with the
+// "fluentd-address" option and the event tag with "fluentd-tag", which
+// defaults to docker.<short container id>.
+func New(ctx logger.Context) (logger.Logger, error) {
+	address, err := parseAddress(ctx.Config["fluentd-address"])
+	if err != nil {
+		return nil, err
+	}
+	tag := ctx.Config["fluentd-tag"]
+	if tag == "" {
+		tag = "docker." + utils.TruncateID(ctx.ContainerID)
+	}
+	spoolMaxSize, err := logger.SpoolMaxSize(ctx.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Fluentd{
+		tag:         tag,
+		containerID: ctx.ContainerID,
+		name:        ctx.ContainerName,
+		forwarder:   logger.NewForwarder(&sender{address: address}, ctx.SpoolPath, spoolMaxSize),
+	}, nil
+}
+
+// ValidateLogOpt checks the options of the fluentd log driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		switch key {
+		case "fluentd-address", "fluentd-tag", "spool-max-size":
+		default:
+			return fmt.Errorf("unknown log opt '%s' for %s log driver", key, Name)
+		}
+	}
+	if _, err := parseAddress(cfg["fluentd-address"]); err != nil {
+		return err
+	}
+	_, err := logger.SpoolMaxSize(cfg)
+	return err
+}
+
+func parseAddress(address string) (string, error) {
+	if address == "" {
+		return defaultAddress, nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", fmt.Errorf("Invalid fluentd-address %s: %s", address, err)
+	}
+	return address, nil
+}
+
+func (f *Fluentd) Log(msg *logger.Message) error {
+	record := map[string]string{
+		"log":            string(msg.Line),
+		"source":         msg.Source,
+		"container_id":   f.containerID,
+		"container_name": f.name,
+	}
+	event, err := json.Marshal([]interface{}{f.tag, msg.Timestamp.Unix(), record})
+	if err != nil {
+		return err
+	}
+	return f.forwarder.Forward(event)
+}
+
+func (f *Fluentd) Name() string {
+	return Name
+}
+
+func (f *Fluentd) Close() error {
+	err := f.forwarder.Close()
+	if dropped := f.forwarder.Dropped(); dropped > 0 {
+		log.Infof("%s: %d log records dropped, the fluentd spool was full", f.containerID, dropped)
+	}
+	return err
+}
+
+func (s *sender) Connect() error {
+	conn, err := net.DialTimeout("tcp", s.address, dialTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *sender) Send(event []byte) error {
+	if s.conn == nil {
+		return fmt.Errorf("Not connected to %s", s.address)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := s.conn.Write(event)
+	return err
+}
+
+func (s *sender) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
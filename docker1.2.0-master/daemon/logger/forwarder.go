@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/pkg/units"
+)
+
+const (
+	DefaultSpoolMaxSize   = 10 * 1024 * 1024
+	defaultReconnectDelay = 5 * time.Second
+)
+
+// Sender delivers encoded records to a remote log collector.
+type Sender interface {
+	Connect() error
+	Send(record []byte) error
+	Close() error
+}
+
+// Forwarder sends records through a Sender and keeps them in a local spool
+// file while the collector is unreachable. Spooled records are replayed, in
+// order, as soon as the connection is re-established. Records must not
+// contain newlines.
+//
+// The connections are made in the background, out of the lock, so that a
+// collector slow to answer doesn't block the output of the container: the
+// records are spooled meanwhile.
+type Forwarder struct {
+	sync.Mutex
+	sender         Sender
+	connected      bool
+	connecting     bool
+	closed         bool
+	lastAttempt    time.Time
+	reconnectDelay time.Duration
+	spoolPath      string
+	spoolMaxSize   int64
+	dropped        int64
+}
+
+// SpoolMaxSize returns the maximum spool size set by the "spool-max-size"
+// option, like the max-size of json-file, or DefaultSpoolMaxSize.
+func SpoolMaxSize(config map[string]string) (int64, error) {
+	s, ok := config["spool-max-size"]
+	if !ok {
+		return DefaultSpoolMaxSize, nil
+	}
+	size, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, err
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("spool-max-size must be a positive size: %s", s)
+	}
+	return size, nil
+}
+
+func NewForwarder(sender Sender, spoolPath string, spoolMaxSize int64) *Forwarder {
+	return &Forwarder{
+		sender:         sender,
+		reconnectDelay: defaultReconnectDelay,
+		spoolPath:      spoolPath,
+		spoolMaxSize:   spoolMaxSize,
+	}
+}
+
+// Forward sends record to the collector, spooling it if that's not possible.
+// It only returns an error if the record could be neither sent nor spooled.
+func (f *Forwarder) Forward(record []byte) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.ensureConnected() {
+		if err := f.flushSpool(); err == nil {
+			if err := f.sender.Send(record); err == nil {
+				return nil
+			}
+		}
+		f.disconnect()
+	}
+	return f.spool(record)
+}
+
+// Dropped returns the number of records discarded because the spool was full.
+func (f *Forwarder) Dropped() int64 {
+	f.Lock()
+	defer f.Unlock()
+	return f.dropped
+}
+
+func (f *Forwarder) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	f.closed = true
+	if f.connected {
+		f.flushSpool()
+	}
+	f.connected = false
+	if f.connecting {
+		// The connection is closed by connect once made
+		return nil
+	}
+	return f.sender.Close()
+}
+
+// ensureConnected returns whether the sender is connected, starting a
+// connection in the background if it isn't and the reconnect delay elapsed.
+func (f *Forwarder) ensureConnected() bool {
+	if f.connected {
+		return true
+	}
+	if f.connecting || f.closed || time.Since(f.lastAttempt) < f.reconnectDelay {
+		return false
+	}
+	f.lastAttempt = time.Now()
+	f.connecting = true
+	go f.connect()
+	return false
+}
+
+// connect connects the sender, whose dial has a timeout, and replays the
+// records spooled meanwhile.
+func (f *Forwarder) connect() {
+	err := f.sender.Connect()
+
+	f.Lock()
+	defer f.Unlock()
+	f.connecting = false
+	if err != nil {
+		log.Debugf("Unable to connect to log collector: %s", err)
+		return
+	}
+	if f.closed {
+		f.sender.Close()
+		return
+	}
+	f.connected = true
+	if err := f.flushSpool(); err != nil {
+		f.disconnect()
+	}
+}
+
+func (f *Forwarder) disconnect() {
+	f.connected = false
+	f.lastAttempt = time.Now()
+	if err := f.sender.Close(); err != nil {
+		log.Debugf("Error closing connection to log collector: %s", err)
+	}
+}
+
+func (f *Forwarder) spool(record []byte) error {
+	if fi, err := os.Stat(f.spoolPath); err == nil && fi.Size()+int64(len(record))+1 > f.spoolMaxSize {
+		f.dropped++
+		return nil
+	}
+	spool, err := os.OpenFile(f.spoolPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer spool.Close()
+	_, err = spool.Write(append(record, '\n'))
+	return err
+}
+
+// flushSpool replays the spooled records. Records which could not be sent
+// are kept in the spool.
+func (f *Forwarder) flushSpool() error {
+	data, err := ioutil.ReadFile(f.spoolPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	sent := 0
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) > 0 {
+			if err := f.sender.Send(line); err != nil {
+				if err := ioutil.WriteFile(f.spoolPath, data[sent:], 0600); err != nil {
+					log.Errorf("Error rewriting log spool %s: %s", f.spoolPath, err)
+				}
+				return err
+			}
+		}
+		sent += len(line) + 1
+	}
+	return os.Remove(f.spoolPath)
+}
@@ -0,0 +1,190 @@
+package logger
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSender struct {
+	sync.Mutex
+	down    bool
+	sent    []string
+	dialing chan struct{} // blocks Connect until closed, if not nil
+}
+
+func (s *fakeSender) Connect() error {
+	if s.dialing != nil {
+		<-s.dialing
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (s *fakeSender) Send(record []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		return errors.New("broken pipe")
+	}
+	s.sent = append(s.sent, string(record))
+	return nil
+}
+
+func (s *fakeSender) Close() error {
+	return nil
+}
+
+func (s *fakeSender) setDown(down bool) {
+	s.Lock()
+	s.down = down
+	s.Unlock()
+}
+
+func (s *fakeSender) records() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.sent...)
+}
+
+// waitConnection waits for the connection made in the background by f and
+// returns whether it succeeded.
+func waitConnection(t *testing.T, f *Forwarder) bool {
+	for i := 0; i < 100; i++ {
+		f.Lock()
+		connecting, connected := f.connecting, f.connected
+		f.Unlock()
+		if !connecting {
+			return connected
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timeout waiting for the connection to the collector")
+	return false
+}
+
+func TestForwarderSpoolsWhileDown(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-forwarder-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	s := &fakeSender{}
+	f := NewForwarder(s, filepath.Join(tmp, "spool"), DefaultSpoolMaxSize)
+	f.reconnectDelay = 0
+
+	// The first record is spooled while connecting
+	if err := f.Forward([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if !waitConnection(t, f) {
+		t.Fatal("Expected the forwarder to connect")
+	}
+	s.setDown(true)
+	for _, r := range []string{"b", "c"} {
+		if err := f.Forward([]byte(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if waitConnection(t, f) {
+		t.Fatal("Expected the forwarder to fail to connect while the collector is down")
+	}
+	if sent := s.records(); len(sent) != 1 {
+		t.Fatalf("Expected 1 record to be sent while the collector is down, got %d", len(sent))
+	}
+	s.setDown(false)
+	if err := f.Forward([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	if !waitConnection(t, f) {
+		t.Fatal("Expected the forwarder to reconnect")
+	}
+
+	expected := []string{"a", "b", "c", "d"}
+	if sent := s.records(); !reflect.DeepEqual(sent, expected) {
+		t.Fatalf("Expected %v, got %v", expected, sent)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "spool")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the spool to be removed once flushed, got %v", err)
+	}
+}
+
+func TestForwarderDropsWhenSpoolIsFull(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-forwarder-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	s := &fakeSender{down: true}
+	f := NewForwarder(s, filepath.Join(tmp, "spool"), 4)
+	for _, r := range []string{"a", "b", "c"} {
+		if err := f.Forward([]byte(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.Dropped() != 1 {
+		t.Fatalf("Expected 1 dropped record, got %d", f.Dropped())
+	}
+}
+
+func TestForwarderConnectsInBackground(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-forwarder-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	s := &fakeSender{dialing: make(chan struct{})}
+	f := NewForwarder(s, filepath.Join(tmp, "spool"), DefaultSpoolMaxSize)
+
+	// A collector slow to answer must not block the container
+	done := make(chan error)
+	go func() {
+		done <- f.Forward([]byte("a"))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Forward blocked on the connection to the collector")
+	}
+
+	close(s.dialing)
+	if !waitConnection(t, f) {
+		t.Fatal("Expected the forwarder to connect")
+	}
+	if sent := s.records(); !reflect.DeepEqual(sent, []string{"a"}) {
+		t.Fatalf("Expected the spooled record to be sent once connected, got %v", sent)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	if size, err := SpoolMaxSize(map[string]string{}); err != nil || size != DefaultSpoolMaxSize {
+		t.Fatalf("Expected the default spool size, got %d (%v)", size, err)
+	}
+	// Sizes are parsed like the max-size of json-file
+	if size, err := SpoolMaxSize(map[string]string{"spool-max-size": "10m"}); err != nil || size != 10*1024*1024 {
+		t.Fatalf("Expected 10m to be %d bytes, got %d (%v)", 10*1024*1024, size, err)
+	}
+	for _, s := range []string{"foo", "0"} {
+		if _, err := SpoolMaxSize(map[string]string{"spool-max-size": s}); err == nil {
+			t.Fatalf("Expected an error for spool-max-size %s", s)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package gelf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/pkg/log"
+)
+
+const (
+	Name = "gelf"
+
+	dialTimeout = 2 * time.Second
+	// Payloads bigger than chunkSize are split in chunks of that size, as
+	// described in the GELF specification.
+	chunkSize = 8154
+	maxChunks = 128
+	// maxPayloadSize is the size of the biggest payload the GELF inputs
+	// accept, bigger messages are truncated.
+	maxPayloadSize = chunkSize * maxChunks
+	// Syslog severity "informational" for stdout, "error" for stderr.
+	levelInfo  = 6
+	levelError = 3
+)
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+func init() {
+	if err := logger.RegisterLogDriver(Name, New); err != nil {
+		panic(err)
+	}
+	if err := logger.RegisterLogOptValidator(Name, ValidateLogOpt); err != nil {
+		panic(err)
+	}
+}
+
+// GELF sends every message as a GELF 1.1 payload to a Graylog UDP input.
+type GELF struct {
+	hostname    string
+	containerID string
+	name        string
+	forwarder   *logger.Forwarder
+	truncated   int64
+}
+
+type message struct {
+	Version       string  `json:"version"`
+	Host          string  `json:"host"`
+	ShortMessage  string  `json:"short_message"`
+	Timestamp     float64 `json:"timestamp"`
+	Level         int     `json:"level"`
+	ContainerID   string  `json:"_container_id"`
+	ContainerName string  `json:"_container_name"`
+	Stream        string  `json:"_stream"`
+}
+
+type sender struct {
+	address string
+	conn    net.Conn
+}
+
+// New creates a GELF logger sending to the "gelf-address" option, in the
+// udp://host:port format.
+func New(ctx logger.Context) (logger.Logger, error) {
+	address, err := parseAddress(ctx.Config["gelf-address"])
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	spoolMaxSize, err := logger.SpoolMaxSize(ctx.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &GELF{
+		hostname:    hostname,
+		containerID: ctx.ContainerID,
+		name:        ctx.ContainerName,
+		forwarder:   logger.NewForwarder(&sender{address: address}, ctx.SpoolPath, spoolMaxSize),
+	}, nil
+}
+
+// ValidateLogOpt checks the options of the gelf log driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		switch key {
+		case "gelf-address", "spool-max-size":
+		default:
+			return fmt.Errorf("unknown log opt '%s' for %s log driver", key, Name)
+		}
+	}
+	if _, err := parseAddress(cfg["gelf-address"]); err != nil {
+		return err
+	}
+	_, err := logger.SpoolMaxSize(cfg)
+	return err
+}
+
+func parseAddress(address string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("gelf-address is required by the %s log driver", Name)
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "udp" {
+		return "", fmt.Errorf("gelf: endpoint needs to be UDP: %s", address)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return "", fmt.Errorf("gelf: please provide gelf-address as udp://host:port")
+	}
+	return u.Host, nil
+}
+
+func (g *GELF) Log(msg *logger.Message) error {
+	level := levelInfo
+	if msg.Source == "stderr" {
+		level = levelError
+	}
+	m := message{
+		Version:       "1.1",
+		Host:          g.hostname,
+		ShortMessage:  string(bytes.TrimRight(msg.Line, "\n")),
+		Timestamp:     float64(msg.Timestamp.UnixNano()) / float64(time.Second),
+		Level:         level,
+		ContainerID:   g.containerID,
+		ContainerName: g.name,
+		Stream:        msg.Source,
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if len(b) > maxPayloadSize {
+		if b, err = truncate(m, maxPayloadSize); err != nil {
+			return err
+		}
+		atomic.AddInt64(&g.truncated, 1)
+	}
+	return g.forwarder.Forward(b)
+}
+
+// Truncated returns the number of messages truncated to fit in maxChunks
+// chunks.
+func (g *GELF) Truncated() int64 {
+	return atomic.LoadInt64(&g.truncated)
+}
+
+// truncate shortens the message of m to its longest prefix whose payload
+// fits in size bytes, the JSON escaping making the payload longer than the
+// message by a variable amount.
+func truncate(m message, size int) ([]byte, error) {
+	line := m.ShortMessage
+	m.ShortMessage = ""
+	fit, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	for low, high := 1, len(line); low <= high; {
+		middle := (low + high) / 2
+		m.ShortMessage = line[:middle]
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) <= size {
+			fit = b
+			low = middle + 1
+		} else {
+			high = middle - 1
+		}
+	}
+	return fit, nil
+}
+
+func (g *GELF) Name() string {
+	return Name
+}
+
+func (g *GELF) Close() error {
+	err := g.forwarder.Close()
+	if truncated := g.Truncated(); truncated > 0 {
+		log.Infof("%s: %d log messages truncated to fit in a GELF payload", g.containerID, truncated)
+	}
+	if dropped := g.forwarder.Dropped(); dropped > 0 {
+		log.Infof("%s: %d log records dropped, the GELF spool was full", g.containerID, dropped)
+	}
+	return err
+}
+
+func (s *sender) Connect() error {
+	conn, err := net.DialTimeout("udp", s.address, dialTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *sender) Send(payload []byte) error {
+	if s.conn == nil {
+		return fmt.Errorf("Not connected to %s", s.address)
+	}
+	chunks, err := chunk(payload)
+	if err != nil {
+		// Retrying would fail forever, the record is dropped
+		log.Errorf("Dropping GELF record: %s", err)
+		return nil
+	}
+	for _, c := range chunks {
+		if _, err := s.conn.Write(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sender) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// chunk splits payload into GELF chunked datagrams when it does not fit in
+// a single one.
+func chunk(payload []byte) ([][]byte, error) {
+	if len(payload) <= chunkSize {
+		return [][]byte{payload}, nil
+	}
+	count := (len(payload) + chunkSize - 1) / chunkSize
+	if count > maxChunks {
+		return nil, fmt.Errorf("gelf: message too big (%d bytes)", len(payload))
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		c := make([]byte, 0, 12+end-i*chunkSize)
+		c = append(c, chunkMagic...)
+		c = append(c, id...)
+		c = append(c, byte(i), byte(count))
+		c = append(c, payload[i*chunkSize:end]...)
+		chunks = append(chunks, c)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,109 @@
+package gelf
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+func TestChunk(t *testing.T) {
+	small := []byte("hello")
+	chunks, err := chunk(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || !bytes.Equal(chunks[0], small) {
+		t.Fatalf("Small payloads should not be chunked, got %v", chunks)
+	}
+
+	big := bytes.Repeat([]byte("x"), chunkSize*2+1)
+	chunks, err = chunk(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+	var payload []byte
+	for i, c := range chunks {
+		if !bytes.Equal(c[:2], chunkMagic) {
+			t.Fatalf("Chunk %d is missing the GELF magic bytes", i)
+		}
+		if !bytes.Equal(c[2:10], chunks[0][2:10]) {
+			t.Fatalf("Chunk %d has a different message id", i)
+		}
+		if int(c[10]) != i || int(c[11]) != 3 {
+			t.Fatalf("Chunk %d has a wrong sequence header: %d/%d", i, c[10], c[11])
+		}
+		payload = append(payload, c[12:]...)
+	}
+	if !bytes.Equal(payload, big) {
+		t.Fatal("Reassembled chunks differ from the payload")
+	}
+
+	if _, err := chunk(bytes.Repeat([]byte("x"), chunkSize*maxChunks+1)); err == nil {
+		t.Fatal("Payloads needing more than 128 chunks should be rejected")
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	if addr, err := parseAddress("udp://127.0.0.1:12201"); err != nil || addr != "127.0.0.1:12201" {
+		t.Fatalf("Expected 127.0.0.1:12201, got %s %v", addr, err)
+	}
+	for _, invalid := range []string{"", "tcp://127.0.0.1:12201", "udp://127.0.0.1"} {
+		if _, err := parseAddress(invalid); err == nil {
+			t.Fatalf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLogTruncatesBigMessages(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-gelf-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	g, err := New(logger.Context{
+		Config:      map[string]string{"gelf-address": "udp://127.0.0.1:12201"},
+		ContainerID: "abc",
+		SpoolPath:   filepath.Join(tmp, "spool"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	line := strings.Repeat("é\"", maxPayloadSize)
+	if err := g.Log(&logger.Message{Line: []byte(line), Source: "stdout", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if truncated := g.(*GELF).Truncated(); truncated != 1 {
+		t.Fatalf("Expected 1 truncated message, got %d", truncated)
+	}
+
+	b, err := truncate(message{ShortMessage: line}, maxPayloadSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > maxPayloadSize {
+		t.Fatalf("Expected a payload of at most %d bytes, got %d", maxPayloadSize, len(b))
+	}
+	var m message
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.ShortMessage == "" || !strings.HasPrefix(line, strings.TrimSuffix(m.ShortMessage, "\ufffd")) {
+		t.Fatalf("Expected the message to be a prefix of the line, got %d bytes", len(m.ShortMessage))
+	}
+	if _, err := chunk(b); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,184 @@
+package jsonfilelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/pkg/jsonlog"
+	"github.com/docker/docker/pkg/units"
+)
+
+const Name = "json-file"
+
+func init() {
+	if err := logger.RegisterLogDriver(Name, New); err != nil {
+		panic(err)
+	}
+	if err := logger.RegisterLogOptValidator(Name, ValidateLogOpt); err != nil {
+		panic(err)
+	}
+}
+
+// JSONFileLogger writes every message as a serialized jsonlog.JSONLog line
+// to the container's log file. This is the format read back by `docker logs`.
+// When max-size is set, the file is rotated to <path>.1 ... <path>.N, with
+// N the max-file option, before it would grow beyond max-size.
+type JSONFileLogger struct {
+	mu       sync.Mutex
+	f        *os.File
+	path     string
+	size     int64
+	maxSize  int64 // -1 for unlimited
+	maxFiles int
+}
+
+func New(ctx logger.Context) (logger.Logger, error) {
+	maxSize, maxFiles, err := parseRotateOptions(ctx.Config)
+	if err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(ctx.LogPath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := log.Stat()
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	return &JSONFileLogger{
+		f:        log,
+		path:     ctx.LogPath,
+		size:     fi.Size(),
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}, nil
+}
+
+// ValidateLogOpt checks the options of the json-file log driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		switch key {
+		case "max-size", "max-file":
+		default:
+			return fmt.Errorf("unknown log opt '%s' for %s log driver", key, Name)
+		}
+	}
+	_, _, err := parseRotateOptions(cfg)
+	return err
+}
+
+func parseRotateOptions(config map[string]string) (int64, int, error) {
+	var (
+		maxSize  int64 = -1
+		maxFiles       = 1
+		err      error
+	)
+	if s, ok := config["max-size"]; ok {
+		if maxSize, err = units.RAMInBytes(s); err != nil {
+			return 0, 0, err
+		}
+		if maxSize <= 0 {
+			return 0, 0, fmt.Errorf("max-size must be a positive size: %s", s)
+		}
+	}
+	if s, ok := config["max-file"]; ok {
+		if maxFiles, err = strconv.Atoi(s); err != nil {
+			return 0, 0, err
+		}
+		if maxFiles < 1 {
+			return 0, 0, fmt.Errorf("max-file cannot be less than 1: %s", s)
+		}
+		if maxSize == -1 {
+			return 0, 0, fmt.Errorf("max-file cannot be set without max-size")
+		}
+	}
+	return maxSize, maxFiles, nil
+}
+
+func (l *JSONFileLogger) Log(msg *logger.Message) error {
+	b, err := json.Marshal(jsonlog.JSONLog{Log: string(msg.Line), Stream: msg.Source, Created: msg.Timestamp})
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize != -1 && l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		// The file may have been truncated behind our back, by the
+		// --log-disk-max pruning, rotating it would be premature
+		fi, err := l.f.Stat()
+		if err != nil {
+			return err
+		}
+		l.size = fi.Size()
+		if l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+			if err := l.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+// rotate shifts the existing log files by one and starts a new, empty log
+// file. Every step is a rename, so readers holding the old file open keep
+// reading a complete file.
+func (l *JSONFileLogger) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	if l.maxFiles > 1 {
+		for i := l.maxFiles - 1; i > 1; i-- {
+			if err := os.Rename(rotatedPath(l.path, i-1), rotatedPath(l.path, i)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(l.path, rotatedPath(l.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	l.f = f
+	l.size = 0
+	return nil
+}
+
+func (l *JSONFileLogger) Name() string {
+	return Name
+}
+
+func (l *JSONFileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+func rotatedPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// LogFiles returns the existing log files for path, from the oldest rotated
+// one to path itself.
+func LogFiles(path string) []string {
+	var rotated []string
+	for i := 1; ; i++ {
+		p := rotatedPath(path, i)
+		if _, err := os.Stat(p); err != nil {
+			break
+		}
+		rotated = append([]string{p}, rotated...)
+	}
+	return append(rotated, path)
+}
@@ -0,0 +1,173 @@
+package jsonfilelog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/pkg/jsonlog"
+)
+
+func TestJSONFileLogger(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{ContainerID: "cid", LogPath: filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Unix(0, 0).UTC()
+	if err := l.Log(&logger.Message{ContainerID: "cid", Line: []byte("line1\n"), Source: "stdout", Timestamp: created}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log(&logger.Message{ContainerID: "cid", Line: []byte("line2\n"), Source: "stderr", Timestamp: created}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for _, expected := range []jsonlog.JSONLog{
+		{Log: "line1\n", Stream: "stdout", Created: created},
+		{Log: "line2\n", Stream: "stderr", Created: created},
+	} {
+		var jl jsonlog.JSONLog
+		if err := dec.Decode(&jl); err != nil {
+			t.Fatal(err)
+		}
+		if jl != expected {
+			t.Fatalf("Expected %v, got %v", expected, jl)
+		}
+	}
+}
+
+func TestJSONFileLoggerRotation(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{
+		ContainerID: "cid",
+		LogPath:     filename,
+		Config:      map[string]string{"max-size": "1k", "max-file": "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(strings.Repeat("x", 100) + "\n")
+	for i := 0; i < 50; i++ {
+		if err := l.Log(&logger.Message{ContainerID: "cid", Line: line, Source: "stdout", Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := LogFiles(filename)
+	expected := []string{filename + ".2", filename + ".1", filename}
+	if len(files) != len(expected) {
+		t.Fatalf("Expected log files %v, got %v", expected, files)
+	}
+	for i, f := range files {
+		if f != expected[i] {
+			t.Fatalf("Expected log files %v, got %v", expected, files)
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > 1024 {
+			t.Fatalf("%s is bigger than max-size: %d", f, fi.Size())
+		}
+	}
+	if _, err := os.Stat(filename + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("Expected no more than 3 log files, got %v", err)
+	}
+}
+
+func TestJSONFileLoggerTruncated(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{
+		ContainerID: "cid",
+		LogPath:     filename,
+		Config:      map[string]string{"max-size": "1k", "max-file": "2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	line := []byte(strings.Repeat("x", 100) + "\n")
+	for i := 0; i < 5; i++ {
+		if err := l.Log(&logger.Message{ContainerID: "cid", Line: line, Source: "stdout", Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Truncated as --log-disk-max does, the file has room for more lines
+	if err := os.Truncate(filename, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := l.Log(&logger.Message{ContainerID: "cid", Line: line, Source: "stdout", Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filename + ".1"); !os.IsNotExist(err) {
+		t.Fatalf("Expected the truncated file not to be rotated, got %v", err)
+	}
+}
+
+func TestParseRotateOptions(t *testing.T) {
+	for _, invalid := range []map[string]string{
+		{"max-size": "foo"},
+		{"max-size": "0"},
+		{"max-size": "1m", "max-file": "0"},
+		{"max-file": "2"},
+	} {
+		if _, _, err := parseRotateOptions(invalid); err == nil {
+			t.Fatalf("Expected an error for %v", invalid)
+		}
+	}
+	if size, files, err := parseRotateOptions(map[string]string{"max-size": "10m", "max-file": "2"}); err != nil || size != 10*1024*1024 || files != 2 {
+		t.Fatalf("Expected 10m and 2 files, got %d %d %v", size, files, err)
+	}
+}
+
+func TestValidateLogOpts(t *testing.T) {
+	if err := logger.ValidateLogOpts(Name, map[string]string{"max-size": "1m", "max-file": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.ValidateLogOpts(Name, map[string]string{"gelf-address": "udp://localhost:12201"}); err == nil {
+		t.Fatal("Expected an error for an option of another driver")
+	}
+	if err := logger.ValidateLogOpts(Name, map[string]string{"max-file": "2"}); err == nil {
+		t.Fatal("Expected an error for max-file without max-size")
+	}
+	if err := logger.ValidateLogOpts("unknown", nil); err == nil {
+		t.Fatal("Expected an error for an unknown driver")
+	}
+}
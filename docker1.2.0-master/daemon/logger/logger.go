@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrReadLogsNotSupported = errors.New("configured logging driver does not support reading")
+
+// Message is a single line of container output as handed to a log driver.
+type Message struct {
+	ContainerID string
+	Line        []byte
+	Source      string
+	Timestamp   time.Time
+}
+
+// Logger is the interface implemented by every log driver.
+type Logger interface {
+	Log(*Message) error
+	Name() string
+	Close() error
+}
+
+// Context holds the information about the container a Logger is created for.
+type Context struct {
+	ContainerID   string
+	ContainerName string
+	LogPath       string
+	// SpoolPath is where network drivers keep records while their collector
+	// is unreachable.
+	SpoolPath string
+	// Config holds the driver specific options.
+	Config map[string]string
+}
+
+type Creator func(Context) (Logger, error)
+
+// LogOptValidator checks the options given to a log driver, before any
+// container is started with them.
+type LogOptValidator func(cfg map[string]string) error
+
+var (
+	driversLock sync.Mutex
+	// All registered log drivers
+	drivers = make(map[string]Creator)
+	// Option validators of the log drivers supporting options
+	validators = make(map[string]LogOptValidator)
+)
+
+// RegisterLogDriver makes a log driver available by the provided name.
+func RegisterLogDriver(name string, c Creator) error {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if _, exists := drivers[name]; exists {
+		return fmt.Errorf("Log driver named '%s' is already registered", name)
+	}
+	drivers[name] = c
+	return nil
+}
+
+// GetLogDriver returns the Creator of the log driver registered as name.
+func GetLogDriver(name string) (Creator, error) {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	c, exists := drivers[name]
+	if !exists {
+		return nil, fmt.Errorf("logger: no log driver named '%s' is registered", name)
+	}
+	return c, nil
+}
+
+// RegisterLogOptValidator registers the options validator of the log driver
+// registered as name. Drivers without a validator don't accept any option.
+func RegisterLogOptValidator(name string, v LogOptValidator) error {
+	driversLock.Lock()
+	defer driversLock.Unlock()
+	if _, exists := validators[name]; exists {
+		return fmt.Errorf("Log options validator for '%s' is already registered", name)
+	}
+	validators[name] = v
+	return nil
+}
+
+// ValidateLogOpts checks that the log driver registered as name exists and
+// supports the options in cfg.
+func ValidateLogOpts(name string, cfg map[string]string) error {
+	driversLock.Lock()
+	_, exists := drivers[name]
+	v := validators[name]
+	driversLock.Unlock()
+	if !exists {
+		return fmt.Errorf("logger: no log driver named '%s' is registered", name)
+	}
+	if v == nil {
+		if len(cfg) > 0 {
+			return fmt.Errorf("logger: log driver %s does not support options", name)
+		}
+		return nil
+	}
+	return v(cfg)
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// Writer splits the raw output of a container stream into lines and hands
+// every complete line to a Logger. It is meant to be added to a container's
+// BroadcastWriter; closing it flushes a trailing non-eol-terminated line but
+// leaves the underlying Logger open, as it is shared by stdout and stderr.
+type Writer struct {
+	sync.Mutex
+	logger      Logger
+	containerID string
+	source      string
+	buf         *bytes.Buffer
+}
+
+func NewWriter(l Logger, containerID, source string) *Writer {
+	return &Writer{
+		logger:      l,
+		containerID: containerID,
+		source:      source,
+		buf:         bytes.NewBuffer(nil),
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	created := time.Now().UTC()
+	w.Lock()
+	defer w.Unlock()
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			w.buf.Write(line)
+			break
+		}
+		if err := w.log(line, created); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *Writer) Close() error {
+	w.Lock()
+	defer w.Unlock()
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := w.buf.Bytes()
+	w.buf.Reset()
+	return w.log(line, time.Now().UTC())
+}
+
+func (w *Writer) log(line []byte, created time.Time) error {
+	return w.logger.Log(&Message{
+		ContainerID: w.containerID,
+		Line:        line,
+		Source:      w.source,
+		Timestamp:   created,
+	})
+}
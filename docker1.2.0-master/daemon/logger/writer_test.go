@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"testing"
+)
+
+type recordingLogger struct {
+	msgs []*Message
+}
+
+func (l *recordingLogger) Log(m *Message) error {
+	l.msgs = append(l.msgs, m)
+	return nil
+}
+
+func (l *recordingLogger) Name() string {
+	return "recording"
+}
+
+func (l *recordingLogger) Close() error {
+	return nil
+}
+
+func TestWriterSplitsLines(t *testing.T) {
+	l := &recordingLogger{}
+	w := NewWriter(l, "cid", "stdout")
+
+	if _, err := w.Write([]byte("foo\nba")); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(l.msgs))
+	}
+	if _, err := w.Write([]byte("r\nbaz")); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.msgs) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(l.msgs))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"foo\n", "bar\n", "baz"}
+	if len(l.msgs) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d", len(expected), len(l.msgs))
+	}
+	for i, m := range l.msgs {
+		if string(m.Line) != expected[i] {
+			t.Fatalf("Expected line %q, got %q", expected[i], m.Line)
+		}
+		if m.Source != "stdout" || m.ContainerID != "cid" {
+			t.Fatalf("Unexpected message metadata: %s %s", m.Source, m.ContainerID)
+		}
+	}
+}
+
+func TestGetLogDriver(t *testing.T) {
+	if err := RegisterLogDriver("test-driver", func(Context) (Logger, error) { return &recordingLogger{}, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterLogDriver("test-driver", nil); err == nil {
+		t.Fatal("Registering a log driver twice should return an error")
+	}
+	if _, err := GetLogDriver("test-driver"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetLogDriver("no-such-driver"); err == nil {
+		t.Fatal("Getting an unknown log driver should return an error")
+	}
+}
@@ -9,9 +9,9 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/tailfile"
 
+	"github.com/docker/docker/daemon/logger/jsonfilelog"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/jsonlog"
 )
@@ -28,6 +28,7 @@ func (daemon *Daemon) ContainerLogs(job *engine.Job) engine.Status {
 		tail   = job.Getenv("tail")
 		follow = job.GetenvBool("follow")
 		times  = job.GetenvBool("timestamps")
+		since  = job.GetenvInt64("since")
 		lines  = -1
 		format string
 	)
@@ -44,41 +45,51 @@ func (daemon *Daemon) ContainerLogs(job *engine.Job) engine.Status {
 	if container == nil {
 		return job.Errorf("No such container: %s", name)
 	}
-	cLog, err := container.ReadLog("json")
+	if !container.readLogsSupported() {
+		return job.Errorf("\"logs\" command is supported only for the \"%s\" logging driver", jsonfilelog.Name)
+	}
+	cLog, err := container.ReadJSONLogs()
+	if err == nil {
+		defer cLog.Close()
+	}
 	if err != nil && os.IsNotExist(err) {
 		// Legacy logs
-		log.Debugf("Old logs format")
+		daemonLog.Debugf("Old logs format")
 		if stdout {
 			cLog, err := container.ReadLog("stdout")
 			if err != nil {
-				log.Errorf("Error reading logs (stdout): %s", err)
+				daemonLog.Errorf("Error reading logs (stdout): %s", err)
 			} else if _, err := io.Copy(job.Stdout, cLog); err != nil {
-				log.Errorf("Error streaming logs (stdout): %s", err)
+				daemonLog.Errorf("Error streaming logs (stdout): %s", err)
 			}
 		}
 		if stderr {
 			cLog, err := container.ReadLog("stderr")
 			if err != nil {
-				log.Errorf("Error reading logs (stderr): %s", err)
+				daemonLog.Errorf("Error reading logs (stderr): %s", err)
 			} else if _, err := io.Copy(job.Stderr, cLog); err != nil {
-				log.Errorf("Error streaming logs (stderr): %s", err)
+				daemonLog.Errorf("Error streaming logs (stderr): %s", err)
 			}
 		}
 	} else if err != nil {
-		log.Errorf("Error reading logs (json): %s", err)
+		daemonLog.Errorf("Error reading logs (json): %s", err)
 	} else {
 		if tail != "all" {
 			var err error
 			lines, err = strconv.Atoi(tail)
 			if err != nil {
-				log.Errorf("Failed to parse tail %s, error: %v, show all logs", tail, err)
+				daemonLog.Errorf("Failed to parse tail %s, error: %v, show all logs", tail, err)
 				lines = -1
 			}
 		}
 		if lines != 0 {
+			var src io.Reader = cLog
 			if lines > 0 {
-				f := cLog.(*os.File)
-				ls, err := tailfile.TailFile(f, lines)
+				files, err := container.jsonLogFiles()
+				if err != nil {
+					return job.Error(err)
+				}
+				ls, err := tailLogFiles(files, lines)
 				if err != nil {
 					return job.Error(err)
 				}
@@ -86,18 +97,21 @@ func (daemon *Daemon) ContainerLogs(job *engine.Job) engine.Status {
 				for _, l := range ls {
 					fmt.Fprintf(tmp, "%s\n", l)
 				}
-				cLog = tmp
+				src = tmp
 			}
-			dec := json.NewDecoder(cLog)
+			dec := json.NewDecoder(src)
 			for {
 				l := &jsonlog.JSONLog{}
 
 				if err := dec.Decode(l); err == io.EOF {
 					break
 				} else if err != nil {
-					log.Errorf("Error streaming logs: %s", err)
+					daemonLog.Errorf("Error streaming logs: %s", err)
 					break
 				}
+				if since > 0 && l.Created.Unix() < since {
+					continue
+				}
 				logLine := l.Log
 				if times {
 					logLine = fmt.Sprintf("%s %s", l.Created.Format(format), logLine)
@@ -127,8 +141,30 @@ func (daemon *Daemon) ContainerLogs(job *engine.Job) engine.Status {
 		}
 		err := <-errors
 		if err != nil {
-			log.Errorf("%s", err)
+			daemonLog.Errorf("%s", err)
 		}
 	}
 	return engine.StatusOK
 }
+
+// tailLogFiles returns the last n lines of files, which are ordered from the
+// oldest to the newest.
+func tailLogFiles(files []string, n int) ([][]byte, error) {
+	var lines [][]byte
+	for i := len(files) - 1; i >= 0 && len(lines) < n; i-- {
+		f, err := os.Open(files[i])
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		ls, err := tailfile.TailFile(f, n-len(lines))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(ls, lines...)
+	}
+	return lines, nil
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/units"
+)
+
+// logDiskCheckInterval is how often the log files are checked against the
+// --log-disk-max budget.
+const logDiskCheckInterval = 10 * time.Second
+
+// logFile is a json-file log of a container, either the one being written
+// or a rotated one.
+type logFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+	active  bool
+}
+
+// byAge sorts log files from the least recently modified one. The files of
+// a container are listed oldest first, so the sort must be stable to never
+// prune a rotated file before an older one of the same container.
+type byAge []logFile
+
+func (a byAge) Len() int           { return len(a) }
+func (a byAge) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAge) Less(i, j int) bool { return a[i].modTime.Before(a[j].modTime) }
+
+// containerLogFiles returns the existing json-file logs of container, oldest
+// first.
+func containerLogFiles(container *Container) ([]logFile, error) {
+	paths, err := container.jsonLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	var files []logFile
+	for i, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{
+			path:    p,
+			size:    fi.Size(),
+			modTime: fi.ModTime(),
+			active:  i == len(paths)-1,
+		})
+	}
+	return files, nil
+}
+
+// logDiskUsage returns the bytes used by the logs of every container, by
+// container ID, and their total.
+func (daemon *Daemon) logDiskUsage() (map[string]int64, int64) {
+	var (
+		usage = make(map[string]int64)
+		total int64
+	)
+	for _, container := range daemon.List() {
+		files, err := containerLogFiles(container)
+		if err != nil {
+			daemonLog.Debugf("%s: Error listing log files: %s", container.ID, err)
+			continue
+		}
+		for _, f := range files {
+			usage[container.ID] += f.size
+			total += f.size
+		}
+	}
+	return usage, total
+}
+
+// pruneLogs brings the logs of all containers back under max bytes. The
+// least recently modified files go first: rotated files are removed, the
+// files being written are truncated.
+func (daemon *Daemon) pruneLogs(max int64) {
+	var (
+		files []logFile
+		total int64
+	)
+	for _, container := range daemon.List() {
+		cfiles, err := containerLogFiles(container)
+		if err != nil {
+			daemonLog.Debugf("%s: Error listing log files: %s", container.ID, err)
+			continue
+		}
+		for _, f := range cfiles {
+			total += f.size
+		}
+		files = append(files, cfiles...)
+	}
+	if total <= max {
+		return
+	}
+	sort.Stable(byAge(files))
+	for _, f := range files {
+		if total <= max {
+			break
+		}
+		var err error
+		if f.active {
+			err = os.Truncate(f.path, 0)
+		} else {
+			err = os.Remove(f.path)
+		}
+		if err != nil {
+			daemonLog.Errorf("Error pruning log file %s: %s", f.path, err)
+			continue
+		}
+		daemonLog.Infof("Pruned log file %s (%s) to stay under --log-disk-max", f.path, units.HumanSize(f.size))
+		total -= f.size
+	}
+}
+
+// enforceLogDiskMax periodically prunes the container logs exceeding the
+// --log-disk-max budget, until stop is closed.
+func (daemon *Daemon) enforceLogDiskMax(stop chan struct{}) {
+	ticker := time.NewTicker(logDiskCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			daemon.pruneLogs(daemon.logDiskMax)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ContainerLogUsage reports the disk space used by the logs of each container,
+// with their total and the --log-disk-max budget, for GET
+// /containers/logs/usage.
+func (daemon *Daemon) ContainerLogUsage(job *engine.Job) engine.Status {
+	if len(job.Args) != 0 {
+		return job.Errorf("Usage: %s", job.Name)
+	}
+	type containerUsage struct {
+		Id       string
+		Name     string
+		LogsSize int64
+	}
+	usage, total := daemon.logDiskUsage()
+	containers := []containerUsage{}
+	for _, container := range daemon.List() {
+		containers = append(containers, containerUsage{
+			Id:       container.ID,
+			Name:     container.Name,
+			LogsSize: usage[container.ID],
+		})
+	}
+	v := &engine.Env{}
+	v.SetInt64("LogsSize", total)
+	v.SetInt64("LogsSizeMax", daemon.logDiskMax)
+	v.SetJson("Containers", containers)
+	if _, err := v.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
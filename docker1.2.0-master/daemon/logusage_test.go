@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneLogs(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-logusage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	daemon := &Daemon{containers: &contStore{s: make(map[string]*Container)}}
+	old := time.Now().Add(-time.Hour)
+	for i, id := range []string{"aaa", "bbb"} {
+		container := &Container{ID: id, root: filepath.Join(root, id), Created: time.Now()}
+		if err := os.MkdirAll(container.root, 0700); err != nil {
+			t.Fatal(err)
+		}
+		daemon.containers.Add(id, container)
+		base := filepath.Join(container.root, id+"-json.log")
+		for _, p := range []string{base + ".1", base} {
+			if err := ioutil.WriteFile(p, bytes.Repeat([]byte("a"), 100), 0600); err != nil {
+				t.Fatal(err)
+			}
+			// The logs of aaa are older than the ones of bbb
+			mtime := old.Add(time.Duration(i) * time.Minute)
+			if p == base {
+				mtime = mtime.Add(time.Second)
+			}
+			if err := os.Chtimes(p, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	usage, total := daemon.logDiskUsage()
+	if total != 400 || usage["aaa"] != 200 || usage["bbb"] != 200 {
+		t.Fatalf("Unexpected usage %v, total %d", usage, total)
+	}
+
+	daemon.pruneLogs(250)
+	if _, err := os.Stat(filepath.Join(root, "aaa", "aaa-json.log.1")); !os.IsNotExist(err) {
+		t.Fatal("Expected the oldest rotated log to be removed")
+	}
+	fi, err := os.Stat(filepath.Join(root, "aaa", "aaa-json.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 0 {
+		t.Fatalf("Expected the oldest active log to be truncated, got %d bytes", fi.Size())
+	}
+	if _, total := daemon.logDiskUsage(); total != 200 {
+		t.Fatalf("Expected 200 bytes of logs left, got %d", total)
+	}
+}
@@ -0,0 +1,172 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/graph"
+	"github.com/docker/docker/utils"
+)
+
+// graphMigration records the changes of a migration to roll them back: the
+// images and the container layers copied, and the tags and containers moved.
+type graphMigration struct {
+	images     []string
+	layers     []string
+	tags       [][2]string
+	containers []*Container
+}
+
+// GraphMigrate copies the images, containers and tags created with the graph
+// driver given as argument to the current driver of the daemon. The data of
+// the former driver is left untouched, and all the changes are rolled back if
+// the migration fails.
+func (daemon *Daemon) GraphMigrate(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s DRIVER", job.Name)
+	}
+	name := job.Args[0]
+	if name == daemon.driver.String() {
+		return job.Errorf("The daemon already uses the %s driver", name)
+	}
+	src, err := graphdriver.GetDriver(name, daemon.config.Root, job.GetenvList("options"))
+	if err != nil {
+		return job.Errorf("Error loading the %s driver: %s", name, err)
+	}
+	defer src.Cleanup()
+
+	sf := utils.NewStreamFormatter(job.GetenvBool("json"))
+	m := &graphMigration{}
+	if err := daemon.migrateGraph(src, m, sf, job.Stdout); err != nil {
+		if rbErr := daemon.rollbackMigration(src, m); rbErr != nil {
+			daemonLog.Errorf("Error rolling back the migration from %s: %s", name, rbErr)
+		} else {
+			job.Stdout.Write(sf.FormatStatus("", "Migration from %s rolled back", name))
+		}
+		return job.Errorf("Error migrating from %s: %s", name, err)
+	}
+
+	// The migrated containers can be used without restarting the daemon
+	for _, container := range m.containers {
+		if err := daemon.register(container, true); err != nil {
+			daemonLog.Errorf("Failed to register container %s: %s", container.ID, err)
+		}
+	}
+	job.Stdout.Write(sf.FormatStatus("", "Migrated %d image(s), %d container(s) and %d tag(s) from %s", len(m.images), len(m.containers), len(m.tags), name))
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) migrateGraph(src graphdriver.Driver, m *graphMigration, sf *utils.StreamFormatter, out io.Writer) error {
+	var err error
+	m.images, err = daemon.graph.MigrateLayers(src, sf, out)
+	if err != nil {
+		return err
+	}
+
+	containers, err := daemon.containersOf(src)
+	if err != nil {
+		return err
+	}
+	for _, container := range containers {
+		if !daemon.driver.Exists(container.Image) {
+			return fmt.Errorf("Image %s of container %s is missing from %s", container.Image, container.ID, daemon.driver)
+		}
+		initID := fmt.Sprintf("%s-init", container.ID)
+		for _, layer := range [][2]string{{initID, container.Image}, {container.ID, initID}} {
+			if err := graph.MigrateLayer(src, daemon.driver, layer[0], layer[1], sf, out); err != nil {
+				return err
+			}
+			m.layers = append(m.layers, layer[0])
+		}
+		out.Write(sf.FormatStatus(utils.TruncateID(container.ID), "Container migrated"))
+	}
+
+	if err := daemon.migrateTags(src, m); err != nil {
+		return err
+	}
+
+	// Only switch the containers to the new driver once all the data is copied
+	for _, container := range containers {
+		container.Driver = daemon.driver.String()
+		m.containers = append(m.containers, container)
+		if err := container.ToDisk(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containersOf loads the containers created with the driver src.
+func (daemon *Daemon) containersOf(src graphdriver.Driver) ([]*Container, error) {
+	dir, err := ioutil.ReadDir(daemon.repository)
+	if err != nil {
+		return nil, err
+	}
+	var containers []*Container
+	for _, v := range dir {
+		container, err := daemon.load(v.Name())
+		if err != nil {
+			daemonLog.Errorf("Failed to load container %v: %v", v.Name(), err)
+			continue
+		}
+		if container.Driver == src.String() || (container.Driver == "" && src.String() == "aufs") {
+			containers = append(containers, container)
+		}
+	}
+	return containers, nil
+}
+
+// migrateTags adds the tags of the driver src to the repositories of the
+// daemon, the tags already set being kept.
+func (daemon *Daemon) migrateTags(src graphdriver.Driver, m *graphMigration) error {
+	p := path.Join(daemon.config.Root, "repositories-"+src.String())
+	if _, err := os.Stat(p); os.IsNotExist(err) {
+		return nil
+	}
+	store, err := graph.NewTagStore(p, daemon.graph, nil)
+	if err != nil {
+		return err
+	}
+	for repoName, repository := range store.Repositories {
+		existing, err := daemon.repositories.Get(repoName)
+		if err != nil {
+			return err
+		}
+		for tag, id := range repository {
+			if _, exists := existing[tag]; exists || !daemon.driver.Exists(id) {
+				continue
+			}
+			if err := daemon.repositories.Set(repoName, tag, id, false); err != nil {
+				return err
+			}
+			m.tags = append(m.tags, [2]string{repoName, tag})
+		}
+	}
+	return nil
+}
+
+// rollbackMigration undoes the changes recorded in m, in reverse order.
+func (daemon *Daemon) rollbackMigration(src graphdriver.Driver, m *graphMigration) error {
+	for _, container := range m.containers {
+		container.Driver = src.String()
+		if err := container.ToDisk(); err != nil {
+			return err
+		}
+	}
+	for _, t := range m.tags {
+		if _, err := daemon.repositories.Delete(t[0], t[1]); err != nil {
+			return err
+		}
+	}
+	for i := len(m.layers) - 1; i >= 0; i-- {
+		if err := daemon.driver.Remove(m.layers[i]); err != nil {
+			return err
+		}
+	}
+	return daemon.graph.RemoveLayers(m.images)
+}
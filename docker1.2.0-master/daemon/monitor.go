@@ -3,11 +3,11 @@ package daemon
 import (
 	"io"
 	"os/exec"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/daemon/execdriver"
-	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -88,7 +88,7 @@ func (m *containerMonitor) Close() error {
 	// because they share same runconfig and change image. Must be fixed
 	// in builder/builder.go
 	if err := m.container.toDisk(); err != nil {
-		log.Errorf("Error dumping container %s state to disk: %s", m.container.ID, err)
+		daemonLog.Errorf("Error dumping container %s state to disk: %s", m.container.ID, err)
 
 		return err
 	}
@@ -121,7 +121,7 @@ func (m *containerMonitor) Start() error {
 	for {
 		m.container.RestartCount++
 
-		if err := m.container.startLoggingToDisk(); err != nil {
+		if err := m.container.startLogging(); err != nil {
 			m.resetContainer()
 
 			return err
@@ -133,7 +133,15 @@ func (m *containerMonitor) Start() error {
 
 		m.lastStartTime = time.Now()
 
-		if exitStatus, err = m.container.daemon.Run(m.container, pipes, m.callback); err != nil {
+		m.container.command.OOMCallback = m.oom
+		exitStatus, err = m.container.daemon.Run(m.container, pipes, m.callback)
+		m.container.stopHealthcheck()
+		var usage *execdriver.ResourceUsage
+		if err == nil {
+			usage = m.container.command.Usage()
+		}
+		m.container.State.SetUsage(usage)
+		if err != nil {
 			// if we receive an internal error from the initial start of a container then lets
 			// return it instead of entering the restart loop
 			if m.container.RestartCount == 0 {
@@ -142,7 +150,7 @@ func (m *containerMonitor) Start() error {
 				return err
 			}
 
-			log.Errorf("Error running container: %s", err)
+			daemonLog.Errorf("Error running container: %s", err)
 		}
 
 		// here container.Lock is already lost
@@ -153,7 +161,7 @@ func (m *containerMonitor) Start() error {
 		if m.shouldRestart(exitStatus) {
 			m.container.State.SetRestarting(exitStatus)
 
-			m.container.LogEvent("die")
+			m.logDie(exitStatus, usage)
 
 			m.resetContainer()
 
@@ -174,7 +182,7 @@ func (m *containerMonitor) Start() error {
 
 		m.container.State.SetStopped(exitStatus)
 
-		m.container.LogEvent("die")
+		m.logDie(exitStatus, usage)
 
 		m.resetContainer()
 
@@ -184,6 +192,18 @@ func (m *containerMonitor) Start() error {
 	return err
 }
 
+// logDie logs the die event of the container, with its exit code and its
+// resource usage, for the containers running briefly to be accounted.
+func (m *containerMonitor) logDie(exitStatus int, usage *execdriver.ResourceUsage) {
+	attributes := map[string]string{"exitCode": strconv.Itoa(exitStatus)}
+	if usage != nil {
+		attributes["userTime"] = usage.UserTime.String()
+		attributes["systemTime"] = usage.SystemTime.String()
+		attributes["maxRss"] = strconv.FormatInt(usage.MaxRss, 10)
+	}
+	m.container.LogEventWithAttributes("die", attributes)
+}
+
 // resetMonitor resets the stateful fields on the containerMonitor based on the
 // previous runs success or failure.  Reguardless of success, if the container had
 // an execution time of more than 10s then reset the timer back to the default
@@ -232,7 +252,7 @@ func (m *containerMonitor) shouldRestart(exitStatus int) bool {
 	case "on-failure":
 		// the default value of 0 for MaximumRetryCount means that we will not enforce a maximum count
 		if max := m.restartPolicy.MaximumRetryCount; max != 0 && m.failureCount >= max {
-			log.Debugf("stopping restart of container %s because maximum failure could of %d has been reached", max)
+			daemonLog.Debugf("stopping restart of container %s because maximum failure could of %d has been reached", max)
 			return false
 		}
 
@@ -255,6 +275,10 @@ func (m *containerMonitor) callback(command *execdriver.Command) {
 	}
 
 	m.container.State.SetRunning(command.Pid())
+	if command.Tty {
+		m.container.restoreTtySize()
+	}
+	m.container.startHealthcheck()
 
 	// signal that the process has started
 	// close channel only if not closed
@@ -265,10 +289,17 @@ func (m *containerMonitor) callback(command *execdriver.Command) {
 	}
 
 	if err := m.container.ToDisk(); err != nil {
-		log.Debugf("%s", err)
+		daemonLog.Debugf("%s", err)
 	}
 }
 
+// oom records the container ran out of memory, as notified by the
+// execution driver
+func (m *containerMonitor) oom() {
+	m.container.State.SetOOMKilled()
+	m.container.LogEvent("oom")
+}
+
 // resetContainer resets the container's IO and ensures that the command is able to be executed again
 // by copying the data into a new struct
 func (m *containerMonitor) resetContainer() {
@@ -276,21 +307,29 @@ func (m *containerMonitor) resetContainer() {
 
 	if container.Config.OpenStdin {
 		if err := container.stdin.Close(); err != nil {
-			log.Errorf("%s: Error close stdin: %s", container.ID, err)
+			daemonLog.Errorf("%s: Error close stdin: %s", container.ID, err)
 		}
 	}
 
 	if err := container.stdout.Clean(); err != nil {
-		log.Errorf("%s: Error close stdout: %s", container.ID, err)
+		daemonLog.Errorf("%s: Error close stdout: %s", container.ID, err)
 	}
 
 	if err := container.stderr.Clean(); err != nil {
-		log.Errorf("%s: Error close stderr: %s", container.ID, err)
+		daemonLog.Errorf("%s: Error close stderr: %s", container.ID, err)
+	}
+
+	if dropped := container.stdout.Dropped() + container.stderr.Dropped(); dropped > 0 {
+		daemonLog.Infof("%s: %d bytes of output dropped so far for slow attached clients", container.ID, dropped)
+	}
+
+	if err := container.stopLogging(); err != nil {
+		daemonLog.Errorf("%s: Error closing log driver: %s", container.ID, err)
 	}
 
 	if container.command != nil && container.command.Terminal != nil {
 		if err := container.command.Terminal.Close(); err != nil {
-			log.Errorf("%s: Error closing terminal: %s", container.ID, err)
+			daemonLog.Errorf("%s: Error closing terminal: %s", container.ID, err)
 		}
 	}
 
@@ -0,0 +1,212 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/units"
+)
+
+// volumeUsers returns the IDs of the containers mounting the volume stored
+// at hostPath. This is the reference count of a named volume.
+func (daemon *Daemon) volumeUsers(hostPath string) []string {
+	var users []string
+	for _, container := range daemon.List() {
+		for _, p := range container.volumeHostPaths() {
+			if p == hostPath {
+				users = append(users, container.ID)
+				break
+			}
+		}
+	}
+	return users
+}
+
+// volumeHostPaths returns the host paths of the volumes of container. The
+// volumes are set up when the container starts, under its lock.
+func (container *Container) volumeHostPaths() []string {
+	container.Lock()
+	defer container.Unlock()
+	paths := make([]string, 0, len(container.Volumes))
+	for _, p := range container.Volumes {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// namedVolumeHostPath returns the host path of the named volume name,
+// creating the volume if it doesn't exist yet. A driver volume is mounted
+// for container.
+func (daemon *Daemon) namedVolumeHostPath(container *Container, name string) (string, error) {
+	v := daemon.volumeStore.Get(name)
+	if v == nil {
+		var err error
+		if v, err = daemon.volumeStore.Create(name, 0, "", nil); err != nil {
+			return "", err
+		}
+	}
+	if err := daemon.mountDriverVolume(container, v); err != nil {
+		return "", err
+	}
+	return daemon.volumeStore.Path(v)
+}
+
+func (daemon *Daemon) mountDriverVolume(container *Container, v *NamedVolume) error {
+	if v.Driver == "" {
+		return nil
+	}
+	for _, name := range container.driverVolumes {
+		if name == v.Name {
+			return nil
+		}
+	}
+	if err := daemon.volumeStore.Mount(v); err != nil {
+		return err
+	}
+	container.driverVolumes = append(container.driverVolumes, v.Name)
+	return nil
+}
+
+// mountDriverVolumes mounts the driver volumes container already uses, on
+// restart or through --volumes-from.
+func (daemon *Daemon) mountDriverVolumes(container *Container) error {
+	for _, v := range daemon.volumeStore.List() {
+		if v.Driver == "" {
+			continue
+		}
+		p, err := daemon.volumeStore.Path(v)
+		if err != nil {
+			return err
+		}
+		for _, hostPath := range container.Volumes {
+			if hostPath == p {
+				if err := daemon.mountDriverVolume(container, v); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// unmountDriverVolumes releases the driver volumes mounted for container.
+func (daemon *Daemon) unmountDriverVolumes(container *Container) {
+	for _, name := range container.driverVolumes {
+		v := daemon.volumeStore.Get(name)
+		if v == nil {
+			continue
+		}
+		if err := daemon.volumeStore.Unmount(v); err != nil {
+			daemonLog.Errorf("%s: Failed to unmount volume %s: %s", container.ID, name, err)
+		}
+	}
+	container.driverVolumes = nil
+}
+
+func (daemon *Daemon) namedVolumeEnv(v *NamedVolume) (*engine.Env, error) {
+	p, err := daemon.volumeStore.Path(v)
+	if err != nil {
+		return nil, err
+	}
+	users := daemon.volumeUsers(p)
+	out := &engine.Env{}
+	out.Set("Name", v.Name)
+	out.Set("Path", p)
+	out.SetInt64("Created", v.Created.Unix())
+	out.SetInt64("Size", v.Size)
+	out.Set("Driver", v.Driver)
+	if err := out.SetJson("Options", v.Options); err != nil {
+		return nil, err
+	}
+	out.SetInt("RefCount", len(users))
+	out.SetList("Containers", users)
+	return out, nil
+}
+
+func (daemon *Daemon) VolumeCreate(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s NAME", job.Name)
+	}
+	var size int64
+	if s := job.Getenv("size"); s != "" {
+		var err error
+		if size, err = units.RAMInBytes(s); err != nil {
+			return job.Errorf("Invalid volume size %s: %s", s, err)
+		}
+	}
+	var opts map[string]string
+	for _, opt := range job.GetenvList("opt") {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return job.Errorf("Invalid volume option %s, expected key=value", opt)
+		}
+		if opts == nil {
+			opts = make(map[string]string)
+		}
+		opts[parts[0]] = parts[1]
+	}
+	v, err := daemon.volumeStore.Create(job.Args[0], size, job.Getenv("driver"), opts)
+	if err != nil {
+		return job.Error(err)
+	}
+	job.Printf("%s\n", v.Name)
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) VolumeList(job *engine.Job) engine.Status {
+	outs := engine.NewTable("", 0)
+	for _, v := range daemon.volumeStore.List() {
+		out, err := daemon.namedVolumeEnv(v)
+		if err != nil {
+			return job.Error(err)
+		}
+		outs.Add(out)
+	}
+	if _, err := outs.WriteListTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) VolumeInspect(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s NAME", job.Name)
+	}
+	v := daemon.volumeStore.Get(job.Args[0])
+	if v == nil {
+		return job.Errorf("No such volume: %s", job.Args[0])
+	}
+	out, err := daemon.namedVolumeEnv(v)
+	if err != nil {
+		return job.Error(err)
+	}
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) VolumeRm(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s NAME", job.Name)
+	}
+	name := job.Args[0]
+	v := daemon.volumeStore.Get(name)
+	if v == nil {
+		return job.Errorf("No such volume: %s", name)
+	}
+	p, err := daemon.volumeStore.Path(v)
+	if err != nil {
+		return job.Error(err)
+	}
+	if users := daemon.volumeUsers(p); len(users) > 0 {
+		return job.Error(fmt.Errorf("Volume %s is in use by container(s) %s", name, strings.Join(users, ", ")))
+	}
+	if err := daemon.volumeStore.Delete(name); err != nil {
+		return job.Error(err)
+	}
+	job.Printf("%s\n", name)
+	return engine.StatusOK
+}
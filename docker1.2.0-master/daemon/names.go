@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/pkg/namesgenerator"
+)
+
+var validNameAffix = regexp.MustCompile(`^` + validContainerNameChars + `*$`)
+
+// namesGenerator generates the names of the containers created without one,
+// as configured by the --name-* flags. The counter of the sequential names
+// is saved under the root, for a daemon not to reuse the names of the
+// previous ones.
+type namesGenerator struct {
+	*namesgenerator.Generator
+	counterPath string
+	saveLock    sync.Mutex
+}
+
+func newNamesGenerator(config *Config) (*namesGenerator, error) {
+	for flag, affix := range map[string]string{"--name-prefix": config.NamePrefix, "--name-suffix": config.NameSuffix} {
+		if !validNameAffix.MatchString(affix) {
+			return nil, fmt.Errorf("Invalid %s %s, only %s are allowed", flag, affix, validContainerNameChars)
+		}
+	}
+	g := &namesGenerator{
+		Generator: &namesgenerator.Generator{
+			Prefix:     config.NamePrefix,
+			Suffix:     config.NameSuffix,
+			Sequential: config.NameSequential,
+		},
+		counterPath: filepath.Join(config.Root, "names-counter"),
+	}
+	if config.NameWordlist != "" {
+		if config.NameSequential {
+			return nil, fmt.Errorf("--name-wordlist and --name-sequential are exclusive")
+		}
+		words, err := namesgenerator.LoadWords(config.NameWordlist)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --name-wordlist: %s", err)
+		}
+		g.Words = words
+	}
+	if config.NameSequential {
+		// A bare number would be taken for the prefix of an id
+		if config.NamePrefix == "" {
+			return nil, fmt.Errorf("--name-sequential needs a --name-prefix")
+		}
+		data, err := ioutil.ReadFile(g.counterPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			n, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid names counter %s: %s", g.counterPath, err)
+			}
+			g.SetCounter(n)
+		}
+	}
+	return g, nil
+}
+
+// saveCounter saves the counter of the sequential names, once a name was
+// taken.
+func (g *namesGenerator) saveCounter() error {
+	if !g.Sequential {
+		return nil
+	}
+	g.saveLock.Lock()
+	defer g.saveLock.Unlock()
+	return ioutil.WriteFile(g.counterPath, []byte(strconv.FormatUint(g.Counter(), 10)), 0600)
+}
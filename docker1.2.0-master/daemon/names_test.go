@@ -0,0 +1,52 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNamesGeneratorCounter(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-test-names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	config := &Config{Root: root, NamePrefix: "web-", NameSequential: true}
+	g, err := newNamesGenerator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"web-1", "web-2"} {
+		if name := g.Name(0); name != expected {
+			t.Fatalf("Expected %s, got %s", expected, name)
+		}
+		if err := g.saveCounter(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a new daemon goes on from the last name
+	g, err = newNamesGenerator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := g.Name(0); name != "web-3" {
+		t.Fatalf("Expected web-3, got %s", name)
+	}
+}
+
+func TestNamesGeneratorInvalid(t *testing.T) {
+	for _, config := range []*Config{
+		{NamePrefix: "web/"},
+		{NameSuffix: " "},
+		{NameSequential: true},
+		{NamePrefix: "web-", NameSequential: true, NameWordlist: "/words"},
+		{NameWordlist: "/nonexistent"},
+	} {
+		if _, err := newNamesGenerator(config); err == nil {
+			t.Fatalf("Expected an error for %+v", config)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
-	"strings"
 	"sync"
 
 	"github.com/docker/docker/daemon/networkdriver"
@@ -15,6 +14,7 @@ import (
 	"github.com/docker/docker/pkg/iptables"
 	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/networkfs/resolvconf"
+	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/kernel"
 	"github.com/docker/libcontainer/netlink"
 )
@@ -22,6 +22,9 @@ import (
 const (
 	DefaultNetworkBridge     = "docker0"
 	MaxAllocatedPortAttempts = 10
+	// IPServiceName is the name the IP of the bridge is registered under in
+	// the engine.
+	IPServiceName = "bridge.ip"
 )
 
 // Network interface represents the networking stack of a container
@@ -49,6 +52,8 @@ func (i *ifaces) Get(key string) *networkInterface {
 }
 
 var (
+	bridgeLog = log.New("bridge")
+
 	addrs = []string{
 		// Here we don't follow the convention of using the 1st IP of the range for the gateway.
 		// This is to use the same gateway IPs as the /24 ranges, which predate the /16 ranges.
@@ -181,13 +186,16 @@ func InitDriver(job *engine.Job) engine.Status {
 	bridgeNetwork = network
 
 	// https://github.com/docker/docker/issues/2768
-	job.Eng.Hack_SetGlobalVar("httpapi.bridgeIP", bridgeNetwork.IP)
+	if err := job.Eng.RegisterService(IPServiceName, bridgeNetwork.IP); err != nil {
+		return job.Error(err)
+	}
 
 	for name, f := range map[string]engine.Handler{
 		"allocate_interface": Allocate,       //: Docker 容器分配专属网络接口，分配容器网段的 IP 地址;
 		"release_interface":  Release,        //:释放 Docker 容器占用的网络接口资源;
 		"allocate_port":      AllocatePort,   //: Docker 容器分配一个端口;
 		"link":               LinkContainers, //实现 Docker 容器间的连接操作。
+		"bridge_status":      BridgeStatus,
 	} {
 		if err := job.Eng.Register(name, f); err != nil {
 			return job.Error(err)
@@ -197,70 +205,53 @@ func InitDriver(job *engine.Job) engine.Status {
 }
 
 //配置 iptables 规则，
+//缺失的规则收集后通过一次 iptables-restore 提交
 func setupIPTables(addr net.Addr, icc bool) error {
+	var rules []iptables.Rule
+
 	// Enable NAT
 	//使用 iptables 工具开启新建网桥的 NAT 功能
-	natArgs := []string{"POSTROUTING", "-t", "nat", "-s", addr.String(), "!", "-o", bridgeIface, "-j", "MASQUERADE"}
+	natArgs := []string{"-s", addr.String(), "!", "-o", bridgeIface, "-j", "MASQUERADE"}
 
-	if !iptables.Exists(natArgs...) {
-		if output, err := iptables.Raw(append([]string{"-I"}, natArgs...)...); err != nil {
-			return fmt.Errorf("Unable to enable network bridge NAT: %s", err)
-		} else if len(output) != 0 {
-			return fmt.Errorf("Error iptables postrouting: %s", output)
-		}
+	if !iptables.Exists(append([]string{"POSTROUTING", "-t", "nat"}, natArgs...)...) {
+		rules = append(rules, iptables.Rule{Action: iptables.Insert, Chain: iptables.Postrouting, Args: natArgs})
 	}
 	//从 dockerO 出来的数据包，如果需 要继续发往 dockerO ，则说明是 Docker 容器间的通信数据包。
 	var (
-		args       = []string{"FORWARD", "-i", bridgeIface, "-o", bridgeIface, "-j"}
+		args       = []string{"-i", bridgeIface, "-o", bridgeIface, "-j"}
 		acceptArgs = append(args, "ACCEPT")
 		dropArgs   = append(args, "DROP")
 	)
 
+	iccArgs, otherArgs, message := acceptArgs, dropArgs, "Enable inter-container communication"
 	if !icc {
-		iptables.Raw(append([]string{"-D"}, acceptArgs...)...)
-
-		if !iptables.Exists(dropArgs...) {
-			log.Debugf("Disable inter-container communication")
-			if output, err := iptables.Raw(append([]string{"-I"}, dropArgs...)...); err != nil {
-				return fmt.Errorf("Unable to prevent intercontainer communication: %s", err)
-			} else if len(output) != 0 {
-				return fmt.Errorf("Error disabling intercontainer communication: %s", output)
-			}
-		}
-	} else {
-		iptables.Raw(append([]string{"-D"}, dropArgs...)...)
-
-		if !iptables.Exists(acceptArgs...) {
-			log.Debugf("Enable inter-container communication")
-			if output, err := iptables.Raw(append([]string{"-I"}, acceptArgs...)...); err != nil {
-				return fmt.Errorf("Unable to allow intercontainer communication: %s", err)
-			} else if len(output) != 0 {
-				return fmt.Errorf("Error enabling intercontainer communication: %s", output)
-			}
-		}
+		iccArgs, otherArgs, message = dropArgs, acceptArgs, "Disable inter-container communication"
+	}
+	if iptables.Exists(append([]string{"FORWARD"}, otherArgs...)...) {
+		rules = append(rules, iptables.Rule{Action: iptables.Delete, Chain: iptables.Forward, Args: otherArgs})
+	}
+	if !iptables.Exists(append([]string{"FORWARD"}, iccArgs...)...) {
+		bridgeLog.Debugf(message)
+		rules = append(rules, iptables.Rule{Action: iptables.Insert, Chain: iptables.Forward, Args: iccArgs})
 	}
 
 	// Accept all non-intercontainer outgoing packets
 	//允许所有从 dockerO 发出且不是继续发向 dockerO 的数据包
-	outgoingArgs := []string{"FORWARD", "-i", bridgeIface, "!", "-o", bridgeIface, "-j", "ACCEPT"}
-	if !iptables.Exists(outgoingArgs...) {
-		if output, err := iptables.Raw(append([]string{"-I"}, outgoingArgs...)...); err != nil {
-			return fmt.Errorf("Unable to allow outgoing packets: %s", err)
-		} else if len(output) != 0 {
-			return fmt.Errorf("Error iptables allow outgoing: %s", output)
-		}
+	outgoingArgs := []string{"-i", bridgeIface, "!", "-o", bridgeIface, "-j", "ACCEPT"}
+	if !iptables.Exists(append([]string{"FORWARD"}, outgoingArgs...)...) {
+		rules = append(rules, iptables.Rule{Action: iptables.Insert, Chain: iptables.Forward, Args: outgoingArgs})
 	}
 
 	// Accept incoming packets for existing connections
 	//对于发往 dockerO ，并且属于已经建立的连接的数据包， Docker 无条件接受这些连接上的数据包，
-	existingArgs := []string{"FORWARD", "-o", bridgeIface, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
+	existingArgs := []string{"-o", bridgeIface, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
 
-	if !iptables.Exists(existingArgs...) {
-		if output, err := iptables.Raw(append([]string{"-I"}, existingArgs...)...); err != nil {
-			return fmt.Errorf("Unable to allow incoming packets: %s", err)
-		} else if len(output) != 0 {
-			return fmt.Errorf("Error iptables allow incoming: %s", output)
-		}
+	if !iptables.Exists(append([]string{"FORWARD"}, existingArgs...)...) {
+		rules = append(rules, iptables.Rule{Action: iptables.Insert, Chain: iptables.Forward, Args: existingArgs})
+	}
+
+	if err := iptables.Apply(rules...); err != nil {
+		return fmt.Errorf("Unable to setup the iptables rules of the network bridge: %s", err)
 	}
 	return nil
 }
@@ -298,7 +289,7 @@ func createBridge(bridgeIP string) error {
 					ifaceAddr = addr
 					break
 				} else {
-					log.Debugf("%s %s", addr, err)
+					bridgeLog.Debugf("%s %s", addr, err)
 				}
 			}
 		}
@@ -307,7 +298,7 @@ func createBridge(bridgeIP string) error {
 	if ifaceAddr == "" {
 		return fmt.Errorf("Could not find a free IP address range for interface '%s'. Please configure its address manually and run 'docker -b %s'", bridgeIface, bridgeIface)
 	}
-	log.Debugf("Creating bridge %s with network %s", bridgeIface, ifaceAddr)
+	bridgeLog.Debugf("Creating bridge %s with network %s", bridgeIface, ifaceAddr)
 
 	//创建网桥
 	if err := createBridgeIface(bridgeIface); err != nil {
@@ -340,7 +331,7 @@ func createBridgeIface(name string) error {
 	// only set the bridge's mac address if the kernel version is > 3.3
 	// before that it was not supported
 	setBridgeMacAddr := err == nil && (kv.Kernel >= 3 && kv.Major >= 3)
-	log.Debugf("setting bridge mac address = %v", setBridgeMacAddr)
+	bridgeLog.Debugf("setting bridge mac address = %v", setBridgeMacAddr)
 	return netlink.CreateBridge(name, setBridgeMacAddr)
 }
 
@@ -393,16 +384,49 @@ func Release(job *engine.Job) engine.Status {
 
 	for _, nat := range containerInterface.PortMappings {
 		if err := portmapper.Unmap(nat); err != nil {
-			log.Infof("Unable to unmap port %s: %s", nat, err)
+			bridgeLog.Infof("Unable to unmap port %s: %s", nat, err)
 		}
 	}
 
 	if err := ipallocator.ReleaseIP(bridgeNetwork, &containerInterface.IP); err != nil {
-		log.Infof("Unable to release ip %s", err)
+		bridgeLog.Infof("Unable to release ip %s", err)
 	}
 	return engine.StatusOK
 }
 
+// BridgeStatus reports the bridge, whether it is up and still has the
+// address of the network of the containers.
+func BridgeStatus(job *engine.Job) engine.Status {
+	out := engine.Env{}
+	out.Set("Bridge", bridgeIface)
+	out.Set("Network", bridgeNetwork.String())
+	if err := checkBridge(); err != nil {
+		out.Set("Error", err.Error())
+	}
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func checkBridge() error {
+	iface, err := net.InterfaceByName(bridgeIface)
+	if err != nil {
+		return err
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return fmt.Errorf("Bridge %s is down", bridgeIface)
+	}
+	addr, err := networkdriver.GetIfaceAddr(bridgeIface)
+	if err != nil {
+		return err
+	}
+	if !addr.(*net.IPNet).IP.Equal(bridgeNetwork.IP) {
+		return fmt.Errorf("Bridge %s has the address %s instead of %s", bridgeIface, addr, bridgeNetwork)
+	}
+	return nil
+}
+
 // Allocate an external port and map it to the interface
 func AllocatePort(job *engine.Job) engine.Status {
 	var (
@@ -418,10 +442,16 @@ func AllocatePort(job *engine.Job) engine.Status {
 	)
 
 	if hostIP != "" {
-		ip = net.ParseIP(hostIP)
+		// an address or the name of an interface
+		if ip, err = parsers.ResolveHostIP(hostIP); err != nil {
+			return job.Error(err)
+		}
 	}
 
 	// host ip, proto, and host port
+	if proto, err = parsers.ParseProto(proto); err != nil {
+		return job.Error(err)
+	}
 	var container net.Addr
 	switch proto {
 	case "tcp":
@@ -429,7 +459,7 @@ func AllocatePort(job *engine.Job) engine.Status {
 	case "udp":
 		container = &net.UDPAddr{IP: network.IP, Port: containerPort}
 	default:
-		return job.Errorf("unsupported address type %s", proto)
+		return job.Errorf("Publishing %s ports is not supported", proto)
 	}
 
 	//
@@ -492,35 +522,37 @@ func LinkContainers(job *engine.Job) engine.Status {
 		ignoreErrors = job.GetenvBool("IgnoreErrors")
 		ports        = job.GetenvList("Ports")
 	)
-	split := func(p string) (string, string) {
-		parts := strings.Split(p, "/")
-		return parts[0], parts[1]
-	}
-
+	var rules []iptables.Rule
 	for _, p := range ports {
-		port, proto := split(p)
-		if output, err := iptables.Raw(action, "FORWARD",
-			"-i", bridgeIface, "-o", bridgeIface,
-			"-p", proto,
-			"-s", parentIP,
-			"--dport", port,
-			"-d", childIP,
-			"-j", "ACCEPT"); !ignoreErrors && err != nil {
+		portRange, proto, err := parsers.ParsePortProto(p)
+		if err != nil {
 			return job.Error(err)
-		} else if len(output) != 0 {
-			return job.Errorf("Error toggle iptables forward: %s", output)
 		}
-
-		if output, err := iptables.Raw(action, "FORWARD",
-			"-i", bridgeIface, "-o", bridgeIface,
-			"-p", proto,
-			"-s", childIP,
-			"--sport", port,
-			"-d", parentIP,
-			"-j", "ACCEPT"); !ignoreErrors && err != nil {
+		port := portRange.String()
+		rules = append(rules,
+			iptables.Rule{Action: iptables.Action(action), Chain: iptables.Forward, Args: []string{
+				"-i", bridgeIface, "-o", bridgeIface,
+				"-p", proto,
+				"-s", parentIP,
+				"--dport", port,
+				"-d", childIP,
+				"-j", "ACCEPT"}},
+			iptables.Rule{Action: iptables.Action(action), Chain: iptables.Forward, Args: []string{
+				"-i", bridgeIface, "-o", bridgeIface,
+				"-p", proto,
+				"-s", childIP,
+				"--sport", port,
+				"-d", parentIP,
+				"-j", "ACCEPT"}})
+	}
+	if err := iptables.Apply(rules...); err != nil {
+		if !ignoreErrors {
 			return job.Error(err)
-		} else if len(output) != 0 {
-			return job.Errorf("Error toggle iptables forward: %s", output)
+		}
+		// a missing rule fails the whole batch, the other ones still have
+		// to be toggled
+		for _, rule := range rules {
+			iptables.Apply(rule)
 		}
 	}
 	return engine.StatusOK
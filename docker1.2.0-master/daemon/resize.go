@@ -11,13 +11,17 @@ func (daemon *Daemon) ContainerResize(job *engine.Job) engine.Status {
 		return job.Errorf("Not enough arguments. Usage: %s CONTAINER HEIGHT WIDTH\n", job.Name)
 	}
 	name := job.Args[0]
+	// The window sizes are unsigned shorts
 	height, err := strconv.Atoi(job.Args[1])
 	if err != nil {
-		return job.Error(err)
+		return job.Errorf("Bad parameter: invalid height %q", job.Args[1])
 	}
 	width, err := strconv.Atoi(job.Args[2])
 	if err != nil {
-		return job.Error(err)
+		return job.Errorf("Bad parameter: invalid width %q", job.Args[2])
+	}
+	if height < 0 || height > 0xffff || width < 0 || width > 0xffff {
+		return job.Errorf("Bad parameter: invalid tty size %dx%d", height, width)
 	}
 	if container := daemon.Get(name); container != nil {
 		if err := container.Resize(height, width); err != nil {
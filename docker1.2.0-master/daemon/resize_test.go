@@ -0,0 +1,45 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+type fakeTerminal struct {
+	execdriver.Terminal
+	height, width int
+}
+
+func (t *fakeTerminal) Resize(h, w int) error {
+	t.height, t.width = h, w
+	return nil
+}
+
+func TestContainerResize(t *testing.T) {
+	term := &fakeTerminal{}
+	container := &Container{
+		ID:      "abc",
+		State:   NewState(),
+		command: &execdriver.Command{Terminal: term},
+	}
+	if err := container.Resize(24, 80); err == nil {
+		t.Fatal("Expected an error resizing a stopped container")
+	}
+
+	container.State.SetRunning(42)
+	if err := container.Resize(24, 80); err != nil {
+		t.Fatal(err)
+	}
+	if term.height != 24 || term.width != 80 {
+		t.Fatalf("Expected the tty to be resized to 24x80, got %dx%d", term.height, term.width)
+	}
+
+	// A restart gets a new tty of the same size
+	term = &fakeTerminal{}
+	container.command.Terminal = term
+	container.restoreTtySize()
+	if term.height != 24 || term.width != 80 {
+		t.Fatalf("Expected the new tty to be resized to 24x80, got %dx%d", term.height, term.width)
+	}
+}
@@ -9,14 +9,14 @@ func (daemon *Daemon) ContainerRestart(job *engine.Job) engine.Status {
 		return job.Errorf("Usage: %s CONTAINER\n", job.Name)
 	}
 	var (
-		name = job.Args[0]
-		t    = 10
+		name    = job.Args[0]
+		payload = stopPayload{Timeout: 10}
 	)
-	if job.EnvExists("t") {
-		t = job.GetenvInt("t")
+	if err := job.DecodePayload(&payload); err != nil {
+		return job.Error(err)
 	}
 	if container := daemon.Get(name); container != nil {
-		if err := container.Restart(int(t)); err != nil {
+		if err := container.Restart(payload.Timeout); err != nil {
 			return job.Errorf("Cannot restart container %s: %s\n", name, err)
 		}
 		container.LogEvent("restart")
@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/libcontainer/security/seccomp"
+)
+
+// loadSeccompProfile reads the seccomp filter of the container, nil if it
+// has none. The profile lists the allowed syscalls, e.g.
+// {"allowed": ["read", "write"]}, in audit mode the other ones being only
+// logged.
+func loadSeccompProfile(hostConfig *runconfig.HostConfig) (*seccomp.Config, error) {
+	if hostConfig.SeccompProfile == "" && !hostConfig.SeccompAudit {
+		return nil, nil
+	}
+	config := &seccomp.Config{}
+	if hostConfig.SeccompProfile != "" {
+		data, err := ioutil.ReadFile(hostConfig.SeccompProfile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the seccomp profile %s: %s", hostConfig.SeccompProfile, err)
+		}
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("Invalid seccomp profile %s: %s", hostConfig.SeccompProfile, err)
+		}
+	}
+	config.Audit = hostConfig.SeccompAudit
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid seccomp profile %s: %s", hostConfig.SeccompProfile, err)
+	}
+	return config, nil
+}
+
+// auditRecord matches the kernel records of the syscalls logged by a seccomp
+// filter, e.g. audit: type=1326 ... pid=1234 ... syscall=59 ... code=0x7ffc0000
+var auditRecord = regexp.MustCompile(`type=1326 .* pid=([0-9]+) .*syscall=([0-9]+) .*code=0x7ffc0000`)
+
+// seccompAuditor turns the syscalls logged by the filters of the containers
+// in audit mode into events, once per syscall and start of a container. The
+// kernel logs them to /dev/kmsg unless auditd runs, which gets them instead.
+type seccompAuditor struct {
+	sync.Mutex
+	daemon   *Daemon
+	once     sync.Once
+	reported map[string]map[string]bool // the syscalls reported, by container
+}
+
+func newSeccompAuditor(daemon *Daemon) *seccompAuditor {
+	return &seccompAuditor{
+		daemon:   daemon,
+		reported: make(map[string]map[string]bool),
+	}
+}
+
+// watch starts reporting the syscalls of the container, the auditor reading
+// the kernel log from the first container in audit mode.
+func (a *seccompAuditor) watch(container *Container) {
+	a.Lock()
+	a.reported[container.ID] = make(map[string]bool)
+	a.Unlock()
+	a.once.Do(func() {
+		kmsg, err := os.Open("/dev/kmsg")
+		if err != nil {
+			daemonLog.Errorf("Error opening the kernel log, the syscalls audited won't be reported: %s", err)
+			return
+		}
+		// only the records logged from now on
+		if _, err := kmsg.Seek(0, os.SEEK_END); err != nil {
+			daemonLog.Debugf("Error seeking the end of the kernel log: %s", err)
+		}
+		go a.run(kmsg)
+	})
+}
+
+func (a *seccompAuditor) run(kmsg *os.File) {
+	defer kmsg.Close()
+	// every read returns one record
+	buf := make([]byte, 8192)
+	for {
+		n, err := kmsg.Read(buf)
+		if err != nil {
+			if perr, ok := err.(*os.PathError); ok && perr.Err == syscall.EPIPE {
+				// the records overwritten before they were read
+				continue
+			}
+			daemonLog.Errorf("Error reading the kernel log, the syscalls audited won't be reported: %s", err)
+			return
+		}
+		pid, nr, ok := parseAuditRecord(string(buf[:n]))
+		if !ok {
+			continue
+		}
+		if container := a.containerOf(pid); container != nil {
+			a.report(container, seccomp.SyscallName(nr))
+		}
+	}
+}
+
+// parseAuditRecord returns the pid and the syscall of a record of a syscall
+// logged by seccomp.
+func parseAuditRecord(record string) (int, int, bool) {
+	m := auditRecord.FindStringSubmatch(record)
+	if m == nil {
+		return 0, 0, false
+	}
+	pid, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	nr, err := strconv.Atoi(m[2])
+	if err != nil {
+		return 0, 0, false
+	}
+	return pid, nr, true
+}
+
+// containerOf returns the running container in audit mode of the process,
+// by its cgroups.
+func (a *seccompAuditor) containerOf(pid int) *Container {
+	cgroups, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		// the process exited already
+		return nil
+	}
+	for _, container := range a.daemon.List() {
+		if container.State.IsRunning() && container.hostConfig != nil && container.hostConfig.SeccompAudit &&
+			strings.Contains(string(cgroups), container.ID) {
+			return container
+		}
+	}
+	return nil
+}
+
+func (a *seccompAuditor) report(container *Container, name string) {
+	a.Lock()
+	reported := a.reported[container.ID]
+	if reported == nil || reported[name] {
+		a.Unlock()
+		return
+	}
+	reported[name] = true
+	a.Unlock()
+	container.LogEvent("seccomp_audit: " + name)
+}
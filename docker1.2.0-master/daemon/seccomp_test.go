@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestLoadSeccompProfile(t *testing.T) {
+	if config, err := loadSeccompProfile(&runconfig.HostConfig{}); err != nil || config != nil {
+		t.Fatalf("Expected no filter, got %v, %v", config, err)
+	}
+
+	// Audit every syscall without profile
+	config, err := loadSeccompProfile(&runconfig.HostConfig{SeccompAudit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Audit || len(config.Allowed) != 0 {
+		t.Fatalf("Expected to audit every syscall, got %#v", config)
+	}
+
+	f, err := ioutil.TempFile("", "docker-seccomp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`{"allowed": ["read", "write", "execve"]}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	config, err = loadSeccompProfile(&runconfig.HostConfig{SeccompProfile: f.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Audit || len(config.Allowed) != 3 || config.Allowed[2] != "execve" {
+		t.Fatalf("Unexpected filter %#v", config)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte(`{"allowed": ["read", "foo"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSeccompProfile(&runconfig.HostConfig{SeccompProfile: f.Name()}); err == nil {
+		t.Fatal("Expected an error for an unknown syscall")
+	}
+}
+
+func TestParseAuditRecord(t *testing.T) {
+	record := `5,1234,5678901,-;audit: type=1326 audit(1500000000.123:45): auid=4294967295 uid=0 gid=0 ses=4294967295 pid=4321 comm="ls" exe="/bin/ls" sig=0 arch=c000003e syscall=59 compat=0 ip=0x7f0000000000 code=0x7ffc0000`
+	pid, nr, ok := parseAuditRecord(record)
+	if !ok || pid != 4321 || nr != 59 {
+		t.Fatalf("Expected pid 4321 and syscall 59, got %d, %d, %v", pid, nr, ok)
+	}
+	// The syscalls killed or denied are not audited
+	if _, _, ok := parseAuditRecord(record[:len(record)-len("7ffc0000")] + "00000000"); ok {
+		t.Fatal("Expected only the logged syscalls to be parsed")
+	}
+	if _, _, ok := parseAuditRecord("6,1235,5678902,-;eth0: link up"); ok {
+		t.Fatal("Expected other records to be ignored")
+	}
+}
@@ -3,9 +3,12 @@ package daemon
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -48,6 +51,11 @@ func (daemon *Daemon) setHostConfig(container *Container, hostConfig *runconfig.
 		splitBind := strings.Split(bind, ":")
 		source := splitBind[0]
 
+		// named volumes are created on mount
+		if !filepath.IsAbs(source) && IsValidVolumeName(source) {
+			continue
+		}
+
 		// ensure the source exists on the host
 		_, err := os.Stat(source)
 		if err != nil && os.IsNotExist(err) {
@@ -57,6 +65,60 @@ func (daemon *Daemon) setHostConfig(container *Container, hostConfig *runconfig.
 			}
 		}
 	}
+	for _, spec := range hostConfig.VolumesFrom {
+		name, _, err := runconfig.ParseVolumesFrom(spec)
+		if err != nil {
+			return err
+		}
+		if daemon.Get(name) == nil {
+			return fmt.Errorf("Container %s not found. Impossible to mount its volumes", name)
+		}
+	}
+	for _, rule := range hostConfig.DeviceCgroupRules {
+		if _, err := opts.ValidateDeviceCgroupRule(rule); err != nil {
+			return err
+		}
+	}
+	for _, u := range hostConfig.Ulimits {
+		if _, err := u.GetRlimit(); err != nil {
+			return err
+		}
+		if u.Soft < 0 || u.Soft > u.Hard {
+			return fmt.Errorf("Invalid ulimit %s, the soft limit must be between 0 and the hard limit", u)
+		}
+	}
+	if hostConfig.Gpus != "" {
+		if _, err := runconfig.ParseGpus(hostConfig.Gpus); err != nil {
+			return err
+		}
+	}
+	if hostConfig.SeccompProfile != "" && !filepath.IsAbs(hostConfig.SeccompProfile) {
+		return fmt.Errorf("Invalid seccomp profile %s, the path must be absolute", hostConfig.SeccompProfile)
+	}
+	if hostConfig.LxcTemplate != "" && !filepath.IsAbs(hostConfig.LxcTemplate) {
+		return fmt.Errorf("Invalid lxc template %s, the path must be absolute", hostConfig.LxcTemplate)
+	}
+	for volPath, options := range hostConfig.MountOptions {
+		if !filepath.IsAbs(volPath) {
+			return fmt.Errorf("Invalid mount options for %s, the volume path must be absolute", volPath)
+		}
+		if err := runconfig.ValidateMountOptions(options); err != nil {
+			return fmt.Errorf("Invalid mount options for %s: %s", volPath, err)
+		}
+		// Only copy and nocopy are applied without the native driver
+		if strings.HasPrefix(daemon.execDriver.Name(), "lxc") {
+			for _, opt := range options {
+				if opt != "copy" && opt != "nocopy" {
+					return fmt.Errorf("Invalid mount options for %s: the %s execution driver doesn't support %s", volPath, daemon.execDriver.Name(), opt)
+				}
+			}
+		}
+	}
+	// Make sure the log driver accepts the options before they get persisted
+	logConfig := daemon.mergeLogConfig(hostConfig.LogConfig)
+	if err := logger.ValidateLogOpts(logConfig.Type, logConfig.Options); err != nil {
+		return err
+	}
 	// Register any links from the host config before starting the container
 	if err := daemon.RegisterLinks(container, hostConfig); err != nil {
 		return err
@@ -66,3 +128,25 @@ func (daemon *Daemon) setHostConfig(container *Container, hostConfig *runconfig.
 
 	return nil
 }
+
+// mergeLogConfig completes the log configuration of a container with the
+// daemon's --log-driver and --log-opt. The daemon options only apply to
+// containers using the daemon's log driver, options set on the container
+// take precedence over them.
+func (daemon *Daemon) mergeLogConfig(cfg runconfig.LogConfig) runconfig.LogConfig {
+	if cfg.Type == "" {
+		cfg.Type = daemon.config.LogDriver
+	}
+	if cfg.Type != daemon.config.LogDriver || len(daemon.logOpts) == 0 {
+		return cfg
+	}
+	options := make(map[string]string, len(daemon.logOpts)+len(cfg.Options))
+	for k, v := range daemon.logOpts {
+		options[k] = v
+	}
+	for k, v := range cfg.Options {
+		options[k] = v
+	}
+	cfg.Options = options
+	return cfg
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/pkg/units"
 )
 
@@ -16,8 +17,11 @@ type State struct {
 	Restarting bool
 	Pid        int
 	ExitCode   int
+	OOMKilled  bool // the container ran out of memory since it started
 	StartedAt  time.Time
 	FinishedAt time.Time
+	Health     *Health                   // nil when the container has no health check
+	Usage      *execdriver.ResourceUsage // of the last run, nil while running
 	waitChan   chan struct{}
 }
 
@@ -40,6 +44,9 @@ func (s *State) String() string {
 			return fmt.Sprintf("Restarting (%d) %s ago", s.ExitCode, units.HumanDuration(time.Now().UTC().Sub(s.FinishedAt)))
 		}
 
+		if s.Health != nil {
+			return fmt.Sprintf("Up %s (%s)", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)), s.Health.Status)
+		}
 		return fmt.Sprintf("Up %s", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)))
 	}
 
@@ -109,6 +116,25 @@ func (s *State) WaitStop(timeout time.Duration) (int, error) {
 	return s.GetExitCode(), nil
 }
 
+// WaitStopOrCancel waits until state is stopped, like WaitStop, or until
+// canceled is closed, in which case it returns an error.
+func (s *State) WaitStopOrCancel(canceled <-chan struct{}) (int, error) {
+	s.RLock()
+	if !s.Running {
+		exitCode := s.ExitCode
+		s.RUnlock()
+		return exitCode, nil
+	}
+	waitChan := s.waitChan
+	s.RUnlock()
+	select {
+	case <-waitChan:
+		return s.GetExitCode(), nil
+	case <-canceled:
+		return -1, fmt.Errorf("Canceled waiting for the container to stop")
+	}
+}
+
 func (s *State) IsRunning() bool {
 	s.RLock()
 	res := s.Running
@@ -136,6 +162,8 @@ func (s *State) SetRunning(pid int) {
 	s.Paused = false
 	s.Restarting = false
 	s.ExitCode = 0
+	s.OOMKilled = false
+	s.Usage = nil
 	s.Pid = pid
 	s.StartedAt = time.Now().UTC()
 	close(s.waitChan) // fire waiters for start
@@ -155,6 +183,20 @@ func (s *State) SetStopped(exitCode int) {
 	s.Unlock()
 }
 
+// SetOOMKilled records the container ran out of memory.
+func (s *State) SetOOMKilled() {
+	s.Lock()
+	s.OOMKilled = true
+	s.Unlock()
+}
+
+// SetUsage records the resource usage of the container when it exited.
+func (s *State) SetUsage(usage *execdriver.ResourceUsage) {
+	s.Lock()
+	s.Usage = usage
+	s.Unlock()
+}
+
 // SetRestarting is when docker hanldes the auto restart of containers when they are
 // in the middle of a stop and being restarted again
 func (s *State) SetRestarting(exitCode int) {
@@ -190,6 +232,24 @@ func (s *State) SetUnpaused() {
 	s.Unlock()
 }
 
+func (s *State) setHealth(health *Health) {
+	s.Lock()
+	s.Health = health
+	s.Unlock()
+}
+
+// updateHealth records the result of a health check, returning the status
+// and whether it changed.
+func (s *State) updateHealth(exitCode int, output string, retries int) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if s.Health == nil {
+		return "", false
+	}
+	changed := s.Health.update(exitCode, output, retries)
+	return s.Health.Status, changed
+}
+
 func (s *State) IsPaused() bool {
 	s.RLock()
 	res := s.Paused
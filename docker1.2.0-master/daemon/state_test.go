@@ -1,6 +1,8 @@
 package daemon
 
 import (
+	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -100,3 +102,85 @@ func TestStateTimeoutWait(t *testing.T) {
 	}
 
 }
+
+func TestStateHealth(t *testing.T) {
+	s := NewState()
+	if _, changed := s.updateHealth(0, "", 3); changed {
+		t.Fatal("Expected no health status without a health check")
+	}
+	s.setHealth(&Health{Status: HealthStarting})
+	for i, c := range []struct {
+		exitCode int
+		status   string
+		changed  bool
+	}{
+		{1, HealthStarting, false},
+		{0, HealthHealthy, true},
+		{1, HealthHealthy, false},
+		{1, HealthHealthy, false},
+		{1, HealthUnhealthy, true},
+		{1, HealthUnhealthy, false},
+		{0, HealthHealthy, true},
+	} {
+		status, changed := s.updateHealth(c.exitCode, "output", 3)
+		if status != c.status || changed != c.changed {
+			t.Fatalf("Check %d: expected status %s (changed %v), got %s (changed %v)", i, c.status, c.changed, status, changed)
+		}
+	}
+	if s.Health.FailingStreak != 0 || s.Health.LastOutput != "output" {
+		t.Fatalf("Unexpected health %+v", s.Health)
+	}
+}
+
+func TestStateWaitStopOrCancel(t *testing.T) {
+	s := NewState()
+	s.SetRunning(42)
+	canceled := make(chan struct{})
+	errs := make(chan error)
+	go func() {
+		_, err := s.WaitStopOrCancel(canceled)
+		errs <- err
+	}()
+	close(canceled)
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("Wait wasn't canceled in 1 second")
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("Expected an error waiting with a canceled request")
+		}
+	}
+
+	go func() {
+		exitCode, err := s.WaitStopOrCancel(nil)
+		if exitCode != 3 {
+			err = fmt.Errorf("Expected exit code 3, got %d", exitCode)
+		}
+		errs <- err
+	}()
+	s.SetStopped(3)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateOOMKilled(t *testing.T) {
+	s := NewState()
+	s.SetRunning(42)
+	s.SetOOMKilled()
+	s.SetStopped(137)
+	if !s.OOMKilled {
+		t.Fatal("Expected OOMKilled to be kept once stopped")
+	}
+	b, err := s.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"OOMKilled":true`) {
+		t.Fatalf("Expected OOMKilled in the json of the state, got %s", b)
+	}
+	s.SetRunning(43)
+	if s.OOMKilled {
+		t.Fatal("Expected OOMKilled to be reset on start")
+	}
+}
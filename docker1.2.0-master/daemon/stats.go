@@ -0,0 +1,29 @@
+package daemon
+
+import (
+	"github.com/docker/docker/engine"
+)
+
+// ContainerStats writes the metrics of the cgroups of a running container,
+// as read by the execution driver, as json to the stdout of the job.
+func (daemon *Daemon) ContainerStats(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s CONTAINER\n", job.Name)
+	}
+	name := job.Args[0]
+	container := daemon.Get(name)
+	if container == nil {
+		return job.Errorf("No such container: %s", name)
+	}
+	if !container.State.IsRunning() {
+		return job.Errorf("Container %s is not running", name)
+	}
+	stats, err := daemon.execDriver.Stats(container.ID)
+	if err != nil {
+		return job.Error(err)
+	}
+	if err := job.WritePayload(stats); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
@@ -4,22 +4,28 @@ import (
 	"github.com/docker/docker/engine"
 )
 
+// stopPayload is the payload of the stop and restart jobs: the seconds to
+// wait for the container to stop before killing it.
+type stopPayload struct {
+	Timeout int `json:"t"`
+}
+
 func (daemon *Daemon) ContainerStop(job *engine.Job) engine.Status {
 	if len(job.Args) != 1 {
 		return job.Errorf("Usage: %s CONTAINER\n", job.Name)
 	}
 	var (
-		name = job.Args[0]
-		t    = 10
+		name    = job.Args[0]
+		payload = stopPayload{Timeout: 10}
 	)
-	if job.EnvExists("t") {
-		t = job.GetenvInt("t")
+	if err := job.DecodePayload(&payload); err != nil {
+		return job.Error(err)
 	}
 	if container := daemon.Get(name); container != nil {
 		if !container.State.IsRunning() {
 			return job.Errorf("Container already stopped")
 		}
-		if err := container.Stop(int(t)); err != nil {
+		if err := container.Stop(payload.Timeout); err != nil {
 			return job.Errorf("Cannot stop container %s: %s\n", name, err)
 		}
 		container.LogEvent("stop")
@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"strconv"
+
+	"github.com/docker/docker/engine"
+)
+
+// UploadCreate starts an upload, whose id is printed.
+func (daemon *Daemon) UploadCreate(job *engine.Job) engine.Status {
+	id, err := daemon.uploads.Create()
+	if err != nil {
+		return job.Error(err)
+	}
+	job.Printf("%s\n", id)
+	return engine.StatusOK
+}
+
+// UploadAppend appends the chunk read from stdin to the upload, at the
+// offset of the env.
+func (daemon *Daemon) UploadAppend(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s ID", job.Name)
+	}
+	offset, err := strconv.ParseInt(job.Getenv("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return job.Errorf("Bad parameter: invalid offset %q", job.Getenv("offset"))
+	}
+	if _, err := daemon.uploads.Append(job.Args[0], offset, job.Stdin); err != nil {
+		return job.Error(err)
+	}
+	return daemon.writeUploadEnv(job, job.Args[0])
+}
+
+// UploadInspect prints the id, the size and the path of the upload.
+func (daemon *Daemon) UploadInspect(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s ID", job.Name)
+	}
+	return daemon.writeUploadEnv(job, job.Args[0])
+}
+
+func (daemon *Daemon) UploadRm(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s ID", job.Name)
+	}
+	if err := daemon.uploads.Remove(job.Args[0]); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+func (daemon *Daemon) writeUploadEnv(job *engine.Job, id string) engine.Status {
+	p, err := daemon.uploads.Path(id)
+	if err != nil {
+		return job.Error(err)
+	}
+	size, err := daemon.uploads.Size(id)
+	if err != nil {
+		return job.Error(err)
+	}
+	out := &engine.Env{}
+	out.Set("Id", id)
+	out.SetInt64("Size", size)
+	out.Set("Path", p)
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
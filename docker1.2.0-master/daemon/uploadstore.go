@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/utils"
+)
+
+// uploadExpiry is how long an upload is kept without being written to.
+const uploadExpiry = 24 * time.Hour
+
+var validUploadID = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// UploadStore keeps the build contexts and the image archives uploaded in
+// chunks, one file by upload, so that a client can resume an interrupted
+// upload from the size already stored.
+type UploadStore struct {
+	root    string
+	writing map[string]bool // Uploads being written to
+	sync.Mutex
+}
+
+func NewUploadStore(root string) (*UploadStore, error) {
+	abspath, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abspath, 0700); err != nil {
+		return nil, err
+	}
+	store := &UploadStore{
+		root:    abspath,
+		writing: make(map[string]bool),
+	}
+	store.expire(time.Now())
+	return store, nil
+}
+
+// Create starts a new empty upload and returns its id.
+func (store *UploadStore) Create() (string, error) {
+	store.expire(time.Now())
+	id := utils.GenerateRandomID()
+	f, err := os.OpenFile(filepath.Join(store.root, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return id, nil
+}
+
+// Path returns the file of the upload.
+func (store *UploadStore) Path(id string) (string, error) {
+	if !validUploadID.MatchString(id) {
+		return "", fmt.Errorf("No such upload: %s", id)
+	}
+	p := filepath.Join(store.root, id)
+	if _, err := os.Stat(p); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("No such upload: %s", id)
+		}
+		return "", err
+	}
+	return p, nil
+}
+
+// Size returns the number of bytes stored for the upload, the offset its
+// next chunk starts at.
+func (store *UploadStore) Size(id string) (int64, error) {
+	p, err := store.Path(id)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// Append writes the chunk read from src at offset, which must be the size
+// of the upload. It returns the new size, which counts the bytes written
+// before an error, so that the upload resumes after them.
+func (store *UploadStore) Append(id string, offset int64, src io.Reader) (int64, error) {
+	p, err := store.Path(id)
+	if err != nil {
+		return 0, err
+	}
+	store.Lock()
+	if store.writing[id] {
+		store.Unlock()
+		return 0, fmt.Errorf("Conflict: upload %s is already being written to", id)
+	}
+	store.writing[id] = true
+	store.Unlock()
+	defer func() {
+		store.Lock()
+		delete(store.writing, id)
+		store.Unlock()
+	}()
+
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if size := fi.Size(); offset != size {
+		return size, fmt.Errorf("Conflict: upload %s has %d bytes, the chunk at %d can't be appended", id, size, offset)
+	}
+	n, err := io.Copy(f, src)
+	if err == nil {
+		err = f.Sync()
+	}
+	return offset + n, err
+}
+
+// Remove deletes the upload.
+func (store *UploadStore) Remove(id string) error {
+	p, err := store.Path(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// expire removes the uploads not written to since uploadExpiry.
+func (store *UploadStore) expire(now time.Time) {
+	files, err := ioutil.ReadDir(store.root)
+	if err != nil {
+		daemonLog.Errorf("Failed to list the uploads: %s", err)
+		return
+	}
+	store.Lock()
+	defer store.Unlock()
+	for _, fi := range files {
+		if !validUploadID.MatchString(fi.Name()) || store.writing[fi.Name()] {
+			continue
+		}
+		if now.Sub(fi.ModTime()) > uploadExpiry {
+			if err := os.Remove(filepath.Join(store.root, fi.Name())); err != nil {
+				daemonLog.Errorf("Failed to remove the expired upload %s: %s", fi.Name(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingReader struct {
+	data string
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, errors.New("connection reset")
+	}
+	r.read = true
+	return copy(p, r.data), nil
+}
+
+func TestUploadStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-uploadstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store, err := NewUploadStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := store.Append(id, 0, strings.NewReader("hello ")); err != nil || size != 6 {
+		t.Fatalf("Expected a size of 6, got %d (%v)", size, err)
+	}
+
+	// An interrupted chunk keeps what was written
+	if size, err := store.Append(id, 6, &failingReader{data: "wor"}); err == nil || size != 9 {
+		t.Fatalf("Expected an error and a size of 9, got %d (%v)", size, err)
+	}
+	if _, err := store.Append(id, 6, strings.NewReader("world")); err == nil || !strings.HasPrefix(err.Error(), "Conflict") {
+		t.Fatalf("Expected a conflict appending at the wrong offset, got %v", err)
+	}
+	if size, err := store.Append(id, 9, strings.NewReader("ld")); err != nil || size != 11 {
+		t.Fatalf("Expected a size of 11, got %d (%v)", size, err)
+	}
+	if size, err := store.Size(id); err != nil || size != 11 {
+		t.Fatalf("Expected a size of 11, got %d (%v)", size, err)
+	}
+	p, err := store.Path(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(p); err != nil || string(data) != "hello world" {
+		t.Fatalf("Expected hello world, got %q (%v)", data, err)
+	}
+
+	for _, invalid := range []string{"", "../" + id, strings.Repeat("0", 64)} {
+		if _, err := store.Path(invalid); err == nil || !strings.HasPrefix(err.Error(), "No such upload") {
+			t.Fatalf("Expected no upload %q, got %v", invalid, err)
+		}
+	}
+
+	if err := store.Remove(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Append(id, 11, strings.NewReader("!")); err == nil {
+		t.Fatal("Expected an error appending to a removed upload")
+	}
+}
+
+func TestUploadStoreExpire(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-uploadstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store, err := NewUploadStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * uploadExpiry)
+	if err := os.Chtimes(filepath.Join(root, id), old, old); err != nil {
+		t.Fatal(err)
+	}
+	store.expire(time.Now())
+	if _, err := store.Size(id); err == nil {
+		t.Fatal("Expected the upload to expire")
+	}
+}
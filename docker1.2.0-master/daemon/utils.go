@@ -2,10 +2,13 @@ package daemon
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/nat"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/runconfig"
+	"github.com/docker/libcontainer/devices"
 )
 
 func migratePortMappings(config *runconfig.Config, hostConfig *runconfig.HostConfig) error {
@@ -48,4 +51,42 @@ func mergeLxcConfIntoOptions(hostConfig *runconfig.HostConfig, driverConfig map[
 		}
 		driverConfig["lxc"] = lxc
 	}
+	// the lxc driver validates them against the version of lxc
+	if hostConfig.LxcTemplate != "" {
+		driverConfig["lxc_template"] = []string{hostConfig.LxcTemplate}
+	}
+	if hostConfig.LxcFragment != "" {
+		driverConfig["lxc_fragment"] = []string{hostConfig.LxcFragment}
+	}
+}
+
+// parseDeviceCgroupRule returns the devices allowed by a device cgroup rule,
+// e.g. c 189:* rwm, a * number matching any device.
+func parseDeviceCgroupRule(rule string) (*devices.Device, error) {
+	if _, err := opts.ValidateDeviceCgroupRule(rule); err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(rule)
+	numbers := strings.SplitN(fields[1], ":", 2)
+	major, err := parseDeviceNumber(numbers[0])
+	if err != nil {
+		return nil, err
+	}
+	minor, err := parseDeviceNumber(numbers[1])
+	if err != nil {
+		return nil, err
+	}
+	return &devices.Device{
+		Type:              rune(fields[0][0]),
+		MajorNumber:       major,
+		MinorNumber:       minor,
+		CgroupPermissions: fields[2],
+	}, nil
+}
+
+func parseDeviceNumber(n string) (int64, error) {
+	if n == "*" {
+		return devices.Wildcard, nil
+	}
+	return strconv.ParseInt(n, 10, 64)
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
+	"github.com/docker/libcontainer/devices"
 )
 
 func TestMergeLxcConfig(t *testing.T) {
@@ -27,3 +28,37 @@ func TestMergeLxcConfig(t *testing.T) {
 		t.Fatalf("expected %s got %s", expected, cpuset)
 	}
 }
+
+func TestMergeLxcTemplate(t *testing.T) {
+	var (
+		hostConfig = &runconfig.HostConfig{
+			LxcTemplate: "/etc/docker/lxc.tmpl",
+			LxcFragment: "lxc.utsname = {{.ID}}",
+		}
+		driverConfig = make(map[string][]string)
+	)
+
+	mergeLxcConfIntoOptions(hostConfig, driverConfig)
+	if tmpl := driverConfig["lxc_template"]; len(tmpl) != 1 || tmpl[0] != hostConfig.LxcTemplate {
+		t.Fatalf("expected the lxc template %s got %v", hostConfig.LxcTemplate, tmpl)
+	}
+	if fragment := driverConfig["lxc_fragment"]; len(fragment) != 1 || fragment[0] != hostConfig.LxcFragment {
+		t.Fatalf("expected the lxc fragment %s got %v", hostConfig.LxcFragment, fragment)
+	}
+}
+
+func TestParseDeviceCgroupRule(t *testing.T) {
+	device, err := parseDeviceCgroupRule("c 189:* rwm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Type != 'c' || device.MajorNumber != 189 || device.MinorNumber != devices.Wildcard || device.CgroupPermissions != "rwm" {
+		t.Fatalf("Unexpected device %#v", device)
+	}
+	if allow := device.GetCgroupAllowString(); allow != "c 189:* rwm" {
+		t.Fatalf("Expected the rule to be allowed as is, got %q", allow)
+	}
+	if _, err := parseDeviceCgroupRule("c 189 rwm"); err == nil {
+		t.Fatal("Expected an error for a rule without minor number")
+	}
+}
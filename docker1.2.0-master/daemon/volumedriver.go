@@ -0,0 +1,17 @@
+package daemon
+
+// volumeDriver mounts the named volumes whose data isn't stored on the host.
+// The volume is mounted on its directory in the volumes graph while
+// containers using it are running.
+type volumeDriver interface {
+	// Validate checks the options of a volume when it is created.
+	Validate(opts map[string]string) error
+	Mount(opts map[string]string, dir string) error
+	Unmount(dir string) error
+}
+
+var volumeDrivers = make(map[string]volumeDriver)
+
+func registerVolumeDriver(name string, driver volumeDriver) {
+	volumeDrivers[name] = driver
+}
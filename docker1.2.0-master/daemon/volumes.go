@@ -11,6 +11,7 @@ import (
 	"github.com/docker/docker/archive"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/runconfig"
 )
 
 type Volume struct {
@@ -18,6 +19,7 @@ type Volume struct {
 	VolPath     string
 	Mode        string
 	isBindMount bool
+	name        string // set for named volumes, resolved to HostPath on initialize
 }
 
 func (v *Volume) isRw() bool {
@@ -42,6 +44,9 @@ func prepareVolumesForContainer(container *Container) error {
 		}
 	}
 
+	if err := container.daemon.mountDriverVolumes(container); err != nil {
+		return err
+	}
 	if err := createVolumes(container); err != nil {
 		return err
 	}
@@ -50,15 +55,15 @@ func prepareVolumesForContainer(container *Container) error {
 
 func setupMountsForContainer(container *Container) error {
 	mounts := []execdriver.Mount{
-		{container.ResolvConfPath, "/etc/resolv.conf", true, true},
+		{Source: container.ResolvConfPath, Destination: "/etc/resolv.conf", Writable: true, Private: true},
 	}
 
 	if container.HostnamePath != "" {
-		mounts = append(mounts, execdriver.Mount{container.HostnamePath, "/etc/hostname", true, true})
+		mounts = append(mounts, execdriver.Mount{Source: container.HostnamePath, Destination: "/etc/hostname", Writable: true, Private: true})
 	}
 
 	if container.HostsPath != "" {
-		mounts = append(mounts, execdriver.Mount{container.HostsPath, "/etc/hosts", true, true})
+		mounts = append(mounts, execdriver.Mount{Source: container.HostsPath, Destination: "/etc/hosts", Writable: true, Private: true})
 	}
 
 	// Mount user specified volumes
@@ -66,9 +71,16 @@ func setupMountsForContainer(container *Container) error {
 	// volumes. For instance if you use -v /usr:/usr and the host later mounts /usr/share you
 	// want this new mount in the container
 	for r, v := range container.Volumes {
-		mounts = append(mounts, execdriver.Mount{v, r, container.VolumesRW[r], false})
+		mounts = append(mounts, execdriver.Mount{
+			Source:      v,
+			Destination: r,
+			Writable:    container.VolumesRW[r],
+			Options:     container.hostConfig.MountOptions[r],
+		})
 	}
 
+	mounts = append(mounts, container.daemon.gpuMounts(container)...)
+
 	container.command.Mounts = mounts
 
 	return nil
@@ -78,31 +90,18 @@ func applyVolumesFrom(container *Container) error {
 	volumesFrom := container.hostConfig.VolumesFrom
 	if len(volumesFrom) > 0 {
 		for _, containerSpec := range volumesFrom {
-			var (
-				mountRW   = true
-				specParts = strings.SplitN(containerSpec, ":", 2)
-			)
-
-			switch len(specParts) {
-			case 0:
-				return fmt.Errorf("Malformed volumes-from specification: %s", containerSpec)
-			case 2:
-				switch specParts[1] {
-				case "ro":
-					mountRW = false
-				case "rw": // mountRW is already true
-				default:
-					return fmt.Errorf("Malformed volumes-from specification: %s", containerSpec)
-				}
+			name, mountRW, err := runconfig.ParseVolumesFrom(containerSpec)
+			if err != nil {
+				return err
 			}
 
-			c := container.daemon.Get(specParts[0])
+			c := container.daemon.Get(name)
 			if c == nil {
-				return fmt.Errorf("Container %s not found. Impossible to mount its volumes", specParts[0])
+				return fmt.Errorf("Container %s not found. Impossible to mount its volumes", name)
 			}
 
 			if err := c.Mount(); err != nil {
-				return fmt.Errorf("Container %s failed to mount. Impossible to mount its volumes", specParts[0])
+				return fmt.Errorf("Container %s failed to mount. Impossible to mount its volumes", name)
 			}
 			defer c.Unmount()
 
@@ -125,10 +124,11 @@ func applyVolumesFrom(container *Container) error {
 					return err
 				}
 
+				// A volume can only be made read-only: a read-only volume of
+				// the source container stays read-only with :rw
 				container.Volumes[volPath] = id
-				if isRW, exists := c.VolumesRW[volPath]; exists {
-					container.VolumesRW[volPath] = isRW && mountRW
-				}
+				isRW, exists := c.VolumesRW[volPath]
+				container.VolumesRW[volPath] = (isRW || !exists) && mountRW
 			}
 
 		}
@@ -159,6 +159,14 @@ func parseBindVolumeSpec(spec string) (Volume, error) {
 		return vol, fmt.Errorf("Invalid volume specification: %s", spec)
 	}
 
+	// -v name:/path mounts the named volume name
+	if len(arr) > 1 && IsValidVolumeName(vol.HostPath) {
+		vol.name = vol.HostPath
+		vol.HostPath = ""
+		vol.isBindMount = false
+		return vol, nil
+	}
+
 	if !filepath.IsAbs(vol.HostPath) {
 		return vol, fmt.Errorf("cannot bind mount volume: %s volume paths must be absolute.", vol.HostPath)
 	}
@@ -246,8 +254,21 @@ func (v *Volume) initialize(container *Container) error {
 		return nil
 	}
 
+	// The owner is only ever changed on the new volumes private to the
+	// container, never on a host path or a volume other containers use
+	options := container.hostConfig.MountOptions[v.VolPath]
+	uid, gid := runconfig.VolumeOwner(options)
+	if (uid != -1 || gid != -1) && (v.isBindMount || v.name != "") {
+		return fmt.Errorf("Invalid mount options for %s: uid and gid only apply to the volumes private to the container", v.VolPath)
+	}
+
 	// If it's not a bindmount we need to create the dir on the host
-	if !v.isBindMount {
+	if v.name != "" {
+		v.HostPath, err = container.daemon.namedVolumeHostPath(container, v.name)
+		if err != nil {
+			return err
+		}
+	} else if !v.isBindMount {
 		v.HostPath, err = createVolumeHostPath(container)
 		if err != nil {
 			return err
@@ -279,9 +300,15 @@ func (v *Volume) initialize(container *Container) error {
 		return err
 	}
 
-	// Do not copy or change permissions if we are mounting from the host
-	if v.isRw() && !v.isBindMount {
-		return copyExistingContents(fullVolPath, hostPath)
+	// Do not copy or change permissions if we are mounting from the host,
+	// or when the volume is mounted with nocopy
+	if v.isRw() && !v.isBindMount && runconfig.VolumeCopyEnabled(options) {
+		if err := copyExistingContents(fullVolPath, hostPath); err != nil {
+			return err
+		}
+	}
+	if uid != -1 || gid != -1 {
+		return os.Chown(hostPath, uid, gid)
 	}
 	return nil
 }
@@ -0,0 +1,58 @@
+// +build linux
+
+package daemon
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/docker/pkg/mount"
+)
+
+func init() {
+	registerVolumeDriver("nfs", nfsVolumeDriver{})
+}
+
+// nfsVolumeDriver mounts an NFS export, set with the server and export
+// options. The options option holds the mount flags, as in fstab.
+type nfsVolumeDriver struct{}
+
+func (nfsVolumeDriver) Validate(opts map[string]string) error {
+	for key := range opts {
+		switch key {
+		case "server", "export", "options":
+		default:
+			return fmt.Errorf("Unknown nfs volume option %s", key)
+		}
+	}
+	if opts["server"] == "" {
+		return fmt.Errorf("The nfs volume driver requires the server option")
+	}
+	if !filepath.IsAbs(opts["export"]) {
+		return fmt.Errorf("The nfs volume driver requires an absolute export path, got %q", opts["export"])
+	}
+	return nil
+}
+
+func (nfsVolumeDriver) Mount(opts map[string]string, dir string) error {
+	if mounted, err := mount.Mounted(dir); err != nil || mounted {
+		return err
+	}
+	if _, err := exec.LookPath("mount.nfs"); err != nil {
+		return fmt.Errorf("NFS volumes are not supported on this host, mount.nfs is missing: %s", err)
+	}
+	args := []string{"-t", "nfs"}
+	if o := opts["options"]; o != "" {
+		args = append(args, "-o", o)
+	}
+	args = append(args, opts["server"]+":"+opts["export"], dir)
+	if out, err := exec.Command("mount", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("Error mounting %s:%s: %s (%s)", opts["server"], opts["export"], err, out)
+	}
+	return nil
+}
+
+func (nfsVolumeDriver) Unmount(dir string) error {
+	return mount.Unmount(dir)
+}
@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNfsVolumeDriverValidate(t *testing.T) {
+	driver := nfsVolumeDriver{}
+	valid := map[string]string{"server": "10.0.0.1", "export": "/srv/data", "options": "vers=4,soft"}
+	if err := driver.Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []map[string]string{
+		{"export": "/srv/data"},
+		{"server": "10.0.0.1"},
+		{"server": "10.0.0.1", "export": "srv/data"},
+		{"server": "10.0.0.1", "export": "/srv/data", "bogus": "1"},
+	} {
+		if err := driver.Validate(opts); err == nil {
+			t.Fatalf("Expected an error validating %v", opts)
+		}
+	}
+}
+
+func TestCreateDriverVolume(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-volumestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := newTestVolumeStore(t, root)
+	opts := map[string]string{"server": "10.0.0.1", "export": "/srv/data"}
+	if _, err := store.Create("data", 0, "bogus", opts); err == nil {
+		t.Fatal("Expected an error for an unknown driver")
+	}
+	if _, err := store.Create("data", 1024, "nfs", opts); err == nil {
+		t.Fatal("Expected an error for a size limit on an nfs volume")
+	}
+	if _, err := store.Create("data", 0, "", opts); err == nil {
+		t.Fatal("Expected an error for options without a driver")
+	}
+	v, err := store.Create("data", 0, "nfs", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The driver and its options are persisted
+	store = newTestVolumeStore(t, root)
+	if v = store.Get("data"); v == nil || v.Driver != "nfs" || v.Options["export"] != "/srv/data" {
+		t.Fatalf("Unexpected volume after reload: %+v", v)
+	}
+	if err := store.Delete("data"); err != nil {
+		t.Fatal(err)
+	}
+}
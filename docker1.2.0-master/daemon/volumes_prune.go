@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/engine"
+)
+
+// volumePruneGracePeriod protects the volumes just created for a container
+// being started, which doesn't reference them yet.
+var volumePruneGracePeriod = time.Minute
+
+// volumeIDFromPath returns the ID in the volumes graph of the volume stored
+// at hostPath. The volume id is always the base of the path.
+func volumeIDFromPath(hostPath string) string {
+	return filepath.Base(strings.TrimSuffix(hostPath, "/layer"))
+}
+
+// referencedVolumes returns the IDs of the volumes used by at least one
+// container.
+func (daemon *Daemon) referencedVolumes() map[string]struct{} {
+	refs := make(map[string]struct{})
+	for _, container := range daemon.List() {
+		for _, p := range container.volumeHostPaths() {
+			refs[volumeIDFromPath(p)] = struct{}{}
+		}
+	}
+	return refs
+}
+
+// pruneVolumes removes the volumes no container references and returns
+// their IDs. Named volumes are meant to outlive their containers, they are
+// only removed when all is set.
+func (daemon *Daemon) pruneVolumes(all bool) ([]string, error) {
+	volumes, err := daemon.volumes.Map()
+	if err != nil {
+		return nil, err
+	}
+	named := make(map[string]*NamedVolume)
+	for _, v := range daemon.volumeStore.List() {
+		named[v.ID] = v
+	}
+	refs := daemon.referencedVolumes()
+
+	var deleted []string
+	for id, img := range volumes {
+		if _, exists := refs[id]; exists {
+			continue
+		}
+		if time.Since(img.Created) < volumePruneGracePeriod {
+			continue
+		}
+		if v, exists := named[id]; exists {
+			if !all {
+				continue
+			}
+			err = daemon.volumeStore.Delete(v.Name)
+		} else {
+			err = daemon.volumes.Delete(id)
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, id)
+	}
+	return deleted, nil
+}
+
+// reapVolumes periodically prunes the unreferenced anonymous volumes.
+func (daemon *Daemon) reapVolumes(interval time.Duration) {
+	for _ = range time.Tick(interval) {
+		deleted, err := daemon.pruneVolumes(false)
+		if err != nil {
+			daemonLog.Errorf("Error pruning volumes: %s", err)
+		}
+		if len(deleted) > 0 {
+			daemonLog.Infof("Pruned %d orphaned volume(s)", len(deleted))
+		}
+	}
+}
+
+// VolumesPrune removes the volumes no container references. Named volumes
+// are kept unless the "all" env is set.
+func (daemon *Daemon) VolumesPrune(job *engine.Job) engine.Status {
+	if len(job.Args) != 0 {
+		return job.Errorf("Usage: %s", job.Name)
+	}
+	deleted, err := daemon.pruneVolumes(job.GetenvBool("all"))
+	if err != nil {
+		return job.Errorf("Error pruning volumes (%d removed): %s", len(deleted), err)
+	}
+	if deleted == nil {
+		deleted = []string{}
+	}
+	out := &engine.Env{}
+	out.SetList("Deleted", deleted)
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
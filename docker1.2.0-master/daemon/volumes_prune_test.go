@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/graph"
+)
+
+func TestPruneVolumes(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-volumes-prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	driver, err := graphdriver.GetDriver("vfs", root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes, err := graph.NewGraph(filepath.Join(root, "volumes"), driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewVolumeStore(filepath.Join(root, "named-volumes"), volumes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := &Daemon{
+		containers:  &contStore{s: make(map[string]*Container)},
+		volumes:     volumes,
+		volumeStore: store,
+	}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		img, err := volumes.Create(nil, "", "", "", "", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, img.ID)
+	}
+	used, orphan := ids[0], ids[1]
+	usedPath, err := driver.Get(used, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.containers.Add("aaa", &Container{ID: "aaa", Volumes: map[string]string{"/data": usedPath}})
+	named, err := store.Create("data", 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The volumes are too recent to be pruned
+	if deleted, err := daemon.pruneVolumes(true); err != nil || len(deleted) != 0 {
+		t.Fatalf("Expected no volume to be pruned, got %v: %v", deleted, err)
+	}
+
+	defer func(d time.Duration) { volumePruneGracePeriod = d }(volumePruneGracePeriod)
+	volumePruneGracePeriod = 0
+
+	deleted, err := daemon.pruneVolumes(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != orphan {
+		t.Fatalf("Expected only %s to be pruned, got %v", orphan, deleted)
+	}
+	if volumes.Exists(orphan) || !volumes.Exists(used) || !volumes.Exists(named.ID) {
+		t.Fatal("Unexpected volumes left after pruning")
+	}
+
+	deleted, err = daemon.pruneVolumes(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != named.ID || store.Get("data") != nil {
+		t.Fatalf("Expected the named volume to be pruned, got %v", deleted)
+	}
+}
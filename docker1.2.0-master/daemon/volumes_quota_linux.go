@@ -0,0 +1,56 @@
+// +build linux
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/docker/docker/pkg/mount"
+)
+
+// createQuotaFs creates the ext4 image backing a volume limited to size
+// bytes. The image is sparse, so it only takes the space actually used.
+func createQuotaFs(img string, size int64) error {
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		return fmt.Errorf("Volume size limits are not supported on this host, mkfs.ext4 is missing: %s", err)
+	}
+	f, err := os.OpenFile(img, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(size)
+	f.Close()
+	if err != nil {
+		os.Remove(img)
+		return err
+	}
+	if out, err := exec.Command("mkfs.ext4", "-F", "-q", "-m", "0", img).CombinedOutput(); err != nil {
+		os.Remove(img)
+		return fmt.Errorf("Error creating the volume filesystem: %s (%s)", err, out)
+	}
+	return nil
+}
+
+// mountQuotaFs loop mounts img on dir unless it is already mounted.
+func mountQuotaFs(img, dir string) error {
+	if mounted, err := mount.Mounted(dir); err != nil || mounted {
+		return err
+	}
+	if out, err := exec.Command("mount", "-t", "ext4", "-o", "loop", img, dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("Error mounting the volume filesystem: %s (%s)", err, out)
+	}
+	return nil
+}
+
+// initQuotaFs empties a freshly created volume filesystem, so the volume
+// gets populated from the image like any other.
+func initQuotaFs(dir string) error {
+	return os.Remove(filepath.Join(dir, "lost+found"))
+}
+
+func unmountQuotaFs(dir string) error {
+	return mount.Unmount(dir)
+}
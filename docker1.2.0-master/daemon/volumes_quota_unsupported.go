@@ -0,0 +1,23 @@
+// +build !linux
+
+package daemon
+
+import "fmt"
+
+var errQuotaUnsupported = fmt.Errorf("Volume size limits are not supported on this platform")
+
+func createQuotaFs(img string, size int64) error {
+	return errQuotaUnsupported
+}
+
+func mountQuotaFs(img, dir string) error {
+	return errQuotaUnsupported
+}
+
+func initQuotaFs(dir string) error {
+	return errQuotaUnsupported
+}
+
+func unmountQuotaFs(dir string) error {
+	return nil
+}
@@ -0,0 +1,317 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/graph"
+)
+
+const validVolumeNameChars = `[a-zA-Z0-9][a-zA-Z0-9_.-]`
+
+var validVolumeNamePattern = regexp.MustCompile(`^` + validVolumeNameChars + `+$`)
+
+// NamedVolume is a volume created by name, which outlives the containers
+// using it. Its data is stored in the volumes graph like the anonymous
+// volumes, unless a driver mounts it from elsewhere.
+type NamedVolume struct {
+	Name    string
+	ID      string // ID of the volume in the volumes graph
+	Created time.Time
+	Size    int64             // Size limit in bytes, 0 for unlimited
+	Driver  string            // Volume driver, empty for local volumes
+	Options map[string]string // Options of the volume driver
+}
+
+// VolumeStore keeps track of the named volumes, persisted as a json file
+// next to the volumes graph.
+type VolumeStore struct {
+	path    string
+	graph   *graph.Graph
+	Volumes map[string]*NamedVolume
+	mounts  map[string]int // Number of users of the mounted driver volumes
+	sync.Mutex
+}
+
+func NewVolumeStore(path string, graph *graph.Graph) (*VolumeStore, error) {
+	abspath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	store := &VolumeStore{
+		path:    abspath,
+		graph:   graph,
+		Volumes: make(map[string]*NamedVolume),
+		mounts:  make(map[string]int),
+	}
+	// Load the json file if it exists, otherwise create it.
+	if err := store.reload(); os.IsNotExist(err) {
+		if err := store.save(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *VolumeStore) save() error {
+	jsonData, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(store.path, jsonData, 0600)
+}
+
+func (store *VolumeStore) reload() error {
+	jsonData, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, store)
+}
+
+// IsValidVolumeName tells whether name can name a volume. Names can't start
+// with "/" or ".", so they are never mistaken for a host path.
+func IsValidVolumeName(name string) bool {
+	return validVolumeNamePattern.MatchString(name)
+}
+
+// Create creates the volume name in the volumes graph. When size is not 0,
+// the volume is backed by a filesystem of size bytes so it can't grow
+// beyond it. When driver is set, the volume is mounted by this driver,
+// configured with opts.
+func (store *VolumeStore) Create(name string, size int64, driver string, opts map[string]string) (*NamedVolume, error) {
+	if !IsValidVolumeName(name) {
+		return nil, fmt.Errorf("Invalid volume name (%s), only %s are allowed", name, validVolumeNameChars)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("Invalid volume size: %d", size)
+	}
+	if driver == "" {
+		if len(opts) > 0 {
+			return nil, fmt.Errorf("Volume options require a volume driver")
+		}
+	} else {
+		d, exists := volumeDrivers[driver]
+		if !exists {
+			return nil, fmt.Errorf("No such volume driver: %s", driver)
+		}
+		if size > 0 {
+			return nil, fmt.Errorf("Volume size limits are not supported by the %s volume driver", driver)
+		}
+		if err := d.Validate(opts); err != nil {
+			return nil, err
+		}
+	}
+	store.Lock()
+	defer store.Unlock()
+	if _, exists := store.Volumes[name]; exists {
+		return nil, fmt.Errorf("Volume %s already exists", name)
+	}
+	img, err := store.graph.Create(nil, "", "", "", "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	v := &NamedVolume{
+		Name:    name,
+		ID:      img.ID,
+		Created: img.Created,
+		Size:    size,
+		Driver:  driver,
+		Options: opts,
+	}
+	if size > 0 {
+		if err := store.createQuota(v); err != nil {
+			store.graph.Delete(img.ID)
+			return nil, err
+		}
+	}
+	store.Volumes[name] = v
+	if err := store.save(); err != nil {
+		delete(store.Volumes, name)
+		store.graph.Delete(img.ID)
+		return nil, err
+	}
+	return v, nil
+}
+
+// Get returns the volume name, or nil if it doesn't exist.
+func (store *VolumeStore) Get(name string) *NamedVolume {
+	store.Lock()
+	defer store.Unlock()
+	return store.Volumes[name]
+}
+
+// List returns all the named volumes, sorted by name.
+func (store *VolumeStore) List() []*NamedVolume {
+	store.Lock()
+	defer store.Unlock()
+	var names []string
+	for name := range store.Volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	volumes := make([]*NamedVolume, 0, len(names))
+	for _, name := range names {
+		volumes = append(volumes, store.Volumes[name])
+	}
+	return volumes
+}
+
+// Delete removes the volume name and its data.
+func (store *VolumeStore) Delete(name string) error {
+	store.Lock()
+	defer store.Unlock()
+	v, exists := store.Volumes[name]
+	if !exists {
+		return fmt.Errorf("No such volume: %s", name)
+	}
+	if store.mounts[name] > 0 {
+		return fmt.Errorf("Volume %s is mounted", name)
+	}
+	if v.Driver != "" {
+		// Never remove the directory while the remote data is mounted on it
+		if err := store.unmountDriver(v); err != nil {
+			return err
+		}
+	}
+	if v.Size > 0 {
+		dir, err := store.graph.Driver().Get(v.ID, "")
+		if err != nil {
+			return err
+		}
+		if err := unmountQuotaFs(dir); err != nil {
+			return err
+		}
+	}
+	delete(store.Volumes, name)
+	if err := store.save(); err != nil {
+		store.Volumes[name] = v
+		return err
+	}
+	return store.graph.Delete(v.ID)
+}
+
+// Path returns the host path holding the data of v, mounting its filesystem
+// first for volumes with a size limit.
+func (store *VolumeStore) Path(v *NamedVolume) (string, error) {
+	driver := store.graph.Driver()
+	p, err := driver.Get(v.ID, "")
+	if err != nil {
+		return "", fmt.Errorf("Driver %s failed to get volume rootfs %s: %s", driver, v.ID, err)
+	}
+	if v.Size > 0 {
+		if err := mountQuotaFs(store.quotaImage(v), p); err != nil {
+			return "", err
+		}
+	}
+	return filepath.EvalSymlinks(p)
+}
+
+// Mount mounts the driver volume v for a new user. It is only mounted for
+// the first one.
+func (store *VolumeStore) Mount(v *NamedVolume) error {
+	store.Lock()
+	defer store.Unlock()
+	if store.mounts[v.Name] == 0 {
+		d, exists := volumeDrivers[v.Driver]
+		if !exists {
+			return fmt.Errorf("No such volume driver: %s", v.Driver)
+		}
+		dir, err := store.graph.Driver().Get(v.ID, "")
+		if err != nil {
+			return err
+		}
+		if err := d.Mount(v.Options, dir); err != nil {
+			return err
+		}
+	}
+	store.mounts[v.Name]++
+	return nil
+}
+
+// Unmount releases the driver volume v mounted by Mount. It is unmounted
+// once its last user is gone.
+func (store *VolumeStore) Unmount(v *NamedVolume) error {
+	store.Lock()
+	defer store.Unlock()
+	if store.mounts[v.Name] == 0 {
+		return nil
+	}
+	if store.mounts[v.Name] == 1 {
+		if err := store.unmountDriver(v); err != nil {
+			return err
+		}
+	}
+	store.mounts[v.Name]--
+	if store.mounts[v.Name] == 0 {
+		delete(store.mounts, v.Name)
+	}
+	return nil
+}
+
+func (store *VolumeStore) unmountDriver(v *NamedVolume) error {
+	d, exists := volumeDrivers[v.Driver]
+	if !exists {
+		return fmt.Errorf("No such volume driver: %s", v.Driver)
+	}
+	dir, err := store.graph.Driver().Get(v.ID, "")
+	if err != nil {
+		return err
+	}
+	return d.Unmount(dir)
+}
+
+// UnmountQuotas unmounts the filesystems of the volumes with a size limit,
+// when the daemon shuts down. They are mounted again by Path when used.
+func (store *VolumeStore) UnmountQuotas() error {
+	store.Lock()
+	defer store.Unlock()
+	var firstErr error
+	for _, v := range store.Volumes {
+		if v.Size == 0 {
+			continue
+		}
+		dir, err := store.graph.Driver().Get(v.ID, "")
+		if err == nil {
+			err = unmountQuotaFs(dir)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("Error unmounting volume %s: %s", v.Name, err)
+		}
+	}
+	return firstErr
+}
+
+// quotaImage returns the path of the filesystem image of v. It is kept with
+// the volume metadata, so it goes away with the volume.
+func (store *VolumeStore) quotaImage(v *NamedVolume) string {
+	return filepath.Join(store.graph.ImageRoot(v.ID), "quota.img")
+}
+
+func (store *VolumeStore) createQuota(v *NamedVolume) error {
+	img := store.quotaImage(v)
+	if err := createQuotaFs(img, v.Size); err != nil {
+		return err
+	}
+	dir, err := store.graph.Driver().Get(v.ID, "")
+	if err != nil {
+		return err
+	}
+	if err := mountQuotaFs(img, dir); err != nil {
+		return err
+	}
+	if err := initQuotaFs(dir); err != nil {
+		unmountQuotaFs(dir)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/graph"
+	"github.com/docker/docker/pkg/mount"
+)
+
+func newTestVolumeStore(t *testing.T, root string) *VolumeStore {
+	driver, err := graphdriver.GetDriver("vfs", root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := graph.NewGraph(filepath.Join(root, "volumes"), driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewVolumeStore(filepath.Join(root, "named-volumes"), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestVolumeStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-volumestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := newTestVolumeStore(t, root)
+	for _, invalid := range []string{"", "/data", ".data", "da/ta"} {
+		if _, err := store.Create(invalid, 0, "", nil); err == nil {
+			t.Fatalf("Expected an error creating volume %q", invalid)
+		}
+	}
+	if _, err := store.Create("data", -1, "", nil); err == nil {
+		t.Fatal("Expected an error for a negative size")
+	}
+	v, err := store.Create("data", 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create("data", 0, "", nil); err == nil {
+		t.Fatal("Expected an error creating an existing volume")
+	}
+	p, err := store.Path(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+		t.Fatalf("Expected the volume directory %s to exist: %v", p, err)
+	}
+
+	// The volumes are persisted
+	store = newTestVolumeStore(t, root)
+	if volumes := store.List(); len(volumes) != 1 || volumes[0].Name != "data" || volumes[0].ID != v.ID {
+		t.Fatalf("Unexpected volumes after reload: %v", volumes)
+	}
+
+	if err := store.Delete("data"); err != nil {
+		t.Fatal(err)
+	}
+	if store.Get("data") != nil {
+		t.Fatal("Expected the volume to be removed")
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Fatalf("Expected the volume directory to be removed, got %v", err)
+	}
+	if err := store.Delete("data"); err == nil {
+		t.Fatal("Expected an error removing a missing volume")
+	}
+}
+
+func TestVolumeStoreSize(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Loop mounting the volume filesystem requires root")
+	}
+	if _, err := exec.LookPath("mkfs.ext4"); err != nil {
+		t.Skip("mkfs.ext4 is required to create the volume filesystem")
+	}
+	root, err := ioutil.TempDir("", "docker-volumestore-size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := newTestVolumeStore(t, root)
+	v, err := store.Create("data", 16*1024*1024, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := store.Path(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mounted, err := mount.Mounted(p); err != nil || !mounted {
+		t.Fatalf("Expected the volume filesystem to be mounted on %s: %v", p, err)
+	}
+	if entries, err := ioutil.ReadDir(p); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected the volume to be empty, got %v (%v)", entries, err)
+	}
+	// The filesystem is as big as the volume
+	if err := ioutil.WriteFile(filepath.Join(p, "big"), make([]byte, 32*1024*1024), 0600); err == nil {
+		t.Fatal("Expected an error writing beyond the size of the volume")
+	}
+	os.Remove(filepath.Join(p, "big"))
+
+	// Shutting down unmounts it, the next use mounts it again
+	if err := store.UnmountQuotas(); err != nil {
+		t.Fatal(err)
+	}
+	if mounted, err := mount.Mounted(p); err != nil || mounted {
+		t.Fatalf("Expected the volume filesystem to be unmounted from %s: %v", p, err)
+	}
+	if p, err = store.Path(v); err != nil {
+		t.Fatal(err)
+	}
+	if mounted, err := mount.Mounted(p); err != nil || !mounted {
+		t.Fatalf("Expected the volume filesystem to be mounted again on %s: %v", p, err)
+	}
+
+	if err := store.Delete("data"); err != nil {
+		t.Fatal(err)
+	}
+	if mounted, err := mount.Mounted(p); err != nil || mounted {
+		t.Fatalf("Expected the volume filesystem to be unmounted once removed: %v", err)
+	}
+}
+
+func TestParseNamedVolumeSpec(t *testing.T) {
+	vol, err := parseBindVolumeSpec("data:/var/lib/data:ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vol.name != "data" || vol.isBindMount || vol.VolPath != "/var/lib/data" || vol.isRw() {
+		t.Fatalf("Unexpected volume %+v", vol)
+	}
+	if _, err := parseBindVolumeSpec("da/ta:/var/lib/data"); err == nil {
+		t.Fatal("Expected an error for a relative host path")
+	}
+}
@@ -1,8 +1,6 @@
 package daemon
 
 import (
-	"time"
-
 	"github.com/docker/docker/engine"
 )
 
@@ -12,7 +10,10 @@ func (daemon *Daemon) ContainerWait(job *engine.Job) engine.Status {
 	}
 	name := job.Args[0]
 	if container := daemon.Get(name); container != nil {
-		status, _ := container.State.WaitStop(-1 * time.Second)
+		status, err := container.State.WaitStopOrCancel(job.Canceled())
+		if err != nil {
+			return job.Error(err)
+		}
 		job.Printf("%d\n", status)
 		return engine.StatusOK
 	}
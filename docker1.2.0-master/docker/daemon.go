@@ -3,7 +3,12 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/docker/docker/builtins"
 	"github.com/docker/docker/daemon"
@@ -13,6 +18,7 @@ import (
 	"github.com/docker/docker/engine"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/signal"
+	"github.com/docker/docker/registry"
 )
 
 const CanDaemon = true
@@ -37,13 +43,21 @@ func mainDaemon() {
 
 	//创建 engine 对象
 	eng := engine.New()
+	eng.Tracing = *flTraceJobs
+	// A panicking job fails instead of bringing the daemon down
+	eng.Use(engine.Recover)
 
 	//设置 engine 的信号捕获
-	signal.Trap(eng.Shutdown)
+	signal.Trap(eng.Shutdown, func() { dumpDiagnostics(eng) })
 	// Load builtins(Docker Daemon 运行过程中，注册的一些任务(Job) ，这部分任务一般与容器的运行无关，与 Docker Daemon 的运行时信 息有关)
 	if err := builtins.Register(eng); err != nil {
 		log.Fatal(err)
 	}
+	// load the registry service, restricted to the insecure registries
+	// allowed by the daemon
+	if err := registry.NewService(daemonCfg.InsecureRegistries).Install(eng); err != nil {
+		log.Fatal(err)
+	}
 
 	// load the daemon in the background so we can immediately start
 	// the http api so that connections don't fail while the daemon
@@ -82,15 +96,69 @@ func mainDaemon() {
 	job.SetenvBool("Logging", true)
 	job.SetenvBool("EnableCors", *flEnableCors)
 	job.Setenv("Version", dockerversion.VERSION)
+	job.Setenv("SocketOwner", *flSocketOwner)
 	job.Setenv("SocketGroup", *flSocketGroup)
+	job.Setenv("SocketMode", *flSocketMode)
+	job.SetenvBool("LogPeers", *flLogPeers)
+	job.SetenvBool("ProxyProtocol", *flProxyProto)
+	job.SetenvList("ProxyTrusted", flProxyTrusted)
 
 	job.SetenvBool("Tls", *flTls)
 	job.SetenvBool("TlsVerify", *flTlsVerify)
 	job.Setenv("TlsCa", *flCa)
 	job.Setenv("TlsCert", *flCert)
 	job.Setenv("TlsKey", *flKey)
+	job.SetenvList("TlsRoles", flTlsRoles)
+	job.SetenvList("AuthzPlugins", flAuthzPlugins)
+	job.SetenvInt("ApiMaxRequests", *flMaxRequests)
+	job.SetenvInt("ApiMaxConnections", *flMaxConns)
+	job.SetenvInt("ApiRateLimit", *flRateLimit)
+	job.Setenv("AccessLog", *flAccessLog)
+	job.Setenv("ApiReadTimeout", flReadTimeout.String())
+	job.Setenv("ApiWriteTimeout", flWriteTimeout.String())
+	job.Setenv("ApiIdleTimeout", flIdleTimeout.String())
+	job.SetenvInt("BufferMaxConns", *flBufferMax)
+	job.Setenv("BufferTimeout", flBufferTime.String())
+	job.Setenv("BufferHandshakeTimeout", flBufferIdle.String())
+	if *flListeners != "" {
+		listeners, err := ioutil.ReadFile(*flListeners)
+		if err != nil {
+			log.Fatal(err)
+		}
+		job.Setenv("Listeners", string(listeners))
+	}
 	job.SetenvBool("BufferRequests", true)
 	if err := job.Run(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// dumpDiagnostics writes the goroutine stacks and the state of the engine to
+// a file under the docker root, on SIGUSR1, or to the log if it can't.
+func dumpDiagnostics(eng *engine.Engine) {
+	out := os.Stderr
+	f, err := createDiagnosticsFile()
+	if err != nil {
+		log.Printf("Error creating the diagnostics file, dumping them to the log: %s", err)
+	} else {
+		defer f.Close()
+		out = f
+	}
+	// the stacks first, the state of the engine needing its lock
+	fmt.Fprintf(out, "=== goroutines\n")
+	signal.DumpStacks(out)
+	fmt.Fprintf(out, "=== engine\n")
+	eng.WriteDiagnostics(out)
+	if out != os.Stderr {
+		log.Printf("Diagnostics dumped to %s", out.Name())
+	}
+}
+
+func createDiagnosticsFile() (*os.File, error) {
+	dir := filepath.Join(daemonCfg.Root, "diagnostics")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("dump-%s.log", time.Now().UTC().Format("20060102T150405Z"))
+	return os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+}
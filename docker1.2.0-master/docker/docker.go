@@ -34,6 +34,14 @@ func main() {
 	flag.Parse()
 	// FIXME: validate daemon flags here
 
+	// 守护进程模式下，命令行未设置的参数从 DOCKER_ 开头的环境变量读取，
+	// DOCKER_HOST 仍只供 client 使用
+	if *flDaemon {
+		if err := flag.ParseEnv("DOCKER_", "-version", "-daemon", "-host"); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// flVersion为真，输出docker版本信息
 	if *flVersion {
 		showVersion()
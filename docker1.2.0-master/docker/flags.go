@@ -19,24 +19,46 @@ func init() {
 }
 
 var (
-	flVersion     = flag.Bool([]string{"v", "-version"}, false, "Print version information and quit")
-	flDaemon      = flag.Bool([]string{"d", "-daemon"}, false, "Enable daemon mode")
-	flDebug       = flag.Bool([]string{"D", "-debug"}, false, "Enable debug mode")
-	flSocketGroup = flag.String([]string{"G", "-group"}, "docker", "Group to assign the unix socket specified by -H when running in daemon mode\nuse '' (the empty string) to disable setting of a group")
-	flEnableCors  = flag.Bool([]string{"#api-enable-cors", "-api-enable-cors"}, false, "Enable CORS headers in the remote API")
-	flTls         = flag.Bool([]string{"-tls"}, false, "Use TLS; implied by tls-verify flags")
-	flTlsVerify   = flag.Bool([]string{"-tlsverify"}, false, "Use TLS and verify the remote (daemon: verify client, client: verify daemon)")
+	flVersion      = flag.Bool([]string{"v", "-version"}, false, "Print version information and quit")
+	flDaemon       = flag.Bool([]string{"d", "-daemon"}, false, "Enable daemon mode")
+	flDebug        = flag.Bool([]string{"D", "-debug"}, false, "Enable debug mode")
+	flTraceJobs    = flag.Bool([]string{"-trace-jobs"}, false, "Log the request id, duration and status of each job run by the daemon")
+	flSocketGroup  = flag.String([]string{"G", "-group"}, "docker", "Group to assign the unix socket specified by -H when running in daemon mode\nuse '' (the empty string) to disable setting of a group")
+	flSocketOwner  = flag.String([]string{"-socket-owner"}, "", "User (name or uid) to assign the unix socket specified by -H when running in daemon mode")
+	flSocketMode   = flag.String([]string{"-socket-mode"}, "0660", "Permissions (in octal) of the unix socket specified by -H when running in daemon mode")
+	flLogPeers     = flag.Bool([]string{"-api-log-peers"}, false, "Log the process, user and group of each client of the unix sockets")
+	flProxyProto   = flag.Bool([]string{"-api-proxy-protocol"}, false, "Read the address of each client of the tcp sockets from the PROXY protocol header of its load balancer")
+	flEnableCors   = flag.Bool([]string{"#api-enable-cors", "-api-enable-cors"}, false, "Enable CORS headers in the remote API")
+	flListeners    = flag.String([]string{"-api-listeners"}, "", "Path to a json list of the sockets to serve the remote API on, with their own options")
+	flMaxRequests  = flag.Int([]string{"-api-max-requests"}, 0, "Maximum number of API requests in flight per client, 0 for no limit")
+	flMaxConns     = flag.Int([]string{"-api-max-connections"}, 0, "Maximum number of API connections open per client, 0 for no limit")
+	flRateLimit    = flag.Int([]string{"-api-rate-limit"}, 0, "Maximum number of API requests per second per client, 0 for no limit")
+	flAccessLog    = flag.String([]string{"-api-access-log"}, "", "Path to a file logging the API requests in the combined log format")
+	flReadTimeout  = flag.Duration([]string{"-api-read-timeout"}, 0, "Maximum duration to read an API request, body included, 0 for no limit")
+	flWriteTimeout = flag.Duration([]string{"-api-write-timeout"}, 0, "Maximum duration of each write of an API response, 0 for no limit")
+	flIdleTimeout  = flag.Duration([]string{"-api-idle-timeout"}, 0, "Maximum duration an API connection waits for its next request, 0 for no limit")
+	flBufferMax    = flag.Int([]string{"-api-buffer-max"}, 0, "Maximum number of API connections held while the daemon starts, 0 for no limit")
+	flBufferTime   = flag.Duration([]string{"-api-buffer-timeout"}, 0, "Maximum duration API connections are held while the daemon starts, 0 for no limit")
+	flBufferIdle   = flag.Duration([]string{"-api-buffer-handshake-timeout"}, 0, "Maximum duration an API connection held while the daemon starts may go without sending its request, 0 for no limit")
+	flTls          = flag.Bool([]string{"-tls"}, false, "Use TLS; implied by tls-verify flags")
+	flTlsVerify    = flag.Bool([]string{"-tlsverify"}, false, "Use TLS and verify the remote (daemon: verify client, client: verify daemon)")
 
 	// these are initialized in init() below since their default values depend on dockerCertPath which isn't fully initialized until init() runs
-	flCa    *string
-	flCert  *string
-	flKey   *string
-	flHosts []string
+	flCa           *string
+	flCert         *string
+	flKey          *string
+	flHosts        []string
+	flTlsRoles     []string
+	flAuthzPlugins []string
+	flProxyTrusted []string
 )
 
 func init() {
 	flCa = flag.String([]string{"-tlscacert"}, filepath.Join(dockerCertPath, defaultCaFile), "Trust only remotes providing a certificate signed by the CA given here")
 	flCert = flag.String([]string{"-tlscert"}, filepath.Join(dockerCertPath, defaultCertFile), "Path to TLS certificate file")
 	flKey = flag.String([]string{"-tlskey"}, filepath.Join(dockerCertPath, defaultKeyFile), "Path to TLS key file")
+	opts.ListVar(&flTlsRoles, []string{"-tlsrole"}, "Give a role (readonly, operator or admin) to the verified client certificates\nwhose subject matches, as ROLE:ATTR=VALUE[,ATTR=VALUE...] with ATTR CN, O, OU, C, L or ST")
+	opts.ListVar(&flProxyTrusted, []string{"-api-proxy-trusted"}, "Address or CIDR of a load balancer allowed to send the PROXY protocol header, required by --api-proxy-protocol")
+	opts.ListVar(&flAuthzPlugins, []string{"-authz-plugin"}, "Ask this authorization plugin (unix:///path/to/socket or tcp://host:port)\nto allow each api request and response")
 	opts.HostListVar(&flHosts, []string{"H", "-host"}, "The socket(s) to bind to in daemon mode\nspecified using one or more tcp://host:port, unix:///path/to/socket, fd://* or fd://socketfd.")
 }
@@ -47,19 +47,30 @@ func unregister(name string) {
 type Engine struct {
 	handlers   map[string]Handler
 	catchall   Handler
-	hack       Hack // data for temporary hackery (see hack.go)
+	services   map[string]interface{} // see services.go
+	chain      []Middleware           // see middleware.go
+	plugins    map[string]string      // see plugins.go
 	id         string
 	Stdout     io.Writer
 	Stderr     io.Writer
 	Stdin      io.Reader
 	Logging    bool
+	Tracing    bool // log the span of each job
 	tasks      sync.WaitGroup
+	pending    int          // jobs running, counted with tasks
 	l          sync.RWMutex // lock for shutdown
 	shutdown   bool
 	onShutdown []func() // shutdown handlers
+	root       *Engine  // the engine a request engine is derived from
+	requestID  string
+	requests   map[string][]*Engine // request engines in flight, by id
+	canceled   chan struct{}        // closed when the request is canceled
 }
 
 func (eng *Engine) Register(name string, handler Handler) error {
+	eng = eng.main()
+	eng.l.Lock()
+	defer eng.l.Unlock()
 	_, exists := eng.handlers[name]
 	if exists {
 		return fmt.Errorf("Can't overwrite handler for command %s", name)
@@ -69,7 +80,153 @@ func (eng *Engine) Register(name string, handler Handler) error {
 }
 
 func (eng *Engine) RegisterCatchall(catchall Handler) {
-	eng.catchall = catchall
+	eng.main().catchall = catchall
+}
+
+// main returns the engine eng is derived from, or eng itself.
+func (eng *Engine) main() *Engine {
+	if eng.root != nil {
+		return eng.root
+	}
+	return eng
+}
+
+// WithRequestID returns an engine running the jobs of eng, whose jobs and
+// the jobs they start log the id of the api request they serve. They can
+// be canceled with CancelRequest until the engine is released.
+func (eng *Engine) WithRequestID(id string) *Engine {
+	root := eng.main()
+	reqEng := &Engine{
+		handlers:  root.handlers,
+		id:        root.id,
+		Stdout:    root.Stdout,
+		Stderr:    root.Stderr,
+		Stdin:     root.Stdin,
+		Logging:   root.Logging,
+		Tracing:   root.Tracing,
+		root:      root,
+		requestID: id,
+		canceled:  make(chan struct{}),
+	}
+	root.l.Lock()
+	if root.requests == nil {
+		root.requests = make(map[string][]*Engine)
+	}
+	root.requests[id] = append(root.requests[id], reqEng)
+	root.l.Unlock()
+	return reqEng
+}
+
+// Release forgets the request engine once its request is served, the jobs
+// it started in the background can't be canceled anymore.
+func (eng *Engine) Release() {
+	root := eng.main()
+	root.l.Lock()
+	defer root.l.Unlock()
+	engines := root.requests[eng.requestID]
+	for i, reqEng := range engines {
+		if reqEng == eng {
+			engines = append(engines[:i], engines[i+1:]...)
+			break
+		}
+	}
+	if len(engines) == 0 {
+		delete(root.requests, eng.requestID)
+	} else {
+		root.requests[eng.requestID] = engines
+	}
+}
+
+// CancelRequest cancels the jobs of the api request id in flight.
+func (eng *Engine) CancelRequest(id string) error {
+	root := eng.main()
+	root.l.Lock()
+	defer root.l.Unlock()
+	engines, exists := root.requests[id]
+	if !exists {
+		return fmt.Errorf("No such request: %s", id)
+	}
+	for _, reqEng := range engines {
+		reqEng.cancel()
+	}
+	return nil
+}
+
+// Cancel cancels the jobs of the request engine.
+func (eng *Engine) Cancel() {
+	root := eng.main()
+	root.l.Lock()
+	eng.cancel()
+	root.l.Unlock()
+}
+
+func (eng *Engine) cancel() {
+	if eng.canceled == nil {
+		return
+	}
+	select {
+	case <-eng.canceled:
+	default:
+		close(eng.canceled)
+	}
+}
+
+// Canceled returns a channel closed when the request of the engine is
+// canceled, which is never for the engines not serving a request.
+func (eng *Engine) Canceled() <-chan struct{} {
+	return eng.canceled
+}
+
+// WithCancel returns an engine running the jobs of eng, canceled with eng
+// or once the returned function is called. The function must be called
+// when the jobs are done, to release the engine.
+func (eng *Engine) WithCancel() (*Engine, func()) {
+	return eng.withCancel(nil)
+}
+
+// WithDeadline is like WithCancel, the engine being also canceled at the
+// deadline.
+func (eng *Engine) WithDeadline(deadline time.Time) (*Engine, func()) {
+	timer := time.NewTimer(deadline.Sub(time.Now()))
+	child, cancel := eng.withCancel(timer.C)
+	return child, func() {
+		timer.Stop()
+		cancel()
+	}
+}
+
+func (eng *Engine) withCancel(expired <-chan time.Time) (*Engine, func()) {
+	root := eng.main()
+	child := &Engine{
+		handlers:  root.handlers,
+		id:        root.id,
+		Stdout:    root.Stdout,
+		Stderr:    root.Stderr,
+		Stdin:     root.Stdin,
+		Logging:   root.Logging,
+		Tracing:   root.Tracing,
+		root:      root,
+		requestID: eng.requestID,
+		canceled:  make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-eng.canceled:
+		case <-expired:
+		case <-done:
+		}
+		child.Cancel()
+	}()
+	var once sync.Once
+	return child, func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// RequestID returns the id of the api request the engine serves, if any.
+func (eng *Engine) RequestID() string {
+	return eng.requestID
 }
 
 // New initializes a new engine.
@@ -82,6 +239,7 @@ func New() *Engine {
 		Stdin:    os.Stdin,
 		Logging:  true,
 	}
+	eng.Use(countJobs)
 	//向 eng 对象注册名为 commands Handler ，其中 Handler 为临时定义的函数如nc(j ob
 	//*Job) Status{ }，该函数的作用是通过 Job 来打印所有已经注册完毕的 command 名称，最终
 	//返回状态 StatusOK
@@ -100,12 +258,18 @@ func New() *Engine {
 }
 
 func (eng *Engine) String() string {
+	if eng.requestID != "" {
+		return fmt.Sprintf("%s req=%s", eng.id[:8], eng.requestID)
+	}
 	return fmt.Sprintf("%s", eng.id[:8])
 }
 
 // Commands returns a list of all currently registered commands,
 // sorted alphabetically.
 func (eng *Engine) commands() []string {
+	eng = eng.main()
+	eng.l.RLock()
+	defer eng.l.RUnlock()
 	names := make([]string, 0, len(eng.handlers))
 	for name := range eng.handlers {
 		names = append(names, name)
@@ -114,6 +278,23 @@ func (eng *Engine) commands() []string {
 	return names
 }
 
+// Exists returns true if a handler is registered for the command.
+func (eng *Engine) Exists(name string) bool {
+	eng = eng.main()
+	eng.l.RLock()
+	defer eng.l.RUnlock()
+	_, exists := eng.handlers[name]
+	return exists
+}
+
+// PendingJobs returns the number of jobs running, the api server excepted.
+func (eng *Engine) PendingJobs() int {
+	eng = eng.main()
+	eng.l.RLock()
+	defer eng.l.RUnlock()
+	return eng.pending
+}
+
 // Job creates a new job which can later be executed.
 // This function mimics `Command` from the standard os/exec package.
 func (eng *Engine) Job(name string, args ...string) *Job {
@@ -130,12 +311,19 @@ func (eng *Engine) Job(name string, args ...string) *Job {
 		job.Stderr.Add(utils.NopWriteCloser(eng.Stderr))
 	}
 
+	// The plugins register their handlers at runtime
+	root := eng.main()
+	root.l.RLock()
+	handler, exists := root.handlers[name]
+	catchall := root.catchall
+	root.l.RUnlock()
+
 	// Catchall is shadowed by specific Register.
-	if handler, exists := eng.handlers[name]; exists {
-		job.handler = handler
-	} else if eng.catchall != nil && name != "" {
+	if exists {
+		job.handler = eng.wrap(name, handler)
+	} else if catchall != nil && name != "" {
 		// empty job names are illegal, catchall or not.
-		job.handler = eng.catchall
+		job.handler = eng.wrap(name, catchall)
 	}
 	return job
 }
@@ -143,6 +331,7 @@ func (eng *Engine) Job(name string, args ...string) *Job {
 // OnShutdown registers a new callback to be called by Shutdown.
 // This is typically used by services to perform cleanup.
 func (eng *Engine) OnShutdown(h func()) {
+	eng = eng.main()
 	eng.l.Lock()
 	eng.onShutdown = append(eng.onShutdown, h)
 	eng.l.Unlock()
@@ -159,6 +348,7 @@ func (eng *Engine) OnShutdown(h func()) {
 //Docker Daemon 调用所有 shutdown 的处理方法。
 //15 秒时间内，若所有的 handler 执行完毕，则 ShutdownO 函数返回，否则强制 返回。
 func (eng *Engine) Shutdown() {
+	eng = eng.main()
 	eng.l.Lock()
 	if eng.shutdown {
 		eng.l.Unlock()
@@ -210,6 +400,7 @@ func (eng *Engine) Shutdown() {
 // of shutting down, or already shut down.
 // Otherwise it returns false.
 func (eng *Engine) IsShutdown() bool {
+	eng = eng.main()
 	eng.l.RLock()
 	defer eng.l.RUnlock()
 	return eng.shutdown
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRegister(t *testing.T) {
@@ -149,3 +150,130 @@ func TestCatchallEmptyName(t *testing.T) {
 		t.Fatalf("Engine.Job(\"\").Run() should return an error")
 	}
 }
+
+func TestEngineWithRequestID(t *testing.T) {
+	eng := New()
+	var logs bytes.Buffer
+	eng.Stderr = &logs
+	eng.Register("dummy", func(job *Job) Status {
+		// The jobs started by the job serve the same request
+		if id := job.Eng.RequestID(); id != "abc123" {
+			t.Errorf("Expected the request id abc123, got %q", id)
+		}
+		return StatusOK
+	})
+	reqEng := eng.WithRequestID("abc123")
+	if err := reqEng.Job("dummy").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "req=abc123] +job dummy()") {
+		t.Fatalf("Expected the jobs to log the request id, got %q", logs.String())
+	}
+	if eng.RequestID() != "" {
+		t.Fatal("Expected the engine to serve no request")
+	}
+
+	reqEng.Shutdown()
+	if !eng.IsShutdown() {
+		t.Fatal("Expected shutting down a request engine to shut down the engine")
+	}
+}
+
+func TestEnginePendingJobs(t *testing.T) {
+	eng := New()
+	eng.Register("pending", func(job *Job) Status {
+		if n := job.Eng.PendingJobs(); n != 1 {
+			t.Errorf("Expected 1 pending job, got %d", n)
+		}
+		return StatusOK
+	})
+	if !eng.Exists("pending") {
+		t.Fatal("Expected the pending command to exist")
+	}
+	if eng.Exists("missing") {
+		t.Fatal("Expected the missing command not to exist")
+	}
+	if err := eng.WithRequestID("abc123").Job("pending").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := eng.PendingJobs(); n != 0 {
+		t.Fatalf("Expected no pending jobs, got %d", n)
+	}
+}
+
+func TestEngineCancelRequest(t *testing.T) {
+	eng := New()
+	started := make(chan struct{})
+	eng.Register("hang", func(job *Job) Status {
+		close(started)
+		<-job.Canceled()
+		return job.Errorf("canceled")
+	})
+	reqEng := eng.WithRequestID("abc123")
+	errs := make(chan error)
+	go func() {
+		errs <- reqEng.Job("hang").Run()
+	}()
+	<-started
+	if err := eng.CancelRequest("def456"); err == nil {
+		t.Fatal("Expected an error canceling an unknown request")
+	}
+	if err := eng.CancelRequest("abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err == nil {
+		t.Fatal("Expected the canceled job to fail")
+	}
+	if err := reqEng.Job("hang").Run(); err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Fatalf("Expected the jobs of a canceled request not to run, got %v", err)
+	}
+
+	reqEng.Release()
+	if err := eng.CancelRequest("abc123"); err == nil {
+		t.Fatal("Expected an error canceling a released request")
+	}
+	if eng.Job("hang").IsCanceled() {
+		t.Fatal("Expected the jobs of the engine never to be canceled")
+	}
+}
+
+func TestEngineWithCancel(t *testing.T) {
+	eng := New()
+	reqEng := eng.WithRequestID("abc123")
+	defer reqEng.Release()
+
+	child, cancel := reqEng.WithCancel()
+	if child.RequestID() != "abc123" {
+		t.Fatalf("Expected the engine to serve the request of its parent, got %q", child.RequestID())
+	}
+	cancel()
+	<-child.Canceled()
+	if reqEng.Job("hang").IsCanceled() {
+		t.Fatal("Expected canceling the engine not to cancel its parent")
+	}
+
+	child, cancel = reqEng.WithCancel()
+	defer cancel()
+	if err := eng.CancelRequest("abc123"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-child.Canceled():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the engine to be canceled with its parent")
+	}
+}
+
+func TestEngineWithDeadline(t *testing.T) {
+	eng := New()
+	child, cancel := eng.WithDeadline(time.Now().Add(10 * time.Millisecond))
+	defer cancel()
+	select {
+	case <-child.Canceled():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the engine to be canceled at its deadline")
+	}
+	if err := child.Job("hang").Run(); err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Fatalf("Expected the jobs of an expired engine not to run, got %v", err)
+	}
+}
@@ -26,6 +26,7 @@ type Job struct {
 	Name    string
 	Args    []string
 	env     *Env
+	payload []byte // json, set with SetPayload
 	Stdout  *Output
 	Stderr  *Output
 	Stdin   *Input
@@ -55,10 +56,20 @@ func (job *Job) Run() error {
 	// The permanent fix is to implement Job.Stop and Job.OnStop so that
 	// ServeApi can cooperate and terminate cleanly.
 	if job.Name != "serveapi" {
-		job.Eng.l.Lock()
-		job.Eng.tasks.Add(1)
-		job.Eng.l.Unlock()
-		defer job.Eng.tasks.Done()
+		eng := job.Eng.main()
+		eng.l.Lock()
+		eng.tasks.Add(1)
+		eng.pending++
+		eng.l.Unlock()
+		defer func() {
+			eng.l.Lock()
+			eng.pending--
+			eng.l.Unlock()
+			eng.tasks.Done()
+		}()
+	}
+	if job.IsCanceled() {
+		return fmt.Errorf("%s: canceled", job.Name)
 	}
 	// FIXME: make this thread-safe
 	// FIXME: implement wait
@@ -76,8 +87,12 @@ func (job *Job) Run() error {
 		job.Errorf("%s: command not found", job.Name)
 		job.status = 127
 	} else {
+		start := time.Now()
 		job.status = job.handler(job)
 		job.end = time.Now()
+		if job.Eng.Tracing {
+			job.traceSpan(start)
+		}
 	}
 	// Wait for all background tasks to complete
 	if err := job.Stdout.Close(); err != nil {
@@ -95,6 +110,23 @@ func (job *Job) Run() error {
 	return nil
 }
 
+// Canceled returns a channel closed when the job is canceled, with the api
+// request it serves. The handlers of the long running jobs select on it to
+// give up.
+func (job *Job) Canceled() <-chan struct{} {
+	return job.Eng.Canceled()
+}
+
+// IsCanceled returns true if the job has been canceled.
+func (job *Job) IsCanceled() bool {
+	select {
+	case <-job.Canceled():
+		return true
+	default:
+		return false
+	}
+}
+
 func (job *Job) CallString() string {
 	return fmt.Sprintf("%s(%s)", job.Name, strings.Join(job.Args, ", "))
 }
@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jobStats counts the jobs run by the handler they ran, for /debug/vars.
+var (
+	jobStats   = expvar.NewMap("jobs")
+	jobStatsMu sync.Mutex
+)
+
+// handlerStats are the counters and durations of the jobs of a handler.
+type handlerStats struct {
+	sync.Mutex
+	calls    int64
+	failures int64
+	running  int64
+	total    time.Duration
+	max      time.Duration
+}
+
+// statsFor returns the stats of the jobs named name.
+func statsFor(name string) *handlerStats {
+	jobStatsMu.Lock()
+	defer jobStatsMu.Unlock()
+	if s, ok := jobStats.Get(name).(*handlerStats); ok {
+		return s
+	}
+	s := &handlerStats{}
+	jobStats.Set(name, s)
+	return s
+}
+
+// countJobs is the middleware counting the jobs of every handler.
+func countJobs(name string, next Handler) Handler {
+	stats := statsFor(name)
+	return func(job *Job) Status {
+		done := stats.start()
+		status := next(job)
+		done(status)
+		return status
+	}
+}
+
+// start counts a job as running until the returned function is called with
+// its status.
+func (s *handlerStats) start() func(Status) {
+	s.Lock()
+	s.running++
+	s.Unlock()
+	started := time.Now()
+	return func(status Status) {
+		d := time.Since(started)
+		s.Lock()
+		defer s.Unlock()
+		s.running--
+		s.calls++
+		if status != StatusOK {
+			s.failures++
+		}
+		s.total += d
+		if d > s.max {
+			s.max = d
+		}
+	}
+}
+
+func (s *handlerStats) String() string {
+	s.Lock()
+	defer s.Unlock()
+	out := struct {
+		Calls       int64   `json:"calls"`
+		Failures    int64   `json:"failures"`
+		FailureRate float64 `json:"failure_rate"`
+		Running     int64   `json:"running"`
+		TotalMs     float64 `json:"total_ms"`
+		MeanMs      float64 `json:"mean_ms"`
+		MaxMs       float64 `json:"max_ms"`
+	}{
+		Calls:    s.calls,
+		Failures: s.failures,
+		Running:  s.running,
+		TotalMs:  milliseconds(s.total),
+		MaxMs:    milliseconds(s.max),
+	}
+	if s.calls > 0 {
+		out.FailureRate = float64(s.failures) / float64(s.calls)
+		out.MeanMs = milliseconds(s.total / time.Duration(s.calls))
+	}
+	b, _ := json.Marshal(out)
+	return string(b)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// span is the trace of a job, logged when the engine traces its jobs.
+type span struct {
+	RequestID string `json:",omitempty"`
+	Job       string
+	Args      []string `json:",omitempty"`
+	Start     time.Time
+	Duration  float64 // in milliseconds
+	Status    Status
+}
+
+// traceSpan logs the span of the job, which started at start.
+func (job *Job) traceSpan(start time.Time) {
+	b, err := json.Marshal(&span{
+		RequestID: job.Eng.RequestID(),
+		Job:       job.Name,
+		Args:      job.Args,
+		Start:     start,
+		Duration:  milliseconds(time.Since(start)),
+		Status:    job.status,
+	})
+	if err != nil {
+		return
+	}
+	job.Eng.Logf("span %s", b)
+}
+
+// WriteDiagnostics writes the state of the engine to w, for a hung daemon to
+// be diagnosed: the jobs running, the requests in flight and the counters of
+// /debug/vars, among which the jobs running by handler.
+func (eng *Engine) WriteDiagnostics(w io.Writer) {
+	eng = eng.main()
+	eng.l.RLock()
+	pending := eng.pending
+	requests := make([]string, 0, len(eng.requests))
+	for id := range eng.requests {
+		requests = append(requests, id)
+	}
+	eng.l.RUnlock()
+	sort.Strings(requests)
+
+	fmt.Fprintf(w, "Goroutines: %d\n", runtime.NumGoroutine())
+	fmt.Fprintf(w, "Pending jobs: %d\n", pending)
+	fmt.Fprintf(w, "Requests in flight: %s\n", strings.Join(requests, " "))
+	expvar.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(w, "%s: %s\n", kv.Key, kv.Value)
+	})
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJobStats(t *testing.T) {
+	eng := New()
+	eng.Register("metrics_test", func(job *Job) Status {
+		if job.GetenvBool("fail") {
+			return job.Errorf("failed")
+		}
+		return StatusOK
+	})
+	for _, fail := range []bool{false, false, true, false} {
+		job := eng.Job("metrics_test")
+		job.SetenvBool("fail", fail)
+		job.Run()
+	}
+
+	var stats struct {
+		Calls       int64   `json:"calls"`
+		Failures    int64   `json:"failures"`
+		FailureRate float64 `json:"failure_rate"`
+		Running     int64   `json:"running"`
+	}
+	if err := json.Unmarshal([]byte(jobStats.Get("metrics_test").String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Calls != 4 || stats.Failures != 1 || stats.FailureRate != 0.25 || stats.Running != 0 {
+		t.Fatalf("Unexpected stats %#v", stats)
+	}
+}
+
+func TestTraceSpan(t *testing.T) {
+	eng := New()
+	eng.Register("dummy", func(job *Job) Status { return StatusOK })
+	var logs bytes.Buffer
+	eng.Stderr = &logs
+	eng.Tracing = true
+
+	if err := eng.WithRequestID("abc123").Job("dummy", "foo").Run(); err != nil {
+		t.Fatal(err)
+	}
+	var line string
+	for _, l := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(l, "span ") {
+			line = l
+		}
+	}
+	if line == "" {
+		t.Fatalf("Expected a span in the logs, got %q", logs.String())
+	}
+	var s span
+	if err := json.Unmarshal([]byte(line[strings.Index(line, "span ")+5:]), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.RequestID != "abc123" || s.Job != "dummy" || len(s.Args) != 1 || s.Args[0] != "foo" || s.Status != StatusOK || s.Start.IsZero() {
+		t.Fatalf("Unexpected span %#v", s)
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	eng := New()
+	reqEng := eng.WithRequestID("abc123")
+	defer reqEng.Release()
+	var out bytes.Buffer
+	reqEng.WriteDiagnostics(&out)
+	for _, expected := range []string{"Goroutines: ", "Pending jobs: 0\n", "Requests in flight: abc123\n", "jobs: "} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Expected %q in the diagnostics, got %q", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"runtime/debug"
+)
+
+// A Middleware wraps the handler of the jobs named name, to run around
+// them. It is for what all the handlers do alike, like checking access,
+// auditing or recovering from panics.
+type Middleware func(name string, next Handler) Handler
+
+// Use adds a middleware around the handlers of the engine, the catchall
+// included. The middlewares run in the order they are added, the first
+// being the outermost, for the jobs created after.
+func (eng *Engine) Use(m Middleware) {
+	eng = eng.main()
+	eng.l.Lock()
+	eng.chain = append(eng.chain, m)
+	eng.l.Unlock()
+}
+
+// wrap returns the handler of the jobs named name, wrapped in the
+// middlewares of the engine.
+func (eng *Engine) wrap(name string, handler Handler) Handler {
+	eng = eng.main()
+	eng.l.RLock()
+	defer eng.l.RUnlock()
+	for i := len(eng.chain) - 1; i >= 0; i-- {
+		handler = eng.chain[i](name, handler)
+	}
+	return handler
+}
+
+// Recover is a middleware turning the panics of the handlers into job
+// errors, logging their stack, for a broken handler not to bring the daemon
+// down.
+func Recover(name string, next Handler) Handler {
+	return func(job *Job) (status Status) {
+		defer func() {
+			if r := recover(); r != nil {
+				job.Eng.Logf("%s: panic: %v\n%s", job.CallString(), r, debug.Stack())
+				status = job.Errorf("%s: internal error: %v", name, r)
+			}
+		}()
+		return next(job)
+	}
+}
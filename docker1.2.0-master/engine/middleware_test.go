@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	eng := New()
+	var calls []string
+	trace := func(label string) Middleware {
+		return func(name string, next Handler) Handler {
+			return func(job *Job) Status {
+				calls = append(calls, label+" "+name)
+				return next(job)
+			}
+		}
+	}
+	eng.Register("dummy", func(job *Job) Status {
+		calls = append(calls, "handler")
+		return StatusOK
+	})
+	eng.RegisterCatchall(func(job *Job) Status {
+		calls = append(calls, "catchall")
+		return StatusOK
+	})
+	eng.Use(trace("outer"))
+	eng.WithRequestID("abc123").Use(trace("inner"))
+
+	if err := eng.Job("dummy").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Job("other").Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "outer dummy,inner dummy,handler,outer other,inner other,catchall"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Fatalf("Expected the calls %s, got %s", expected, got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	eng := New()
+	var logs bytes.Buffer
+	eng.Stderr = &logs
+	eng.Use(Recover)
+	eng.Register("broken", func(job *Job) Status {
+		var m map[string]string
+		m["boom"] = "boom"
+		return StatusOK
+	})
+	err := eng.Job("broken").Run()
+	if err == nil || !strings.Contains(err.Error(), "broken: internal error") {
+		t.Fatalf("Expected the panic to fail the job, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "panic") {
+		t.Fatalf("Expected the panic to be logged, got %q", logs.String())
+	}
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A payload is the typed alternative to the env of a job. The caller sets
+// it from any value with SetPayload, and the handler decodes it into its own
+// struct with DecodePayload, the struct being the schema of the job: its
+// fields are named by their json tag, and tagged `payload:"required"` when
+// they must be set. Unlike GetenvInt and friends, which return the zero value
+// of what they can't parse, decoding fails on the unknown fields, the missing
+// required fields and the values of the wrong type.
+//
+// The handlers decoding a payload keep working with the callers still using
+// the env: without a payload, the fields are parsed from the env keys of
+// their names, an empty value counting as unset.
+
+// SetPayload sets the payload of the job to the json encoding of v.
+func (job *Job) SetPayload(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	job.payload = data
+	return nil
+}
+
+// DecodePayload decodes the payload of the job, or its env without one,
+// into the struct v points to. The fields of v missing from the payload are
+// left as they are, which sets their default. The errors are prefixed with
+// "Bad parameter" for the api to answer them with a 400.
+func (job *Job) DecodePayload(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%s: the payload must be decoded into a pointer to a struct, not %T", job.Name, v)
+	}
+	fields := payloadFields(rv.Elem().Type())
+	var (
+		set map[string]bool
+		err error
+	)
+	if job.payload != nil {
+		set, err = decodeJsonPayload(job.payload, fields, v)
+	} else {
+		set, err = decodeEnvPayload(job.env, fields, rv.Elem())
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.required && !set[f.name] {
+			return fmt.Errorf("Bad parameter: %s is required", f.name)
+		}
+	}
+	return nil
+}
+
+// WritePayload writes the json encoding of v to the stdout of the job, for
+// its caller to decode with AddPayload.
+func (job *Job) WritePayload(v interface{}) error {
+	return json.NewEncoder(job.Stdout).Encode(v)
+}
+
+type payloadField struct {
+	name     string
+	index    int
+	required bool
+}
+
+// payloadFields returns the fields of the struct type t a payload can set.
+func payloadFields(t reflect.Type) []payloadField {
+	var fields []payloadField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := sf.Name
+		if tag := sf.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+		fields = append(fields, payloadField{
+			name:     name,
+			index:    i,
+			required: sf.Tag.Get("payload") == "required",
+		})
+	}
+	return fields
+}
+
+func decodeJsonPayload(data []byte, fields []payloadField, v interface{}) (map[string]bool, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("Bad parameter: %s", err)
+	}
+	set := make(map[string]bool)
+	for key := range keys {
+		known := false
+		// encoding/json matches the keys with the fields regardless of case
+		for _, f := range fields {
+			if strings.EqualFold(key, f.name) {
+				set[f.name] = true
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("Bad parameter: unknown field %s", key)
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("Bad parameter: %s", err)
+	}
+	return set, nil
+}
+
+func decodeEnvPayload(env *Env, fields []payloadField, v reflect.Value) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, f := range fields {
+		value := env.Get(f.name)
+		if value == "" {
+			continue
+		}
+		if err := setFromString(v.Field(f.index), value); err != nil {
+			return nil, fmt.Errorf("Bad parameter: invalid %s %q: %s", f.name, value, err)
+		}
+		set[f.name] = true
+	}
+	return set, nil
+}
+
+// setFromString sets the field to the value of an env key, parsed according
+// to its type. The values of the types with no string form are json.
+func setFromString(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setFromString(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
+	return nil
+}
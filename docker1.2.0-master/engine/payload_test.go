@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+type testPayload struct {
+	Name    string            `json:"name" payload:"required"`
+	Timeout int               `json:"t"`
+	Force   bool              `json:"force"`
+	Limit   *int              `json:"limit"`
+	Labels  map[string]string `json:"labels"`
+	ignored string
+}
+
+func TestPayload(t *testing.T) {
+	job := New().Job("dummy")
+	if err := job.SetPayload(map[string]interface{}{
+		"name":   "foo",
+		"force":  true,
+		"limit":  3,
+		"labels": map[string]string{"a": "b"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	p := testPayload{Timeout: 10}
+	if err := job.DecodePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "foo" || p.Timeout != 10 || !p.Force || p.Limit == nil || *p.Limit != 3 || p.Labels["a"] != "b" {
+		t.Fatalf("Unexpected payload %#v", p)
+	}
+}
+
+func TestPayloadFromEnv(t *testing.T) {
+	job := New().Job("dummy")
+	job.Setenv("name", "1.14")
+	job.Setenv("t", "")
+	job.Setenv("force", "1")
+	job.SetenvInt("limit", 3)
+	job.SetenvJson("labels", map[string]string{"a": "b"})
+	p := testPayload{Timeout: 10}
+	if err := job.DecodePayload(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "1.14" || p.Timeout != 10 || !p.Force || p.Limit == nil || *p.Limit != 3 || p.Labels["a"] != "b" {
+		t.Fatalf("Unexpected payload %#v", p)
+	}
+}
+
+func TestPayloadInvalid(t *testing.T) {
+	for _, c := range []struct {
+		payload interface{}
+		env     map[string]string
+		err     string
+	}{
+		{payload: map[string]interface{}{"t": 1}, err: "name is required"},
+		{payload: map[string]interface{}{"name": "foo", "timeout": 1}, err: "unknown field timeout"},
+		{payload: map[string]interface{}{"name": "foo", "t": "abc"}, err: "Bad parameter"},
+		{payload: []string{"foo"}, err: "Bad parameter"},
+		{env: map[string]string{"t": "1"}, err: "name is required"},
+		{env: map[string]string{"name": "foo", "t": "abc"}, err: "invalid t"},
+		{env: map[string]string{"name": "foo", "force": "maybe"}, err: "invalid force"},
+		{env: map[string]string{"name": "foo", "labels": "a=b"}, err: "invalid labels"},
+	} {
+		job := New().Job("dummy")
+		if c.payload != nil {
+			if err := job.SetPayload(c.payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for k, v := range c.env {
+			job.Setenv(k, v)
+		}
+		var p testPayload
+		err := job.DecodePayload(&p)
+		if err == nil || !strings.Contains(err.Error(), c.err) || !strings.HasPrefix(err.Error(), "Bad parameter") {
+			t.Errorf("%v%v: expected an error with %q, got %v", c.payload, c.env, c.err, err)
+		}
+	}
+}
+
+func TestWritePayload(t *testing.T) {
+	eng := New()
+	eng.Register("dummy", func(job *Job) Status {
+		var p testPayload
+		if err := job.DecodePayload(&p); err != nil {
+			return job.Error(err)
+		}
+		p.Timeout = len(p.Name)
+		if err := job.WritePayload(&p); err != nil {
+			return job.Error(err)
+		}
+		return StatusOK
+	})
+	job := eng.Job("dummy")
+	if err := job.SetPayload(&testPayload{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	var out testPayload
+	if err := job.Stdout.AddPayload(&out); err != nil {
+		t.Fatal(err)
+	}
+	if err := job.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "foo" || out.Timeout != 3 {
+		t.Fatalf("Unexpected output %#v", out)
+	}
+}
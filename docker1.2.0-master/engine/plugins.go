@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Plugins loaded at runtime register their handlers in a namespace of their
+// own, as `<namespace>.<name>`, and unregister them when unloaded. The
+// handlers registered with Register are never replaced nor removed.
+
+// ConflictPolicy is what RegisterPlugin does when the name of the handler is
+// taken.
+type ConflictPolicy int
+
+const (
+	// FailOnConflict refuses to register the handler.
+	FailOnConflict ConflictPolicy = iota
+	// ReplaceOnConflict replaces the handler of a plugin, for a plugin to be
+	// reloaded.
+	ReplaceOnConflict
+	// KeepOnConflict keeps the handler registered first, silently.
+	KeepOnConflict
+)
+
+var validPluginName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// RegisterPlugin registers handler as namespace.name, on behalf of a plugin.
+// The jobs created before keep the handler they had.
+func (eng *Engine) RegisterPlugin(namespace, name string, handler Handler, policy ConflictPolicy) error {
+	if !validPluginName.MatchString(namespace) {
+		return fmt.Errorf("Invalid plugin namespace %q, only [a-z0-9_-] are allowed", namespace)
+	}
+	if !validPluginName.MatchString(name) {
+		return fmt.Errorf("Invalid plugin handler name %q, only [a-z0-9_-] are allowed", name)
+	}
+	full := namespace + "." + name
+	eng = eng.main()
+	eng.l.Lock()
+	defer eng.l.Unlock()
+	if _, exists := eng.handlers[full]; exists {
+		if _, dynamic := eng.plugins[full]; !dynamic {
+			return fmt.Errorf("Can't overwrite handler for command %s", full)
+		}
+		switch policy {
+		case KeepOnConflict:
+			return nil
+		case ReplaceOnConflict:
+		default:
+			return fmt.Errorf("Conflict: handler %s is already registered", full)
+		}
+	}
+	if eng.plugins == nil {
+		eng.plugins = make(map[string]string)
+	}
+	eng.handlers[full] = handler
+	eng.plugins[full] = namespace
+	return nil
+}
+
+// UnregisterPlugin removes the handler registered as namespace.name with
+// RegisterPlugin. The jobs created before can still run.
+func (eng *Engine) UnregisterPlugin(namespace, name string) error {
+	full := namespace + "." + name
+	eng = eng.main()
+	eng.l.Lock()
+	defer eng.l.Unlock()
+	if _, dynamic := eng.plugins[full]; !dynamic {
+		return fmt.Errorf("No such plugin handler: %s", full)
+	}
+	delete(eng.handlers, full)
+	delete(eng.plugins, full)
+	return nil
+}
+
+// UnregisterNamespace removes all the handlers of the namespace, for the
+// plugin to be unloaded, and returns their names.
+func (eng *Engine) UnregisterNamespace(namespace string) []string {
+	eng = eng.main()
+	eng.l.Lock()
+	defer eng.l.Unlock()
+	var removed []string
+	for full, ns := range eng.plugins {
+		if ns == namespace {
+			delete(eng.handlers, full)
+			delete(eng.plugins, full)
+			removed = append(removed, full)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// PluginHandlers returns the names of the handlers registered in the
+// namespace, sorted alphabetically.
+func (eng *Engine) PluginHandlers(namespace string) []string {
+	eng = eng.main()
+	eng.l.RLock()
+	defer eng.l.RUnlock()
+	var names []string
+	for full, ns := range eng.plugins {
+		if ns == namespace {
+			names = append(names, strings.TrimPrefix(full, namespace+"."))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegisterPlugin(t *testing.T) {
+	eng := New()
+	handler := func(out string) Handler {
+		return func(job *Job) Status {
+			job.Printf("%s", out)
+			return StatusOK
+		}
+	}
+	run := func(name string) string {
+		var out bytes.Buffer
+		job := eng.Job(name)
+		job.Stdout.Add(&out)
+		if err := job.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	if err := eng.RegisterPlugin("myplugin", "backup", handler("v1"), FailOnConflict); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.RegisterPlugin("myplugin", "backup", handler("v2"), FailOnConflict); err == nil || !strings.HasPrefix(err.Error(), "Conflict") {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+	if err := eng.RegisterPlugin("myplugin", "backup", handler("v2"), KeepOnConflict); err != nil {
+		t.Fatal(err)
+	}
+	if out := run("myplugin.backup"); out != "v1" {
+		t.Fatalf("Expected the first handler to be kept, got %q", out)
+	}
+	if err := eng.RegisterPlugin("myplugin", "backup", handler("v2"), ReplaceOnConflict); err != nil {
+		t.Fatal(err)
+	}
+	if out := run("myplugin.backup"); out != "v2" {
+		t.Fatalf("Expected the handler to be replaced, got %q", out)
+	}
+	if err := eng.RegisterPlugin("myplugin", "restore", handler("restore"), FailOnConflict); err != nil {
+		t.Fatal(err)
+	}
+	if names := eng.PluginHandlers("myplugin"); strings.Join(names, ",") != "backup,restore" {
+		t.Fatalf("Unexpected handlers %v", names)
+	}
+
+	if err := eng.UnregisterPlugin("myplugin", "restore"); err != nil {
+		t.Fatal(err)
+	}
+	if eng.Exists("myplugin.restore") {
+		t.Fatal("Expected myplugin.restore to be unregistered")
+	}
+	if removed := eng.UnregisterNamespace("myplugin"); strings.Join(removed, ",") != "myplugin.backup" {
+		t.Fatalf("Unexpected removed handlers %v", removed)
+	}
+	if eng.Exists("myplugin.backup") {
+		t.Fatal("Expected myplugin.backup to be unregistered")
+	}
+}
+
+func TestRegisterPluginStatic(t *testing.T) {
+	eng := New()
+	eng.Register("core.dummy", func(job *Job) Status { return StatusOK })
+	for _, policy := range []ConflictPolicy{FailOnConflict, ReplaceOnConflict, KeepOnConflict} {
+		if err := eng.RegisterPlugin("core", "dummy", func(job *Job) Status { return StatusErr }, policy); err == nil {
+			t.Fatalf("Expected policy %d not to overwrite a static handler", policy)
+		}
+	}
+	if err := eng.UnregisterPlugin("core", "dummy"); err == nil {
+		t.Fatal("Expected a static handler not to be unregistered")
+	}
+	if err := eng.RegisterPlugin("my.plugin", "backup", func(job *Job) Status { return StatusOK }, FailOnConflict); err == nil {
+		t.Fatal("Expected an invalid namespace to fail")
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Services are the objects the subsystems of the daemon share through the
+// engine, like the daemon itself, which don't fit in the jobs. Each is
+// registered under a name until unregistered.
+
+// RegisterService registers svc under name, which must be free.
+func (eng *Engine) RegisterService(name string, svc interface{}) error {
+	eng = eng.main()
+	eng.l.Lock()
+	defer eng.l.Unlock()
+	if _, exists := eng.services[name]; exists {
+		return fmt.Errorf("Can't overwrite service %s", name)
+	}
+	if eng.services == nil {
+		eng.services = make(map[string]interface{})
+	}
+	eng.services[name] = svc
+	return nil
+}
+
+// UnregisterService forgets the service registered under name, if any.
+func (eng *Engine) UnregisterService(name string) {
+	eng = eng.main()
+	eng.l.Lock()
+	delete(eng.services, name)
+	eng.l.Unlock()
+}
+
+// Service returns the service registered under name, if any.
+func (eng *Engine) Service(name string) (svc interface{}, exists bool) {
+	eng = eng.main()
+	eng.l.RLock()
+	defer eng.l.RUnlock()
+	svc, exists = eng.services[name]
+	return svc, exists
+}
+
+// LookupService stores the service registered under name in the variable
+// dst points to, failing if there is none or if it has another type.
+//
+//	var daemon *daemon.Daemon
+//	if err := eng.LookupService("daemon", &daemon); err != nil {
+//		...
+//	}
+func (eng *Engine) LookupService(name string, dst interface{}) error {
+	svc, exists := eng.Service(name)
+	if !exists {
+		return fmt.Errorf("No such service: %s", name)
+	}
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("Can't store service %s in %T, not a pointer", name, dst)
+	}
+	sv := reflect.ValueOf(svc)
+	if !sv.IsValid() || !sv.Type().AssignableTo(v.Elem().Type()) {
+		return fmt.Errorf("Service %s is a %T, not a %s", name, svc, v.Elem().Type())
+	}
+	v.Elem().Set(sv)
+	return nil
+}
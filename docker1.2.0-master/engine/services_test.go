@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestServices(t *testing.T) {
+	eng := New()
+	ip := net.ParseIP("172.17.42.1")
+	if err := eng.RegisterService("bridge.ip", ip); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.RegisterService("bridge.ip", ip); err == nil {
+		t.Fatal("Expected an error registering a service twice")
+	}
+
+	// The request engines share the services of their engine
+	var found net.IP
+	if err := eng.WithRequestID("abc123").LookupService("bridge.ip", &found); err != nil {
+		t.Fatal(err)
+	}
+	if !found.Equal(ip) {
+		t.Fatalf("Expected %s, got %s", ip, found)
+	}
+	var wrong string
+	if err := eng.LookupService("bridge.ip", &wrong); err == nil || !strings.Contains(err.Error(), "not a string") {
+		t.Fatalf("Expected an error looking up a service with another type, got %v", err)
+	}
+	if err := eng.LookupService("bridge.ip", found); err == nil {
+		t.Fatal("Expected an error looking up a service into a non pointer")
+	}
+	var any interface{}
+	if err := eng.LookupService("bridge.ip", &any); err != nil {
+		t.Fatal(err)
+	}
+
+	eng.UnregisterService("bridge.ip")
+	if _, exists := eng.Service("bridge.ip"); exists {
+		t.Fatal("Expected the service to be unregistered")
+	}
+	if err := eng.LookupService("bridge.ip", &found); err == nil || !strings.Contains(err.Error(), "No such service") {
+		t.Fatalf("Expected an error looking up an unregistered service, got %v", err)
+	}
+}
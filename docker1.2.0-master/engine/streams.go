@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -185,6 +186,28 @@ func (o *Output) AddEnv() (dst *Env, err error) {
 	return dst, nil
 }
 
+// AddPayload starts a new goroutine which will decode all subsequent data
+// as a stream of json-encoded objects into dst, like the payload written by
+// a job with WritePayload.
+// It is not safe to query `dst` until the Output is closed.
+func (o *Output) AddPayload(dst interface{}) error {
+	src, err := o.AddPipe()
+	if err != nil {
+		return err
+	}
+	o.tasks.Add(1)
+	go func() {
+		defer o.tasks.Done()
+		decoder := json.NewDecoder(src)
+		for {
+			if err := decoder.Decode(dst); err != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
 func (o *Output) AddListTable() (dst *Table, err error) {
 	src, err := o.AddPipe()
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 type Table struct {
 	Data    []*Env
 	sortKey string
+	tieKey  string
 	Chan    chan *Env
 }
 
@@ -18,6 +19,7 @@ func NewTable(sortKey string, sizeHint int) *Table {
 	return &Table{
 		make([]*Env, 0, sizeHint),
 		sortKey,
+		"",
 		make(chan *Env),
 	}
 }
@@ -26,6 +28,12 @@ func (t *Table) SetKey(sortKey string) {
 	t.sortKey = sortKey
 }
 
+// SetTieKey sets the key ordering the entries with the same sort key, for
+// the order of the table to be stable across calls.
+func (t *Table) SetTieKey(tieKey string) {
+	t.tieKey = tieKey
+}
+
 func (t *Table) Add(env *Env) {
 	t.Data = append(t.Data, env)
 }
@@ -35,7 +43,13 @@ func (t *Table) Len() int {
 }
 
 func (t *Table) Less(a, b int) bool {
-	return t.lessBy(a, b, t.sortKey)
+	if t.lessBy(a, b, t.sortKey) {
+		return true
+	}
+	if t.tieKey == "" || t.lessBy(b, a, t.sortKey) {
+		return false
+	}
+	return t.lessBy(a, b, t.tieKey)
 }
 
 func (t *Table) lessBy(a, b int, by string) bool {
@@ -63,6 +77,18 @@ func (t *Table) ReverseSort() {
 	sort.Sort(sort.Reverse(t))
 }
 
+// Page keeps the limit entries following the first offset ones. A limit of
+// 0 or less keeps all the entries following them.
+func (t *Table) Page(offset, limit int) {
+	if offset > len(t.Data) {
+		offset = len(t.Data)
+	}
+	t.Data = t.Data[offset:]
+	if limit > 0 && limit < len(t.Data) {
+		t.Data = t.Data[:limit]
+	}
+}
+
 func (t *Table) WriteListTo(dst io.Writer) (n int64, err error) {
 	if _, err := dst.Write([]byte{'['}); err != nil {
 		return -1, err
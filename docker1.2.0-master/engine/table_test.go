@@ -110,3 +110,50 @@ func TestTableReverseSortStringValue(t *testing.T) {
 		t.Fatalf("Expected A, got %s", value)
 	}
 }
+
+func TestTableReverseSortTieKey(t *testing.T) {
+	table := NewTable("Key", 0)
+	table.SetTieKey("Id")
+	for _, kv := range [][2]string{{"1", "a"}, {"2", "c"}, {"1", "b"}, {"2", "d"}} {
+		e := &Env{}
+		e.Set("Key", kv[0])
+		e.Set("Id", kv[1])
+		table.Add(e)
+	}
+	table.ReverseSort()
+	for i, id := range []string{"d", "c", "b", "a"} {
+		if value := table.Data[i].Get("Id"); value != id {
+			t.Fatalf("Expected %s at %d, got %s", id, i, value)
+		}
+	}
+}
+
+func TestTablePage(t *testing.T) {
+	for _, c := range []struct {
+		offset, limit int
+		expected      []string
+	}{
+		{0, 0, []string{"A", "B", "C", "D"}},
+		{0, 2, []string{"A", "B"}},
+		{1, 2, []string{"B", "C"}},
+		{3, 2, []string{"D"}},
+		{2, 0, []string{"C", "D"}},
+		{5, 2, []string{}},
+	} {
+		table := NewTable("Key", 0)
+		for _, key := range []string{"A", "B", "C", "D"} {
+			e := &Env{}
+			e.Set("Key", key)
+			table.Add(e)
+		}
+		table.Page(c.offset, c.limit)
+		if table.Len() != len(c.expected) {
+			t.Fatalf("Expected %d entries for offset %d and limit %d, got %d", len(c.expected), c.offset, c.limit, table.Len())
+		}
+		for i, key := range c.expected {
+			if value := table.Data[i].Get("Key"); value != key {
+				t.Fatalf("Expected %s, got %s", key, value)
+			}
+		}
+	}
+}
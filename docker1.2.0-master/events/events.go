@@ -88,8 +88,14 @@ func (e *Events) Log(job *engine.Job) engine.Status {
 	if len(job.Args) != 3 {
 		return job.Errorf("usage: %s ACTION ID FROM", job.Name)
 	}
+	var attributes map[string]string
+	if job.EnvExists("Attributes") {
+		if err := job.GetenvJson("Attributes", &attributes); err != nil {
+			return job.Error(err)
+		}
+	}
 	// not waiting for receivers
-	go e.log(job.Args[0], job.Args[1], job.Args[2])
+	go e.log(job.Args[0], job.Args[1], job.Args[2], attributes)
 	return engine.StatusOK
 }
 
@@ -132,10 +138,10 @@ func (e *Events) subscribersCount() int {
 	return c
 }
 
-func (e *Events) log(action, id, from string) {
+func (e *Events) log(action, id, from string, attributes map[string]string) {
 	e.mu.Lock()
 	now := time.Now().UTC().Unix()
-	jm := &utils.JSONMessage{Status: action, ID: id, From: from, Time: now}
+	jm := &utils.JSONMessage{Status: action, ID: id, From: from, Time: now, Attributes: attributes}
 	if len(e.events) == cap(e.events) {
 		// discard oldest event
 		copy(e.events, e.events[1:])
@@ -22,7 +22,7 @@ func TestEventsPublish(t *testing.T) {
 	if count != 2 {
 		t.Fatalf("Must be 2 subscribers, got %d", count)
 	}
-	go e.log("test", "cont", "image")
+	go e.log("test", "cont", "image", nil)
 	select {
 	case msg := <-l1:
 		if len(e.events) != 1 {
@@ -66,7 +66,7 @@ func TestEventsPublishTimeout(t *testing.T) {
 
 	c := make(chan struct{})
 	go func() {
-		e.log("test", "cont", "image")
+		e.log("test", "cont", "image", nil)
 		close(c)
 	}()
 
@@ -152,3 +152,27 @@ func TestEventsCountJob(t *testing.T) {
 		t.Fatalf("There must be 2 subscribers, got %d", count)
 	}
 }
+
+func TestLogEventAttributes(t *testing.T) {
+	e := New()
+	eng := engine.New()
+	if err := e.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	l := make(chan *utils.JSONMessage)
+	e.subscribe(l)
+
+	job := eng.Job("log", "die", "cont", "image")
+	job.SetenvJson("Attributes", map[string]string{"exitCode": "1"})
+	if err := job.Run(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-l:
+		if msg.Status != "die" || msg.Attributes["exitCode"] != "1" {
+			t.Fatalf("Unexpected event %#v", msg)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("Timeout waiting for broadcasted message")
+	}
+}
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/docker/archive"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/log"
+)
+
+// CmdBuildCacheExport exports the build cache of the given images: the
+// images, their parents and all the images built on top of them, which
+// hold the config of the instructions they were built by. The archive has
+// the format of image_export without tags, so that loading it in another
+// daemon gives the same cache hits.
+func (s *TagStore) CmdBuildCacheExport(job *engine.Job) engine.Status {
+	if len(job.Args) < 1 {
+		return job.Errorf("Usage: %s IMAGE [IMAGE...]\n", job.Name)
+	}
+	tempdir, err := ioutil.TempDir("", "docker-export-cache-")
+	if err != nil {
+		return job.Error(err)
+	}
+	defer os.RemoveAll(tempdir)
+
+	var (
+		pending []string
+		seen    = make(map[string]struct{})
+	)
+	for _, name := range job.Args {
+		img, err := s.LookupImage(name)
+		if err != nil {
+			return job.Error(err)
+		}
+		pending = append(pending, img.ID)
+	}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if _, exists := seen[id]; exists {
+			continue
+		}
+		seen[id] = struct{}{}
+		// The parents already exported are skipped
+		if err := s.exportImage(job.Eng, id, tempdir); err != nil {
+			return job.Error(err)
+		}
+		children, err := s.graph.Children(id)
+		if err != nil {
+			return job.Error(err)
+		}
+		for _, child := range children {
+			pending = append(pending, child.ID)
+		}
+	}
+	log.Debugf("Exporting the build cache of %d images", len(seen))
+
+	fs, err := archive.Tar(tempdir, archive.Uncompressed)
+	if err != nil {
+		return job.Error(err)
+	}
+	defer fs.Close()
+	if _, err := io.Copy(job.Stdout, fs); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
+	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
+)
+
+func TestCmdBuildCacheExport(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	create := func(parent, cmd string) string {
+		img, err := store.graph.Create(fakeLayer([]string{cmd}, nil), "fake", parent, "", "", &runconfig.Config{}, &runconfig.Config{Cmd: []string{cmd}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img.ID
+	}
+	var (
+		child      = create(testImageID, "child")
+		grandchild = create(child, "grandchild")
+		sibling    = create(testImageID, "sibling")
+	)
+	if err := store.Set("app", "child", child, false); err != nil {
+		t.Fatal(err)
+	}
+
+	eng := engine.New()
+	eng.Logging = false
+	if err := store.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	export := func(names ...string) ([]string, error) {
+		job := eng.Job("build_cache_export", names...)
+		buf := new(bytes.Buffer)
+		job.Stdout.Add(buf)
+		if err := job.Run(); err != nil {
+			return nil, err
+		}
+		var (
+			ids  []string
+			seen = make(map[string]bool)
+			tr   = tar.NewReader(buf)
+		)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := path.Clean(hdr.Name)
+			if name == "." {
+				continue
+			}
+			if name == "repositories" {
+				t.Fatal("Expected the export to have no tags")
+			}
+			if id := strings.SplitN(name, "/", 2)[0]; !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		return ids, nil
+	}
+
+	// The image, its parents and the images built on top of it
+	ids, err := export("app:child")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{testImageID, child, grandchild}
+	sort.Strings(expected)
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the images %v, got %v", expected, ids)
+	}
+
+	// The images shared by several names are exported once
+	if ids, err = export(testImageName, child); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, sibling)
+	sort.Strings(expected)
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the images %v, got %v", expected, ids)
+	}
+
+	if _, err := export(); err == nil {
+		t.Fatal("Expected an error without image")
+	}
+	if _, err := export("missing"); err == nil {
+		t.Fatal("Expected an error for a missing image")
+	}
+}
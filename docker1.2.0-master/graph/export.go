@@ -6,23 +6,25 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/docker/docker/archive"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/parsers"
 )
 
-// CmdImageExport exports all images with the given tag. All versions
-// containing the same tag are exported. The resulting output is an
-// uncompressed tar ball.
-// name is the set of tags to export.
+// CmdImageExport exports all images with the given tags. All versions
+// containing the same tag are exported, and the layers shared by several
+// images are only exported once. The resulting output is an uncompressed
+// tar ball.
+// names is the set of tags to export.
 // out is the writer where the images are written to.
 func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
-	if len(job.Args) != 1 {
-		return job.Errorf("Usage: %s IMAGE\n", job.Name)
+	if len(job.Args) < 1 {
+		return job.Errorf("Usage: %s IMAGE [IMAGE...]\n", job.Name)
 	}
-	name := job.Args[0]
 	// get image json
 	tempdir, err := ioutil.TempDir("", "docker-export-")
 	if err != nil {
@@ -30,23 +32,30 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 	}
 	defer os.RemoveAll(tempdir)
 
-	log.Debugf("Serializing %s", name)
-
 	rootRepoMap := map[string]Repository{}
-	rootRepo, err := s.Get(name)
-	if err != nil {
-		return job.Error(err)
+	addTag := func(repoName, tag, id string) {
+		if _, exists := rootRepoMap[repoName]; !exists {
+			rootRepoMap[repoName] = Repository{}
+		}
+		rootRepoMap[repoName][tag] = id
 	}
-	if rootRepo != nil {
-		// this is a base repo name, like 'busybox'
+	for _, name := range job.Args {
+		log.Debugf("Serializing %s", name)
+		rootRepo, err := s.Get(name)
+		if err != nil {
+			return job.Error(err)
+		}
+		if rootRepo != nil {
+			// this is a base repo name, like 'busybox'
 
-		for _, id := range rootRepo {
-			if err := s.exportImage(job.Eng, id, tempdir); err != nil {
-				return job.Error(err)
+			for tag, id := range rootRepo {
+				if err := s.exportImage(job.Eng, id, tempdir); err != nil {
+					return job.Error(err)
+				}
+				addTag(name, tag, id)
 			}
+			continue
 		}
-		rootRepoMap[name] = rootRepo
-	} else {
 		img, err := s.LookupImage(name)
 		if err != nil {
 			return job.Error(err)
@@ -58,9 +67,9 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 				return job.Error(err)
 			}
 			// check this length, because a lookup of a truncated has will not have a tag
-			// and will not need to be added to this map
-			if len(repoTag) > 0 {
-				rootRepoMap[repoName] = Repository{repoTag: img.ID}
+			// and will not need to be added to this map, neither does a digest
+			if len(repoTag) > 0 && !image.IsDigest(repoTag) {
+				addTag(repoName, repoTag, img.ID)
 			}
 		} else {
 			// this must be an ID that didn't get looked up just right?
@@ -89,7 +98,7 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 	if _, err := io.Copy(job.Stdout, fs); err != nil {
 		return job.Error(err)
 	}
-	log.Debugf("End Serializing %s", name)
+	log.Debugf("End Serializing %s", strings.Join(job.Args, ", "))
 	return engine.StatusOK
 }
 
@@ -124,14 +133,22 @@ func (s *TagStore) exportImage(eng *engine.Engine, name, tempdir string) error {
 			return err
 		}
 
-		// serialize filesystem
+		// serialize filesystem, compressed like the layers pushed
 		fsTar, err := os.Create(path.Join(tmpImageDir, "layer.tar"))
 		if err != nil {
 			return err
 		}
+		layer, err := archive.CompressStreamLevel(fsTar, s.layerCompression, s.compressionLevel)
+		if err != nil {
+			fsTar.Close()
+			return err
+		}
+		// Running the job closes the compression of the layer
 		job = eng.Job("image_tarlayer", n)
-		job.Stdout.Add(fsTar)
-		if err := job.Run(); err != nil {
+		job.Stdout.Add(layer)
+		err = job.Run()
+		fsTar.Close()
+		if err != nil {
 			return err
 		}
 
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/pkg/truncindex"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
@@ -27,6 +29,10 @@ type Graph struct {
 	Root    string                 //graph 的工作根目录，一般为 "/var/lib/docker/graph
 	idIndex *truncindex.TruncIndex //idlndex 使得检索字 符串标识符时，允许使用任意一个该字符串唯一的前缀，只要该前缀全局唯一，则可确保找 到相应的镜像。
 	driver  graphdriver.Driver     //表示具体的 graphdriver 类型。
+	parents *parentIndex           //按父镜像索引的镜像，持久化在 graph 根目录，避免每次查找子镜像都加载所有镜像
+
+	registeringLock sync.Mutex
+	registering     map[string]struct{} // the images being registered by a pull, a commit or a load
 }
 
 // NewGraph instantiates a new graph at the given root path in the filesystem.
@@ -42,9 +48,10 @@ func NewGraph(root string, driver graphdriver.Driver) (*Graph, error) {
 	}
 
 	graph := &Graph{
-		Root:    abspath,
-		idIndex: truncindex.NewTruncIndex([]string{}),
-		driver:  driver,
+		Root:        abspath,
+		idIndex:     truncindex.NewTruncIndex([]string{}),
+		driver:      driver,
+		registering: make(map[string]struct{}),
 	}
 	if err := graph.restore(); err != nil {
 		return nil, err
@@ -65,6 +72,16 @@ func (graph *Graph) restore() error {
 		}
 	}
 	graph.idIndex = truncindex.NewTruncIndex(ids)
+	graph.parents = newParentIndex(path.Join(graph.Root, "_index"))
+	if err := graph.parents.restore(ids, func(id string) (string, error) {
+		img, err := image.LoadImage(graph.ImageRoot(id))
+		if err != nil {
+			return "", err
+		}
+		return img.Parent, nil
+	}); err != nil {
+		return err
+	}
 	log.Debugf("Restored %d elements", len(dir))
 	return nil
 }
@@ -84,6 +101,15 @@ func (graph *Graph) Exists(id string) bool {
 	return true
 }
 
+// Registering tells whether the image id is being registered, its
+// directory being possibly already in the root of the graph.
+func (graph *Graph) Registering(id string) bool {
+	graph.registeringLock.Lock()
+	defer graph.registeringLock.Unlock()
+	_, exists := graph.registering[id]
+	return exists
+}
+
 // Get returns the image with the given id, or an error if the image doesn't exist.
 func (graph *Graph) Get(name string) (*image.Image, error) {
 	id, err := graph.idIndex.Get(name)
@@ -134,10 +160,28 @@ func (graph *Graph) Get(name string) (*image.Image, error) {
 
 // Create creates a new image and registers it in the graph.
 func (graph *Graph) Create(layerData archive.ArchiveReader, containerID, containerImage, comment, author string, containerConfig, config *runconfig.Config) (*image.Image, error) {
+	return graph.CreateAt(layerData, containerID, containerImage, comment, author, containerConfig, config, time.Time{})
+}
+
+// CreateAt is Create with the creation date of the image and the
+// modification time of the files of its layer pinned to timestamp, for
+// reproducible images. A zero timestamp is the current time, not pinned.
+func (graph *Graph) CreateAt(layerData archive.ArchiveReader, containerID, containerImage, comment, author string, containerConfig, config *runconfig.Config, timestamp time.Time) (*image.Image, error) {
+	created := time.Now().UTC()
+	if !timestamp.IsZero() {
+		created = timestamp.UTC()
+		if layerData != nil {
+			pinned, err := archive.PinTimestamps(layerData, timestamp)
+			if err != nil {
+				return nil, err
+			}
+			defer pinned.Close()
+			layerData = pinned
+		}
+	}
 	img := &image.Image{
-		ID:            utils.GenerateRandomID(),
 		Comment:       comment,
-		Created:       time.Now().UTC(),
+		Created:       created,
 		DockerVersion: dockerversion.VERSION,
 		Author:        author,
 		Config:        config,
@@ -150,6 +194,49 @@ func (graph *Graph) Create(layerData archive.ArchiveReader, containerID, contain
 		img.Container = containerID
 		img.ContainerConfig = *containerConfig
 	}
+	return graph.create(img, layerData)
+}
+
+// create sets the ID of img, from its content when it has a layer, and
+// registers it. The image of the graph is returned if it already has it.
+func (graph *Graph) create(img *image.Image, layerData archive.ArchiveReader) (*image.Image, error) {
+	if layerData == nil {
+		img.ID = utils.GenerateRandomID()
+	} else {
+		// The image is content addressable: its ID is the digest of its
+		// json, which includes the checksum of the layer. The layer is
+		// spooled to compute it before the image gets registered.
+		tmp, err := graph.Mktemp("")
+		if err != nil {
+			return nil, fmt.Errorf("Mktemp failed: %s", err)
+		}
+		defer os.RemoveAll(tmp)
+		layer, err := os.Create(path.Join(tmp, "layer.tar"))
+		if err != nil {
+			return nil, err
+		}
+		defer layer.Close()
+		decompressed, err := archive.DecompressStream(layerData)
+		if err != nil {
+			return nil, err
+		}
+		defer decompressed.Close()
+		ts := &tarsum.TarSum{Reader: decompressed, DisableCompression: true}
+		if _, err := io.Copy(layer, ts); err != nil {
+			return nil, err
+		}
+		img.Checksum = ts.Sum(nil)
+		if img.ID, err = img.ContentID(); err != nil {
+			return nil, err
+		}
+		if graph.Exists(img.ID) {
+			return graph.Get(img.ID)
+		}
+		if _, err := layer.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		layerData = layer
+	}
 
 	if err := graph.Register(nil, layerData, img); err != nil {
 		return nil, err
@@ -177,6 +264,28 @@ func (graph *Graph) Register(jsonData []byte, layerData archive.ArchiveReader, i
 	if graph.Exists(img.ID) {
 		return fmt.Errorf("Image %s already exists", img.ID)
 	}
+	graph.registeringLock.Lock()
+	graph.registering[img.ID] = struct{}{}
+	graph.registeringLock.Unlock()
+	defer func() {
+		graph.registeringLock.Lock()
+		delete(graph.registering, img.ID)
+		graph.registeringLock.Unlock()
+	}()
+	// Content addressable images must match their digest
+	if err := img.VerifyContentID(); err != nil {
+		return err
+	}
+	var ts *tarsum.TarSum
+	if img.Checksum != "" && layerData != nil {
+		decompressed, err := archive.DecompressStream(layerData)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		ts = &tarsum.TarSum{Reader: decompressed, DisableCompression: true}
+		layerData = ts
+	}
 
 	// Ensure that the image root does not exist on the filesystem
 	// when it is not registered in the graph.
@@ -212,19 +321,33 @@ func (graph *Graph) Register(jsonData []byte, layerData archive.ArchiveReader, i
 	if err := image.StoreImage(img, jsonData, layerData, tmp, rootfs); err != nil {
 		return err
 	}
+	if ts != nil {
+		// The layer is applied once its end is found, read the rest of
+		// the archive for the checksum
+		if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+			return err
+		}
+		if checksum := ts.Sum(nil); checksum != img.Checksum {
+			return fmt.Errorf("Layer of image %s doesn't match its checksum: expected %s, got %s", img.ID, img.Checksum, checksum)
+		}
+	}
 	// Commit
 	if err := os.Rename(tmp, graph.ImageRoot(img.ID)); err != nil {
 		return err
 	}
 	graph.idIndex.Add(img.ID)
+	if err := graph.parents.Add(img.ID, img.Parent); err != nil {
+		log.Errorf("Error saving the image index: %s", err)
+	}
 	return nil
 }
 
-// TempLayerArchive creates a temporary archive of the given image's filesystem layer.
+// TempLayerArchive creates a temporary archive of the given image's filesystem layer,
+// compressed with compression at level, 0 being the default level.
 //   The archive is stored on disk and will be automatically deleted as soon as has been read.
 //   If output is not nil, a human-readable progress bar will be written to it.
 //   FIXME: does this belong in Graph? How about MktempFile, let the caller use it for archives?
-func (graph *Graph) TempLayerArchive(id string, compression archive.Compression, sf *utils.StreamFormatter, output io.Writer) (*archive.TempArchive, error) {
+func (graph *Graph) TempLayerArchive(id string, compression archive.Compression, level int, sf *utils.StreamFormatter, output io.Writer) (*archive.TempArchive, error) {
 	image, err := graph.Get(id)
 	if err != nil {
 		return nil, err
@@ -233,10 +356,15 @@ func (graph *Graph) TempLayerArchive(id string, compression archive.Compression,
 	if err != nil {
 		return nil, err
 	}
-	a, err := image.TarLayer()
+	layer, err := image.TarLayer()
 	if err != nil {
 		return nil, err
 	}
+	a, err := archive.Compress(layer, compression, level)
+	if err != nil {
+		layer.Close()
+		return nil, err
+	}
 	progress := utils.ProgressReader(a, 0, output, sf, false, utils.TruncateID(id), "Buffering to disk")
 	defer progress.Close()
 	return archive.NewTempArchive(progress, tmp)
@@ -334,6 +462,9 @@ func (graph *Graph) Delete(name string) error {
 		return err
 	}
 	graph.idIndex.Delete(id)
+	if err := graph.parents.Delete(id); err != nil {
+		log.Errorf("Error saving the image index: %s", err)
+	}
 	err = os.Rename(graph.ImageRoot(id), tmp)
 	if err != nil {
 		return err
@@ -380,36 +511,51 @@ func (graph *Graph) walkAll(handler func(*image.Image)) error {
 // If an image has no children, it will not have an entry in the table.
 func (graph *Graph) ByParent() (map[string][]*image.Image, error) {
 	byParent := make(map[string][]*image.Image)
-	err := graph.walkAll(func(img *image.Image) {
-		parent, err := graph.Get(img.Parent)
-		if err != nil {
-			return
+	for id, parent := range graph.parents.Parents() {
+		if _, err := graph.idIndex.Get(parent); parent == "" || err != nil {
+			continue
 		}
-		if children, exists := byParent[parent.ID]; exists {
-			byParent[parent.ID] = append(children, img)
-		} else {
-			byParent[parent.ID] = []*image.Image{img}
+		img, err := graph.Get(id)
+		if err != nil {
+			continue
 		}
-	})
-	return byParent, err
+		byParent[parent] = append(byParent[parent], img)
+	}
+	return byParent, nil
 }
 
 // Heads returns all heads in the graph, keyed by id.
 // A head is an image which is not the parent of another image in the graph.
 func (graph *Graph) Heads() (map[string]*image.Image, error) {
 	heads := make(map[string]*image.Image)
-	byParent, err := graph.ByParent()
-	if err != nil {
-		return nil, err
+	for id := range graph.parents.Parents() {
+		if graph.HasChildren(id) {
+			continue
+		}
+		if img, err := graph.Get(id); err == nil {
+			heads[id] = img
+		}
 	}
-	err = graph.walkAll(func(image *image.Image) {
-		// If it's not in the byParent lookup table, then
-		// it's not a parent -> so it's a head!
-		if _, exists := byParent[image.ID]; !exists {
-			heads[image.ID] = image
+	return heads, nil
+}
+
+// Children returns the children of the image id, or the images without
+// parent when id is empty.
+func (graph *Graph) Children(id string) ([]*image.Image, error) {
+	var children []*image.Image
+	for _, child := range graph.parents.Children(id) {
+		img, err := graph.Get(child)
+		if err != nil {
+			return nil, err
 		}
-	})
-	return heads, err
+		children = append(children, img)
+	}
+	return children, nil
+}
+
+// HasChildren tells whether another image of the graph has id as parent.
+func (graph *Graph) HasChildren(id string) bool {
+	return len(graph.parents.Children(id)) > 0
 }
 
 func (graph *Graph) ImageRoot(id string) string {
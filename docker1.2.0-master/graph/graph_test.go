@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/utils"
+	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
+)
+
+func TestCreateAtTimestamp(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	timestamp := time.Unix(0, 0)
+	var ids []string
+	for _, mtime := range []time.Time{time.Unix(1000, 0), time.Unix(2000, 0)} {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		tw.WriteHeader(&tar.Header{Name: "file", Uid: os.Getuid(), Gid: os.Getgid(), Size: 4, Mode: 0644, ModTime: mtime})
+		tw.Write([]byte("file"))
+		tw.Close()
+
+		img, err := store.graph.CreateAt(buf, "", "", "", "", nil, nil, timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !img.Created.Equal(timestamp) {
+			t.Fatalf("Expected the image created at %s, got %s", timestamp, img.Created)
+		}
+		ids = append(ids, img.ID)
+	}
+	// The layers are identical once their times are pinned
+	if ids[0] != ids[1] {
+		t.Fatalf("Expected the same image, got %s and %s", ids[0], ids[1])
+	}
+}
@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/docker/docker/pkg/log"
+)
+
+// parentIndex indexes the images of the graph by parent, so that the
+// children of an image are found without loading every image from disk. It
+// is persisted in the graph root, and updated as images are registered and
+// deleted.
+type parentIndex struct {
+	sync.RWMutex
+	path     string
+	parents  map[string]string              //镜像 id 到其父镜像 id 的映射
+	children map[string]map[string]struct{} //父镜像 id 到其子镜像 id 集合的映射，"" 对应没有父镜像的镜像
+}
+
+func newParentIndex(path string) *parentIndex {
+	return &parentIndex{
+		path:     path,
+		parents:  make(map[string]string),
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+// restore loads the index persisted on disk and reconciles it with ids, the
+// images of the graph. The parent of the images missing from the index is
+// looked up with load, and the images no longer in the graph are dropped.
+func (idx *parentIndex) restore(ids []string, load func(id string) (string, error)) error {
+	idx.Lock()
+	defer idx.Unlock()
+	saved := make(map[string]string)
+	if data, err := ioutil.ReadFile(idx.path); err == nil {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			log.Errorf("Rebuilding the corrupted image index %s: %s", idx.path, err)
+			saved = make(map[string]string)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	changed := false
+	for _, id := range ids {
+		parent, exists := saved[id]
+		if !exists {
+			var err error
+			if parent, err = load(id); err != nil {
+				log.Debugf("Couldn't index image %s: %s", id, err)
+				continue
+			}
+			changed = true
+		}
+		idx.set(id, parent)
+	}
+	if changed || len(idx.parents) != len(saved) {
+		return idx.save()
+	}
+	return nil
+}
+
+func (idx *parentIndex) set(id, parent string) {
+	idx.parents[id] = parent
+	if _, exists := idx.children[parent]; !exists {
+		idx.children[parent] = make(map[string]struct{})
+	}
+	idx.children[parent][id] = struct{}{}
+}
+
+// Add indexes the image id with its parent.
+func (idx *parentIndex) Add(id, parent string) error {
+	idx.Lock()
+	defer idx.Unlock()
+	idx.set(id, parent)
+	return idx.save()
+}
+
+// Delete removes the image id from the index.
+func (idx *parentIndex) Delete(id string) error {
+	idx.Lock()
+	defer idx.Unlock()
+	parent, exists := idx.parents[id]
+	if !exists {
+		return nil
+	}
+	delete(idx.parents, id)
+	delete(idx.children[parent], id)
+	if len(idx.children[parent]) == 0 {
+		delete(idx.children, parent)
+	}
+	return idx.save()
+}
+
+// Children returns the IDs of the children of the image id, or of the images
+// without parent when id is empty.
+func (idx *parentIndex) Children(id string) []string {
+	idx.RLock()
+	defer idx.RUnlock()
+	children := make([]string, 0, len(idx.children[id]))
+	for child := range idx.children[id] {
+		children = append(children, child)
+	}
+	return children
+}
+
+// Parents returns the IDs of the images indexed, with their parent.
+func (idx *parentIndex) Parents() map[string]string {
+	idx.RLock()
+	defer idx.RUnlock()
+	parents := make(map[string]string, len(idx.parents))
+	for id, parent := range idx.parents {
+		parents[id] = parent
+	}
+	return parents
+}
+
+// save writes the index to disk, atomically.
+func (idx *parentIndex) save() error {
+	data, err := json.Marshal(idx.parents)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(idx.path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(idx.path+".tmp", idx.path)
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/utils"
+)
+
+func TestParentIndex(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	driver, err := graphdriver.GetDriver("vfs", tmp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := NewGraph(path.Join(tmp, "graph"), driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	register := func(parent string) string {
+		img := &image.Image{ID: utils.GenerateRandomID(), Parent: parent}
+		if err := graph.Register(nil, nil, img); err != nil {
+			t.Fatal(err)
+		}
+		return img.ID
+	}
+	base := register("")
+	child := register(base)
+	other := register(base)
+
+	check := func(graph *Graph) {
+		children, err := graph.Children(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(children) != 2 || !graph.HasChildren(base) || graph.HasChildren(child) {
+			t.Fatalf("Expected %s to have 2 children, got %v", base, children)
+		}
+		heads, err := graph.Heads()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, exists := heads[child]; len(heads) != 2 || !exists {
+			t.Fatalf("Expected %s and %s as heads, got %v", child, other, heads)
+		}
+	}
+	check(graph)
+
+	// The index is persisted, and rebuilt if corrupted
+	restored, err := NewGraph(graph.Root, graph.driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(restored)
+	if err := ioutil.WriteFile(path.Join(graph.Root, "_index"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err = NewGraph(graph.Root, graph.driver); err != nil {
+		t.Fatal(err)
+	}
+	check(restored)
+
+	if err := restored.Delete(other); err != nil {
+		t.Fatal(err)
+	}
+	if children, err := restored.Children(base); err != nil || len(children) != 1 || children[0].ID != child {
+		t.Fatalf("Expected %s as only child, got %v: %v", child, children, err)
+	}
+}
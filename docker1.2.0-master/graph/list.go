@@ -11,17 +11,38 @@ import (
 	"github.com/docker/docker/pkg/parsers/filters"
 )
 
+// acceptedImageFilters are the filters of the images job.
+var acceptedImageFilters = map[string]struct{}{
+	"dangling":  {},
+	"label":     {},
+	"reference": {},
+	"before":    {},
+	"since":     {},
+}
+
 func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	var (
 		allImages   map[string]*image.Image
 		err         error
 		filt_tagged = true
+		before      *image.Image
+		since       *image.Image
+		limit       = job.GetenvInt("limit")
+		offset      = job.GetenvInt("offset")
 	)
+	if limit < 0 || offset < 0 {
+		return job.Errorf("Bad parameter: limit and offset can't be negative")
+	}
 
 	imageFilters, err := filters.FromParam(job.Getenv("filters"))
 	if err != nil {
 		return job.Error(err)
 	}
+	for name := range imageFilters {
+		if _, exists := acceptedImageFilters[name]; !exists {
+			return job.Errorf("Invalid filter '%s'", name)
+		}
+	}
 	if i, ok := imageFilters["dangling"]; ok {
 		for _, value := range i {
 			if strings.ToLower(value) == "true" {
@@ -29,6 +50,30 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 			}
 		}
 	}
+	for _, value := range imageFilters["before"] {
+		if before, err = s.LookupImage(value); err != nil {
+			return job.Error(err)
+		}
+	}
+	for _, value := range imageFilters["since"] {
+		if since, err = s.LookupImage(value); err != nil {
+			return job.Error(err)
+		}
+	}
+	references := imageFilters["reference"]
+	matches := func(img *image.Image) bool {
+		if before != nil && !img.Created.Before(before.Created) {
+			return false
+		}
+		if since != nil && !img.Created.After(since.Created) {
+			return false
+		}
+		var labels map[string]string
+		if img.Config != nil {
+			labels = img.Config.Labels
+		}
+		return imageFilters.MatchKVList("label", labels)
+	}
 
 	if job.GetenvBool("all") && filt_tagged {
 		allImages, err = s.graph.Map()
@@ -52,6 +97,10 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 				log.Printf("Warning: couldn't load %s from %s/%s: %s", id, name, tag, err)
 				continue
 			}
+			delete(allImages, id)
+			if !matches(image) || !matchReference(name, tag, references) {
+				continue
+			}
 
 			if out, exists := lookup[id]; exists {
 				if filt_tagged {
@@ -59,7 +108,6 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 				}
 			} else {
 				// get the boolean list for if only the untagged images are requested
-				delete(allImages, id)
 				if filt_tagged {
 					out := &engine.Env{}
 					out.Set("ParentId", image.Parent)
@@ -82,8 +130,11 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	}
 
 	// Display images which aren't part of a repository/tag
-	if job.Getenv("filter") == "" {
+	if job.Getenv("filter") == "" && len(references) == 0 {
 		for _, image := range allImages {
+			if !matches(image) {
+				continue
+			}
 			out := &engine.Env{}
 			out.Set("ParentId", image.Parent)
 			out.SetList("RepoTags", []string{"<none>:<none>"})
@@ -95,9 +146,68 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 		}
 	}
 
+	// The images of the same second are ordered by id, for the pages to
+	// follow each other
+	outs.SetTieKey("Id")
 	outs.ReverseSort()
+	outs.Page(offset, limit)
+
+	if err := s.setImagesUsage(job, outs); err != nil {
+		return job.Error(err)
+	}
 	if _, err := outs.WriteListTo(job.Stdout); err != nil {
 		return job.Error(err)
 	}
 	return engine.StatusOK
 }
+
+// setImagesUsage sets the size each image shares with other images and the
+// number of containers using it, from the images_usage job. Computing them
+// measures every layer, they are only set with the "usage" env, and -1
+// otherwise.
+func (s *TagStore) setImagesUsage(job *engine.Job, outs *engine.Table) error {
+	if !job.GetenvBool("usage") {
+		for _, out := range outs.Data {
+			out.SetInt64("SharedSize", -1)
+			out.SetInt64("Containers", -1)
+		}
+		return nil
+	}
+	usageJob := job.Eng.Job("images_usage")
+	usageJob.SetenvBool("all", true)
+	usage, err := usageJob.Stdout.AddListTable()
+	if err != nil {
+		return err
+	}
+	if err := usageJob.Run(); err != nil {
+		return err
+	}
+	byID := make(map[string]*engine.Env)
+	for _, u := range usage.Data {
+		byID[u.Get("Id")] = u
+	}
+	for _, out := range outs.Data {
+		if u, exists := byID[out.Get("Id")]; exists {
+			out.SetInt64("SharedSize", u.GetInt64("VirtualSize")-u.GetInt64("UniqueSize"))
+			out.SetInt64("Containers", int64(len(u.GetList("Containers"))))
+		}
+	}
+	return nil
+}
+
+// matchReference tells whether name:tag matches one of the glob patterns,
+// matched against the repository name alone or with the tag.
+func matchReference(name, tag string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+		if match, _ := path.Match(pattern, name+":"+tag); match {
+			return true
+		}
+	}
+	return false
+}
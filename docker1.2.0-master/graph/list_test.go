@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
+)
+
+func TestImagesFilters(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	config := &runconfig.Config{Labels: map[string]string{"com.example.tier": "web"}}
+	web, err := store.graph.Create(fakeLayer([]string{"web"}, nil), "", "", "", "", nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("app", "web", web.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	eng := engine.New()
+	eng.Logging = false
+	eng.Register("images", store.CmdImages)
+	list := func(args filters.Args) []string {
+		job := eng.Job("images")
+		param, err := filters.ToParam(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.Setenv("filters", param)
+		outs, err := job.Stdout.AddListTable()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := job.Run(); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, out := range outs.Data {
+			if out.GetInt64("SharedSize") != -1 || out.GetInt64("Containers") != -1 {
+				t.Fatalf("Expected the usage to be unset, got %v", out)
+			}
+			ids = append(ids, out.Get("Id"))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	for _, c := range []struct {
+		filters  filters.Args
+		expected []string
+	}{
+		{filters.Args{"label": {"com.example.tier"}}, []string{web.ID}},
+		{filters.Args{"label": {"com.example.tier=db"}}, nil},
+		{filters.Args{"reference": {"app"}}, []string{web.ID}},
+		{filters.Args{"reference": {testImageName + ":*"}}, []string{testImageID}},
+		{filters.Args{"before": {"app:web"}}, []string{testImageID}},
+		{filters.Args{"since": {testImageName}}, []string{web.ID}},
+		{filters.Args{"dangling": {"true"}}, nil},
+	} {
+		if ids := list(c.filters); len(ids) != len(c.expected) || (len(ids) > 0 && ids[0] != c.expected[0]) {
+			t.Errorf("Expected %v with the filters %v, got %v", c.expected, c.filters, ids)
+		}
+	}
+
+	job := eng.Job("images")
+	job.Setenv("filters", `{"unknown":["1"]}`)
+	if err := job.Run(); err == nil {
+		t.Fatal("Expected an error with an unknown filter")
+	}
+}
@@ -11,11 +11,14 @@ import (
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/utils"
 )
 
 // Loads a set of images into the repository. This is the complementary of ImageExport.
 // The input stream is an uncompressed tar ball containing images and metadata.
+// The progress of the load is streamed to the output, as json with json set.
 func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
+	sf := utils.NewStreamFormatter(job.GetenvBool("json"))
 	tmpImageDir, err := ioutil.TempDir("", "docker-import-")
 	if err != nil {
 		return job.Error(err)
@@ -31,7 +34,7 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 	if err != nil {
 		return job.Error(err)
 	}
-	if _, err := io.Copy(tarFile, job.Stdin); err != nil {
+	if _, err := io.Copy(tarFile, utils.ProgressReader(job.Stdin, 0, job.Stdout, sf, false, "", "Receiving")); err != nil {
 		return job.Error(err)
 	}
 	tarFile.Close()
@@ -64,7 +67,7 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 
 	for _, d := range dirs {
 		if d.IsDir() {
-			if err := s.recursiveLoad(job.Eng, d.Name(), tmpImageDir); err != nil {
+			if err := s.recursiveLoad(job.Eng, d.Name(), tmpImageDir, job.Stdout, sf); err != nil {
 				return job.Error(err)
 			}
 		}
@@ -82,6 +85,7 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 				if err := s.Set(imageName, tag, address, true); err != nil {
 					return job.Error(err)
 				}
+				job.Stdout.Write(sf.FormatStatus("", "Loaded image: %s:%s", imageName, tag))
 			}
 		}
 	} else if !os.IsNotExist(err) {
@@ -91,7 +95,7 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
-func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string) error {
+func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string, out io.Writer, sf *utils.StreamFormatter) error {
 	if err := eng.Job("image_get", address).Run(); err != nil {
 		log.Debugf("Loading %s", address)
 
@@ -106,6 +110,11 @@ func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string
 			log.Debugf("Error reading embedded tar", err)
 			return err
 		}
+		defer layer.Close()
+		st, err := layer.Stat()
+		if err != nil {
+			return err
+		}
 		img, err := image.NewImgJSON(imageJson)
 		if err != nil {
 			log.Debugf("Error unmarshalling json", err)
@@ -113,14 +122,15 @@ func (s *TagStore) recursiveLoad(eng *engine.Engine, address, tmpImageDir string
 		}
 		if img.Parent != "" {
 			if !s.graph.Exists(img.Parent) {
-				if err := s.recursiveLoad(eng, img.Parent, tmpImageDir); err != nil {
+				if err := s.recursiveLoad(eng, img.Parent, tmpImageDir, out, sf); err != nil {
 					return err
 				}
 			}
 		}
-		if err := s.graph.Register(imageJson, layer, img); err != nil {
+		if err := s.graph.Register(imageJson, utils.ProgressReader(layer, int(st.Size()), out, sf, false, utils.TruncateID(img.ID), "Loading layer"), img); err != nil {
 			return err
 		}
+		out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Load complete", nil))
 	}
 	log.Debugf("Completed processing %s", address)
 
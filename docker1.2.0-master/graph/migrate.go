@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/docker/docker/archive"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/utils"
+)
+
+// MigrateLayer copies the layer id, created on top of parent, from the driver
+// src to the driver dst. The layer is removed from dst if the copy fails.
+func MigrateLayer(src, dst graphdriver.Driver, id, parent string, sf *utils.StreamFormatter, out io.Writer) (err error) {
+	layer, err := image.ExportLayer(src, id, parent)
+	if err != nil {
+		return fmt.Errorf("Error exporting layer %s from %s: %s", id, src, err)
+	}
+	defer layer.Close()
+
+	if err := dst.Create(id, parent); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Remove(id)
+		}
+	}()
+	progress := utils.ProgressReader(layer, 0, out, sf, false, utils.TruncateID(id), "Migrating")
+	if differ, ok := dst.(graphdriver.Differ); ok {
+		return differ.ApplyDiff(id, progress)
+	}
+	dir, err := dst.Get(id, "")
+	if err != nil {
+		return err
+	}
+	defer dst.Put(id)
+	return archive.ApplyLayer(dir, progress)
+}
+
+// MigrateLayers copies the layers of the images stored in the graph from the
+// driver src to the driver of the graph, parents first, and makes the images
+// available. The images already in the driver of the graph or not in src are
+// skipped. It returns the IDs of the images migrated, even on error, for the
+// caller to roll back with RemoveLayers.
+func (graph *Graph) MigrateLayers(src graphdriver.Driver, sf *utils.StreamFormatter, out io.Writer) ([]string, error) {
+	// The images of other drivers are not indexed, load them from disk
+	files, err := ioutil.ReadDir(graph.Root)
+	if err != nil {
+		return nil, err
+	}
+	images := make(map[string]*image.Image)
+	for _, st := range files {
+		if img, err := image.LoadImage(graph.ImageRoot(st.Name())); err == nil {
+			images[img.ID] = img
+		}
+	}
+	var (
+		pending  []*image.Image
+		depths   = make(map[string]int)
+		migrated []string
+	)
+	for id, img := range images {
+		if graph.driver.Exists(id) {
+			continue
+		}
+		if !src.Exists(id) {
+			log.Debugf("Image %s not found in %s, skipping it", id, src)
+			continue
+		}
+		pending = append(pending, img)
+		for p := img; p != nil && p.Parent != ""; p = images[p.Parent] {
+			depths[id]++
+		}
+	}
+	sort.Sort(byDepth{pending, depths})
+
+	for i, img := range pending {
+		if img.Parent != "" && !graph.driver.Exists(img.Parent) {
+			return migrated, fmt.Errorf("Parent %s of image %s is missing from %s", img.Parent, img.ID, graph.driver)
+		}
+		if err := MigrateLayer(src, graph.driver, img.ID, img.Parent, sf, out); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, img.ID)
+		if err := graph.idIndex.Add(img.ID); err != nil {
+			return migrated, err
+		}
+		if err := graph.parents.Add(img.ID, img.Parent); err != nil {
+			return migrated, err
+		}
+		out.Write(sf.FormatStatus(utils.TruncateID(img.ID), "Image migrated (%d/%d)", i+1, len(pending)))
+	}
+	return migrated, nil
+}
+
+// RemoveLayers removes the layers of the images ids from the driver of the
+// graph, in reverse order, keeping their metadata for the driver they were
+// migrated from.
+func (graph *Graph) RemoveLayers(ids []string) error {
+	for i := len(ids) - 1; i >= 0; i-- {
+		graph.idIndex.Delete(ids[i])
+		if err := graph.parents.Delete(ids[i]); err != nil {
+			return err
+		}
+		if err := graph.driver.Remove(ids[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// byDepth sorts the images from the ones without parents.
+type byDepth struct {
+	images []*image.Image
+	depths map[string]int
+}
+
+func (s byDepth) Len() int      { return len(s.images) }
+func (s byDepth) Swap(i, j int) { s.images[i], s.images[j] = s.images[j], s.images[i] }
+func (s byDepth) Less(i, j int) bool {
+	return s.depths[s.images[i].ID] < s.depths[s.images[j].ID]
+}
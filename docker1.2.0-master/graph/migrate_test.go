@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
+)
+
+func TestMigrateLayers(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	src, err := graphdriver.GetDriver("vfs", path.Join(tmp, "src"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := graphdriver.GetDriver("vfs", path.Join(tmp, "dst"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := NewGraph(path.Join(tmp, "graph"), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := old.Create(fakeLayer([]string{"passwd", "hosts"}, nil), "", "", "", "", nil, &runconfig.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := old.Create(fakeLayer([]string{"app"}, []string{"hosts"}), "fake", base.ID, "", "", &runconfig.Config{}, &runconfig.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := NewGraph(path.Join(tmp, "graph"), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := utils.NewStreamFormatter(false)
+	migrated, err := graph.MigrateLayers(src, sf, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 2 || migrated[0] != base.ID || migrated[1] != child.ID {
+		t.Fatalf("Expected the parent to be migrated first, got %v", migrated)
+	}
+
+	dir, err := dst.Get(child.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Put(child.ID)
+	for name, expected := range map[string]bool{
+		"app":    true,
+		"passwd": true,
+		"hosts":  false,
+	} {
+		if _, err := os.Stat(path.Join(dir, name)); (err == nil) != expected {
+			t.Errorf("Expected %s to exist: %v", name, expected)
+		}
+	}
+	if !src.Exists(child.ID) {
+		t.Fatal("Expected the layers to be kept in the former driver")
+	}
+	if _, err := graph.Get(child.ID); err != nil {
+		t.Fatalf("Expected the migrated image to be available: %s", err)
+	}
+
+	// The layers already migrated are skipped
+	if migrated, err = graph.MigrateLayers(src, sf, ioutil.Discard); err != nil || len(migrated) != 0 {
+		t.Fatalf("Expected nothing to migrate, got %v: %v", migrated, err)
+	}
+
+	if err := graph.RemoveLayers([]string{base.ID, child.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Exists(base.ID) || graph.Exists(child.ID) {
+		t.Fatal("Expected the migrated layers to be removed")
+	}
+	if _, err := os.Stat(graph.ImageRoot(child.ID)); err != nil {
+		t.Fatalf("Expected the image metadata to be kept: %s", err)
+	}
+}
@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/docker/docker/pkg/log"
+)
+
+// partialSyncSize is the amount of data written to a partial layer between
+// two saves of its offset.
+const partialSyncSize = 4 * 1024 * 1024
+
+// partialState is saved next to a partial layer.
+type partialState struct {
+	Source string // where the layer is downloaded from
+	Offset int64  // bytes of the layer synced to disk
+}
+
+// partialLayer is a layer being downloaded to the _partial directory of the
+// graph. The offset up to which its data is synced to disk is saved with
+// it, so that a pull interrupted, even by the daemon going down, resumes
+// from there rather than from zero.
+type partialLayer struct {
+	*os.File
+	dir    string
+	source string
+	size   int64
+	synced int64
+}
+
+// openPartialLayer opens the partial layer of the image id downloaded from
+// source, positioned at the offset saved by a previous download from the
+// same source, if any.
+func (graph *Graph) openPartialLayer(id, source string) (*partialLayer, error) {
+	dir := path.Join(graph.Root, "_partial", id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	var state partialState
+	if data, err := ioutil.ReadFile(path.Join(dir, "state")); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			log.Debugf("Ignoring the invalid state of partial layer %s: %s", id, err)
+			state = partialState{}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path.Join(dir, "layer.tar"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	if state.Source == source {
+		offset = state.Offset
+	}
+	if st, err := f.Stat(); err != nil {
+		f.Close()
+		return nil, err
+	} else if st.Size() < offset {
+		offset = st.Size()
+	}
+	// Only keep what is known to be synced
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &partialLayer{File: f, dir: dir, source: source, size: offset, synced: offset}, nil
+}
+
+func (l *partialLayer) Write(p []byte) (int, error) {
+	n, err := l.File.Write(p)
+	l.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if l.size-l.synced >= partialSyncSize {
+		if err := l.sync(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Offset returns the number of bytes of the layer downloaded.
+func (l *partialLayer) Offset() int64 {
+	return l.size
+}
+
+// Reset discards the data downloaded, to start over.
+func (l *partialLayer) Reset() error {
+	if err := l.File.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.File.Seek(0, 0); err != nil {
+		return err
+	}
+	l.size = 0
+	return l.sync()
+}
+
+// sync syncs the data written to disk and saves its offset.
+func (l *partialLayer) sync() error {
+	if err := l.File.Sync(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(&partialState{Source: l.source, Offset: l.size})
+	if err != nil {
+		return err
+	}
+	tmp := path.Join(l.dir, "state.tmp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path.Join(l.dir, "state")); err != nil {
+		return err
+	}
+	l.synced = l.size
+	return nil
+}
+
+// Close saves the offset of the layer and closes it, keeping it to resume
+// the download later.
+func (l *partialLayer) Close() error {
+	err := l.sync()
+	if cerr := l.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Remove closes the layer and removes it.
+func (l *partialLayer) Remove() error {
+	l.File.Close()
+	return os.RemoveAll(l.dir)
+}
@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/docker/utils"
+)
+
+func TestPartialLayerResume(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	graph := &Graph{Root: tmp}
+
+	partial, err := graph.openPartialLayer(testImageID, "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial.Offset() != 0 {
+		t.Fatalf("Expected a new partial layer, got offset %d", partial.Offset())
+	}
+	if _, err := partial.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if err := partial.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Data written after the last sync is dropped, as if the daemon went down
+	if partial, err = graph.openPartialLayer(testImageID, "source"); err != nil {
+		t.Fatal(err)
+	}
+	if partial.Offset() != 10 {
+		t.Fatalf("Expected to resume at offset 10, got %d", partial.Offset())
+	}
+	if _, err := partial.Write([]byte("unsynced")); err != nil {
+		t.Fatal(err)
+	}
+	partial.File.Close()
+
+	if partial, err = graph.openPartialLayer(testImageID, "source"); err != nil {
+		t.Fatal(err)
+	}
+	if partial.Offset() != 10 {
+		t.Fatalf("Expected to resume at offset 10, got %d", partial.Offset())
+	}
+	if _, err := partial.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partial.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789abc" {
+		t.Fatalf("Unexpected partial layer %q", data)
+	}
+	if err := partial.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A download from another source starts over
+	if partial, err = graph.openPartialLayer(testImageID, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if partial.Offset() != 0 {
+		t.Fatalf("Expected to start over for another source, got offset %d", partial.Offset())
+	}
+	if err := partial.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(partial.dir); !os.IsNotExist(err) {
+		t.Fatal("Expected the partial layer to be removed")
+	}
+}
@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -11,10 +12,14 @@ import (
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/utils"
 )
 
+// errPullCanceled is returned by the pulls whose request was canceled.
+var errPullCanceled = errors.New("Pull canceled")
+
 func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 	if n := len(job.Args); n != 1 && n != 2 {
 		return job.Errorf("Usage: %s IMAGE [TAG]", job.Name)
@@ -51,12 +56,38 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
-	endpoint, err := registry.ExpandAndVerifyRegistryUrl(hostname)
+	secure := registry.IsSecure(hostname, s.insecureRegistries)
+
+	// Registries speaking the v2 protocol are preferred, falling back to v1.
+	// Digests reference v1 image IDs, which v2 manifests don't.
+	var v2Err error
+	if !image.IsDigest(tag) {
+		if r, err := registry.NewV2Session(authConfig, registry.HTTPRequestFactory(metaHeaders), hostname, true, secure); err != nil {
+			log.Debugf("v2 registry unavailable for %s: %s", hostname, err)
+		} else {
+			v2Name, v2LocalName := remoteName, localName
+			if hostname == registry.IndexServerAddress() {
+				v2Name, v2LocalName = registry.NormalizeV2Name(remoteName), remoteName
+			}
+			if v2Err = s.pullV2Repository(r, job.Stdout, v2LocalName, v2Name, tag, sf, job.Canceled()); v2Err == nil {
+				return engine.StatusOK
+			}
+			if job.IsCanceled() {
+				return job.Error(v2Err)
+			}
+			log.Errorf("Error from v2 registry %s: %s", r.Endpoint(), v2Err)
+		}
+	}
+
+	endpoint, err := registry.ExpandAndVerifyRegistryUrl(hostname, secure)
 	if err != nil {
+		if v2Err != nil {
+			return job.Error(v2Err)
+		}
 		return job.Error(err)
 	}
 	//创建session
-	r, err := registry.NewSession(authConfig, registry.HTTPRequestFactory(metaHeaders), endpoint, true)
+	r, err := registry.NewSession(authConfig, registry.HTTPRequestFactory(metaHeaders), endpoint, true, secure)
 	if err != nil {
 		return job.Error(err)
 	}
@@ -66,14 +97,14 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 		localName = remoteName
 	}
 
-	if err = s.pullRepository(r, job.Stdout, localName, remoteName, tag, sf, job.GetenvBool("parallel")); err != nil {
+	if err = s.pullRepository(r, job.Stdout, localName, remoteName, tag, sf, job.GetenvBool("parallel"), job.Canceled()); err != nil {
 		return job.Error(err)
 	}
 
 	return engine.StatusOK
 }
 
-func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, localName, remoteName, askedTag string, sf *utils.StreamFormatter, parallel bool) error {
+func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, localName, remoteName, askedTag string, sf *utils.StreamFormatter, parallel bool, canceled <-chan struct{}) error {
 	out.Write(sf.FormatStatus("", "Pulling repository %s", localName))
 
 	repoData, err := r.GetRepositoryData(remoteName)
@@ -107,6 +138,16 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, localName,
 		for tag, id := range tagsList {
 			repoData.ImgList[id].Tag = tag
 		}
+	} else if image.IsDigest(askedTag) {
+		// A digest may reference any image of the repository, tagged or not
+		id, err := image.ParseDigest(askedTag)
+		if err != nil {
+			return err
+		}
+		if _, exists := repoData.ImgList[id]; !exists {
+			return fmt.Errorf("Digest %s not found in repository %s", askedTag, localName)
+		}
+		repoData.ImgList[id].Tag = askedTag
 	} else {
 		// Otherwise, check that the tag exists and use only that one
 		id, exists := tagsList[askedTag]
@@ -119,6 +160,12 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, localName,
 	errors := make(chan error)
 	for _, image := range repoData.ImgList {
 		downloadImage := func(img *registry.ImgData) {
+			if isCanceled(canceled) {
+				if parallel {
+					errors <- errPullCanceled
+				}
+				return
+			}
 			if askedTag != "" && img.Tag != askedTag {
 				log.Debugf("(%s) does not match %s (id: %s), skipping", img.Tag, askedTag, img.ID)
 				if parallel {
@@ -156,7 +203,7 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, localName,
 			var lastErr error
 			for _, ep := range repoData.Endpoints {
 				out.Write(sf.FormatProgress(utils.TruncateID(img.ID), fmt.Sprintf("Pulling image (%s) from %s, endpoint: %s", img.Tag, localName, ep), nil))
-				if err := s.pullImage(r, out, img.ID, ep, repoData.Tokens, sf); err != nil {
+				if err := s.pullImage(r, out, img.ID, ep, repoData.Tokens, sf, canceled); err != nil {
 					// It's not ideal that only the last error is returned, it would be better to concatenate the errors.
 					// As the error is also given to the output stream the user will see the error.
 					lastErr = err
@@ -199,6 +246,22 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, localName,
 		}
 
 	}
+	if isCanceled(canceled) {
+		return errPullCanceled
+	}
+	if image.IsDigest(askedTag) {
+		// The layer and json of the image were verified when registered,
+		// make sure the registry didn't serve a random ID instead
+		id, _ := image.ParseDigest(askedTag)
+		img, err := s.graph.Get(id)
+		if err != nil {
+			return err
+		}
+		if img.Digest() != askedTag {
+			return fmt.Errorf("Image %s is not content addressable", id)
+		}
+		return nil
+	}
 	for tag, id := range tagsList {
 		if askedTag != "" && tag != askedTag {
 			continue
@@ -212,95 +275,195 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, localName,
 	return nil
 }
 
-func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint string, token []string, sf *utils.StreamFormatter) error {
+func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint string, token []string, sf *utils.StreamFormatter, canceled <-chan struct{}) error {
 	history, err := r.GetRemoteHistory(imgID, endpoint, token) //获取历史镜像（所有父镜像layer）
 	if err != nil {
 		return err
 	}
 	out.Write(sf.FormatProgress(utils.TruncateID(imgID), "Pulling dependent layers", nil))
-	// FIXME: Try to stream the images?
-	// FIXME: Launch the getRemoteImage() in goroutines
 
+	layers := make([]*layerDownload, 0, len(history))
 	for i := len(history) - 1; i >= 0; i-- {
 		id := history[i]
+		layers = append(layers, &layerDownload{
+			id:     id,
+			source: endpoint + "images/" + id + "/layer",
+			download: func(partial *partialLayer) (*image.Image, []byte, error) {
+				return s.downloadV1Layer(r, out, id, endpoint, token, sf, partial)
+			},
+		})
+	}
+	return s.pullLayers(out, sf, layers, canceled)
+}
 
-		// ensure no two downloads of the same layer happen at the same time
-		if c, err := s.poolAdd("pull", "layer:"+id); err != nil {
-			log.Debugf("Image (id: %s) pull is already running, skipping: %v", id, err)
-			<-c
+// downloadV1Layer downloads the json of the image id and the rest of its
+// layer to partial.
+func (s *TagStore) downloadV1Layer(r *registry.Session, out io.Writer, id, endpoint string, token []string, sf *utils.StreamFormatter, partial *partialLayer) (*image.Image, []byte, error) {
+	out.Write(sf.FormatProgress(utils.TruncateID(id), "Pulling metadata", nil))
+	var (
+		imgJSON []byte
+		imgSize int
+		err     error
+		img     *image.Image
+	)
+	retries := 5
+	for j := 1; j <= retries; j++ {
+		//GetRemoteImageJSON 返回的对象 imgJSON 代表 image json 信息， imgSize 代表镜像
+		//的大小。通过 imgJSON 对象， Docker Daemon 立即创建一个 image 对象
+		imgJSON, imgSize, err = r.GetRemoteImageJSON(id, endpoint, token)
+		if err != nil && j == retries {
+			return nil, nil, err
+		} else if err != nil {
+			time.Sleep(time.Duration(j) * 500 * time.Millisecond)
+			continue
 		}
-		defer s.poolRemove("pull", "layer:"+id)
-
-		if !s.graph.Exists(id) {
-			out.Write(sf.FormatProgress(utils.TruncateID(id), "Pulling metadata", nil))
-			var (
-				imgJSON []byte
-				imgSize int
-				err     error
-				img     *image.Image
-			)
-			retries := 5
-			for j := 1; j <= retries; j++ {
-				//GetRemoteImageJSON 返回的对象 imgJSON 代表 image json 信息， imgSize 代表镜像
-				//的大小。通过 imgJSON 对象， Docker Daemon 立即创建一个 image 对象
-				imgJSON, imgSize, err = r.GetRemoteImageJSON(id, endpoint, token)
-				if err != nil && j == retries {
-					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
-					return err
-				} else if err != nil {
-					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
-					continue
-				}
-				img, err = image.NewImgJSON(imgJSON)
-				if err != nil && j == retries {
-					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
-					return fmt.Errorf("Failed to parse json: %s", err)
-				} else if err != nil {
-					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
-					continue
-				} else {
-					break
-				}
-			}
+		img, err = image.NewImgJSON(imgJSON)
+		if err != nil && j == retries {
+			return nil, nil, fmt.Errorf("Failed to parse json: %s", err)
+		} else if err != nil {
+			time.Sleep(time.Duration(j) * 500 * time.Millisecond)
+			continue
+		} else {
+			break
+		}
+	}
 
-			for j := 1; j <= retries; j++ {
-				// Get the layer
-				status := "Pulling fs layer"
-				if j > 1 {
-					status = fmt.Sprintf("Pulling fs layer [retries: %d]", j)
-				}
-				out.Write(sf.FormatProgress(utils.TruncateID(id), status, nil))
-				//下载镜像 layer 的内容
-				layer, err := r.GetRemoteImageLayer(img.ID, endpoint, token, int64(imgSize))
-				if uerr, ok := err.(*url.Error); ok {
-					err = uerr.Err
-				}
-				if terr, ok := err.(net.Error); ok && terr.Timeout() && j < retries {
-					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
-					continue
-				} else if err != nil {
-					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
-					return err
-				}
-				defer layer.Close()
-
-				//向graph 中注册 image
-				err = s.graph.Register(imgJSON,
-					utils.ProgressReader(layer, imgSize, out, sf, false, utils.TruncateID(id), "Downloading"),
-					img)
-				if terr, ok := err.(net.Error); ok && terr.Timeout() && j < retries {
-					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
-					continue
-				} else if err != nil {
-					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error downloading dependent layers", nil))
-					return err
-				} else {
-					break
-				}
+	if imgSize > 0 && partial.Offset() > int64(imgSize) {
+		if err := partial.Reset(); err != nil {
+			return nil, nil, err
+		}
+	}
+	for j := 1; j <= retries; j++ {
+		// Get the layer
+		status := "Pulling fs layer"
+		if j > 1 {
+			status = fmt.Sprintf("Pulling fs layer [retries: %d]", j)
+		}
+		out.Write(sf.FormatProgress(utils.TruncateID(id), status, nil))
+		//下载镜像 layer 的内容
+		offset := partial.Offset()
+		layer, err := r.GetRemoteImageLayer(img.ID, endpoint, token, int64(imgSize), offset)
+		if err == nil {
+			_, err = io.Copy(partial, utils.ProgressReader(layer, imgSize-int(offset), out, sf, false, utils.TruncateID(id), "Downloading"))
+			layer.Close()
+		}
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		if terr, ok := err.(net.Error); ok && terr.Timeout() && j < retries {
+			// Resume from what was downloaded
+			time.Sleep(time.Duration(j) * 500 * time.Millisecond)
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+	return img, imgJSON, nil
+}
+
+// layerDownload is a layer of an image to pull.
+type layerDownload struct {
+	id string
+	// source identifies where the layer is downloaded from, to only resume
+	// the partial layer of a download from the same source
+	source string
+	// download writes the rest of the layer to partial and returns its
+	// image and json
+	download func(partial *partialLayer) (*image.Image, []byte, error)
+}
+
+// pullLayers pulls the layers, listed from the base up, missing from the
+// graph. They are downloaded in parallel, up to the download limit of the
+// daemon, and registered once their parent is. No layer is started once
+// canceled is closed, but those started are still waited for.
+func (s *TagStore) pullLayers(out io.Writer, sf *utils.StreamFormatter, layers []*layerDownload, canceled <-chan struct{}) error {
+	var (
+		transfers []*transfer
+		parent    *transfer
+		lastErr   error
+	)
+	for _, l := range layers {
+		if isCanceled(canceled) {
+			lastErr = errPullCanceled
+			break
+		}
+		if s.graph.Exists(l.id) {
+			out.Write(sf.FormatProgress(utils.TruncateID(l.id), "Already exists", nil))
+			parent = nil
+			continue
+		}
+		t, running := s.downloads.start(l.id, s.downloadLayer(out, sf, l, parent))
+		if running {
+			out.Write(sf.FormatProgress(utils.TruncateID(l.id), "Layer already being pulled by another client. Waiting.", nil))
+		}
+		transfers = append(transfers, t)
+		parent = t
+	}
+
+	// Wait for all the transfers, so that none is left behind on errors
+	for _, t := range transfers {
+		if err := t.Wait(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (s *TagStore) downloadLayer(out io.Writer, sf *utils.StreamFormatter, l *layerDownload, parent *transfer) func() (string, error) {
+	return func() (string, error) {
+		if s.graph.Exists(l.id) {
+			return "", nil
+		}
+		partial, err := s.graph.openPartialLayer(l.id, l.source)
+		if err != nil {
+			return "", err
+		}
+		if offset := partial.Offset(); offset > 0 {
+			out.Write(sf.FormatProgress(utils.TruncateID(l.id), fmt.Sprintf("Resuming download at %s", units.HumanSize(offset)), nil))
+		}
+
+		s.downloads.acquire(func() {
+			out.Write(sf.FormatProgress(utils.TruncateID(l.id), "Waiting", nil))
+		})
+		img, imgJSON, err := l.download(partial)
+		s.downloads.release()
+		if err != nil {
+			// Keep what was downloaded for the next pull to resume it
+			partial.Close()
+			out.Write(sf.FormatProgress(utils.TruncateID(l.id), "Error pulling dependent layers", nil))
+			return "", err
+		}
+		out.Write(sf.FormatProgress(utils.TruncateID(l.id), "Download complete", nil))
+
+		if parent != nil {
+			if err := parent.Wait(); err != nil {
+				partial.Close()
+				return "", err
 			}
 		}
-		out.Write(sf.FormatProgress(utils.TruncateID(id), "Download complete", nil))
+		defer partial.Remove()
+		size := partial.Offset()
+		if _, err := partial.Seek(0, 0); err != nil {
+			return "", err
+		}
+		//向graph 中注册 image
+		if err := s.graph.Register(imgJSON, utils.ProgressReader(partial, int(size), out, sf, false, utils.TruncateID(l.id), "Extracting"), img); err != nil {
+			out.Write(sf.FormatProgress(utils.TruncateID(l.id), "Error downloading dependent layers", nil))
+			return "", err
+		}
+		out.Write(sf.FormatProgress(utils.TruncateID(l.id), "Pull complete", nil))
+		return "", nil
+	}
+}
 
+// isCanceled returns true if the pull, whose job is given canceled, was
+// canceled.
+func isCanceled(canceled <-chan struct{}) bool {
+	select {
+	case <-canceled:
+		return true
+	default:
+		return false
 	}
-	return nil
 }
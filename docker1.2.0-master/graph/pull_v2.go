@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/utils"
+)
+
+func (s *TagStore) pullV2Repository(r *registry.Session, out io.Writer, localName, remoteName, askedTag string, sf *utils.StreamFormatter, canceled <-chan struct{}) error {
+	var tags []string
+	if askedTag == "" {
+		var err error
+		if tags, err = r.GetV2RemoteTags(remoteName); err != nil {
+			return err
+		}
+	} else {
+		tags = []string{askedTag}
+	}
+
+	for _, tag := range tags {
+		if isCanceled(canceled) {
+			return errPullCanceled
+		}
+		out.Write(sf.FormatStatus("", "Pulling %s:%s from %s", localName, tag, r.Endpoint()))
+		id, err := s.pullV2Tag(r, out, remoteName, tag, sf, canceled)
+		if err != nil {
+			return err
+		}
+		if err := s.Set(localName, tag, id, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pullV2Tag pulls the layers of the manifest of tag and returns the ID of
+// the image.
+func (s *TagStore) pullV2Tag(r *registry.Session, out io.Writer, remoteName, tag string, sf *utils.StreamFormatter, canceled <-chan struct{}) (string, error) {
+	manifest, err := r.GetV2ImageManifest(remoteName, tag)
+	if err != nil {
+		return "", err
+	}
+	if len(manifest.FSLayers) == 0 {
+		return "", fmt.Errorf("No layers in the manifest of %s:%s", remoteName, tag)
+	}
+	if arch := image.NormalizeArch(manifest.Architecture); arch != "" && arch != runtime.GOARCH {
+		out.Write(sf.FormatStatus("", "Warning: %s:%s is built for the %s architecture, containers can't be created from it on %s", remoteName, tag, arch, runtime.GOARCH))
+	}
+
+	// The manifest lists the image first and its base last
+	var (
+		layers = make([]*layerDownload, len(manifest.FSLayers))
+		parent string
+	)
+	for i := len(manifest.FSLayers) - 1; i >= 0; i-- {
+		imgJSON := []byte(manifest.History[i].V1Compatibility)
+		img, err := image.NewImgJSON(imgJSON)
+		if err != nil {
+			return "", fmt.Errorf("Failed to parse json: %s", err)
+		}
+		if img.Parent != parent {
+			return "", fmt.Errorf("Invalid manifest for %s:%s: %s is not the parent of %s", remoteName, tag, parent, img.ID)
+		}
+		parent = img.ID
+		blobSum := manifest.FSLayers[i].BlobSum
+		layers[len(layers)-1-i] = &layerDownload{
+			id:     img.ID,
+			source: blobSum,
+			download: func(partial *partialLayer) (*image.Image, []byte, error) {
+				if err := s.downloadV2Blob(r, out, remoteName, img.ID, blobSum, sf, partial); err != nil {
+					return nil, nil, err
+				}
+				return img, imgJSON, nil
+			},
+		}
+	}
+	if err := s.pullLayers(out, sf, layers, canceled); err != nil {
+		return "", err
+	}
+	return parent, nil
+}
+
+// downloadV2Blob downloads the rest of the blob of the image id to partial,
+// verifying the digest of the whole blob.
+func (s *TagStore) downloadV2Blob(r *registry.Session, out io.Writer, remoteName, id, blobSum string, sf *utils.StreamFormatter, partial *partialLayer) error {
+	verifier, expected, err := newBlobVerifier(blobSum)
+	if err != nil {
+		return err
+	}
+	offset := partial.Offset()
+	if _, err := io.Copy(verifier, io.NewSectionReader(partial.File, 0, offset)); err != nil {
+		return err
+	}
+	out.Write(sf.FormatProgress(utils.TruncateID(id), "Pulling fs layer", nil))
+	blob, size, err := r.GetV2ImageBlobReader(remoteName, blobSum, offset)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	reader := utils.ProgressReader(blob, int(size-offset), out, sf, false, utils.TruncateID(id), "Downloading")
+	if _, err := io.Copy(io.MultiWriter(partial, verifier), reader); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(verifier.Sum(nil)); sum != expected {
+		// Don't resume from corrupted data
+		if err := partial.Reset(); err != nil {
+			return err
+		}
+		return fmt.Errorf("Layer of image %s doesn't match its digest %s", id, blobSum)
+	}
+	out.Write(sf.FormatProgress(utils.TruncateID(id), "Verifying Checksum", nil))
+	return nil
+}
+
+// newBlobVerifier returns a hash computing the digest blobSum and the hex
+// sum expected.
+func newBlobVerifier(blobSum string) (hash.Hash, string, error) {
+	parts := strings.SplitN(blobSum, ":", 2)
+	if len(parts) != 2 || parts[0] != "sha256" {
+		return nil, "", fmt.Errorf("Unsupported blob digest %s", blobSum)
+	}
+	return sha256.New(), parts[1], nil
+}
@@ -9,23 +9,54 @@ import (
 
 	"github.com/docker/docker/archive"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/utils"
 )
 
-// Retrieve the all the images to be uploaded in the correct order
-func (s *TagStore) getImageList(localRepo map[string]string, requestedTag string) ([]string, map[string][]string, error) {
+// Retrieve the all the images to be uploaded in the correct order. Only the
+// requested tags are pushed, or every tag of the repository when none is
+// requested, and the images shared by several tags are uploaded once.
+func (s *TagStore) getImageList(localRepo map[string]string, requestedTags []string) ([]string, map[string][]string, error) {
 	var (
 		imageList   []string
 		imagesSeen  map[string]bool     = make(map[string]bool)
 		tagsByImage map[string][]string = make(map[string][]string)
+		requested   map[string]bool     = make(map[string]bool)
 	)
 
-	for tag, id := range localRepo {
-		if requestedTag != "" && requestedTag != tag {
+	for _, requestedTag := range requestedTags {
+		requested[requestedTag] = false
+		if !image.IsDigest(requestedTag) {
 			continue
 		}
+		// Pushing by digest pushes every tag of that image
+		id, err := image.ParseDigest(requestedTag)
+		if err != nil {
+			return nil, nil, err
+		}
+		if img, err := s.graph.Get(id); err != nil {
+			return nil, nil, err
+		} else if img.Digest() != requestedTag {
+			return nil, nil, fmt.Errorf("Image %s is not content addressable", id)
+		}
+	}
+
+	for tag, id := range localRepo {
+		if len(requested) > 0 {
+			_, byTag := requested[tag]
+			_, byDigest := requested[image.DigestPrefix+id]
+			if !byTag && !byDigest {
+				continue
+			}
+			if byTag {
+				requested[tag] = true
+			}
+			if byDigest {
+				requested[image.DigestPrefix+id] = true
+			}
+		}
 		var imageListForThisTag []string
 
 		tagsByImage[id] = append(tagsByImage[id], tag)
@@ -55,16 +86,21 @@ func (s *TagStore) getImageList(localRepo map[string]string, requestedTag string
 	if len(imageList) == 0 {
 		return nil, nil, fmt.Errorf("No images found for the requested repository / tag")
 	}
+	for requestedTag, found := range requested {
+		if !found {
+			return nil, nil, fmt.Errorf("Tag %s not found in the repository", requestedTag)
+		}
+	}
 	log.Debugf("Image list: %v", imageList)
 	log.Debugf("Tags by image: %v", tagsByImage)
 
 	return imageList, tagsByImage, nil
 }
 
-func (s *TagStore) pushRepository(r *registry.Session, out io.Writer, localName, remoteName string, localRepo map[string]string, tag string, sf *utils.StreamFormatter) error {
+func (s *TagStore) pushRepository(r *registry.Session, out io.Writer, localName, remoteName string, localRepo map[string]string, tags []string, sf *utils.StreamFormatter) error {
 	out = utils.NewWriteFlusher(out)
 	log.Debugf("Local repo: %s", localRepo)
-	imgList, tagsByImage, err := s.getImageList(localRepo, tag)
+	imgList, tagsByImage, err := s.getImageList(localRepo, tags)
 	if err != nil {
 		return err
 	}
@@ -109,9 +145,9 @@ func (s *TagStore) pushRepository(r *registry.Session, out io.Writer, localName,
 		return err
 	}
 
-	nTag := 1
-	if tag == "" {
-		nTag = len(localRepo)
+	nTag := 0
+	for _, tags := range tagsByImage {
+		nTag += len(tags)
 	}
 	for _, ep := range repoData.Endpoints {
 		out.Write(sf.FormatStatus("", "Pushing repository %s (%d tags)", localName, nTag))
@@ -164,7 +200,8 @@ func (s *TagStore) pushImage(r *registry.Session, out io.Writer, remote, imgID,
 		return "", err
 	}
 
-	layerData, err := s.graph.TempLayerArchive(imgID, archive.Uncompressed, sf, out)
+	// The layer gets compressed while being pushed
+	layerData, err := s.graph.TempLayerArchive(imgID, archive.Uncompressed, 0, sf, out)
 	if err != nil {
 		return "", fmt.Errorf("Failed to generate layer archive: %s", err)
 	}
@@ -173,7 +210,7 @@ func (s *TagStore) pushImage(r *registry.Session, out io.Writer, remote, imgID,
 	// Send the layer
 	log.Debugf("rendered layer for %s of [%d] size", imgData.ID, layerData.Size)
 
-	checksum, checksumPayload, err := r.PushImageLayerRegistry(imgData.ID, utils.ProgressReader(layerData, int(layerData.Size), out, sf, false, utils.TruncateID(imgData.ID), "Pushing"), ep, token, jsonRaw)
+	checksum, checksumPayload, err := r.PushImageLayerRegistry(imgData.ID, utils.ProgressReader(layerData, int(layerData.Size), out, sf, false, utils.TruncateID(imgData.ID), "Pushing"), ep, token, jsonRaw, s.layerCompression, s.compressionLevel)
 	if err != nil {
 		return "", err
 	}
@@ -200,7 +237,15 @@ func (s *TagStore) CmdPush(job *engine.Job) engine.Status {
 		metaHeaders map[string][]string
 	)
 
-	tag := job.Getenv("tag")
+	// The tags pushed together share the uploads of their common layers
+	tags := job.GetenvList("tags")
+	if tag := job.Getenv("tag"); tag != "" {
+		tags = append(tags, tag)
+	}
+	byDigest := false
+	for _, tag := range tags {
+		byDigest = byDigest || image.IsDigest(tag)
+	}
 	job.GetenvJson("authConfig", authConfig)
 	job.GetenvJson("metaHeaders", &metaHeaders)
 	if _, err := s.poolAdd("push", localName); err != nil {
@@ -214,26 +259,50 @@ func (s *TagStore) CmdPush(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
-	endpoint, err := registry.ExpandAndVerifyRegistryUrl(hostname)
+	secure := registry.IsSecure(hostname, s.insecureRegistries)
+
+	// Repositories are pushed with the v2 protocol when the registry speaks
+	// it, falling back to v1. Digests reference v1 image IDs.
+	var v2Err error
+	if localRepo, exists := s.Repositories[localName]; exists && !byDigest {
+		if r, err := registry.NewV2Session(authConfig, registry.HTTPRequestFactory(metaHeaders), hostname, false, secure); err != nil {
+			log.Debugf("v2 registry unavailable for %s: %s", hostname, err)
+		} else {
+			v2Name := remoteName
+			if hostname == registry.IndexServerAddress() {
+				v2Name = registry.NormalizeV2Name(remoteName)
+			}
+			job.Stdout.Write(sf.FormatStatus("", "The push refers to a repository [%s] on %s", localName, r.Endpoint()))
+			if v2Err = s.pushV2Repository(r, job.Stdout, localName, v2Name, localRepo, tags, sf); v2Err == nil {
+				return engine.StatusOK
+			}
+			log.Errorf("Error from v2 registry %s: %s", r.Endpoint(), v2Err)
+		}
+	}
+
+	endpoint, err := registry.ExpandAndVerifyRegistryUrl(hostname, secure)
 	if err != nil {
+		if v2Err != nil {
+			return job.Error(v2Err)
+		}
 		return job.Error(err)
 	}
 
 	img, err := s.graph.Get(localName)
-	r, err2 := registry.NewSession(authConfig, registry.HTTPRequestFactory(metaHeaders), endpoint, false)
+	r, err2 := registry.NewSession(authConfig, registry.HTTPRequestFactory(metaHeaders), endpoint, false, secure)
 	if err2 != nil {
 		return job.Error(err2)
 	}
 
 	if err != nil {
-		reposLen := 1
-		if tag == "" {
+		reposLen := len(tags)
+		if reposLen == 0 {
 			reposLen = len(s.Repositories[localName])
 		}
 		job.Stdout.Write(sf.FormatStatus("", "The push refers to a repository [%s] (len: %d)", localName, reposLen))
 		// If it fails, try to get the repository
 		if localRepo, exists := s.Repositories[localName]; exists {
-			if err := s.pushRepository(r, job.Stdout, localName, remoteName, localRepo, tag, sf); err != nil {
+			if err := s.pushRepository(r, job.Stdout, localName, remoteName, localRepo, tags, sf); err != nil {
 				return job.Error(err)
 			}
 			return engine.StatusOK
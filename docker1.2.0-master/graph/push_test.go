@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"os"
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
+)
+
+func TestGetImageListTags(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	app, err := store.graph.Create(fakeLayer([]string{"app"}, nil), "fake", testImageID, "", "", &runconfig.Config{}, &runconfig.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"1.0", "2.0"} {
+		if err := store.Set(testImageName, tag, app.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	localRepo := store.Repositories[testImageName]
+
+	// The tags share their layers, which are listed once
+	imgList, tagsByImage, err := store.getImageList(localRepo, []string{"1.0", "2.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgList) != 2 || imgList[0] != testImageID || imgList[1] != app.ID {
+		t.Fatalf("Expected the parent then the image, got %v", imgList)
+	}
+	if len(tagsByImage[app.ID]) != 2 || len(tagsByImage[testImageID]) != 0 {
+		t.Fatalf("Expected the 2 tags to be pushed, got %v", tagsByImage)
+	}
+
+	// Every tag is pushed when none is requested
+	if _, tagsByImage, err = store.getImageList(localRepo, nil); err != nil {
+		t.Fatal(err)
+	} else if len(tagsByImage[testImageID]) != 1 || len(tagsByImage[app.ID]) != 2 {
+		t.Fatalf("Expected all the tags to be pushed, got %v", tagsByImage)
+	}
+
+	if _, _, err := store.getImageList(localRepo, []string{"1.0", "missing"}); err == nil {
+		t.Fatal("Expected an error with a missing tag")
+	}
+}
@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/utils"
+)
+
+func (s *TagStore) pushV2Repository(r *registry.Session, out io.Writer, localName, remoteName string, localRepo map[string]string, tags []string, sf *utils.StreamFormatter) error {
+	out = utils.NewWriteFlusher(out)
+	imgList, tagsByImage, err := s.getImageList(localRepo, tags)
+	if err != nil {
+		return err
+	}
+
+	// Blobs are uploaded in parallel, up to the upload limit of the daemon,
+	// then each tag gets its manifest
+	var (
+		blobSums  = make(map[string]string)
+		transfers = make([]*transfer, len(imgList))
+	)
+	for i, imgID := range imgList {
+		imgID := imgID
+		t, running := s.uploads.start(r.Endpoint()+remoteName+"@"+imgID, func() (string, error) {
+			return s.pushV2Image(r, out, remoteName, imgID, sf)
+		})
+		if running {
+			out.Write(sf.FormatProgress(utils.TruncateID(imgID), "Layer already being pushed by another client. Waiting.", nil))
+		}
+		transfers[i] = t
+	}
+	var lastErr error
+	for i, t := range transfers {
+		if err := t.Wait(); err != nil {
+			lastErr = err
+			continue
+		}
+		blobSums[imgList[i]] = t.result
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+
+	for _, imgID := range imgList {
+		if len(tagsByImage[imgID]) == 0 {
+			continue
+		}
+		// The manifests record the architecture the image is built for
+		top, err := s.graph.Get(imgID)
+		if err != nil {
+			return err
+		}
+		arch := image.NormalizeArch(top.Architecture)
+		if arch == "" {
+			arch = runtime.GOARCH
+		}
+		for _, tag := range tagsByImage[imgID] {
+			manifest := &registry.ManifestData{
+				Name:          remoteName,
+				Tag:           tag,
+				Architecture:  arch,
+				SchemaVersion: 1,
+			}
+			for img, err := s.graph.Get(imgID); img != nil; img, err = img.GetParent() {
+				if err != nil {
+					return err
+				}
+				jsonRaw, err := ioutil.ReadFile(path.Join(s.graph.Root, img.ID, "json"))
+				if err != nil {
+					return fmt.Errorf("Cannot retrieve the path for {%s}: %s", img.ID, err)
+				}
+				manifest.FSLayers = append(manifest.FSLayers, &registry.FSLayer{BlobSum: blobSums[img.ID]})
+				manifest.History = append(manifest.History, &registry.ManifestHistory{V1Compatibility: string(jsonRaw)})
+			}
+			out.Write(sf.FormatStatus("", "Pushing tag for rev [%s] on {%s}", utils.TruncateID(imgID), r.Endpoint()+remoteName+"/manifests/"+tag))
+			if err := r.PutV2ImageManifest(remoteName, tag, manifest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pushV2Image uploads the layer of imgID, unless the registry already has
+// it, and returns its blob sum.
+func (s *TagStore) pushV2Image(r *registry.Session, out io.Writer, remoteName, imgID string, sf *utils.StreamFormatter) (string, error) {
+	s.uploads.acquire(func() {
+		out.Write(sf.FormatProgress(utils.TruncateID(imgID), "Waiting", nil))
+	})
+	defer s.uploads.release()
+
+	layerData, err := s.graph.TempLayerArchive(imgID, s.layerCompression, s.compressionLevel, sf, out)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate layer archive: %s", err)
+	}
+	defer os.RemoveAll(layerData.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(h, layerData); err != nil {
+		return "", err
+	}
+	sum := "sha256:" + hex.EncodeToString(h.Sum(nil))
+
+	if exists, err := r.HeadV2ImageBlob(remoteName, sum); err != nil {
+		return "", err
+	} else if exists {
+		out.Write(sf.FormatProgress(utils.TruncateID(imgID), "Image already pushed, skipping", nil))
+		return sum, nil
+	}
+
+	if _, err := layerData.Seek(0, 0); err != nil {
+		return "", err
+	}
+	if err := r.PutV2ImageBlob(remoteName, sum, utils.ProgressReader(layerData, int(layerData.Size), out, sf, false, utils.TruncateID(imgID), "Pushing")); err != nil {
+		return "", err
+	}
+	out.Write(sf.FormatProgress(utils.TruncateID(imgID), "Image successfully pushed", nil))
+	return sum, nil
+}
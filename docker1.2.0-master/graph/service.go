@@ -11,20 +11,21 @@ import (
 
 func (s *TagStore) Install(eng *engine.Engine) error {
 	for name, handler := range map[string]engine.Handler{
-		"image_set":      s.CmdSet,
-		"image_tag":      s.CmdTag,
-		"tag":            s.CmdTagLegacy, // FIXME merge with "image_tag"
-		"image_get":      s.CmdGet,
-		"image_inspect":  s.CmdLookup,
-		"image_tarlayer": s.CmdTarLayer,
-		"image_export":   s.CmdImageExport,
-		"history":        s.CmdHistory,
-		"images":         s.CmdImages,
-		"viz":            s.CmdViz,
-		"load":           s.CmdLoad,
-		"import":         s.CmdImport,
-		"pull":           s.CmdPull,
-		"push":           s.CmdPush,
+		"image_set":          s.CmdSet,
+		"image_tag":          s.CmdTag,
+		"tag":                s.CmdTagLegacy, // FIXME merge with "image_tag"
+		"image_get":          s.CmdGet,
+		"image_inspect":      s.CmdLookup,
+		"image_tarlayer":     s.CmdTarLayer,
+		"image_export":       s.CmdImageExport,
+		"build_cache_export": s.CmdBuildCacheExport,
+		"history":            s.CmdHistory,
+		"images":             s.CmdImages,
+		"viz":                s.CmdViz,
+		"load":               s.CmdLoad,
+		"import":             s.CmdImport,
+		"pull":               s.CmdPull,
+		"push":               s.CmdPush,
 	} {
 		if err := eng.Register(name, handler); err != nil {
 			return fmt.Errorf("Could not register %q: %v", name, err)
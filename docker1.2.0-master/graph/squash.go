@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/docker/archive"
+	"github.com/docker/docker/dockerversion"
+	"github.com/docker/docker/image"
+)
+
+// Squash creates an image with the filesystem and the config of the image
+// name, collapsing all its layers above the image base into a single one.
+// With an empty base, the new image has a single layer and no parent. A
+// non zero timestamp pins the creation date and the file times of the layer.
+func (graph *Graph) Squash(name, base string, timestamp time.Time) (*image.Image, error) {
+	img, err := graph.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if base != "" {
+		baseImg, err := graph.Get(base)
+		if err != nil {
+			return nil, err
+		}
+		base = baseImg.ID
+	}
+	if img.ID == base {
+		return img, nil
+	}
+	if err := graph.checkAncestor(img, base); err != nil {
+		return nil, err
+	}
+
+	newDir, err := graph.driver.Get(img.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	defer graph.driver.Put(img.ID)
+
+	var oldDir string
+	if base != "" {
+		if oldDir, err = graph.driver.Get(base, ""); err != nil {
+			return nil, err
+		}
+		defer graph.driver.Put(base)
+	} else {
+		// Diff against an empty filesystem
+		if oldDir, err = graph.Mktemp(""); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(oldDir)
+	}
+
+	changes, err := archive.ChangesDirs(newDir, oldDir)
+	if err != nil {
+		return nil, err
+	}
+	layerData, err := archive.ExportChanges(newDir, changes)
+	if err != nil {
+		return nil, err
+	}
+	defer layerData.Close()
+
+	created := time.Now().UTC()
+	if !timestamp.IsZero() {
+		created = timestamp.UTC()
+		if layerData, err = archive.PinTimestamps(layerData, timestamp); err != nil {
+			return nil, err
+		}
+		defer layerData.Close()
+	}
+
+	squashed := &image.Image{
+		Parent:          base,
+		Comment:         img.Comment,
+		Created:         created,
+		Container:       img.Container,
+		ContainerConfig: img.ContainerConfig,
+		DockerVersion:   dockerversion.VERSION,
+		Author:          img.Author,
+		Config:          img.Config,
+		Architecture:    img.Architecture,
+		OS:              img.OS,
+	}
+	return graph.create(squashed, layerData)
+}
+
+// checkAncestor returns an error unless base is an ancestor of img, an
+// empty base being the ancestor of every image.
+func (graph *Graph) checkAncestor(img *image.Image, base string) error {
+	if base == "" {
+		return nil
+	}
+	for parent := img; parent != nil; {
+		if parent.ID == base {
+			return nil
+		}
+		var err error
+		if parent, err = parent.GetParent(); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("Image %s is not based on %s", img.ID, base)
+}
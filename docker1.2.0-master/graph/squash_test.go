@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
+	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
+)
+
+// fakeLayer returns a layer adding the files and removing the files deleted.
+func fakeLayer(files []string, deleted []string) io.Reader {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, name := range files {
+		hdr := &tar.Header{Name: name, Uid: os.Getuid(), Gid: os.Getgid(), Size: int64(len(name)), Mode: 0644}
+		tw.WriteHeader(hdr)
+		tw.Write([]byte(name))
+	}
+	for _, name := range deleted {
+		hdr := &tar.Header{Name: path.Join(path.Dir(name), ".wh."+path.Base(name)), Uid: os.Getuid(), Gid: os.Getgid()}
+		tw.WriteHeader(hdr)
+	}
+	tw.Close()
+	return buf
+}
+
+func TestSquash(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	graph := store.graph
+	defer graph.driver.Cleanup()
+
+	config := &runconfig.Config{Cmd: []string{"true"}}
+	child, err := graph.Create(fakeLayer([]string{"etc/added"}, []string{"etc/passwd"}), "fake", testImageID, "", "", &runconfig.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := graph.Create(fakeLayer([]string{"top"}, nil), "fake", child.ID, "", "", &runconfig.Config{}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	squashed, err := graph.Squash(top.ID, testImageID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if squashed.Parent != testImageID {
+		t.Fatalf("Expected the squashed image on top of %s, got %s", testImageID, squashed.Parent)
+	}
+	if squashed.Config == nil || len(squashed.Config.Cmd) != 1 || squashed.Config.Cmd[0] != "true" {
+		t.Fatalf("Expected the config of the image squashed, got %v", squashed.Config)
+	}
+
+	for name, expected := range map[string]bool{
+		"etc/added":                  true,
+		"top":                        true,
+		"etc/postgres/postgres.conf": true,
+		"etc/passwd":                 false,
+		"etc/.wh.passwd":             false,
+	} {
+		dir, err := graph.driver.Get(squashed.ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = os.Stat(path.Join(dir, name))
+		graph.driver.Put(squashed.ID)
+		if exists := err == nil; exists != expected {
+			t.Errorf("Expected %s to exist: %v", name, expected)
+		}
+	}
+
+	// Without base, all the layers are squashed
+	flat, err := graph.Squash(top.ID, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flat.Parent != "" {
+		t.Fatalf("Expected an image without parent, got parent %s", flat.Parent)
+	}
+	dir, err := graph.driver.Get(flat.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer graph.driver.Put(flat.ID)
+	if _, err := os.Stat(path.Join(dir, "etc/postgres/postgres.conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := graph.Squash(testImageID, top.ID, time.Time{}); err == nil {
+		t.Fatal("Expected an error squashing onto an image which isn't an ancestor")
+	}
+}
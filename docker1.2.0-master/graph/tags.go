@@ -10,12 +10,16 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/docker/docker/archive"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/utils"
 )
 
-const DEFAULTTAG = "latest"
+const (
+	DEFAULTTAG              = "latest"
+	DefaultLayerCompression = "gzip"
+)
 
 type TagStore struct {
 	path         string                //TagStore 中记录镜像仓库的文件所在路径，
@@ -24,23 +28,65 @@ type TagStore struct {
 	sync.Mutex                         //TagStore 的互斥锁。
 	// FIXME: move push/pull-related fields
 	// to a helper type
-	pullingPool map[string]chan struct{} //:记录池，记录有哪些镜像正在被下载，若某一个镜像正在被下载，则驳 回其他 Docker Client 发起下载该镜像的请求。
-	pushingPool map[string]chan struct{} //:记录池，记录有哪些镜像正在被上传，若某一个镜像正在被上传，则驳 回其他 Docker Client 发起上传该镜像的请求。
+	pullingPool        map[string]chan struct{} //:记录池，记录有哪些镜像正在被下载，若某一个镜像正在被下载，则驳 回其他 Docker Client 发起下载该镜像的请求。
+	pushingPool        map[string]chan struct{} //:记录池，记录有哪些镜像正在被上传，若某一个镜像正在被上传，则驳 回其他 Docker Client 发起上传该镜像的请求。
+	insecureRegistries []string                 //允许通过 http 或自签名证书访问的 registry
+	downloads          *transferManager         //layer 下载的并发限制与去重
+	uploads            *transferManager         //layer 上传的并发限制与去重
+	layerCompression   archive.Compression      //push 与 save 时 layer 的压缩算法
+	compressionLevel   int                      //layer 的压缩级别，0 为默认级别
+}
+
+// TagStoreConfig holds the registry settings of a TagStore.
+type TagStoreConfig struct {
+	InsecureRegistries     []string
+	MaxConcurrentDownloads int
+	MaxConcurrentUploads   int
+	// LayerCompression is the compression of the layers pushed and saved,
+	// gzip by default
+	LayerCompression string
+	// LayerCompressionLevel is the level of the compression, 0 being the
+	// default level
+	LayerCompressionLevel int
 }
 
 type Repository map[string]string
 
-func NewTagStore(path string, graph *Graph) (*TagStore, error) {
+func NewTagStore(path string, graph *Graph, config *TagStoreConfig) (*TagStore, error) {
 	abspath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, err
 	}
+	if config == nil {
+		config = &TagStoreConfig{}
+	}
+	if config.MaxConcurrentDownloads <= 0 {
+		config.MaxConcurrentDownloads = DefaultMaxConcurrentDownloads
+	}
+	if config.MaxConcurrentUploads <= 0 {
+		config.MaxConcurrentUploads = DefaultMaxConcurrentUploads
+	}
+	if config.LayerCompression == "" {
+		config.LayerCompression = DefaultLayerCompression
+	}
+	compression, err := archive.ParseCompression(config.LayerCompression)
+	if err != nil {
+		return nil, err
+	}
+	if config.LayerCompressionLevel < 0 || config.LayerCompressionLevel > 9 {
+		return nil, fmt.Errorf("Invalid layer compression level %d, it must be between 1 and 9, or 0 for the default", config.LayerCompressionLevel)
+	}
 	store := &TagStore{
-		path:         abspath,
-		graph:        graph,
-		Repositories: make(map[string]Repository),
-		pullingPool:  make(map[string]chan struct{}),
-		pushingPool:  make(map[string]chan struct{}),
+		path:               abspath,
+		graph:              graph,
+		Repositories:       make(map[string]Repository),
+		pullingPool:        make(map[string]chan struct{}),
+		pushingPool:        make(map[string]chan struct{}),
+		insecureRegistries: config.InsecureRegistries,
+		downloads:          newTransferManager(config.MaxConcurrentDownloads),
+		uploads:            newTransferManager(config.MaxConcurrentUploads),
+		layerCompression:   compression,
+		compressionLevel:   config.LayerCompressionLevel,
 	}
 	// Load the json file if it exists, otherwise create it.
 	if err := store.reload(); os.IsNotExist(err) {
@@ -217,6 +263,9 @@ func (store *TagStore) Get(repoName string) (Repository, error) {
 }
 
 func (store *TagStore) GetImage(repoName, tagOrID string) (*image.Image, error) {
+	if image.IsDigest(tagOrID) {
+		return store.getDigest(tagOrID)
+	}
 	repo, err := store.Get(repoName)
 	store.Lock()
 	defer store.Unlock()
@@ -237,6 +286,26 @@ func (store *TagStore) GetImage(repoName, tagOrID string) (*image.Image, error)
 	return nil, nil
 }
 
+// getDigest returns the content addressable image referenced by digest,
+// whichever repository it is tagged in.
+func (store *TagStore) getDigest(digest string) (*image.Image, error) {
+	id, err := image.ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	if !store.graph.Exists(id) {
+		return nil, nil
+	}
+	img, err := store.graph.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if img.Digest() != digest {
+		return nil, fmt.Errorf("Image %s is not content addressable", id)
+	}
+	return img, nil
+}
+
 func (store *TagStore) GetRepoRefs() map[string][]string {
 	store.Lock()
 	reporefs := make(map[string][]string)
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"bytes"
+	"github.com/docker/docker/archive"
 	"github.com/docker/docker/daemon/graphdriver"
 	_ "github.com/docker/docker/daemon/graphdriver/vfs" // import the vfs driver so it is used in the tests
 	"github.com/docker/docker/image"
@@ -52,7 +53,7 @@ func mkTestTagStore(root string, t *testing.T) *TagStore {
 	if err != nil {
 		t.Fatal(err)
 	}
-	store, err := NewTagStore(path.Join(root, "tags"), graph)
+	store, err := NewTagStore(path.Join(root, "tags"), graph, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -114,3 +115,79 @@ func TestLookupImage(t *testing.T) {
 		t.Errorf("Expected 1 image, none found")
 	}
 }
+
+func TestLookupImageByDigest(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	archive, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := store.graph.Create(archive, "", "", "digest", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := img.ContentID(); err != nil {
+		t.Fatal(err)
+	} else if id != img.ID || img.Checksum == "" {
+		t.Fatalf("Expected a content addressable image, got id %s and checksum %q", img.ID, img.Checksum)
+	}
+
+	if found, err := store.LookupImage(testImageName + "@" + img.Digest()); err != nil {
+		t.Fatal(err)
+	} else if found == nil || found.ID != img.ID {
+		t.Errorf("Expected image %s, got %v", img.ID, found)
+	}
+	// testImageID has a random ID and can't be referenced by digest
+	if _, err := store.LookupImage(testImageName + "@" + image.DigestPrefix + testImageID); err == nil {
+		t.Errorf("Expected error, none found")
+	}
+
+	// An image which doesn't match its digest is refused
+	tampered := &image.Image{Comment: "tampered", Checksum: img.Checksum}
+	tampered.ID = img.ID
+	archive, err = fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.graph.Delete(img.ID)
+	if err := store.graph.Register(nil, archive, tampered); err == nil {
+		t.Errorf("Expected error registering an image which doesn't match its digest")
+	}
+}
+
+func TestTagStoreLayerCompression(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	if store.layerCompression != archive.Gzip || store.compressionLevel != 0 {
+		t.Fatalf("Expected gzip layers by default, got %s at level %d", store.layerCompression.Extension(), store.compressionLevel)
+	}
+	for _, config := range []*TagStoreConfig{
+		{LayerCompression: "xz"},
+		{LayerCompressionLevel: 10},
+		{LayerCompressionLevel: -1},
+	} {
+		if _, err := NewTagStore(path.Join(tmp, "tags"), store.graph, config); err == nil {
+			t.Fatalf("Expected an error creating a tag store with %#v", config)
+		}
+	}
+	none, err := NewTagStore(path.Join(tmp, "tags"), store.graph, &TagStoreConfig{LayerCompression: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if none.layerCompression != archive.Uncompressed {
+		t.Fatalf("Expected uncompressed layers, got %s", none.layerCompression.Extension())
+	}
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"sync"
+)
+
+const (
+	DefaultMaxConcurrentDownloads = 3
+	DefaultMaxConcurrentUploads   = 5
+)
+
+// transfer is a layer download or upload shared by all the pulls or pushes
+// needing it.
+type transfer struct {
+	done   chan struct{}
+	result string
+	err    error
+}
+
+// Wait waits for the transfer to end and returns its error.
+func (t *transfer) Wait() error {
+	<-t.done
+	return t.err
+}
+
+// transferManager runs the transfers of layers, at most max at a time, and
+// deduplicates the concurrent transfers of the same layer.
+type transferManager struct {
+	sync.Mutex
+	slots     chan struct{}
+	transfers map[string]*transfer
+}
+
+func newTransferManager(max int) *transferManager {
+	return &transferManager{
+		slots:     make(chan struct{}, max),
+		transfers: make(map[string]*transfer),
+	}
+}
+
+// start runs fn in the background as the transfer of key, unless the
+// transfer of key is already running. The transfer is returned with
+// whether it was already running; its result is the one of fn.
+func (m *transferManager) start(key string, fn func() (string, error)) (*transfer, bool) {
+	m.Lock()
+	defer m.Unlock()
+	if t, exists := m.transfers[key]; exists {
+		return t, true
+	}
+	t := &transfer{done: make(chan struct{})}
+	m.transfers[key] = t
+	go func() {
+		t.result, t.err = fn()
+		m.Lock()
+		delete(m.transfers, key)
+		m.Unlock()
+		close(t.done)
+	}()
+	return t, false
+}
+
+// acquire blocks until a transfer slot is available, calling waiting
+// first if none is.
+func (m *transferManager) acquire(waiting func()) {
+	select {
+	case m.slots <- struct{}{}:
+		return
+	default:
+	}
+	if waiting != nil {
+		waiting()
+	}
+	m.slots <- struct{}{}
+}
+
+func (m *transferManager) release() {
+	<-m.slots
+}
@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestTransferManagerDeduplicates(t *testing.T) {
+	m := newTransferManager(2)
+	release := make(chan struct{})
+	calls := 0
+	fn := func() (string, error) {
+		calls++
+		<-release
+		return "sum", nil
+	}
+
+	first, running := m.start("layer", fn)
+	if running {
+		t.Fatal("Expected the first transfer not to be running")
+	}
+	second, running := m.start("layer", fn)
+	if !running || second != first {
+		t.Fatal("Expected the second transfer to wait for the first one")
+	}
+	close(release)
+	if err := second.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || second.result != "sum" {
+		t.Fatalf("Expected 1 call with result sum, got %d calls with result %q", calls, second.result)
+	}
+
+	// Ended transfers are forgotten
+	third, running := m.start("layer", func() (string, error) { return "", fmt.Errorf("failed") })
+	if running || third == first {
+		t.Fatal("Expected a new transfer once the first one ended")
+	}
+	if err := third.Wait(); err == nil {
+		t.Fatal("Expected the error of the transfer")
+	}
+}
+
+func TestTransferManagerLimit(t *testing.T) {
+	var (
+		m       = newTransferManager(2)
+		lock    sync.Mutex
+		running int
+		max     int
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.acquire(nil)
+			defer m.release()
+			lock.Lock()
+			running++
+			if running > max {
+				max = running
+			}
+			lock.Unlock()
+
+			lock.Lock()
+			running--
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	if max > 2 {
+		t.Fatalf("Expected at most 2 transfers at a time, got %d", max)
+	}
+}
+
+func TestTransferManagerConcurrentTransfers(t *testing.T) {
+	var (
+		m       = newTransferManager(2)
+		release = make(chan struct{})
+		started = make(chan string, 4)
+		waiting = make(chan string, 4)
+	)
+	transfers := make([]*transfer, 4)
+	for i := range transfers {
+		key := fmt.Sprintf("layer%d", i)
+		transfers[i], _ = m.start(key, func() (string, error) {
+			m.acquire(func() { waiting <- key })
+			defer m.release()
+			started <- key
+			<-release
+			return key, nil
+		})
+	}
+
+	// Two transfers hold the slots, the two others wait for them
+	for i := 0; i < 2; i++ {
+		<-started
+		<-waiting
+	}
+	select {
+	case key := <-started:
+		t.Fatalf("Expected at most 2 transfers at a time, %s started", key)
+	default:
+	}
+
+	close(release)
+	for i, tr := range transfers {
+		if err := tr.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("layer%d", i); tr.result != expected {
+			t.Fatalf("Expected the result %s, got %s", expected, tr.result)
+		}
+	}
+	if len(started) != 2 {
+		t.Fatalf("Expected the 2 waiting transfers to start once released, %d did", len(started))
+	}
+}
@@ -0,0 +1,66 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DigestPrefix prefixes the digest references of images, as in
+// ubuntu@sha256:<id>.
+const DigestPrefix = "sha256:"
+
+// IsDigest tells whether ref references an image by digest.
+func IsDigest(ref string) bool {
+	return strings.HasPrefix(ref, DigestPrefix)
+}
+
+// ParseDigest returns the image ID referenced by the digest ref.
+func ParseDigest(ref string) (string, error) {
+	id := strings.TrimPrefix(ref, DigestPrefix)
+	if _, err := hex.DecodeString(id); err != nil || !IsDigest(ref) || len(id) != sha256.Size*2 || strings.ToLower(id) != id {
+		return "", fmt.Errorf("Invalid digest %s", ref)
+	}
+	return id, nil
+}
+
+// ContentID computes the ID of a content addressable image: the sha256 of
+// its json, which includes the checksum of its layer.
+func (img *Image) ContentID() (string, error) {
+	c := *img
+	c.ID = ""
+	c.Size = 0
+	jsonData, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(jsonData)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Digest returns the digest of img, or "" if its ID isn't content
+// addressable.
+func (img *Image) Digest() string {
+	if img.Checksum == "" {
+		return ""
+	}
+	return DigestPrefix + img.ID
+}
+
+// VerifyContentID checks the ID of a content addressable image matches its
+// content. The layer checksum is verified when the layer is stored.
+func (img *Image) VerifyContentID() error {
+	if img.Checksum == "" {
+		return nil
+	}
+	id, err := img.ContentID()
+	if err != nil {
+		return err
+	}
+	if id != img.ID {
+		return fmt.Errorf("Image %s doesn't match its content digest %s", img.ID, id)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"runtime"
 	"strconv"
 	"time"
 
@@ -33,6 +34,7 @@ type Image struct {
 	Config          *runconfig.Config `json:"config,omitempty"`
 	Architecture    string            `json:"architecture,omitempty"`
 	OS              string            `json:"os,omitempty"`
+	Checksum        string            `json:"checksum,omitempty"` // Tarsum of the layer, set for content addressable images
 	Size            int64
 
 	graph Graph
@@ -177,39 +179,44 @@ func (img *Image) TarLayer() (arch archive.Archive, err error) {
 	if img.graph == nil {
 		return nil, fmt.Errorf("Can't load storage driver for unregistered image %s", img.ID)
 	}
-	driver := img.graph.Driver()
+	return ExportLayer(img.graph.Driver(), img.ID, img.Parent)
+}
+
+// ExportLayer returns a tar archive of the changes of the layer id of driver
+// from its parent layer.
+func ExportLayer(driver graphdriver.Driver, id, parent string) (arch archive.Archive, err error) {
 	if differ, ok := driver.(graphdriver.Differ); ok {
-		return differ.Diff(img.ID)
+		return differ.Diff(id)
 	}
 
-	imgFs, err := driver.Get(img.ID, "")
+	imgFs, err := driver.Get(id, "")
 	if err != nil {
 		return nil, err
 	}
 
 	defer func() {
 		if err != nil {
-			driver.Put(img.ID)
+			driver.Put(id)
 		}
 	}()
 
-	if img.Parent == "" {
+	if parent == "" {
 		archive, err := archive.Tar(imgFs, archive.Uncompressed)
 		if err != nil {
 			return nil, err
 		}
 		return utils.NewReadCloserWrapper(archive, func() error {
 			err := archive.Close()
-			driver.Put(img.ID)
+			driver.Put(id)
 			return err
 		}), nil
 	}
 
-	parentFs, err := driver.Get(img.Parent, "")
+	parentFs, err := driver.Get(parent, "")
 	if err != nil {
 		return nil, err
 	}
-	defer driver.Put(img.Parent)
+	defer driver.Put(parent)
 	changes, err := archive.ChangesDirs(imgFs, parentFs)
 	if err != nil {
 		return nil, err
@@ -220,7 +227,7 @@ func (img *Image) TarLayer() (arch archive.Archive, err error) {
 	}
 	return utils.NewReadCloserWrapper(archive, func() error {
 		err := archive.Close()
-		driver.Put(img.ID)
+		driver.Put(id)
 		return err
 	}), nil
 }
@@ -318,6 +325,28 @@ func (img *Image) CheckDepth() error {
 	return nil
 }
 
+// CheckPlatform returns an error if the image was built for another
+// operating system or architecture than the one of the daemon. Images which
+// don't record their platform are assumed to match it.
+func (img *Image) CheckPlatform() error {
+	if img.OS != "" && img.OS != runtime.GOOS {
+		return fmt.Errorf("Image %s is built for the %s operating system, not %s", utils.TruncateID(img.ID), img.OS, runtime.GOOS)
+	}
+	if arch := NormalizeArch(img.Architecture); arch != "" && arch != runtime.GOARCH {
+		return fmt.Errorf("Image %s is built for the %s architecture, not %s", utils.TruncateID(img.ID), arch, runtime.GOARCH)
+	}
+	return nil
+}
+
+// NormalizeArch returns the Go name of the architecture arch, older images
+// naming amd64 x86_64.
+func NormalizeArch(arch string) string {
+	if arch == "x86_64" {
+		return "amd64"
+	}
+	return arch
+}
+
 // Build an Image object from raw json data
 func NewImgJSON(src []byte) (*Image, error) {
 	ret := &Image{}
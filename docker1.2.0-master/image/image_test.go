@@ -0,0 +1,34 @@
+package image
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCheckPlatform(t *testing.T) {
+	for _, img := range []*Image{
+		{},
+		{OS: runtime.GOOS, Architecture: runtime.GOARCH},
+		{Architecture: runtime.GOARCH},
+	} {
+		if err := img.CheckPlatform(); err != nil {
+			t.Fatalf("Expected %s/%s to match the daemon: %s", img.OS, img.Architecture, err)
+		}
+	}
+	for _, img := range []*Image{
+		{OS: "plan9", Architecture: runtime.GOARCH},
+		{OS: runtime.GOOS, Architecture: "mips"},
+	} {
+		if err := img.CheckPlatform(); err == nil {
+			t.Fatalf("Expected %s/%s not to match the daemon", img.OS, img.Architecture)
+		}
+	}
+}
+
+func TestNormalizeArch(t *testing.T) {
+	for arch, expected := range map[string]string{"x86_64": "amd64", "amd64": "amd64", "arm": "arm", "": ""} {
+		if normalized := NormalizeArch(arch); normalized != expected {
+			t.Fatalf("Expected %q for %q, got %q", expected, arch, normalized)
+		}
+	}
+}
@@ -167,3 +167,40 @@ func TestSaveAndLoadRepoFlags(t *testing.T) {
 	logDone("save - save a repo using -o")
 	logDone("load - load a repo using -i")
 }
+
+func TestSaveMultipleNames(t *testing.T) {
+	repoName := "foobar-save-multi-name-test"
+
+	for _, tag := range []string{"first", "second"} {
+		tagCmdFinal := fmt.Sprintf("%v tag busybox:latest %v-%v:latest", dockerBinary, repoName, tag)
+		tagCmd := exec.Command("bash", "-c", tagCmdFinal)
+		out, _, err := runCommandWithOutput(tagCmd)
+		errorOut(err, t, fmt.Sprintf("failed to tag repo: %v %v", out, err))
+	}
+
+	idCmdFinal := fmt.Sprintf("%v images -q --no-trunc busybox:latest", dockerBinary)
+	idCmd := exec.Command("bash", "-c", idCmdFinal)
+	out, _, err := runCommandWithOutput(idCmd)
+	errorOut(err, t, fmt.Sprintf("failed to get repo ID: %v %v", out, err))
+
+	cleanedImageID := stripTrailingCharacters(out)
+
+	// Both repositories are listed and the shared layers are only saved once
+	saveCmdFinal := fmt.Sprintf("%v save %v-first:latest %v-second:latest | tar xO repositories | grep %v-first | grep %v-second", dockerBinary, repoName, repoName, repoName, repoName)
+	saveCmd := exec.Command("bash", "-c", saveCmdFinal)
+	out, _, err = runCommandWithOutput(saveCmd)
+	errorOut(err, t, fmt.Sprintf("failed to save multiple repos: %v %v", out, err))
+
+	countCmdFinal := fmt.Sprintf("%v save %v-first:latest %v-second:latest | tar t | grep -c '^%v/json$'", dockerBinary, repoName, repoName, cleanedImageID)
+	countCmd := exec.Command("bash", "-c", countCmdFinal)
+	out, _, err = runCommandWithOutput(countCmd)
+	errorOut(err, t, fmt.Sprintf("failed to list the saved layers: %v %v", out, err))
+	if count := stripTrailingCharacters(out); count != "1" {
+		t.Fatalf("expected the shared layer to be saved once, got %v", count)
+	}
+
+	deleteImages(repoName + "-first")
+	deleteImages(repoName + "-second")
+
+	logDone("save - save multiple images at once")
+}
@@ -26,7 +26,7 @@ func TestLogin(t *testing.T) {
 		Email:         "noise+unittester@docker.com",
 		ServerAddress: "https://registry-stage.hub.docker.com/v1/",
 	}
-	status, err := registry.Login(authConfig, nil)
+	status, err := registry.Login(authConfig, nil, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -50,7 +50,7 @@ func TestCreateAccount(t *testing.T) {
 		Email:         fmt.Sprintf("docker-ut+%s@example.com", token),
 		ServerAddress: "https://registry-stage.hub.docker.com/v1/",
 	}
-	status, err := registry.Login(authConfig, nil)
+	status, err := registry.Login(authConfig, nil, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -62,7 +62,7 @@ func TestCreateAccount(t *testing.T) {
 		t.Fatalf("Expected status: \"%s\", found \"%s\" instead.", expectedStatus, status)
 	}
 
-	status, err = registry.Login(authConfig, nil)
+	status, err = registry.Login(authConfig, nil, true)
 	if err == nil {
 		t.Fatalf("Expected error but found nil instead")
 	}
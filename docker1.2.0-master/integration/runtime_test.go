@@ -336,7 +336,7 @@ func TestDaemonCreate(t *testing.T) {
 	}
 	container, _, err = daemon.Create(config, "")
 
-	_, err = daemon.Commit(container, "testrepo", "testtag", "", "", true, config)
+	_, err = daemon.Commit(container, "testrepo", "testtag", "", "", true, false, time.Time{}, config)
 	if err != nil {
 		t.Error(err)
 	}
@@ -19,6 +19,7 @@ import (
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/registry"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 )
@@ -154,15 +155,11 @@ func getContainer(eng *engine.Engine, id string, t log.Fataler) *daemon.Containe
 }
 
 func mkDaemonFromEngine(eng *engine.Engine, t log.Fataler) *daemon.Daemon {
-	iDaemon := eng.Hack_GetGlobalVar("httpapi.daemon")
-	if iDaemon == nil {
-		panic("Legacy daemon field not set in engine")
+	var d *daemon.Daemon
+	if err := eng.LookupService(daemon.ServiceName, &d); err != nil {
+		panic(err)
 	}
-	daemon, ok := iDaemon.(*daemon.Daemon)
-	if !ok {
-		panic("Legacy daemon field in engine does not cast to *daemon.Daemon")
-	}
-	return daemon
+	return d
 }
 
 func newTestEngine(t log.Fataler, autorestart bool, root string) *engine.Engine {
@@ -178,6 +175,7 @@ func newTestEngine(t log.Fataler, autorestart bool, root string) *engine.Engine
 	eng := engine.New()
 	// Load default plugins
 	builtins.Register(eng)
+	registry.NewService(nil).Install(eng)
 	// (This is manually copied and modified from main() until we have a more generic plugin system)
 	cfg := &daemon.Config{
 		Root:        root,
@@ -5,7 +5,6 @@ package nat
 
 import (
 	"fmt"
-	"net"
 	"strconv"
 	"strings"
 
@@ -78,17 +77,9 @@ func SplitProtoPort(rawPort string) (string, string) {
 	return parts[1], parts[0]
 }
 
-func validateProto(proto string) bool {
-	for _, availableProto := range []string{"tcp", "udp"} {
-		if availableProto == proto {
-			return true
-		}
-	}
-	return false
-}
-
 // We will receive port specs in the format of ip:public:private/proto and these need to be
-// parsed in the internal types
+// parsed in the internal types. Ranges of ports are expanded, e.g.
+// 8000-8001:80-81 binds 80 to 8000 and 81 to 8001.
 func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding, error) {
 	var (
 		exposedPorts = make(map[Port]struct{}, len(ports))
@@ -96,60 +87,48 @@ func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding,
 	)
 
 	for _, rawPort := range ports {
-		proto := "tcp"
-
-		if i := strings.LastIndex(rawPort, "/"); i != -1 {
-			proto = rawPort[i+1:]
-			rawPort = rawPort[:i]
-		}
-		if !strings.Contains(rawPort, ":") {
-			rawPort = fmt.Sprintf("::%s", rawPort)
-		} else if len(strings.Split(rawPort, ":")) == 2 {
-			rawPort = fmt.Sprintf(":%s", rawPort)
-		}
-
-		parts, err := parsers.PartParser(PortSpecTemplate, rawPort)
+		spec, err := parsers.ParsePortSpec(rawPort)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		var (
-			containerPort = parts["containerPort"]
-			rawIp         = parts["ip"]
-			hostPort      = parts["hostPort"]
-		)
-
-		if rawIp != "" && net.ParseIP(rawIp) == nil {
-			return nil, nil, fmt.Errorf("Invalid ip address: %s", rawIp)
-		}
-		if containerPort == "" {
-			return nil, nil, fmt.Errorf("No port specified: %s<empty>", rawPort)
-		}
-		if _, err := strconv.ParseUint(containerPort, 10, 16); err != nil {
-			return nil, nil, fmt.Errorf("Invalid containerPort: %s", containerPort)
-		}
-		if _, err := strconv.ParseUint(hostPort, 10, 16); hostPort != "" && err != nil {
-			return nil, nil, fmt.Errorf("Invalid hostPort: %s", hostPort)
-		}
-
-		if !validateProto(proto) {
-			return nil, nil, fmt.Errorf("Invalid proto: %s", proto)
+		for i := 0; i < spec.ContainerPorts.Len(); i++ {
+			port := NewPort(spec.Proto, strconv.Itoa(spec.ContainerPorts.Start+i))
+			if _, exists := exposedPorts[port]; !exists {
+				exposedPorts[port] = struct{}{}
+			}
+
+			binding := PortBinding{
+				HostIp: spec.HostIP,
+			}
+			if spec.HostPorts.Start != 0 {
+				binding.HostPort = strconv.Itoa(spec.HostPorts.Start + i)
+			}
+			bslice, exists := bindings[port]
+			if !exists {
+				bslice = []PortBinding{}
+			}
+			bindings[port] = append(bslice, binding)
 		}
+	}
+	return exposedPorts, bindings, nil
+}
 
-		port := NewPort(proto, containerPort)
-		if _, exists := exposedPorts[port]; !exists {
-			exposedPorts[port] = struct{}{}
+// ParseExposedPorts parses ports exposed without being published, e.g. 80,
+// 53/udp or 7000-7010.
+func ParseExposedPorts(ports []string) (map[Port]struct{}, error) {
+	exposedPorts := make(map[Port]struct{}, len(ports))
+	for _, rawPort := range ports {
+		if strings.Contains(rawPort, ":") {
+			return nil, fmt.Errorf("Invalid port format for --expose: %s", rawPort)
 		}
-
-		binding := PortBinding{
-			HostIp:   rawIp,
-			HostPort: hostPort,
+		portRange, proto, err := parsers.ParsePortProto(rawPort)
+		if err != nil {
+			return nil, err
 		}
-		bslice, exists := bindings[port]
-		if !exists {
-			bslice = []PortBinding{}
+		for i := 0; i < portRange.Len(); i++ {
+			exposedPorts[NewPort(proto, strconv.Itoa(portRange.Start+i))] = struct{}{}
 		}
-		bindings[port] = append(bslice, binding)
 	}
-	return exposedPorts, bindings, nil
+	return exposedPorts, nil
 }
@@ -181,9 +181,52 @@ func TestParsePortSpecs(t *testing.T) {
 		}
 	}
 
-	_, _, err = ParsePortSpecs([]string{"localhost:1234:1234/tcp"})
+	// short names are taken for the names of interfaces
+	_, _, err = ParsePortSpecs([]string{"localhost.localdomain:1234:1234/tcp"})
 
 	if err == nil {
 		t.Fatal("Received no error while trying to parse a hostname instead of ip")
 	}
 }
+
+func TestParsePortSpecsRanges(t *testing.T) {
+	portMap, bindingMap, err := ParsePortSpecs([]string{"127.0.0.1:8000-8001:80-81", "eth0::53/udp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for port, hostPort := range map[Port]string{"80/tcp": "8000", "81/tcp": "8001"} {
+		if _, ok := portMap[port]; !ok {
+			t.Fatalf("%s was not parsed properly", port)
+		}
+		if bindings := bindingMap[port]; len(bindings) != 1 || bindings[0].HostIp != "127.0.0.1" || bindings[0].HostPort != hostPort {
+			t.Fatalf("Unexpected bindings %v for %s", bindings, port)
+		}
+	}
+	if bindings := bindingMap["53/udp"]; len(bindings) != 1 || bindings[0].HostIp != "eth0" || bindings[0].HostPort != "" {
+		t.Fatalf("Unexpected bindings %v for 53/udp", bindings)
+	}
+
+	for _, spec := range []string{"8000-8002:80-81", "::1:80:80", "[::1]:80:80", "80/icmp", "80/sctp", "81-80"} {
+		if _, _, err := ParsePortSpecs([]string{spec}); err == nil {
+			t.Fatalf("Received no error while parsing %s", spec)
+		}
+	}
+}
+
+func TestParseExposedPorts(t *testing.T) {
+	ports, err := ParseExposedPorts([]string{"7000-7002", "53/udp", "132/sctp", "80-80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 6 {
+		t.Fatalf("Expected 6 ports, got %v", ports)
+	}
+	for _, port := range []Port{"7000/tcp", "7001/tcp", "7002/tcp", "53/udp", "132/sctp", "80/tcp"} {
+		if _, ok := ports[port]; !ok {
+			t.Fatalf("%s was not parsed properly", port)
+		}
+	}
+	if _, err := ParseExposedPorts([]string{"8080:80"}); err == nil {
+		t.Fatal("Received no error while parsing a published port")
+	}
+}
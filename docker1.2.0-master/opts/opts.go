@@ -29,6 +29,18 @@ func DnsSearchListVar(values *[]string, names []string, usage string) {
 	flag.Var(newListOptsRef(values, ValidateDnsSearch), names, usage)
 }
 
+func EnvListVar(values *[]string, names []string, usage string) {
+	flag.Var(newListOptsRef(values, ValidateEnv), names, usage)
+}
+
+func LabelListVar(values *[]string, names []string, usage string) {
+	flag.Var(newListOptsRef(values, ValidateLabel), names, usage)
+}
+
+func InsecureRegistryListVar(values *[]string, names []string, usage string) {
+	flag.Var(newListOptsRef(values, ValidateInsecureRegistry), names, usage)
+}
+
 func IPVar(value *net.IP, names []string, defaultValue, usage string) {
 	flag.Var(NewIpOpt(value, defaultValue), names, usage)
 }
@@ -161,6 +173,42 @@ func ValidateEnv(val string) (string, error) {
 	return fmt.Sprintf("%s=%s", val, os.Getenv(val)), nil
 }
 
+// ValidateLabel checks that the label is in the key=value format with a
+// non-empty key.
+func ValidateLabel(val string) (string, error) {
+	arr := strings.SplitN(val, "=", 2)
+	if len(arr) != 2 || strings.TrimSpace(arr[0]) == "" {
+		return "", fmt.Errorf("bad format for label: %s, expected key=value", val)
+	}
+	return val, nil
+}
+
+// ValidateInsecureRegistry checks that the registry is a host[:port]
+// without scheme nor path, or a CIDR.
+func ValidateInsecureRegistry(val string) (string, error) {
+	if _, _, err := net.ParseCIDR(val); err == nil {
+		return val, nil
+	}
+	if val == "" || strings.Contains(val, "://") || strings.Contains(val, "/") {
+		return "", fmt.Errorf("%s is not a valid insecure registry, expected host[:port] or CIDR", val)
+	}
+	return val, nil
+}
+
+// deviceCgroupRuleRegexp matches the entries of the device cgroup, e.g.
+// `c 189:* rwm`.
+var deviceCgroupRuleRegexp = regexp.MustCompile(`^([acb]) ([0-9]+|\*):([0-9]+|\*) ([rwm]{1,3})$`)
+
+// ValidateDeviceCgroupRule checks that the rule is a device cgroup entry:
+// the type of the devices (a, b or c), their major:minor numbers, * matching
+// any, and their access (r, w and m).
+func ValidateDeviceCgroupRule(val string) (string, error) {
+	if !deviceCgroupRuleRegexp.MatchString(val) {
+		return "", fmt.Errorf("invalid device cgroup rule %s, expected type major:minor access (e.g. 'c 189:* rwm')", val)
+	}
+	return val, nil
+}
+
 func ValidateIPAddress(val string) (string, error) {
 	var ip = net.ParseIP(strings.TrimSpace(val))
 	if ip != nil {
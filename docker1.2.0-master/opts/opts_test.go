@@ -27,6 +27,59 @@ func TestValidateIPAddress(t *testing.T) {
 
 }
 
+func TestValidateLabel(t *testing.T) {
+	valid := []string{
+		`foo=bar`,
+		`com.example.rack=r1`,
+		`empty=`,
+		`with=equal=sign`,
+	}
+	invalid := []string{
+		``,
+		`foo`,
+		`=bar`,
+		` =bar`,
+	}
+
+	for _, label := range valid {
+		if ret, err := ValidateLabel(label); err != nil || ret != label {
+			t.Fatalf("ValidateLabel(`%s`) should succeed: got %s %v", label, ret, err)
+		}
+	}
+
+	for _, label := range invalid {
+		if ret, err := ValidateLabel(label); err == nil || ret != "" {
+			t.Fatalf("ValidateLabel(`%s`) should fail: got %s %v", label, ret, err)
+		}
+	}
+}
+
+func TestValidateInsecureRegistry(t *testing.T) {
+	valid := []string{
+		`registry.example.com`,
+		`registry.example.com:5000`,
+		`10.1.0.0/16`,
+		`[fe80::1]:5000`,
+	}
+	invalid := []string{
+		``,
+		`http://registry.example.com`,
+		`registry.example.com/v1/`,
+	}
+
+	for _, registry := range valid {
+		if ret, err := ValidateInsecureRegistry(registry); err != nil || ret != registry {
+			t.Fatalf("ValidateInsecureRegistry(`%s`) should succeed: got %s %v", registry, ret, err)
+		}
+	}
+
+	for _, registry := range invalid {
+		if ret, err := ValidateInsecureRegistry(registry); err == nil || ret != "" {
+			t.Fatalf("ValidateInsecureRegistry(`%s`) should fail: got %s %v", registry, ret, err)
+		}
+	}
+}
+
 func TestListOpts(t *testing.T) {
 	o := NewListOpts(nil)
 	o.Set("foo")
@@ -88,3 +141,33 @@ func TestValidateDnsSearch(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateDeviceCgroupRule(t *testing.T) {
+	valid := []string{
+		`c 189:* rwm`,
+		`b 8:16 r`,
+		`a *:* rwm`,
+		`c 1:3 mr`,
+	}
+	invalid := []string{
+		``,
+		`c 189`,
+		`x 1:3 rwm`,
+		`c 1:3 rwx`,
+		`c 1:3`,
+		`c -1:3 rwm`,
+		`c  1:3 rwm`,
+	}
+
+	for _, rule := range valid {
+		if ret, err := ValidateDeviceCgroupRule(rule); err != nil || ret != rule {
+			t.Fatalf("ValidateDeviceCgroupRule(`%s`) should succeed: got %s %v", rule, ret, err)
+		}
+	}
+
+	for _, rule := range invalid {
+		if ret, err := ValidateDeviceCgroupRule(rule); err == nil || ret != "" {
+			t.Fatalf("ValidateDeviceCgroupRule(`%s`) should fail: got %s %v", rule, ret, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/docker/pkg/jsonlog"
@@ -13,9 +14,12 @@ import (
 
 // BroadcastWriter accumulate multiple io.WriteCloser by stream.
 type BroadcastWriter struct {
+	// dropped is first to keep it 64-bit aligned for atomic operations.
+	dropped int64
 	sync.Mutex
 	buf     *bytes.Buffer
 	streams map[string](map[io.WriteCloser]struct{})
+	queued  []*queueWriter
 }
 
 // AddWriter adds new io.WriteCloser for stream.
@@ -30,6 +34,65 @@ func (w *BroadcastWriter) AddWriter(writer io.WriteCloser, stream string) {
 	w.Unlock()
 }
 
+// AddBufferedWriter adds new io.WriteCloser for stream, like AddWriter, but
+// decouples it from the broadcast with a queue of size bytes. Output which
+// doesn't fit in the queue because the writer is too slow is dropped and
+// accounted in Dropped. Writers failing, or not accepting any data for
+// StallTimeout, are evicted and closed.
+func (w *BroadcastWriter) AddBufferedWriter(writer io.WriteCloser, stream string, size int) {
+	w.AddQueuedWriter(writer, stream, size, DropNewest)
+}
+
+// AddQueuedWriter adds new io.WriteCloser for stream, like AddBufferedWriter,
+// policy being what to do with output which doesn't fit in its queue.
+func (w *BroadcastWriter) AddQueuedWriter(writer io.WriteCloser, stream string, size int, policy Policy) {
+	qw := newQueueWriter(writer, stream, size, policy, &w.dropped)
+	w.Lock()
+	w.queued = append(w.queued, qw)
+	w.Unlock()
+	w.AddWriter(qw, stream)
+	// The writer is forgotten once done, even if the broadcast doesn't write
+	// anymore to evict it
+	go func() {
+		<-qw.done
+		w.Lock()
+		w.evict(stream, qw)
+		w.Unlock()
+	}()
+}
+
+// evict removes writer from stream. It must be called with the lock held.
+func (w *BroadcastWriter) evict(stream string, writer io.WriteCloser) {
+	delete(w.streams[stream], writer)
+	qw, ok := writer.(*queueWriter)
+	if !ok {
+		return
+	}
+	for i, queued := range w.queued {
+		if queued == qw {
+			w.queued = append(w.queued[:i], w.queued[i+1:]...)
+			break
+		}
+	}
+}
+
+// Dropped returns the number of bytes dropped for slow buffered writers.
+func (w *BroadcastWriter) Dropped() int64 {
+	return atomic.LoadInt64(&w.dropped)
+}
+
+// Stats returns the statistics of the buffered writers not evicted yet.
+func (w *BroadcastWriter) Stats() []WriterStats {
+	w.Lock()
+	queued := append([]*queueWriter(nil), w.queued...)
+	w.Unlock()
+	stats := make([]WriterStats, len(queued))
+	for i, qw := range queued {
+		stats[i] = qw.Stats()
+	}
+	return stats
+}
+
 // Write writes bytes to all writers. Failed writers will be evicted during
 // this call.
 func (w *BroadcastWriter) Write(p []byte) (n int, err error) {
@@ -39,7 +102,7 @@ func (w *BroadcastWriter) Write(p []byte) (n int, err error) {
 		for sw := range writers {
 			if n, err := sw.Write(p); err != nil || n != len(p) {
 				// On error, evict the writer
-				delete(writers, sw)
+				w.evict("", sw)
 			}
 		}
 	}
@@ -62,7 +125,7 @@ func (w *BroadcastWriter) Write(p []byte) (n int, err error) {
 			b = append(b, '\n')
 			for sw := range writers {
 				if _, err := sw.Write(b); err != nil {
-					delete(writers, sw)
+					w.evict(stream, sw)
 				}
 			}
 		}
@@ -81,6 +144,7 @@ func (w *BroadcastWriter) Clean() error {
 		}
 	}
 	w.streams = make(map[string](map[io.WriteCloser]struct{}))
+	w.queued = nil
 	w.Unlock()
 	return nil
 }
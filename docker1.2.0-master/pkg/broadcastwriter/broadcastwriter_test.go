@@ -3,8 +3,8 @@ package broadcastwriter
 import (
 	"bytes"
 	"errors"
-
 	"testing"
+	"time"
 )
 
 type dummyWriter struct {
@@ -85,6 +85,219 @@ func TestBroadcastWriter(t *testing.T) {
 	writer.Clean()
 }
 
+// blockingWriter blocks every write until unblock is closed.
+type blockingWriter struct {
+	dummyWriter
+	unblock chan struct{}
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.unblock
+	return bw.dummyWriter.Write(p)
+}
+
+func TestBroadcastWriterBuffered(t *testing.T) {
+	writer := New()
+
+	slow := &blockingWriter{unblock: make(chan struct{})}
+	writer.AddBufferedWriter(slow, "", 8)
+	fast := &dummyWriter{}
+	writer.AddWriter(fast, "")
+
+	// The slow writer must not stall the broadcast, chunks which don't fit
+	// in its buffer are dropped.
+	for _, chunk := range []string{"foo", "bar", "baz"} {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fast.String() != "foobarbaz" {
+		t.Errorf("Buffer contains %v", fast.String())
+	}
+	if dropped := writer.Dropped(); dropped != 3 {
+		t.Errorf("Dropped %d bytes, expected 3", dropped)
+	}
+
+	// Pending data is flushed on clean
+	close(slow.unblock)
+	writer.Clean()
+	if slow.String() != "foobar" {
+		t.Errorf("Buffer contains %v", slow.String())
+	}
+}
+
+func TestBroadcastWriterBufferedEviction(t *testing.T) {
+	writer := New()
+
+	failing := &dummyWriter{failOnWrite: true}
+	writer.AddBufferedWriter(failing, "", 1024)
+	writer.Write([]byte("foo"))
+
+	// The failure is noticed without another write
+	if !waitEvicted(writer) {
+		t.Fatal("Failing writer was not evicted")
+	}
+	writer.Clean()
+}
+
+// waitEvicted waits for the buffered writers of the broadcast to be evicted.
+func waitEvicted(writer *BroadcastWriter) bool {
+	for i := 0; i < 1000; i++ {
+		writer.Lock()
+		evicted := len(writer.queued) == 0
+		for _, writers := range writer.streams {
+			for w := range writers {
+				if _, ok := w.(*queueWriter); ok {
+					evicted = false
+				}
+			}
+		}
+		writer.Unlock()
+		if evicted {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+// closingWriter fails once closed, like the connection of a client which
+// detached.
+type closingWriter struct {
+	dummyWriter
+	closed chan struct{}
+}
+
+func (cw *closingWriter) Write(p []byte) (int, error) {
+	select {
+	case <-cw.closed:
+		return 0, errors.New("Fake closed")
+	default:
+	}
+	return cw.dummyWriter.Write(p)
+}
+
+func TestBroadcastWriterAttachDetach(t *testing.T) {
+	writer := New()
+	for i := 0; i < 100; i++ {
+		stream := ""
+		if i%2 == 0 {
+			stream = "stdout"
+		}
+		client := &closingWriter{closed: make(chan struct{})}
+		writer.AddBufferedWriter(client, stream, 1024)
+		if _, err := writer.Write([]byte("foo\n")); err != nil {
+			t.Fatal(err)
+		}
+		close(client.closed)
+		if _, err := writer.Write([]byte("bar\n")); err != nil {
+			t.Fatal(err)
+		}
+		if !waitEvicted(writer) {
+			t.Fatalf("The writer of the client %d was not evicted", i)
+		}
+	}
+	if stats := writer.Stats(); len(stats) != 0 {
+		t.Fatalf("Expected the detached writers to be forgotten, got %d", len(stats))
+	}
+	writer.Clean()
+}
+
+// waitWriting waits for the queued writer to write its first chunk.
+func waitWriting(w *queueWriter) {
+	for {
+		w.Lock()
+		writing := len(w.chunks) == 0
+		w.Unlock()
+		if writing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBroadcastWriterDropOldest(t *testing.T) {
+	writer := New()
+
+	slow := &blockingWriter{unblock: make(chan struct{})}
+	writer.AddQueuedWriter(slow, "", 8, DropOldest)
+	for _, chunk := range []string{"foo", "bar", "baz"} {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		if chunk == "foo" {
+			waitWriting(writer.queued[0])
+		}
+	}
+	// "foo" is being written already, "bar" makes room for "baz"
+	if stats := writer.Stats(); len(stats) != 1 || stats[0].Dropped != 3 || stats[0].Policy != DropOldest {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	close(slow.unblock)
+	writer.Clean()
+	if slow.String() != "foobaz" {
+		t.Errorf("Buffer contains %v", slow.String())
+	}
+}
+
+func TestBroadcastWriterDisconnect(t *testing.T) {
+	writer := New()
+
+	slow := &blockingWriter{unblock: make(chan struct{})}
+	writer.AddQueuedWriter(slow, "", 4, Disconnect)
+	fast := &dummyWriter{}
+	writer.AddWriter(fast, "")
+	for _, chunk := range []string{"foo", "bar"} {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(writer.streams[""]) != 1 {
+		t.Fatal("Slow writer was not evicted")
+	}
+	if fast.String() != "foobar" {
+		t.Errorf("Buffer contains %v", fast.String())
+	}
+	// the evicted writers are forgotten
+	close(slow.unblock)
+	if !waitEvicted(writer) {
+		t.Errorf("Unexpected stats %+v", writer.Stats())
+	}
+	writer.Clean()
+}
+
+func TestBroadcastWriterBlock(t *testing.T) {
+	writer := New()
+
+	slow := &blockingWriter{unblock: make(chan struct{})}
+	writer.AddQueuedWriter(slow, "", 4, Block)
+	if _, err := writer.Write([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		writer.Write([]byte("bar"))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Write didn't wait for the slow writer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(slow.unblock)
+	<-done
+	stats := writer.Stats()
+	writer.Clean()
+	if slow.String() != "foobar" {
+		t.Errorf("Buffer contains %v", slow.String())
+	}
+	if len(stats) != 1 || stats[0].Blocked < 50*time.Millisecond || stats[0].Dropped != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
 type devNullCloser int
 
 func (d devNullCloser) Close() error {
@@ -0,0 +1,251 @@
+package broadcastwriter
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// StallTimeout is how long a buffered writer may go without accepting
+	// any data while its buffer is full before it gets evicted.
+	StallTimeout = 30 * time.Second
+	// CloseTimeout is how long closing a buffered writer waits for the
+	// pending data to be flushed.
+	CloseTimeout = 5 * time.Second
+
+	errStalled    = errors.New("writer stalled")
+	errBufferFull = errors.New("writer buffer full")
+)
+
+// Policy is what a buffered writer does with a write which doesn't fit in its
+// buffer.
+type Policy int
+
+const (
+	// DropNewest drops the write.
+	DropNewest Policy = iota
+	// DropOldest drops the oldest buffered writes until the write fits.
+	DropOldest
+	// Block waits for the write to fit, stalling the broadcast, and so the
+	// writes of the container, for at most StallTimeout.
+	Block
+	// Disconnect evicts and closes the writer.
+	Disconnect
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case Block:
+		return "block"
+	case Disconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+// WriterStats are the statistics of a buffered writer.
+type WriterStats struct {
+	Stream   string
+	Policy   Policy
+	Size     int           // the size of the buffer
+	Buffered int           // the bytes waiting to be written
+	Written  int64         // the bytes written to the writer
+	Dropped  int64         // the bytes dropped as the writer was too slow
+	Blocked  time.Duration // how long the broadcast waited for the writer
+	Error    string        // why the writer was evicted, if it was
+}
+
+// queueWriter decouples a possibly slow writer from the broadcast: writes are
+// queued, up to size bytes, and written to the underlying writer from its own
+// goroutine. Writes are queued and dropped whole, so the reader never sees a
+// truncated line or frame.
+type queueWriter struct {
+	sync.Mutex
+	cond         sync.Cond
+	writer       io.WriteCloser
+	policy       Policy
+	chunks       [][]byte
+	size         int // the capacity of the queue
+	buffered     int // the bytes queued
+	closed       bool
+	err          error
+	lastProgress time.Time
+	stats        WriterStats
+	dropped      *int64 // shared by the writers of the broadcast
+	done         chan struct{}
+}
+
+func newQueueWriter(writer io.WriteCloser, stream string, size int, policy Policy, dropped *int64) *queueWriter {
+	w := &queueWriter{
+		writer:       writer,
+		policy:       policy,
+		size:         size,
+		lastProgress: time.Now(),
+		stats:        WriterStats{Stream: stream, Policy: policy, Size: size},
+		dropped:      dropped,
+		done:         make(chan struct{}),
+	}
+	w.cond.L = &w.Mutex
+	go w.flush()
+	return w
+}
+
+// Write only blocks on the underlying writer with the Block policy. It
+// returns an error once the underlying writer failed, stalled or, with the
+// Disconnect policy, fell behind, so the broadcast evicts it.
+func (w *queueWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if w.buffered == 0 {
+		w.lastProgress = time.Now()
+	}
+	if len(p) > w.size-w.buffered {
+		switch w.policy {
+		case DropOldest:
+			for len(w.chunks) > 0 && len(p) > w.size-w.buffered {
+				w.drop(len(w.chunks[0]))
+				w.buffered -= len(w.chunks[0])
+				w.chunks = w.chunks[1:]
+			}
+		case Block:
+			if err := w.waitRoom(len(p)); err != nil {
+				return 0, err
+			}
+		case Disconnect:
+			w.fail(errBufferFull)
+			return 0, w.err
+		}
+		// with the Block policy, a write larger than the queue goes alone
+		if len(p) > w.size-w.buffered && (w.policy != Block || w.buffered > 0) {
+			if w.buffered > 0 && time.Since(w.lastProgress) > StallTimeout {
+				w.fail(errStalled)
+				return 0, w.err
+			}
+			w.drop(len(p))
+			return len(p), nil
+		}
+	}
+	w.chunks = append(w.chunks, append([]byte(nil), p...))
+	w.buffered += len(p)
+	w.cond.Broadcast()
+	return len(p), nil
+}
+
+// waitRoom waits for n bytes to fit in the queue, or for the queue to be
+// empty if they never will. It must be called with the lock held.
+func (w *queueWriter) waitRoom(n int) error {
+	start := time.Now()
+	timeout := time.AfterFunc(StallTimeout, func() {
+		w.Lock()
+		w.cond.Broadcast()
+		w.Unlock()
+	})
+	defer timeout.Stop()
+	for w.buffered > 0 && n > w.size-w.buffered && w.err == nil && !w.closed {
+		if time.Since(start) >= StallTimeout {
+			w.fail(errStalled)
+			break
+		}
+		w.cond.Wait()
+	}
+	w.stats.Blocked += time.Since(start)
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+func (w *queueWriter) drop(n int) {
+	w.stats.Dropped += int64(n)
+	atomic.AddInt64(w.dropped, int64(n))
+}
+
+func (w *queueWriter) flush() {
+	defer close(w.done)
+	for {
+		w.Lock()
+		for len(w.chunks) == 0 && !w.closed && w.err == nil {
+			w.cond.Wait()
+		}
+		if w.err != nil || len(w.chunks) == 0 {
+			w.Unlock()
+			return
+		}
+		chunk := w.chunks[0]
+		w.chunks = w.chunks[1:]
+		w.Unlock()
+
+		_, err := w.writer.Write(chunk)
+
+		w.Lock()
+		if err != nil {
+			w.fail(err)
+			w.Unlock()
+			return
+		}
+		w.buffered -= len(chunk)
+		w.stats.Written += int64(len(chunk))
+		w.lastProgress = time.Now()
+		w.cond.Broadcast()
+		w.Unlock()
+	}
+}
+
+// fail records err and closes the underlying writer, which also unblocks a
+// pending write to it. It must be called with the lock held.
+func (w *queueWriter) fail(err error) {
+	if w.err == nil {
+		w.err = err
+		w.stats.Error = err.Error()
+		w.writer.Close()
+		w.cond.Broadcast()
+	}
+}
+
+// Stats returns the statistics of the writer.
+func (w *queueWriter) Stats() WriterStats {
+	w.Lock()
+	defer w.Unlock()
+	stats := w.stats
+	stats.Buffered = w.buffered
+	return stats
+}
+
+// Close flushes the pending data, waiting at most CloseTimeout, and closes
+// the underlying writer.
+func (w *queueWriter) Close() error {
+	w.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(CloseTimeout):
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	if w.err != nil {
+		// Already closed by fail
+		return nil
+	}
+	w.err = io.ErrClosedPipe
+	return w.writer.Close()
+}
@@ -4,11 +4,53 @@ package graphdb
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"os"
+	"time"
 
 	_ "code.google.com/p/gosqlite/sqlite3" // registers sqlite
+	"github.com/docker/docker/pkg/log"
 )
 
+// BusyTimeout is how long a statement waits for a lock held by another
+// connection before failing with "database is locked".
+var BusyTimeout = 5 * time.Second
+
+func init() {
+	// sql.Open doesn't connect
+	db, _ := sql.Open("sqlite3", "")
+	sql.Register("sqlite3-graphdb", busyDriver{db.Driver()})
+}
+
+// busyDriver sets the busy timeout of the connections of the sqlite3 driver,
+// a setting of each connection.
+type busyDriver struct {
+	driver.Driver
+}
+
+func (d busyDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := conn.Prepare(fmt.Sprintf("PRAGMA busy_timeout = %d;", BusyTimeout/time.Millisecond))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	rows, err := stmt.Query(nil)
+	if err == nil {
+		rows.Close()
+	}
+	stmt.Close()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
 func NewSqliteConn(root string) (*Database, error) {
 	initDatabase := false
 
@@ -25,10 +67,21 @@ func NewSqliteConn(root string) (*Database, error) {
 		initDatabase = true
 	}
 
-	conn, err := sql.Open("sqlite3", root)
+	conn, err := sql.Open("sqlite3-graphdb", root)
 	if err != nil {
 		return nil, err
 	}
 
+	// The readers don't block the writer in WAL mode, which is saved in the
+	// database. Some filesystems don't support it.
+	var mode string
+	if err := conn.QueryRow("PRAGMA journal_mode = WAL;").Scan(&mode); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if mode != "wal" {
+		log.Infof("The graph database %s is in %s journal mode, WAL is not supported", root, mode)
+	}
+
 	return NewDatabase(conn, initDatabase)
 }
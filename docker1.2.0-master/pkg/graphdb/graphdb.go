@@ -2,10 +2,11 @@ package graphdb
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"path"
 	"strings"
-	"sync"
+	"time"
 )
 
 const (
@@ -46,10 +47,48 @@ type Edges []*Edge
 
 type WalkFunc func(fullPath string, entity *Entity) error
 
-// Graph database for storing entities and their relationships
+// Graph database for storing entities and their relationships. The writes
+// are serialized by a goroutine, each in its own transaction, while the
+// reads run concurrently.
 type Database struct {
-	conn *sql.DB
-	mux  sync.RWMutex
+	conn   *sql.DB
+	writes chan *write
+	closed chan struct{}
+}
+
+// write is a transaction run by the writer of a database.
+type write struct {
+	fn   func(tx *sql.Tx) error
+	done chan error
+}
+
+// querier is a connection or a transaction.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+var (
+	errDatabaseClosed = errors.New("Database is closed")
+
+	// RetryAttempts is how many times a write failing because the database
+	// is locked is attempted, waiting twice longer before each new attempt
+	// from RetryDelay on.
+	RetryAttempts = 5
+	RetryDelay    = 10 * time.Millisecond
+)
+
+// IsLockedError returns true if err is a transient failure to lock the
+// database.
+func IsLockedError(err error) bool {
+	str := err.Error()
+	// SQLITE_BUSY and SQLITE_LOCKED, as returned by sqlite3_errmsg and the
+	// driver
+	return strings.Contains(str, "database is locked") ||
+		strings.Contains(str, "database table is locked") ||
+		strings.Contains(str, "The database file is locked") ||
+		strings.Contains(str, "A table in the database is locked")
 }
 
 func IsNonUniqueNameError(err error) bool {
@@ -77,38 +116,30 @@ func NewDatabase(conn *sql.DB, init bool) (*Database, error) {
 	if conn == nil {
 		return nil, fmt.Errorf("Database connection cannot be nil")
 	}
-	db := &Database{conn: conn}
+	db := &Database{
+		conn:   conn,
+		writes: make(chan *write),
+		closed: make(chan struct{}),
+	}
+	go db.writer()
 
 	if init {
-		if _, err := conn.Exec(createEntityTable); err != nil {
-			return nil, err
-		}
-		if _, err := conn.Exec(createEdgeTable); err != nil {
-			return nil, err
-		}
-		if _, err := conn.Exec(createEdgeIndices); err != nil {
-			return nil, err
-		}
-
-		rollback := func() {
-			conn.Exec("ROLLBACK")
-		}
-
-		// Create root entities
-		if _, err := conn.Exec("BEGIN"); err != nil {
-			return nil, err
-		}
-		if _, err := conn.Exec("INSERT INTO entity (id) VALUES (?);", "0"); err != nil {
-			rollback()
-			return nil, err
-		}
-
-		if _, err := conn.Exec("INSERT INTO edge (entity_id, name) VALUES(?,?);", "0", "/"); err != nil {
-			rollback()
-			return nil, err
-		}
+		err := db.write(func(tx *sql.Tx) error {
+			for _, statement := range []string{createEntityTable, createEdgeTable, createEdgeIndices} {
+				if _, err := tx.Exec(statement); err != nil {
+					return err
+				}
+			}
 
-		if _, err := conn.Exec("COMMIT"); err != nil {
+			// Create root entities
+			if _, err := tx.Exec("INSERT INTO entity (id) VALUES (?);", "0"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("INSERT INTO edge (entity_id, name) VALUES(?,?);", "0", "/")
+			return err
+		})
+		if err != nil {
+			db.Close()
 			return nil, err
 		}
 	}
@@ -117,41 +148,93 @@ func NewDatabase(conn *sql.DB, init bool) (*Database, error) {
 
 // Close the underlying connection to the database
 func (db *Database) Close() error {
+	select {
+	case <-db.closed:
+	default:
+		close(db.closed)
+	}
 	return db.conn.Close()
 }
 
-// Set the entity id for a given path
-func (db *Database) Set(fullPath, id string) (*Entity, error) {
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
-	rollback := func() {
-		db.conn.Exec("ROLLBACK")
+// write runs fn in a transaction from the writer of the database.
+func (db *Database) write(fn func(tx *sql.Tx) error) error {
+	w := &write{fn: fn, done: make(chan error, 1)}
+	select {
+	case db.writes <- w:
+		return <-w.done
+	case <-db.closed:
+		return errDatabaseClosed
 	}
-	if _, err := db.conn.Exec("BEGIN EXCLUSIVE"); err != nil {
-		return nil, err
+}
+
+func (db *Database) writer() {
+	for {
+		select {
+		case w := <-db.writes:
+			w.done <- db.transaction(w.fn)
+		case <-db.closed:
+			return
+		}
 	}
-	var entityId string
-	if err := db.conn.QueryRow("SELECT id FROM entity WHERE id = ?;", id).Scan(&entityId); err != nil {
-		if err == sql.ErrNoRows {
-			if _, err := db.conn.Exec("INSERT INTO entity (id) VALUES(?);", id); err != nil {
-				rollback()
-				return nil, err
+}
+
+// transaction runs fn in a transaction, again while the database is locked
+// by another connection, up to RetryAttempts times.
+func (db *Database) transaction(fn func(tx *sql.Tx) error) error {
+	var err error
+	delay := RetryDelay
+	for i := 0; i < RetryAttempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		var tx *sql.Tx
+		if tx, err = db.conn.Begin(); err != nil {
+			if IsLockedError(err) {
+				continue
 			}
-		} else {
-			rollback()
-			return nil, err
+			return err
 		}
+		if err = fn(tx); err != nil {
+			tx.Rollback()
+			if IsLockedError(err) {
+				continue
+			}
+			return err
+		}
+		if err = tx.Commit(); err == nil || !IsLockedError(err) {
+			return err
+		}
+		// the transaction is still open after a failed commit
+		tx.Rollback()
 	}
-	e := &Entity{id}
+	return err
+}
 
-	parentPath, name := splitPath(fullPath)
-	if err := db.setEdge(parentPath, name, e); err != nil {
-		rollback()
-		return nil, err
-	}
+// Ping checks the database can still be queried
+func (db *Database) Ping() error {
+	var count int
+	return db.conn.QueryRow("SELECT COUNT(*) FROM entity WHERE id = ?;", "0").Scan(&count)
+}
 
-	if _, err := db.conn.Exec("COMMIT"); err != nil {
+// Set the entity id for a given path
+func (db *Database) Set(fullPath, id string) (*Entity, error) {
+	e := &Entity{id}
+	err := db.write(func(tx *sql.Tx) error {
+		var entityId string
+		if err := tx.QueryRow("SELECT id FROM entity WHERE id = ?;", id).Scan(&entityId); err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+			if _, err := tx.Exec("INSERT INTO entity (id) VALUES(?);", id); err != nil {
+				return err
+			}
+		}
+
+		parentPath, name := splitPath(fullPath)
+		return setEdge(tx, parentPath, name, e)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return e, nil
@@ -159,18 +242,15 @@ func (db *Database) Set(fullPath, id string) (*Entity, error) {
 
 // Return true if a name already exists in the database
 func (db *Database) Exists(name string) bool {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-
-	e, err := db.get(name)
+	e, err := get(db.conn, name)
 	if err != nil {
 		return false
 	}
 	return e != nil
 }
 
-func (db *Database) setEdge(parentPath, name string, e *Entity) error {
-	parent, err := db.get(parentPath)
+func setEdge(tx querier, parentPath, name string, e *Entity) error {
+	parent, err := get(tx, parentPath)
 	if err != nil {
 		return err
 	}
@@ -178,7 +258,7 @@ func (db *Database) setEdge(parentPath, name string, e *Entity) error {
 		return fmt.Errorf("Cannot set self as child")
 	}
 
-	if _, err := db.conn.Exec("INSERT INTO edge (parent_id, name, entity_id) VALUES (?,?,?);", parent.id, name, e.id); err != nil {
+	if _, err := tx.Exec("INSERT INTO edge (parent_id, name, entity_id) VALUES (?,?,?);", parent.id, name, e.id); err != nil {
 		return err
 	}
 	return nil
@@ -186,6 +266,10 @@ func (db *Database) setEdge(parentPath, name string, e *Entity) error {
 
 // Return the root "/" entity for the database
 func (db *Database) RootEntity() *Entity {
+	return rootEntity()
+}
+
+func rootEntity() *Entity {
 	return &Entity{
 		id: "0",
 	}
@@ -193,18 +277,15 @@ func (db *Database) RootEntity() *Entity {
 
 // Return the entity for a given path
 func (db *Database) Get(name string) *Entity {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-
-	e, err := db.get(name)
+	e, err := get(db.conn, name)
 	if err != nil {
 		return nil
 	}
 	return e
 }
 
-func (db *Database) get(name string) (*Entity, error) {
-	e := db.RootEntity()
+func get(q querier, name string) (*Entity, error) {
+	e := rootEntity()
 	// We always know the root name so return it if
 	// it is requested
 	if name == "/" {
@@ -218,7 +299,7 @@ func (db *Database) get(name string) (*Entity, error) {
 			continue
 		}
 
-		next := db.child(e, p)
+		next := child(q, e, p)
 		if next == nil {
 			return nil, fmt.Errorf("Cannot find child for %s", name)
 		}
@@ -231,11 +312,8 @@ func (db *Database) get(name string) (*Entity, error) {
 // List all entities by from the name
 // The key will be the full path of the entity
 func (db *Database) List(name string, depth int) Entities {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-
 	out := Entities{}
-	e, err := db.get(name)
+	e, err := get(db.conn, name)
 	if err != nil {
 		return out
 	}
@@ -270,10 +348,7 @@ func (db *Database) Walk(name string, walkFunc WalkFunc, depth int) error {
 
 // Return the children of the specified entity
 func (db *Database) Children(name string, depth int) ([]WalkMeta, error) {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-
-	e, err := db.get(name)
+	e, err := get(db.conn, name)
 	if err != nil {
 		return nil, err
 	}
@@ -283,9 +358,6 @@ func (db *Database) Children(name string, depth int) ([]WalkMeta, error) {
 
 // Return the refrence count for a specified id
 func (db *Database) Refs(id string) int {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-
 	var count int
 	if err := db.conn.QueryRow("SELECT COUNT(*) FROM edge WHERE entity_id = ?;", id).Scan(&count); err != nil {
 		return 0
@@ -295,9 +367,6 @@ func (db *Database) Refs(id string) int {
 
 // Return all the id's path references
 func (db *Database) RefPaths(id string) Edges {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-
 	refs := Edges{}
 
 	rows, err := db.conn.Query("SELECT name, parent_id FROM edge WHERE entity_id = ?;", id)
@@ -323,69 +392,50 @@ func (db *Database) RefPaths(id string) Edges {
 
 // Delete the reference to an entity at a given path
 func (db *Database) Delete(name string) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
 	if name == "/" {
 		return fmt.Errorf("Cannot delete root entity")
 	}
 
-	parentPath, n := splitPath(name)
-	parent, err := db.get(parentPath)
-	if err != nil {
-		return err
-	}
+	return db.write(func(tx *sql.Tx) error {
+		parentPath, n := splitPath(name)
+		parent, err := get(tx, parentPath)
+		if err != nil {
+			return err
+		}
 
-	if _, err := db.conn.Exec("DELETE FROM edge WHERE parent_id = ? AND name = ?;", parent.id, n); err != nil {
+		_, err = tx.Exec("DELETE FROM edge WHERE parent_id = ? AND name = ?;", parent.id, n)
 		return err
-	}
-	return nil
+	})
 }
 
 // Remove the entity with the specified id
 // Walk the graph to make sure all references to the entity
 // are removed and return the number of references removed
 func (db *Database) Purge(id string) (int, error) {
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
-	rollback := func() {
-		db.conn.Exec("ROLLBACK")
-	}
-
-	if _, err := db.conn.Exec("BEGIN"); err != nil {
-		return -1, err
-	}
+	var changes int64
+	err := db.write(func(tx *sql.Tx) error {
+		// Delete all edges
+		rows, err := tx.Exec("DELETE FROM edge WHERE entity_id = ?;", id)
+		if err != nil {
+			return err
+		}
 
-	// Delete all edges
-	rows, err := db.conn.Exec("DELETE FROM edge WHERE entity_id = ?;", id)
-	if err != nil {
-		rollback()
-		return -1, err
-	}
+		if changes, err = rows.RowsAffected(); err != nil {
+			return err
+		}
 
-	changes, err := rows.RowsAffected()
+		// Delete entity
+		_, err = tx.Exec("DELETE FROM entity where id = ?;", id)
+		return err
+	})
 	if err != nil {
 		return -1, err
 	}
-
-	// Delete entity
-	if _, err := db.conn.Exec("DELETE FROM entity where id = ?;", id); err != nil {
-		rollback()
-		return -1, err
-	}
-
-	if _, err := db.conn.Exec("COMMIT"); err != nil {
-		return -1, err
-	}
 	return int(changes), nil
 }
 
 // Rename an edge for a given path
 func (db *Database) Rename(currentName, newName string) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
 	parentPath, name := splitPath(currentName)
 	newParentPath, newEdgeName := splitPath(newName)
 
@@ -393,23 +443,25 @@ func (db *Database) Rename(currentName, newName string) error {
 		return fmt.Errorf("Cannot rename when root paths do not match %s != %s", parentPath, newParentPath)
 	}
 
-	parent, err := db.get(parentPath)
-	if err != nil {
-		return err
-	}
+	return db.write(func(tx *sql.Tx) error {
+		parent, err := get(tx, parentPath)
+		if err != nil {
+			return err
+		}
 
-	rows, err := db.conn.Exec("UPDATE edge SET name = ? WHERE parent_id = ? AND name = ?;", newEdgeName, parent.id, name)
-	if err != nil {
-		return err
-	}
-	i, err := rows.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if i == 0 {
-		return fmt.Errorf("Cannot locate edge for %s %s", parent.id, name)
-	}
-	return nil
+		rows, err := tx.Exec("UPDATE edge SET name = ? WHERE parent_id = ? AND name = ?;", newEdgeName, parent.id, name)
+		if err != nil {
+			return err
+		}
+		i, err := rows.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			return fmt.Errorf("Cannot locate edge for %s %s", parent.id, name)
+		}
+		return nil
+	})
 }
 
 type WalkMeta struct {
@@ -467,9 +519,9 @@ func (db *Database) children(e *Entity, name string, depth int, entities []WalkM
 }
 
 // Return the entity based on the parent path and name
-func (db *Database) child(parent *Entity, name string) *Entity {
+func child(q querier, parent *Entity, name string) *Entity {
 	var id string
-	if err := db.conn.QueryRow("SELECT entity_id FROM edge WHERE parent_id = ? AND name = ?;", parent.id, name).Scan(&id); err != nil {
+	if err := q.QueryRow("SELECT entity_id FROM edge WHERE parent_id = ? AND name = ?;", parent.id, name).Scan(&id); err != nil {
 		return nil
 	}
 	return &Entity{id}
@@ -2,6 +2,7 @@ package graphdb
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -43,6 +44,19 @@ func TestCreateRootEnity(t *testing.T) {
 	}
 }
 
+func TestPing(t *testing.T) {
+	db, dbpath := newTestDb(t)
+	defer destroyTestDb(dbpath)
+
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if err := db.Ping(); err == nil {
+		t.Fatal("Ping should fail once the database is closed")
+	}
+}
+
 func TestGetRootEntity(t *testing.T) {
 	db, dbpath := newTestDb(t)
 	defer destroyTestDb(dbpath)
@@ -539,3 +553,66 @@ func TestConcurrentWrites(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestConcurrentWritesAndReads(t *testing.T) {
+	p := path.Join(os.TempDir(), "sqlite-concurrent.db")
+	os.Remove(p)
+	defer os.Remove(p)
+	defer os.Remove(p + "-wal")
+	defer os.Remove(p + "-shm")
+
+	db, err := NewSqliteConn(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	errs := make(chan error, 100)
+	for i := 0; i < 50; i++ {
+		go func(i int) {
+			name, id := fmt.Sprintf("/%d", i), strconv.Itoa(i+1)
+			if _, err := db.Set(name, id); err != nil {
+				errs <- err
+				return
+			}
+			if !db.Exists(name) {
+				errs <- fmt.Errorf("%s should exist", name)
+				return
+			}
+			errs <- db.Delete(name)
+		}(i)
+		go func() {
+			_, err := db.Children("/", 1)
+			errs <- err
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if refs := db.Refs("0"); refs != 1 {
+		t.Fatalf("Expected only the root edge, got %d edges", refs)
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	db, dbpath := newTestDb(t)
+	defer destroyTestDb(dbpath)
+
+	db.Close()
+	if _, err := db.Set("/webapp", "1"); err != errDatabaseClosed {
+		t.Fatalf("Expected %s, got %v", errDatabaseClosed, err)
+	}
+}
+
+func TestIsLockedError(t *testing.T) {
+	for _, msg := range []string{"database is locked", "sqlite3: database table is locked"} {
+		if !IsLockedError(errors.New(msg)) {
+			t.Errorf("%q should be a locked error", msg)
+		}
+	}
+	if IsLockedError(errors.New("UNIQUE constraint failed: edge.parent_id, edge.name")) {
+		t.Error("A constraint error should not be a locked error")
+	}
+}
@@ -23,9 +23,12 @@ var (
 	supportsXlock       = false
 )
 
+// Chain is a chain of a table, e.g. Chain{Table: "nat", Name: "POSTROUTING"},
+// the chain of the containers' ports also having the bridge they are on.
 type Chain struct {
 	Name   string
 	Bridge string
+	Table  string // filter if empty
 }
 
 func init() {
@@ -41,6 +44,7 @@ func NewChain(name, bridge string) (*Chain, error) {
 	chain := &Chain{
 		Name:   name,
 		Bridge: bridge,
+		Table:  "nat",
 	}
 
 	if err := chain.Prerouting(Add, "-m", "addrtype", "--dst-type", "LOCAL"); err != nil {
@@ -54,7 +58,8 @@ func NewChain(name, bridge string) (*Chain, error) {
 
 func RemoveExistingChain(name string) error {
 	chain := &Chain{
-		Name: name,
+		Name:  name,
+		Table: "nat",
 	}
 	return chain.Remove()
 }
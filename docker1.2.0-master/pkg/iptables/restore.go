@@ -0,0 +1,115 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const Insert Action = "-I"
+
+// The built-in chains the rules of the daemon go to.
+var (
+	Forward     = Chain{Name: "FORWARD"}
+	Postrouting = Chain{Name: "POSTROUTING", Table: "nat"}
+)
+
+// Rule is a rule of a batch committed by Apply, e.g.
+// Rule{Action: Insert, Chain: Postrouting, Args: []string{"-j", "MASQUERADE"}}
+type Rule struct {
+	Action Action
+	Chain  Chain
+	Args   []string
+}
+
+func (r Rule) table() string {
+	if r.Chain.Table == "" {
+		return "filter"
+	}
+	return r.Chain.Table
+}
+
+// spec returns the rule as the arguments of iptables, without its table.
+func (r Rule) spec() []string {
+	return append([]string{string(r.Action), r.Chain.Name}, r.Args...)
+}
+
+var (
+	supportsRestoreXlock bool
+	restoreXlockOnce     sync.Once
+)
+
+// Apply commits the rules with a single iptables-restore, instead of one
+// iptables per rule. The rules of a table are committed all or none, in
+// order, the tables in the order of their first rule. Without
+// iptables-restore, the rules are applied one by one.
+func Apply(rules ...Rule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	path, err := exec.LookPath("iptables-restore")
+	if err != nil {
+		for _, r := range rules {
+			if output, err := Raw(append([]string{"-t", r.table()}, r.spec()...)...); err != nil {
+				return err
+			} else if len(output) != 0 {
+				return fmt.Errorf("Error iptables %s: %s", r.Chain.Name, output)
+			}
+		}
+		return nil
+	}
+
+	restoreXlockOnce.Do(func() {
+		supportsRestoreXlock = exec.Command(path, "--wait", "--test", "--noflush").Run() == nil
+	})
+	args := []string{"--noflush"}
+	if supportsRestoreXlock {
+		args = append(args, "--wait")
+	}
+
+	input := render(rules)
+	if os.Getenv("DEBUG") != "" {
+		fmt.Fprintf(os.Stderr, "[debug] %s, %v\n%s", path, args, input)
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("iptables-restore failed: %s (%s)", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// render returns the input of iptables-restore committing the rules.
+func render(rules []Rule) []byte {
+	var (
+		tables []string
+		lines  = make(map[string][]string)
+	)
+	for _, r := range rules {
+		table := r.table()
+		if _, exists := lines[table]; !exists {
+			tables = append(tables, table)
+		}
+		spec := r.spec()
+		for i, arg := range spec {
+			if arg == "" || strings.ContainsAny(arg, " \t\"") {
+				spec[i] = fmt.Sprintf("%q", arg)
+			}
+		}
+		lines[table] = append(lines[table], strings.Join(spec, " "))
+	}
+
+	var buf bytes.Buffer
+	for _, table := range tables {
+		fmt.Fprintf(&buf, "*%s\n", table)
+		for _, line := range lines[table] {
+			fmt.Fprintln(&buf, line)
+		}
+		fmt.Fprintln(&buf, "COMMIT")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,32 @@
+package iptables
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	rules := []Rule{
+		{Action: Insert, Chain: Forward, Args: []string{"-i", "docker0", "-o", "docker0", "-j", "ACCEPT"}},
+		{Action: Insert, Chain: Postrouting, Args: []string{"-s", "172.17.0.0/16", "!", "-o", "docker0", "-j", "MASQUERADE"}},
+		{Action: Delete, Chain: Chain{Name: "FORWARD", Table: "filter"}, Args: []string{"-m", "comment", "--comment", "linked containers", "-j", "DROP"}},
+		{Action: Add, Chain: Chain{Name: "DOCKER", Table: "nat"}, Args: []string{"-p", "tcp", "--dport", "80", "-j", "DNAT", "--to-destination", "172.17.0.2:80"}},
+	}
+	expected := `*filter
+-I FORWARD -i docker0 -o docker0 -j ACCEPT
+-D FORWARD -m comment --comment "linked containers" -j DROP
+COMMIT
+*nat
+-I POSTROUTING -s 172.17.0.0/16 ! -o docker0 -j MASQUERADE
+-A DOCKER -p tcp --dport 80 -j DNAT --to-destination 172.17.0.2:80
+COMMIT
+`
+	if output := string(render(rules)); output != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	if output := render(nil); len(output) != 0 {
+		t.Fatalf("Expected no output, got %q", output)
+	}
+}
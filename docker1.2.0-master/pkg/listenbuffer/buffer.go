@@ -5,29 +5,171 @@
 */
 package listenbuffer
 
-import "net"
+import (
+	"errors"
+	"expvar"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManyConnections rejects the connections over the maximum held.
+	ErrTooManyConnections = errors.New("Too many connections waiting for the daemon to start, try again later")
+	// ErrActivationTimeout rejects the connections once the listener has
+	// waited for its activation longer than its timeout.
+	ErrActivationTimeout = errors.New("The daemon did not start in time, try again later")
+)
+
+// stats counts the connections held, rejected because too many were held,
+// rejected after the activation timeout and closed as they didn't send
+// anything before the handshake timeout, by all the listeners. It also
+// records how long the connections handed after the activation waited, in
+// total and at most, and how long the oldest connection held has been
+// waiting.
+var (
+	stats         = expvar.NewMap("listenbuffer")
+	maxWaited     = new(expvar.Int)
+	maxWaitedLock sync.Mutex
+
+	// listeners are the listeners holding connections, for oldest_held_ms
+	listeners = struct {
+		sync.Mutex
+		m map[*defaultListener]struct{}
+	}{m: make(map[*defaultListener]struct{})}
+)
+
+func init() {
+	stats.Set("max_waited_ms", maxWaited)
+	stats.Set("oldest_held_ms", expvar.Func(func() interface{} {
+		return int64(oldestHeld() / time.Millisecond)
+	}))
+}
+
+// oldestHeld returns how long the oldest connection held by any listener
+// has been waiting.
+func oldestHeld() time.Duration {
+	listeners.Lock()
+	defer listeners.Unlock()
+
+	var oldest time.Duration
+	for l := range listeners.m {
+		l.Lock()
+		if len(l.held) > 0 {
+			if waited := time.Since(l.held[0].since); waited > oldest {
+				oldest = waited
+			}
+		}
+		l.Unlock()
+	}
+	return oldest
+}
+
+// Options bound the connections held until the activation.
+type Options struct {
+	// MaxConns is the number of connections held at most, 0 for no limit
+	MaxConns int
+	// Timeout is how long the connections are held, 0 for no limit. The
+	// connections held are rejected after it, as are the next ones until
+	// the activation.
+	Timeout time.Duration
+	// HandshakeTimeout is how long the connections held may go without
+	// sending anything, 0 for no limit, after which they are closed. The
+	// connections are then read from as they are held, which wraps them.
+	HandshakeTimeout time.Duration
+	// Reject is called with the connections rejected, before they are
+	// closed, to tell their client why.
+	Reject func(conn net.Conn, err error)
+}
 
 // NewListenBuffer returns a listener listening on addr with the protocol.
 //让 Docker Se er 立即监昕指定协议地址上的请求，但是将这些
 //请求暂时先缓存下来，等 Docker Daemon 全部启动完毕之后，才让 Docker Server 开始接受
 //这些请求。这样设计有一个很大的好处，那就是可以保证在 Docker Daemon 还没有完全启动
 //完毕之前，接收并缓存尽可能多的用户请求。
-func NewListenBuffer(proto, addr string, activate chan struct{}) (net.Listener, error) {
+func NewListenBuffer(proto, addr string, activate chan struct{}, opts Options) (net.Listener, error) {
 	wrapped, err := net.Listen(proto, addr)
 	if err != nil {
 		return nil, err
 	}
 
-	return &defaultListener{
+	return Buffer(wrapped, activate, opts), nil
+}
+
+// Buffer holds the connections of a listener already listening, like a
+// socket activated one, until activate is closed.
+func Buffer(wrapped net.Listener, activate chan struct{}, opts Options) net.Listener {
+	l := &defaultListener{
 		wrapped:  wrapped,
 		activate: activate,
-	}, nil
+		opts:     opts,
+		accepted: make(chan accepted),
+	}
+	listeners.Lock()
+	listeners.m[l] = struct{}{}
+	listeners.Unlock()
+	go l.run()
+	return l
+}
+
+// Unwrap returns the connection accepted by the wrapped listener of conn, a
+// connection returned by the Accept of a listener buffer.
+func Unwrap(conn net.Conn) net.Conn {
+	if h, ok := conn.(*heldConn); ok {
+		return h.Conn
+	}
+	return conn
+}
+
+type accepted struct {
+	conn net.Conn
+	err  error
 }
 
 type defaultListener struct {
+	sync.Mutex
 	wrapped  net.Listener // the real listener to wrap
-	ready    bool         // is the listner ready to start accpeting connections
 	activate chan struct{}
+	opts     Options
+	accepted chan accepted // the connections handed to Accept
+	held     []*heldConn   // the connections held, oldest first
+	err      error         // the error of the wrapped listener, once closed
+}
+
+// heldConn is a connection held until the activation. With a handshake
+// timeout, it reads the first bytes sent by the client as it is held and
+// replays them.
+type heldConn struct {
+	net.Conn
+	since  time.Time
+	peeked chan struct{} // closed once the first bytes are read
+	first  []byte
+	err    error
+}
+
+func (h *heldConn) Read(p []byte) (int, error) {
+	<-h.peeked
+	if len(h.first) > 0 {
+		n := copy(p, h.first)
+		h.first = h.first[n:]
+		return n, nil
+	}
+	if h.err != nil {
+		return 0, h.err
+	}
+	return h.Conn.Read(p)
+}
+
+// SetDeadline and SetReadDeadline wait for the first bytes, not to be
+// overridden by the end of the handshake.
+func (h *heldConn) SetDeadline(t time.Time) error {
+	<-h.peeked
+	return h.Conn.SetDeadline(t)
+}
+
+func (h *heldConn) SetReadDeadline(t time.Time) error {
+	<-h.peeked
+	return h.Conn.SetReadDeadline(t)
 }
 
 func (l *defaultListener) Close() error {
@@ -39,12 +181,177 @@ func (l *defaultListener) Addr() net.Addr {
 }
 
 func (l *defaultListener) Accept() (net.Conn, error) {
-	// if the listen has been told it is ready then we can go ahead and
-	// start returning connections
-	if l.ready {
-		return l.wrapped.Accept()
-	}
-	<-l.activate
-	l.ready = true
-	return l.Accept()
+	a, ok := <-l.accepted
+	if !ok {
+		return nil, l.err
+	}
+	return a.conn, a.err
+}
+
+// run accepts the connections of the wrapped listener, holding them until
+// the activation and handing them to Accept after it.
+func (l *defaultListener) run() {
+	defer close(l.accepted)
+	defer func() {
+		listeners.Lock()
+		delete(listeners.m, l)
+		listeners.Unlock()
+	}()
+
+	conns := make(chan accepted)
+	go func() {
+		for {
+			conn, err := l.wrapped.Accept()
+			conns <- accepted{conn, err}
+			if err != nil {
+				if !isTemporary(err) {
+					return
+				}
+				// Out of file descriptors, for instance
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}()
+
+	var (
+		expired bool
+		timeout <-chan time.Time
+	)
+	if l.opts.Timeout > 0 {
+		timeout = time.After(l.opts.Timeout)
+	}
+	for activate := l.activate; activate != nil; {
+		select {
+		case <-activate:
+			activate = nil
+		case <-timeout:
+			expired = true
+			for _, h := range l.release() {
+				l.reject(h.Conn, ErrActivationTimeout, "expired")
+			}
+		case a := <-conns:
+			switch {
+			case a.err != nil:
+				if isTemporary(a.err) {
+					continue
+				}
+				for _, h := range l.release() {
+					h.Conn.Close()
+				}
+				l.err = a.err
+				return
+			case expired:
+				l.reject(a.conn, ErrActivationTimeout, "expired")
+			case l.opts.MaxConns > 0 && l.heldCount() >= l.opts.MaxConns:
+				l.reject(a.conn, ErrTooManyConnections, "rejected")
+			default:
+				l.hold(a.conn)
+			}
+		}
+	}
+
+	for _, h := range l.release() {
+		recordWait(time.Since(h.since))
+		if h.peeked != nil {
+			l.accepted <- accepted{conn: h}
+		} else {
+			l.accepted <- accepted{conn: h.Conn}
+		}
+	}
+	for a := range conns {
+		if a.err != nil && !isTemporary(a.err) {
+			l.err = a.err
+			return
+		}
+		l.accepted <- a
+	}
+}
+
+// recordWait records how long a connection handed after the activation
+// waited.
+func recordWait(waited time.Duration) {
+	ms := int64(waited / time.Millisecond)
+	stats.Add("waited_ms", ms)
+	maxWaitedLock.Lock()
+	if ms > maxWaited.Value() {
+		maxWaited.Set(ms)
+	}
+	maxWaitedLock.Unlock()
+}
+
+// hold holds conn until the activation, reading its first bytes with a
+// handshake timeout.
+func (l *defaultListener) hold(conn net.Conn) {
+	h := &heldConn{Conn: conn, since: time.Now()}
+	if l.opts.HandshakeTimeout > 0 {
+		h.peeked = make(chan struct{})
+		go l.handshake(h)
+	}
+	l.Lock()
+	l.held = append(l.held, h)
+	l.Unlock()
+	stats.Add("held", 1)
+}
+
+// handshake reads the first bytes of h, closing it if it is still held when
+// its client closed it or sent nothing before the handshake timeout.
+func (l *defaultListener) handshake(h *heldConn) {
+	defer close(h.peeked)
+
+	h.Conn.SetReadDeadline(h.since.Add(l.opts.HandshakeTimeout))
+	buf := make([]byte, 4096)
+	n, err := h.Conn.Read(buf)
+	h.first, h.err = buf[:n], err
+	if n > 0 {
+		h.Conn.SetReadDeadline(time.Time{})
+		return
+	}
+
+	l.Lock()
+	held := false
+	for i, c := range l.held {
+		if c == h {
+			l.held = append(l.held[:i], l.held[i+1:]...)
+			held = true
+			break
+		}
+	}
+	l.Unlock()
+	if held {
+		stats.Add("held", -1)
+		stats.Add("idle", 1)
+		h.Conn.Close()
+	}
+}
+
+// release returns the connections held, which are no longer.
+func (l *defaultListener) release() []*heldConn {
+	l.Lock()
+	held := l.held
+	l.held = nil
+	l.Unlock()
+	stats.Add("held", int64(-len(held)))
+	return held
+}
+
+func (l *defaultListener) heldCount() int {
+	l.Lock()
+	defer l.Unlock()
+	return len(l.held)
+}
+
+// reject tells the client of conn why it is rejected and closes it, the
+// rejection being counted as what.
+func (l *defaultListener) reject(conn net.Conn, err error, what string) {
+	stats.Add(what, 1)
+	if l.opts.Reject != nil {
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		l.opts.Reject(conn, err)
+	}
+	conn.Close()
+}
+
+func isTemporary(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Temporary()
 }
@@ -0,0 +1,159 @@
+package listenbuffer
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rejectWithError(conn net.Conn, err error) {
+	conn.Write([]byte(err.Error()))
+}
+
+// dial connects to l and returns what the listener wrote before closing
+// the connection, waiting at most for timeout.
+func dial(t *testing.T, l net.Listener, timeout time.Duration) (net.Conn, chan string) {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan string, 1)
+	go func() {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		b, _ := ioutil.ReadAll(conn)
+		read <- string(b)
+	}()
+	return conn, read
+}
+
+func TestBufferMaxConns(t *testing.T) {
+	activate := make(chan struct{})
+	l, err := NewListenBuffer("tcp", "127.0.0.1:0", activate, Options{MaxConns: 1, Reject: rejectWithError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	held, _ := dial(t, l, time.Second)
+	defer held.Close()
+	rejected, read := dial(t, l, 5*time.Second)
+	defer rejected.Close()
+	if out := <-read; out != ErrTooManyConnections.Error() {
+		t.Fatalf("Expected the connection over the maximum rejected with %q, got %q", ErrTooManyConnections, out)
+	}
+
+	close(activate)
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.RemoteAddr().String() != held.LocalAddr().String() {
+		t.Fatalf("Expected the connection held, got the one from %s", conn.RemoteAddr())
+	}
+}
+
+func TestBufferTimeout(t *testing.T) {
+	activate := make(chan struct{})
+	l, err := NewListenBuffer("tcp", "127.0.0.1:0", activate, Options{Timeout: 100 * time.Millisecond, Reject: rejectWithError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	held, read := dial(t, l, 5*time.Second)
+	defer held.Close()
+	if out := <-read; out != ErrActivationTimeout.Error() {
+		t.Fatalf("Expected the connection held rejected with %q, got %q", ErrActivationTimeout, out)
+	}
+	late, read := dial(t, l, 5*time.Second)
+	defer late.Close()
+	if out := <-read; out != ErrActivationTimeout.Error() {
+		t.Fatalf("Expected the connection after the timeout rejected with %q, got %q", ErrActivationTimeout, out)
+	}
+}
+
+func TestBufferActivated(t *testing.T) {
+	activate := make(chan struct{})
+	l, err := NewListenBuffer("tcp", "127.0.0.1:0", activate, Options{MaxConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, _ := dial(t, l, time.Second)
+	defer held.Close()
+	close(activate)
+	// The cap only bounds the connections held before the activation
+	for i := 0; i < 2; i++ {
+		c, _ := dial(t, l, time.Second)
+		defer c.Close()
+	}
+	for i := 0; i < 3; i++ {
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	l.Close()
+	if _, err := l.Accept(); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("Expected Accept to fail once closed, got %v", err)
+	}
+}
+
+func TestBufferHandshakeTimeout(t *testing.T) {
+	activate := make(chan struct{})
+	l, err := NewListenBuffer("tcp", "127.0.0.1:0", activate, Options{MaxConns: 1, HandshakeTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	idle, read := dial(t, l, 5*time.Second)
+	defer idle.Close()
+	if out := <-read; out != "" {
+		t.Fatalf("Expected the idle connection closed, got %q", out)
+	}
+	if held := stats.Get("held").String(); held != "0" {
+		t.Fatalf("Expected no connection held, got %s", held)
+	}
+
+	// The idle connection no longer counts in the maximum
+	speaking, _ := dial(t, l, 5*time.Second)
+	defer speaking.Close()
+	if _, err := speaking.Write([]byte("GET /_ping")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if oldest := stats.Get("oldest_held_ms").String(); oldest == "0" {
+		t.Fatal("Expected the connection held to be waiting")
+	}
+
+	close(activate)
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if Unwrap(conn).RemoteAddr().String() != speaking.LocalAddr().String() {
+		t.Fatalf("Expected the connection which spoke, got the one from %s", conn.RemoteAddr())
+	}
+	// The first bytes are replayed, and the handshake timeout is lifted
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "GET /_ping" {
+		t.Fatalf("Expected the first bytes replayed, got %q, %v", buf[:n], err)
+	}
+	if _, err := speaking.Write([]byte(" HTTP/1.1")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = conn.Read(buf); err != nil || string(buf[:n]) != " HTTP/1.1" {
+		t.Fatalf("Expected the next bytes, got %q, %v", buf[:n], err)
+	}
+	if waited := stats.Get("max_waited_ms").String(); waited == "0" {
+		t.Fatal("Expected the wait of the connection handed recorded")
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type priority int
@@ -41,20 +44,163 @@ func (p priority) String() string {
 	return ""
 }
 
-// Debug function, if the debug flag is set, then display. Do nothing otherwise
+func parsePriority(level string) (priority, bool) {
+	for _, p := range []priority{fatal, error, info, debug} {
+		if p.String() == level {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// DefaultLogger is the name given to the default level in SetLevel and
+// Levels, followed by the package functions and the loggers without a level
+// of their own.
+const DefaultLogger = "default"
+
+var (
+	// defaultLevel is the default priority, 0 until set, when it is info,
+	// or debug if the DEBUG environment variable is set
+	defaultLevel int32
+
+	loggersLock sync.Mutex
+	loggers     = make(map[string]*Logger)
+)
+
+func defaultPriority() priority {
+	if p := atomic.LoadInt32(&defaultLevel); p != 0 {
+		return priority(p)
+	}
+	if os.Getenv("DEBUG") != "" {
+		return debug
+	}
+	return info
+}
+
+// Logger logs the messages of a subsystem, like the bridge, at a level which
+// can be changed at runtime, for instance to debug only the networking.
+type Logger struct {
+	name  string
+	level int32 // the priority, 0 to follow the default one
+}
+
+// New returns the logger of the subsystem, the same for the same name.
+func New(name string) *Logger {
+	loggersLock.Lock()
+	defer loggersLock.Unlock()
+	l, exists := loggers[name]
+	if !exists {
+		l = &Logger{name: name}
+		loggers[name] = l
+	}
+	return l
+}
+
+func (l *Logger) priority() priority {
+	if p := atomic.LoadInt32(&l.level); p != 0 {
+		return priority(p)
+	}
+	return defaultPriority()
+}
+
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	if l.priority() >= debug {
+		logf(os.Stderr, debug, format, a...)
+	}
+}
+
+func (l *Logger) Infof(format string, a ...interface{}) {
+	if l.priority() >= info {
+		logf(os.Stdout, info, format, a...)
+	}
+}
+
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	if l.priority() >= error {
+		logf(os.Stderr, error, format, a...)
+	}
+}
+
+func (l *Logger) Fatalf(format string, a ...interface{}) {
+	logf(os.Stderr, fatal, format, a...)
+	os.Exit(1)
+}
+
+// Registered returns whether the named logger was created with New, or is
+// the default one.
+func Registered(name string) bool {
+	if name == DefaultLogger {
+		return true
+	}
+	loggersLock.Lock()
+	defer loggersLock.Unlock()
+	_, exists := loggers[name]
+	return exists
+}
+
+// SetLevel sets the level of the named logger, or the default level with
+// DefaultLogger: fatal, error, info or debug, or default for the logger to
+// follow the default level again. It returns false for an invalid level or
+// a logger not created with New, the names coming from the API not to
+// create loggers.
+func SetLevel(name, level string) bool {
+	var p priority
+	if level != DefaultLogger {
+		var ok bool
+		if p, ok = parsePriority(level); !ok {
+			return false
+		}
+	}
+	if name == DefaultLogger {
+		atomic.StoreInt32(&defaultLevel, int32(p))
+		return true
+	}
+	loggersLock.Lock()
+	l, exists := loggers[name]
+	loggersLock.Unlock()
+	if !exists {
+		return false
+	}
+	atomic.StoreInt32(&l.level, int32(p))
+	return true
+}
+
+// Levels returns the names of the loggers, sorted, and the default one
+// first, with their levels.
+func Levels() [][2]string {
+	loggersLock.Lock()
+	names := make([]string, 0, len(loggers))
+	for name := range loggers {
+		names = append(names, name)
+	}
+	loggersLock.Unlock()
+	sort.Strings(names)
+
+	levels := [][2]string{{DefaultLogger, defaultPriority().String()}}
+	for _, name := range names {
+		levels = append(levels, [2]string{name, New(name).priority().String()})
+	}
+	return levels
+}
+
+// Debug function, if the debug level is set, then display. Do nothing otherwise
 // If Docker is in damon mode, also send the debug info on the socket
 func Debugf(format string, a ...interface{}) {
-	if os.Getenv("DEBUG") != "" {
+	if defaultPriority() >= debug {
 		logf(os.Stderr, debug, format, a...)
 	}
 }
 
 func Infof(format string, a ...interface{}) {
-	logf(os.Stdout, info, format, a...)
+	if defaultPriority() >= info {
+		logf(os.Stdout, info, format, a...)
+	}
 }
 
 func Errorf(format string, a ...interface{}) {
-	logf(os.Stderr, error, format, a...)
+	if defaultPriority() >= error {
+		logf(os.Stderr, error, format, a...)
+	}
 }
 
 func Fatalf(format string, a ...interface{}) {
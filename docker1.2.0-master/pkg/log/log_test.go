@@ -35,3 +35,49 @@ func TestLogFatalf(t *testing.T) {
 		}
 	}
 }
+
+func TestLoggerLevels(t *testing.T) {
+	defer SetLevel(DefaultLogger, DefaultLogger)
+
+	bridge := New("bridge")
+	if New("bridge") != bridge {
+		t.Fatal("Expected the same logger for the same name")
+	}
+	SetLevel(DefaultLogger, "info")
+	if p := bridge.priority(); p != info {
+		t.Fatalf("Expected the bridge to follow the default level, got %s", p)
+	}
+
+	if !SetLevel("bridge", "debug") {
+		t.Fatal("Expected the debug level to be valid")
+	}
+	if SetLevel("bridge", "trace") {
+		t.Fatal("Expected the trace level to be invalid")
+	}
+	// The unknown loggers aren't created
+	if Registered("brige") || SetLevel("brige", "debug") || Registered("brige") {
+		t.Fatal("Expected the unknown logger brige to be rejected")
+	}
+	if p := bridge.priority(); p != debug {
+		t.Fatalf("Expected the bridge at the debug level, got %s", p)
+	}
+	if p := New("api").priority(); p != info {
+		t.Fatalf("Expected the api at the default level, got %s", p)
+	}
+
+	levels := Levels()
+	if levels[0] != [2]string{DefaultLogger, "info"} {
+		t.Fatalf("Expected the default level first, got %v", levels)
+	}
+	for _, level := range levels {
+		if level[0] == "bridge" && level[1] != "debug" {
+			t.Fatalf("Expected the bridge at the debug level, got %v", levels)
+		}
+	}
+
+	SetLevel("bridge", DefaultLogger)
+	SetLevel(DefaultLogger, "error")
+	if p := bridge.priority(); p != error {
+		t.Fatalf("Expected the bridge to follow the default level again, got %s", p)
+	}
+}
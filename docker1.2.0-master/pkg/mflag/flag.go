@@ -940,6 +940,64 @@ func (f *FlagSet) Parse(arguments []string) error {
 	return nil
 }
 
+// EnvName returns the name of the environment variable of the flag, after
+// its first long name, e.g. DOCKER_STORAGE_DRIVER for --storage-driver with
+// the prefix DOCKER_. It returns "" if the flag has no long name.
+func (flag *Flag) EnvName(prefix string) string {
+	for _, name := range flag.Names {
+		if strings.HasPrefix(name, "-") {
+			return prefix + strings.ToUpper(strings.Replace(name[1:], "-", "_", -1))
+		}
+	}
+	return ""
+}
+
+// ParseEnv sets the flags which were not set by Parse from the environment
+// variables named after them, see Flag.EnvName, the flags named in skip
+// apart. The command line thus takes precedence over the environment, which
+// takes precedence over the defaults. Empty variables are ignored, and the
+// values of the flags which can be set several times, like lists, are
+// separated by spaces.
+func (f *FlagSet) ParseEnv(prefix string, skip ...string) error {
+	set := make(map[*Flag]bool)
+	for _, flag := range f.actual {
+		set[flag] = true
+	}
+	for _, name := range skip {
+		if flag, ok := f.formal[name]; ok {
+			set[flag] = true
+		}
+	}
+	for _, flag := range sortFlags(f.formal) {
+		envName := flag.EnvName(prefix)
+		if set[flag] || envName == "" {
+			continue
+		}
+		value := os.Getenv(envName)
+		if value == "" {
+			continue
+		}
+		values := []string{value}
+		if _, ok := flag.Value.(interface {
+			GetAll() []string
+		}); ok {
+			values = strings.Fields(value)
+		}
+		for _, v := range values {
+			if err := f.Set(strings.TrimPrefix(flag.Names[0], "#"), v); err != nil {
+				return fmt.Errorf("invalid value %q for environment variable %s: %v", v, envName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseEnv sets the command-line flags which were not set by Parse from the
+// environment, see FlagSet.ParseEnv.
+func ParseEnv(prefix string, skip ...string) error {
+	return CommandLine.ParseEnv(prefix, skip...)
+}
+
 // Parsed reports whether f.Parse has been called.
 func (f *FlagSet) Parsed() bool {
 	return f.parsed
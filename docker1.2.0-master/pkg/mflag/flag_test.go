@@ -428,3 +428,66 @@ func TestHelp(t *testing.T) {
 		t.Fatal("help was called; should not have been for defined help flag")
 	}
 }
+
+// listVar is a user-defined flag type which can be set several times.
+type listVar struct {
+	flagVar
+}
+
+func (l *listVar) GetAll() []string {
+	return l.flagVar
+}
+
+func TestParseEnv(t *testing.T) {
+	var flags FlagSet
+	flags.Init("test", ContinueOnError)
+	var (
+		driver = flags.String([]string{"s", "-storage-driver"}, "", "storage driver")
+		debug  = flags.Bool([]string{"D", "-debug"}, false, "debug")
+		mtu    = flags.Int([]string{"-mtu"}, 0, "mtu")
+		group  = flags.String([]string{"G", "-group"}, "docker", "group")
+		host   = flags.String([]string{"-host"}, "", "host")
+		dns    listVar
+	)
+	flags.Var(&dns, []string{"#dns", "-dns"}, "dns")
+
+	for name, value := range map[string]string{
+		"TEST_STORAGE_DRIVER": "vfs",
+		"TEST_DEBUG":          "true",
+		"TEST_MTU":            "1400",
+		"TEST_GROUP":          "",
+		"TEST_HOST":           "tcp://remote:2375",
+		"TEST_DNS":            "8.8.8.8 8.8.4.4",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	if err := flags.Parse([]string{"-s", "aufs"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.ParseEnv("TEST_", "-host"); err != nil {
+		t.Fatal(err)
+	}
+	// the command line takes precedence
+	if *driver != "aufs" {
+		t.Errorf("Expected storage driver aufs, got %s", *driver)
+	}
+	if !*debug || *mtu != 1400 {
+		t.Errorf("Expected debug and mtu 1400 from the environment, got %v and %d", *debug, *mtu)
+	}
+	// empty and skipped variables are ignored
+	if *group != "docker" || *host != "" {
+		t.Errorf("Expected the default group and host, got %s and %s", *group, *host)
+	}
+	if len(dns.flagVar) != 2 || dns.flagVar[0] != "8.8.8.8" || dns.flagVar[1] != "8.8.4.4" {
+		t.Errorf("Expected 2 dns servers, got %v", dns.flagVar)
+	}
+
+	os.Setenv("TEST_MTU", "big")
+	invalid := NewFlagSet("test", ContinueOnError)
+	invalid.Int([]string{"-mtu"}, 0, "mtu")
+	if err := invalid.ParseEnv("TEST_"); err == nil || !strings.Contains(err.Error(), "TEST_MTU") {
+		t.Errorf("Expected an error naming TEST_MTU, got %v", err)
+	}
+}
@@ -1,8 +1,13 @@
 package namesgenerator
 
 import (
+	"bufio"
 	"fmt"
 	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -90,3 +95,76 @@ begin:
 	}
 	return name
 }
+
+// validWord is the pattern of the words of a word list, which make valid
+// container names once joined with '_'.
+var validWord = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Generator generates names like GetRandomName, from its own words if any,
+// between a prefix and a suffix. In sequential mode, the names are the prefix
+// and the suffix around a counter instead: prefix1suffix, prefix2suffix...
+type Generator struct {
+	Prefix     string
+	Suffix     string
+	Sequential bool
+	Words      []string // the names are two of them joined with '_', the default names if empty
+	counter    uint64
+}
+
+// Name returns a new name, retry being the number of names taken already.
+func (g *Generator) Name(retry int) string {
+	if g.Sequential {
+		return fmt.Sprintf("%s%d%s", g.Prefix, atomic.AddUint64(&g.counter, 1), g.Suffix)
+	}
+	var name string
+	if len(g.Words) == 0 {
+		name = GetRandomName(retry)
+	} else {
+		rand.Seed(time.Now().UnixNano())
+		name = fmt.Sprintf("%s_%s", g.Words[rand.Intn(len(g.Words))], g.Words[rand.Intn(len(g.Words))])
+		if retry > 0 {
+			name = fmt.Sprintf("%s%d", name, rand.Intn(10))
+		}
+	}
+	return g.Prefix + name + g.Suffix
+}
+
+// Counter returns the number of the last sequential name.
+func (g *Generator) Counter() uint64 {
+	return atomic.LoadUint64(&g.counter)
+}
+
+// SetCounter sets the number of the last sequential name, e.g. to the one
+// saved by a previous daemon.
+func (g *Generator) SetCounter(n uint64) {
+	atomic.StoreUint64(&g.counter, n)
+}
+
+// LoadWords reads a word list, one word per line. The blank lines and the
+// lines starting with # are skipped.
+func LoadWords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		if !validWord.MatchString(word) {
+			return nil, fmt.Errorf("Invalid word %q in %s, only [a-zA-Z0-9_.-] are allowed", word, path)
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("No words in %s", path)
+	}
+	return words, nil
+}
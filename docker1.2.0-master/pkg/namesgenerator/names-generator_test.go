@@ -1,6 +1,9 @@
 package namesgenerator
 
 import (
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
 )
 
@@ -21,3 +24,47 @@ func isAwesome(name string) bool {
 	politicallyCorrect := true
 	return coolInventorNames && easyToRemember && mildlyFunnyOnOccasion && politicallyCorrect
 }
+
+func TestGeneratorWords(t *testing.T) {
+	g := &Generator{Prefix: "web-", Suffix: "-eu", Words: []string{"alpha"}}
+	if name := g.Name(0); name != "web-alpha_alpha-eu" {
+		t.Fatalf("Expected web-alpha_alpha-eu, got %s", name)
+	}
+}
+
+func TestGeneratorSequential(t *testing.T) {
+	g := &Generator{Prefix: "host1-", Sequential: true}
+	g.SetCounter(41)
+	for _, expected := range []string{"host1-42", "host1-43"} {
+		if name := g.Name(0); name != expected {
+			t.Fatalf("Expected %s, got %s", expected, name)
+		}
+	}
+	if g.Counter() != 43 {
+		t.Fatalf("Expected the counter at 43, got %d", g.Counter())
+	}
+}
+
+func TestLoadWords(t *testing.T) {
+	f, err := ioutil.TempFile("", "docker-words-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# the words\nred\n\n  blue \n")
+	f.Close()
+	words, err := LoadWords(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(words, []string{"red", "blue"}) {
+		t.Fatalf("Unexpected words %v", words)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte("red\nnot valid\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadWords(f.Name()); err == nil {
+		t.Fatal("Expected an error for a word with a space")
+	}
+}
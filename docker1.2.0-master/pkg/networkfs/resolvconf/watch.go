@@ -0,0 +1,88 @@
+package resolvconf
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// WatchDelay is how long a watcher waits for the changes of a file to settle
+// before reading it, resolv.conf being often written in several steps.
+var WatchDelay = 100 * time.Millisecond
+
+// Watcher calls its callback with the content of a file each time it
+// changes, until it is closed.
+type Watcher struct {
+	path      string
+	callback  func(resolvConf []byte)
+	sum       [sha256.Size]byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	fd  int   // the inotify instance
+	wds []int // the watches of the directories of the file
+}
+
+// Watch watches /etc/resolv.conf, see WatchFile.
+func Watch(callback func(resolvConf []byte)) (*Watcher, error) {
+	return WatchFile("/etc/resolv.conf", callback)
+}
+
+func newWatcher(path string, callback func([]byte)) *Watcher {
+	w := &Watcher{
+		path:     path,
+		callback: callback,
+		closed:   make(chan struct{}),
+	}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		w.sum = sha256.Sum256(data)
+	}
+	return w
+}
+
+func (w *Watcher) isClosed() bool {
+	select {
+	case <-w.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// coalesce checks the file once no event was received for WatchDelay, until
+// events is closed.
+func (w *Watcher) coalesce(events chan struct{}) {
+	for _ = range events {
+		timer := time.NewTimer(WatchDelay)
+	wait:
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					timer.Stop()
+					return
+				}
+				timer.Reset(WatchDelay)
+			case <-timer.C:
+				break wait
+			}
+		}
+		w.check()
+	}
+}
+
+// check calls the callback if the content of the file changed. A file
+// missing while it is replaced is not a change.
+func (w *Watcher) check() {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	if sum == w.sum || w.isClosed() {
+		return
+	}
+	w.sum = sum
+	w.callback(data)
+}
@@ -0,0 +1,75 @@
+package resolvconf
+
+import (
+	"path/filepath"
+	"syscall"
+)
+
+const watchEvents = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM
+
+// WatchFile calls callback with the content of the file at path each time it
+// changes, whether it is written in place, replaced, or is a symlink whose
+// target changes. The changes within WatchDelay of each other are reported
+// once, and only if the content differs from the one reported last.
+func WatchFile(path string, callback func(resolvConf []byte)) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := newWatcher(path, callback)
+	w.fd = fd
+
+	// the directories, the file being often replaced by a rename
+	dirs := []string{filepath.Dir(path)}
+	if target, err := filepath.EvalSymlinks(path); err == nil && filepath.Dir(target) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(target))
+	}
+	for _, dir := range dirs {
+		wd, err := syscall.InotifyAddWatch(fd, dir, watchEvents)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+		w.wds = append(w.wds, wd)
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	events := make(chan struct{}, 1)
+	go w.coalesce(events)
+	defer func() {
+		close(events)
+		syscall.Close(w.fd)
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 || w.isClosed() {
+			return
+		}
+		// the events only tell the file may have changed, the checksum does
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close stops the watcher, the callback not being called anymore.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+		// removing the watches wakes the reader up with IN_IGNORED events
+		for _, wd := range w.wds {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+		}
+	})
+	return nil
+}
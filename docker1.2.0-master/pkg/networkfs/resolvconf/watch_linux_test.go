@@ -0,0 +1,77 @@
+package resolvconf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test-resolvconf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(path, []byte("nameserver 1.2.3.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan string, 10)
+	w, err := WatchFile(path, func(resolvConf []byte) {
+		changes <- string(resolvConf)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	expect := func(expected string) {
+		select {
+		case content := <-changes:
+			if content != expected {
+				t.Fatalf("Expected %q, got %q", expected, content)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %q, got no change", expected)
+		}
+	}
+
+	// written in place, twice in a row
+	if err := ioutil.WriteFile(path, []byte("nameserver 5.6.7.8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("nameserver 5.6.7.8\nsearch example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("nameserver 5.6.7.8\nsearch example.com\n")
+
+	// replaced with the same content
+	tmp := filepath.Join(dir, "resolv.conf.tmp")
+	if err := ioutil.WriteFile(tmp, []byte("nameserver 5.6.7.8\nsearch example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	// replaced with another content
+	if err := ioutil.WriteFile(tmp, []byte("nameserver 9.9.9.9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	expect("nameserver 9.9.9.9\n")
+
+	w.Close()
+	if err := ioutil.WriteFile(path, []byte("nameserver 1.1.1.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case content := <-changes:
+		t.Fatalf("Expected no change once closed, got %q", content)
+	case <-time.After(3 * WatchDelay):
+	}
+}
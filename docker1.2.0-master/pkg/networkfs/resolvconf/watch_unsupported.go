@@ -0,0 +1,17 @@
+// +build !linux
+
+package resolvconf
+
+import (
+	"errors"
+)
+
+var ErrWatchNotSupported = errors.New("Watching resolv.conf is not supported on this platform")
+
+func WatchFile(path string, callback func(resolvConf []byte)) (*Watcher, error) {
+	return nil, ErrWatchNotSupported
+}
+
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -61,3 +61,16 @@ func FromParam(p string) (Args, error) {
 	}
 	return args, nil
 }
+
+// MatchKVList tells whether sources has all the values of the field, given
+// as key or key=value, like the labels of a container.
+func (filters Args) MatchKVList(field string, sources map[string]string) bool {
+	for _, value := range filters[field] {
+		parts := strings.SplitN(value, "=", 2)
+		source, exists := sources[parts[0]]
+		if !exists || (len(parts) == 2 && source != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
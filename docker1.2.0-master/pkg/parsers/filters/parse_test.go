@@ -76,3 +76,29 @@ func TestEmpty(t *testing.T) {
 		t.Errorf("these should both be empty sets")
 	}
 }
+
+func TestMatchKVList(t *testing.T) {
+	labels := map[string]string{"rack": "r1", "role": "db"}
+	for _, a := range []Args{
+		{},
+		{"label": {"rack"}},
+		{"label": {"rack=r1", "role"}},
+		{"other": {"rack=r2"}},
+	} {
+		if !a.MatchKVList("label", labels) {
+			t.Errorf("%v should match %v", a, labels)
+		}
+	}
+	for _, a := range []Args{
+		{"label": {"zone"}},
+		{"label": {"rack=r2"}},
+		{"label": {"rack=r1", "role=web"}},
+	} {
+		if a.MatchKVList("label", labels) {
+			t.Errorf("%v shouldn't match %v", a, labels)
+		}
+	}
+	if (Args{"label": {"rack"}}).MatchKVList("label", nil) {
+		t.Errorf("no label should match a label filter")
+	}
+}
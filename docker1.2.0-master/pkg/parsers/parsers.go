@@ -71,6 +71,10 @@ func ParseHost(defaultHost string, defaultUnix, addr string) (string, error) {
 //     Ex: localhost.localdomain:5000/samalba/hipache:latest
 //解析镜像url
 func ParseRepositoryTag(repos string) (string, string) {
+	// repo@sha256:<id> references an image by digest
+	if n := strings.Index(repos, "@"); n >= 0 {
+		return repos[:n], repos[n+1:]
+	}
 	n := strings.LastIndex(repos, ":")
 	if n < 0 {
 		return repos, ""
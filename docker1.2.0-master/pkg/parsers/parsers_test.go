@@ -60,6 +60,9 @@ func TestParseRepositoryTag(t *testing.T) {
 	if repo, tag := ParseRepositoryTag("url:5000/repo:tag"); repo != "url:5000/repo" || tag != "tag" {
 		t.Errorf("Expected repo: '%s' and tag: '%s', got '%s' and '%s'", "url:5000/repo", "tag", repo, tag)
 	}
+	if repo, tag := ParseRepositoryTag("url:5000/repo@sha256:abcd"); repo != "url:5000/repo" || tag != "sha256:abcd" {
+		t.Errorf("Expected repo: '%s' and tag: '%s', got '%s' and '%s'", "url:5000/repo", "sha256:abcd", repo, tag)
+	}
 }
 
 func TestParsePortMapping(t *testing.T) {
@@ -0,0 +1,157 @@
+package parsers
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// validInterfaceName matches the names of network interfaces, at most
+// IFNAMSIZ-1 characters, e.g. eth0 or eth0.100.
+var validInterfaceName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]{0,14}$`)
+
+// PortRange is a range of ports, e.g. 8000-8010 or 80, the zero value being
+// port 0, i.e. any port.
+type PortRange struct {
+	Start int
+	End   int
+}
+
+// Len returns the number of ports of the range.
+func (r PortRange) Len() int {
+	return r.End - r.Start + 1
+}
+
+func (r PortRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// PortSpec is a published port specification,
+// e.g. 127.0.0.1:8000-8001:80-81/tcp, 8080:80 or eth0:53:53/udp.
+type PortSpec struct {
+	HostIP         string    // an IPv4 address or the name of an interface, empty for all the addresses
+	HostPorts      PortRange // port 0 for ports chosen by the daemon
+	ContainerPorts PortRange
+	Proto          string
+}
+
+// ParsePortRange parses a port, e.g. 80, or a range of ports, e.g. 8000-8010.
+func ParsePortRange(rawRange string) (PortRange, error) {
+	start, end := rawRange, rawRange
+	if i := strings.Index(rawRange, "-"); i != -1 {
+		start, end = rawRange[:i], rawRange[i+1:]
+	}
+	s, err := strconv.ParseUint(start, 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("Invalid port: %s", rawRange)
+	}
+	e, err := strconv.ParseUint(end, 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("Invalid port: %s", rawRange)
+	}
+	if e < s || (s == 0 && e != 0) {
+		return PortRange{}, fmt.Errorf("Invalid port range: %s", rawRange)
+	}
+	return PortRange{Start: int(s), End: int(e)}, nil
+}
+
+// ParseProto validates a protocol, tcp for the empty one.
+func ParseProto(proto string) (string, error) {
+	switch proto {
+	case "":
+		return "tcp", nil
+	case "tcp", "udp", "sctp":
+		return proto, nil
+	}
+	return "", fmt.Errorf("Invalid proto: %s", proto)
+}
+
+// ParsePortProto parses a port or a range of ports with an optional protocol,
+// e.g. 80, 53/udp or 8000-8010/tcp.
+func ParsePortProto(rawPort string) (PortRange, string, error) {
+	var proto string
+	if i := strings.LastIndex(rawPort, "/"); i != -1 {
+		rawPort, proto = rawPort[:i], rawPort[i+1:]
+	}
+	proto, err := ParseProto(proto)
+	if err != nil {
+		return PortRange{}, "", err
+	}
+	if rawPort == "" {
+		return PortRange{}, "", fmt.Errorf("No port specified")
+	}
+	ports, err := ParsePortRange(rawPort)
+	if err != nil {
+		return PortRange{}, "", err
+	}
+	return ports, proto, nil
+}
+
+// ParsePortSpec parses a published port specification, in the format
+// [ip:][hostPort:]containerPort[/proto], the ports being ports or ranges of
+// ports and ip an IPv4 address or the name of an interface. The port mapper
+// only publishes tcp and udp ports on IPv4 addresses, so sctp ports and IPv6
+// addresses are rejected here rather than when the container starts.
+func ParsePortSpec(rawSpec string) (*PortSpec, error) {
+	spec := &PortSpec{}
+	if strings.HasPrefix(rawSpec, "[") {
+		return nil, fmt.Errorf("Invalid ip address: publishing ports on IPv6 addresses is not supported: %s", rawSpec)
+	}
+	parts := strings.Split(rawSpec, ":")
+	switch len(parts) {
+	case 1:
+		parts = append([]string{""}, parts...)
+	case 2:
+	case 3:
+		spec.HostIP, parts = parts[0], parts[1:]
+		if spec.HostIP != "" && net.ParseIP(spec.HostIP) == nil && !validInterfaceName.MatchString(spec.HostIP) {
+			return nil, fmt.Errorf("Invalid ip address: %s", spec.HostIP)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid format to parse.  %s should match template ip:hostPort:containerPort", rawSpec)
+	}
+
+	var err error
+	if spec.ContainerPorts, spec.Proto, err = ParsePortProto(parts[1]); err != nil {
+		return nil, fmt.Errorf("%s: %s", err, rawSpec)
+	}
+	if spec.Proto == "sctp" {
+		return nil, fmt.Errorf("Publishing sctp ports is not supported: %s", rawSpec)
+	}
+	if parts[0] != "" {
+		if spec.HostPorts, err = ParsePortRange(parts[0]); err != nil {
+			return nil, fmt.Errorf("Invalid hostPort: %s", parts[0])
+		}
+		if spec.HostPorts.Start != 0 && spec.HostPorts.Len() != spec.ContainerPorts.Len() {
+			return nil, fmt.Errorf("Invalid ranges: the host and container ranges of %s differ in size", rawSpec)
+		}
+	}
+	return spec, nil
+}
+
+// ResolveHostIP returns the IP address of hostIP, the first IPv4 address of
+// the interface if it names one.
+func ResolveHostIP(hostIP string) (net.IP, error) {
+	if ip := net.ParseIP(hostIP); ip != nil {
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(hostIP)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid host ip %s: %s", hostIP, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("Interface %s has no IPv4 address", hostIP)
+}
@@ -0,0 +1,56 @@
+package parsers
+
+import (
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	for rawSpec, expected := range map[string]PortSpec{
+		"80":                          {ContainerPorts: PortRange{80, 80}, Proto: "tcp"},
+		"8080:80/udp":                 {HostPorts: PortRange{8080, 8080}, ContainerPorts: PortRange{80, 80}, Proto: "udp"},
+		"127.0.0.1::80":               {HostIP: "127.0.0.1", ContainerPorts: PortRange{80, 80}, Proto: "tcp"},
+		"eth0.100:53:53/udp":          {HostIP: "eth0.100", HostPorts: PortRange{53, 53}, ContainerPorts: PortRange{53, 53}, Proto: "udp"},
+		"0.0.0.0:7000-7001:7000-7001": {HostIP: "0.0.0.0", HostPorts: PortRange{7000, 7001}, ContainerPorts: PortRange{7000, 7001}, Proto: "tcp"},
+	} {
+		spec, err := ParsePortSpec(rawSpec)
+		if err != nil {
+			t.Fatalf("Error parsing %s: %s", rawSpec, err)
+		}
+		if *spec != expected {
+			t.Fatalf("Expected %+v for %s, got %+v", expected, rawSpec, *spec)
+		}
+	}
+
+	for _, rawSpec := range []string{
+		"",
+		"80/icmp",
+		"65536",
+		"90-80",
+		"8000-8001:80",
+		"::1:80:80",
+		"[::1]:8000-8010:80-90",
+		"[127.0.0.1]::80",
+		"[::1::80",
+		"9000/sctp",
+		"127.0.0.1::9000/sctp",
+		"1.2.3:80:80",
+		"an-interface-name:80:80",
+	} {
+		if _, err := ParsePortSpec(rawSpec); err == nil {
+			t.Fatalf("Expected an error parsing %q", rawSpec)
+		}
+	}
+}
+
+func TestParsePortProto(t *testing.T) {
+	ports, proto, err := ParsePortProto("7000-7010/udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ports != (PortRange{7000, 7010}) || ports.Len() != 11 || ports.String() != "7000-7010" || proto != "udp" {
+		t.Fatalf("Unexpected %v %s", ports, proto)
+	}
+	if _, _, err := ParsePortProto("/tcp"); err == nil {
+		t.Fatal("Expected an error parsing /tcp")
+	}
+}
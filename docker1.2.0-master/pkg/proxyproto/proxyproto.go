@@ -0,0 +1,291 @@
+// Package proxyproto reads the PROXY protocol header, versions 1 and 2,
+// that load balancers send ahead of the connections they forward, to give
+// the connections the address of their real client.
+//
+// See http://www.haproxy.org/download/1.5/doc/proxy-protocol.txt
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/log"
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrNoHeader = errors.New("No PROXY protocol header")
+)
+
+// v1MaxLength is the longest version 1 header, its CRLF included.
+const v1MaxLength = 107
+
+// Conn is a connection whose PROXY protocol header has been read. Its
+// addresses are the ones of the header, unless it had none.
+type Conn struct {
+	net.Conn
+	r      *bufio.Reader
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// NewConn reads the header of conn, which must have one, waiting for it at
+// most for timeout when not zero.
+func NewConn(conn net.Conn, timeout time.Duration) (*Conn, error) {
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+	c := &Conn{Conn: conn, r: bufio.NewReader(conn)}
+	first, err := c.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch first[0] {
+	case v1Prefix[0]:
+		err = c.readV1()
+	case v2Signature[0]:
+		err = c.readV2()
+	default:
+		err = ErrNoHeader
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readV1 reads a header like "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443".
+func (c *Conn) readV1() error {
+	var line []byte
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) == v1MaxLength {
+			return fmt.Errorf("PROXY protocol header longer than %d bytes", v1MaxLength)
+		}
+	}
+	if !bytes.HasPrefix(line, v1Prefix) || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return ErrNoHeader
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return fmt.Errorf("Invalid PROXY protocol header %q", line)
+	}
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+	c.remote, c.local = src, dst
+	return nil
+}
+
+func parseV1Addr(ip, port string) (*net.TCPAddr, error) {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip)}
+	if addr.IP == nil {
+		return nil, fmt.Errorf("Invalid address %s in the PROXY protocol header", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid port %s in the PROXY protocol header", port)
+	}
+	addr.Port = int(p)
+	return addr, nil
+}
+
+// readV2 reads a binary header: the signature, the version and command,
+// the family and protocol, the length of the addresses and the addresses.
+func (c *Conn) readV2() error {
+	header := make([]byte, len(v2Signature)+4)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:len(v2Signature)], v2Signature) {
+		return ErrNoHeader
+	}
+	verCmd, family := header[12], header[13]
+	if verCmd>>4 != 2 {
+		return fmt.Errorf("Unsupported PROXY protocol version %d", verCmd>>4)
+	}
+	addrs := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(c.r, addrs); err != nil {
+		return err
+	}
+	// The LOCAL command is sent by the proxy itself, for health checks
+	if verCmd&0xf == 0 {
+		return nil
+	}
+	if verCmd&0xf != 1 {
+		return fmt.Errorf("Unsupported PROXY protocol command %d", verCmd&0xf)
+	}
+
+	var ipLen int
+	switch family {
+	case 0x11: // TCP over IPv4
+		ipLen = net.IPv4len
+	case 0x21: // TCP over IPv6
+		ipLen = net.IPv6len
+	default:
+		// Other families, like unix sockets, keep the address of the proxy
+		return nil
+	}
+	if len(addrs) < 2*ipLen+4 {
+		return fmt.Errorf("PROXY protocol header too short for its addresses")
+	}
+	c.remote = &net.TCPAddr{
+		IP:   net.IP(addrs[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLen:])),
+	}
+	c.local = &net.TCPAddr{
+		IP:   net.IP(addrs[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLen+2:])),
+	}
+	return nil
+}
+
+// ParseTrusted parses the CIDRs of the proxies allowed to send a header. A
+// single address is a network of its own.
+func ParseTrusted(cidrs []string) ([]*net.IPNet, error) {
+	var trusted []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid trusted proxy %s, expected an IP address or a CIDR", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy %s, expected an IP address or a CIDR", cidr)
+		}
+		trusted = append(trusted, network)
+	}
+	return trusted, nil
+}
+
+type listener struct {
+	net.Listener
+	trusted  []*net.IPNet
+	accepted chan net.Conn
+	closed   chan struct{} // closed once the wrapped listener fails
+	err      error         // the error it failed with
+}
+
+// NewListener returns a listener whose connections must start with a PROXY
+// protocol header, and come from the trusted proxies. The headers are read
+// apart from Accept, for a slow client not to hold the others, and the
+// connections without one, or from another peer, are dropped: a client
+// reaching the listener directly could claim any address with its header.
+func NewListener(l net.Listener, timeout time.Duration, trusted []*net.IPNet) net.Listener {
+	pl := &listener{
+		Listener: l,
+		trusted:  trusted,
+		accepted: make(chan net.Conn),
+		closed:   make(chan struct{}),
+	}
+	go pl.run(timeout)
+	return pl
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.accepted:
+		return conn, nil
+	case <-l.closed:
+		return nil, l.err
+	}
+}
+
+func (l *listener) run(timeout time.Duration) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			l.err = err
+			close(l.closed)
+			return
+		}
+		if !l.trusts(conn.RemoteAddr()) {
+			log.Debugf("Dropping the connection from %s: not a trusted proxy", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		go func() {
+			c, err := NewConn(conn, timeout)
+			if err != nil {
+				log.Debugf("Dropping the connection from %s: %s", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+			select {
+			case l.accepted <- c:
+			case <-l.closed:
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// trusts tells whether the peer is one of the trusted proxies.
+func (l *listener) trusts(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
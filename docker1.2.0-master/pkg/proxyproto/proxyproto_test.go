@@ -0,0 +1,185 @@
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeConn is a connection whose client sent in.
+type fakeConn struct {
+	net.Conn
+	in *bytes.Reader
+}
+
+func (c *fakeConn) Read(b []byte) (int, error)         { return c.in.Read(b) }
+func (c *fakeConn) RemoteAddr() net.Addr               { return &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 80} }
+func (c *fakeConn) LocalAddr() net.Addr                { return &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 2375} }
+func (c *fakeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *fakeConn) Close() error                       { return nil }
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *fakeConn) Write(b []byte) (int, error)        { return len(b), nil }
+func (c *fakeConn) SetDeadline(t time.Time) error      { return nil }
+
+func v2Header(cmd, family byte, addrs []byte) []byte {
+	h := append([]byte{}, v2Signature...)
+	h = append(h, 0x20|cmd, family, 0, 0)
+	binary.BigEndian.PutUint16(h[14:], uint16(len(addrs)))
+	return append(h, addrs...)
+}
+
+func TestNewConn(t *testing.T) {
+	v4 := []byte{192, 168, 0, 1, 192, 168, 0, 11, 0xdc, 0x04, 0x01, 0xbb}
+	v6 := make([]byte, 36)
+	copy(v6, net.ParseIP("2001:db8::1"))
+	copy(v6[16:], net.ParseIP("2001:db8::2"))
+	binary.BigEndian.PutUint16(v6[32:], 56324)
+	binary.BigEndian.PutUint16(v6[34:], 443)
+
+	for _, c := range []struct {
+		header []byte
+		remote string
+		local  string
+	}{
+		{[]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"), "192.168.0.1:56324", "192.168.0.11:443"},
+		{[]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"), "[2001:db8::1]:56324", "[2001:db8::2]:443"},
+		{[]byte("PROXY UNKNOWN\r\n"), "10.0.0.1:80", "10.0.0.2:2375"},
+		{v2Header(1, 0x11, v4), "192.168.0.1:56324", "192.168.0.11:443"},
+		{v2Header(1, 0x21, v6), "[2001:db8::1]:56324", "[2001:db8::2]:443"},
+		// The addresses can be followed by extensions
+		{v2Header(1, 0x11, append(v4, 1, 2, 3)), "192.168.0.1:56324", "192.168.0.11:443"},
+		{v2Header(0, 0x00, nil), "10.0.0.1:80", "10.0.0.2:2375"},
+	} {
+		in := append(append([]byte{}, c.header...), "GET /_ping HTTP/1.1\r\n\r\n"...)
+		conn, err := NewConn(&fakeConn{in: bytes.NewReader(in)}, time.Second)
+		if err != nil {
+			t.Errorf("%q: %s", c.header, err)
+			continue
+		}
+		if remote := conn.RemoteAddr().String(); remote != c.remote {
+			t.Errorf("%q: expected the remote address %s, got %s", c.header, c.remote, remote)
+		}
+		if local := conn.LocalAddr().String(); local != c.local {
+			t.Errorf("%q: expected the local address %s, got %s", c.header, c.local, local)
+		}
+		if rest, _ := ioutil.ReadAll(conn); string(rest) != "GET /_ping HTTP/1.1\r\n\r\n" {
+			t.Errorf("%q: expected the request after the header, got %q", c.header, rest)
+		}
+	}
+}
+
+func TestNewConnInvalid(t *testing.T) {
+	for _, header := range [][]byte{
+		[]byte("GET /_ping HTTP/1.1\r\n\r\n"),
+		[]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324\r\n"),
+		[]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 65536\r\n"),
+		[]byte("PROXY TCP4 not-an-ip 192.168.0.11 56324 443\r\n"),
+		[]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\n"),
+		append([]byte("PROXY "), bytes.Repeat([]byte("x"), 200)...),
+		v2Header(1, 0x11, []byte{192, 168, 0, 1}),
+		v2Header(2, 0x11, nil),
+	} {
+		if _, err := NewConn(&fakeConn{in: bytes.NewReader(header)}, time.Second); err == nil {
+			t.Errorf("%q: expected an error", header)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	wrapped, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := ParseTrusted([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(wrapped, time.Second, trusted)
+	defer l.Close()
+
+	// A client without a header does not hold the next one
+	silent, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if remote := conn.RemoteAddr().String(); remote != "192.168.0.1:56324" {
+		t.Fatalf("Expected the remote address of the header, got %s", remote)
+	}
+
+	l.Close()
+	if _, err := l.Accept(); err == nil {
+		t.Fatal("Expected Accept to fail once closed")
+	}
+}
+
+func TestListenerUntrusted(t *testing.T) {
+	wrapped, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := ParseTrusted([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewListener(wrapped, time.Second, trusted)
+	defer l.Close()
+
+	// The header of a client reaching the listener directly is not read
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Expected the connection of an untrusted peer to be closed")
+	}
+}
+
+func TestParseTrusted(t *testing.T) {
+	trusted, err := ParseTrusted([]string{"10.0.0.0/8", "192.168.0.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		ip      string
+		trusted bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.0.1", true},
+		{"192.168.0.2", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	} {
+		l := &listener{trusted: trusted}
+		if l.trusts(&net.TCPAddr{IP: net.ParseIP(c.ip)}) != c.trusted {
+			t.Errorf("Expected %s to be trusted: %t", c.ip, c.trusted)
+		}
+	}
+	for _, invalid := range []string{"", "10.0.0.0/33", "proxy.local"} {
+		if _, err := ParseTrusted([]string{invalid}); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
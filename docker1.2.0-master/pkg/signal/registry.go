@@ -0,0 +1,92 @@
+package signal
+
+import (
+	"log"
+	"os"
+	gosignal "os/signal"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Handler is a callback a subsystem binds to a signal, e.g. to reopen its
+// logs on SIGUSR2.
+type Handler struct {
+	Name    string        // for the logs
+	Order   int           // the handlers of a signal run by increasing order
+	Timeout time.Duration // how long the next handlers wait for this one, 0 for ever
+	Func    func(sig os.Signal)
+}
+
+// Registry calls the handlers bound to the signals the process receives.
+type Registry struct {
+	sync.Mutex
+	handlers map[os.Signal][]*Handler
+	c        chan os.Signal
+	once     sync.Once
+}
+
+// DefaultRegistry is the registry of Handle and Trap.
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[os.Signal][]*Handler),
+		c:        make(chan os.Signal, 1),
+	}
+}
+
+// Handle binds h to sig in the default registry.
+func Handle(sig os.Signal, h *Handler) {
+	DefaultRegistry.Handle(sig, h)
+}
+
+// Handle binds h to sig, the registry listening to sig from then on. The
+// handlers of the same order run in the order they were bound.
+func (r *Registry) Handle(sig os.Signal, h *Handler) {
+	r.Lock()
+	handlers := append(r.handlers[sig], h)
+	sort.Stable(byOrder(handlers))
+	r.handlers[sig] = handlers
+	r.Unlock()
+
+	r.once.Do(func() {
+		go func() {
+			for sig := range r.c {
+				// the handlers of a signal received again run again
+				go r.Run(sig)
+			}
+		}()
+	})
+	gosignal.Notify(r.c, sig)
+}
+
+// Run calls the handlers of sig in order, a handler running past its timeout
+// being left to finish in the background.
+func (r *Registry) Run(sig os.Signal) {
+	r.Lock()
+	handlers := append([]*Handler(nil), r.handlers[sig]...)
+	r.Unlock()
+	for _, h := range handlers {
+		if h.Timeout <= 0 {
+			h.Func(sig)
+			continue
+		}
+		done := make(chan struct{})
+		go func(h *Handler) {
+			h.Func(sig)
+			close(done)
+		}(h)
+		select {
+		case <-done:
+		case <-time.After(h.Timeout):
+			log.Printf("The handler %s of signal '%v' timed out after %s\n", h.Name, sig, h.Timeout)
+		}
+	}
+}
+
+type byOrder []*Handler
+
+func (b byOrder) Len() int           { return len(b) }
+func (b byOrder) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byOrder) Less(i, j int) bool { return b[i].Order < b[j].Order }
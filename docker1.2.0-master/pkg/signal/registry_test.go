@@ -0,0 +1,44 @@
+package signal
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRegistryOrder(t *testing.T) {
+	r := NewRegistry()
+	var calls []string
+	handler := func(name string) func(os.Signal) {
+		return func(sig os.Signal) {
+			if sig != syscall.SIGHUP {
+				t.Fatalf("Expected SIGHUP, got %v", sig)
+			}
+			calls = append(calls, name)
+		}
+	}
+	r.Handle(syscall.SIGHUP, &Handler{Name: "b", Order: 1, Func: handler("b")})
+	r.Handle(syscall.SIGHUP, &Handler{Name: "a", Order: 0, Func: handler("a")})
+	r.Handle(syscall.SIGHUP, &Handler{Name: "c", Order: 1, Func: handler("c")})
+	r.Run(syscall.SIGHUP)
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("Expected the handlers %v to run, got %v", expected, calls)
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	r := NewRegistry()
+	block := make(chan struct{})
+	defer close(block)
+	next := make(chan struct{})
+	r.Handle(syscall.SIGUSR2, &Handler{Name: "blocked", Timeout: 10 * time.Millisecond, Func: func(os.Signal) { <-block }})
+	r.Handle(syscall.SIGUSR2, &Handler{Name: "next", Order: 1, Func: func(os.Signal) { close(next) }})
+	go r.Run(syscall.SIGUSR2)
+	select {
+	case <-next:
+	case <-time.After(time.Second):
+		t.Fatal("The handler after the one timing out didn't run")
+	}
+}
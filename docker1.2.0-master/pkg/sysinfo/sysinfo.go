@@ -5,36 +5,70 @@ import (
 	"log"
 	"os"
 	"path"
+	"strings"
 
-	"github.com/docker/libcontainer/cgroups"
+	"github.com/docker/docker/pkg/mount"
 )
 
+// SysInfo stores the features of the kernel, among which the cgroup
+// controllers able to enforce the resources of the containers.
 type SysInfo struct {
 	MemoryLimit            bool
 	SwapLimit              bool
+	CpuShares              bool
+	Cpuset                 bool
+	BlkioWeight            bool
+	PidsLimit              bool
+	CgroupUnified          bool // some controllers are only in the unified (v2) cgroup hierarchy, thus not usable
 	IPv4ForwardingDisabled bool
 	AppArmor               bool
 }
 
+// Controllers returns the usable cgroup controllers among memory, cpu,
+// cpuset, blkio and pids.
+func (sysInfo *SysInfo) Controllers() []string {
+	controllers := []string{}
+	for _, c := range []struct {
+		name   string
+		usable bool
+	}{
+		{"memory", sysInfo.MemoryLimit},
+		{"cpu", sysInfo.CpuShares},
+		{"cpuset", sysInfo.Cpuset},
+		{"blkio", sysInfo.BlkioWeight},
+		{"pids", sysInfo.PidsLimit},
+	} {
+		if c.usable {
+			controllers = append(controllers, c.name)
+		}
+	}
+	return controllers
+}
+
 func New(quiet bool) *SysInfo {
 	sysInfo := &SysInfo{}
-	if cgroupMemoryMountpoint, err := cgroups.FindCgroupMountpoint("memory"); err != nil {
+	if mounts, err := mount.GetMounts(); err != nil {
 		if !quiet {
 			log.Printf("WARNING: %s\n", err)
 		}
 	} else {
-		_, err1 := ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.limit_in_bytes"))
-		_, err2 := ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.soft_limit_in_bytes"))
-		sysInfo.MemoryLimit = err1 == nil && err2 == nil
-		if !sysInfo.MemoryLimit && !quiet {
-			log.Printf("WARNING: Your kernel does not support cgroup memory limit.")
+		sysInfo.detectCgroups(mounts)
+	}
+	if !quiet {
+		if sysInfo.CgroupUnified {
+			log.Printf("WARNING: Some cgroup controllers are only in the unified hierarchy, which is not supported.")
 		}
-
-		_, err = ioutil.ReadFile(path.Join(cgroupMemoryMountpoint, "memory.memsw.limit_in_bytes"))
-		sysInfo.SwapLimit = err == nil
-		if !sysInfo.SwapLimit && !quiet {
+		if !sysInfo.MemoryLimit {
+			log.Printf("WARNING: Your kernel does not support cgroup memory limit.")
+		} else if !sysInfo.SwapLimit {
 			log.Printf("WARNING: Your kernel does not support cgroup swap limit.")
 		}
+		if !sysInfo.CpuShares {
+			log.Printf("WARNING: Your kernel does not support cgroup cpu shares.")
+		}
+		if !sysInfo.Cpuset {
+			log.Printf("WARNING: Your kernel does not support cgroup cpuset.")
+		}
 	}
 
 	// Check if AppArmor seems to be enabled on this system.
@@ -45,3 +79,64 @@ func New(quiet bool) *SysInfo {
 	}
 	return sysInfo
 }
+
+// detectCgroups finds the usable controllers in the mounted legacy (v1)
+// cgroup hierarchies, the only ones the execution drivers can enforce limits
+// with. The controllers only in the unified (v2) hierarchy are not usable,
+// their limits being discarded with a warning, but they are reported by
+// CgroupUnified.
+func (sysInfo *SysInfo) detectCgroups(mounts []*mount.MountInfo) {
+	var (
+		legacy  = make(map[string]string) // the mountpoints of the v1 controllers
+		unified string
+	)
+	for _, m := range mounts {
+		switch m.Fstype {
+		case "cgroup":
+			for _, opt := range strings.Split(m.VfsOpts, ",") {
+				if _, exists := legacy[opt]; !exists {
+					legacy[opt] = m.Mountpoint
+				}
+			}
+		case "cgroup2":
+			if unified == "" {
+				unified = m.Mountpoint
+			}
+		}
+	}
+
+	if unified != "" {
+		if content, err := ioutil.ReadFile(path.Join(unified, "cgroup.controllers")); err == nil {
+			// v2 names the blkio controller io
+			v1 := map[string]string{"memory": "memory", "cpu": "cpu", "cpuset": "cpuset", "io": "blkio", "pids": "pids"}
+			for _, controller := range strings.Fields(string(content)) {
+				if name, known := v1[controller]; known {
+					if _, exists := legacy[name]; !exists {
+						sysInfo.CgroupUnified = true
+					}
+				}
+			}
+		}
+	}
+
+	if mountpoint, exists := legacy["memory"]; exists {
+		sysInfo.MemoryLimit = fileExists(mountpoint, "memory.limit_in_bytes") && fileExists(mountpoint, "memory.soft_limit_in_bytes")
+		sysInfo.SwapLimit = fileExists(mountpoint, "memory.memsw.limit_in_bytes")
+	}
+	if mountpoint, exists := legacy["cpu"]; exists {
+		sysInfo.CpuShares = fileExists(mountpoint, "cpu.shares")
+	}
+	if mountpoint, exists := legacy["cpuset"]; exists {
+		sysInfo.Cpuset = fileExists(mountpoint, "cpuset.cpus")
+	}
+	if mountpoint, exists := legacy["blkio"]; exists {
+		sysInfo.BlkioWeight = fileExists(mountpoint, "blkio.weight")
+	}
+	// The root cgroup has no pids.max, the mount is enough
+	_, sysInfo.PidsLimit = legacy["pids"]
+}
+
+func fileExists(dir, name string) bool {
+	_, err := os.Stat(path.Join(dir, name))
+	return err == nil
+}
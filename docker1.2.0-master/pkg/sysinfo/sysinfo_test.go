@@ -0,0 +1,87 @@
+package sysinfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/pkg/mount"
+)
+
+func createFiles(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		p := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte("max\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetectLegacyCgroups(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-test-sysinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	createFiles(t, path.Join(root, "memory"), "memory.limit_in_bytes", "memory.soft_limit_in_bytes")
+	createFiles(t, path.Join(root, "cpu,cpuacct"), "cpu.shares")
+	createFiles(t, path.Join(root, "cpuset"), "cpuset.cpus")
+	mounts := []*mount.MountInfo{
+		{Fstype: "cgroup", Mountpoint: path.Join(root, "memory"), VfsOpts: "rw,memory"},
+		{Fstype: "cgroup", Mountpoint: path.Join(root, "cpu,cpuacct"), VfsOpts: "rw,cpu,cpuacct"},
+		{Fstype: "cgroup", Mountpoint: path.Join(root, "cpuset"), VfsOpts: "rw,cpuset"},
+		{Fstype: "cgroup", Mountpoint: path.Join(root, "pids"), VfsOpts: "rw,pids"},
+	}
+
+	sysInfo := &SysInfo{}
+	sysInfo.detectCgroups(mounts)
+	if !sysInfo.MemoryLimit || sysInfo.SwapLimit || sysInfo.CgroupUnified {
+		t.Fatalf("Expected a memory limit without swap limit in the legacy hierarchy, got %+v", sysInfo)
+	}
+	if expected := []string{"memory", "cpu", "cpuset", "pids"}; !reflect.DeepEqual(sysInfo.Controllers(), expected) {
+		t.Fatalf("Expected the controllers %v, got %v", expected, sysInfo.Controllers())
+	}
+}
+
+func TestDetectUnifiedCgroups(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-test-sysinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(path.Join(root, "cgroup.controllers"), []byte("cpuset cpu io memory\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mounts := []*mount.MountInfo{
+		{Fstype: "cgroup2", Mountpoint: root, VfsOpts: "rw"},
+	}
+
+	// The vendored cgroup manager can't enforce the v2 controllers
+	sysInfo := &SysInfo{}
+	sysInfo.detectCgroups(mounts)
+	if !sysInfo.CgroupUnified || sysInfo.MemoryLimit || sysInfo.SwapLimit {
+		t.Fatalf("Expected no limit in the unified hierarchy, got %+v", sysInfo)
+	}
+	if controllers := sysInfo.Controllers(); len(controllers) != 0 {
+		t.Fatalf("Expected no controllers, got %v", controllers)
+	}
+
+	// The controllers in a legacy hierarchy are usable in hybrid setups
+	createFiles(t, path.Join(root, "legacy-memory"), "memory.limit_in_bytes", "memory.soft_limit_in_bytes")
+	mounts = append(mounts, &mount.MountInfo{Fstype: "cgroup", Mountpoint: path.Join(root, "legacy-memory"), VfsOpts: "rw,memory"})
+	sysInfo = &SysInfo{}
+	sysInfo.detectCgroups(mounts)
+	if !sysInfo.CgroupUnified || !sysInfo.MemoryLimit || sysInfo.SwapLimit {
+		t.Fatalf("Expected the legacy memory hierarchy, got %+v", sysInfo)
+	}
+	if expected := []string{"memory"}; !reflect.DeepEqual(sysInfo.Controllers(), expected) {
+		t.Fatalf("Expected the controllers %v, got %v", expected, sysInfo.Controllers())
+	}
+}
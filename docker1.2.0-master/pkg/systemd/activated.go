@@ -0,0 +1,65 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"sync"
+
+	"github.com/coreos/go-systemd/activation"
+)
+
+var (
+	activatedOnce      sync.Once
+	activatedListeners []net.Listener
+	activatedErr       error
+)
+
+// activatedSockets returns the socket activated listeners. The files passed by
+// systemd are only opened once, for all the addresses to share them.
+func activatedSockets() ([]net.Listener, error) {
+	activatedOnce.Do(func() {
+		activatedListeners, activatedErr = activation.Listeners(false)
+	})
+	return activatedListeners, activatedErr
+}
+
+// ActivatedListener returns the socket activated listener of the unix or
+// tcp address, or nil when systemd passed none.
+func ActivatedListener(proto, addr string) (net.Listener, error) {
+	ls, err := activatedSockets()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range ls {
+		if matchAddr(l.Addr(), proto, addr) {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchAddr tells whether the address of a listener is proto://addr. The
+// unspecified addresses, 0.0.0.0 and ::, match each other.
+func matchAddr(la net.Addr, proto, addr string) bool {
+	if la.Network() != proto {
+		return false
+	}
+	switch proto {
+	case "unix":
+		return filepath.Clean(la.String()) == filepath.Clean(addr)
+	case "tcp":
+		tcpAddr, ok := la.(*net.TCPAddr)
+		if !ok {
+			return false
+		}
+		ra, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil || ra.Port != tcpAddr.Port {
+			return false
+		}
+		if ra.IP == nil || ra.IP.IsUnspecified() {
+			return tcpAddr.IP == nil || tcpAddr.IP.IsUnspecified()
+		}
+		return ra.IP.Equal(tcpAddr.IP)
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package systemd
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMatchAddr(t *testing.T) {
+	for _, c := range []struct {
+		la    net.Addr
+		proto string
+		addr  string
+		match bool
+	}{
+		{&net.UnixAddr{Name: "/var/run/docker.sock", Net: "unix"}, "unix", "/var/run/docker.sock", true},
+		{&net.UnixAddr{Name: "/var/run/docker.sock", Net: "unix"}, "unix", "/var/run/other.sock", false},
+		{&net.UnixAddr{Name: "/var/run/docker.sock", Net: "unix"}, "tcp", "/var/run/docker.sock", false},
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 2375}, "tcp", "0.0.0.0:2375", true},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 2375}, "tcp", "0.0.0.0:2375", true},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2375}, "tcp", "127.0.0.1:2375", true},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2375}, "tcp", "127.0.0.1:2376", false},
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 2375}, "tcp", "127.0.0.1:2375", false},
+	} {
+		if match := matchAddr(c.la, c.proto, c.addr); match != c.match {
+			t.Errorf("Expected %s to match %s://%s: %t, got %t", c.la, c.proto, c.addr, c.match, match)
+		}
+	}
+}
@@ -4,15 +4,13 @@ import (
 	"errors"
 	"net"
 	"strconv"
-
-	"github.com/coreos/go-systemd/activation"
 )
 
 // ListenFD returns the specified socket activated files as a slice of
 // net.Listeners or all of the activated files if "*" is given.
 func ListenFD(addr string) ([]net.Listener, error) {
 	// socket activation
-	listeners, err := activation.Listeners(false)
+	listeners, err := activatedSockets()
 	if err != nil {
 		return nil, err
 	}
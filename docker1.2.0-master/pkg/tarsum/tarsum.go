@@ -30,6 +30,12 @@ type TarSum struct {
 	finished           bool
 	first              bool
 	DisableCompression bool
+	// CompressionLevel is the level of the gzip compression of the output,
+	// 0 being the default level
+	CompressionLevel int
+	// IgnoreMtime leaves the modification times out of the sums, which
+	// then only depend on the contents and the other metadata of the files
+	IgnoreMtime bool
 }
 
 type writeCloseFlusher interface {
@@ -50,13 +56,17 @@ func (n *nopCloseFlusher) Flush() error {
 }
 
 func (ts *TarSum) encodeHeader(h *tar.Header) error {
+	mtime := strconv.Itoa(int(h.ModTime.UTC().Unix()))
+	if ts.IgnoreMtime {
+		mtime = ""
+	}
 	for _, elem := range [][2]string{
 		{"name", h.Name},
 		{"mode", strconv.Itoa(int(h.Mode))},
 		{"uid", strconv.Itoa(h.Uid)},
 		{"gid", strconv.Itoa(h.Gid)},
 		{"size", strconv.Itoa(int(h.Size))},
-		{"mtime", strconv.Itoa(int(h.ModTime.UTC().Unix()))},
+		{"mtime", mtime},
 		{"typeflag", string([]byte{h.Typeflag})},
 		{"linkname", h.Linkname},
 		{"uname", h.Uname},
@@ -79,7 +89,13 @@ func (ts *TarSum) Read(buf []byte) (int, error) {
 		ts.bufGz = bytes.NewBuffer([]byte{})
 		ts.tarR = tar.NewReader(ts.Reader)
 		ts.tarW = tar.NewWriter(ts.bufTar)
-		if !ts.DisableCompression {
+		if !ts.DisableCompression && ts.CompressionLevel != 0 {
+			gz, err := gzip.NewWriterLevel(ts.bufGz, ts.CompressionLevel)
+			if err != nil {
+				return 0, err
+			}
+			ts.gz = gz
+		} else if !ts.DisableCompression {
 			ts.gz = gzip.NewWriter(ts.bufGz)
 		} else {
 			ts.gz = &nopCloseFlusher{Writer: ts.bufGz}
@@ -177,11 +193,11 @@ func (ts *TarSum) Sum(extra []byte) string {
 		h.Write(extra)
 	}
 	for _, sum := range sums {
-		log.Infof("-->%s<--", sum)
+		log.Debugf("-->%s<--", sum)
 		h.Write([]byte(sum))
 	}
 	checksum := "tarsum+sha256:" + hex.EncodeToString(h.Sum(nil))
-	log.Infof("checksum processed: %s", checksum)
+	log.Debugf("checksum processed: %s", checksum)
 	return checksum
 }
 
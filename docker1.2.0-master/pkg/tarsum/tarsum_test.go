@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/vendor/src/code.google.com/p/go/src/pkg/archive/tar"
 )
@@ -17,6 +18,7 @@ type testLayer struct {
 	options  *sizedOptions
 	jsonfile string
 	gzip     bool
+	level    int
 	tarsum   string
 }
 
@@ -30,6 +32,12 @@ var testLayers = []testLayer{
 		jsonfile: "testdata/46af0962ab5afeb5ce6740d4d91652e69206fc991fd5328c1a94d364ad00e457/json",
 		gzip:     true,
 		tarsum:   "tarsum+sha256:e58fcf7418d4390dec8e8fb69d88c06ec07039d651fedd3aa72af9972e7d046b"},
+	{
+		filename: "testdata/46af0962ab5afeb5ce6740d4d91652e69206fc991fd5328c1a94d364ad00e457/layer.tar",
+		jsonfile: "testdata/46af0962ab5afeb5ce6740d4d91652e69206fc991fd5328c1a94d364ad00e457/json",
+		gzip:     true,
+		level:    1,
+		tarsum:   "tarsum+sha256:e58fcf7418d4390dec8e8fb69d88c06ec07039d651fedd3aa72af9972e7d046b"},
 	{
 		filename: "testdata/511136ea3c5a64f264b78b5433614aec563103b4d4702f3ba7d4d2698e22c158/layer.tar",
 		jsonfile: "testdata/511136ea3c5a64f264b78b5433614aec563103b4d4702f3ba7d4d2698e22c158/json",
@@ -118,7 +126,7 @@ func TestTarSums(t *testing.T) {
 		}
 
 		//                                  double negatives!
-		ts := &TarSum{Reader: fh, DisableCompression: !layer.gzip}
+		ts := &TarSum{Reader: fh, DisableCompression: !layer.gzip, CompressionLevel: layer.level}
 		_, err = io.Copy(ioutil.Discard, ts)
 		if err != nil {
 			t.Errorf("failed to copy from %s: %s", layer.filename, err)
@@ -147,6 +155,35 @@ func TestTarSums(t *testing.T) {
 	}
 }
 
+func TestTarSumIgnoreMtime(t *testing.T) {
+	sum := func(mtime time.Time, ignoreMtime bool) string {
+		buf := bytes.NewBuffer([]byte{})
+		tarW := tar.NewWriter(buf)
+		content := []byte("hello world")
+		if err := tarW.WriteHeader(&tar.Header{Name: "file", Mode: 0644, Size: int64(len(content)), ModTime: mtime}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarW.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := tarW.Close(); err != nil {
+			t.Fatal(err)
+		}
+		ts := &TarSum{Reader: buf, DisableCompression: true, IgnoreMtime: ignoreMtime}
+		if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+			t.Fatal(err)
+		}
+		return ts.GetSums()["file"]
+	}
+	before, after := time.Unix(1400000000, 0), time.Unix(1400000100, 0)
+	if sum(before, false) == sum(after, false) {
+		t.Fatal("Expected the sums to depend on the modification time")
+	}
+	if sum(before, true) != sum(after, true) {
+		t.Fatal("Expected the sums to ignore the modification time")
+	}
+}
+
 func Benchmark9kTar(b *testing.B) {
 	buf := bytes.NewBuffer([]byte{})
 	fh, err := os.Open("testdata/46af0962ab5afeb5ce6740d4d91652e69206fc991fd5328c1a94d364ad00e457/layer.tar")
@@ -3,6 +3,7 @@ package truncindex
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -38,7 +39,8 @@ func NewTruncIndex(ids []string) (idx *TruncIndex) {
 	return
 }
 
-func (idx *TruncIndex) addId(id string) error {
+// checkId returns an error if id can't be added to the index.
+func (idx *TruncIndex) checkId(id string) error {
 	if strings.Contains(id, " ") {
 		return fmt.Errorf("Illegal character: ' '")
 	}
@@ -48,6 +50,13 @@ func (idx *TruncIndex) addId(id string) error {
 	if _, exists := idx.ids[id]; exists {
 		return fmt.Errorf("Id already exists: '%s'", id)
 	}
+	return nil
+}
+
+func (idx *TruncIndex) addId(id string) error {
+	if err := idx.checkId(id); err != nil {
+		return err
+	}
 	idx.ids[id] = struct{}{}
 	if inserted := idx.trie.Insert(patricia.Prefix(id), struct{}{}); !inserted {
 		return fmt.Errorf("Failed to insert id: %s", id)
@@ -64,6 +73,30 @@ func (idx *TruncIndex) Add(id string) error {
 	return nil
 }
 
+// AddBatch adds ids under a single lock, e.g. when restoring the ids at
+// startup. Either all the ids are added or, if one is invalid or exists
+// already, none.
+func (idx *TruncIndex) AddBatch(ids []string) error {
+	idx.Lock()
+	defer idx.Unlock()
+	batch := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if err := idx.checkId(id); err != nil {
+			return err
+		}
+		if _, exists := batch[id]; exists {
+			return fmt.Errorf("Id already exists: '%s'", id)
+		}
+		batch[id] = struct{}{}
+	}
+	for _, id := range ids {
+		if err := idx.addId(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (idx *TruncIndex) Delete(id string) error {
 	idx.Lock()
 	defer idx.Unlock()
@@ -104,3 +137,18 @@ func (idx *TruncIndex) Get(s string) (string, error) {
 	}
 	return "", fmt.Errorf("No such id: %s", s)
 }
+
+// Iterate calls handler with each id of the index, in lexical order. The ids
+// are those of the index when it is called, handler being free to modify it.
+func (idx *TruncIndex) Iterate(handler func(id string)) {
+	idx.RLock()
+	ids := make([]string, 0, len(idx.ids))
+	for id := range idx.ids {
+		ids = append(ids, id)
+	}
+	idx.RUnlock()
+	sort.Strings(ids)
+	for _, id := range ids {
+		handler(id)
+	}
+}
@@ -2,6 +2,7 @@ package truncindex
 
 import (
 	"math/rand"
+	"reflect"
 	"testing"
 
 	"github.com/docker/docker/utils"
@@ -103,6 +104,53 @@ func assertIndexGet(t *testing.T, index *TruncIndex, input, expectedResult strin
 	}
 }
 
+func TestTruncIndexAddBatch(t *testing.T) {
+	index := NewTruncIndex([]string{})
+	id := "99b36c2c326ccc11e726eee6ee78a0baf166ef96"
+	id2 := "27d0c4a4f0a6d21e6ad9a2d9c4b3fb3ca6c1bb0d"
+	if err := index.AddBatch([]string{id, id2}); err != nil {
+		t.Fatal(err)
+	}
+	assertIndexGet(t, index, id[:4], id, false)
+	assertIndexGet(t, index, id2[:4], id2, false)
+
+	// A batch is added entirely or not at all
+	id3 := "5e6f7a8b9c0d"
+	for _, batch := range [][]string{
+		{id3, id},
+		{id3, "I have a space"},
+		{id3, ""},
+		{id3, id3},
+	} {
+		if err := index.AddBatch(batch); err == nil {
+			t.Fatalf("Adding the batch %v should return an error", batch)
+		}
+		assertIndexGet(t, index, id3, "", true)
+	}
+}
+
+func TestTruncIndexIterate(t *testing.T) {
+	ids := []string{"c3d4", "a1b2", "b2c3"}
+	index := NewTruncIndex(ids)
+	var got []string
+	index.Iterate(func(id string) {
+		got = append(got, id)
+	})
+	if expected := []string{"a1b2", "b2c3", "c3d4"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected the ids %v, got %v", expected, got)
+	}
+	if err := index.Delete("b2c3"); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	index.Iterate(func(id string) {
+		got = append(got, id)
+	})
+	if expected := []string{"a1b2", "c3d4"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected the ids %v, got %v", expected, got)
+	}
+}
+
 func BenchmarkTruncIndexAdd100(b *testing.B) {
 	var testSet []string
 	for i := 0; i < 100; i++ {
@@ -399,3 +447,43 @@ func BenchmarkTruncIndexAddGet500(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkTruncIndexAddBatch100000(b *testing.B) {
+	var testSet []string
+	for i := 0; i < 100000; i++ {
+		testSet = append(testSet, utils.GenerateRandomID())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index := NewTruncIndex([]string{})
+		if err := index.AddBatch(testSet); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTruncIndexGet100000 looks 500 prefixes up in an index of 100k ids,
+// to be compared with BenchmarkTruncIndexGet500.
+func BenchmarkTruncIndexGet100000(b *testing.B) {
+	var testSet []string
+	var testKeys []string
+	for i := 0; i < 100000; i++ {
+		testSet = append(testSet, utils.GenerateRandomID())
+	}
+	index := NewTruncIndex([]string{})
+	if err := index.AddBatch(testSet); err != nil {
+		b.Fatal(err)
+	}
+	for _, id := range testSet[:500] {
+		l := rand.Intn(12) + 12
+		testKeys = append(testKeys, id[:l])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range testKeys {
+			if res, err := index.Get(id); err != nil {
+				b.Fatal(res, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package ulimit parses the resource limits of the containers, given as
+// name=soft[:hard], e.g. nofile=1024:2048.
+package ulimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ulimit is a resource limit of a container, by the name of the resource
+// as in ulimit(1).
+type Ulimit struct {
+	Name string
+	Hard int64
+	Soft int64
+}
+
+// Rlimit is a limit to set with setrlimit(2).
+type Rlimit struct {
+	Type int    `json:"type,omitempty"`
+	Hard uint64 `json:"hard,omitempty"`
+	Soft uint64 `json:"soft,omitempty"`
+}
+
+// The resources of setrlimit(2) on linux, not all being in syscall
+const (
+	rlimitCore    = 4
+	rlimitNproc   = 6
+	rlimitNofile  = 7
+	rlimitMemlock = 8
+)
+
+var ulimitNameMapping = map[string]int{
+	"core":    rlimitCore,
+	"memlock": rlimitMemlock,
+	"nofile":  rlimitNofile,
+	"nproc":   rlimitNproc,
+}
+
+// Parse parses a ulimit given as name=soft[:hard], the hard limit being
+// the soft one when not given.
+func Parse(val string) (*Ulimit, error) {
+	parts := strings.SplitN(val, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid ulimit %s, expected name=soft[:hard]", val)
+	}
+	if _, exists := ulimitNameMapping[parts[0]]; !exists {
+		return nil, fmt.Errorf("invalid ulimit %s, the supported ulimits are core, memlock, nofile and nproc", val)
+	}
+
+	limits := strings.SplitN(parts[1], ":", 2)
+	soft, err := strconv.ParseInt(limits[0], 10, 64)
+	if err != nil || soft < 0 {
+		return nil, fmt.Errorf("invalid ulimit %s, the limits must be positive numbers", val)
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = strconv.ParseInt(limits[1], 10, 64); err != nil || hard < 0 {
+			return nil, fmt.Errorf("invalid ulimit %s, the limits must be positive numbers", val)
+		}
+	}
+	if soft > hard {
+		return nil, fmt.Errorf("invalid ulimit %s, the soft limit %d is over the hard limit %d", val, soft, hard)
+	}
+	return &Ulimit{Name: parts[0], Soft: soft, Hard: hard}, nil
+}
+
+// GetRlimit returns the limit to set for the ulimit.
+func (u *Ulimit) GetRlimit() (*Rlimit, error) {
+	t, exists := ulimitNameMapping[u.Name]
+	if !exists {
+		return nil, fmt.Errorf("invalid ulimit name %s", u.Name)
+	}
+	return &Rlimit{Type: t, Soft: uint64(u.Soft), Hard: uint64(u.Hard)}, nil
+}
+
+func (u *Ulimit) String() string {
+	return fmt.Sprintf("%s=%d:%d", u.Name, u.Soft, u.Hard)
+}
@@ -0,0 +1,59 @@
+package ulimit
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for val, expected := range map[string]Ulimit{
+		"nofile=512:1024": {Name: "nofile", Soft: 512, Hard: 1024},
+		"nproc=1024":      {Name: "nproc", Soft: 1024, Hard: 1024},
+		"core=0":          {Name: "core", Soft: 0, Hard: 0},
+	} {
+		u, err := Parse(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *u != expected {
+			t.Fatalf("Expected %v for %s, got %v", expected, val, u)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	u, err := Parse("nproc=1024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.String() != "nproc=1024:1024" {
+		t.Fatalf("Unexpected string %s", u)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, val := range []string{
+		"nofile",
+		"nofile=",
+		"nofile=abc",
+		"nofile=-1",
+		"nofile=1024:512",
+		"nofile=512:abc",
+		"cpu=10",
+	} {
+		if u, err := Parse(val); err == nil {
+			t.Fatalf("Expected an error for %s, got %v", val, u)
+		}
+	}
+}
+
+func TestGetRlimit(t *testing.T) {
+	u := &Ulimit{Name: "nofile", Soft: 512, Hard: 1024}
+	r, err := u.GetRlimit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != rlimitNofile || r.Soft != 512 || r.Hard != 1024 {
+		t.Fatalf("Unexpected rlimit %#v", r)
+	}
+	if _, err := (&Ulimit{Name: "cpu"}).GetRlimit(); err == nil {
+		t.Fatal("Expected an error for an unsupported ulimit")
+	}
+}
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -150,8 +151,9 @@ func SaveConfig(configFile *ConfigFile) error {
 	return nil
 }
 
-// try to register/login to the registry server
-func Login(authConfig *AuthConfig, factory *utils.HTTPRequestFactory) (string, error) {
+// try to register/login to the registry server, verifying its certificate
+// if it is secure
+func Login(authConfig *AuthConfig, factory *utils.HTTPRequestFactory, secure bool) (string, error) {
 	var (
 		status  string
 		reqBody []byte
@@ -160,6 +162,7 @@ func Login(authConfig *AuthConfig, factory *utils.HTTPRequestFactory) (string, e
 			Transport: &http.Transport{
 				DisableKeepAlives: true,
 				Proxy:             http.ProxyFromEnvironment,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: !secure},
 			},
 			CheckRedirect: AddRequiredHeadersToRedirectedRequests,
 		}
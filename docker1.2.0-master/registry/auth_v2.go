@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docker/docker/utils"
+)
+
+// authChallenge is the WWW-Authenticate challenge of a v2 registry.
+type authChallenge struct {
+	Scheme     string
+	Parameters map[string]string
+}
+
+// parseAuthChallenge parses challenges like
+// Bearer realm="https://auth.example.com/token",service="registry.example.com"
+func parseAuthChallenge(header string) (*authChallenge, error) {
+	header = strings.TrimSpace(header)
+	n := strings.Index(header, " ")
+	if n < 0 {
+		return &authChallenge{Scheme: strings.ToLower(header), Parameters: map[string]string{}}, nil
+	}
+	challenge := &authChallenge{
+		Scheme:     strings.ToLower(header[:n]),
+		Parameters: make(map[string]string),
+	}
+	rest := header[n+1:]
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("Invalid auth challenge: %s", header)
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("Invalid auth challenge: %s", header)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if end := strings.Index(rest, ","); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+		challenge.Parameters[key] = value
+	}
+	return challenge, nil
+}
+
+// setV2Auth authorizes req to do actions (pull, push) on the repository
+// name, fetching a token from the registry's auth server when challenged
+// with the Bearer scheme.
+func (r *Session) setV2Auth(req *http.Request, name string, actions ...string) error {
+	if r.v2Challenge == nil {
+		return nil
+	}
+	switch r.v2Challenge.Scheme {
+	case "basic":
+		if r.authConfig != nil && len(r.authConfig.Username) > 0 {
+			req.SetBasicAuth(r.authConfig.Username, r.authConfig.Password)
+		}
+	case "bearer":
+		token, err := r.getV2Token(fmt.Sprintf("repository:%s:%s", name, strings.Join(actions, ",")))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		return fmt.Errorf("Unsupported auth scheme %s", r.v2Challenge.Scheme)
+	}
+	return nil
+}
+
+// getV2Token returns a token for scope from the realm of the challenge,
+// fetched with the credentials of the session.
+func (r *Session) getV2Token(scope string) (string, error) {
+	r.v2Lock.Lock()
+	defer r.v2Lock.Unlock()
+	if token, exists := r.v2Tokens[scope]; exists {
+		return token, nil
+	}
+
+	realm := r.v2Challenge.Parameters["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("No realm in the auth challenge of %s", r.indexEndpoint)
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+	// The credentials are sent to the realm, which could be any server: only
+	// over https, unless the registry itself is insecure
+	if u.Scheme != "https" && (r.secure || u.Scheme != "http") {
+		return "", fmt.Errorf("Refusing to authenticate to the realm %s of %s over %s, add the registry with --insecure-registry to allow it", realm, r.indexEndpoint, u.Scheme)
+	}
+	q := u.Query()
+	if service := r.v2Challenge.Parameters["service"]; service != "" {
+		q.Set("service", service)
+	}
+	q.Set("scope", scope)
+	if r.authConfig != nil && len(r.authConfig.Username) > 0 {
+		q.Set("account", r.authConfig.Username)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := r.reqFactory.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if r.authConfig != nil && len(r.authConfig.Username) > 0 {
+		req.SetBasicAuth(r.authConfig.Username, r.authConfig.Password)
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 401 {
+		return "", errLoginRequired
+	}
+	if res.StatusCode != 200 {
+		return "", utils.NewHTTPRequestError(fmt.Sprintf("HTTP code %d while getting a token for %s", res.StatusCode, scope), res)
+	}
+
+	var tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokenResponse); err != nil {
+		return "", err
+	}
+	token := tokenResponse.Token
+	if token == "" {
+		token = tokenResponse.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("Auth server of %s returned no token", r.indexEndpoint)
+	}
+	if r.v2Tokens == nil {
+		r.v2Tokens = make(map[string]string)
+	}
+	r.v2Tokens[scope] = token
+	return token, nil
+}
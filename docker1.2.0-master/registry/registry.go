@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"regexp"
@@ -33,8 +34,12 @@ const (
 	ConnectTimeout
 )
 
-func newClient(jar http.CookieJar, roots *x509.CertPool, cert *tls.Certificate, timeout TimeoutType) *http.Client {
-	tlsConfig := tls.Config{RootCAs: roots}
+func newClient(jar http.CookieJar, roots *x509.CertPool, cert *tls.Certificate, timeout TimeoutType, secure bool) *http.Client {
+	tlsConfig := tls.Config{
+		RootCAs: roots,
+		// Insecure registries may use self-signed certificates
+		InsecureSkipVerify: !secure,
+	}
 
 	if cert != nil {
 		tlsConfig.Certificates = append(tlsConfig.Certificates, *cert)
@@ -76,7 +81,7 @@ func newClient(jar http.CookieJar, roots *x509.CertPool, cert *tls.Certificate,
 	}
 }
 
-func doRequest(req *http.Request, jar http.CookieJar, timeout TimeoutType) (*http.Response, *http.Client, error) {
+func doRequest(req *http.Request, jar http.CookieJar, timeout TimeoutType, secure bool) (*http.Response, *http.Client, error) {
 	hasFile := func(files []os.FileInfo, name string) bool {
 		for _, f := range files {
 			if f.Name() == name {
@@ -132,7 +137,7 @@ func doRequest(req *http.Request, jar http.CookieJar, timeout TimeoutType) (*htt
 	}
 
 	if len(certs) == 0 {
-		client := newClient(jar, pool, nil, timeout)
+		client := newClient(jar, pool, nil, timeout, secure)
 		res, err := client.Do(req)
 		if err != nil {
 			return nil, nil, err
@@ -140,7 +145,7 @@ func doRequest(req *http.Request, jar http.CookieJar, timeout TimeoutType) (*htt
 		return res, client, nil
 	} else {
 		for i, cert := range certs {
-			client := newClient(jar, pool, cert, timeout)
+			client := newClient(jar, pool, cert, timeout, secure)
 			res, err := client.Do(req)
 			if i == len(certs)-1 {
 				// If this is the last cert, always return the result
@@ -157,7 +162,7 @@ func doRequest(req *http.Request, jar http.CookieJar, timeout TimeoutType) (*htt
 	return nil, nil, nil
 }
 
-func pingRegistryEndpoint(endpoint string) (RegistryInfo, error) {
+func pingRegistryEndpoint(endpoint string, secure bool) (RegistryInfo, error) {
 	if endpoint == IndexServerAddress() {
 		// Skip the check, we now this one is valid
 		// (and we never want to fallback to http in case of error)
@@ -169,7 +174,7 @@ func pingRegistryEndpoint(endpoint string) (RegistryInfo, error) {
 		return RegistryInfo{Standalone: false}, err
 	}
 
-	resp, _, err := doRequest(req, nil, ConnectTimeout)
+	resp, _, err := doRequest(req, nil, ConnectTimeout, secure)
 	if err != nil {
 		return RegistryInfo{Standalone: false}, err
 	}
@@ -258,26 +263,63 @@ func ResolveRepositoryName(reposName string) (string, string, error) {
 	return hostname, reposName, nil
 }
 
+// IsSecure tells whether the registry at hostname must be accessed over
+// https with its certificate verified. Only the registries listed in
+// insecureRegistries, as host, host:port or CIDR, and the ones on the
+// loopback may be accessed over http or with a self-signed certificate.
+func IsSecure(hostname string, insecureRegistries []string) bool {
+	if hostname == IndexServerAddress() {
+		return true
+	}
+	if u, err := url.Parse(hostname); err == nil && u.Host != "" {
+		hostname = u.Host
+	}
+	host := hostname
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return false
+	}
+	for _, insecure := range insecureRegistries {
+		if insecure == hostname || insecure == host {
+			return false
+		}
+		if _, ipnet, err := net.ParseCIDR(insecure); err == nil && ip != nil && ipnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
 // this method expands the registry name as used in the prefix of a repo
 // to a full url. if it already is a url, there will be no change.
-// The registry is pinged to test if it http or https
-func ExpandAndVerifyRegistryUrl(hostname string) (string, error) {
+// The registry is pinged to test if it http or https, secure registries
+// being only accessed over https.
+func ExpandAndVerifyRegistryUrl(hostname string, secure bool) (string, error) {
 	if strings.HasPrefix(hostname, "http:") || strings.HasPrefix(hostname, "https:") {
+		if secure && strings.HasPrefix(hostname, "http:") {
+			return "", fmt.Errorf("Invalid Registry endpoint: %s is not an insecure registry, use https or add it with --insecure-registry", hostname)
+		}
 		// if there is no slash after https:// (8 characters) then we have no path in the url
 		if strings.LastIndex(hostname, "/") < 9 {
 			// there is no path given. Expand with default path
 			hostname = hostname + "/v1/"
 		}
-		if _, err := pingRegistryEndpoint(hostname); err != nil {
+		if _, err := pingRegistryEndpoint(hostname, secure); err != nil {
 			return "", errors.New("Invalid Registry endpoint: " + err.Error())
 		}
 		return hostname, nil
 	}
 	endpoint := fmt.Sprintf("https://%s/v1/", hostname)
-	if _, err := pingRegistryEndpoint(endpoint); err != nil {
+	if _, err := pingRegistryEndpoint(endpoint, secure); err != nil {
+		if secure {
+			return "", fmt.Errorf("Invalid Registry endpoint %s: %s. If this registry uses http or a self-signed certificate, add it with --insecure-registry %s", endpoint, err, hostname)
+		}
 		log.Debugf("Registry %s does not work (%s), falling back to http", endpoint, err)
 		endpoint = fmt.Sprintf("http://%s/v1/", hostname)
-		if _, err = pingRegistryEndpoint(endpoint); err != nil {
+		if _, err = pingRegistryEndpoint(endpoint, secure); err != nil {
 			//TODO: triggering highland build can be done there without "failing"
 			return "", errors.New("Invalid Registry endpoint: " + err.Error())
 		}
@@ -2,11 +2,13 @@ package registry
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/docker/docker/archive"
 	"github.com/docker/docker/utils"
 )
 
@@ -18,7 +20,7 @@ var (
 
 func spawnTestRegistrySession(t *testing.T) *Session {
 	authConfig := &AuthConfig{}
-	r, err := NewSession(authConfig, utils.NewHTTPRequestFactory(), makeURL("/v1/"), true)
+	r, err := NewSession(authConfig, utils.NewHTTPRequestFactory(), makeURL("/v1/"), true, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -26,7 +28,7 @@ func spawnTestRegistrySession(t *testing.T) *Session {
 }
 
 func TestPingRegistryEndpoint(t *testing.T) {
-	regInfo, err := pingRegistryEndpoint(makeURL("/v1/"))
+	regInfo, err := pingRegistryEndpoint(makeURL("/v1/"), false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -72,7 +74,7 @@ func TestGetRemoteImageJSON(t *testing.T) {
 
 func TestGetRemoteImageLayer(t *testing.T) {
 	r := spawnTestRegistrySession(t)
-	data, err := r.GetRemoteImageLayer(IMAGE_ID, makeURL("/v1/"), TOKEN, 0)
+	data, err := r.GetRemoteImageLayer(IMAGE_ID, makeURL("/v1/"), TOKEN, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -80,10 +82,24 @@ func TestGetRemoteImageLayer(t *testing.T) {
 		t.Fatal("Expected non-nil data result")
 	}
 
-	_, err = r.GetRemoteImageLayer("abcdef", makeURL("/v1/"), TOKEN, 0)
+	_, err = r.GetRemoteImageLayer("abcdef", makeURL("/v1/"), TOKEN, 0, 0)
 	if err == nil {
 		t.Fatal("Expected image not found error")
 	}
+
+	// The mock registry ignores ranges, the reader must skip to the offset
+	data, err = r.GetRemoteImageLayer(IMAGE_ID, makeURL("/v1/"), TOKEN, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+	rest, err := ioutil.ReadAll(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != testLayers[IMAGE_ID]["layer"][10:] {
+		t.Fatal("Expected the layer from offset 10")
+	}
 }
 
 func TestGetRemoteTags(t *testing.T) {
@@ -138,7 +154,7 @@ func TestPushImageJSONRegistry(t *testing.T) {
 func TestPushImageLayerRegistry(t *testing.T) {
 	r := spawnTestRegistrySession(t)
 	layer := strings.NewReader("")
-	_, _, err := r.PushImageLayerRegistry(IMAGE_ID, layer, makeURL("/v1/"), TOKEN, []byte{})
+	_, _, err := r.PushImageLayerRegistry(IMAGE_ID, layer, makeURL("/v1/"), TOKEN, []byte{}, archive.Gzip, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -300,3 +316,25 @@ func TestAddRequiredHeadersToRedirectedRequests(t *testing.T) {
 		}
 	}
 }
+
+func TestIsSecure(t *testing.T) {
+	insecureRegistries := []string{"registry.internal", "other.internal:5000", "10.1.0.0/16"}
+	for hostname, secure := range map[string]bool{
+		IndexServerAddress():          true,
+		"registry.example.com":        true,
+		"registry.internal":           false,
+		"registry.internal:5000":      false,
+		"other.internal:5000":         false,
+		"other.internal:5001":         true,
+		"10.1.2.3:5000":               false,
+		"10.2.2.3:5000":               true,
+		"localhost:5000":              false,
+		"127.0.0.1:5000":              false,
+		"https://registry.internal/":  false,
+		"https://registry.example.io": true,
+	} {
+		if IsSecure(hostname, insecureRegistries) != secure {
+			t.Errorf("Expected IsSecure(%q) to be %v", hostname, secure)
+		}
+	}
+}
@@ -13,12 +13,16 @@ import (
 //  'pull': Download images from any registry (TODO)
 //  'push': Upload images to any registry (TODO)
 type Service struct {
+	insecureRegistries []string
 }
 
 // NewService returns a new instance of Service ready to be
-// installed no an engine.
-func NewService() *Service {
-	return &Service{}
+// installed no an engine. The registries listed in insecureRegistries
+// may be accessed over http or with self-signed certificates.
+func NewService(insecureRegistries []string) *Service {
+	return &Service{
+		insecureRegistries: insecureRegistries,
+	}
 }
 
 // Install installs registry capabilities to eng.
@@ -39,14 +43,16 @@ func (s *Service) Auth(job *engine.Job) engine.Status {
 
 	job.GetenvJson("authConfig", authConfig)
 	// TODO: this is only done here because auth and registry need to be merged into one pkg
+	secure := true
 	if addr := authConfig.ServerAddress; addr != "" && addr != IndexServerAddress() {
-		addr, err = ExpandAndVerifyRegistryUrl(addr)
+		secure = IsSecure(addr, s.insecureRegistries)
+		addr, err = ExpandAndVerifyRegistryUrl(addr, secure)
 		if err != nil {
 			return job.Error(err)
 		}
 		authConfig.ServerAddress = addr
 	}
-	status, err := Login(authConfig, HTTPRequestFactory(nil))
+	status, err := Login(authConfig, HTTPRequestFactory(nil), secure)
 	if err != nil {
 		return job.Error(err)
 	}
@@ -86,11 +92,12 @@ func (s *Service) Search(job *engine.Job) engine.Status {
 	if err != nil {
 		return job.Error(err)
 	}
-	hostname, err = ExpandAndVerifyRegistryUrl(hostname)
+	secure := IsSecure(hostname, s.insecureRegistries)
+	hostname, err = ExpandAndVerifyRegistryUrl(hostname, secure)
 	if err != nil {
 		return job.Error(err)
 	}
-	r, err := NewSession(authConfig, HTTPRequestFactory(metaHeaders), hostname, true)
+	r, err := NewSession(authConfig, HTTPRequestFactory(metaHeaders), hostname, true, secure)
 	if err != nil {
 		return job.Error(err)
 	}
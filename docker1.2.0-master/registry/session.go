@@ -14,8 +14,10 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/archive"
 	"github.com/docker/docker/pkg/httputils"
 	"github.com/docker/docker/pkg/log"
 	"github.com/docker/docker/pkg/tarsum"
@@ -28,12 +30,20 @@ type Session struct {
 	indexEndpoint string
 	jar           *cookiejar.Jar
 	timeout       TimeoutType
+	secure        bool
+
+	// v2 sessions authenticate as challenged by the registry, with
+	// tokens cached by scope
+	v2Challenge *authChallenge
+	v2Tokens    map[string]string
+	v2Lock      sync.Mutex
 }
 
-func NewSession(authConfig *AuthConfig, factory *utils.HTTPRequestFactory, indexEndpoint string, timeout bool) (r *Session, err error) {
+func NewSession(authConfig *AuthConfig, factory *utils.HTTPRequestFactory, indexEndpoint string, timeout, secure bool) (r *Session, err error) {
 	r = &Session{
 		authConfig:    authConfig,
 		indexEndpoint: indexEndpoint,
+		secure:        secure,
 	}
 
 	if timeout {
@@ -48,7 +58,7 @@ func NewSession(authConfig *AuthConfig, factory *utils.HTTPRequestFactory, index
 	// If we're working with a standalone private registry over HTTPS, send Basic Auth headers
 	// alongside our requests.
 	if indexEndpoint != IndexServerAddress() && strings.HasPrefix(indexEndpoint, "https://") {
-		info, err := pingRegistryEndpoint(indexEndpoint)
+		info, err := pingRegistryEndpoint(indexEndpoint, secure)
 		if err != nil {
 			return nil, err
 		}
@@ -64,7 +74,7 @@ func NewSession(authConfig *AuthConfig, factory *utils.HTTPRequestFactory, index
 }
 
 func (r *Session) doRequest(req *http.Request) (*http.Response, *http.Client, error) {
-	return doRequest(req, r.jar, r.timeout)
+	return doRequest(req, r.jar, r.timeout, r.secure)
 }
 
 // Retrieve the history of a given image from the Registry.
@@ -151,7 +161,9 @@ func (r *Session) GetRemoteImageJSON(imgID, registry string, token []string) ([]
 	return jsonString, imageSize, nil
 }
 
-func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, imgSize int64) (io.ReadCloser, error) {
+// GetRemoteImageLayer returns a reader of the layer of imgID, starting at
+// offset to resume a previous download.
+func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, imgSize, offset int64) (io.ReadCloser, error) {
 	var (
 		retries  = 5
 		client   *http.Client
@@ -164,6 +176,9 @@ func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, im
 		return nil, fmt.Errorf("Error while getting from the server: %s\n", err)
 	}
 	setTokenAuth(req, token)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 	for i := 1; i <= retries; i++ {
 		res, client, err = r.doRequest(req)
 		if err != nil {
@@ -178,13 +193,22 @@ func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, im
 		break
 	}
 
-	if res.StatusCode != 200 {
+	if offset > 0 && res.StatusCode == 416 {
+		// Nothing left to download
+		res.Body.Close()
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if res.StatusCode != 200 && res.StatusCode != 206 {
 		res.Body.Close()
 		return nil, fmt.Errorf("Server error: Status %d while fetching image layer (%s)",
 			res.StatusCode, imgID)
 	}
+	if err := skipToOffset(res, offset); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
 
-	if res.Header.Get("Accept-Ranges") == "bytes" && imgSize > 0 {
+	if res.Header.Get("Accept-Ranges") == "bytes" && imgSize > 0 && offset == 0 {
 		log.Debugf("server supports resume")
 		return httputils.ResumableRequestReaderWithInitialResponse(client, req, 5, imgSize, res), nil
 	}
@@ -192,6 +216,17 @@ func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, im
 	return res.Body, nil
 }
 
+// skipToOffset makes the body of res start at offset, discarding its first
+// bytes when the server ignored the range requested.
+func skipToOffset(res *http.Response, offset int64) error {
+	if offset == 0 || res.StatusCode == 206 {
+		return nil
+	}
+	log.Debugf("server ignored the range, skipping %d bytes", offset)
+	_, err := io.CopyN(ioutil.Discard, res.Body, offset)
+	return err
+}
+
 func (r *Session) GetRemoteTags(registries []string, repository string, token []string) (map[string]string, error) {
 	if strings.Count(repository, "/") == 0 {
 		// This will be removed once the Registry supports auto-resolution on
@@ -395,11 +430,17 @@ func (r *Session) PushImageJSONRegistry(imgData *ImgData, jsonRaw []byte, regist
 	return nil
 }
 
-func (r *Session) PushImageLayerRegistry(imgID string, layer io.Reader, registry string, token []string, jsonRaw []byte) (checksum string, checksumPayload string, err error) {
+// PushImageLayerRegistry uploads the uncompressed layer of imgID,
+// compressing it with compression at level, 0 being the default level.
+func (r *Session) PushImageLayerRegistry(imgID string, layer io.Reader, registry string, token []string, jsonRaw []byte, compression archive.Compression, level int) (checksum string, checksumPayload string, err error) {
 
 	log.Debugf("[registry] Calling PUT %s", registry+"images/"+imgID+"/layer")
 
-	tarsumLayer := &tarsum.TarSum{Reader: layer}
+	tarsumLayer := &tarsum.TarSum{
+		Reader:             layer,
+		DisableCompression: compression == archive.Uncompressed,
+		CompressionLevel:   level,
+	}
 	h := sha256.New()
 	h.Write(jsonRaw)
 	h.Write([]byte{'\n'})
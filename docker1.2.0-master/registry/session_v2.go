@@ -0,0 +1,472 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/httputils"
+	"github.com/docker/docker/pkg/log"
+	"github.com/docker/docker/utils"
+)
+
+// The v2 protocol of the Docker Hub is served by a registry distinct from
+// its index.
+const INDEXSERVERV2 = "https://registry-1.docker.io/v2/"
+
+// Blobs are uploaded by chunks of this size, each chunk being retried on
+// its own.
+const v2ChunkSize = 5 * 1024 * 1024
+
+var (
+	ErrDoesNotExist     = errors.New("Image does not exist")
+	errV2NotSupported   = errors.New("Registry doesn't support the v2 protocol")
+	errBlobUploadFailed = errors.New("Blob upload failed")
+)
+
+// NewV2Session expands hostname to the endpoint of its v2 registry, falling
+// back to http for insecure registries like ExpandAndVerifyRegistryUrl, and
+// returns a session with it. An error is returned if the registry doesn't
+// speak the v2 protocol.
+func NewV2Session(authConfig *AuthConfig, factory *utils.HTTPRequestFactory, hostname string, timeout, secure bool) (*Session, error) {
+	var endpoints []string
+	switch {
+	case hostname == IndexServerAddress():
+		endpoints = []string{INDEXSERVERV2}
+	case strings.HasPrefix(hostname, "http:") || strings.HasPrefix(hostname, "https:"):
+		u, err := url.Parse(hostname)
+		if err != nil {
+			return nil, err
+		}
+		if secure && u.Scheme == "http" {
+			return nil, fmt.Errorf("%s is not an insecure registry", hostname)
+		}
+		endpoints = []string{fmt.Sprintf("%s://%s/v2/", u.Scheme, u.Host)}
+	default:
+		endpoints = []string{fmt.Sprintf("https://%s/v2/", hostname)}
+		if !secure {
+			endpoints = append(endpoints, fmt.Sprintf("http://%s/v2/", hostname))
+		}
+	}
+
+	var (
+		endpoint  string
+		challenge *authChallenge
+		err       error
+	)
+	for _, endpoint = range endpoints {
+		if challenge, err = pingRegistryEndpointV2(endpoint, secure); err == nil {
+			break
+		}
+		log.Debugf("Registry %s does not support v2 (%s)", endpoint, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Session{
+		authConfig:    authConfig,
+		reqFactory:    factory,
+		indexEndpoint: endpoint,
+		secure:        secure,
+		v2Challenge:   challenge,
+		v2Tokens:      make(map[string]string),
+	}
+	if timeout {
+		r.timeout = ReceiveTimeout
+	}
+	if r.jar, err = cookiejar.New(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// pingRegistryEndpointV2 checks endpoint speaks the v2 protocol and returns
+// the auth challenge of the registry, if any.
+func pingRegistryEndpointV2(endpoint string, secure bool) (*authChallenge, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := doRequest(req, nil, ConnectTimeout, secure)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	supported := false
+	for _, versions := range resp.Header[http.CanonicalHeaderKey("Docker-Distribution-API-Version")] {
+		for _, version := range strings.Fields(versions) {
+			if version == "registry/2.0" {
+				supported = true
+			}
+		}
+	}
+	if !supported {
+		return nil, errV2NotSupported
+	}
+
+	switch resp.StatusCode {
+	case 200:
+		return nil, nil
+	case 401:
+		return parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+	}
+	return nil, utils.NewHTTPRequestError(fmt.Sprintf("HTTP code %d while pinging %s", resp.StatusCode, endpoint), resp)
+}
+
+// Endpoint returns the registry endpoint of the session.
+func (r *Session) Endpoint() string {
+	return r.indexEndpoint
+}
+
+func (r *Session) newV2Request(method, path string, body io.Reader, name string, actions ...string) (*http.Request, error) {
+	u := path
+	if !strings.Contains(path, "://") {
+		u = r.indexEndpoint + path
+	}
+	req, err := r.reqFactory.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.setV2Auth(req, name, actions...); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetV2RemoteTags lists the tags of the repository name.
+func (r *Session) GetV2RemoteTags(name string) ([]string, error) {
+	req, err := r.newV2Request("GET", name+"/tags/list", nil, name, "pull")
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case 200:
+	case 401:
+		return nil, errLoginRequired
+	case 404:
+		return nil, ErrDoesNotExist
+	default:
+		return nil, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch the tags of %s", res.StatusCode, name), res)
+	}
+
+	var tagList struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tagList); err != nil {
+		return nil, err
+	}
+	return tagList.Tags, nil
+}
+
+// GetV2ImageManifest retrieves the manifest of the image tagged tag in the
+// repository name.
+func (r *Session) GetV2ImageManifest(name, tag string) (*ManifestData, error) {
+	req, err := r.newV2Request("GET", name+"/manifests/"+tag, nil, name, "pull")
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case 200:
+	case 401:
+		return nil, errLoginRequired
+	case 404:
+		return nil, ErrDoesNotExist
+	default:
+		return nil, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch the manifest of %s:%s", res.StatusCode, name, tag), res)
+	}
+
+	manifest := &ManifestData{}
+	if err := json.NewDecoder(res.Body).Decode(manifest); err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion != 1 {
+		return nil, fmt.Errorf("Unsupported manifest schema version %d for %s:%s", manifest.SchemaVersion, name, tag)
+	}
+	if len(manifest.FSLayers) != len(manifest.History) {
+		return nil, fmt.Errorf("Invalid manifest for %s:%s: %d layers for %d history entries", name, tag, len(manifest.FSLayers), len(manifest.History))
+	}
+	return manifest, nil
+}
+
+// PutV2ImageManifest tags an image in the repository name with its manifest.
+func (r *Session) PutV2ImageManifest(name, tag string, manifest *ManifestData) error {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "   ")
+	if err != nil {
+		return err
+	}
+	req, err := r.newV2Request("PUT", name+"/manifests/"+tag, bytes.NewReader(manifestJSON), name, "push", "pull")
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = int64(len(manifestJSON))
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 401 {
+		return errLoginRequired
+	}
+	if res.StatusCode != 201 && res.StatusCode != 202 {
+		errBody, _ := ioutil.ReadAll(res.Body)
+		return utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to push the manifest of %s:%s: %s", res.StatusCode, name, tag, errBody), res)
+	}
+	return nil
+}
+
+// HeadV2ImageBlob checks if the blob sum exists in the repository name.
+func (r *Session) HeadV2ImageBlob(name, sum string) (bool, error) {
+	req, err := r.newV2Request("HEAD", name+"/blobs/"+sum, nil, name, "pull")
+	if err != nil {
+		return false, err
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	switch res.StatusCode {
+	case 200:
+		return true, nil
+	case 404:
+		return false, nil
+	case 401:
+		return false, errLoginRequired
+	}
+	return false, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to check blob %s of %s", res.StatusCode, sum, name), res)
+}
+
+// GetV2ImageBlobReader returns a reader of the blob sum of the repository
+// name starting at offset, resuming the download on errors when the
+// registry supports it, and the size of the whole blob.
+func (r *Session) GetV2ImageBlobReader(name, sum string, offset int64) (io.ReadCloser, int64, error) {
+	req, err := r.newV2Request("GET", name+"/blobs/"+sum, nil, name, "pull")
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, client, err := r.doRequest(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 && res.StatusCode == 416 {
+		// Nothing left to download
+		res.Body.Close()
+		return ioutil.NopCloser(bytes.NewReader(nil)), offset, nil
+	}
+	if res.StatusCode != 200 && res.StatusCode != 206 {
+		res.Body.Close()
+		if res.StatusCode == 401 {
+			return nil, 0, errLoginRequired
+		}
+		return nil, 0, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch blob %s of %s", res.StatusCode, sum, name), res)
+	}
+
+	size := res.ContentLength
+	if res.StatusCode == 206 && size >= 0 {
+		size += offset
+	}
+	if err := skipToOffset(res, offset); err != nil {
+		res.Body.Close()
+		return nil, 0, err
+	}
+	if res.Header.Get("Accept-Ranges") == "bytes" && size > 0 && offset == 0 {
+		log.Debugf("server supports resume")
+		return httputils.ResumableRequestReaderWithInitialResponse(client, req, 5, size, res), size, nil
+	}
+	log.Debugf("server doesn't support resume")
+	return res.Body, size, nil
+}
+
+// PutV2ImageBlob uploads blob to the repository name by chunks, each chunk
+// being resumed from the offset the registry got on errors, then commits it
+// as sum.
+func (r *Session) PutV2ImageBlob(name, sum string, blob io.Reader) error {
+	req, err := r.newV2Request("POST", name+"/blobs/uploads/", nil, name, "push", "pull")
+	if err != nil {
+		return err
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == 401 {
+		return errLoginRequired
+	}
+	if res.StatusCode != 202 {
+		return utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to start the upload of blob %s to %s", res.StatusCode, sum, name), res)
+	}
+	location, err := r.resolveLocation(req, res)
+	if err != nil {
+		return err
+	}
+
+	var (
+		chunk  = make([]byte, v2ChunkSize)
+		offset int64
+	)
+	for {
+		n, err := io.ReadFull(blob, chunk)
+		if n > 0 {
+			if location, err = r.patchV2Chunk(name, location, chunk[:n], offset); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("digest", sum)
+	u.RawQuery = q.Encode()
+	req, err = r.newV2Request("PUT", u.String(), nil, name, "push", "pull")
+	if err != nil {
+		return err
+	}
+	req.ContentLength = 0
+	res, _, err = r.doRequest(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != 201 {
+		return utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to commit blob %s to %s", res.StatusCode, sum, name), res)
+	}
+	return nil
+}
+
+// patchV2Chunk sends chunk, which starts at offset in the blob, to the
+// upload at location and returns the location of the next chunk.
+func (r *Session) patchV2Chunk(name, location string, chunk []byte, offset int64) (string, error) {
+	var (
+		retries = 5
+		sent    int64
+		lastErr error
+	)
+	for i := 1; i <= retries; i++ {
+		data := chunk[sent:]
+		req, err := r.newV2Request("PATCH", location, bytes.NewReader(data), name, "push", "pull")
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset+sent, offset+int64(len(chunk))-1))
+		req.ContentLength = int64(len(data))
+		res, _, err := r.doRequest(req)
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == 202 {
+				return r.resolveLocation(req, res)
+			}
+			if res.StatusCode == 401 {
+				return "", errLoginRequired
+			}
+			lastErr = utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d uploading a chunk to %s", res.StatusCode, name), res)
+		} else {
+			lastErr = err
+		}
+		log.Debugf("Error uploading a chunk of %s (retry %d): %s", name, i, lastErr)
+		time.Sleep(time.Duration(i) * 500 * time.Millisecond)
+
+		// Resume from what the registry got
+		received, err := r.getV2UploadOffset(name, location)
+		if err != nil {
+			return "", err
+		}
+		if received < offset || received > offset+int64(len(chunk)) {
+			return "", errBlobUploadFailed
+		}
+		sent = received - offset
+		if sent == int64(len(chunk)) {
+			return location, nil
+		}
+	}
+	return "", lastErr
+}
+
+// getV2UploadOffset returns how many bytes of the upload at location the
+// registry received.
+func (r *Session) getV2UploadOffset(name, location string) (int64, error) {
+	req, err := r.newV2Request("GET", location, nil, name, "push", "pull")
+	if err != nil {
+		return 0, err
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	if res.StatusCode != 204 {
+		return 0, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d checking an upload to %s", res.StatusCode, name), res)
+	}
+	// The range is inclusive, "0-0" being returned before any data
+	rng := res.Header.Get("Range")
+	parts := strings.SplitN(rng, "-", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("Invalid upload range %q", rng)
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid upload range %q", rng)
+	}
+	if end == 0 {
+		return 0, nil
+	}
+	return end + 1, nil
+}
+
+// resolveLocation returns the absolute Location of res, answering req.
+func (r *Session) resolveLocation(req *http.Request, res *http.Response) (string, error) {
+	location := res.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("Missing Location header in the response to %s %s", req.Method, req.URL)
+	}
+	u, err := req.URL.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// NormalizeV2Name returns the v2 name of a Docker Hub repository, where
+// official images live in the library namespace.
+func NormalizeV2Name(name string) string {
+	if !strings.Contains(name, "/") {
+		return "library/" + name
+	}
+	return name
+}
@@ -0,0 +1,282 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/utils"
+)
+
+// mockV2Registry serves blobs and manifests of a single repository, behind
+// token auth, and fails the first PATCH of each upload half way.
+type mockV2Registry struct {
+	sync.Mutex
+	server    *httptest.Server
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   map[string][]byte
+	failed    map[string]bool
+}
+
+func newMockV2Registry() *mockV2Registry {
+	m := &mockV2Registry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		uploads:   make(map[string][]byte),
+		failed:    make(map[string]bool),
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+func (m *mockV2Registry) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	if r.URL.Path == "/token" {
+		json.NewEncoder(w).Encode(map[string]string{"token": "token-" + r.URL.Query().Get("scope")})
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer token-repository:foo42/bar:") {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="mock"`, m.server.URL))
+		w.WriteHeader(401)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/foo42/bar")
+	switch {
+	case r.URL.Path == "/v2/":
+		w.WriteHeader(200)
+	case path == "/tags/list":
+		var tags []string
+		for tag := range m.manifests {
+			tags = append(tags, tag)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": "foo42/bar", "tags": tags})
+	case strings.HasPrefix(path, "/manifests/"):
+		tag := strings.TrimPrefix(path, "/manifests/")
+		if r.Method == "PUT" {
+			m.manifests[tag], _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(201)
+		} else if manifest, exists := m.manifests[tag]; exists {
+			w.Write(manifest)
+		} else {
+			w.WriteHeader(404)
+		}
+	case strings.HasPrefix(path, "/blobs/uploads/"):
+		id := strings.TrimPrefix(path, "/blobs/uploads/")
+		switch r.Method {
+		case "POST":
+			id = utils.GenerateRandomID()
+			w.Header().Set("Location", "/v2/foo42/bar/blobs/uploads/"+id)
+			w.WriteHeader(202)
+		case "PATCH":
+			data, _ := ioutil.ReadAll(r.Body)
+			if !m.failed[id] {
+				// Only keep half of the first chunk
+				m.failed[id] = true
+				m.uploads[id] = append(m.uploads[id], data[:len(data)/2]...)
+				w.WriteHeader(500)
+				return
+			}
+			m.uploads[id] = append(m.uploads[id], data...)
+			w.Header().Set("Location", "/v2/foo42/bar/blobs/uploads/"+id)
+			w.WriteHeader(202)
+		case "GET":
+			end := len(m.uploads[id]) - 1
+			if end < 0 {
+				end = 0
+			}
+			w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+			w.WriteHeader(204)
+		case "PUT":
+			m.blobs[r.URL.Query().Get("digest")] = m.uploads[id]
+			w.WriteHeader(201)
+		}
+	case strings.HasPrefix(path, "/blobs/"):
+		blob, exists := m.blobs[strings.TrimPrefix(path, "/blobs/")]
+		if !exists {
+			w.WriteHeader(404)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	challenge, err := parseAuthChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:foo:pull,push"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, challenge.Scheme, "bearer", "Unexpected scheme")
+	assertEqual(t, challenge.Parameters["realm"], "https://auth.example.com/token", "Unexpected realm")
+	assertEqual(t, challenge.Parameters["service"], "registry.example.com", "Unexpected service")
+	assertEqual(t, challenge.Parameters["scope"], "repository:foo:pull,push", "Unexpected scope")
+
+	if challenge, err = parseAuthChallenge(`Basic realm=registry`); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, challenge.Scheme, "basic", "Unexpected scheme")
+	assertEqual(t, challenge.Parameters["realm"], "registry", "Unexpected realm")
+
+	if _, err := parseAuthChallenge(`Bearer realm="unterminated`); err == nil {
+		t.Fatal("Expected an error parsing an unterminated challenge")
+	}
+}
+
+func TestGetV2TokenRealm(t *testing.T) {
+	var called bool
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		json.NewEncoder(w).Encode(map[string]string{"token": "token"})
+	}))
+	defer auth.Close()
+
+	newSession := func(secure bool) *Session {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Session{
+			authConfig:    &AuthConfig{Username: "foo", Password: "bar"},
+			reqFactory:    utils.NewHTTPRequestFactory(),
+			indexEndpoint: "https://registry.example.com/v2/",
+			jar:           jar,
+			secure:        secure,
+			v2Challenge:   &authChallenge{Scheme: "bearer", Parameters: map[string]string{"realm": auth.URL + "/token"}},
+		}
+	}
+	if _, err := newSession(true).getV2Token("repository:foo42/bar:pull"); err == nil {
+		t.Fatal("Expected an error getting a token from an http realm for a secure registry")
+	}
+	if called {
+		t.Fatal("Expected the credentials not to be sent to an http realm for a secure registry")
+	}
+
+	token, err := newSession(false).getV2Token("repository:foo42/bar:pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, token, "token", "Unexpected token from the realm of an insecure registry")
+
+	s := newSession(false)
+	s.v2Challenge.Parameters["realm"] = "ftp://auth.example.com/token"
+	if _, err := s.getV2Token("repository:foo42/bar:pull"); err == nil {
+		t.Fatal("Expected an error getting a token from an ftp realm")
+	}
+}
+
+func TestV2PushPull(t *testing.T) {
+	m := newMockV2Registry()
+	defer m.server.Close()
+
+	r, err := NewV2Session(&AuthConfig{}, utils.NewHTTPRequestFactory(), m.server.URL, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, r.Endpoint(), m.server.URL+"/v2/", "Unexpected endpoint")
+
+	blob := bytes.Repeat([]byte("layer"), v2ChunkSize/4)
+	h := sha256.Sum256(blob)
+	sum := "sha256:" + hex.EncodeToString(h[:])
+
+	if exists, err := r.HeadV2ImageBlob("foo42/bar", sum); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatal("Expected blob not to exist")
+	}
+	if err := r.PutV2ImageBlob("foo42/bar", sum, bytes.NewReader(blob)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.blobs[sum], blob) {
+		t.Fatalf("Expected the upload to resume, got %d bytes out of %d", len(m.blobs[sum]), len(blob))
+	}
+
+	manifest := &ManifestData{
+		Name:          "foo42/bar",
+		Tag:           "latest",
+		FSLayers:      []*FSLayer{{BlobSum: sum}},
+		History:       []*ManifestHistory{{V1Compatibility: `{"id":"` + IMAGE_ID + `"}`}},
+		SchemaVersion: 1,
+	}
+	if err := r.PutV2ImageManifest("foo42/bar", "latest", manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := r.GetV2RemoteTags("foo42/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(tags), 1, "Expected 1 tag")
+	assertEqual(t, tags[0], "latest", "Expected tag latest")
+
+	pulled, err := r.GetV2ImageManifest("foo42/bar", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, pulled.FSLayers[0].BlobSum, sum, "Unexpected blob sum")
+	if _, err := r.GetV2ImageManifest("foo42/bar", "missing"); err != ErrDoesNotExist {
+		t.Fatalf("Expected ErrDoesNotExist, got %v", err)
+	}
+
+	reader, size, err := r.GetV2ImageBlobReader("foo42/bar", sum, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, size, int64(len(blob)), "Unexpected blob size")
+	if !bytes.Equal(data, blob) {
+		t.Fatal("Pulled blob doesn't match the pushed one")
+	}
+
+	// Resume the download
+	offset := int64(len(blob) / 3)
+	rest, size, err := r.GetV2ImageBlobReader("foo42/bar", sum, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rest.Close()
+	if data, err = ioutil.ReadAll(rest); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, size, int64(len(blob)), "Unexpected blob size when resuming")
+	if !bytes.Equal(data, blob[offset:]) {
+		t.Fatal("Resumed blob doesn't match the end of the pushed one")
+	}
+
+	// Nothing is left past the end of the blob
+	rest, _, err = r.GetV2ImageBlobReader("foo42/bar", sum, int64(len(blob)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rest.Close()
+	if data, err = ioutil.ReadAll(rest); err != nil {
+		t.Fatal(err)
+	} else if len(data) != 0 {
+		t.Fatalf("Expected nothing left to download, got %d bytes", len(data))
+	}
+}
+
+func TestNewV2SessionV1Only(t *testing.T) {
+	if _, err := NewV2Session(&AuthConfig{}, utils.NewHTTPRequestFactory(), makeURL("/v1/"), true, false); err == nil {
+		t.Fatal("Expected an error creating a v2 session with a v1 registry")
+	}
+}
@@ -22,7 +22,8 @@ func Compare(a, b *Config) bool {
 		len(a.PortSpecs) != len(b.PortSpecs) ||
 		len(a.ExposedPorts) != len(b.ExposedPorts) ||
 		len(a.Entrypoint) != len(b.Entrypoint) ||
-		len(a.Volumes) != len(b.Volumes) {
+		len(a.Volumes) != len(b.Volumes) ||
+		len(a.Labels) != len(b.Labels) {
 		return false
 	}
 
@@ -56,5 +57,24 @@ func Compare(a, b *Config) bool {
 			return false
 		}
 	}
+	for key, value := range a.Labels {
+		if v, exists := b.Labels[key]; !exists || v != value {
+			return false
+		}
+	}
+	if (a.Healthcheck == nil) != (b.Healthcheck == nil) {
+		return false
+	}
+	if a.Healthcheck != nil {
+		ha, hb := a.Healthcheck, b.Healthcheck
+		if ha.Interval != hb.Interval || ha.Timeout != hb.Timeout || ha.Retries != hb.Retries || len(ha.Test) != len(hb.Test) {
+			return false
+		}
+		for i := 0; i < len(ha.Test); i++ {
+			if ha.Test[i] != hb.Test[i] {
+				return false
+			}
+		}
+	}
 	return true
 }
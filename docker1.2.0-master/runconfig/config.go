@@ -1,6 +1,8 @@
 package runconfig
 
 import (
+	"time"
+
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/nat"
 )
@@ -32,6 +34,24 @@ type Config struct {
 	Entrypoint      []string
 	NetworkDisabled bool
 	OnBuild         []string
+	Labels          map[string]string
+	Healthcheck     *HealthConfig // Check of the health of the running container
+}
+
+// HealthConfig is the command checking that a container works, run in the
+// container every Interval. It is unhealthy after Retries consecutive
+// failures, a failure being a non-zero exit code or taking over Timeout.
+type HealthConfig struct {
+	// Test is the command, ["NONE"] disabling the check of the image
+	Test     []string
+	Interval time.Duration
+	Timeout  time.Duration
+	Retries  int
+}
+
+// Disabled returns whether the check is unset or disabled.
+func (h *HealthConfig) Disabled() bool {
+	return h == nil || len(h.Test) == 0 || (len(h.Test) == 1 && h.Test[0] == "NONE")
 }
 
 func ContainerConfigFromJob(job *engine.Job) *Config {
@@ -55,6 +75,8 @@ func ContainerConfigFromJob(job *engine.Job) *Config {
 	}
 	job.GetenvJson("ExposedPorts", &config.ExposedPorts)
 	job.GetenvJson("Volumes", &config.Volumes)
+	job.GetenvJson("Labels", &config.Labels)
+	job.GetenvJson("Healthcheck", &config.Healthcheck)
 	if PortSpecs := job.GetenvList("PortSpecs"); PortSpecs != nil {
 		config.PortSpecs = PortSpecs
 	}
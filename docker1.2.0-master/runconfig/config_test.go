@@ -190,6 +190,11 @@ func TestCompare(t *testing.T) {
 	if !Compare(&config1, &config1) {
 		t.Fatalf("Compare should return true")
 	}
+	config6 := config1
+	config6.Healthcheck = &HealthConfig{Test: []string{"/bin/sh", "-c", "true"}, Retries: 3}
+	if Compare(&config1, &config6) {
+		t.Fatalf("Compare should return false, Healthcheck is different")
+	}
 }
 
 func TestMerge(t *testing.T) {
@@ -200,6 +205,7 @@ func TestMerge(t *testing.T) {
 		PortSpecs: []string{"1111:1111", "2222:2222"},
 		Env:       []string{"VAR1=1", "VAR2=2"},
 		Volumes:   volumesImage,
+		Labels:    map[string]string{"rack": "r1", "role": "db"},
 	}
 
 	volumesUser := make(map[string]struct{})
@@ -208,6 +214,7 @@ func TestMerge(t *testing.T) {
 		PortSpecs: []string{"3333:2222", "3333:3333"},
 		Env:       []string{"VAR2=3", "VAR3=3"},
 		Volumes:   volumesUser,
+		Labels:    map[string]string{"role": "web"},
 	}
 
 	if err := Merge(configUser, configImage); err != nil {
@@ -240,6 +247,13 @@ func TestMerge(t *testing.T) {
 		}
 	}
 
+	if len(configUser.Labels) != 2 {
+		t.Fatalf("Expected 2 labels, rack=r1 and role=web, found %d", len(configUser.Labels))
+	}
+	if configUser.Labels["rack"] != "r1" || configUser.Labels["role"] != "web" {
+		t.Fatalf("Expected rack=r1 and role=web, found %v", configUser.Labels)
+	}
+
 	ports, _, err := nat.ParsePortSpecs([]string{"0000"})
 	if err != nil {
 		t.Error(err)
@@ -253,11 +267,11 @@ func TestMerge(t *testing.T) {
 	}
 
 	if len(configUser.ExposedPorts) != 4 {
-		t.Fatalf("Expected 4 ExposedPorts, 0000, 1111, 2222 and 3333, found %d", len(configUser.ExposedPorts))
+		t.Fatalf("Expected 4 ExposedPorts, 0, 1111, 2222 and 3333, found %d", len(configUser.ExposedPorts))
 	}
 	for portSpecs := range configUser.ExposedPorts {
-		if portSpecs.Port() != "0000" && portSpecs.Port() != "1111" && portSpecs.Port() != "2222" && portSpecs.Port() != "3333" {
-			t.Fatalf("Expected 0000 or 1111 or 2222 or 3333, found %s", portSpecs)
+		if portSpecs.Port() != "0" && portSpecs.Port() != "1111" && portSpecs.Port() != "2222" && portSpecs.Port() != "3333" {
+			t.Fatalf("Expected 0 or 1111 or 2222 or 3333, found %s", portSpecs)
 		}
 	}
 
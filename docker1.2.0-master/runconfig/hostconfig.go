@@ -5,6 +5,7 @@ import (
 
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/nat"
+	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/utils"
 )
 
@@ -30,27 +31,46 @@ type RestartPolicy struct {
 	MaximumRetryCount int
 }
 
+type LogConfig struct {
+	Type    string
+	Options map[string]string
+}
+
 type HostConfig struct {
-	Binds           []string
-	ContainerIDFile string
-	LxcConf         []utils.KeyValuePair
-	Privileged      bool
-	PortBindings    nat.PortMap
-	Links           []string
-	PublishAllPorts bool
-	Dns             []string
-	DnsSearch       []string
-	VolumesFrom     []string
-	Devices         []DeviceMapping
-	NetworkMode     NetworkMode
-	CapAdd          []string
-	CapDrop         []string
-	RestartPolicy   RestartPolicy
+	Binds             []string
+	ContainerIDFile   string
+	LxcConf           []utils.KeyValuePair
+	LxcTemplate       string // Path of an lxc template replacing the builtin one, on the daemon host
+	LxcFragment       string // lxc template appended to the config of the container
+	Privileged        bool
+	PortBindings      nat.PortMap
+	Links             []string
+	PublishAllPorts   bool
+	Dns               []string
+	DnsSearch         []string
+	VolumesFrom       []string
+	Devices           []DeviceMapping
+	DeviceCgroupRules []string // Entries added to the device cgroup, e.g. c 189:* rwm
+	NetworkMode       NetworkMode
+	CapAdd            []string
+	CapDrop           []string
+	RestartPolicy     RestartPolicy
+	LogConfig         LogConfig
+	MountOptions      map[string][]string // Mount options of the volumes, by path in the container
+	Ulimits           []*ulimit.Ulimit    // Resource limits of the processes of the container
+	Gpus              string              // GPUs of the host given to the container: all or their numbers, e.g. 0,1
+	SeccompProfile    string              // Path of the JSON profile of the syscalls allowed in the container, on the daemon host
+	SeccompAudit      bool                // Only log the syscalls not allowed by the seccomp profile
 }
 
 func ContainerHostConfigFromJob(job *engine.Job) *HostConfig {
 	hostConfig := &HostConfig{
 		ContainerIDFile: job.Getenv("ContainerIDFile"),
+		LxcTemplate:     job.Getenv("LxcTemplate"),
+		LxcFragment:     job.Getenv("LxcFragment"),
+		Gpus:            job.Getenv("Gpus"),
+		SeccompProfile:  job.Getenv("SeccompProfile"),
+		SeccompAudit:    job.GetenvBool("SeccompAudit"),
 		Privileged:      job.GetenvBool("Privileged"),
 		PublishAllPorts: job.GetenvBool("PublishAllPorts"),
 		NetworkMode:     NetworkMode(job.Getenv("NetworkMode")),
@@ -60,6 +80,9 @@ func ContainerHostConfigFromJob(job *engine.Job) *HostConfig {
 	job.GetenvJson("PortBindings", &hostConfig.PortBindings)
 	job.GetenvJson("Devices", &hostConfig.Devices)
 	job.GetenvJson("RestartPolicy", &hostConfig.RestartPolicy)
+	job.GetenvJson("LogConfig", &hostConfig.LogConfig)
+	job.GetenvJson("MountOptions", &hostConfig.MountOptions)
+	job.GetenvJson("Ulimits", &hostConfig.Ulimits)
 	if Binds := job.GetenvList("Binds"); Binds != nil {
 		hostConfig.Binds = Binds
 	}
@@ -75,6 +98,9 @@ func ContainerHostConfigFromJob(job *engine.Job) *HostConfig {
 	if VolumesFrom := job.GetenvList("VolumesFrom"); VolumesFrom != nil {
 		hostConfig.VolumesFrom = VolumesFrom
 	}
+	if DeviceCgroupRules := job.GetenvList("DeviceCgroupRules"); DeviceCgroupRules != nil {
+		hostConfig.DeviceCgroupRules = DeviceCgroupRules
+	}
 	if CapAdd := job.GetenvList("CapAdd"); CapAdd != nil {
 		hostConfig.CapAdd = CapAdd
 	}
@@ -100,5 +100,17 @@ func Merge(userConf, imageConf *Config) error {
 			userConf.Volumes[k] = v
 		}
 	}
+	if len(userConf.Labels) == 0 {
+		userConf.Labels = imageConf.Labels
+	} else {
+		for k, v := range imageConf.Labels {
+			if _, exists := userConf.Labels[k]; !exists {
+				userConf.Labels[k] = v
+			}
+		}
+	}
+	if userConf.Healthcheck == nil {
+		userConf.Healthcheck = imageConf.Healthcheck
+	}
 	return nil
 }
@@ -12,6 +12,7 @@ import (
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/sysinfo"
+	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/pkg/units"
 	"github.com/docker/docker/utils"
 )
@@ -47,6 +48,8 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 		flEnv     = opts.NewListOpts(opts.ValidateEnv)
 		flDevices = opts.NewListOpts(opts.ValidatePath)
 
+		flDeviceCgroupRules = opts.NewListOpts(opts.ValidateDeviceCgroupRule)
+
 		flPublish     = opts.NewListOpts(nil)
 		flExpose      = opts.NewListOpts(nil)
 		flDns         = opts.NewListOpts(opts.ValidateIPAddress)
@@ -56,6 +59,9 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 		flEnvFile     = opts.NewListOpts(nil)
 		flCapAdd      = opts.NewListOpts(nil)
 		flCapDrop     = opts.NewListOpts(nil)
+		flLogOpts     = opts.NewListOpts(nil)
+		flMountOpts   = opts.NewListOpts(nil)
+		flUlimits     = opts.NewListOpts(nil)
 
 		flAutoRemove      = cmd.Bool([]string{"#rm", "-rm"}, false, "Automatically remove the container when it exits (incompatible with -d)")
 		flDetach          = cmd.Bool([]string{"d", "-detach"}, false, "Detached mode: run container in the background and print new container ID")
@@ -74,6 +80,12 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 		flCpuset          = cmd.String([]string{"-cpuset"}, "", "CPUs in which to allow execution (0-3, 0,1)")
 		flNetMode         = cmd.String([]string{"-net"}, "bridge", "Set the Network mode for the container\n'bridge': creates a new network stack for the container on the docker bridge\n'none': no networking for this container\n'container:<name|id>': reuses another container network stack\n'host': use the host network stack inside the container.  Note: the host mode gives the container full access to local system services such as D-bus and is therefore considered insecure.")
 		flRestartPolicy   = cmd.String([]string{"-restart"}, "", "Restart policy to apply when a container exits (no, on-failure, always)")
+		flLogDriver       = cmd.String([]string{"-log-driver"}, "", "Logging driver for the container (defaults to the daemon's --log-driver)")
+		flLxcTemplate     = cmd.String([]string{"-lxc-template"}, "", "(lxc exec-driver only) Path on the daemon host of an lxc template replacing the builtin one")
+		flLxcFragment     = cmd.String([]string{"-lxc-fragment"}, "", "(lxc exec-driver only) lxc template appended to the config of the container")
+		flGpus            = cmd.String([]string{"-gpus"}, "", "GPUs of the host to give to the container: all or their numbers (e.g. 0,1)")
+		flSeccompProfile  = cmd.String([]string{"-seccomp-profile"}, "", "(native exec-driver only) Path on the daemon host of the JSON profile of the syscalls allowed in the container")
+		flSeccompAudit    = cmd.Bool([]string{"-seccomp-audit"}, false, "(native exec-driver only) Log the syscalls not allowed by the seccomp profile as events rather than denying them")
 		// For documentation purpose
 		_ = cmd.Bool([]string{"#sig-proxy", "-sig-proxy"}, true, "Proxy received signals to the process (even in non-TTY mode). SIGCHLD, SIGSTOP, and SIGKILL are not proxied.")
 		_ = cmd.String([]string{"#name", "-name"}, "", "Assign a name to the container")
@@ -83,6 +95,7 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 	cmd.Var(&flVolumes, []string{"v", "-volume"}, "Bind mount a volume (e.g., from the host: -v /host:/container, from Docker: -v /container)")
 	cmd.Var(&flLinks, []string{"#link", "-link"}, "Add link to another container in the form of name:alias")
 	cmd.Var(&flDevices, []string{"-device"}, "Add a host device to the container (e.g. --device=/dev/sdc:/dev/xvdc)")
+	cmd.Var(&flDeviceCgroupRules, []string{"-device-cgroup-rule"}, "Add a rule to the device cgroup of the container (e.g. --device-cgroup-rule='c 189:* rwm')")
 	cmd.Var(&flEnv, []string{"e", "-env"}, "Set environment variables")
 	cmd.Var(&flEnvFile, []string{"-env-file"}, "Read in a line delimited file of environment variables")
 
@@ -90,11 +103,14 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 	cmd.Var(&flExpose, []string{"#expose", "-expose"}, "Expose a port from the container without publishing it to your host")
 	cmd.Var(&flDns, []string{"#dns", "-dns"}, "Set custom DNS servers")
 	cmd.Var(&flDnsSearch, []string{"-dns-search"}, "Set custom DNS search domains")
-	cmd.Var(&flVolumesFrom, []string{"#volumes-from", "-volumes-from"}, "Mount volumes from the specified container(s)")
+	cmd.Var(&flVolumesFrom, []string{"#volumes-from", "-volumes-from"}, "Mount volumes from the specified container(s), in the form of container[:ro|rw]")
 	cmd.Var(&flLxcOpts, []string{"#lxc-conf", "-lxc-conf"}, "(lxc exec-driver only) Add custom lxc options --lxc-conf=\"lxc.cgroup.cpuset.cpus = 0,1\"")
 
 	cmd.Var(&flCapAdd, []string{"-cap-add"}, "Add Linux capabilities")
 	cmd.Var(&flCapDrop, []string{"-cap-drop"}, "Drop Linux capabilities")
+	cmd.Var(&flLogOpts, []string{"-log-opt"}, "Log driver specific options in the form of key=value")
+	cmd.Var(&flUlimits, []string{"-ulimit"}, "Set a resource limit of the container in the form of name=soft[:hard] (core, memlock, nofile, nproc)")
+	cmd.Var(&flMountOpts, []string{"-mount-opt"}, "Set mount options of a volume in the form of /container/path:opt[,opt] (nosuid, nodev, noexec, uid=UID, gid=GID, copy, nocopy)")
 
 	if err := cmd.Parse(args); err != nil {
 		return nil, nil, cmd, err
@@ -197,11 +213,11 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 	}
 
 	// Merge in exposed ports to the map of published ports
-	for _, e := range flExpose.GetAll() {
-		if strings.Contains(e, ":") {
-			return nil, nil, cmd, fmt.Errorf("Invalid port format for --expose: %s", e)
-		}
-		p := nat.NewPort(nat.SplitProtoPort(e))
+	exposedPorts, err := nat.ParseExposedPorts(flExpose.GetAll())
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+	for p := range exposedPorts {
 		if _, exists := ports[p]; !exists {
 			ports[p] = struct{}{}
 		}
@@ -239,6 +255,41 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 		return nil, nil, cmd, err
 	}
 
+	for _, spec := range flVolumesFrom.GetAll() {
+		if _, _, err := ParseVolumesFrom(spec); err != nil {
+			return nil, nil, cmd, err
+		}
+	}
+
+	logOpts, err := parseLogOpts(flLogOpts)
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
+	mountOpts, err := parseMountOpts(flMountOpts)
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
+	ulimits, err := parseUlimits(flUlimits)
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
+	if *flGpus != "" {
+		if _, err := ParseGpus(*flGpus); err != nil {
+			return nil, nil, cmd, err
+		}
+	}
+
+	if *flLxcTemplate != "" && !path.IsAbs(*flLxcTemplate) {
+		return nil, nil, cmd, fmt.Errorf("Invalid lxc template %s, the path must be absolute", *flLxcTemplate)
+	}
+
+	if *flSeccompProfile != "" && !path.IsAbs(*flSeccompProfile) {
+		return nil, nil, cmd, fmt.Errorf("Invalid seccomp profile %s, the path must be absolute", *flSeccompProfile)
+	}
+
 	if *flAutoRemove && (restartPolicy.Name == "always" || restartPolicy.Name == "on-failure") {
 		return nil, nil, cmd, ErrConflictRestartPolicyAndAutoRemove
 	}
@@ -267,21 +318,30 @@ func parseRun(cmd *flag.FlagSet, args []string, sysInfo *sysinfo.SysInfo) (*Conf
 	}
 
 	hostConfig := &HostConfig{
-		Binds:           binds,
-		ContainerIDFile: *flContainerIDFile,
-		LxcConf:         lxcConf,
-		Privileged:      *flPrivileged,
-		PortBindings:    portBindings,
-		Links:           flLinks.GetAll(),
-		PublishAllPorts: *flPublishAll,
-		Dns:             flDns.GetAll(),
-		DnsSearch:       flDnsSearch.GetAll(),
-		VolumesFrom:     flVolumesFrom.GetAll(),
-		NetworkMode:     netMode,
-		Devices:         deviceMappings,
-		CapAdd:          flCapAdd.GetAll(),
-		CapDrop:         flCapDrop.GetAll(),
-		RestartPolicy:   restartPolicy,
+		Binds:             binds,
+		ContainerIDFile:   *flContainerIDFile,
+		LxcConf:           lxcConf,
+		LxcTemplate:       *flLxcTemplate,
+		LxcFragment:       *flLxcFragment,
+		Privileged:        *flPrivileged,
+		PortBindings:      portBindings,
+		Links:             flLinks.GetAll(),
+		PublishAllPorts:   *flPublishAll,
+		Dns:               flDns.GetAll(),
+		DnsSearch:         flDnsSearch.GetAll(),
+		VolumesFrom:       flVolumesFrom.GetAll(),
+		NetworkMode:       netMode,
+		Devices:           deviceMappings,
+		DeviceCgroupRules: flDeviceCgroupRules.GetAll(),
+		CapAdd:            flCapAdd.GetAll(),
+		CapDrop:           flCapDrop.GetAll(),
+		RestartPolicy:     restartPolicy,
+		LogConfig:         LogConfig{Type: *flLogDriver, Options: logOpts},
+		MountOptions:      mountOpts,
+		Ulimits:           ulimits,
+		Gpus:              *flGpus,
+		SeccompProfile:    *flSeccompProfile,
+		SeccompAudit:      *flSeccompAudit,
 	}
 
 	if sysInfo != nil && flMemory > 0 && !sysInfo.SwapLimit {
@@ -355,6 +415,65 @@ func parseDriverOpts(opts opts.ListOpts) (map[string][]string, error) {
 	return out, nil
 }
 
+// parseLogOpts parses the key=value log driver options into a map, nil if
+// there are none.
+func parseLogOpts(opts opts.ListOpts) (map[string]string, error) {
+	if opts.Len() == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, opts.Len())
+	for _, o := range opts.GetAll() {
+		k, v, err := parsers.ParseKeyValueOpt(o)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// parseMountOpts parses the --mount-opt specifications into the options of
+// every volume path, nil if there are none.
+func parseMountOpts(opts opts.ListOpts) (map[string][]string, error) {
+	if opts.Len() == 0 {
+		return nil, nil
+	}
+	out := make(map[string][]string)
+	for _, spec := range opts.GetAll() {
+		volPath, options, err := ParseMountOptions(spec)
+		if err != nil {
+			return nil, err
+		}
+		out[volPath] = append(out[volPath], options...)
+		if err := ValidateMountOptions(out[volPath]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// parseUlimits parses the --ulimit specifications, a later one replacing the
+// limits of the same resource.
+func parseUlimits(opts opts.ListOpts) ([]*ulimit.Ulimit, error) {
+	var (
+		out     []*ulimit.Ulimit
+		indexes = make(map[string]int)
+	)
+	for _, spec := range opts.GetAll() {
+		u, err := ulimit.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		if i, exists := indexes[u.Name]; exists {
+			out[i] = u
+			continue
+		}
+		indexes[u.Name] = len(out)
+		out = append(out, u)
+	}
+	return out, nil
+}
+
 func parseKeyValueOpts(opts opts.ListOpts) ([]utils.KeyValuePair, error) {
 	out := make([]utils.KeyValuePair, opts.Len())
 	for i, o := range opts.GetAll() {
@@ -410,3 +529,128 @@ func ParseDevice(device string) (DeviceMapping, error) {
 	}
 	return deviceMapping, nil
 }
+
+// ParseVolumesFrom parses a --volumes-from specification in the
+// container[:ro|rw] format. It returns the container and whether its volumes
+// are mounted read-write, which they are by default.
+func ParseVolumesFrom(spec string) (string, bool, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if parts[0] == "" {
+		return "", false, fmt.Errorf("Malformed volumes-from specification: %s", spec)
+	}
+	if len(parts) == 1 {
+		return parts[0], true, nil
+	}
+	switch parts[1] {
+	case "ro":
+		return parts[0], false, nil
+	case "rw":
+		return parts[0], true, nil
+	}
+	return "", false, fmt.Errorf("Malformed volumes-from specification: %s", spec)
+}
+
+// ParseMountOptions parses a --mount-opt specification in the
+// /container/path:opt[,opt] format.
+func ParseMountOptions(spec string) (string, []string, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 || !path.IsAbs(parts[0]) || parts[1] == "" {
+		return "", nil, fmt.Errorf("Malformed mount-opt specification: %s", spec)
+	}
+	options := strings.Split(parts[1], ",")
+	if err := ValidateMountOptions(options); err != nil {
+		return "", nil, err
+	}
+	return path.Clean(parts[0]), options, nil
+}
+
+// ValidateMountOptions checks the options of a volume are all supported
+// and don't conflict.
+func ValidateMountOptions(options []string) error {
+	var copyData, noCopy bool
+	for _, opt := range options {
+		if err := ValidateMountOption(opt); err != nil {
+			return err
+		}
+		switch opt {
+		case "copy":
+			copyData = true
+		case "nocopy":
+			noCopy = true
+		}
+	}
+	if copyData && noCopy {
+		return fmt.Errorf("Conflicting mount options: copy and nocopy")
+	}
+	return nil
+}
+
+// ValidateMountOption checks opt is one of the options supported on
+// volumes: nosuid, nodev, noexec, the uid=UID and gid=GID owner of the
+// volume mountpoint, or copy and nocopy to populate a new volume from the
+// image or not.
+func ValidateMountOption(opt string) error {
+	switch opt {
+	case "nosuid", "nodev", "noexec", "copy", "nocopy":
+		return nil
+	}
+	parts := strings.SplitN(opt, "=", 2)
+	if len(parts) == 2 && (parts[0] == "uid" || parts[0] == "gid") {
+		if id, err := strconv.Atoi(parts[1]); err == nil && id >= 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid mount option %s, only nosuid, nodev, noexec, uid=UID, gid=GID, copy and nocopy are supported", opt)
+}
+
+// VolumeCopyEnabled tells whether a new volume mounted with options is
+// populated from the content of the image at its path. It is unless the
+// nocopy option is set.
+func VolumeCopyEnabled(options []string) bool {
+	for _, opt := range options {
+		if opt == "nocopy" {
+			return false
+		}
+	}
+	return true
+}
+
+// VolumeOwner returns the uid and gid a new volume mounted with options is
+// owned by, -1 when unchanged.
+func VolumeOwner(options []string) (uid int, gid int) {
+	uid, gid = -1, -1
+	for _, opt := range options {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		id, err := strconv.Atoi(parts[1])
+		if err != nil || id < 0 {
+			continue
+		}
+		switch parts[0] {
+		case "uid":
+			uid = id
+		case "gid":
+			gid = id
+		}
+	}
+	return uid, gid
+}
+
+// ParseGpus parses a --gpus specification: all, or the comma separated
+// numbers of the GPUs, which are returned, nil for all.
+func ParseGpus(spec string) ([]int, error) {
+	if spec == "all" {
+		return nil, nil
+	}
+	var numbers []int
+	for _, n := range strings.Split(spec, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("Invalid --gpus %s, expected all or the numbers of the GPUs (e.g. 0,1)", spec)
+		}
+		numbers = append(numbers, i)
+	}
+	return numbers, nil
+}
@@ -48,3 +48,108 @@ func TestNetHostname(t *testing.T) {
 		t.Fatalf("Expected error ErrConflictNetworkHostname, got: %s", err)
 	}
 }
+
+func TestParseLogOpts(t *testing.T) {
+	_, hostConfig, _, err := Parse([]string{"--log-driver=json-file", "--log-opt", "max-size=10m", "--log-opt", "max-file=2", "img", "cmd"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if hostConfig.LogConfig.Type != "json-file" {
+		t.Fatalf("Expected json-file log driver, got %s", hostConfig.LogConfig.Type)
+	}
+	if len(hostConfig.LogConfig.Options) != 2 || hostConfig.LogConfig.Options["max-size"] != "10m" || hostConfig.LogConfig.Options["max-file"] != "2" {
+		t.Fatalf("Unexpected log options: %v", hostConfig.LogConfig.Options)
+	}
+
+	if _, _, _, err := Parse([]string{"--log-opt", "max-size", "img", "cmd"}, nil); err == nil {
+		t.Fatal("Expected an error for a log option without value")
+	}
+}
+
+func TestParseVolumesFrom(t *testing.T) {
+	for spec, expected := range map[string]struct {
+		name string
+		rw   bool
+	}{
+		"data":    {"data", true},
+		"data:rw": {"data", true},
+		"data:ro": {"data", false},
+	} {
+		name, rw, err := ParseVolumesFrom(spec)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", spec, err)
+		}
+		if name != expected.name || rw != expected.rw {
+			t.Fatalf("Expected %s and %v for %s, got %s and %v", expected.name, expected.rw, spec, name, rw)
+		}
+	}
+	for _, spec := range []string{"", ":ro", "data:rx", "data:ro:rw"} {
+		if _, _, err := ParseVolumesFrom(spec); err == nil {
+			t.Fatalf("Expected an error for %q", spec)
+		}
+	}
+	if _, _, _, err := Parse([]string{"--volumes-from", "data:rx", "img", "cmd"}, nil); err == nil {
+		t.Fatal("Expected an error for an invalid --volumes-from mode")
+	}
+}
+
+func TestParseMountOpts(t *testing.T) {
+	_, hostConfig, _, err := Parse([]string{"--mount-opt", "/data/:nosuid,nodev", "--mount-opt", "/data:uid=1000", "-v", "/data", "img", "cmd"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if opts := hostConfig.MountOptions["/data"]; len(opts) != 3 || opts[0] != "nosuid" || opts[1] != "nodev" || opts[2] != "uid=1000" {
+		t.Fatalf("Unexpected mount options: %v", hostConfig.MountOptions)
+	}
+	if !VolumeCopyEnabled(hostConfig.MountOptions["/data"]) {
+		t.Fatal("Expected volumes to be populated by default")
+	}
+	if VolumeCopyEnabled([]string{"nosuid", "nocopy"}) {
+		t.Fatal("Expected nocopy to disable the volume population")
+	}
+	if uid, gid := VolumeOwner(hostConfig.MountOptions["/data"]); uid != 1000 || gid != -1 {
+		t.Fatalf("Expected the volume to be owned by 1000:-1, got %d:%d", uid, gid)
+	}
+	if _, _, _, err := Parse([]string{"--mount-opt", "/data:copy", "--mount-opt", "/data:nocopy", "img", "cmd"}, nil); err == nil {
+		t.Fatal("Expected an error for conflicting copy options")
+	}
+	for _, spec := range []string{"/data", "data:nosuid", "/data:", "/data:suid", "/data:uid=-1", "/data:gid=root", "/data:copy,nocopy"} {
+		if _, _, err := ParseMountOptions(spec); err == nil {
+			t.Fatalf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestParseGpus(t *testing.T) {
+	_, hostConfig, _, err := Parse([]string{"--gpus", "0,1", "img", "cmd"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if hostConfig.Gpus != "0,1" {
+		t.Fatalf("Unexpected gpus: %q", hostConfig.Gpus)
+	}
+	if numbers, err := ParseGpus("all"); err != nil || numbers != nil {
+		t.Fatalf("Expected all the GPUs, got %v, %v", numbers, err)
+	}
+	if numbers, err := ParseGpus("0, 2"); err != nil || len(numbers) != 2 || numbers[0] != 0 || numbers[1] != 2 {
+		t.Fatalf("Expected GPUs 0 and 2, got %v, %v", numbers, err)
+	}
+	for _, spec := range []string{"", "none", "0,", "-1"} {
+		if _, err := ParseGpus(spec); err == nil {
+			t.Fatalf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestParseUlimits(t *testing.T) {
+	_, hostConfig, _, err := Parse([]string{"--ulimit", "nofile=512:1024", "--ulimit", "nproc=100", "--ulimit", "nofile=2048", "img", "cmd"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(hostConfig.Ulimits) != 2 || hostConfig.Ulimits[0].String() != "nofile=2048:2048" || hostConfig.Ulimits[1].String() != "nproc=100:100" {
+		t.Fatalf("Unexpected ulimits: %v", hostConfig.Ulimits)
+	}
+	if _, _, _, err := Parse([]string{"--ulimit", "nofile=1024:512", "img", "cmd"}, nil); err == nil {
+		t.Fatal("Expected an error for a soft limit over the hard limit")
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IDGenerator generates the IDs of the containers and the images, made of 64
+// hexadecimal digits.
+type IDGenerator interface {
+	GenerateID() string
+}
+
+var (
+	idGeneratorLock sync.RWMutex
+	idGenerator     IDGenerator = RandomIDGenerator{}
+)
+
+// SetIDGenerator sets the generator used by GenerateRandomID.
+func SetIDGenerator(generator IDGenerator) {
+	idGeneratorLock.Lock()
+	idGenerator = generator
+	idGeneratorLock.Unlock()
+}
+
+// NewIDGenerator returns the generator of the given name: random or
+// sortable. The deterministic generator, whose IDs repeat from one start of
+// the daemon to the next, is only set by the tests.
+func NewIDGenerator(name string) (IDGenerator, error) {
+	switch name {
+	case "", "random":
+		return RandomIDGenerator{}, nil
+	case "sortable":
+		return SortableIDGenerator{}, nil
+	}
+	return nil, fmt.Errorf("Unknown ID generator %s, expected random or sortable", name)
+}
+
+// RandomIDGenerator generates 256 random bits.
+type RandomIDGenerator struct{}
+
+func (RandomIDGenerator) GenerateID() string {
+	for {
+		id := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, id); err != nil {
+			panic(err) // This shouldn't happen
+		}
+		if value := hex.EncodeToString(id); isValidGeneratedID(value) {
+			return value
+		}
+	}
+}
+
+// SortableIDGenerator generates IDs sorting in the order they were generated
+// in, to the second, once their short form is skipped: 48 random bits, for
+// the short IDs generated in the same second not to collide, the time in
+// seconds since the epoch, in 32 bits, and 176 random bits.
+type SortableIDGenerator struct{}
+
+// sortableTimeOffset is the offset of the time in the sortable IDs, in bytes,
+// the short IDs being the first 12 hexadecimal characters.
+const sortableTimeOffset = 6
+
+func (SortableIDGenerator) GenerateID() string {
+	for {
+		id := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, id); err != nil {
+			panic(err) // This shouldn't happen
+		}
+		binary.BigEndian.PutUint32(id[sortableTimeOffset:], uint32(time.Now().Unix()))
+		if value := hex.EncodeToString(id); isValidGeneratedID(value) {
+			return value
+		}
+	}
+}
+
+// DeterministicIDGenerator generates the same sequence of IDs for the same
+// seed, the hashes of the seed and a counter. It is meant for tests: the
+// counter restarting at each start of the daemon, the IDs would repeat.
+type DeterministicIDGenerator struct {
+	sync.Mutex
+	seed    string
+	counter uint64
+}
+
+func NewDeterministicIDGenerator(seed string) *DeterministicIDGenerator {
+	return &DeterministicIDGenerator{seed: seed}
+}
+
+func (g *DeterministicIDGenerator) GenerateID() string {
+	g.Lock()
+	defer g.Unlock()
+	for {
+		g.counter++
+		sum := sha256.Sum256([]byte(g.seed + ":" + strconv.FormatUint(g.counter, 10)))
+		if value := hex.EncodeToString(sum[:]); isValidGeneratedID(value) {
+			return value
+		}
+	}
+}
+
+// isValidGeneratedID returns false if the truncated form of id is all
+// numeric, which causes issues when used as a hostname. ref #3869
+func isValidGeneratedID(id string) bool {
+	_, err := strconv.ParseInt(TruncateID(id), 10, 64)
+	return err != nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+)
+
+var validGeneratedID = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+func TestIDGenerators(t *testing.T) {
+	for _, name := range []string{"random", "sortable"} {
+		generator, err := NewIDGenerator(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			id := generator.GenerateID()
+			if !validGeneratedID.MatchString(id) {
+				t.Fatalf("%s: invalid id %s", name, id)
+			}
+			if !isValidGeneratedID(id) {
+				t.Fatalf("%s: the truncated id of %s is numeric", name, id)
+			}
+			if seen[id] {
+				t.Fatalf("%s: id %s generated twice", name, id)
+			}
+			seen[id] = true
+		}
+	}
+	for _, name := range []string{"uuid", "deterministic"} {
+		if _, err := NewIDGenerator(name); err == nil {
+			t.Fatalf("Expected an error for the generator %s", name)
+		}
+	}
+}
+
+func TestSortableIDGenerator(t *testing.T) {
+	first := SortableIDGenerator{}.GenerateID()
+	second := SortableIDGenerator{}.GenerateID()
+	if first[12:20] > second[12:20] {
+		t.Fatalf("Expected %s to sort before %s", first, second)
+	}
+
+	// The short IDs generated in the same second stay random
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		short := TruncateID(SortableIDGenerator{}.GenerateID())
+		if seen[short] {
+			t.Fatalf("Short id %s generated twice", short)
+		}
+		seen[short] = true
+	}
+}
+
+func TestDeterministicIDGenerator(t *testing.T) {
+	a, b := NewDeterministicIDGenerator("test"), NewDeterministicIDGenerator("test")
+	for i := 0; i < 10; i++ {
+		if idA, idB := a.GenerateID(), b.GenerateID(); idA != idB {
+			t.Fatalf("Expected the same ids, got %s and %s", idA, idB)
+		}
+	}
+	if NewDeterministicIDGenerator("other").GenerateID() == NewDeterministicIDGenerator("test").GenerateID() {
+		t.Fatal("Expected different ids for different seeds")
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	defer SetIDGenerator(RandomIDGenerator{})
+
+	SetIDGenerator(NewDeterministicIDGenerator("test"))
+	expected := NewDeterministicIDGenerator("test").GenerateID()
+	if id := GenerateRandomID(); id != expected {
+		t.Fatalf("Expected %s, got %s", expected, id)
+	}
+}
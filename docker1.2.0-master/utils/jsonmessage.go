@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -72,16 +73,31 @@ func (p *JSONProgress) String() string {
 	return pbBox + numbersBox + timeLeftBox
 }
 
+// JSONBuildStep is the machine-readable progress of a build instruction.
+type JSONBuildStep struct {
+	Step        string  `json:"step"`
+	Instruction string  `json:"instruction"`
+	Status      string  `json:"status"` // started, done, skipped or failed
+	Cached      bool    `json:"cached,omitempty"`
+	ID          string  `json:"id,omitempty"`
+	Duration    float64 `json:"duration,omitempty"` // in seconds
+	Error       string  `json:"error,omitempty"`
+}
+
 type JSONMessage struct {
-	Stream          string        `json:"stream,omitempty"`
-	Status          string        `json:"status,omitempty"`
-	Progress        *JSONProgress `json:"progressDetail,omitempty"`
-	ProgressMessage string        `json:"progress,omitempty"` //deprecated
-	ID              string        `json:"id,omitempty"`
-	From            string        `json:"from,omitempty"`
-	Time            int64         `json:"time,omitempty"`
-	Error           *JSONError    `json:"errorDetail,omitempty"`
-	ErrorMessage    string        `json:"error,omitempty"` //deprecated
+	Stream          string         `json:"stream,omitempty"`
+	Status          string         `json:"status,omitempty"`
+	Progress        *JSONProgress  `json:"progressDetail,omitempty"`
+	ProgressMessage string         `json:"progress,omitempty"` //deprecated
+	ID              string         `json:"id,omitempty"`
+	From            string         `json:"from,omitempty"`
+	Time            int64          `json:"time,omitempty"`
+	Error           *JSONError     `json:"errorDetail,omitempty"`
+	ErrorMessage    string         `json:"error,omitempty"` //deprecated
+	BuildStep       *JSONBuildStep `json:"buildStep,omitempty"`
+	// Attributes are the details of an event, e.g. the exit code of a
+	// container for die
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
@@ -91,6 +107,10 @@ func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
 		}
 		return jm.Error
 	}
+	if jm.BuildStep != nil {
+		// The text of the step is in the stream already
+		return nil
+	}
 	var endl string
 	if isTerminal && jm.Stream == "" && jm.Progress != nil {
 		// <ESC>[2K = erase entire current line
@@ -114,12 +134,29 @@ func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
 		fmt.Fprintf(out, "%s %s%s", jm.Status, jm.ProgressMessage, endl)
 	} else if jm.Stream != "" {
 		fmt.Fprintf(out, "%s%s", jm.Stream, endl)
+	} else if len(jm.Attributes) > 0 {
+		fmt.Fprintf(out, "%s (%s)%s\n", jm.Status, jm.attributesString(), endl)
 	} else {
 		fmt.Fprintf(out, "%s%s\n", jm.Status, endl)
 	}
 	return nil
 }
 
+// attributesString returns the attributes of the message sorted by name, e.g.
+// exitCode=0, maxRss=1024.
+func (jm *JSONMessage) attributesString() string {
+	names := make([]string, 0, len(jm.Attributes))
+	for name := range jm.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attributes := make([]string, len(names))
+	for i, name := range names {
+		attributes[i] = name + "=" + jm.Attributes[name]
+	}
+	return strings.Join(attributes, ", ")
+}
+
 func DisplayJSONMessagesStream(in io.Reader, out io.Writer, terminalFd uintptr, isTerminal bool) error {
 	var (
 		dec  = json.NewDecoder(in)
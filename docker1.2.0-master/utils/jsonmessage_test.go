@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -36,3 +37,15 @@ func TestProgress(t *testing.T) {
 		t.Fatalf("Expected %q, got %q", expected, jp4.String())
 	}
 }
+
+func TestDisplayAttributes(t *testing.T) {
+	jm := JSONMessage{Status: "die", ID: "cont", Attributes: map[string]string{"maxRss": "1024", "exitCode": "0"}}
+	var out bytes.Buffer
+	if err := jm.Display(&out, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "cont: die (exitCode=0, maxRss=1024)\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, out.String())
+	}
+}
@@ -55,6 +55,19 @@ func (sf *StreamFormatter) FormatError(err error) []byte {
 	return []byte("Error: " + err.Error() + streamNewline)
 }
 
+// FormatBuildStep formats the progress of a build step, which is only
+// given to the JSON streams.
+func (sf *StreamFormatter) FormatBuildStep(step *JSONBuildStep) []byte {
+	if !sf.json {
+		return nil
+	}
+	b, err := json.Marshal(&JSONMessage{BuildStep: step})
+	if err != nil {
+		return sf.FormatError(err)
+	}
+	return append(b, streamNewlineBytes...)
+}
+
 func (sf *StreamFormatter) FormatProgress(id, action string, progress *JSONProgress) []byte {
 	if progress == nil {
 		progress = &JSONProgress{}
@@ -23,6 +23,17 @@ func TestFormatStatus(t *testing.T) {
 	}
 }
 
+func TestFormatBuildStep(t *testing.T) {
+	sf := NewStreamFormatter(true)
+	res := sf.FormatBuildStep(&JSONBuildStep{Step: "1", Instruction: "RUN", Status: "done", Cached: true, ID: "ID"})
+	if string(res) != `{"buildStep":{"step":"1","instruction":"RUN","status":"done","cached":true,"id":"ID"}}`+"\r\n" {
+		t.Fatalf("%q", res)
+	}
+	if res := NewStreamFormatter(false).FormatBuildStep(&JSONBuildStep{}); res != nil {
+		t.Fatalf("Expected no build step in a text stream, got %q", res)
+	}
+}
+
 func TestFormatSimpleError(t *testing.T) {
 	sf := NewStreamFormatter(true)
 	res := sf.FormatError(errors.New("Error for formatter"))
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"bytes"
-	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/hex"
@@ -253,22 +252,13 @@ func TruncateID(id string) string {
 	return id[:shortLen]
 }
 
-// GenerateRandomID returns an unique id
+// GenerateRandomID returns an unique id, from the generator set with
+// SetIDGenerator, random by default
 func GenerateRandomID() string {
-	for {
-		id := make([]byte, 32)
-		if _, err := io.ReadFull(rand.Reader, id); err != nil {
-			panic(err) // This shouldn't happen
-		}
-		value := hex.EncodeToString(id)
-		// if we try to parse the truncated for as an int and we don't have
-		// an error then the value is all numberic and causes issues when
-		// used as a hostname. ref #3869
-		if _, err := strconv.ParseInt(TruncateID(value), 10, 64); err == nil {
-			continue
-		}
-		return value
-	}
+	idGeneratorLock.RLock()
+	generator := idGenerator
+	idGeneratorLock.RUnlock()
+	return generator.GenerateID()
 }
 
 func ValidateID(id string) error {
@@ -390,6 +380,27 @@ func IsGIT(str string) bool {
 	return strings.HasPrefix(str, "git://") || strings.HasPrefix(str, "github.com/") || strings.HasPrefix(str, "git@github.com:") || (strings.HasSuffix(str, ".git") && IsURL(str))
 }
 
+// GitClone clones the repository at remoteURL with its submodules into root.
+// ref, if set, is the branch or tag to check out, and a positive depth
+// truncates the history to that many commits.
+func GitClone(remoteURL, ref string, depth int, root string) error {
+	if !strings.HasPrefix(remoteURL, "git://") && !strings.HasPrefix(remoteURL, "git@") && !IsURL(remoteURL) {
+		remoteURL = "https://" + remoteURL
+	}
+	args := []string{"clone", "--recursive"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	args = append(args, remoteURL, root)
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("Error trying to use git: %s (%s)", err, output)
+	}
+	return nil
+}
+
 // CheckLocalDns looks into the /etc/resolv.conf,
 // it returns true if there is a local nameserver or if there is no nameserver.
 func CheckLocalDns(resolvConf []byte) bool {